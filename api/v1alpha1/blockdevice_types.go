@@ -64,6 +64,11 @@ type DeviceSpec struct {
 	// +optional
 	ParentDevice string `json:"parentDevice,omitempty"`
 
+	// PartitionInfo has the slot of the partition on the parent disk, if the
+	// blockdevice is a partition
+	// +optional
+	PartitionInfo *DevicePartitionInfo `json:"partitionInfo,omitempty"`
+
 	// Partitioned represents if BlockDevice has partitions or not (Yes/No)
 	// Currently always default to No.
 	// To be deprecated
@@ -116,6 +121,22 @@ type DeviceCapacity struct {
 	LogicalSectorSize uint32 `json:"logicalSectorSize"`
 }
 
+// DevicePartitionInfo defines the location of a partition on the parent disk
+type DevicePartitionInfo struct {
+	// PartitionGUID is the unique GUID of the partition in a GPT partition table
+	// +optional
+	PartitionGUID string `json:"partitionGUID,omitempty"`
+
+	// PartitionNumber is the number of the partition in the partition table of the parent disk
+	// +optional
+	PartitionNumber uint8 `json:"partitionNumber,omitempty"`
+
+	// StartOffset is the offset in bytes of the start of the partition from the start
+	// of the parent disk
+	// +optional
+	StartOffset uint64 `json:"startOffset,omitempty"`
+}
+
 // DeviceDetails represent certain hardware/static attributes of the block device
 type DeviceDetails struct {
 	// DeviceType represents the type of device like
@@ -163,6 +184,54 @@ type DeviceDetails struct {
 	// FirmwareRevision is the disk firmware revision
 	// +optional
 	FirmwareRevision string `json:"firmwareRevision"`
+
+	// FormFactor is the nominal form factor of the disk, eg: 2.5", M.2
+	// +optional
+	FormFactor string `json:"formFactor,omitempty"`
+
+	// WriteEnduranceTBW is the write endurance of the SSD in terabytes written,
+	// estimated from the bytes written and the percentage of endurance used
+	// +optional
+	WriteEnduranceTBW uint64 `json:"writeEnduranceTBW,omitempty"`
+
+	// DiscardSupported is true if the device supports discard (TRIM / UNMAP)
+	// +optional
+	DiscardSupported bool `json:"discardSupported,omitempty"`
+
+	// DiscardGranularity is the size in bytes of the unit in which the
+	// device discards blocks
+	// +optional
+	DiscardGranularity uint64 `json:"discardGranularity,omitempty"`
+
+	// IOScheduler is the active IO scheduler of the device, eg: mq-deadline, none
+	// reported by /sys/class/block/sda/queue/scheduler
+	// +optional
+	IOScheduler string `json:"ioScheduler,omitempty"`
+
+	// QueueDepth is the number of requests that can be queued for the device
+	// reported by /sys/class/block/sda/queue/nr_requests
+	// +optional
+	QueueDepth uint64 `json:"queueDepth,omitempty"`
+
+	// WriteCache is the state of the volatile write cache of the device, enabled or
+	// disabled, reported by /sys/class/block/sda/queue/write_cache
+	// +optional
+	WriteCache string `json:"writeCache,omitempty"`
+
+	// Transport is the transport over which the device is attached, eg: nvme-pcie,
+	// nvme-tcp, nvme-rdma, nvme-fc, iser
+	// +optional
+	Transport string `json:"transport,omitempty"`
+
+	// FabricTarget is the NQN of the NVMe-oF subsystem or the IQN of the iSER target
+	// that exports the device
+	// +optional
+	FabricTarget string `json:"fabricTarget,omitempty"`
+
+	// FabricAddress is the address (portal) of the fabric target,
+	// eg: traddr=10.0.0.1,trsvcid=4420
+	// +optional
+	FabricAddress string `json:"fabricAddress,omitempty"`
 }
 
 // FileSystemInfo defines the filesystem type and mountpoint of the device if it exists
@@ -178,8 +247,9 @@ type FileSystemInfo struct {
 
 // DeviceDevLink holds the mapping between type and links like by-id type or by-path type link
 type DeviceDevLink struct {
-	// Kind is the type of link like by-id or by-path.
-	// +kubebuilder:validation:Enum:=by-id;by-path
+	// Kind is the type of link like by-id or by-path. The dm-name kind is
+	// the /dev/mapper link of a dm device.
+	// +kubebuilder:validation:Enum:=by-id;by-path;by-uuid;by-partuuid;by-label;by-partlabel;dm-name
 	Kind string `json:"kind,omitempty"`
 
 	// Links are the soft links
@@ -192,9 +262,87 @@ type DeviceStatus struct {
 	// +kubebuilder:validation:Enum:=Claimed;Unclaimed;Released
 	ClaimState DeviceClaimState `json:"claimState"`
 
-	// State is the current state of the blockdevice (Active/Inactive/Unknown)
-	// +kubebuilder:validation:Enum:=Active;Inactive;Unknown
+	// State is the current state of the blockdevice (Active/Inactive/Unknown/Quarantined)
+	// +kubebuilder:validation:Enum:=Active;Inactive;Unknown;Quarantined
 	State BlockDeviceState `json:"state"`
+
+	// Conditions are the observations of the health of the blockdevice, like the
+	// device running over temperature
+	// +optional
+	Conditions []BlockDeviceCondition `json:"conditions,omitempty"`
+
+	// Parent is the name of the blockdevice of the disk, if the device is a partition
+	// +optional
+	Parent string `json:"parent,omitempty"`
+
+	// Children are the names of the blockdevices of the partitions of the device
+	// +optional
+	Children []string `json:"children,omitempty"`
+}
+
+// BlockDeviceConditionType is the type of a condition of the blockdevice
+type BlockDeviceConditionType string
+
+const (
+	// BlockDeviceOverTemperature is the condition of a blockdevice whose temperature, as
+	// reported by SMART, is above the configured threshold
+	BlockDeviceOverTemperature BlockDeviceConditionType = "OverTemperature"
+
+	// BlockDeviceLayoutViolation is the condition of a partition that does not respect the
+	// layout of its parent disk, eg: it starts in the area reserved by NDM
+	BlockDeviceLayoutViolation BlockDeviceConditionType = "LayoutViolation"
+
+	// BlockDeviceCapacityShrink is the condition of a claimed blockdevice whose capacity
+	// has decreased below the recorded capacity
+	BlockDeviceCapacityShrink BlockDeviceConditionType = "CapacityShrink"
+
+	// BlockDeviceDegradedLink is the condition of an NVMe blockdevice whose PCIe link
+	// negotiated a lower speed or width than its maximum
+	BlockDeviceDegradedLink BlockDeviceConditionType = "DegradedLink"
+
+	// BlockDeviceErrorState is the condition of a SCSI blockdevice that reported more IO
+	// errors than the configured threshold within the error window
+	BlockDeviceErrorState BlockDeviceConditionType = "ErrorState"
+
+	// BlockDeviceMediaErrors is the condition of a blockdevice on which the surface scan
+	// found sectors that cannot be read
+	BlockDeviceMediaErrors BlockDeviceConditionType = "MediaErrors"
+
+	// BlockDeviceWritable is the condition of a blank disk that passed the writability
+	// check of NDM. Unlike the other conditions, the disk is healthy if it is True.
+	BlockDeviceWritable BlockDeviceConditionType = "Writable"
+
+	// BlockDeviceUnexpectedSignature is the condition of a partition created by NDM on a
+	// blank disk, that was found to have a filesystem or other signature, ie. the disk was
+	// not actually blank
+	BlockDeviceUnexpectedSignature BlockDeviceConditionType = "UnexpectedSignature"
+
+	// BlockDeviceCorruptPartitionTable is the condition of a disk whose partition table
+	// cannot be trusted, eg: it has overlapping entries or a bad CRC, and of the partitions
+	// on such a disk
+	BlockDeviceCorruptPartitionTable BlockDeviceConditionType = "CorruptPartitionTable"
+)
+
+// BlockDeviceCondition is an observation of the health of the blockdevice
+type BlockDeviceCondition struct {
+	// Type is the type of the condition
+	Type BlockDeviceConditionType `json:"type"`
+
+	// Status is the status of the condition (True/False/Unknown)
+	// +kubebuilder:validation:Enum:=True;False;Unknown
+	Status v1.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the time at which the condition last changed its status
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a one word reason for the status of the condition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable message with details of the condition
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // DeviceClaimState defines the observed state of BlockDevice
@@ -226,6 +374,10 @@ const (
 	// BlockDeviceUnknown is the state for a block device whose state (attached/detached) cannot
 	// be determined at this time.
 	BlockDeviceUnknown BlockDeviceState = "Unknown"
+
+	// BlockDeviceQuarantined is the state for a block device having conflicting or suspicious
+	// metadata. The device cannot be claimed and no destructive operations are performed on it.
+	BlockDeviceQuarantined BlockDeviceState = "Quarantined"
 )
 
 //+kubebuilder:object:root=true
@@ -16,7 +16,7 @@ func (in *BlockDevice) DeepCopyInto(out *BlockDevice) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockDevice.
@@ -96,6 +96,22 @@ func (in *BlockDeviceClaimList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockDeviceCondition) DeepCopyInto(out *BlockDeviceCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockDeviceCondition.
+func (in *BlockDeviceCondition) DeepCopy() *BlockDeviceCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockDeviceCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BlockDeviceList) DeepCopyInto(out *BlockDeviceList) {
 	*out = *in
@@ -268,6 +284,21 @@ func (in *DeviceDevLink) DeepCopy() *DeviceDevLink {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePartitionInfo) DeepCopyInto(out *DevicePartitionInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePartitionInfo.
+func (in *DevicePartitionInfo) DeepCopy() *DevicePartitionInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePartitionInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceSpec) DeepCopyInto(out *DeviceSpec) {
 	*out = *in
@@ -287,6 +318,11 @@ func (in *DeviceSpec) DeepCopyInto(out *DeviceSpec) {
 	}
 	out.FileSystem = in.FileSystem
 	out.NodeAttributes = in.NodeAttributes
+	if in.PartitionInfo != nil {
+		in, out := &in.PartitionInfo, &out.PartitionInfo
+		*out = new(DevicePartitionInfo)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceSpec.
@@ -302,6 +338,18 @@ func (in *DeviceSpec) DeepCopy() *DeviceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceStatus) DeepCopyInto(out *DeviceStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]BlockDeviceCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Children != nil {
+		in, out := &in.Children, &out.Children
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceStatus.
@@ -16,6 +16,11 @@ limitations under the License.
 
 package blockdevice
 
+import (
+	"strings"
+	"time"
+)
+
 // BlockDevice is an internal representation of any block device present on the system.
 // All data related to that device will be held by this struct
 //
@@ -118,6 +123,17 @@ type BlockDevice struct {
 	// DMInfo is filled if the device is a DM device
 	DMInfo DeviceMapperInformation
 
+	// LoopInfo is filled if the device is a loop device
+	LoopInfo LoopInformation
+
+	// UdevProperties contains the udev properties of the device as reported by
+	// the udev probe
+	UdevProperties map[string]string
+
+	// DecisionTrace records the branches taken while processing the add event of
+	// the device. It is nil unless the decision trace is enabled.
+	DecisionTrace *DecisionTrace
+
 	DevUse DeviceUsage
 
 	// PartitionInfo contains details if this blockdevice is a partition
@@ -223,6 +239,12 @@ const (
 
 	// BlockDeviceTypeMultiPath is a multipath device
 	BlockDeviceTypeMultiPath = "mpath"
+
+	// BlockDeviceTypeZRAM is a compressed RAM block device, used for swap or as a cache
+	BlockDeviceTypeZRAM = "zram"
+
+	// BlockDeviceTypeRAMDisk is a RAM disk created by the brd driver
+	BlockDeviceTypeRAMDisk = "ram"
 )
 
 // DeviceMapperDeviceTypes is the slice of device types that uses a device mapper
@@ -233,6 +255,13 @@ var DeviceMapperDeviceTypes = []string{
 	BlockDeviceTypeMultiPath,
 }
 
+// KernelPseudoDeviceTypes is the slice of device types that are backed by memory and
+// used by kernel subsystems. These devices are never managed by NDM.
+var KernelPseudoDeviceTypes = []string{
+	BlockDeviceTypeZRAM,
+	BlockDeviceTypeRAMDisk,
+}
+
 const (
 	// DriveTypeHDD represents a rotating hard disk drive
 	DriveTypeHDD = "HDD"
@@ -244,6 +273,159 @@ const (
 	DriveTypeUnknown = "Unknown"
 )
 
+const (
+	// TransportNVMePCIe represents a local NVMe device attached over PCIe
+	TransportNVMePCIe = "nvme-pcie"
+
+	// TransportNVMeTCP represents an NVMe-oF namespace attached over TCP
+	TransportNVMeTCP = "nvme-tcp"
+
+	// TransportNVMeRDMA represents an NVMe-oF namespace attached over RDMA
+	TransportNVMeRDMA = "nvme-rdma"
+
+	// TransportNVMeFC represents an NVMe-oF namespace attached over fibre channel
+	TransportNVMeFC = "nvme-fc"
+
+	// TransportISER represents an iSCSI device attached using iSER (iSCSI
+	// Extensions for RDMA)
+	TransportISER = "iser"
+
+	// TransportISCSI represents an iSCSI device attached over TCP
+	TransportISCSI = "iscsi"
+
+	// TransportSATA represents a local disk attached to an ATA / SATA controller
+	TransportSATA = "sata"
+
+	// TransportSAS represents a local disk attached to a SAS controller
+	TransportSAS = "sas"
+
+	// TransportUSB represents a disk attached over USB
+	TransportUSB = "usb"
+
+	// TransportVirtio represents a virtio block device of a virtual machine
+	TransportVirtio = "virtio"
+)
+
+// IsFabricTransport checks if the transport is one of the fabric transports, in
+// which case the device is exported by a remote target and is not local to the node
+func IsFabricTransport(transport string) bool {
+	switch transport {
+	case TransportNVMeTCP, TransportNVMeRDMA, TransportNVMeFC, TransportISER:
+		return true
+	}
+	return false
+}
+
+const (
+	// SectorModel512n is a disk with 512 byte logical and physical blocks
+	SectorModel512n = "512n"
+	// SectorModel512e is an advanced format disk with 4K physical blocks, emulating
+	// 512 byte logical blocks
+	SectorModel512e = "512e"
+	// SectorModel4Kn is an advanced format disk with 4K logical and physical blocks
+	SectorModel4Kn = "4Kn"
+)
+
+// GetSectorModel gets the sector model of the disk from its logical and physical block
+// sizes. Empty string is returned if the block sizes are not known or do not match any
+// of the sector models.
+func GetSectorModel(logicalBlockSize, physicalBlockSize uint32) string {
+	switch {
+	case logicalBlockSize == 512 && physicalBlockSize == 512:
+		return SectorModel512n
+	case logicalBlockSize == 512 && physicalBlockSize == 4096:
+		return SectorModel512e
+	case logicalBlockSize == 4096 && physicalBlockSize == 4096:
+		return SectorModel4Kn
+	}
+	return ""
+}
+
+const (
+	// PartitionTypeBIOSBoot is the GPT partition type GUID of the BIOS boot partition
+	// used by GRUB on disks with a GPT
+	PartitionTypeBIOSBoot = "21686148-6449-6e6f-744e-656564454649"
+
+	// PartitionTypeEFISystem is the GPT partition type GUID of the EFI System Partition
+	PartitionTypeEFISystem = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+
+	// PartitionTypeMBREFISystem is the MBR partition type of the EFI System Partition
+	PartitionTypeMBREFISystem = "0xef"
+
+	// PartitionTypeStorageSpaces is the GPT partition type GUID of the protective partition
+	// having the metadata of a Windows Storage Spaces pool
+	PartitionTypeStorageSpaces = "e75caf8f-f680-4cee-afa3-b001e56efc2d"
+
+	// PartitionTypeLinuxSwap is the GPT partition type GUID of a linux swap partition
+	PartitionTypeLinuxSwap = "0657fd6d-a4ab-43c4-84e5-0933c84b4f4f"
+
+	// PartitionTypeMBRLinuxSwap is the MBR partition type of a linux swap partition
+	PartitionTypeMBRLinuxSwap = "0x82"
+
+	// PartitionTypeLinuxRootAMD64 is the GPT partition type GUID of the root partition
+	// of an x86-64 linux installation
+	PartitionTypeLinuxRootAMD64 = "4f68bce3-e8cd-4db1-96e7-fbcaf984b709"
+
+	// PartitionTypeLinuxRootARM64 is the GPT partition type GUID of the root partition
+	// of an arm64 linux installation
+	PartitionTypeLinuxRootARM64 = "b921b045-1df0-41c3-af44-4c6f280d3fae"
+
+	// PartitionTypeLinuxHome is the GPT partition type GUID of the /home partition of a
+	// linux installation
+	PartitionTypeLinuxHome = "933ac7e1-2eb4-4f13-b844-0e14e2aef915"
+
+	// PartitionTypeWindowsRecovery is the GPT partition type GUID of the recovery
+	// partition created by the Windows installer
+	PartitionTypeWindowsRecovery = "de94bba4-06d1-4d40-a16a-bfd50179d6ac"
+)
+
+const (
+	// FileSystemReFS is the filesystem type of a device having a Windows ReFS filesystem
+	FileSystemReFS = "refs"
+
+	// FileSystemStorageSpaces is the filesystem type of a device that is a member of a
+	// Windows Storage Spaces pool
+	FileSystemStorageSpaces = "storage_spaces_member"
+
+	// FileSystemMDRaidMember is the filesystem type of a device that is a member of a
+	// linux md array, as reported by blkid
+	FileSystemMDRaidMember = "linux_raid_member"
+
+	// FileSystemSwap is the filesystem type of a device having a linux swap signature
+	FileSystemSwap = "swap"
+)
+
+// IsBootPartitionType checks if the partition type is that of a BIOS boot or EFI System
+// partition. A disk having such a partition is almost always the boot disk of the node.
+func IsBootPartitionType(partitionType string) bool {
+	switch strings.ToLower(partitionType) {
+	case PartitionTypeBIOSBoot, PartitionTypeEFISystem, PartitionTypeMBREFISystem:
+		return true
+	}
+	return false
+}
+
+// IsOSPartitionType checks if the partition type is that of a partition created by an
+// os installer, i.e a boot, root, /home, swap or recovery partition. A disk having such
+// a partition is the os disk of the node.
+func IsOSPartitionType(partitionType string) bool {
+	if IsBootPartitionType(partitionType) {
+		return true
+	}
+	switch strings.ToLower(partitionType) {
+	case PartitionTypeLinuxSwap, PartitionTypeMBRLinuxSwap, PartitionTypeLinuxRootAMD64,
+		PartitionTypeLinuxRootARM64, PartitionTypeLinuxHome, PartitionTypeWindowsRecovery:
+		return true
+	}
+	return false
+}
+
+// IsStorageSpacesPartitionType checks if the partition type is that of the protective
+// partition created by Windows on the disks that are members of a Storage Spaces pool.
+func IsStorageSpacesPartitionType(partitionType string) bool {
+	return strings.ToLower(partitionType) == PartitionTypeStorageSpaces
+}
+
 // FileSystemInformation contains the filesystem and mount information of blockdevice, if present
 type FileSystemInformation struct {
 	// FileSystemUUID is the UUID of the filesystem on the blockdevice
@@ -252,8 +434,16 @@ type FileSystemInformation struct {
 	// FileSystem is the filesystem present on the blockdevice
 	FileSystem string
 
+	// FileSystemLabel is the label of the filesystem present on the blockdevice
+	FileSystemLabel string
+
 	// MountPoint is the list of mountpoints at which this blockdevice is mounted
 	MountPoint []string
+
+	// Signatures are the types of all the filesystem / storage engine signatures found
+	// on the blockdevice. A device reused across tools may have more than one signature,
+	// in which case FileSystem may be any of them.
+	Signatures []string
 }
 
 // CapacityInformation holds the capacity related information for the device
@@ -321,8 +511,124 @@ type DeviceAttribute struct {
 	// FirmwareRevision
 	FirmwareRevision string
 
+	// FormFactor is the nominal form factor of the disk, eg: 2.5", M.2.
+	// It is empty if the disk does not report it
+	FormFactor string
+
+	// WriteEnduranceTBW is the write endurance of the SSD in terabytes written.
+	// It is 0 if it cannot be estimated
+	WriteEnduranceTBW uint64
+
 	// Compliance is implemented specifications version i.e. SPC-1, SPC-2, etc
 	Compliance string
+
+	// Removable is true if the device is a removable media, like USB sticks
+	// and external USB drives
+	Removable bool
+
+	// Transport is the transport over which the device is attached,
+	// eg: sata, sas, usb, nvme-pcie, nvme-tcp, nvme-rdma, iser. It is empty if
+	// the transport is not known
+	Transport string
+
+	// FabricTarget is the NQN of the NVMe-oF subsystem or the IQN of the
+	// iSER target that exports the device
+	FabricTarget string
+
+	// FabricAddress is the address (portal) of the fabric target,
+	// eg: traddr=10.0.0.1,trsvcid=4420
+	FabricAddress string
+
+	// VirtioBusAddress is the PCI address of the virtio device of a virtio disk,
+	// eg: 0000:00:05.0, i.e the slot at which the disk is attached to the virtual machine
+	VirtioBusAddress string
+
+	// ControllerSerial is the serial number of the NVMe controller that exposes
+	// the namespace. All the namespaces of a controller share the physical device.
+	ControllerSerial string
+
+	// ControllerID is the id (cntlid) of the NVMe controller that exposes the
+	// namespace
+	ControllerID string
+
+	// DiscardSupported is true if the device supports discard (TRIM / UNMAP)
+	DiscardSupported bool
+
+	// DiscardGranularity is the size in bytes of the unit in which the device
+	// discards blocks, reported by /sys/class/block/sda/queue/discard_granularity
+	DiscardGranularity uint64
+
+	// IOScheduler is the active IO scheduler of the device, reported by
+	// /sys/class/block/sda/queue/scheduler, eg: mq-deadline, none
+	IOScheduler string
+
+	// QueueDepth is the number of requests that can be queued for the device in the
+	// block layer, reported by /sys/class/block/sda/queue/nr_requests
+	QueueDepth uint64
+
+	// NUMANode is the NUMA node to which the PCI controller of the device is attached,
+	// reported by /sys/bus/pci/devices/<addr>/numa_node. It is nil if the device is not
+	// attached over PCI or the controller has no NUMA affinity.
+	NUMANode *int
+
+	// WriteCacheEnabled is true if the volatile write cache of the device is enabled,
+	// reported by /sys/class/block/sda/queue/write_cache. It is nil if not known.
+	WriteCacheEnabled *bool
+
+	// PCIeLink is the negotiated and the maximum speed / width of the PCIe link of the
+	// NVMe controller of the device. It is nil if the device is not attached over PCIe.
+	PCIeLink *PCIeLink
+
+	// IOErrors is the number of IO errors of the SCSI device within the error window.
+	// It is nil if the device does not report its IO errors or the tracking is disabled.
+	IOErrors *IOErrorState
+
+	// Writability is the result of the writability check of a blank disk. It is nil if
+	// the disk was not checked.
+	Writability *WritabilityState
+}
+
+// WritabilityState is the result of the writability check of a disk, which writes to a
+// scratch region at the end of a blank disk and reads it back
+type WritabilityState struct {
+	// Checked is false if the check was skipped, as the disk is in use or not blank
+	Checked bool
+	// Writable is true if the data read back was the same as the data written
+	Writable bool
+	// Message is the reason for which the check failed or was skipped
+	Message string
+}
+
+// IOErrorState is the number of IO requests of a SCSI device that completed with an
+// error, as reported by /sys/class/block/<dev>/device/ioerr_cnt
+type IOErrorState struct {
+	// Count is the number of IO errors since the device was attached
+	Count uint64
+	// RecentCount is the number of IO errors within the window
+	RecentCount uint64
+	// Window is the duration over which RecentCount is counted
+	Window time.Duration
+	// Failing is true if RecentCount reached the configured threshold
+	Failing bool
+}
+
+// PCIeLink is the link of a PCIe device, as reported by
+// /sys/bus/pci/devices/<addr>/{current,max}_link_{speed,width}
+type PCIeLink struct {
+	// CurrentSpeed is the negotiated speed of the link in GT/s
+	CurrentSpeed float64
+	// MaxSpeed is the maximum speed of the link in GT/s
+	MaxSpeed float64
+	// CurrentWidth is the negotiated no. of lanes of the link
+	CurrentWidth int
+	// MaxWidth is the maximum no. of lanes of the link
+	MaxWidth int
+}
+
+// IsDegraded checks if the link negotiated a lower speed or width than its maximum,
+// which usually indicates a hardware problem like a bad slot, riser or cable
+func (l PCIeLink) IsDegraded() bool {
+	return l.CurrentSpeed < l.MaxSpeed || l.CurrentWidth < l.MaxWidth
 }
 
 // DevLink represents a type of dev link for a device. A device can have multiple
@@ -366,6 +672,10 @@ type PartitionInformation struct {
 	// PartitionNumber is the partition number
 	PartitionNumber uint8
 
+	// StartOffset is the offset in bytes of the start of the partition from the start
+	// of the parent disk
+	StartOffset uint64
+
 	// PartitionEntryUUID is the UUID of the partition
 	PartitionEntryUUID string
 
@@ -374,6 +684,37 @@ type PartitionInformation struct {
 
 	// PartitionTableType is the type of the partition (dos/gpt)
 	PartitionTableType string
+
+	// PartitionType is the partition type GUID (gpt) or the partition type (dos)
+	// of the partition
+	PartitionType string
+
+	// PartitionName is the name of the gpt partition
+	PartitionName string
+
+	// LayoutChecked is true if the position of the partition was checked against the
+	// area reserved at the start of the parent disk partitioned by NDM
+	LayoutChecked bool
+
+	// LayoutViolation is the description of how the partition violates the layout of
+	// the parent disk, eg: it starts in the area reserved by NDM. Empty if the layout is valid.
+	LayoutViolation string
+
+	// BlankChecked is true if the partition created by NDM was probed again after its
+	// creation to verify that it has no signatures
+	BlankChecked bool
+
+	// UnexpectedSignatures are the signatures found on the partition created by NDM, which
+	// should have been blank. Empty if the partition is blank.
+	UnexpectedSignatures []string
+
+	// PartitionTableChecked is true if the partition table of the disk, or of the parent
+	// disk of the partition, was validated
+	PartitionTableChecked bool
+
+	// PartitionTableCorruption is the description of the defect in the partition table of
+	// the disk, or of the parent disk of the partition. Empty if the table is valid.
+	PartitionTableCorruption string
 }
 
 type DeviceMapperInformation struct {
@@ -385,6 +726,17 @@ type DeviceMapperInformation struct {
 	DevMapperPath string
 }
 
+// LoopInformation contains the details of the file backing a loop device
+type LoopInformation struct {
+	// BackingFile is the path of the file backing the loop device, as present in
+	// <dev-sys-path>/loop/backing_file. Empty if the device is not attached to a file
+	BackingFile string
+
+	// Sparse is true if the backing file has fewer blocks allocated than its size,
+	// like the image files created with truncate for testing
+	Sparse bool
+}
+
 // DependentBlockDevices contains path of all devices that are
 // related to this BlockDevice
 type DependentBlockDevices struct {
@@ -411,6 +763,12 @@ type DependentBlockDevices struct {
 type DeviceUsage struct {
 	InUse  bool
 	UsedBy StorageEngine
+	// Reason gives additional details about the usage of the device,
+	// eg: the mountpoints if the device is mounted
+	Reason string
+	// ProbedAt is the time at which the usage of the device was last probed,
+	// it is zero if the usage was never probed
+	ProbedAt time.Time
 }
 
 // StorageEngine is a typed string for the storage engine
@@ -429,8 +787,56 @@ const (
 	// LocalPV
 	LocalPV StorageEngine = "localpv"
 
+	// LocalPVBlock is a kubernetes local PV with volumeMode: Block, i.e the
+	// device is used as a raw block device without any filesystem on it
+	LocalPVBlock StorageEngine = "localpv-block"
+
 	// Jiva
 	Jiva StorageEngine = "jiva"
+
+	// Mounted is a device having an active mount that is not owned by any
+	// of the known storage engines, eg: a device manually mounted by an admin
+	Mounted StorageEngine = "mounted"
+
+	// Windows is a device having the metadata of a Windows host, like a Storage
+	// Spaces pool or a ReFS filesystem, eg: a disk imported from a Windows host
+	Windows StorageEngine = "windows"
+
+	// VMDatastore is a device mounted at the path of a VM datastore, like a libvirt
+	// storage pool, having the backing images of the disks of virtual machines
+	VMDatastore StorageEngine = "vm-datastore"
+
+	// SCSIReserved is a device having a SCSI-3 persistent reservation held on it,
+	// eg: a shared SAS/FC disk reserved by a peer node
+	SCSIReserved StorageEngine = "scsi-reserved"
+
+	// Longhorn is a device having a filesystem used as a disk by longhorn, i.e
+	// mounted at a path having the longhorn disk config
+	Longhorn StorageEngine = "longhorn"
+
+	// Gluster is a device having a filesystem used as a GlusterFS brick, i.e mounted
+	// at a path having the glusterfs volume-id xattr
+	Gluster StorageEngine = "glusterfs"
+
+	// LocalPath is a device mounted at the base directory of the local-path
+	// provisioner, having the host directories used as volumes by the pods
+	LocalPath StorageEngine = "local-path"
+
+	// MDRaidMember is a device having the superblock of a linux md array, i.e a member
+	// of an array which may not be assembled, eg: an array stopped for maintenance
+	MDRaidMember StorageEngine = "md-raid-member"
+
+	// ArraySpare is a device reserved as a spare of an md array. The device does not
+	// hold any data, but it is used to rebuild the array when a member fails
+	ArraySpare StorageEngine = "array-spare"
+
+	// CSIManaged is a device stamped with the partition type or filesystem label of a
+	// CSI driver configured by the operator, i.e a device owned by another CSI driver
+	CSIManaged StorageEngine = "csi-managed"
+
+	// UsedByPlugin is a device reported in use by one of the used-by plugins configured
+	// by the operator, eg: a DRBD backing device
+	UsedByPlugin StorageEngine = "used-by-plugin"
 )
 
 // Status is used to represent the status of the blockdevice
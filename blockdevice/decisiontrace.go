@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import "strings"
+
+// maxDecisionTraceSteps is the maximum no. of steps recorded in a decision trace, so
+// that the trace stays compact enough to be added as an annotation
+const maxDecisionTraceSteps = 32
+
+// DecisionTrace records the sequence of branches taken by NDM while processing a device,
+// eg: the unmanaged device checks, the basis of the uuid and the final action. A nil
+// trace does not record anything, so that the branches can be instrumented without
+// checking if tracing is enabled.
+type DecisionTrace struct {
+	steps []string
+}
+
+// Add appends a step to the trace. Steps beyond the maximum are dropped.
+func (t *DecisionTrace) Add(step string) {
+	if t == nil || len(t.steps) >= maxDecisionTraceSteps {
+		return
+	}
+	t.steps = append(t.steps, step)
+}
+
+// Steps gets the steps recorded in the trace
+func (t *DecisionTrace) Steps() []string {
+	if t == nil {
+		return nil
+	}
+	return append([]string(nil), t.steps...)
+}
+
+// Copy returns a copy of the trace, which does not share the steps with the trace
+func (t *DecisionTrace) Copy() *DecisionTrace {
+	if t == nil {
+		return nil
+	}
+	return &DecisionTrace{steps: t.Steps()}
+}
+
+// String gets the compact form of the trace, with the steps separated by " > "
+func (t *DecisionTrace) String() string {
+	if t == nil {
+		return ""
+	}
+	return strings.Join(t.steps, " > ")
+}
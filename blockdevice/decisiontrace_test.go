@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	var nilTrace *DecisionTrace
+	nilTrace.Add("uuid-basis:wwn")
+	assert.Nil(t, nilTrace.Steps())
+	assert.Equal(t, "", nilTrace.String())
+
+	trace := &DecisionTrace{}
+	trace.Add("unmanaged-checks:passed")
+	trace.Add("uuid-basis:wwn")
+	trace.Add("action:resource-created")
+	assert.Equal(t, []string{"unmanaged-checks:passed", "uuid-basis:wwn", "action:resource-created"}, trace.Steps())
+	assert.Equal(t, "unmanaged-checks:passed > uuid-basis:wwn > action:resource-created", trace.String())
+
+	// the steps beyond the maximum are dropped
+	for i := 0; i < 2*maxDecisionTraceSteps; i++ {
+		trace.Add(fmt.Sprintf("step-%d", i))
+	}
+	assert.Len(t, trace.Steps(), maxDecisionTraceSteps)
+	assert.Equal(t, "unmanaged-checks:passed", trace.Steps()[0])
+}
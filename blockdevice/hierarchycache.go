@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// devMapperDir is the directory in which the device mapper creates the friendly
+// name symlinks of the dm devices
+const devMapperDir = "/dev/mapper/"
+
+// evalSymlinks resolves the symlinks in a path, it is a variable so that it
+// can be replaced in tests
+var evalSymlinks = filepath.EvalSymlinks
+
+// HierarchyCache is the block device hierarchy on the system, which can be
+// accessed concurrently. The events are processed while the probes and the
+// rescans read and update the hierarchy, so all the access to the cached
+// devices is done through its methods.
+type HierarchyCache struct {
+	mutex     sync.RWMutex
+	hierarchy Hierarchy
+	// aliases maps the /dev/mapper path of a dm device to the kernel path
+	// (/dev/dm-N) with which the device is stored in the hierarchy
+	aliases map[string]string
+}
+
+// NewHierarchyCache creates a cache with the devices in the given hierarchy.
+// The hierarchy is copied, so that the caller cannot modify the cache.
+func NewHierarchyCache(hierarchy Hierarchy) *HierarchyCache {
+	c := &HierarchyCache{
+		hierarchy: make(Hierarchy, len(hierarchy)),
+	}
+	for devPath, bd := range hierarchy {
+		c.hierarchy[devPath] = bd
+		c.addAlias(devPath, bd)
+	}
+	return c
+}
+
+// CanonicalizeDevPath gets the kernel path of a device for a /dev/mapper path,
+// as the same dm device can be referred to as /dev/dm-N and as /dev/mapper/<name>.
+// Other paths, and the mapper paths that cannot be resolved, are returned as is.
+func CanonicalizeDevPath(devPath string) string {
+	if !strings.HasPrefix(devPath, devMapperDir) {
+		return devPath
+	}
+	resolved, err := evalSymlinks(devPath)
+	if err != nil {
+		return devPath
+	}
+	return resolved
+}
+
+// Canonicalize gets the path with which the device is stored in the cache. The
+// /dev/mapper path of a cached dm device resolves to its kernel path, and the
+// other mapper paths are resolved from the filesystem.
+func (c *HierarchyCache) Canonicalize(devPath string) string {
+	if c != nil {
+		c.mutex.RLock()
+		canonicalPath, ok := c.aliases[devPath]
+		c.mutex.RUnlock()
+		if ok {
+			return canonicalPath
+		}
+	}
+	return CanonicalizeDevPath(devPath)
+}
+
+// canonicalize gets the path for the device from the aliases, the caller
+// should hold the lock
+func (c *HierarchyCache) canonicalize(devPath string) string {
+	if canonicalPath, ok := c.aliases[devPath]; ok {
+		return canonicalPath
+	}
+	return devPath
+}
+
+// addAlias records the /dev/mapper path of a dm device as an alias of the path
+// with which it is cached, the caller should hold the lock
+func (c *HierarchyCache) addAlias(devPath string, bd BlockDevice) {
+	mapperPath := bd.DMInfo.DevMapperPath
+	if mapperPath == "" || mapperPath == devPath {
+		return
+	}
+	if c.aliases == nil {
+		c.aliases = make(map[string]string)
+	}
+	c.aliases[mapperPath] = devPath
+}
+
+// Get gets the device with the given path from the cache, false is returned if
+// the device is not present
+func (c *HierarchyCache) Get(devPath string) (BlockDevice, bool) {
+	if c == nil {
+		return BlockDevice{}, false
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	bd, ok := c.hierarchy[c.canonicalize(devPath)]
+	return bd, ok
+}
+
+// Set adds or replaces the device with the given path in the cache. The
+// /dev/mapper path of a dm device is recorded as an alias of the given path.
+func (c *HierarchyCache) Set(devPath string, bd BlockDevice) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.hierarchy == nil {
+		c.hierarchy = make(Hierarchy)
+	}
+	devPath = c.canonicalize(devPath)
+	c.hierarchy[devPath] = bd
+	c.addAlias(devPath, bd)
+}
+
+// Delete removes the device with the given path from the cache
+func (c *HierarchyCache) Delete(devPath string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	devPath = c.canonicalize(devPath)
+	delete(c.hierarchy, devPath)
+	for alias, canonicalPath := range c.aliases {
+		if canonicalPath == devPath {
+			delete(c.aliases, alias)
+		}
+	}
+}
+
+// Reset removes all the devices from the cache
+func (c *HierarchyCache) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hierarchy = make(Hierarchy)
+	c.aliases = nil
+}
+
+// Len gets the no. of devices in the cache
+func (c *HierarchyCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.hierarchy)
+}
+
+// Snapshot gets a copy of the devices in the cache. The copy can be iterated and
+// modified without holding the lock, changes to it are not reflected in the cache.
+func (c *HierarchyCache) Snapshot() Hierarchy {
+	snapshot := make(Hierarchy)
+	if c == nil {
+		return snapshot
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for devPath, bd := range c.hierarchy {
+		snapshot[devPath] = bd
+	}
+	return snapshot
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHierarchyCache(t *testing.T) {
+	sda := BlockDevice{Identifier: Identifier{DevPath: "/dev/sda"}}
+	sdb := BlockDevice{Identifier: Identifier{DevPath: "/dev/sdb"}}
+
+	hierarchy := Hierarchy{sda.DevPath: sda}
+	c := NewHierarchyCache(hierarchy)
+	// the cache does not share the map with the caller
+	hierarchy[sdb.DevPath] = sdb
+	assert.Equal(t, 1, c.Len())
+
+	got, ok := c.Get(sda.DevPath)
+	assert.True(t, ok)
+	assert.Equal(t, sda, got)
+	_, ok = c.Get(sdb.DevPath)
+	assert.False(t, ok)
+
+	c.Set(sdb.DevPath, sdb)
+	snapshot := c.Snapshot()
+	assert.Equal(t, Hierarchy{sda.DevPath: sda, sdb.DevPath: sdb}, snapshot)
+	// changes to the snapshot are not reflected in the cache
+	delete(snapshot, sda.DevPath)
+	assert.Equal(t, 2, c.Len())
+
+	c.Delete(sda.DevPath)
+	_, ok = c.Get(sda.DevPath)
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+
+	c.Reset()
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, Hierarchy{}, c.Snapshot())
+}
+
+func TestHierarchyCacheZeroValue(t *testing.T) {
+	var nilCache *HierarchyCache
+	_, ok := nilCache.Get("/dev/sda")
+	assert.False(t, ok)
+	assert.Equal(t, 0, nilCache.Len())
+	assert.Equal(t, Hierarchy{}, nilCache.Snapshot())
+
+	c := &HierarchyCache{}
+	c.Set("/dev/sda", BlockDevice{})
+	assert.Equal(t, 1, c.Len())
+}
+
+// TestHierarchyCacheConcurrentAccess accesses the cache from several goroutines, it
+// should be run with the race detector enabled to find unsynchronized access.
+func TestHierarchyCacheConcurrentAccess(t *testing.T) {
+	c := NewHierarchyCache(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				devPath := fmt.Sprintf("/dev/sd%d", j%16)
+				switch (worker + j) % 5 {
+				case 0:
+					c.Set(devPath, BlockDevice{Identifier: Identifier{DevPath: devPath}})
+				case 1:
+					if bd, ok := c.Get(devPath); ok {
+						assert.Equal(t, devPath, bd.DevPath)
+					}
+				case 2:
+					c.Delete(devPath)
+				case 3:
+					for path, bd := range c.Snapshot() {
+						assert.Equal(t, path, bd.DevPath)
+					}
+				case 4:
+					assert.LessOrEqual(t, c.Len(), 16)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestHierarchyCacheDevMapperAlias(t *testing.T) {
+	dm := BlockDevice{
+		Identifier: Identifier{DevPath: "/dev/dm-3"},
+		DMInfo:     DeviceMapperInformation{DevMapperPath: "/dev/mapper/foo"},
+	}
+	c := NewHierarchyCache(Hierarchy{dm.DevPath: dm})
+
+	assert.Equal(t, "/dev/dm-3", c.Canonicalize("/dev/mapper/foo"))
+	got, ok := c.Get("/dev/mapper/foo")
+	assert.True(t, ok)
+	assert.Equal(t, dm, got)
+
+	// an update through the mapper path replaces the cached device
+	c.Set("/dev/mapper/foo", dm)
+	assert.Equal(t, 1, c.Len())
+
+	c.Delete("/dev/mapper/foo")
+	assert.Equal(t, 0, c.Len())
+	// the alias is removed along with the device
+	assert.Equal(t, "/dev/mapper/foo", c.Canonicalize("/dev/mapper/foo"))
+}
+
+func TestCanonicalizeDevPath(t *testing.T) {
+	defer func(f func(string) (string, error)) { evalSymlinks = f }(evalSymlinks)
+	evalSymlinks = func(path string) (string, error) {
+		if path == "/dev/mapper/foo" {
+			return "/dev/dm-3", nil
+		}
+		return "", fmt.Errorf("lstat %s: no such file or directory", path)
+	}
+
+	tests := map[string]struct {
+		devPath string
+		want    string
+	}{
+		"kernel path of a dm device": {
+			devPath: "/dev/dm-3",
+			want:    "/dev/dm-3",
+		},
+		"mapper path of a dm device": {
+			devPath: "/dev/mapper/foo",
+			want:    "/dev/dm-3",
+		},
+		"mapper path that cannot be resolved": {
+			devPath: "/dev/mapper/bar",
+			want:    "/dev/mapper/bar",
+		},
+		"path of a disk": {
+			devPath: "/dev/sda",
+			want:    "/dev/sda",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, CanonicalizeDevPath(test.devPath))
+		})
+	}
+}
@@ -69,11 +69,28 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var claimHookCommand string
+	var claimHookURL string
+	var claimHookTimeout time.Duration
+	var claimValidationURL string
+	var claimValidationTimeout time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8484", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8585", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&claimHookCommand, "claim-hook-command", "",
+		"Command to run when a BlockDevice becomes claimed or unclaimed. "+
+			"The device name, path, node and new claim state are passed as NDM_* environment variables.")
+	flag.StringVar(&claimHookURL, "claim-hook-url", "",
+		"URL to POST a JSON event to when a BlockDevice becomes claimed or unclaimed.")
+	flag.DurationVar(&claimHookTimeout, "claim-hook-timeout", blockdevice.DefaultClaimHookTimeout,
+		"Maximum time a single run of the claim hook is allowed to take.")
+	flag.StringVar(&claimValidationURL, "claim-validation-url", "",
+		"URL to POST the claim and the attributes of the BlockDevices matching it to, before the claim binds. "+
+			"The first device allowed by the response is bound.")
+	flag.DurationVar(&claimValidationTimeout, "claim-validation-timeout", blockdeviceclaim.DefaultClaimValidationTimeout,
+		"Maximum time the claim validation webhook is allowed to take for the devices matching a claim.")
 	klog.InitFlags(nil)
 
 	flag.Parse()
@@ -101,20 +118,40 @@ func main() {
 		os.Exit(1)
 	}
 
+	var claimValidationHook blockdeviceclaim.ClaimValidationHook
+	if claimValidationURL != "" {
+		claimValidationHook = blockdeviceclaim.NewWebhookClaimValidationHook(claimValidationURL)
+	}
+
 	if err = (&blockdeviceclaim.BlockDeviceClaimReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("BlockDeviceClaim"),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("blockdeviceclaim-controller"),
+		Client:                 mgr.GetClient(),
+		Log:                    ctrl.Log.WithName("controllers").WithName("BlockDeviceClaim"),
+		Scheme:                 mgr.GetScheme(),
+		Recorder:               mgr.GetEventRecorderFor("blockdeviceclaim-controller"),
+		ClaimValidationHook:    claimValidationHook,
+		ClaimValidationTimeout: claimValidationTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BlockDeviceClaim")
 		os.Exit(1)
 	}
+	var claimHook blockdevice.ClaimStateHook
+	switch {
+	case claimHookCommand != "" && claimHookURL != "":
+		setupLog.Error(nil, "only one of --claim-hook-command and --claim-hook-url can be set")
+		os.Exit(1)
+	case claimHookCommand != "":
+		claimHook = blockdevice.NewExecClaimStateHook(claimHookCommand)
+	case claimHookURL != "":
+		claimHook = blockdevice.NewWebhookClaimStateHook(claimHookURL)
+	}
+
 	if err = (&blockdevice.BlockDeviceReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("BlockDevice"),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("blockdevice-controller"),
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("BlockDevice"),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("blockdevice-controller"),
+		ClaimStateHook:   claimHook,
+		ClaimHookTimeout: claimHookTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BlockDevice")
 		os.Exit(1)
@@ -21,6 +21,7 @@ import (
 
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/features"
+	"github.com/openebs/node-disk-manager/pkg/partition"
 	"github.com/openebs/node-disk-manager/pkg/util"
 	"github.com/openebs/node-disk-manager/pkg/version"
 
@@ -57,11 +58,178 @@ func NewNodeDiskManager() (*cobra.Command, error) {
 	cmd.PersistentFlags().StringSliceVar(&options.FeatureGate, "feature-gates",
 		nil,
 		"FeatureGates to be enabled or disabled")
+	cmd.PersistentFlags().BoolVar(&options.SafeMode, "safe-mode",
+		true,
+		"Block all destructive operations like partitioning and deactivation of devices. Set to false to opt in to them")
+	cmd.PersistentFlags().StringVar(&options.RemovableDevicePolicy, "removable-device-policy",
+		string(controller.DefaultRemovableDevicePolicy),
+		"Policy for removable media like USB drives. Can be ignore or manage")
+	cmd.PersistentFlags().DurationVar(&options.RemovableDeviceDelay, "removable-device-delay",
+		controller.DefaultRemovableDeviceDelay,
+		"Time for which a removable disk has to be present before it is managed, with the manage policy. "+
+			"0 manages it at once")
+	cmd.PersistentFlags().StringVar(&options.LoopDevicePolicy, "loop-device-policy",
+		string(controller.DefaultLoopDevicePolicy),
+		"Policy for loop devices, like the ones backed by image files on test nodes. Can be ignore or manage")
+	cmd.PersistentFlags().StringVar(&options.PartitionReclaimPolicy, "partition-reclaim-policy",
+		string(controller.DefaultPartitionReclaimPolicy),
+		"Policy for unused partitions created by NDM that are flagged for reclaim. Can be retain or reclaim")
+	cmd.PersistentFlags().StringVar(&options.PartitionedParentPolicy, "partitioned-parent-policy",
+		string(controller.DefaultPartitionedParentPolicy),
+		"Policy for disks having partitions. Can be ignore or show, which creates an unclaimable resource for the disk")
+	cmd.PersistentFlags().StringVar(&options.NodeNameSource, "node-name-source",
+		string(controller.DefaultNodeNameSource),
+		"Source of the node name. Can be env (NODE_NAME env), hostname or config (--node-name)")
+	cmd.PersistentFlags().StringVar(&options.NodeName, "node-name",
+		"",
+		"Node name to be used when the node name source is config")
+	cmd.PersistentFlags().BoolVar(&options.PartitionFabricDevices, "partition-fabric-devices",
+		false,
+		"Allow partitioning of devices attached over fabric transports like NVMe-oF and iSER")
+	cmd.PersistentFlags().StringSliceVar(&options.SCSIReservationKeys, "scsi-reservation-keys",
+		nil,
+		"Persistent reservation keys in hex registered by this host, eg: by multipathd. "+
+			"Disks reserved with other keys are treated as in use by another host")
+	cmd.PersistentFlags().DurationVar(&options.ProbeTimeout, "probe-timeout",
+		controller.DefaultProbeTimeout,
+		"Time after which a probe is abandoned for a device. 0 disables the timeout")
+	cmd.PersistentFlags().DurationVar(&options.EventCoalesceWindow, "event-coalesce-window",
+		controller.DefaultEventCoalesceWindow,
+		"Time for which the udev events of a disk and its partitions are buffered to be processed together. "+
+			"0 disables the buffering")
+	cmd.PersistentFlags().DurationVar(&options.DeviceReadyTimeout, "device-ready-timeout",
+		controller.DefaultDeviceReadyTimeout,
+		"Time after which a device that is not ready to accept IO is reported. "+
+			"Devices are probed only once they are ready. 0 disables the readiness check")
+	cmd.PersistentFlags().StringVar(&options.PartitionName, "partition-name",
+		partition.DefaultPartitionName,
+		"Name of the GPT partition created by NDM on blank disks, starting with a static prefix of at least 4 characters. Can have the short UUID of the partition as {{.ShortUUID}}")
+	cmd.PersistentFlags().StringVar(&options.MetricsAddress, "metrics-address",
+		"",
+		"Address(ip:port) at which the metrics of the daemon are exposed. Metrics are disabled if empty")
+	cmd.PersistentFlags().StringVar(&options.DecisionAddress, "decision-address",
+		"",
+		"Loopback address(ip:port) at which the decisions that NDM would make on the devices are served, "+
+			"at /decision?path=<device path>. Disabled if empty")
+	cmd.PersistentFlags().StringVar(&options.InternalErrorPolicy, "internal-error-policy",
+		string(controller.DefaultInternalErrorPolicy),
+		"Policy for non-fatal internal errors while processing a device. Can be report or propagate")
+	cmd.PersistentFlags().StringVar(&options.RemovalPolicy, "removal-policy",
+		string(controller.DefaultRemovalPolicy),
+		"Policy for the blockdevice resources of removed devices. Can be deactivate or delete. "+
+			"Only Unclaimed blockdevices without finalizers are ever deleted")
+	cmd.PersistentFlags().StringVar(&options.ProtectionLevel, "protection-level",
+		string(controller.DefaultProtectionLevel),
+		"Protection of claimed blockdevices, or blockdevices having an outstanding claim, from destructive operations. "+
+			"Can be normal or strict. In strict, they are not deactivated even when removed")
+	cmd.PersistentFlags().StringVar(&options.MultiSignaturePolicy, "multi-signature-policy",
+		string(controller.DefaultMultiSignaturePolicy),
+		"Policy for devices having multiple filesystem signatures. Can be quarantine, prefer-recent or require-wipe. "+
+			"Such devices are never partitioned. Claimed and Released blockdevices are not affected")
+	cmd.PersistentFlags().BoolVar(&options.ReidentifyDevices, "reidentify-devices",
+		false,
+		"Migrate unclaimed blockdevices to a new uuid when a better identifier of the device, like the WWN, becomes available")
+	cmd.PersistentFlags().StringSliceVar(&options.ProvisioningMarkers, "provisioning-markers",
+		nil,
+		"Files marking the completion of node provisioning, like /run/cloud-init/result.json. "+
+			"Blank disks are partitioned only after all the files are present")
+	cmd.PersistentFlags().DurationVar(&options.ProvisioningTimeout, "provisioning-timeout",
+		controller.DefaultProvisioningTimeout,
+		"Maximum time to wait for the provisioning markers. 0 waits forever")
+	cmd.PersistentFlags().BoolVar(&options.DiscoverOnly, "discover-only",
+		false,
+		"Only discover the devices, never partition them. Blank disks that cannot be uniquely identified "+
+			"get a resource with an identifier independent of the partition table")
+	cmd.PersistentFlags().IntVar(&options.ContentFingerprintKiB, "content-fingerprint-kib",
+		0,
+		"Size in KiB of the start and end of an unclaimed, unused device hashed to detect disks swapped at the same path. "+
+			"0 disables the fingerprint")
+	cmd.PersistentFlags().StringVar(&options.InstanceID, "instance-id",
+		"",
+		"ID of this NDM instance, when multiple NDM daemonsets run on the same nodes. Devices managed by "+
+			"another instance are left alone. Empty manages all the devices")
+	cmd.PersistentFlags().BoolVar(&options.DiscardBeforePartition, "discard-before-partition",
+		false,
+		"Discard (TRIM) all the blocks of blank disks supporting discard before partitioning them")
+	cmd.PersistentFlags().BoolVar(&options.DisableWriteCache, "disable-write-cache",
+		false,
+		"Disable the volatile write cache of blank SCSI / SATA disks before partitioning them. Affects the write performance")
+	cmd.PersistentFlags().StringVar(&options.AuditSink, "audit-sink",
+		string(controller.DefaultAuditSink),
+		"Sink of the audit log of destructive operations. Can be stdout, file (--audit-log-file) or events on the node")
+	cmd.PersistentFlags().StringVar(&options.AuditLogFile, "audit-log-file",
+		"",
+		"File to which the audit log of destructive operations is appended, with the file audit sink")
+	cmd.PersistentFlags().StringVar(&options.AuditKeyFile, "audit-key-file",
+		"",
+		"File having the key with which the records of the audit log are hashed (HMAC-SHA256), so that modified records cannot be re-hashed without the key")
+	cmd.PersistentFlags().StringVar(&options.UUIDVersion, "uuid-version",
+		string(controller.DefaultUUIDVersion),
+		"Version of the uuid algorithm used for new devices. Can be v1, v2, v3 or v5, "+
+			"v3 generates the uuid of disks with a WWN from the WWN, serial and vendor, "+
+			"v5 generates RFC 4122 v5 uuids in the --uuid-namespace. "+
+			"Existing blockdevices keep their uuid")
+	cmd.PersistentFlags().StringVar(&options.UUIDNamespace, "uuid-namespace",
+		"",
+		"Namespace UUID in which the v5 uuids of the devices are generated. Required by uuid version v5, "+
+			"and should not be changed once devices have v5 uuids")
+	cmd.PersistentFlags().StringVar(&options.UUIDBasisAnnotation, "uuid-basis-annotation",
+		controller.DefaultUUIDBasisAnnotation,
+		"Annotation recording the identifier of the device, like wwn or partition-table-uuid, from which its uuid was generated")
+	cmd.PersistentFlags().BoolVar(&options.UdevAnnotations, "udev-annotations",
+		false,
+		"Annotate the blockdevices with the identifying udev properties of the device, like ID_BUS and ID_SERIAL")
+	cmd.PersistentFlags().BoolVar(&options.DecisionTrace, "decision-trace",
+		false,
+		"Record the branches taken while processing each device, as the ndm.io/decision-trace annotation on the blockdevice")
+	cmd.PersistentFlags().StringVar(&options.InventoryEventSink, "inventory-event-sink",
+		"",
+		"URL (http/https) to which the lifecycle events of the blockdevices are POSTed as JSON. Disabled if empty")
+	cmd.PersistentFlags().StringVar(&options.RescanCheckpointFile, "rescan-checkpoint-file",
+		"",
+		"File in which the progress of full rescans is recorded, so that an interrupted rescan is resumed "+
+			"without running the SMART probes again on the devices already processed. Disabled if empty")
+	cmd.PersistentFlags().DurationVar(&options.RescanCheckpointTTL, "rescan-checkpoint-ttl",
+		controller.DefaultRescanCheckpointTTL,
+		"Time for which the details of the devices recorded in the rescan checkpoint are reused")
+	cmd.PersistentFlags().Uint64Var(&options.IOErrorThreshold, "io-error-threshold",
+		0,
+		"Number of IO errors of a SCSI device within the io error window, at which the device is in the "+
+			"error state and no destructive operation is performed on it. Disabled if 0")
+	cmd.PersistentFlags().DurationVar(&options.IOErrorWindow, "io-error-window",
+		controller.DefaultIOErrorWindow,
+		"Duration over which the IO errors of a device are counted against the io error threshold")
+	cmd.PersistentFlags().DurationVar(&options.SurfaceScanInterval, "surface-scan-interval",
+		0,
+		"Interval at which a sample of the sectors of the unclaimed disks not in use are read, to find "+
+			"unreadable sectors. Disabled if 0")
+	cmd.PersistentFlags().Int64Var(&options.SurfaceScanRate, "surface-scan-rate",
+		controller.DefaultSurfaceScanRate,
+		"Maximum rate in bytes per second at which a disk is read during a surface scan")
+	cmd.PersistentFlags().BoolVar(&options.WritabilityCheck, "writability-check",
+		false,
+		"Check that blank disks are writable by writing to a scratch region at their end and reading it back, "+
+			"and report the result as the Writable condition of the blockdevice")
+	cmd.PersistentFlags().BoolVar(&options.VerifyPartitionBlank, "verify-partition-blank",
+		false,
+		"Verify that the partitions created by NDM on blank disks have no signatures, and quarantine them "+
+			"with the UnexpectedSignature condition if they do")
+	cmd.PersistentFlags().DurationVar(&options.SMARTRefreshInterval, "smart-refresh-interval",
+		0,
+		"Interval at which the temperature of the disks is read again from SMART to update their "+
+			"OverTemperature condition. Each disk is read at a random offset within the interval. Disabled if 0")
+	cmd.PersistentFlags().IntVar(&options.SMARTRefreshConcurrency, "smart-refresh-concurrency",
+		controller.DefaultSMARTRefreshConcurrency,
+		"Maximum number of disks whose SMART data is read at the same time")
+	cmd.PersistentFlags().DurationVar(&options.ShutdownTimeout, "shutdown-timeout",
+		controller.DefaultShutdownTimeout,
+		"Maximum time to wait on shutdown for the devices being processed. 0 does not wait")
 	_ = goflag.CommandLine.Parse([]string{})
 
 	cmd.AddCommand(
 		NewCmdBlockDevice(), //Add new command on block device
 		NewCmdStart(),       //Add new command to start the ndm controller
+		NewCmdInventory(),   //Add new command to print the inventory of devices
 	)
 
 	return cmd, nil
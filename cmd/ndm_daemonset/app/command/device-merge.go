@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/spf13/cobra"
+)
+
+// NewSubCmdMergeDuplicates is to merge the duplicate block devices
+func NewSubCmdMergeDuplicates() *cobra.Command {
+	var apply bool
+	mergeCmd := &cobra.Command{
+		Use:   "merge-duplicates",
+		Short: "Merge block devices created more than once for the same device",
+		Long: `the block devices of a node having the same stable identifier are
+		merged into one via 'ndm device merge-duplicates' command. The claimed
+		block device, or else the newest active one, is retained, claims are moved
+		to it and the rest are deleted. Block devices are merged only with --apply.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := mergeDuplicates(apply)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	mergeCmd.Flags().BoolVar(&apply, "apply",
+		false,
+		"Merge the duplicate block devices. Only the merge is printed if not set")
+
+	return mergeCmd
+}
+
+// mergeDuplicates merges the duplicate block devices in the cluster
+func mergeDuplicates(apply bool) error {
+	ctrl, err := controller.NewController()
+	if err != nil {
+		return err
+	}
+
+	err = ctrl.SetControllerOptions(options)
+	if err != nil {
+		return err
+	}
+	return ctrl.MergeDuplicateBlockDevices(os.Stdout, apply)
+}
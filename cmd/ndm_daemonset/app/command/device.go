@@ -31,6 +31,7 @@ func NewCmdBlockDevice() *cobra.Command {
 	//New sub command to list block device is added
 	cmd.AddCommand(
 		NewSubCmdListBlockDevice(),
+		NewSubCmdMergeDuplicates(),
 	)
 
 	return cmd
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/filter"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/probe"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	inventoryOutputTable = "table"
+	inventoryOutputJSON  = "json"
+)
+
+/*
+defaultInventory template is used to print the inventory as a table
+This template looks like below -
+
+root@instance-1:~#ndm inventory
+PATH           TYPE        UUID                                           USED-BY     CLAIM-STATE  ACTION      REASON
+/dev/sda       disk                                                                                skip        boot-disk
+/dev/sdb       disk        blockdevice-ccc636c88bd9ab09dde9de476309058d               Unclaimed    manage
+/dev/sdc       disk                                                                                partition
+*/
+const defaultInventory = `
+{{- printf "%-15s" "PATH"}}
+{{- printf "%-12s" "TYPE"}}
+{{- printf "%-47s" "UUID"}}
+{{- printf "%-12s" "USED-BY"}}
+{{- printf "%-13s" "CLAIM-STATE"}}
+{{- printf "%-12s" "ACTION"}}
+{{- printf "%s" "REASON"}}
+{{range .}}
+	{{- printf "%-15s" .DevPath}}
+	{{- printf "%-12s" .DeviceType}}
+	{{- printf "%-47s" .UUID}}
+	{{- printf "%-12s" .UsedBy}}
+	{{- printf "%-13s" .ClaimState}}
+	{{- printf "%-12s" .Action}}
+	{{- printf "%s" .Reason}}
+{{end}}`
+
+// NewCmdInventory creates the command to print the inventory of devices on the node
+func NewCmdInventory() *cobra.Command {
+	var output string
+	getCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Print the inventory of devices on the node",
+		Long: `the devices on the node, their identifiers and the action
+		that NDM would take on each of them can be listed via 'ndm inventory'
+		command. Nothing is modified on the devices or the resources.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := inventory(os.Stdout, output)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	getCmd.Flags().StringVarP(&output, "output", "o",
+		inventoryOutputTable,
+		"Output format. Can be table or json")
+
+	return getCmd
+}
+
+// inventory evaluates the devices on the node in the evaluation mode and prints the
+// inventory in the given output format
+func inventory(w io.Writer, output string) error {
+	if output != inventoryOutputTable && output != inventoryOutputJSON {
+		return fmt.Errorf("invalid output format: %q, should be one of %s, %s",
+			output, inventoryOutputTable, inventoryOutputJSON)
+	}
+
+	ctrl, err := controller.NewController()
+	if err != nil {
+		return err
+	}
+	err = ctrl.SetControllerOptions(options)
+	if err != nil {
+		return err
+	}
+	// the evaluation mode should be set before the probes are registered, so that
+	// they are not started
+	ctrl.StartEvaluation()
+	ctrl.Broadcast()
+	filter.Start(filter.RegisteredFilters)
+	probe.Start(probe.RegisteredProbes)
+
+	entries, err := probe.Inventory(ctrl)
+	if err != nil {
+		return err
+	}
+	return printInventory(w, output, entries)
+}
+
+// printInventory prints the inventory entries in the given output format
+func printInventory(w io.Writer, output string, entries []probe.InventoryEntry) error {
+	if output == inventoryOutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	inventoryTemplate := template.Must(template.New("defaultInventory").Parse(defaultInventory))
+	return inventoryTemplate.Execute(w, entries)
+}
@@ -26,7 +26,9 @@ import (
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/grpc"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/probe"
 	"github.com/openebs/node-disk-manager/pkg/features"
+	"github.com/openebs/node-disk-manager/pkg/server"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -56,6 +58,16 @@ func NewCmdStart() *cobra.Command {
 				fmt.Println(err)
 				os.Exit(1)
 			}
+			if options.MetricsAddress != "" {
+				metricsServer := server.Server{
+					ListenPort:  options.MetricsAddress,
+					MetricsPath: "/metrics",
+					Handler:     promhttp.Handler(),
+				}
+				go func() {
+					_ = metricsServer.Start()
+				}()
+			}
 			// Broadcast starts broadcasting controller pointer. Using this
 			// each probe and filter registers themselves.
 			ctrl.Broadcast()
@@ -63,6 +75,17 @@ func NewCmdStart() *cobra.Command {
 			filter.Start(filter.RegisteredFilters)
 			// Start starts registering of probes present in RegisteredProbes
 			probe.Start(probe.RegisteredProbes)
+			// the decisions are evaluated using the registered filters and probes
+			if options.DecisionAddress != "" {
+				decisionServer := server.Server{
+					ListenPort:  options.DecisionAddress,
+					MetricsPath: probe.DecisionPath,
+					Handler:     &probe.DecisionHandler{Controller: ctrl},
+				}
+				go func() {
+					_ = decisionServer.Start()
+				}()
+			}
 			ctrl.Start()
 
 		},
@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+)
+
+// IsDeviceAllowListed checks if the device is managed as per the allow list in the config.
+// If not, the reason is returned. All the devices are managed if the allow list mode is not
+// enabled.
+func (c *Controller) IsDeviceAllowListed(bd blockdevice.BlockDevice) (bool, string) {
+	if c.NDMConfig == nil || c.NDMConfig.AllowListConfig == nil || !c.NDMConfig.AllowListConfig.Enabled {
+		return true, ""
+	}
+	for _, device := range c.NDMConfig.AllowListConfig.Devices {
+		if matchesStableIdentifiers(device.WWN, device.Serial, bd) {
+			return true, ""
+		}
+	}
+	return false, "device is not in the allow list"
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDeviceAllowListed(t *testing.T) {
+	allowList := &AllowListConfig{
+		Enabled: true,
+		Devices: []AllowListDevice{
+			{WWN: "0x5000c500a1b2c3d4"},
+			{Serial: "ZA1B2C3E"},
+			{WWN: "0x5000c500a1b2c3d6", Serial: "ZA1B2C3F"},
+		},
+	}
+	newDevice := func(wwn, serial string) bd.BlockDevice {
+		device := bd.BlockDevice{}
+		device.DeviceAttributes.WWN = wwn
+		device.DeviceAttributes.Serial = serial
+		return device
+	}
+	tests := map[string]struct {
+		allowList *AllowListConfig
+		device    bd.BlockDevice
+		want      bool
+	}{
+		"no allow list manages all the devices": {
+			allowList: nil,
+			device:    newDevice("0x5000c500a1b2c3d9", "ZA1B2C3Z"),
+			want:      true,
+		},
+		"disabled allow list manages all the devices": {
+			allowList: &AllowListConfig{Devices: allowList.Devices},
+			device:    newDevice("0x5000c500a1b2c3d9", "ZA1B2C3Z"),
+			want:      true,
+		},
+		"device listed by wwn": {
+			allowList: allowList,
+			device:    newDevice("0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			want:      true,
+		},
+		"device listed by serial": {
+			allowList: allowList,
+			device:    newDevice("", "ZA1B2C3E"),
+			want:      true,
+		},
+		"device matching only one of the identifiers": {
+			allowList: allowList,
+			device:    newDevice("0x5000c500a1b2c3d6", "ZA1B2C3G"),
+			want:      false,
+		},
+		"device not listed": {
+			allowList: allowList,
+			device:    newDevice("0x5000c500a1b2c3d9", "ZA1B2C3Z"),
+			want:      false,
+		},
+		"device without identifiers": {
+			allowList: allowList,
+			device:    newDevice("", ""),
+			want:      false,
+		},
+		"empty allow list manages no device": {
+			allowList: &AllowListConfig{Enabled: true},
+			device:    newDevice("0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			want:      false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{NDMConfig: &NodeDiskManagerConfig{AllowListConfig: test.allowList}}
+			got, _ := c.IsDeviceAllowListed(test.device)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// AuditSink defines where the audit log of the destructive operations is written
+type AuditSink string
+
+const (
+	// StdoutAuditSink writes the audit records to the standard output, separate from the
+	// logs which are written to the standard error
+	StdoutAuditSink AuditSink = "stdout"
+
+	// FileAuditSink appends the audit records to a file
+	FileAuditSink AuditSink = "file"
+
+	// EventsAuditSink records the audit records as events on the node
+	EventsAuditSink AuditSink = "events"
+
+	// DefaultAuditSink is the sink used if none is specified
+	DefaultAuditSink = StdoutAuditSink
+)
+
+const (
+	// AuditTriggerDeviceAdded is the trigger of the operations performed while processing
+	// a device that was added or changed, or found during a scan
+	AuditTriggerDeviceAdded = "device-added"
+	// AuditTriggerDeviceRemoved is the trigger of the operations performed on the resource
+	// of a device that is no longer present on the node
+	AuditTriggerDeviceRemoved = "device-removed"
+	// AuditTriggerPartitionReclaim is the trigger of the operations performed while
+	// reclaiming the partitions flagged for reclaim
+	AuditTriggerPartitionReclaim = "partition-reclaim"
+	// AuditTriggerDuplicateMerge is the trigger of the operations performed while merging
+	// the duplicate resources of a device
+	AuditTriggerDuplicateMerge = "duplicate-merge"
+
+	// AuditResultSuccess is the result of an operation that succeeded
+	AuditResultSuccess = "success"
+	// AuditResultFailure is the result of an operation that failed
+	AuditResultFailure = "failure"
+
+	// auditEventReason is the reason of the events of the events audit sink
+	auditEventReason = "DestructiveOperation"
+)
+
+// ParseAuditSink validates and returns the audit sink.
+// Empty value is treated as the default sink.
+func ParseAuditSink(sink string) (AuditSink, error) {
+	switch AuditSink(sink) {
+	case "":
+		return DefaultAuditSink, nil
+	case StdoutAuditSink, FileAuditSink, EventsAuditSink:
+		return AuditSink(sink), nil
+	}
+	return "", fmt.Errorf("invalid audit sink: %q, should be one of %s, %s, %s",
+		sink, StdoutAuditSink, FileAuditSink, EventsAuditSink)
+}
+
+// AuditTarget identifies the device and the resource on which a destructive operation
+// is performed
+type AuditTarget struct {
+	// Device is the path of the device
+	Device string
+	// BlockDevice is the name of the resource of the device
+	BlockDevice string
+	// WWN is the WWN of the device, if known
+	WWN string
+	// Serial is the serial number of the device, if known
+	Serial string
+}
+
+// getAuditTarget gets the device and the resource of the blockdevice as the target of
+// a destructive operation.
+func getAuditTarget(blockDevice apis.BlockDevice) AuditTarget {
+	return AuditTarget{
+		Device:      blockDevice.Spec.Path,
+		BlockDevice: blockDevice.Name,
+		WWN:         GetBlockDeviceWWN(blockDevice),
+		Serial:      blockDevice.Spec.Details.Serial,
+	}
+}
+
+// GetBlockDeviceWWN gets the WWN of the device of the blockdevice. The WWN is not a field
+// of the resource, it is taken from the by-id link of the device, which has the vendor
+// extension of the WWN appended, if any. Empty string is returned if there is no link.
+func GetBlockDeviceWWN(blockDevice apis.BlockDevice) string {
+	for _, devLink := range blockDevice.Spec.DevLinks {
+		if devLink.Kind != "by-id" {
+			continue
+		}
+		for _, link := range devLink.Links {
+			if wwn := strings.TrimPrefix(filepath.Base(link), "wwn-"); wwn != filepath.Base(link) {
+				return wwn
+			}
+		}
+	}
+	return ""
+}
+
+// AuditCause is why a destructive operation is performed
+type AuditCause struct {
+	// Trigger is the event that triggered the operation, eg: device-added
+	Trigger string
+	// Reason is the decision for which the operation is performed
+	Reason string
+}
+
+// AuditRecord is a record of a destructive operation in the audit log. The records are
+// chained, each record having the hash of the previous record, so that a record that is
+// modified or removed is detected by VerifyAuditLog. The hash is a HMAC with the audit
+// key, if configured. Without a key, anyone who can write the log can recompute the
+// chain, and only accidental modifications are detected.
+type AuditRecord struct {
+	Sequence    uint64 `json:"sequence"`
+	Timestamp   string `json:"timestamp"`
+	Node        string `json:"node"`
+	Instance    string `json:"instance,omitempty"`
+	Operation   string `json:"operation"`
+	Device      string `json:"device,omitempty"`
+	BlockDevice string `json:"blockDevice,omitempty"`
+	WWN         string `json:"wwn,omitempty"`
+	Serial      string `json:"serial,omitempty"`
+	Trigger     string `json:"trigger"`
+	Reason      string `json:"reason"`
+	Result      string `json:"result"`
+	Error       string `json:"error,omitempty"`
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+// getHash gets the hash of the record, computed over the hash of the previous record and
+// the record without its own hash. It is a HMAC-SHA256 if the key is set.
+func (r AuditRecord) getHash(key []byte) (string, error) {
+	r.Hash = ""
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256(append([]byte(r.PrevHash), payload...))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(r.PrevHash))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// AuditLog writes the records of the destructive operations, one JSON record per line.
+// Only the file sink continues the chain of records across restarts, the chain of the
+// stdout and events sinks starts again from sequence 1 with every start of NDM.
+type AuditLog struct {
+	mutex    sync.Mutex
+	writer   io.Writer
+	key      []byte
+	sequence uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// NewAuditLog returns an audit log writing to the given writer. The chain of records
+// continues from the last record, if any, eg: the last record in the audit log file.
+func NewAuditLog(writer io.Writer, last *AuditRecord) *AuditLog {
+	a := &AuditLog{
+		writer: writer,
+		now:    time.Now,
+	}
+	if last != nil {
+		a.sequence = last.Sequence
+		a.lastHash = last.Hash
+	}
+	return a
+}
+
+// write completes the record with its position in the chain and writes it
+func (a *AuditLog) write(record AuditRecord) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	record.Sequence = a.sequence + 1
+	record.Timestamp = a.now().UTC().Format(time.RFC3339Nano)
+	record.PrevHash = a.lastHash
+	hash, err := record.getHash(a.key)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := a.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// the records in a file are synced, so that a record is not lost on a crash
+	if f, ok := a.writer.(*os.File); ok && f != os.Stdout {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	a.sequence = record.Sequence
+	a.lastHash = record.Hash
+	return nil
+}
+
+// Audit records the destructive operation performed on the target in the audit log.
+// The operation is recorded irrespective of whether it succeeded, along with the error.
+// Nothing is recorded in the evaluation mode, as the operations are not performed.
+func (c *Controller) Audit(op DestructiveOperation, target AuditTarget, cause AuditCause, err error) {
+	if c.AuditLog == nil || c.Evaluation != nil {
+		return
+	}
+	record := AuditRecord{
+		Node:        c.NodeAttributes[NodeNameKey],
+		Instance:    c.InstanceID,
+		Operation:   string(op),
+		Device:      target.Device,
+		BlockDevice: target.BlockDevice,
+		WWN:         target.WWN,
+		Serial:      target.Serial,
+		Trigger:     cause.Trigger,
+		Reason:      cause.Reason,
+		Result:      AuditResultSuccess,
+	}
+	if err != nil {
+		record.Result = AuditResultFailure
+		record.Error = err.Error()
+	}
+	if err := c.AuditLog.write(record); err != nil {
+		klog.Errorf("eventcode=%s msg=%s op=%s err=%v rname=%v",
+			"ndm.audit.failure", "Unable to write audit record", op, err, target.Device)
+	}
+}
+
+// newAuditLog creates the audit log writing to the sink. The records are hashed with the
+// key read from the key file, if any.
+func (c *Controller) newAuditLog(sink AuditSink, file, keyFile string) (*AuditLog, error) {
+	var key []byte
+	if keyFile != "" {
+		var err error
+		key, err = os.ReadFile(filepath.Clean(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit key file %s: %v", keyFile, err)
+		}
+		key = bytes.TrimSpace(key)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("audit key file %s is empty", keyFile)
+		}
+	}
+
+	var a *AuditLog
+	switch sink {
+	case FileAuditSink:
+		if file == "" {
+			return nil, fmt.Errorf("audit log file is required for audit sink: %s", FileAuditSink)
+		}
+		last, err := readLastAuditRecord(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(filepath.Clean(file), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open audit log file %s: %v", file, err)
+		}
+		a = NewAuditLog(f, last)
+	case EventsAuditSink:
+		a = NewAuditLog(&auditEventWriter{controller: c}, nil)
+	default:
+		a = NewAuditLog(os.Stdout, nil)
+	}
+	a.key = key
+	return a, nil
+}
+
+// auditEventWriter writes the audit records as events on the node
+type auditEventWriter struct {
+	controller *Controller
+}
+
+func (w *auditEventWriter) Write(line []byte) (int, error) {
+	w.controller.recordNodeEvent(v1.EventTypeNormal, auditEventReason, "%s", bytes.TrimSpace(line))
+	return len(line), nil
+}
+
+// readLastAuditRecord reads the last record in the audit log file, so that the chain is
+// continued after a restart. nil is returned if the file does not exist or is empty.
+// A partial record at the end of the file, left by a crash while the record was being
+// written, is truncated, so that the next record starts on a new line.
+func readLastAuditRecord(file string) (*AuditRecord, error) {
+	f, err := os.OpenFile(filepath.Clean(file), os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var last *AuditRecord
+	var offset int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return last, nil
+			}
+			klog.Warningf("eventcode=%s msg=%s offset=%d file=%s",
+				"ndm.audit.truncated", "Truncating partial record at the end of the audit log", offset, file)
+			if err := f.Truncate(offset); err != nil {
+				return nil, fmt.Errorf("unable to truncate partial record in audit log file %s: %v", file, err)
+			}
+			return last, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit log file %s: %v", file, err)
+		}
+		record := AuditRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("invalid record in audit log file %s: %v", file, err)
+		}
+		last = &record
+		offset += int64(len(line))
+	}
+}
+
+// VerifyAuditLog verifies the chain of records in the audit log, hashed with the given key
+// if any, and returns an error describing the first record that was modified, or that
+// follows a removed record.
+func VerifyAuditLog(r io.Reader, key []byte) error {
+	var prev *AuditRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		record := AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("invalid audit record: %v", err)
+		}
+		if prev != nil && (record.Sequence != prev.Sequence+1 || record.PrevHash != prev.Hash) {
+			return fmt.Errorf("audit record %d does not follow record %d", record.Sequence, prev.Sequence)
+		}
+		hash, err := record.getHash(key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return fmt.Errorf("audit record %d has been modified", record.Sequence)
+		}
+		prev = &record
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getAuditRecords gets the records written to the audit log
+func getAuditRecords(t *testing.T, data []byte) []AuditRecord {
+	records := make([]AuditRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		record := AuditRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestParseAuditSink(t *testing.T) {
+	tests := map[string]struct {
+		sink    string
+		want    AuditSink
+		wantErr bool
+	}{
+		"empty sink":   {sink: "", want: StdoutAuditSink, wantErr: false},
+		"stdout sink":  {sink: "stdout", want: StdoutAuditSink, wantErr: false},
+		"file sink":    {sink: "file", want: FileAuditSink, wantErr: false},
+		"events sink":  {sink: "events", want: EventsAuditSink, wantErr: false},
+		"invalid sink": {sink: "syslog", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseAuditSink(tt.sink)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+		InstanceID:     "ndm-a",
+		AuditLog:       NewAuditLog(buf, nil),
+	}
+	c.AuditLog.now = func() time.Time { return time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC) }
+	target := AuditTarget{Device: "/dev/sdb", BlockDevice: "blockdevice-1", WWN: "0x5000c500a1b2c3d4", Serial: "serial-1"}
+	cause := AuditCause{Trigger: AuditTriggerDeviceAdded, Reason: "blank disk"}
+
+	c.Audit(CreatePartitionOperation, target, cause, nil)
+	c.Audit(DiscardOperation, target, cause, fmt.Errorf("operation not supported"))
+	c.Audit(VerifyWritableOperation, target, cause, nil)
+
+	records := getAuditRecords(t, buf.Bytes())
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, AuditRecord{
+		Sequence:    1,
+		Timestamp:   "2023-05-01T10:00:00Z",
+		Node:        "node-1",
+		Instance:    "ndm-a",
+		Operation:   string(CreatePartitionOperation),
+		Device:      "/dev/sdb",
+		BlockDevice: "blockdevice-1",
+		WWN:         "0x5000c500a1b2c3d4",
+		Serial:      "serial-1",
+		Trigger:     AuditTriggerDeviceAdded,
+		Reason:      "blank disk",
+		Result:      AuditResultSuccess,
+		PrevHash:    "",
+		Hash:        records[0].Hash,
+	}, records[0])
+	assert.Equal(t, AuditResultFailure, records[1].Result)
+	assert.Equal(t, "operation not supported", records[1].Error)
+	for i := 1; i < len(records); i++ {
+		assert.Equal(t, uint64(i+1), records[i].Sequence)
+		assert.Equal(t, records[i-1].Hash, records[i].PrevHash)
+	}
+	assert.NoError(t, VerifyAuditLog(bytes.NewReader(buf.Bytes()), nil))
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+		AuditLog:       NewAuditLog(buf, nil),
+	}
+	for _, op := range []DestructiveOperation{CreatePartitionOperation, WipeSignaturesOperation, DeletePartitionOperation} {
+		c.Audit(op, AuditTarget{Device: "/dev/sdb"}, AuditCause{Trigger: AuditTriggerPartitionReclaim, Reason: "reclaim"}, nil)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	tests := map[string]struct {
+		log     string
+		wantErr bool
+	}{
+		"intact log": {
+			log:     strings.Join(lines, ""),
+			wantErr: false,
+		},
+		"modified record": {
+			log:     lines[0] + strings.Replace(lines[1], `"reason":"reclaim"`, `"reason":"manual"`, 1) + lines[2],
+			wantErr: true,
+		},
+		"removed record": {
+			log:     lines[0] + lines[2],
+			wantErr: true,
+		},
+		"reordered records": {
+			log:     lines[1] + "\n" + lines[0] + lines[2],
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyAuditLog(strings.NewReader(tt.log), nil)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestFileAuditLog(t *testing.T) {
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+	}
+	_, err := c.newAuditLog(FileAuditSink, "", "")
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "ndm", "audit.log")
+	target := AuditTarget{Device: "/dev/sdb"}
+	cause := AuditCause{Trigger: AuditTriggerDeviceAdded, Reason: "blank disk"}
+
+	// the chain of records is continued after a restart
+	for i := 0; i < 2; i++ {
+		c.AuditLog, err = c.newAuditLog(FileAuditSink, file, "")
+		if !assert.NoError(t, err) {
+			return
+		}
+		c.Audit(CreatePartitionOperation, target, cause, nil)
+		c.Audit(DiscardOperation, target, cause, nil)
+	}
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	records := getAuditRecords(t, data)
+	if assert.Len(t, records, 4) {
+		assert.Equal(t, uint64(4), records[3].Sequence)
+	}
+	assert.NoError(t, VerifyAuditLog(bytes.NewReader(data), nil))
+}
+
+func TestAuditLogWithKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("audit-key\n"), 0600))
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+	}
+	_, err := c.newAuditLog(StdoutAuditSink, "", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "audit.log")
+	c.AuditLog, err = c.newAuditLog(FileAuditSink, file, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.Audit(CreatePartitionOperation, AuditTarget{Device: "/dev/sdb"}, AuditCause{Trigger: AuditTriggerDeviceAdded, Reason: "blank disk"}, nil)
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyAuditLog(bytes.NewReader(data), []byte("audit-key")))
+	assert.Error(t, VerifyAuditLog(bytes.NewReader(data), []byte("other-key")))
+
+	// a modified record cannot be re-hashed without the key
+	record := getAuditRecords(t, data)[0]
+	record.Reason = "manual"
+	record.Hash, err = record.getHash(nil)
+	assert.NoError(t, err)
+	line, err := json.Marshal(record)
+	assert.NoError(t, err)
+	assert.Error(t, VerifyAuditLog(bytes.NewReader(line), []byte("audit-key")))
+}
+
+func TestFileAuditLogWithPartialRecord(t *testing.T) {
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+	}
+	file := filepath.Join(t.TempDir(), "audit.log")
+	target := AuditTarget{Device: "/dev/sdb"}
+	cause := AuditCause{Trigger: AuditTriggerDeviceAdded, Reason: "blank disk"}
+
+	var err error
+	c.AuditLog, err = c.newAuditLog(FileAuditSink, file, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.Audit(CreatePartitionOperation, target, cause, nil)
+
+	// a crash while writing the second record leaves a partial record
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"sequence":2,"timestamp":"2023-05`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	c.AuditLog, err = c.newAuditLog(FileAuditSink, file, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.Audit(DiscardOperation, target, cause, nil)
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	records := getAuditRecords(t, data)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, uint64(2), records[1].Sequence)
+		assert.Equal(t, string(DiscardOperation), records[1].Operation)
+	}
+	assert.NoError(t, VerifyAuditLog(bytes.NewReader(data), nil))
+}
+
+func TestAuditInEvaluationMode(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+		Clientset:      CreateFakeClient(t),
+		AuditLog:       NewAuditLog(buf, nil),
+	}
+	c.StartEvaluation()
+	c.Audit(CreatePartitionOperation, AuditTarget{Device: "/dev/sdb"}, AuditCause{Trigger: AuditTriggerDeviceAdded, Reason: "blank disk"}, nil)
+	assert.Empty(t, buf.String())
+}
+
+func TestAuditOfBlockDeviceOperations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Controller{
+		NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+		Clientset:      CreateFakeClient(t),
+		AuditLog:       NewAuditLog(buf, nil),
+	}
+	bd := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "blockdevice-audit",
+			Labels: make(map[string]string),
+		},
+		Spec: apis.DeviceSpec{
+			Path: "/dev/sdb",
+			Details: apis.DeviceDetails{
+				Serial: "serial-1",
+			},
+			DevLinks: []apis.DeviceDevLink{
+				{
+					Kind:  "by-id",
+					Links: []string{"/dev/disk/by-id/scsi-35000c500a1b2c3d4", "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4"},
+				},
+			},
+		},
+		Status: apis.DeviceStatus{
+			State: NDMActive,
+		},
+	}
+	assert.NoError(t, c.Clientset.Create(context.TODO(), bd.DeepCopy()))
+	cause := AuditCause{Trigger: AuditTriggerDeviceRemoved, Reason: "device is no longer present on the node"}
+
+	created, err := c.GetBlockDevice(bd.Name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.DeactivateBlockDevice(*created, cause)
+	c.DeleteBlockDevice(bd.Name, cause)
+	c.DeleteBlockDevice("blockdevice-missing", cause)
+
+	records := getAuditRecords(t, buf.Bytes())
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, string(DeactivateBlockDeviceOperation), records[0].Operation)
+	assert.Equal(t, "/dev/sdb", records[0].Device)
+	assert.Equal(t, "blockdevice-audit", records[0].BlockDevice)
+	assert.Equal(t, "serial-1", records[0].Serial)
+	assert.Equal(t, "0x5000c500a1b2c3d4", records[0].WWN)
+	assert.Equal(t, AuditResultSuccess, records[0].Result)
+	assert.Equal(t, string(DeleteBlockDeviceOperation), records[1].Operation)
+	assert.Equal(t, "blockdevice-audit", records[1].BlockDevice)
+	assert.Equal(t, AuditResultSuccess, records[1].Result)
+	assert.Equal(t, AuditResultFailure, records[2].Result)
+	for _, record := range records {
+		assert.Equal(t, "node-1", record.Node)
+		assert.Equal(t, cause.Trigger, record.Trigger)
+		assert.Equal(t, cause.Reason, record.Reason)
+	}
+}
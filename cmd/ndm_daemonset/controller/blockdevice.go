@@ -41,23 +41,69 @@ type DeviceInfo struct {
 	NodeAttributes bd.NodeAttribute
 	// Optional labels that can be added to the blockdevice resource
 	Labels             map[string]string
-	UUID               string   // UUID of backing disk
-	Capacity           uint64   // Capacity of blockdevice
-	Model              string   // Do blockdevice have model ??
-	Serial             string   // Do blockdevice have serial no ??
-	Vendor             string   // Vendor of blockdevice
-	Path               string   // blockdevice Path like /dev/sda
-	ByIdDevLinks       []string // ByIdDevLinks contains by-id devlinks
-	ByPathDevLinks     []string // ByPathDevLinks contains by-path devlinks
-	FirmwareRevision   string   // FirmwareRevision is the firmware revision for a disk
-	LogicalBlockSize   uint32   // LogicalBlockSize is the logical block size of the device in bytes
-	PhysicalBlockSize  uint32   // PhysicalBlockSize is the physical block size in bytes
-	HardwareSectorSize uint32   // HardwareSectorSize is the hardware sector size in bytes
-	Compliance         string   // Compliance is implemented specifications version i.e. SPC-1, SPC-2, etc
-	DeviceType         string   // DeviceType represents the type of device, like disk/sparse/partition
-	DriveType          string   // DriveType represents the type of backing drive HDD/SSD
-	PartitionType      string   // Partition type if the blockdevice is a partition
-	FileSystemInfo     FSInfo   // FileSystem info of the blockdevice like FSType and MountPoint
+	UUID               string              // UUID of backing disk
+	Capacity           uint64              // Capacity of blockdevice
+	Model              string              // Do blockdevice have model ??
+	Serial             string              // Do blockdevice have serial no ??
+	Vendor             string              // Vendor of blockdevice
+	Path               string              // blockdevice Path like /dev/sda
+	DevLinks           map[string][]string // DevLinks contains devlinks like by-id, by-path, by-uuid grouped by kind
+	FirmwareRevision   string              // FirmwareRevision is the firmware revision for a disk
+	FormFactor         string              // FormFactor is the nominal form factor of the disk
+	WriteEnduranceTBW  uint64              // WriteEnduranceTBW is the write endurance of the SSD in terabytes written
+	LogicalBlockSize   uint32              // LogicalBlockSize is the logical block size of the device in bytes
+	PhysicalBlockSize  uint32              // PhysicalBlockSize is the physical block size in bytes
+	HardwareSectorSize uint32              // HardwareSectorSize is the hardware sector size in bytes
+	Compliance         string              // Compliance is implemented specifications version i.e. SPC-1, SPC-2, etc
+	DeviceType         string              // DeviceType represents the type of device, like disk/sparse/partition
+	DriveType          string              // DriveType represents the type of backing drive HDD/SSD
+	PartitionType      string              // Partition type if the blockdevice is a partition
+	PartitionGUID      string              // PartitionGUID is the GPT partition GUID if the blockdevice is a partition
+	PartitionNumber    uint8               // PartitionNumber is the number of the partition on the parent disk
+	PartitionOffset    uint64              // PartitionOffset is the offset in bytes of the partition on the parent disk
+	FileSystemInfo     FSInfo              // FileSystem info of the blockdevice like FSType and MountPoint
+	DiscardSupported   bool                // DiscardSupported is true if the device supports discard (TRIM / UNMAP)
+	DiscardGranularity uint64              // DiscardGranularity is the discard granularity of the device in bytes
+	IOScheduler        string              // IOScheduler is the active IO scheduler of the device
+	QueueDepth         uint64              // QueueDepth is the number of requests that can be queued for the device
+	WriteCache         string              // WriteCache is the state of the volatile write cache, enabled or disabled
+	Transport          string              // Transport is the transport over which the device is attached
+	FabricTarget       string              // FabricTarget is the NQN / IQN of the fabric target exporting the device
+	FabricAddress      string              // FabricAddress is the address of the fabric target
+	// Temperature is the current temperature of the device in celsius reported by SMART.
+	// It is nil if the temperature is not known.
+	Temperature *int16
+	// LayoutChecked is true if the layout of the partition on the parent disk was checked
+	LayoutChecked bool
+	// LayoutViolation describes how the partition violates the reserved layout of the
+	// parent disk. Empty if the layout is valid.
+	LayoutViolation string
+	// BlankChecked is true if the partition created by NDM was verified to be blank
+	BlankChecked bool
+	// UnexpectedSignatures are the signatures found on the partition created by NDM.
+	// Empty if the partition is blank.
+	UnexpectedSignatures []string
+	// PartitionTableChecked is true if the partition table of the disk, or of the parent
+	// disk of the partition, was validated
+	PartitionTableChecked bool
+	// PartitionTableCorruption describes the defect in the partition table. Empty if the
+	// partition table is valid.
+	PartitionTableCorruption string
+	// PCIeLink is the link of the PCIe controller of the device. It is nil if the device
+	// is not attached over PCIe.
+	PCIeLink *bd.PCIeLink
+	// IOErrors is the number of IO errors of the SCSI device within the error window. It
+	// is nil if the IO errors of the device are not tracked.
+	IOErrors *bd.IOErrorState
+	// Writability is the result of the writability check of the disk. It is nil if the
+	// disk was not checked.
+	Writability *bd.WritabilityState
+	// ParentPath is the path of the parent disk, if the blockdevice is a partition
+	ParentPath string
+	// PartitionPaths are the paths of the partitions of the blockdevice
+	PartitionPaths []string
+	// Annotations are added to the annotations of the blockdevice resource
+	Annotations map[string]string
 }
 
 // NewDeviceInfo returns a pointer of empty DeviceInfo
@@ -80,8 +126,34 @@ func (di *DeviceInfo) ToDevice(controller *Controller) (apis.BlockDevice, error)
 	blockDevice := apis.BlockDevice{}
 	blockDevice.Spec = di.getDeviceSpec()
 	blockDevice.ObjectMeta = di.getObjectMeta()
+	// the uuid is sanitized when it is generated, an invalid name at this point is a bug
+	if err := validateResourceName(blockDevice.Name); err != nil {
+		return blockDevice, err
+	}
 	blockDevice.TypeMeta = di.getTypeMeta()
 	blockDevice.Status = di.getStatus()
+	blockDevice.Status.Parent, blockDevice.Status.Children = controller.getHierarchyReferences(di)
+	if condition, ok := controller.getTemperatureCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getLayoutCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getDegradedLinkCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getErrorStateCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getWritableCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getUnexpectedSignatureCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
+	if condition, ok := getCorruptPartitionTableCondition(di); ok {
+		blockDevice.Status.Conditions = append(blockDevice.Status.Conditions, condition)
+	}
 	err := addBdLabels(&blockDevice, controller)
 	if err != nil {
 		return blockDevice, fmt.Errorf("error in adding labels to the blockdevice: %v", err)
@@ -158,15 +230,19 @@ func addBdLabels(bd *apis.BlockDevice, ctrl *Controller) error {
 }
 
 // RelaxedJSONPathExpression attempts to be flexible with JSONPath expressions, it accepts:
-//   * metadata.name (no leading '.' or curly braces '{...}'
-//   * {metadata.name} (no leading '.')
-//   * .metadata.name (no curly braces '{...}')
-//   * {.metadata.name} (complete expression)
+//   - metadata.name (no leading '.' or curly braces '{...}'
+//   - {metadata.name} (no leading '.')
+//   - .metadata.name (no curly braces '{...}')
+//   - {.metadata.name} (complete expression)
+//
 // And transforms them all into a valid jsonpath expression:
-//   {.metadata.name}
+//
+//	{.metadata.name}
+//
 // NOTE: This code has been referenced from kubernetes kubectl github repo.
-//       Ref: https://github.com/kubernetes/kubectl/blob/caeb9274868c57d8a320014290cc7e3d1bcb9e46/pkg/cmd/get
-//      /customcolumn.go#L47
+//
+//	 Ref: https://github.com/kubernetes/kubectl/blob/caeb9274868c57d8a320014290cc7e3d1bcb9e46/pkg/cmd/get
+//	/customcolumn.go#L47
 func RelaxedJSONPathExpression(pathExpression string) (string, error) {
 	var jsonRegexp = regexp.MustCompile(`^\{\.?([^{}]+)\}$|^\.?([^{}]+)$`)
 
@@ -212,6 +288,9 @@ func (di *DeviceInfo) getObjectMeta() metav1.ObjectMeta {
 	for k, v := range di.Labels {
 		objectMeta.Labels[k] = v
 	}
+	for k, v := range di.Annotations {
+		objectMeta.Annotations[k] = v
+	}
 	return objectMeta
 }
 
@@ -251,9 +330,24 @@ func (di *DeviceInfo) getDeviceSpec() apis.DeviceSpec {
 	deviceSpec.DevLinks = di.getDeviceLinks()
 	deviceSpec.Partitioned = NDMNotPartitioned
 	deviceSpec.FileSystem = di.FileSystemInfo.getFileSystemInfo()
+	deviceSpec.PartitionInfo = di.getPartitionInfo()
 	return deviceSpec
 }
 
+// getPartitionInfo returns the slot of the partition on the parent disk, so that the
+// partition blockdevice can be correlated with its entry in the partition table. It is
+// nil if the blockdevice is not a partition.
+func (di *DeviceInfo) getPartitionInfo() *apis.DevicePartitionInfo {
+	if di.DeviceType != bd.BlockDeviceTypePartition {
+		return nil
+	}
+	return &apis.DevicePartitionInfo{
+		PartitionGUID:   di.PartitionGUID,
+		PartitionNumber: di.PartitionNumber,
+		StartOffset:     di.PartitionOffset,
+	}
+}
+
 // getPath returns path of the blockdevice like (/dev/sda , /dev/sdb ...).
 // It is used to populate data of BlockDevice struct of BlockDevice CR.
 func (di *DeviceInfo) getPath() string {
@@ -269,12 +363,22 @@ func (di *DeviceInfo) getDeviceDetails() apis.DeviceDetails {
 	deviceDetails.Serial = di.Serial
 	deviceDetails.Vendor = di.Vendor
 	deviceDetails.FirmwareRevision = di.FirmwareRevision
+	deviceDetails.FormFactor = di.FormFactor
+	deviceDetails.WriteEnduranceTBW = di.WriteEnduranceTBW
 	deviceDetails.Compliance = di.Compliance
 	deviceDetails.DeviceType = di.DeviceType
 	deviceDetails.DriveType = di.DriveType
 	deviceDetails.LogicalBlockSize = di.LogicalBlockSize
 	deviceDetails.PhysicalBlockSize = di.PhysicalBlockSize
 	deviceDetails.HardwareSectorSize = di.HardwareSectorSize
+	deviceDetails.DiscardSupported = di.DiscardSupported
+	deviceDetails.DiscardGranularity = di.DiscardGranularity
+	deviceDetails.IOScheduler = di.IOScheduler
+	deviceDetails.QueueDepth = di.QueueDepth
+	deviceDetails.WriteCache = di.WriteCache
+	deviceDetails.Transport = di.Transport
+	deviceDetails.FabricTarget = di.FabricTarget
+	deviceDetails.FabricAddress = di.FabricAddress
 
 	return deviceDetails
 }
@@ -297,19 +401,14 @@ func (di *DeviceInfo) getDeviceCapacity() apis.DeviceCapacity {
 // data of BlockDevice struct of BlockDevice CR.
 func (di *DeviceInfo) getDeviceLinks() []apis.DeviceDevLink {
 	devLinks := make([]apis.DeviceDevLink, 0)
-	if len(di.ByIdDevLinks) != 0 {
-		byIDLinks := apis.DeviceDevLink{
-			Kind:  "by-id",
-			Links: di.ByIdDevLinks,
-		}
-		devLinks = append(devLinks, byIDLinks)
-	}
-	if len(di.ByPathDevLinks) != 0 {
-		byPathLinks := apis.DeviceDevLink{
-			Kind:  "by-path",
-			Links: di.ByPathDevLinks,
+	for _, kind := range DevLinkKinds {
+		if len(di.DevLinks[kind]) == 0 {
+			continue
 		}
-		devLinks = append(devLinks, byPathLinks)
+		devLinks = append(devLinks, apis.DeviceDevLink{
+			Kind:  kind,
+			Links: di.DevLinks[kind],
+		})
 	}
 	return devLinks
 }
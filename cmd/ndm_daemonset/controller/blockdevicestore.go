@@ -22,6 +22,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
@@ -40,11 +41,13 @@ func (c *Controller) CreateBlockDevice(blockDevice apis.BlockDevice) error {
 	blockDevice.SetNamespace(c.Namespace)
 
 	blockDeviceCopy := blockDevice.DeepCopy()
+	c.stampManagedBy(blockDeviceCopy)
 	err := c.Clientset.Create(context.TODO(), blockDeviceCopy)
 	if err == nil {
 		klog.Infof("eventcode=%s msg=%s rname=%v",
 			"ndm.blockdevice.create.success", "Created blockdevice object in etcd",
 			blockDeviceCopy.ObjectMeta.Name)
+		c.recordConditionTransitions(blockDeviceCopy, nil)
 		return err
 	}
 
@@ -84,6 +87,14 @@ func (c *Controller) CreateBlockDevice(blockDevice apis.BlockDevice) error {
 
 // UpdateBlockDevice update the BlockDevice resource in etcd
 func (c *Controller) UpdateBlockDevice(blockDevice apis.BlockDevice, oldBlockDevice *apis.BlockDevice) error {
+	_, err := c.UpdateBlockDeviceIfChanged(blockDevice, oldBlockDevice)
+	return err
+}
+
+// UpdateBlockDeviceIfChanged updates the BlockDevice resource in etcd, and reports whether
+// it was written. The update is skipped if merging the new resource into the old one
+// leaves it unchanged, or if the resource is managed by a peer NDM instance.
+func (c *Controller) UpdateBlockDeviceIfChanged(blockDevice apis.BlockDevice, oldBlockDevice *apis.BlockDevice) (bool, error) {
 	var err error
 
 	blockDeviceCopy := blockDevice.DeepCopy()
@@ -97,31 +108,60 @@ func (c *Controller) UpdateBlockDevice(blockDevice apis.BlockDevice, oldBlockDev
 				"ndm.blockdevice.update.failure",
 				"Failed to update block device : unable to get blockdevice object",
 				blockDeviceCopy.ObjectMeta.Name, err, blockDeviceCopy.ObjectMeta.Name)
-			return err
+			return false, err
 		}
 	}
 
-	blockDeviceCopy = mergeBlockDeviceData(*blockDeviceCopy, *oldBlockDevice)
+	// the resource may have been created by a peer NDM instance in the meantime
+	if c.skipPeerManagedBlockDevice(*oldBlockDevice, "update") {
+		return false, nil
+	}
+	c.stampManagedBy(blockDeviceCopy)
+
+	oldConditions := oldBlockDevice.Status.Conditions
+	// the old resource is copied, as the merge modifies its labels and annotations
+	blockDeviceCopy = mergeBlockDeviceData(*blockDeviceCopy, *oldBlockDevice.DeepCopy())
+	if isBlockDeviceUnchanged(blockDeviceCopy, oldBlockDevice) {
+		klog.V(4).Infof("blockdevice: %s is unchanged, skipping update", blockDeviceCopy.ObjectMeta.Name)
+		return false, nil
+	}
 
 	err = c.Clientset.Update(context.TODO(), blockDeviceCopy)
 	if err != nil {
 		klog.Errorf("eventcode=%s msg=%s : %v rname=%v",
 			"ndm.blockdevice.update.failure", "Unable to update blockdevice object",
 			err, blockDeviceCopy.ObjectMeta.Name)
-		return err
+		return false, err
 	}
 	klog.Infof("eventcode=%s msg=%s rname=%v",
 		"ndm.blockdevice.update.success", "Updated blockdevice object",
 		blockDeviceCopy.ObjectMeta.Name)
-	return nil
+	c.recordConditionTransitions(blockDeviceCopy, oldConditions)
+	return true, nil
 }
 
-// DeactivateBlockDevice API is used to set blockdevice status to "inactive" state in etcd
-func (c *Controller) DeactivateBlockDevice(blockDevice apis.BlockDevice) {
+// isBlockDeviceUnchanged checks if the merged resource has the same metadata, spec and
+// status as the old resource, in which case updating it would be a no-op
+func isBlockDeviceUnchanged(mergedBD, oldBD *apis.BlockDevice) bool {
+	return equality.Semantic.DeepEqual(mergedBD.ObjectMeta, oldBD.ObjectMeta) &&
+		equality.Semantic.DeepEqual(mergedBD.Spec, oldBD.Spec) &&
+		equality.Semantic.DeepEqual(mergedBD.Status, oldBD.Status)
+}
+
+// DeactivateBlockDevice API is used to set blockdevice status to "inactive" state in etcd.
+// The deactivation is recorded in the audit log with the given cause.
+func (c *Controller) DeactivateBlockDevice(blockDevice apis.BlockDevice, cause AuditCause) {
+	if c.skipPeerManagedBlockDevice(blockDevice, "deactivation") {
+		return
+	}
+	if !c.IsDestructiveOperationAllowed(DeactivateBlockDeviceOperation, blockDevice.Name) {
+		return
+	}
 
 	blockDeviceCopy := blockDevice.DeepCopy()
 	blockDeviceCopy.Status.State = NDMInactive
 	err := c.Clientset.Update(context.TODO(), blockDeviceCopy)
+	c.Audit(DeactivateBlockDeviceOperation, getAuditTarget(blockDevice), cause, err)
 	if err != nil {
 		klog.Errorf("eventcode=%s msg=%s : %v rname=%v ",
 			"ndm.blockdevice.deactivate.failure", "Unable to deactivate blockdevice",
@@ -131,6 +171,47 @@ func (c *Controller) DeactivateBlockDevice(blockDevice apis.BlockDevice) {
 	klog.Infof("eventcode=%s msg=%s rname=%v",
 		"ndm.blockdevice.deactivate.success", "Deactivated blockdevice",
 		blockDeviceCopy.ObjectMeta.Name)
+	c.PublishInventoryEvent(InventoryEventDeactivated, *blockDeviceCopy)
+}
+
+// ActivateBlockDevice API is used to set blockdevice status to "active" state in etcd
+func (c *Controller) ActivateBlockDevice(blockDevice apis.BlockDevice) error {
+	blockDeviceCopy := blockDevice.DeepCopy()
+	blockDeviceCopy.Status.State = NDMActive
+	err := c.Clientset.Update(context.TODO(), blockDeviceCopy)
+	if err != nil {
+		klog.Errorf("eventcode=%s msg=%s : %v rname=%v ",
+			"ndm.blockdevice.activate.failure", "Unable to activate blockdevice",
+			err, blockDeviceCopy.ObjectMeta.Name)
+		return err
+	}
+	klog.Infof("eventcode=%s msg=%s rname=%v",
+		"ndm.blockdevice.activate.success", "Activated blockdevice",
+		blockDeviceCopy.ObjectMeta.Name)
+	return nil
+}
+
+// AnnotateBlockDevice API is used to add the given annotations to the blockdevice resource
+// in etcd. Existing annotations with the same keys are overwritten.
+func (c *Controller) AnnotateBlockDevice(blockDevice apis.BlockDevice, annotations map[string]string) error {
+	blockDeviceCopy := blockDevice.DeepCopy()
+	if blockDeviceCopy.Annotations == nil {
+		blockDeviceCopy.Annotations = make(map[string]string)
+	}
+	for key, value := range annotations {
+		blockDeviceCopy.Annotations[key] = value
+	}
+	err := c.Clientset.Update(context.TODO(), blockDeviceCopy)
+	if err != nil {
+		klog.Errorf("eventcode=%s msg=%s : %v rname=%v ",
+			"ndm.blockdevice.annotate.failure", "Unable to annotate blockdevice",
+			err, blockDeviceCopy.ObjectMeta.Name)
+		return err
+	}
+	klog.Infof("eventcode=%s msg=%s rname=%v",
+		"ndm.blockdevice.annotate.success", "Annotated blockdevice",
+		blockDeviceCopy.ObjectMeta.Name)
+	return nil
 }
 
 // GetBlockDevice get Disk resource from etcd
@@ -147,8 +228,9 @@ func (c *Controller) GetBlockDevice(name string) (*apis.BlockDevice, error) {
 	return dvr, nil
 }
 
-// DeleteBlockDevice delete the BlockDevice resource from etcd
-func (c *Controller) DeleteBlockDevice(name string) {
+// DeleteBlockDevice delete the BlockDevice resource from etcd. The deletion is recorded
+// in the audit log with the given cause.
+func (c *Controller) DeleteBlockDevice(name string, cause AuditCause) {
 	blockDevice := &apis.BlockDevice{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: make(map[string]string),
@@ -157,6 +239,7 @@ func (c *Controller) DeleteBlockDevice(name string) {
 	}
 
 	err := c.Clientset.Delete(context.TODO(), blockDevice)
+	c.Audit(DeleteBlockDeviceOperation, AuditTarget{BlockDevice: name}, cause, err)
 	if err != nil {
 		klog.Errorf("eventcode=%s msg=%s : %v rname=%v",
 			"ndm.blockdevice.delete.failure", "Unable to delete blockdevice object",
@@ -230,19 +313,27 @@ func (c *Controller) GetExistingBlockDeviceResource(blockDeviceList *apis.BlockD
 // DeactivateStaleBlockDeviceResource deactivates the stale entry from etcd.
 // It gets list of resources which are present in system and queries etcd to get
 // list of active resources. Active resource which is present in etcd not in
-// system that will be marked as inactive.
-func (c *Controller) DeactivateStaleBlockDeviceResource(devices []string) {
+// system that will be marked as inactive. The uuids of the stale resources are
+// returned, so that the removal policy can be applied to the ones that are not
+// activated again once all the devices on the node are processed.
+func (c *Controller) DeactivateStaleBlockDeviceResource(devices []string) []string {
 	listDevices := append(devices, GetActiveSparseBlockDevicesUUID(c.NodeAttributes[HostNameKey])...)
 	blockDeviceList, err := c.ListBlockDeviceResource(false)
 	if err != nil {
 		klog.Error(err)
-		return
+		return nil
 	}
+	staleDevices := make([]string, 0)
 	for _, item := range blockDeviceList.Items {
 		if !util.Contains(listDevices, item.ObjectMeta.Name) {
-			c.DeactivateBlockDevice(item)
+			c.DeactivateBlockDevice(item, AuditCause{
+				Trigger: AuditTriggerDeviceRemoved,
+				Reason:  "blockdevice not found at scan",
+			})
+			staleDevices = append(staleDevices, item.ObjectMeta.Name)
 		}
 	}
+	return staleDevices
 }
 
 // PushBlockDeviceResource is a utility function which checks old blockdevice resource
@@ -285,22 +376,49 @@ func (c *Controller) MarkBlockDeviceStatusToUnknown() {
 // with the system generated BlockDevice information
 // If the device is in use, then only the capacity, node attributes, path, devlinks
 // and state will be updated. This is because, these are the fields relevant even if
-// the device is in use.
+// the device is in use. A decrease in the capacity of a device in use is not applied,
+// it is flagged with the CapacityShrink condition instead.
 func mergeBlockDeviceData(newBD, oldBD apis.BlockDevice) *apis.BlockDevice {
+	newConditions := newBD.Status.Conditions
+	shrinkCondition, shrunk := getCapacityShrinkCondition(newBD, oldBD)
+	if shrinkCondition != nil {
+		newConditions = append(newConditions, *shrinkCondition)
+	}
+	// the conditions are merged irrespective of the claim state, as they report the
+	// health of the device
+	conditions := mergeConditions(newConditions, oldBD.Status.Conditions)
 	oldBD.TypeMeta = newBD.TypeMeta
 	oldBD.ObjectMeta = mergeMetadata(newBD.ObjectMeta, oldBD.ObjectMeta)
+	// the quarantine reason is no longer relevant once the device is out of quarantine
+	if newBD.Status.State != apis.BlockDeviceQuarantined {
+		delete(oldBD.Annotations, QuarantineReasonAnnotation)
+	}
+	// the partitions are no longer relevant once the disk is not shown as a partitioned
+	// parent, eg: if the partitions were removed
+	if _, ok := newBD.Annotations[HasPartitionsAnnotation]; !ok {
+		delete(oldBD.Annotations, HasPartitionsAnnotation)
+	}
 	// if the device is in use, only the below fields will be updated.
 	if oldBD.Status.ClaimState != apis.BlockDeviceUnclaimed {
 		klog.V(4).Infof("device: %s is in use, updating only relevant fields", newBD.Spec.Path)
 		oldBD.Spec.NodeAttributes = newBD.Spec.NodeAttributes
-		oldBD.Spec.Capacity.Storage = newBD.Spec.Capacity.Storage
+		if !shrunk {
+			oldBD.Spec.Capacity.Storage = newBD.Spec.Capacity.Storage
+		}
 		oldBD.Spec.Path = newBD.Spec.Path
 		oldBD.Spec.DevLinks = newBD.Spec.DevLinks
+		// the queue settings are tuned on the node while the device is in use
+		oldBD.Spec.Details.IOScheduler = newBD.Spec.Details.IOScheduler
+		oldBD.Spec.Details.QueueDepth = newBD.Spec.Details.QueueDepth
+		oldBD.Spec.Details.WriteCache = newBD.Spec.Details.WriteCache
 		oldBD.Status.State = newBD.Status.State
+		oldBD.Status.Parent = newBD.Status.Parent
+		oldBD.Status.Children = newBD.Status.Children
 	} else {
 		oldBD.Spec = newBD.Spec
 		oldBD.Status = newBD.Status
 	}
+	oldBD.Status.Conditions = conditions
 	return &oldBD
 }
 
@@ -333,6 +451,13 @@ func mergeMetadata(newMetadata, oldMetadata metav1.ObjectMeta) metav1.ObjectMeta
 	for key, value := range newMetadata.Labels {
 		oldMetadata.Labels[key] = value
 	}
+	// the labels derived from the filesystem are removed if the filesystem is no longer
+	// present on the device
+	for _, key := range existingFSLabels {
+		if _, ok := newMetadata.Labels[key]; !ok {
+			delete(oldMetadata.Labels, key)
+		}
+	}
 
 	// Patch older annotations with new annotations. If there is a new key then it will be added
 	// if it is an existing key then value will be overwritten with value from new annotations
@@ -179,6 +179,36 @@ func TestUpdateDevice(t *testing.T) {
 	}
 }
 
+func TestUpdateBlockDeviceIfChanged(t *testing.T) {
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+	}
+
+	devR := mockEmptyDeviceCr()
+	devR.ObjectMeta.Labels[KubernetesHostNameLabel] = fakeHostName
+	devR.Spec.Path = "/dev/sdb"
+	assert.NoError(t, fakeController.CreateBlockDevice(devR))
+	existingBD, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+
+	// the same details are merged into the resource, which is not written
+	updated, err := fakeController.UpdateBlockDeviceIfChanged(devR, existingBD)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	unchangedBD, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+	assert.Equal(t, existingBD.ResourceVersion, unchangedBD.ResourceVersion)
+
+	devR.Spec.Path = "/dev/sdc"
+	updated, err = fakeController.UpdateBlockDeviceIfChanged(devR, existingBD)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	updatedBD, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/sdc", updatedBD.Spec.Path)
+}
+
 func TestDeactivateDevice(t *testing.T) {
 	fakeNdmClient := CreateFakeClient(t)
 	nodeAttributes := make(map[string]string, 0)
@@ -194,7 +224,7 @@ func TestDeactivateDevice(t *testing.T) {
 	dr.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
 	fakeController.CreateBlockDevice(dr)
 	cdr1, err1 := fakeController.GetBlockDevice(fakeDeviceUID)
-	fakeController.DeactivateBlockDevice(*cdr1)
+	fakeController.DeactivateBlockDevice(*cdr1, AuditCause{})
 
 	// Retrieve blockdevice resource
 	cdr1, err1 = fakeController.GetBlockDevice(fakeDeviceUID)
@@ -203,7 +233,7 @@ func TestDeactivateDevice(t *testing.T) {
 	dr1 := newFakeDevice
 	dr1.ObjectMeta.Labels[KubernetesHostNameLabel] = fakeController.NodeAttributes[HostNameKey]
 	dr1.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
-	fakeController.DeactivateBlockDevice(dr1)
+	fakeController.DeactivateBlockDevice(dr1, AuditCause{})
 
 	// Create another resource and deactivate it.
 	fakeResource := newFakeDevice
@@ -211,7 +241,7 @@ func TestDeactivateDevice(t *testing.T) {
 	fakeResource.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
 	fakeController.CreateBlockDevice(fakeResource)
 	newDr, err2 := fakeController.GetBlockDevice(fakeResource.Name)
-	fakeController.DeactivateBlockDevice(*newDr)
+	fakeController.DeactivateBlockDevice(*newDr, AuditCause{})
 
 	// Retrieve blockdevice resource
 	cdr2, err2 := fakeController.GetBlockDevice(newFakeDeviceUID)
@@ -248,20 +278,20 @@ func TestDeleteDevice(t *testing.T) {
 	dr.ObjectMeta.Labels[KubernetesHostNameLabel] = fakeController.NodeAttributes[HostNameKey]
 	dr.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
 	fakeController.CreateBlockDevice(dr)
-	fakeController.DeleteBlockDevice(fakeDeviceUID)
+	fakeController.DeleteBlockDevice(fakeDeviceUID, AuditCause{})
 
 	// Retrieve blockdevice resource
 	cdr1, err1 := fakeController.GetBlockDevice(fakeDeviceUID)
 
 	// Delete one resource which is not present it should return error
-	fakeController.DeleteBlockDevice("another-uuid")
+	fakeController.DeleteBlockDevice("another-uuid", AuditCause{})
 
 	// Create another resource and delete it
 	newDr := newFakeDevice
 	newDr.ObjectMeta.Labels[KubernetesHostNameLabel] = fakeController.NodeAttributes[HostNameKey]
 	newDr.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
 	fakeController.CreateBlockDevice(newDr)
-	fakeController.DeleteBlockDevice(newFakeDeviceUID)
+	fakeController.DeleteBlockDevice(newFakeDeviceUID, AuditCause{})
 
 	// Retrieve disk resource
 	cdr2, err2 := fakeController.GetBlockDevice(fakeDeviceUID)
@@ -0,0 +1,55 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// IsCapacityInRange checks if the capacity is within the capacity range in the config.
+// If not, the reason is returned. Bounds that cannot be parsed are ignored.
+func (c *Controller) IsCapacityInRange(capacity uint64) (bool, string) {
+	if c.NDMConfig == nil || c.NDMConfig.CapacityRangeConfig == nil {
+		return true, ""
+	}
+	capacityRange := c.NDMConfig.CapacityRangeConfig
+
+	if min, ok := parseCapacityBound("min", capacityRange.Min); ok && capacity < min {
+		return false, fmt.Sprintf("capacity %d is less than the minimum capacity %s", capacity, capacityRange.Min)
+	}
+	if max, ok := parseCapacityBound("max", capacityRange.Max); ok && capacity > max {
+		return false, fmt.Sprintf("capacity %d is more than the maximum capacity %s", capacity, capacityRange.Max)
+	}
+	return true, ""
+}
+
+// parseCapacityBound parses the bound of the capacity range to bytes. false is returned
+// if the bound is not set or is invalid.
+func parseCapacityBound(name, bound string) (uint64, bool) {
+	if bound == "" {
+		return 0, false
+	}
+	quantity, err := resource.ParseQuantity(bound)
+	if err != nil || quantity.Sign() < 0 {
+		klog.Errorf("ignoring invalid %s capacity: %q in capacity range config", name, bound)
+		return 0, false
+	}
+	return uint64(quantity.Value()), true
+}
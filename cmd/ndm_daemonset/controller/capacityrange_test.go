@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCapacityInRange(t *testing.T) {
+	const (
+		gb = 1000 * 1000 * 1000
+		tb = 1000 * gb
+	)
+	tests := map[string]struct {
+		capacityRange *CapacityRangeConfig
+		capacity      uint64
+		want          bool
+	}{
+		"no capacity range": {
+			capacityRange: nil,
+			capacity:      100 * gb,
+			want:          true,
+		},
+		"capacity below the minimum": {
+			capacityRange: &CapacityRangeConfig{Min: "500G", Max: "2T"},
+			capacity:      500*gb - 1,
+			want:          false,
+		},
+		"capacity equal to the minimum": {
+			capacityRange: &CapacityRangeConfig{Min: "500G", Max: "2T"},
+			capacity:      500 * gb,
+			want:          true,
+		},
+		"capacity equal to the maximum": {
+			capacityRange: &CapacityRangeConfig{Min: "500G", Max: "2T"},
+			capacity:      2 * tb,
+			want:          true,
+		},
+		"capacity above the maximum": {
+			capacityRange: &CapacityRangeConfig{Min: "500G", Max: "2T"},
+			capacity:      2*tb + 1,
+			want:          false,
+		},
+		"binary suffix": {
+			capacityRange: &CapacityRangeConfig{Max: "2Ti"},
+			capacity:      2 * 1024 * 1024 * 1024 * 1024,
+			want:          true,
+		},
+		"only the minimum": {
+			capacityRange: &CapacityRangeConfig{Min: "500G"},
+			capacity:      20 * tb,
+			want:          true,
+		},
+		"only the maximum": {
+			capacityRange: &CapacityRangeConfig{Max: "2T"},
+			capacity:      1,
+			want:          true,
+		},
+		"invalid bound is ignored": {
+			capacityRange: &CapacityRangeConfig{Min: "500 gigs", Max: "2T"},
+			capacity:      100 * gb,
+			want:          true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{CapacityRangeConfig: tt.capacityRange},
+			}
+			got, reason := c.IsCapacityInRange(tt.capacity)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want, reason == "", "reason: %q", reason)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// capacityShrinkReason is the reason of the CapacityShrink condition when the capacity
+	// of a claimed device has decreased
+	capacityShrinkReason = "CapacityShrink"
+	// capacityNormalReason is the reason of the CapacityShrink condition when the capacity
+	// of the device is no longer below the recorded capacity
+	capacityNormalReason = "CapacityNormal"
+)
+
+// getCapacityShrinkCondition gets the CapacityShrink condition of the device by comparing the
+// newly observed capacity with the capacity recorded on the resource. A claimed device whose
+// capacity has decreased, eg: due to a SAN misconfiguration or an exhausted thin pool, may
+// lose the data of its consumer, so the decrease is flagged and true is returned, so that the
+// recorded capacity is retained. Growth is applied as usual. The condition is nil if it is
+// not relevant, ie: the device has not shrunk and was never flagged.
+func getCapacityShrinkCondition(newBD, oldBD apis.BlockDevice) (*apis.BlockDeviceCondition, bool) {
+	newCapacity := newBD.Spec.Capacity.Storage
+	oldCapacity := oldBD.Spec.Capacity.Storage
+	if oldBD.Status.ClaimState != apis.BlockDeviceUnclaimed && newCapacity < oldCapacity {
+		klog.Warningf("eventcode=%s msg=%s rname=%v recorded=%d observed=%d",
+			"ndm.blockdevice.capacity.shrink", "Capacity of claimed blockdevice decreased",
+			oldBD.Name, oldCapacity, newCapacity)
+		return &apis.BlockDeviceCondition{
+			Type:               apis.BlockDeviceCapacityShrink,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             capacityShrinkReason,
+			Message: fmt.Sprintf("capacity decreased from %d to %d bytes while the device is claimed",
+				oldCapacity, newCapacity),
+		}, true
+	}
+
+	// the condition is cleared only on the devices that were flagged earlier
+	if _, ok := getCondition(oldBD.Status.Conditions, apis.BlockDeviceCapacityShrink); !ok {
+		return nil, false
+	}
+	return &apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceCapacityShrink,
+		Status:             v1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             capacityNormalReason,
+		Message:            fmt.Sprintf("capacity %d bytes is not below the recorded capacity", newCapacity),
+	}, false
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCapacityShrinkOfClaimedBlockDevice(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+		Recorder:       recorder,
+	}
+
+	newBlockDevice := func(capacity uint64) apis.BlockDevice {
+		di := NewDeviceInfo()
+		di.UUID = fakeDeviceUID
+		di.NodeAttributes = fakeController.NodeAttributes
+		di.Capacity = capacity
+		bd, err := di.ToDevice(fakeController)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bd
+	}
+
+	// the device is created and claimed with a capacity of 10GiB
+	if err := fakeController.CreateBlockDevice(newBlockDevice(10 << 30)); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed.Status.ClaimState = apis.BlockDeviceClaimed
+	if err := fakeController.Clientset.Update(context.TODO(), claimed); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name         string
+		capacity     uint64
+		wantCapacity uint64
+		// wantStatus is empty if the condition should not be present
+		wantStatus v1.ConditionStatus
+		wantEvent  string
+	}{
+		{
+			name:         "grow is applied",
+			capacity:     20 << 30,
+			wantCapacity: 20 << 30,
+		},
+		{
+			name:         "shrink is flagged",
+			capacity:     5 << 30,
+			wantCapacity: 20 << 30,
+			wantStatus:   v1.ConditionTrue,
+			wantEvent:    "Warning CapacityShrink",
+		},
+		{
+			name:         "capacity restored",
+			capacity:     20 << 30,
+			wantCapacity: 20 << 30,
+			wantStatus:   v1.ConditionFalse,
+			wantEvent:    "Normal CapacityNormal",
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := fakeController.CreateBlockDevice(newBlockDevice(step.capacity)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := fakeController.GetBlockDevice(fakeDeviceUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, step.wantCapacity, got.Spec.Capacity.Storage)
+			condition, ok := getCondition(got.Status.Conditions, apis.BlockDeviceCapacityShrink)
+			assert.Equal(t, step.wantStatus != "", ok)
+			assert.Equal(t, step.wantStatus, condition.Status)
+
+			select {
+			case event := <-recorder.Events:
+				assert.Contains(t, event, step.wantEvent)
+				assert.NotEmpty(t, step.wantEvent, "unexpected event %q", event)
+			default:
+				assert.Empty(t, step.wantEvent, "no event")
+			}
+		})
+	}
+}
+
+func TestGetCapacityShrinkCondition(t *testing.T) {
+	tests := map[string]struct {
+		claimState  apis.DeviceClaimState
+		oldCapacity uint64
+		newCapacity uint64
+		flagged     bool
+		wantShrunk  bool
+		wantNil     bool
+		wantStatus  v1.ConditionStatus
+	}{
+		"unclaimed device shrinks": {
+			claimState:  apis.BlockDeviceUnclaimed,
+			oldCapacity: 100,
+			newCapacity: 50,
+			wantNil:     true,
+		},
+		"claimed device grows": {
+			claimState:  apis.BlockDeviceClaimed,
+			oldCapacity: 100,
+			newCapacity: 200,
+			wantNil:     true,
+		},
+		"claimed device shrinks": {
+			claimState:  apis.BlockDeviceClaimed,
+			oldCapacity: 100,
+			newCapacity: 50,
+			wantShrunk:  true,
+			wantStatus:  v1.ConditionTrue,
+		},
+		"flagged device released": {
+			claimState:  apis.BlockDeviceUnclaimed,
+			oldCapacity: 100,
+			newCapacity: 50,
+			flagged:     true,
+			wantStatus:  v1.ConditionFalse,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldBD := apis.BlockDevice{}
+			oldBD.Status.ClaimState = test.claimState
+			oldBD.Spec.Capacity.Storage = test.oldCapacity
+			if test.flagged {
+				oldBD.Status.Conditions = []apis.BlockDeviceCondition{
+					{Type: apis.BlockDeviceCapacityShrink, Status: v1.ConditionTrue},
+				}
+			}
+			newBD := apis.BlockDevice{}
+			newBD.Spec.Capacity.Storage = test.newCapacity
+
+			condition, shrunk := getCapacityShrinkCondition(newBD, oldBD)
+			assert.Equal(t, test.wantShrunk, shrunk)
+			if test.wantNil {
+				assert.Nil(t, condition)
+				return
+			}
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, test.wantStatus, condition.Status)
+			}
+		})
+	}
+}
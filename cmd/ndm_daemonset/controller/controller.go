@@ -20,13 +20,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -35,7 +41,9 @@ import (
 
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
-	"github.com/openebs/node-disk-manager/pkg/util"
+	"github.com/openebs/node-disk-manager/pkg/metrics/daemon"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/surfacescan"
 )
 
 const (
@@ -63,6 +71,27 @@ const (
 	reconcileKey = "reconcile"
 	// OpenEBSReconcile is used in annotation to check whether CR is to be reconciled or not
 	OpenEBSReconcile = openEBSLabelPrefix + reconcileKey
+	// QuarantineReasonAnnotation is the annotation having the reason for which the
+	// blockdevice was quarantined
+	QuarantineReasonAnnotation = openEBSLabelPrefix + "quarantine-reason"
+	// PotentialSwapAnnotation is the annotation having the reason for which the unused
+	// device at the path of the blockdevice is suspected to be a different disk
+	PotentialSwapAnnotation = openEBSLabelPrefix + "potential-swap"
+	// WipedAnnotation is the annotation having the signature that was wiped from the
+	// device, when a device with a stable identifier is found blank
+	WipedAnnotation = openEBSLabelPrefix + "wiped"
+	// FirstSeenAnnotation is the annotation having the time at which NDM first found the
+	// device, in RFC 3339 format. It is never changed once set.
+	FirstSeenAnnotation = openEBSLabelPrefix + "first-seen"
+	// LastSeenAnnotation is the annotation having the time at which NDM last processed an
+	// event of the device, in RFC 3339 format. It is refreshed at most once an hour.
+	LastSeenAnnotation = openEBSLabelPrefix + "last-seen"
+	// DefaultUUIDBasisAnnotation is the default annotation having the identifier of the
+	// device that was used for generating the UUID
+	DefaultUUIDBasisAnnotation = "internal.openebs.io/uuid-basis"
+	// DecisionTraceAnnotation is the annotation having the branches taken by NDM while
+	// processing the device
+	DecisionTraceAnnotation = NDMLabelPrefix + "decision-trace"
 	// NDMNotPartitioned is used to say blockdevice does not have any partition.
 	NDMNotPartitioned = "No"
 	// NDMPartitioned is used to say blockdevice has some partitions.
@@ -85,6 +114,26 @@ const (
 	NDMLabelPrefix = "ndm.io/"
 	// NDMZpoolName specifies the zpool name
 	NDMZpoolName = NDMLabelPrefix + "zpool-name"
+	// NDMTransportKey specifies the fabric transport of the device
+	NDMTransportKey = NDMLabelPrefix + "transport"
+	// NDMNVMeControllerKey specifies the serial number of the NVMe controller of the
+	// namespace, so that namespaces sharing a physical device can be identified
+	NDMNVMeControllerKey = NDMLabelPrefix + "nvme-controller"
+	// NDMSectorModelKey specifies the sector model (512n/512e/4Kn) of the device
+	NDMSectorModelKey = NDMLabelPrefix + "sector-model"
+	// NDMDiscardKey is set on the devices that support discard (TRIM / UNMAP)
+	NDMDiscardKey = NDMLabelPrefix + "discard"
+	// NDMNUMANodeKey specifies the NUMA node of the controller of the device
+	NDMNUMANodeKey = NDMLabelPrefix + "numa-node"
+	// NDMWriteCacheKey specifies whether the volatile write cache of the device is
+	// enabled or disabled
+	NDMWriteCacheKey = NDMLabelPrefix + "write-cache"
+	// NDMDegradedLinkKey is set on the devices whose PCIe link negotiated a lower
+	// speed or width than its maximum, to the observed and the maximum speed / width
+	NDMDegradedLinkKey = NDMLabelPrefix + "degraded-link"
+	// NDMGlusterVolumeIDKey is the label having the id of the glusterfs volume for devices
+	// used as a glusterfs brick
+	NDMGlusterVolumeIDKey = NDMLabelPrefix + "gluster-volume-id"
 )
 
 const (
@@ -97,6 +146,29 @@ const (
 const (
 	// CRDRetryInterval is used if CRD is not present.
 	CRDRetryInterval = 10 * time.Second
+
+	// DefaultProbeTimeout is the default time after which a probe is abandoned for a device
+	DefaultProbeTimeout = 1 * time.Minute
+
+	// DefaultEventCoalesceWindow is the default time for which the udev events of the
+	// devices of a disk are buffered, before they are processed together
+	DefaultEventCoalesceWindow = 250 * time.Millisecond
+
+	// DefaultDeviceReadyTimeout is the default time after which a device that is not ready
+	// to accept IO is reported
+	DefaultDeviceReadyTimeout = 30 * time.Second
+
+	// DefaultIOErrorWindow is the default duration over which the IO errors of a device
+	// are counted against the IO error threshold
+	DefaultIOErrorWindow = 10 * time.Minute
+
+	// DefaultSurfaceScanRate is the default maximum rate in bytes per second at which a
+	// device is read during a surface scan
+	DefaultSurfaceScanRate = 4 * 1024 * 1024
+
+	// DefaultSMARTRefreshConcurrency is the default number of disks whose SMART data is
+	// refreshed at the same time
+	DefaultSMARTRefreshConcurrency = 2
 )
 
 // ControllerBroadcastChannel is used to send a copy of controller object to each probe.
@@ -108,6 +180,123 @@ type NDMOptions struct {
 	ConfigFilePath string
 	// holds the slice of feature gates.
 	FeatureGate []string
+	// SafeMode blocks all destructive operations on the disks. It is enabled by default,
+	// operators have to opt in to the destructive operations.
+	SafeMode bool
+	// RemovableDevicePolicy is the policy for handling removable media (ignore/manage)
+	RemovableDevicePolicy string
+	// RemovableDeviceDelay is the time for which a managed removable disk has to be present
+	RemovableDeviceDelay time.Duration
+	// LoopDevicePolicy is the policy for handling loop devices (ignore/manage)
+	LoopDevicePolicy string
+	// PartitionReclaimPolicy is the policy for partitions created by NDM (retain/reclaim)
+	PartitionReclaimPolicy string
+	// PartitionedParentPolicy is the policy for disks having partitions (ignore/show)
+	PartitionedParentPolicy string
+	// NodeNameSource is the source of the node name (env/hostname/config)
+	NodeNameSource string
+	// NodeName is the node name to be used when the node name source is config
+	NodeName string
+	// PartitionFabricDevices allows partitioning of devices attached over fabric
+	// transports like NVMe-oF and iSER
+	PartitionFabricDevices bool
+	// SCSIReservationKeys are the persistent reservation keys, in hex, registered by this
+	// host on the shared disks
+	SCSIReservationKeys []string
+	// ProbeTimeout is the default time after which a probe is abandoned for a device
+	ProbeTimeout time.Duration
+	// MetricsAddress is the address(ip:port) at which the metrics of the daemon are
+	// exposed. Metrics are disabled if empty.
+	MetricsAddress string
+	// DecisionAddress is the loopback address(ip:port) at which the decisions that NDM
+	// would make on the devices are served. The endpoint is disabled if empty.
+	DecisionAddress string
+	// InternalErrorPolicy is the policy for handling internal errors (report/propagate)
+	InternalErrorPolicy string
+	// RemovalPolicy is the policy for the resources of removed devices
+	// (deactivate/delete)
+	RemovalPolicy string
+	// ProtectionLevel is the protection of claimed devices from destructive
+	// operations (normal/strict)
+	ProtectionLevel string
+	// ReidentifyDevices allows migrating the resource of a device to the uuid generated
+	// from a better identifier, once it becomes available
+	ReidentifyDevices bool
+	// ProvisioningMarkers are the files that mark the completion of provisioning of
+	// the node by tools like cloud-init / ignition
+	ProvisioningMarkers []string
+	// ProvisioningTimeout is the maximum time to wait for the provisioning markers
+	ProvisioningTimeout time.Duration
+	// EventCoalesceWindow is the time for which the udev events of the devices of a
+	// disk are buffered before they are processed together
+	EventCoalesceWindow time.Duration
+	// PartitionName is the template of the name of the GPT partition created by NDM
+	PartitionName string
+	// ShutdownTimeout is the time for which the shutdown waits for the events being
+	// processed to complete
+	ShutdownTimeout time.Duration
+	// DiscoverOnly runs NDM only for the inventory of devices, without writing to them
+	DiscoverOnly bool
+	// DeviceReadyTimeout is the time after which a device not ready to accept IO is reported
+	DeviceReadyTimeout time.Duration
+	// ContentFingerprintKiB is the size in KiB of the regions near the start and the end
+	// of an unclaimed, unused device that are hashed to detect disk swaps. Disabled if 0.
+	ContentFingerprintKiB int
+	// InstanceID is the ID of this NDM instance, stamped on the blockdevices it manages
+	InstanceID string
+	// DiscardBeforePartition discards all the blocks of blank disks supporting discard
+	// before partitioning them
+	DiscardBeforePartition bool
+	// DisableWriteCache disables the volatile write cache of blank disks before
+	// partitioning them
+	DisableWriteCache bool
+	// AuditSink is the sink of the audit log of destructive operations (stdout/file/events)
+	AuditSink string
+	// AuditLogFile is the file to which the audit log is appended, with the file sink
+	AuditLogFile string
+	// AuditKeyFile is the file having the key with which the audit records are hashed
+	AuditKeyFile string
+	// UUIDVersion is the version of the uuid algorithm used for new devices (v1/v2/v3)
+	UUIDVersion string
+	// UUIDNamespace is the namespace of the v5 uuids, required by uuid version v5
+	UUIDNamespace string
+	// UUIDBasisAnnotation is the annotation having the identifier the uuid was generated from
+	UUIDBasisAnnotation string
+	// UdevAnnotations annotates the blockdevices with a curated set of their udev properties
+	UdevAnnotations bool
+	// DecisionTrace annotates the blockdevices with the branches taken while processing them
+	DecisionTrace bool
+	// InventoryEventSink is the URL of the sink to which the inventory events are published
+	InventoryEventSink string
+	// MultiSignaturePolicy is the policy for devices having multiple signatures
+	// (quarantine/prefer-recent/require-wipe)
+	MultiSignaturePolicy string
+	// RescanCheckpointFile is the file in which the progress of full rescans is recorded
+	RescanCheckpointFile string
+	// RescanCheckpointTTL is the time for which the details of the devices recorded in
+	// the rescan checkpoint are reused
+	RescanCheckpointTTL time.Duration
+	// IOErrorThreshold is the number of IO errors within the IO error window above which
+	// a device is in the error state. 0 disables the tracking
+	IOErrorThreshold uint64
+	// IOErrorWindow is the duration over which the IO errors of a device are counted
+	IOErrorWindow time.Duration
+	// SurfaceScanInterval is the interval at which the unclaimed disks are surface
+	// scanned for unreadable sectors. 0 disables the scan
+	SurfaceScanInterval time.Duration
+	// SurfaceScanRate is the maximum rate in bytes per second at which a disk is read
+	// during a surface scan
+	SurfaceScanRate int64
+	// WritabilityCheck enables the writability check of the blank disks
+	WritabilityCheck bool
+	// VerifyPartitionBlank verifies that the partitions created by NDM have no signatures
+	VerifyPartitionBlank bool
+	// SMARTRefreshInterval is the interval at which the SMART data of the disks is
+	// refreshed. 0 disables the refresh
+	SMARTRefreshInterval time.Duration
+	// SMARTRefreshConcurrency is the maximum number of disks whose SMART data is
+	// refreshed at the same time
+	SMARTRefreshConcurrency int
 }
 
 // Controller is the controller implementation for disk resources
@@ -125,12 +314,213 @@ type Controller struct {
 	// The attributes can be hostname, nodename, zone, failure-domain etc
 	NodeAttributes map[string]string
 	// BDHierarchy stores the hierarchy of devices on this node
-	BDHierarchy blockdevice.Hierarchy
+	BDHierarchy *blockdevice.HierarchyCache
+	// SafeMode, when enabled, makes NDM run in an observe-only posture. BlockDevice
+	// resources are still created and updated, but partitioning, wiping and deactivation
+	// of devices / resources are not performed. The --safe-mode flag enables it by default.
+	SafeMode bool
+	// RemovableDevicePolicy decides whether removable media like USB sticks are
+	// ignored or managed by NDM
+	RemovableDevicePolicy RemovableDevicePolicy
+	// RemovableDeviceDelay is the time for which a removable disk has to be present before
+	// it and its partitions are managed, with the manage policy. A disk removed before
+	// the delay is never managed, so that media plugged in and out do not churn the
+	// BlockDevice resources. Zero manages them at once.
+	RemovableDeviceDelay time.Duration
+	// LoopDevicePolicy decides whether loop devices, which are mostly backed by image
+	// files on developer and CI nodes, are ignored or managed by NDM
+	LoopDevicePolicy LoopDevicePolicy
+	// PartitionReclaimPolicy decides whether the partitions created by NDM, that are
+	// flagged for reclaim, are removed once they are no longer in use
+	PartitionReclaimPolicy PartitionReclaimPolicy
+	// PartitionedParentPolicy decides whether a disk having partitions, but no resource
+	// of its own, gets an inactive resource so that it is visible in the inventory
+	PartitionedParentPolicy PartitionedParentPolicy
+	// PartitionFabricDevices decides whether devices attached over fabric transports
+	// (NVMe-oF, iSER) can be partitioned by NDM. These devices are exported by remote
+	// targets and are not partitioned by default.
+	PartitionFabricDevices bool
+	// SCSIReservationKeys are the persistent reservation keys registered by this host,
+	// eg: by multipathd or a cluster manager. A disk reserved with one of these keys is
+	// held by this host, and is not treated as reserved by a peer node.
+	SCSIReservationKeys []uint64
+	// ProbeTimeout is the time after which a probe filling the details of a device is
+	// abandoned, so that a hung probe does not stall the processing of events. It can be
+	// overridden per probe in the probe config. Zero disables the timeout.
+	ProbeTimeout time.Duration
+	// EventCoalesceWindow is the time for which the udev events of a disk and its
+	// partitions are buffered, so that the burst of events generated by a partition table
+	// re-read is processed at once, with a single list of the BlockDevice resources.
+	// Zero disables the buffering.
+	EventCoalesceWindow time.Duration
+	// PartitionName is the template of the name of the GPT partition created by NDM on
+	// blank disks. The partitions created by NDM are recognized by its static prefix.
+	PartitionName string
+	// Metrics are the metrics exposed by the daemon, like the metrics of the hierarchy
+	// cache. It is nil if metrics are disabled.
+	Metrics *daemon.Metrics
+	// Recorder is used to record events on the node
+	Recorder record.EventRecorder
+	// InternalErrorPolicy decides whether non-fatal internal errors while processing a
+	// device are reported as metrics and events, or propagated like other errors
+	InternalErrorPolicy InternalErrorPolicy
+	// RemovalPolicy decides whether the BlockDevice resource of a device removed from
+	// the node is deactivated or deleted. Claimed resources are never deleted.
+	RemovalPolicy RemovalPolicy
+	// ProtectionLevel decides which destructive operations are performed on claimed
+	// BlockDevices, or on BlockDevices having an outstanding claim. In the normal level,
+	// they are never written to, or deactivated because of a change in their usage. In
+	// the strict level, they are never touched, not even when the device is removed.
+	ProtectionLevel ProtectionLevel
+	// claims is the index of the outstanding claims of the BlockDevices, used to find
+	// the protected BlockDevices without listing the claims on every operation
+	claims claimIndex
+	// targets is the index of the BlockDevices in use at the device paths, used to find
+	// the BlockDevice of a disk without listing the BlockDevices on every operation
+	targets targetIndex
+	// localBlockPVs is the index of the raw block local PVs on this node, used to find
+	// the devices used by them without listing the PVs for every device
+	localBlockPVs localBlockPVIndex
+	// scsiReservations is the index of the persistent reservations of the disks, so that
+	// the reservation of a disk is read at most once per batch of events
+	scsiReservations scsiReservationIndex
+	// ReidentifyDevices, when enabled, migrates the unclaimed resource of a device to
+	// a new uuid, if the uuid of the resource was generated from an inferior identifier,
+	// eg: the serial, and a better identifier like the WWN can now be read. The old uuid
+	// is recorded in an annotation on the new resource. Claimed resources are never
+	// re-identified.
+	ReidentifyDevices bool
+	// Evaluation, if set, records the operations that would have been performed by
+	// NDM instead of performing them. See StartEvaluation.
+	Evaluation *Evaluation
+	// ShutdownTimeout is the time for which the shutdown of the daemon waits for the
+	// events being processed to complete. Zero does not wait.
+	ShutdownTimeout time.Duration
+	// DeviceReadyTimeout is the time after which a freshly attached device that is not
+	// ready to accept IO is reported on the node. A device that is not ready is not probed,
+	// so that it is not misclassified as blank and partitioned. It is deferred, and is
+	// processed once it is ready, without blocking the other events. Zero disables the
+	// readiness check.
+	DeviceReadyTimeout time.Duration
+	// DiscoverOnly, when enabled, makes NDM only discover the devices and maintain their
+	// BlockDevice resources. Unlike SafeMode, it is a supported long running posture:
+	// blank disks that cannot be uniquely identified get a resource with an identifier
+	// independent of the partition table, instead of being partitioned.
+	DiscoverOnly bool
+	// ContentFingerprintKiB, when not zero, is the size in KiB of the regions near the start
+	// and the end of an unclaimed, unused device that are hashed and stored on the
+	// BlockDevice resource. If the fingerprint of the device at the same path changes at
+	// both the ends, the disk may have been swapped, which is flagged on the resource.
+	// Since it reads the devices, it is disabled by default.
+	ContentFingerprintKiB int
+	// InstanceID, when set, is the ID of this NDM instance used to coexist with other NDM
+	// instances on the same nodes, eg: during a migration. The blockdevices managed by this
+	// instance are annotated with the ID, and the devices whose resource is annotated with
+	// the ID of another instance are left alone. Unowned devices are managed by any instance.
+	InstanceID string
+	// DiscardBeforePartition, when enabled, discards (TRIM / UNMAP) all the blocks of a
+	// blank disk that supports discard, before NDM partitions it, so that SSDs start
+	// afresh. A failed discard is logged and the disk is partitioned anyway.
+	DiscardBeforePartition bool
+	// DisableWriteCache, when enabled, disables the volatile write cache of a blank disk
+	// before NDM partitions it, for deployments in which the data must not be lost on a
+	// power failure. It is off by default as it affects the write performance. A failure
+	// is logged and the disk is partitioned anyway.
+	DisableWriteCache bool
+	// AuditLog records every destructive operation performed by NDM, like the creation of
+	// partitions, wiping and deletion of resources, with the cause of the operation. Unlike
+	// the logs, it is always on. Its records are chained, and hashed with the audit key if
+	// configured, so that a modified or removed record is detected.
+	AuditLog *AuditLog
+	// UUIDVersion is the version of the algorithm used to hash the identifier of a newly
+	// found device into the uuid of its resource. The version is recorded on the resource,
+	// and existing resources keep the uuid generated by their version, so that changing
+	// the version only affects the devices that do not have a resource yet.
+	UUIDVersion UUIDVersion
+	// UUIDNamespace is the namespace in which the v5 uuids of the devices are generated.
+	// uuid.Nil if no namespace is configured. When set, resources having a v5 uuid in this
+	// namespace are found irrespective of the configured version, so the namespace should
+	// not be changed once devices have v5 uuids.
+	UUIDNamespace uuid.UUID
+	// UUIDBasisAnnotation is the key of the annotation having the identifier of the device,
+	// like the WWN or the partition table UUID, from which its uuid was generated. The
+	// resources are also looked up with DefaultUUIDBasisAnnotation, so that the basis of
+	// the resources annotated before the key was changed is still known.
+	UUIDBasisAnnotation string
+	// UdevAnnotations, when enabled, annotates the blockdevices with a whitelisted set of
+	// the udev properties of the device, like ID_BUS and ID_SERIAL, eg: ndm.io/udev-id-bus.
+	// The properties are the identification details from which the uuid is generated, so
+	// that they can be audited and used for advanced selection.
+	UdevAnnotations bool
+	// DecisionTrace, when enabled, records the sequence of branches taken while processing
+	// the add event of a device, like the unmanaged device checks, the basis of the uuid and
+	// the final action. The trace is logged, and added as the ndm.io/decision-trace
+	// annotation on the resource of the device, so that it can be found later why NDM did
+	// what it did with the device. It is opt-in as it increases the size of the resources.
+	DecisionTrace bool
+	// MultiSignaturePolicy decides how the devices having multiple filesystem / storage
+	// engine signatures, which make the usage of the device ambiguous, are handled. Such
+	// devices are never partitioned. They are quarantined by default.
+	MultiSignaturePolicy MultiSignaturePolicy
+	// InventoryPublisher, if set, publishes the lifecycle events of the BlockDevice
+	// resources of the node, like creation, deactivation and claim state transitions, to
+	// a sink for event driven integrations. The events are buffered and published outside
+	// the processing of the devices. If the sink cannot keep up, the events are dropped
+	// and counted, instead of stalling the processing.
+	InventoryPublisher *InventoryPublisher
+	// RescanCheckpoint, if set, records the progress of the full rescans of the node, so
+	// that a rescan interrupted by a crash or restart of the daemon is resumed without
+	// probing again the devices it had already processed. It helps on nodes with hundreds
+	// of disks, where probing all of them again on every restart is expensive.
+	RescanCheckpoint *RescanCheckpoint
+	// IOErrors, if set, tracks the IO errors reported by the SCSI devices. A device with
+	// more errors than the threshold within the window is in the error state: it gets the
+	// ErrorState condition, and no destructive operation is performed on it, so that NDM
+	// does not keep hammering a failing disk.
+	IOErrors *IOErrorTracker
+	// SurfaceScanner, if set, scans the surface of the unclaimed disks that are not in
+	// use every SurfaceScanInterval, by reading a sample of their sectors at a bounded
+	// rate. A disk with unreadable sectors gets the MediaErrors condition. It complements
+	// SMART, which may report the bad sectors only long after reads have started failing.
+	SurfaceScanner *surfacescan.Scanner
+	// SurfaceScanInterval is the interval at which the disks are surface scanned
+	SurfaceScanInterval time.Duration
+	// WritabilityCheck, if set, checks that a blank disk can be written to before its
+	// resource is created, by writing to a scratch region at the very end of the disk and
+	// reading it back. The region is checked to be blank before it is written to, and is
+	// zeroed again afterwards. The disks in use or not blank are never written to. The
+	// result is the Writable condition of the blockdevice, so that consumers can know
+	// that NDM confirmed the disk is writable before claiming it.
+	WritabilityCheck bool
+	// VerifyPartitionBlank, if set, probes the partition created by NDM on a blank disk
+	// again once it is added, to verify that it has no filesystem or other signatures. A
+	// partition having a signature means the disk was wrongly found to be blank, it gets
+	// the UnexpectedSignature condition and is quarantined instead of being offered as a
+	// clean device.
+	VerifyPartitionBlank bool
+	// SMARTRefreshInterval is the interval at which the temperature of the disks is read
+	// again from SMART, to update their OverTemperature condition. Each disk is refreshed
+	// at a random offset within the interval, so that the disks of a node, and the nodes
+	// of a cluster, are not all read at the same time. 0 disables the refresh.
+	SMARTRefreshInterval time.Duration
+	// SMARTRefreshConcurrency is the maximum number of disks of the node whose SMART data
+	// is read at the same time
+	SMARTRefreshConcurrency int
+	// nodeLabelChanges receives the node labels added to the blockdevices when they
+	// change, it is nil if the node labels are not watched
+	nodeLabelChanges chan map[string]string
+	// shutdown is used to stop processing of new events on shutdown
+	shutdown shutdownState
+	// provisioningDone is closed once the provisioning of the node is complete, blank
+	// disks are partitioned only after that. It is nil if there is nothing to wait for.
+	provisioningDone chan struct{}
 }
 
 // NewController returns a controller pointer for any error case it will return nil
 func NewController() (*Controller, error) {
-	controller := &Controller{}
+	controller := &Controller{
+		BDHierarchy: blockdevice.NewHierarchyCache(nil),
+	}
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, err
@@ -153,6 +543,7 @@ func NewController() (*Controller, error) {
 	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
 		return controller, err
 	}
+	controller.Recorder = mgr.GetEventRecorderFor("node-disk-manager")
 
 	_, err = controller.newClientSet()
 	if err != nil {
@@ -169,13 +560,242 @@ func (c *Controller) SetControllerOptions(opts NDMOptions) error {
 	// set the config for running NDM daemon
 	c.SetNDMConfig(opts)
 
+	c.SafeMode = opts.SafeMode
+	if c.SafeMode {
+		klog.Info("safe mode enabled, destructive operations will be blocked")
+	}
+
+	policy, err := ParseRemovableDevicePolicy(opts.RemovableDevicePolicy)
+	if err != nil {
+		return err
+	}
+	c.RemovableDevicePolicy = policy
+
+	if opts.RemovableDeviceDelay < 0 {
+		return fmt.Errorf("invalid removable device delay: %v, should not be negative", opts.RemovableDeviceDelay)
+	}
+	c.RemovableDeviceDelay = opts.RemovableDeviceDelay
+
+	loopPolicy, err := ParseLoopDevicePolicy(opts.LoopDevicePolicy)
+	if err != nil {
+		return err
+	}
+	c.LoopDevicePolicy = loopPolicy
+
+	reclaimPolicy, err := ParsePartitionReclaimPolicy(opts.PartitionReclaimPolicy)
+	if err != nil {
+		return err
+	}
+	c.PartitionReclaimPolicy = reclaimPolicy
+
+	partitionedParentPolicy, err := ParsePartitionedParentPolicy(opts.PartitionedParentPolicy)
+	if err != nil {
+		return err
+	}
+	c.PartitionedParentPolicy = partitionedParentPolicy
+
+	c.DiscoverOnly = opts.DiscoverOnly
+	if c.DiscoverOnly {
+		if c.PartitionReclaimPolicy == ReclaimPartitions {
+			return fmt.Errorf("partition reclaim policy: %s cannot be used in discover only mode", ReclaimPartitions)
+		}
+		klog.Info("discover only mode enabled, devices will not be partitioned")
+	}
+
+	internalErrorPolicy, err := ParseInternalErrorPolicy(opts.InternalErrorPolicy)
+	if err != nil {
+		return err
+	}
+	c.InternalErrorPolicy = internalErrorPolicy
+
+	removalPolicy, err := ParseRemovalPolicy(opts.RemovalPolicy)
+	if err != nil {
+		return err
+	}
+	c.RemovalPolicy = removalPolicy
+
+	protectionLevel, err := ParseProtectionLevel(opts.ProtectionLevel)
+	if err != nil {
+		return err
+	}
+	c.ProtectionLevel = protectionLevel
+
+	c.ReidentifyDevices = opts.ReidentifyDevices
+
+	multiSignaturePolicy, err := ParseMultiSignaturePolicy(opts.MultiSignaturePolicy)
+	if err != nil {
+		return err
+	}
+	c.MultiSignaturePolicy = multiSignaturePolicy
+
+	uuidVersion, err := ParseUUIDVersion(opts.UUIDVersion)
+	if err != nil {
+		return err
+	}
+	c.UUIDVersion = uuidVersion
+
+	uuidNamespace, err := ParseUUIDNamespace(opts.UUIDNamespace, uuidVersion)
+	if err != nil {
+		return err
+	}
+	c.UUIDNamespace = uuidNamespace
+
+	if opts.UUIDBasisAnnotation == "" {
+		opts.UUIDBasisAnnotation = DefaultUUIDBasisAnnotation
+	}
+	if errs := validation.IsQualifiedName(opts.UUIDBasisAnnotation); len(errs) != 0 {
+		return fmt.Errorf("invalid uuid basis annotation: %q, %s", opts.UUIDBasisAnnotation, strings.Join(errs, ", "))
+	}
+	c.UUIDBasisAnnotation = opts.UUIDBasisAnnotation
+
+	c.UdevAnnotations = opts.UdevAnnotations
+
+	c.DecisionTrace = opts.DecisionTrace
+
+	if opts.InventoryEventSink != "" {
+		publisher, err := NewPublisher(opts.InventoryEventSink)
+		if err != nil {
+			return err
+		}
+		c.InventoryPublisher = NewInventoryPublisher(publisher, DefaultInventoryEventQueueSize)
+	}
+
+	if opts.RescanCheckpointFile != "" {
+		if opts.RescanCheckpointTTL <= 0 {
+			return fmt.Errorf("invalid rescan checkpoint ttl: %v, should be positive", opts.RescanCheckpointTTL)
+		}
+		c.RescanCheckpoint = NewRescanCheckpoint(opts.RescanCheckpointFile, opts.RescanCheckpointTTL)
+	}
+
+	if opts.IOErrorThreshold > 0 {
+		if opts.IOErrorWindow <= 0 {
+			return fmt.Errorf("invalid io error window: %v, should be positive", opts.IOErrorWindow)
+		}
+		c.IOErrors = NewIOErrorTracker(opts.IOErrorThreshold, opts.IOErrorWindow)
+	}
+
+	if opts.SurfaceScanInterval < 0 {
+		return fmt.Errorf("invalid surface scan interval: %v, should not be negative", opts.SurfaceScanInterval)
+	}
+	if opts.SurfaceScanInterval > 0 {
+		if opts.SurfaceScanRate <= 0 {
+			return fmt.Errorf("invalid surface scan rate: %d, should be positive", opts.SurfaceScanRate)
+		}
+		c.SurfaceScanner = surfacescan.NewScanner(opts.SurfaceScanRate)
+		c.SurfaceScanInterval = opts.SurfaceScanInterval
+	}
+
+	c.WritabilityCheck = opts.WritabilityCheck
+	c.VerifyPartitionBlank = opts.VerifyPartitionBlank
+
+	if opts.SMARTRefreshInterval < 0 {
+		return fmt.Errorf("invalid smart refresh interval: %v, should not be negative", opts.SMARTRefreshInterval)
+	}
+	if opts.SMARTRefreshInterval > 0 && opts.SMARTRefreshConcurrency <= 0 {
+		return fmt.Errorf("invalid smart refresh concurrency: %d, should be positive", opts.SMARTRefreshConcurrency)
+	}
+	c.SMARTRefreshInterval = opts.SMARTRefreshInterval
+	c.SMARTRefreshConcurrency = opts.SMARTRefreshConcurrency
+
+	c.DiscardBeforePartition = opts.DiscardBeforePartition
+	if c.DiscardBeforePartition && c.DiscoverOnly {
+		return fmt.Errorf("discard before partition cannot be used in discover only mode")
+	}
+
+	c.DisableWriteCache = opts.DisableWriteCache
+	if c.DisableWriteCache && c.DiscoverOnly {
+		return fmt.Errorf("disable write cache cannot be used in discover only mode")
+	}
+
+	auditSink, err := ParseAuditSink(opts.AuditSink)
+	if err != nil {
+		return err
+	}
+	c.AuditLog, err = c.newAuditLog(auditSink, opts.AuditLogFile, opts.AuditKeyFile)
+	if err != nil {
+		return err
+	}
+
+	c.PartitionFabricDevices = opts.PartitionFabricDevices
+
+	c.SCSIReservationKeys, err = ParseSCSIReservationKeys(opts.SCSIReservationKeys)
+	if err != nil {
+		return err
+	}
+
+	if opts.ProbeTimeout < 0 {
+		return fmt.Errorf("invalid probe timeout: %v, should not be negative", opts.ProbeTimeout)
+	}
+	c.ProbeTimeout = opts.ProbeTimeout
+
+	if opts.EventCoalesceWindow < 0 {
+		return fmt.Errorf("invalid event coalesce window: %v, should not be negative", opts.EventCoalesceWindow)
+	}
+	c.EventCoalesceWindow = opts.EventCoalesceWindow
+
+	if opts.DeviceReadyTimeout < 0 {
+		return fmt.Errorf("invalid device ready timeout: %v, should not be negative", opts.DeviceReadyTimeout)
+	}
+	c.DeviceReadyTimeout = opts.DeviceReadyTimeout
+
+	if opts.ContentFingerprintKiB < 0 {
+		return fmt.Errorf("invalid content fingerprint size: %d KiB, should not be negative", opts.ContentFingerprintKiB)
+	}
+	c.ContentFingerprintKiB = opts.ContentFingerprintKiB
+
+	if opts.InstanceID != "" {
+		if errs := validation.IsValidLabelValue(opts.InstanceID); len(errs) != 0 {
+			return fmt.Errorf("invalid instance id: %q, %s", opts.InstanceID, strings.Join(errs, ", "))
+		}
+	}
+	c.InstanceID = opts.InstanceID
+
+	if opts.PartitionName != "" {
+		if err := partition.ValidatePartitionName(opts.PartitionName); err != nil {
+			return err
+		}
+	}
+	c.PartitionName = opts.PartitionName
+
+	if opts.ProvisioningTimeout < 0 {
+		return fmt.Errorf("invalid provisioning timeout: %v, should not be negative", opts.ProvisioningTimeout)
+	}
+	c.StartProvisioningWatcher(opts.ProvisioningMarkers, opts.ProvisioningTimeout)
+
+	if opts.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %v, should not be negative", opts.ShutdownTimeout)
+	}
+	c.ShutdownTimeout = opts.ShutdownTimeout
+
+	if opts.DecisionAddress != "" && opts.DecisionAddress == opts.MetricsAddress {
+		return fmt.Errorf("decision address: %s cannot be the same as the metrics address", opts.DecisionAddress)
+	}
+	// the decision endpoint is not authenticated, and the daemon runs on the host network
+	if opts.DecisionAddress != "" && !isLoopbackAddress(opts.DecisionAddress) {
+		return fmt.Errorf("invalid decision address: %s, should be a loopback address, eg: 127.0.0.1:9102", opts.DecisionAddress)
+	}
+
+	if opts.MetricsAddress != "" {
+		metrics, err := getDaemonMetrics()
+		if err != nil {
+			return err
+		}
+		c.Metrics = metrics
+	}
+
+	nodeNameSource, err := ParseNodeNameSource(opts.NodeNameSource)
+	if err != nil {
+		return err
+	}
+
 	c.Filters = make([]*Filter, 0)
 	c.Probes = make([]*Probe, 0)
 	c.NodeAttributes = make(map[string]string, 0)
 	c.Mutex = &sync.Mutex{}
-	if err := c.setNodeAttributes(); err != nil {
+	if err := c.setNodeAttributes(nodeNameSource, opts.NodeName); err != nil {
 		return err
 	}
+	c.StartNodeLabelWatcher()
 	return nil
 }
 
@@ -191,12 +811,13 @@ func (c *Controller) newClientSet() (client.Client, error) {
 	return clientSet, nil
 }
 
-func (c *Controller) setNodeAttributes() error {
+func (c *Controller) setNodeAttributes(source NodeNameSource, configuredName string) error {
 	// sets the node name label
-	nodeName, err := getNodeName()
+	nodeName, err := resolveNodeName(source, configuredName)
 	if err != nil {
 		return fmt.Errorf("unable to set node attributes: %v", err)
 	}
+	klog.Infof("using node name: %s from source: %s", nodeName, source)
 	c.NodeAttributes[NodeNameKey] = nodeName
 
 	// set the node labels
@@ -214,6 +835,14 @@ func (c *Controller) setNodeLabels() error {
 	// node object
 	node := &v1.Node{}
 	err := c.Clientset.Get(context.TODO(), client.ObjectKey{Namespace: "", Name: nodeName}, node)
+	if k8serrors.IsNotFound(err) {
+		// the resources will be attributed to a node that does not exist, most likely
+		// the node name source is not the right one for this cluster.
+		klog.Warningf("node: %s not found, the node name source may be incorrect. "+
+			"using node name as hostname", nodeName)
+		c.NodeAttributes[HostNameKey] = nodeName
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -226,30 +855,10 @@ func (c *Controller) setNodeLabels() error {
 		c.NodeAttributes[HostNameKey] = hostName
 	}
 
-	var labelPattern []string
-
-	// Get the list of node label patterns to be added from the configmap
-	if c.NDMConfig != nil {
-		for _, metaConfig := range c.NDMConfig.MetaConfigs {
-			if metaConfig.Key == nodeLabelsKey {
-				labelPattern = strings.Split(metaConfig.Pattern, ",")
-			}
-		}
-	}
-
-	if len(labelPattern) > 0 {
-		// Add only those node labels that matches the pattern specified in the
-		// node-labels meta config
-		for key, value := range node.Labels {
-			for _, pattern := range labelPattern {
-				if util.IsMatchRegex(pattern, key) {
-					if value != "" {
-						c.NodeAttributes[key] = value
-						break
-					}
-				}
-			}
-		}
+	// Add only those node labels that matches the pattern specified in the
+	// node-labels meta config
+	for key, value := range getNodeLabelAttributes(node.Labels, c.getNodeLabelPatterns()) {
+		c.NodeAttributes[key] = value
 	}
 
 	return nil
@@ -324,6 +933,13 @@ func (c *Controller) run(threadiness int, ctx context.Context) error {
 		return ctx.Err()
 	}
 	<-ctx.Done()
+	// stop processing new events and let the event being processed complete, before
+	// the state of the resources is changed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	if err := c.Shutdown(shutdownCtx); err != nil {
+		klog.Warningf("shutting down before the processing of devices completed: %v", err)
+	}
 	// Changing the state to unknown before shutting down. Similar as when one pod is
 	// running and you stopped kubelet it will make pod status unknown.
 	c.MarkBlockDeviceStatusToUnknown()
@@ -340,3 +956,39 @@ func (c *Controller) Lock() {
 func (c *Controller) Unlock() {
 	c.Mutex.Unlock()
 }
+
+// daemonMetrics are the metrics of the daemon. They are created and registered only once,
+// as the options may be set more than once, eg: in tests.
+var (
+	daemonMetrics     *daemon.Metrics
+	daemonMetricsErr  error
+	daemonMetricsOnce sync.Once
+)
+
+// getDaemonMetrics gets the metrics of the daemon, registering them on first use
+func getDaemonMetrics() (*daemon.Metrics, error) {
+	daemonMetricsOnce.Do(func() {
+		metrics := daemon.NewMetrics()
+		for _, collector := range metrics.Collectors() {
+			if err := prometheus.Register(collector); err != nil {
+				daemonMetricsErr = fmt.Errorf("unable to register daemon metrics: %v", err)
+				return
+			}
+		}
+		daemonMetrics = metrics
+	})
+	return daemonMetrics, daemonMetricsErr
+}
+
+// isLoopbackAddress checks if the host of the address(ip:port) is a loopback address
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
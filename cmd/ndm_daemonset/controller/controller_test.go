@@ -25,8 +25,8 @@ import (
 )
 
 /*
-	set environment variable "NODE_NAME" with some value and getNodeName
-	unset environment variable "NODE_NAME" with some value and getNodeName
+set environment variable "NODE_NAME" with some value and getNodeName
+unset environment variable "NODE_NAME" with some value and getNodeName
 */
 func TestGetNodeName(t *testing.T) {
 	fakeNodeName := "fake-node-name"
@@ -75,8 +75,8 @@ func TestSetNamespace(t *testing.T) {
 }
 
 /*
-	Broadcast start broadcasting controller pointer in ControllerBroadcastChannel channel
-	In this test case read ControllerBroadcastChannel channel and match controller pointer
+Broadcast start broadcasting controller pointer in ControllerBroadcastChannel channel
+In this test case read ControllerBroadcastChannel channel and match controller pointer
 */
 func TestBroadcast(t *testing.T) {
 	ctrl := &Controller{}
@@ -94,3 +94,93 @@ func TestBroadcast(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRemovableDevicePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    RemovableDevicePolicy
+		wantErr bool
+	}{
+		"empty policy":   {policy: "", want: IgnoreRemovableDevices, wantErr: false},
+		"ignore policy":  {policy: "ignore", want: IgnoreRemovableDevices, wantErr: false},
+		"manage policy":  {policy: "manage", want: ManageRemovableDevices, wantErr: false},
+		"invalid policy": {policy: "eject", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRemovableDevicePolicy(tt.policy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePartitionReclaimPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    PartitionReclaimPolicy
+		wantErr bool
+	}{
+		"empty policy":   {policy: "", want: RetainPartitions, wantErr: false},
+		"retain policy":  {policy: "retain", want: RetainPartitions, wantErr: false},
+		"reclaim policy": {policy: "reclaim", want: ReclaimPartitions, wantErr: false},
+		"invalid policy": {policy: "delete", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePartitionReclaimPolicy(tt.policy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePartitionedParentPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    PartitionedParentPolicy
+		wantErr bool
+	}{
+		"empty policy":   {policy: "", want: IgnorePartitionedParents, wantErr: false},
+		"ignore policy":  {policy: "ignore", want: IgnorePartitionedParents, wantErr: false},
+		"show policy":    {policy: "show", want: ShowPartitionedParents, wantErr: false},
+		"invalid policy": {policy: "manage", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePartitionedParentPolicy(tt.policy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsLoopbackAddress(t *testing.T) {
+	tests := map[string]struct {
+		address string
+		want    bool
+	}{
+		"ipv4 loopback":        {address: "127.0.0.1:9102", want: true},
+		"ipv6 loopback":        {address: "[::1]:9102", want: true},
+		"localhost":            {address: "localhost:9102", want: true},
+		"all the interfaces":   {address: ":9102", want: false},
+		"node address":         {address: "10.0.0.5:9102", want: false},
+		"address without port": {address: "127.0.0.1", want: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isLoopbackAddress(tt.address))
+		})
+	}
+}
+
+func TestGetDaemonMetricsRegisteredOnce(t *testing.T) {
+	metrics, err := getDaemonMetrics()
+	assert.NoError(t, err)
+	assert.NotNil(t, metrics)
+
+	// the options may be set more than once, the metrics are registered only once
+	again, err := getDaemonMetrics()
+	assert.NoError(t, err)
+	assert.Same(t, metrics, again)
+}
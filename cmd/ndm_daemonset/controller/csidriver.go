@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path"
+	"strings"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"k8s.io/klog/v2"
+)
+
+// GetCSIDriverOfDevice gets the name of the CSI driver in the config that owns the
+// device, recognized by the partition type or the filesystem label stamped on the device
+// by the driver. false is returned if the device is not owned by any of the drivers.
+func (c *Controller) GetCSIDriverOfDevice(bd blockdevice.BlockDevice) (string, bool) {
+	if c.NDMConfig == nil {
+		return "", false
+	}
+	for _, driver := range c.NDMConfig.CSIDriverConfigs {
+		if matchesPartitionType(driver.PartitionTypes, bd.PartitionInfo.PartitionType) ||
+			matchesFilesystemLabel(driver.FilesystemLabels, bd.FSInfo.FileSystemLabel) {
+			return driver.Name, true
+		}
+	}
+	return "", false
+}
+
+// matchesPartitionType checks if the partition type is in the list of partition type
+// GUIDs. The GUIDs are compared irrespective of their case.
+func matchesPartitionType(partitionTypes []string, partitionType string) bool {
+	if partitionType == "" {
+		return false
+	}
+	for _, t := range partitionTypes {
+		if strings.EqualFold(strings.TrimSpace(t), partitionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilesystemLabel checks if the filesystem label matches any of the glob patterns
+func matchesFilesystemLabel(patterns []string, label string) bool {
+	if label == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		ok, err := path.Match(strings.TrimSpace(pattern), label)
+		if err != nil {
+			klog.Errorf("invalid filesystem label pattern: %q in csi driver config, %v", pattern, err)
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
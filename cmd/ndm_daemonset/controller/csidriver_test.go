@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCSIDriverOfDevice(t *testing.T) {
+	drivers := []CSIDriverConfig{
+		{
+			Name:           "lvm.csi.example.com",
+			PartitionTypes: []string{"E6D6D379-F507-44C2-A23C-238F2A3DF928"},
+		},
+		{
+			Name:             "raw.csi.example.com",
+			FilesystemLabels: []string{"csi-raw-*", "[invalid"},
+		},
+	}
+	newDevice := func(partitionType, label string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			PartitionInfo: blockdevice.PartitionInformation{PartitionType: partitionType},
+			FSInfo:        blockdevice.FileSystemInformation{FileSystemLabel: label},
+		}
+	}
+
+	tests := map[string]struct {
+		drivers    []CSIDriverConfig
+		bd         blockdevice.BlockDevice
+		wantDriver string
+		wantOk     bool
+	}{
+		"no csi drivers in the config": {
+			drivers: nil,
+			bd:      newDevice("", "csi-raw-pvc-1"),
+			wantOk:  false,
+		},
+		"partition type of a csi driver": {
+			drivers:    drivers,
+			bd:         newDevice("e6d6d379-f507-44c2-a23c-238f2a3df928", ""),
+			wantDriver: "lvm.csi.example.com",
+			wantOk:     true,
+		},
+		"filesystem label matching the pattern of a csi driver": {
+			drivers:    drivers,
+			bd:         newDevice("", "csi-raw-pvc-1"),
+			wantDriver: "raw.csi.example.com",
+			wantOk:     true,
+		},
+		"filesystem label not matching any pattern": {
+			drivers: drivers,
+			bd:      newDevice("0fc63daf-8483-4772-8e79-3d69d8477de4", "data"),
+			wantOk:  false,
+		},
+		"device without partition type and label": {
+			drivers: drivers,
+			bd:      newDevice("", ""),
+			wantOk:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{
+					CSIDriverConfigs: tt.drivers,
+				},
+			}
+			got, ok := c.GetCSIDriverOfDevice(tt.bd)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantDriver, got)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/klog/v2"
+)
+
+// devDiskPath is the directory having the by-* trees of device symlinks created by udev
+var devDiskPath = "/dev/disk"
+
+// DevLinkKinds are the kinds of devlinks that are stored on the BlockDevice resource,
+// in the order in which they are stored. Each by-* kind is a directory in /dev/disk. The
+// dm-name kind is the /dev/mapper link of a dm device, which is filled by the udev probe.
+var DevLinkKinds = []string{
+	"by-id",
+	"by-path",
+	"by-uuid",
+	"by-partuuid",
+	"by-label",
+	"by-partlabel",
+	"dm-name",
+}
+
+// getDevLinks merges the devlinks filled by the probes with the symlinks in /dev/disk
+// that resolve to the device. The links of each kind are sorted and deduplicated. The
+// first by-id link filled by the udev probe is the default by-id link of the device and
+// is kept as the first link, as consumers rely on it.
+func getDevLinks(blockDevice *bd.BlockDevice) map[string][]string {
+	devLinks := getDevLinksFromDevDisk(blockDevice.DevPath)
+	primaryLinks := make(map[string]string)
+	for _, devLink := range blockDevice.DevLinks {
+		if !util.Contains(DevLinkKinds, devLink.Kind) || len(devLink.Links) == 0 {
+			continue
+		}
+		primaryLinks[devLink.Kind] = devLink.Links[0]
+		devLinks[devLink.Kind] = append(devLinks[devLink.Kind], devLink.Links...)
+	}
+
+	for kind, links := range devLinks {
+		links = sortAndDeduplicate(links)
+		if primary, ok := primaryLinks[kind]; ok && kind == "by-id" {
+			links = append([]string{primary}, util.RemoveString(links, primary)...)
+		}
+		devLinks[kind] = links
+	}
+	return devLinks
+}
+
+// getDevLinksFromDevDisk gets the symlinks in the /dev/disk/by-* trees that resolve to
+// the given device, grouped by kind.
+func getDevLinksFromDevDisk(devPath string) map[string][]string {
+	devLinks := make(map[string][]string)
+	realDevPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		klog.V(4).Infof("unable to resolve device: %s to read devlinks: %v", devPath, err)
+		return devLinks
+	}
+
+	for _, kind := range DevLinkKinds {
+		dir := filepath.Join(devDiskPath, kind)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			// not all the by-* trees are present on every node
+			continue
+		}
+		for _, file := range files {
+			link := filepath.Join(dir, file.Name())
+			target, err := filepath.EvalSymlinks(link)
+			if err != nil || target != realDevPath {
+				continue
+			}
+			devLinks[kind] = append(devLinks[kind], link)
+		}
+	}
+	return devLinks
+}
+
+// sortAndDeduplicate returns the sorted list of unique strings in the slice
+func sortAndDeduplicate(s []string) []string {
+	result := make([]string, 0, len(s))
+	for _, str := range s {
+		result = util.AddUniqueStringtoSlice(result, str)
+	}
+	sort.Strings(result)
+	return result
+}
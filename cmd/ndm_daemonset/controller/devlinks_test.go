@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDevLinks(t *testing.T) {
+	// create a fake /dev with the device nodes and /dev/disk/by-* trees
+	devDir := t.TempDir()
+	for _, dev := range []string{"sda", "sdb"} {
+		if err := os.WriteFile(filepath.Join(devDir, dev), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"by-id/wwn-0x5000c500a1b2c3d4":                 "sda",
+		"by-id/ata-ST1000NM0055_ZA1B2C3D":              "sda",
+		"by-path/pci-0000:00:1f.2-ata-1":               "sda",
+		"by-uuid/9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c4d": "sda",
+		"by-id/wwn-0x5000c500e5f6a7b8":                 "sdb",
+		"by-foo/unknown-kind":                          "sda",
+	}
+	oldDevDiskPath := devDiskPath
+	devDiskPath = filepath.Join(devDir, "disk")
+	defer func() { devDiskPath = oldDevDiskPath }()
+	for link, dev := range links {
+		path := filepath.Join(devDiskPath, link)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..", "..", dev), path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byID := func(name string) string { return filepath.Join(devDiskPath, "by-id", name) }
+
+	tests := map[string]struct {
+		blockDevice *bd.BlockDevice
+		want        map[string][]string
+	}{
+		"links from /dev/disk are sorted and grouped by kind": {
+			blockDevice: &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: filepath.Join(devDir, "sda")},
+			},
+			want: map[string][]string{
+				"by-id": {
+					byID("ata-ST1000NM0055_ZA1B2C3D"),
+					byID("wwn-0x5000c500a1b2c3d4"),
+				},
+				"by-path": {filepath.Join(devDiskPath, "by-path", "pci-0000:00:1f.2-ata-1")},
+				"by-uuid": {filepath.Join(devDiskPath, "by-uuid", "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c4d")},
+			},
+		},
+		"links from udev are deduplicated and the default by-id link is kept first": {
+			blockDevice: &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: filepath.Join(devDir, "sda")},
+				DevLinks: []bd.DevLink{
+					{
+						Kind: "by-id",
+						Links: []string{
+							byID("wwn-0x5000c500a1b2c3d4"),
+							byID("ata-ST1000NM0055_ZA1B2C3D"),
+						},
+					},
+					{
+						Kind:  "symlink",
+						Links: []string{"/dev/my-disk"},
+					},
+				},
+			},
+			want: map[string][]string{
+				"by-id": {
+					byID("wwn-0x5000c500a1b2c3d4"),
+					byID("ata-ST1000NM0055_ZA1B2C3D"),
+				},
+				"by-path": {filepath.Join(devDiskPath, "by-path", "pci-0000:00:1f.2-ata-1")},
+				"by-uuid": {filepath.Join(devDiskPath, "by-uuid", "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c4d")},
+			},
+		},
+		"device not present, only links from udev are used": {
+			blockDevice: &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: filepath.Join(devDir, "sdc")},
+				DevLinks: []bd.DevLink{
+					{
+						Kind:  "by-path",
+						Links: []string{"/dev/disk/by-path/pci-0000:00:1f.2-ata-3", "/dev/disk/by-path/pci-0000:00:1f.2-ata-3"},
+					},
+				},
+			},
+			want: map[string][]string{
+				"by-path": {"/dev/disk/by-path/pci-0000:00:1f.2-ata-3"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, getDevLinks(test.blockDevice))
+		})
+	}
+}
+
+func TestGetDeviceLinks(t *testing.T) {
+	di := &DeviceInfo{
+		DevLinks: map[string][]string{
+			"by-uuid": {"/dev/disk/by-uuid/9a1b8f0e"},
+			"by-id":   {"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4"},
+			"by-path": {},
+		},
+	}
+	want := []apis.DeviceDevLink{
+		{Kind: "by-id", Links: []string{"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4"}},
+		{Kind: "by-uuid", Links: []string{"/dev/disk/by-uuid/9a1b8f0e"}},
+	}
+	assert.Equal(t, want, di.getDeviceLinks())
+}
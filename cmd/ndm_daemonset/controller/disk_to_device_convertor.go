@@ -17,8 +17,12 @@ limitations under the License.
 package controller
 
 import (
+	"strconv"
+
 	bd "github.com/openebs/node-disk-manager/blockdevice"
-	"github.com/openebs/node-disk-manager/pkg/udev"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
 )
 
 // NewDeviceInfoFromBlockDevice converts the internal BlockDevice struct to
@@ -36,25 +40,142 @@ func (c *Controller) NewDeviceInfoFromBlockDevice(blockDevice *bd.BlockDevice) *
 
 	deviceDetails.UUID = blockDevice.UUID
 	deviceDetails.Labels = blockDevice.Labels
+	if c.UdevAnnotations {
+		deviceDetails.Annotations = getUdevAnnotations(blockDevice.UdevProperties)
+	}
+	if blockDevice.DecisionTrace != nil {
+		if deviceDetails.Annotations == nil {
+			deviceDetails.Annotations = make(map[string]string)
+		}
+		deviceDetails.Annotations[DecisionTraceAnnotation] = blockDevice.DecisionTrace.String()
+	}
+	if blockDevice.DeviceAttributes.Removable {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMRemovableKey] = TrueString
+	}
+	if backingFile := blockDevice.LoopInfo.BackingFile; backingFile != "" {
+		if deviceDetails.Annotations == nil {
+			deviceDetails.Annotations = make(map[string]string)
+		}
+		deviceDetails.Annotations[LoopBackingFileAnnotation] = backingFile
+		if blockDevice.LoopInfo.Sparse {
+			if deviceDetails.Labels == nil {
+				deviceDetails.Labels = make(map[string]string)
+			}
+			deviceDetails.Labels[NDMSparseKey] = TrueString
+		}
+	}
+	if bd.IsFabricTransport(blockDevice.DeviceAttributes.Transport) {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMTransportKey] = blockDevice.DeviceAttributes.Transport
+	}
+	deviceDetails.Transport = blockDevice.DeviceAttributes.Transport
+	deviceDetails.FabricTarget = blockDevice.DeviceAttributes.FabricTarget
+	deviceDetails.FabricAddress = blockDevice.DeviceAttributes.FabricAddress
+	if controllerSerial := blockDevice.DeviceAttributes.ControllerSerial; controllerSerial != "" {
+		if errs := validation.IsValidLabelValue(controllerSerial); len(errs) > 0 {
+			klog.V(4).Infof("device: %s, not adding label %s=%s: %v",
+				blockDevice.DevPath, NDMNVMeControllerKey, controllerSerial, errs)
+		} else {
+			if deviceDetails.Labels == nil {
+				deviceDetails.Labels = make(map[string]string)
+			}
+			deviceDetails.Labels[NDMNVMeControllerKey] = controllerSerial
+		}
+	}
+	if sectorModel := bd.GetSectorModel(blockDevice.DeviceAttributes.LogicalBlockSize,
+		blockDevice.DeviceAttributes.PhysicalBlockSize); sectorModel != "" {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMSectorModelKey] = sectorModel
+	}
+	if blockDevice.DeviceAttributes.DiscardSupported {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMDiscardKey] = TrueString
+	}
+	if writeCache := getWriteCacheState(blockDevice.DeviceAttributes.WriteCacheEnabled); writeCache != "" {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMWriteCacheKey] = writeCache
+		deviceDetails.WriteCache = writeCache
+	}
+	if numaNode := blockDevice.DeviceAttributes.NUMANode; numaNode != nil {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		deviceDetails.Labels[NDMNUMANodeKey] = strconv.Itoa(*numaNode)
+	}
+	if ioErrors := blockDevice.DeviceAttributes.IOErrors; ioErrors != nil {
+		state := *ioErrors
+		deviceDetails.IOErrors = &state
+	}
+	if writability := blockDevice.DeviceAttributes.Writability; writability != nil {
+		state := *writability
+		deviceDetails.Writability = &state
+	}
+	if pcieLink := blockDevice.DeviceAttributes.PCIeLink; pcieLink != nil {
+		link := *pcieLink
+		deviceDetails.PCIeLink = &link
+		if link.IsDegraded() {
+			if deviceDetails.Labels == nil {
+				deviceDetails.Labels = make(map[string]string)
+			}
+			deviceDetails.Labels[NDMDegradedLinkKey] = getDegradedLinkLabelValue(link)
+		}
+	}
+	if existingFSLabels := getExistingFSLabels(blockDevice); len(existingFSLabels) > 0 {
+		if deviceDetails.Labels == nil {
+			deviceDetails.Labels = make(map[string]string)
+		}
+		for k, v := range existingFSLabels {
+			deviceDetails.Labels[k] = v
+		}
+	}
 	deviceDetails.Capacity = blockDevice.Capacity.Storage
 	deviceDetails.Model = blockDevice.DeviceAttributes.Model
 	deviceDetails.Serial = blockDevice.DeviceAttributes.Serial
 	deviceDetails.Vendor = blockDevice.DeviceAttributes.Vendor
 	deviceDetails.Path = blockDevice.DevPath
 	deviceDetails.FirmwareRevision = blockDevice.DeviceAttributes.FirmwareRevision
-
-	for _, devlink := range blockDevice.DevLinks {
-		if devlink.Kind == udev.BY_ID_LINK {
-			deviceDetails.ByIdDevLinks = devlink.Links
-		} else if devlink.Kind == udev.BY_PATH_LINK {
-			deviceDetails.ByPathDevLinks = devlink.Links
-		}
+	deviceDetails.FormFactor = blockDevice.DeviceAttributes.FormFactor
+	deviceDetails.WriteEnduranceTBW = blockDevice.DeviceAttributes.WriteEnduranceTBW
+	if blockDevice.SMARTInfo.TemperatureInfo.CurrentTemperatureDataValid {
+		temperature := blockDevice.SMARTInfo.TemperatureInfo.CurrentTemperature
+		deviceDetails.Temperature = &temperature
 	}
+
+	deviceDetails.DevLinks = getDevLinks(blockDevice)
 	deviceDetails.LogicalBlockSize = blockDevice.DeviceAttributes.LogicalBlockSize
 	deviceDetails.PhysicalBlockSize = blockDevice.DeviceAttributes.PhysicalBlockSize
 	deviceDetails.HardwareSectorSize = blockDevice.DeviceAttributes.HardwareSectorSize
 	deviceDetails.DriveType = blockDevice.DeviceAttributes.DriveType
+	deviceDetails.DiscardSupported = blockDevice.DeviceAttributes.DiscardSupported
+	deviceDetails.DiscardGranularity = blockDevice.DeviceAttributes.DiscardGranularity
+	deviceDetails.IOScheduler = blockDevice.DeviceAttributes.IOScheduler
+	deviceDetails.QueueDepth = blockDevice.DeviceAttributes.QueueDepth
 	deviceDetails.DeviceType = blockDevice.DeviceAttributes.DeviceType
+	if blockDevice.DeviceAttributes.DeviceType == bd.BlockDeviceTypePartition {
+		deviceDetails.PartitionType = blockDevice.PartitionInfo.PartitionType
+		deviceDetails.PartitionGUID = blockDevice.PartitionInfo.PartitionEntryUUID
+		deviceDetails.PartitionNumber = blockDevice.PartitionInfo.PartitionNumber
+		deviceDetails.PartitionOffset = blockDevice.PartitionInfo.StartOffset
+		deviceDetails.LayoutChecked = blockDevice.PartitionInfo.LayoutChecked
+		deviceDetails.LayoutViolation = blockDevice.PartitionInfo.LayoutViolation
+		deviceDetails.BlankChecked = blockDevice.PartitionInfo.BlankChecked
+		deviceDetails.UnexpectedSignatures = blockDevice.PartitionInfo.UnexpectedSignatures
+		deviceDetails.ParentPath = blockDevice.DependentDevices.Parent
+	}
+	deviceDetails.PartitionPaths = blockDevice.DependentDevices.Partitions
+	deviceDetails.PartitionTableChecked = blockDevice.PartitionInfo.PartitionTableChecked
+	deviceDetails.PartitionTableCorruption = blockDevice.PartitionInfo.PartitionTableCorruption
 
 	deviceDetails.Compliance = blockDevice.DeviceAttributes.Compliance
 	deviceDetails.FileSystemInfo.FileSystem = blockDevice.FSInfo.FileSystem
@@ -0,0 +1,407 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeviceInfoFromBlockDeviceSectorModel(t *testing.T) {
+	tests := map[string]struct {
+		logicalBlockSize  uint32
+		physicalBlockSize uint32
+		wantSectorModel   string
+	}{
+		"native 512 disk": {
+			logicalBlockSize:  512,
+			physicalBlockSize: 512,
+			wantSectorModel:   bd.SectorModel512n,
+		},
+		"512 emulated disk": {
+			logicalBlockSize:  512,
+			physicalBlockSize: 4096,
+			wantSectorModel:   bd.SectorModel512e,
+		},
+		"native 4K disk": {
+			logicalBlockSize:  4096,
+			physicalBlockSize: 4096,
+			wantSectorModel:   bd.SectorModel4Kn,
+		},
+		"block sizes not known": {
+			logicalBlockSize:  0,
+			physicalBlockSize: 0,
+			wantSectorModel:   "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: "/dev/non-existent-disk"},
+				DeviceAttributes: bd.DeviceAttribute{
+					LogicalBlockSize:  tt.logicalBlockSize,
+					PhysicalBlockSize: tt.physicalBlockSize,
+				},
+			}
+			deviceInfo := c.NewDeviceInfoFromBlockDevice(blockDevice)
+			assert.Equal(t, tt.logicalBlockSize, deviceInfo.LogicalBlockSize)
+			assert.Equal(t, tt.physicalBlockSize, deviceInfo.PhysicalBlockSize)
+			assert.Equal(t, tt.wantSectorModel, deviceInfo.Labels[NDMSectorModelKey])
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceNVMeController(t *testing.T) {
+	tests := map[string]struct {
+		controllerSerial string
+		wantLabel        string
+		wantLabelPresent bool
+	}{
+		"namespace of an nvme controller": {
+			controllerSerial: "S4EVNX0N123456",
+			wantLabel:        "S4EVNX0N123456",
+			wantLabelPresent: true,
+		},
+		"controller serial not a valid label value": {
+			controllerSerial: "S4EVNX0N 123456",
+			wantLabelPresent: false,
+		},
+		"not an nvme namespace": {
+			controllerSerial: "",
+			wantLabelPresent: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: "/dev/non-existent-namespace"},
+				DeviceAttributes: bd.DeviceAttribute{
+					ControllerSerial: tt.controllerSerial,
+				},
+			}
+			deviceInfo := c.NewDeviceInfoFromBlockDevice(blockDevice)
+			label, ok := deviceInfo.Labels[NDMNVMeControllerKey]
+			assert.Equal(t, tt.wantLabelPresent, ok)
+			assert.Equal(t, tt.wantLabel, label)
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDevicePartitionInfo(t *testing.T) {
+	partitionInfo := bd.PartitionInformation{
+		PartitionNumber:    2,
+		PartitionEntryUUID: "5d3e1e4a-3f6b-4f0b-9c3a-6c1f2b7a8e02",
+		PartitionTableType: "gpt",
+		StartOffset:        2097152,
+	}
+	tests := map[string]struct {
+		deviceType string
+		want       *apis.DevicePartitionInfo
+	}{
+		"partition": {
+			deviceType: bd.BlockDeviceTypePartition,
+			want: &apis.DevicePartitionInfo{
+				PartitionGUID:   "5d3e1e4a-3f6b-4f0b-9c3a-6c1f2b7a8e02",
+				PartitionNumber: 2,
+				StartOffset:     2097152,
+			},
+		},
+		"disk": {
+			deviceType: bd.BlockDeviceTypeDisk,
+			want:       nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-partition",
+					DevPath: "/dev/sdb2",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType: tt.deviceType,
+				},
+				PartitionInfo: partitionInfo,
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, bdAPI.Spec.PartitionInfo)
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceDiscard(t *testing.T) {
+	tests := map[string]struct {
+		discardSupported   bool
+		discardGranularity uint64
+		wantLabel          bool
+	}{
+		"device supporting discard": {
+			discardSupported:   true,
+			discardGranularity: 4096,
+			wantLabel:          true,
+		},
+		"device without discard": {
+			discardSupported: false,
+			wantLabel:        false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-discard",
+					DevPath: "/dev/nvme0n1",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType:         bd.BlockDeviceTypeDisk,
+					DiscardSupported:   tt.discardSupported,
+					DiscardGranularity: tt.discardGranularity,
+				},
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.discardSupported, bdAPI.Spec.Details.DiscardSupported)
+			assert.Equal(t, tt.discardGranularity, bdAPI.Spec.Details.DiscardGranularity)
+			_, ok := bdAPI.Labels[NDMDiscardKey]
+			assert.Equal(t, tt.wantLabel, ok)
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceWriteCache(t *testing.T) {
+	enabled, disabled := true, false
+	tests := map[string]struct {
+		writeCacheEnabled *bool
+		want              string
+	}{
+		"write cache enabled": {
+			writeCacheEnabled: &enabled,
+			want:              WriteCacheEnabled,
+		},
+		"write cache disabled": {
+			writeCacheEnabled: &disabled,
+			want:              WriteCacheDisabled,
+		},
+		"write cache state not known": {
+			writeCacheEnabled: nil,
+			want:              "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-write-cache",
+					DevPath: "/dev/sda",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType:        bd.BlockDeviceTypeDisk,
+					WriteCacheEnabled: tt.writeCacheEnabled,
+				},
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, bdAPI.Spec.Details.WriteCache)
+			assert.Equal(t, tt.want, bdAPI.Labels[NDMWriteCacheKey])
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceFabric(t *testing.T) {
+	tests := map[string]struct {
+		transport string
+		target    string
+		address   string
+		wantLabel string
+	}{
+		"nvme-tcp namespace": {
+			transport: bd.TransportNVMeTCP,
+			target:    "nqn.2014-08.org.nvmexpress:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+			address:   "traddr=10.0.0.1,trsvcid=4420",
+			wantLabel: bd.TransportNVMeTCP,
+		},
+		"local nvme device": {
+			transport: bd.TransportNVMePCIe,
+			wantLabel: "",
+		},
+		"transport not known": {
+			wantLabel: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-fabric",
+					DevPath: "/dev/nvme1n1",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType:    bd.BlockDeviceTypeDisk,
+					Transport:     tt.transport,
+					FabricTarget:  tt.target,
+					FabricAddress: tt.address,
+				},
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.transport, bdAPI.Spec.Details.Transport)
+			assert.Equal(t, tt.target, bdAPI.Spec.Details.FabricTarget)
+			assert.Equal(t, tt.address, bdAPI.Spec.Details.FabricAddress)
+			assert.Equal(t, tt.wantLabel, bdAPI.Labels[NDMTransportKey])
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceExtendedAttributes(t *testing.T) {
+	tests := map[string]struct {
+		formFactor        string
+		writeEnduranceTBW uint64
+	}{
+		"ssd reporting form factor and endurance": {
+			formFactor:        "M.2",
+			writeEnduranceTBW: 600,
+		},
+		"disk not reporting the attributes": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-extended",
+					DevPath: "/dev/sda",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType:        bd.BlockDeviceTypeDisk,
+					FormFactor:        tt.formFactor,
+					WriteEnduranceTBW: tt.writeEnduranceTBW,
+				},
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.formFactor, bdAPI.Spec.Details.FormFactor)
+			assert.Equal(t, tt.writeEnduranceTBW, bdAPI.Spec.Details.WriteEnduranceTBW)
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceNUMANode(t *testing.T) {
+	numaNode := 1
+	firstNUMANode := 0
+	tests := map[string]struct {
+		numaNode  *int
+		wantLabel string
+	}{
+		"device attached to numa node 1": {
+			numaNode:  &numaNode,
+			wantLabel: "1",
+		},
+		"device attached to numa node 0": {
+			numaNode:  &firstNUMANode,
+			wantLabel: "0",
+		},
+		"device without numa affinity": {
+			numaNode: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    "blockdevice-numa",
+					DevPath: "/dev/nvme0n1",
+				},
+				DeviceAttributes: bd.DeviceAttribute{
+					DeviceType: bd.BlockDeviceTypeDisk,
+					NUMANode:   tt.numaNode,
+				},
+			}
+			bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+			assert.NoError(t, err)
+			label, ok := bdAPI.Labels[NDMNUMANodeKey]
+			assert.Equal(t, tt.wantLabel != "", ok)
+			assert.Equal(t, tt.wantLabel, label)
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceLoopInfo(t *testing.T) {
+	tests := map[string]struct {
+		loopInfo        bd.LoopInformation
+		wantBackingFile string
+		wantSparse      string
+	}{
+		"loop device backed by a sparse file": {
+			loopInfo: bd.LoopInformation{
+				BackingFile: "/var/lib/images/disk0.img",
+				Sparse:      true,
+			},
+			wantBackingFile: "/var/lib/images/disk0.img",
+			wantSparse:      TrueString,
+		},
+		"loop device backed by a preallocated file": {
+			loopInfo: bd.LoopInformation{
+				BackingFile: "/var/lib/images/disk1.img",
+			},
+			wantBackingFile: "/var/lib/images/disk1.img",
+		},
+		"not a loop device": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: "/dev/loop0"},
+				LoopInfo:   tt.loopInfo,
+			}
+			deviceInfo := c.NewDeviceInfoFromBlockDevice(blockDevice)
+			assert.Equal(t, tt.wantBackingFile, deviceInfo.Annotations[LoopBackingFileAnnotation])
+			assert.Equal(t, tt.wantSparse, deviceInfo.Labels[NDMSparseKey])
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceQueueSettings(t *testing.T) {
+	c := &Controller{}
+	blockDevice := &bd.BlockDevice{
+		Identifier: bd.Identifier{
+			UUID:    "blockdevice-queue",
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: bd.DeviceAttribute{
+			DeviceType:  bd.BlockDeviceTypeDisk,
+			IOScheduler: "mq-deadline",
+			QueueDepth:  64,
+		},
+	}
+	bdAPI, err := c.NewDeviceInfoFromBlockDevice(blockDevice).ToDevice(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "mq-deadline", bdAPI.Spec.Details.IOScheduler)
+	assert.Equal(t, uint64(64), bdAPI.Spec.Details.QueueDepth)
+}
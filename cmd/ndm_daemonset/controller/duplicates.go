@@ -0,0 +1,265 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+	controllerutil "github.com/openebs/node-disk-manager/pkg/controllers/util"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DuplicateGroup is a set of BlockDevice resources having the same stable identifier, i.e
+// resources created for the same physical device, eg: by the path or WWN change bugs
+type DuplicateGroup struct {
+	// Identifier is the stable identifier shared by the resources
+	Identifier string
+	// BlockDevices are the resources having the identifier
+	BlockDevices []apis.BlockDevice
+}
+
+// getStableIdentifier gets the identifier of the physical device from the details recorded
+// on the resource. Disks are identified by their vendor, model and serial, and partitions
+// by their GPT partition GUID. The identifier is prefixed with the node of the resource, as
+// the resources are listed across the cluster, and the same identifier on different nodes,
+// eg: of cloned virtual disks, does not mean the same device. false is returned if the
+// device or its node cannot be identified.
+func getStableIdentifier(blockDevice apis.BlockDevice) (string, bool) {
+	hostName := blockDevice.Labels[KubernetesHostNameLabel]
+	if hostName == "" {
+		return "", false
+	}
+	details := blockDevice.Spec.Details
+	if details.DeviceType == bd.BlockDeviceTypePartition {
+		partitionInfo := blockDevice.Spec.PartitionInfo
+		if partitionInfo == nil || partitionInfo.PartitionGUID == "" {
+			return "", false
+		}
+		return hostName + "/partition/" + strings.ToLower(partitionInfo.PartitionGUID), true
+	}
+	if details.Serial == "" {
+		return "", false
+	}
+	return strings.Join([]string{hostName, details.DeviceType, details.Vendor, details.Model, details.Serial}, "/"), true
+}
+
+// GetDuplicateGroups groups the resources having the same stable identifier. Only the
+// groups having more than one resource are returned, sorted by the identifier. The sparse
+// devices and the devices without a stable identifier are never considered duplicates.
+func GetDuplicateGroups(blockDevices []apis.BlockDevice) []DuplicateGroup {
+	byIdentifier := make(map[string][]apis.BlockDevice)
+	for _, blockDevice := range blockDevices {
+		if blockDevice.Spec.Details.DeviceType == bd.SparseBlockDeviceType {
+			continue
+		}
+		identifier, ok := getStableIdentifier(blockDevice)
+		if !ok {
+			continue
+		}
+		byIdentifier[identifier] = append(byIdentifier[identifier], blockDevice)
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for identifier, duplicates := range byIdentifier {
+		if len(duplicates) > 1 {
+			groups = append(groups, DuplicateGroup{Identifier: identifier, BlockDevices: duplicates})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Identifier < groups[j].Identifier
+	})
+	return groups
+}
+
+// PickCanonicalBlockDevice picks the resource among the duplicates that is retained. The
+// claimed resource is picked, as it is in use by a consumer, otherwise the newest Active
+// resource, as it has the latest details of the device that is attached. The newest
+// resource is picked if none of them is Active. The resources cannot be merged if more
+// than one of them is claimed, since one of the consumers would lose its device.
+func PickCanonicalBlockDevice(blockDevices []apis.BlockDevice) (apis.BlockDevice, error) {
+	if len(blockDevices) == 0 {
+		return apis.BlockDevice{}, fmt.Errorf("no blockdevice to pick from")
+	}
+	claimed := make([]apis.BlockDevice, 0)
+	for _, blockDevice := range blockDevices {
+		if blockDevice.Status.ClaimState == apis.BlockDeviceClaimed {
+			claimed = append(claimed, blockDevice)
+		}
+	}
+	switch len(claimed) {
+	case 0:
+	case 1:
+		return claimed[0], nil
+	default:
+		names := make([]string, 0, len(claimed))
+		for _, blockDevice := range claimed {
+			names = append(names, blockDevice.Name)
+		}
+		return apis.BlockDevice{}, fmt.Errorf("refusing to merge, more than one blockdevice is claimed: %s",
+			strings.Join(names, ", "))
+	}
+
+	candidates := make([]apis.BlockDevice, 0, len(blockDevices))
+	for _, blockDevice := range blockDevices {
+		if blockDevice.Status.State == NDMActive {
+			candidates = append(candidates, blockDevice)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = blockDevices
+	}
+
+	canonical := candidates[0]
+	for _, blockDevice := range candidates[1:] {
+		created := blockDevice.CreationTimestamp
+		// the name breaks the tie, so that the pick does not depend on the order
+		if canonical.CreationTimestamp.Before(&created) ||
+			(created.Equal(&canonical.CreationTimestamp) && blockDevice.Name > canonical.Name) {
+			canonical = blockDevice
+		}
+	}
+	return canonical, nil
+}
+
+// MergeDuplicateBlockDevices finds the resources created for the same physical device and
+// merges each group into its canonical resource. The claim held by a duplicate, eg: a
+// released device yet to be cleaned up, is moved to the canonical resource, the claims
+// pointing to a duplicate are pointed to the canonical resource, and the duplicates are
+// deleted. The merge is only printed unless apply is set. Groups that cannot be merged are
+// skipped, and an error is returned after the other groups are merged.
+func (c *Controller) MergeDuplicateBlockDevices(w io.Writer, apply bool) error {
+	blockDeviceList, err := c.ListBlockDeviceResource(true)
+	if err != nil {
+		return err
+	}
+	groups := GetDuplicateGroups(blockDeviceList.Items)
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "No duplicate blockdevices found.")
+		return nil
+	}
+
+	var failed []string
+	for _, group := range groups {
+		canonical, err := PickCanonicalBlockDevice(group.BlockDevices)
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", group.Identifier, err)
+			failed = append(failed, group.Identifier)
+			continue
+		}
+		duplicates := make([]apis.BlockDevice, 0, len(group.BlockDevices)-1)
+		names := make([]string, 0, len(group.BlockDevices)-1)
+		for _, blockDevice := range group.BlockDevices {
+			if blockDevice.Name != canonical.Name {
+				duplicates = append(duplicates, blockDevice)
+				names = append(names, blockDevice.Name)
+			}
+		}
+		fmt.Fprintf(w, "%s: keep %s, merge %s\n", group.Identifier, canonical.Name, strings.Join(names, ", "))
+		if !apply {
+			continue
+		}
+		if err := c.mergeDuplicates(canonical, duplicates); err != nil {
+			fmt.Fprintf(w, "%s: merge failed: %v\n", group.Identifier, err)
+			failed = append(failed, group.Identifier)
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("unable to merge duplicates of: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// mergeDuplicates merges the duplicates into the canonical resource
+func (c *Controller) mergeDuplicates(canonical apis.BlockDevice, duplicates []apis.BlockDevice) error {
+	for _, duplicate := range duplicates {
+		if !c.IsDestructiveOperationAllowed(DeleteBlockDeviceOperation, duplicate.Name) {
+			return fmt.Errorf("deletion of %s is not allowed", duplicate.Name)
+		}
+	}
+
+	// move the claim of a duplicate, if the canonical resource does not have one
+	if canonical.Spec.ClaimRef == nil {
+		for _, duplicate := range duplicates {
+			if duplicate.Spec.ClaimRef == nil {
+				continue
+			}
+			err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				blockDevice, err := c.GetBlockDevice(canonical.Name)
+				if err != nil {
+					return err
+				}
+				blockDevice.Spec.ClaimRef = duplicate.Spec.ClaimRef
+				blockDevice.Status.ClaimState = duplicate.Status.ClaimState
+				return c.Clientset.Update(context.TODO(), blockDevice)
+			})
+			if err != nil {
+				return fmt.Errorf("unable to move claim of %s to %s: %v", duplicate.Name, canonical.Name, err)
+			}
+			break
+		}
+	}
+
+	// point the claims referring to a duplicate to the canonical resource
+	claimList := &apis.BlockDeviceClaimList{}
+	if err := c.Clientset.List(context.TODO(), claimList, client.InNamespace(c.Namespace)); err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		for i := range claimList.Items {
+			claim := &claimList.Items[i]
+			if claim.Spec.BlockDeviceName != duplicate.Name {
+				continue
+			}
+			claim.Spec.BlockDeviceName = canonical.Name
+			if err := c.Clientset.Update(context.TODO(), claim); err != nil {
+				return fmt.Errorf("unable to point claim %s to %s: %v", claim.Name, canonical.Name, err)
+			}
+		}
+	}
+
+	for _, duplicate := range duplicates {
+		// the finalizer of a released duplicate is removed, as its claim has been moved
+		// and it would otherwise never be deleted
+		if util.Contains(duplicate.Finalizers, controllerutil.BlockDeviceFinalizer) {
+			duplicate.Finalizers = util.RemoveString(duplicate.Finalizers, controllerutil.BlockDeviceFinalizer)
+			if err := c.Clientset.Update(context.TODO(), &duplicate); err != nil {
+				return fmt.Errorf("unable to remove finalizer of %s: %v", duplicate.Name, err)
+			}
+		}
+		err := c.Clientset.Delete(context.TODO(), &duplicate)
+		c.Audit(DeleteBlockDeviceOperation, getAuditTarget(duplicate), AuditCause{
+			Trigger: AuditTriggerDuplicateMerge,
+			Reason:  fmt.Sprintf("duplicate of blockdevice: %s", canonical.Name),
+		}, err)
+		if err != nil {
+			return fmt.Errorf("unable to delete %s: %v", duplicate.Name, err)
+		}
+		klog.Infof("eventcode=%s msg=%s rname=%v canonical=%s",
+			"ndm.blockdevice.duplicate.merged", "Merged duplicate blockdevice", duplicate.Name, canonical.Name)
+	}
+	return nil
+}
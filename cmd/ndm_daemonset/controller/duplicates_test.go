@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	controllerutil "github.com/openebs/node-disk-manager/pkg/controllers/util"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newDuplicateBlockDevice creates a blockdevice resource of the disk with the given serial,
+// created at the given minute
+func newDuplicateBlockDevice(name, serial string, minute int, claimState apis.DeviceClaimState) apis.BlockDevice {
+	blockDevice := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            map[string]string{KubernetesHostNameLabel: fakeHostName},
+			CreationTimestamp: metav1.NewTime(time.Date(2023, 1, 1, 0, minute, 0, 0, time.UTC)),
+		},
+	}
+	blockDevice.Spec.Details.DeviceType = "disk"
+	blockDevice.Spec.Details.Vendor = "ATA"
+	blockDevice.Spec.Details.Model = "SAMSUNG_MZ7LH960"
+	blockDevice.Spec.Details.Serial = serial
+	blockDevice.Status.ClaimState = claimState
+	blockDevice.Status.State = NDMActive
+	return blockDevice
+}
+
+func TestPickCanonicalBlockDevice(t *testing.T) {
+	inactive := func(blockDevice apis.BlockDevice) apis.BlockDevice {
+		blockDevice.Status.State = NDMInactive
+		return blockDevice
+	}
+	tests := map[string]struct {
+		blockDevices []apis.BlockDevice
+		want         string
+		wantErr      bool
+	}{
+		"no blockdevices": {
+			wantErr: true,
+		},
+		"newest is picked when none is claimed": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceUnclaimed),
+				newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceUnclaimed),
+				newDuplicateBlockDevice("blockdevice-mid", "S1", 2, apis.BlockDeviceUnclaimed),
+			},
+			want: "blockdevice-new",
+		},
+		"claimed is picked over a newer one": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceClaimed),
+				newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceUnclaimed),
+			},
+			want: "blockdevice-old",
+		},
+		"active is picked over a newer inactive one": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceUnclaimed),
+				inactive(newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceUnclaimed)),
+			},
+			want: "blockdevice-old",
+		},
+		"newest is picked when none is active": {
+			blockDevices: []apis.BlockDevice{
+				inactive(newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceUnclaimed)),
+				inactive(newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceUnclaimed)),
+			},
+			want: "blockdevice-new",
+		},
+		"released is not considered claimed": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceReleased),
+				newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceUnclaimed),
+			},
+			want: "blockdevice-new",
+		},
+		"same creation time is decided by the name": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-b", "S1", 1, apis.BlockDeviceUnclaimed),
+				newDuplicateBlockDevice("blockdevice-a", "S1", 1, apis.BlockDeviceUnclaimed),
+			},
+			want: "blockdevice-b",
+		},
+		"more than one claimed is refused": {
+			blockDevices: []apis.BlockDevice{
+				newDuplicateBlockDevice("blockdevice-old", "S1", 1, apis.BlockDeviceClaimed),
+				newDuplicateBlockDevice("blockdevice-new", "S1", 3, apis.BlockDeviceClaimed),
+			},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := PickCanonicalBlockDevice(test.blockDevices)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got.Name)
+		})
+	}
+}
+
+func TestGetDuplicateGroups(t *testing.T) {
+	partition := func(name, guid string) apis.BlockDevice {
+		blockDevice := newDuplicateBlockDevice(name, "S1", 1, apis.BlockDeviceUnclaimed)
+		blockDevice.Spec.Details.DeviceType = "partition"
+		blockDevice.Spec.PartitionInfo = &apis.DevicePartitionInfo{PartitionGUID: guid}
+		return blockDevice
+	}
+	sparse := newDuplicateBlockDevice("sparse-1", "", 1, apis.BlockDeviceUnclaimed)
+	sparse.Spec.Details.DeviceType = "sparse"
+	otherNode := newDuplicateBlockDevice("blockdevice-9", "S2", 2, apis.BlockDeviceUnclaimed)
+	otherNode.Labels[KubernetesHostNameLabel] = "other-host"
+	noNode := newDuplicateBlockDevice("blockdevice-10", "S2", 3, apis.BlockDeviceUnclaimed)
+	delete(noNode.Labels, KubernetesHostNameLabel)
+
+	groups := GetDuplicateGroups([]apis.BlockDevice{
+		newDuplicateBlockDevice("blockdevice-1", "S1", 1, apis.BlockDeviceUnclaimed),
+		newDuplicateBlockDevice("blockdevice-2", "S2", 1, apis.BlockDeviceUnclaimed),
+		newDuplicateBlockDevice("blockdevice-3", "S1", 2, apis.BlockDeviceUnclaimed),
+		newDuplicateBlockDevice("blockdevice-4", "", 1, apis.BlockDeviceUnclaimed),
+		newDuplicateBlockDevice("blockdevice-5", "", 2, apis.BlockDeviceUnclaimed),
+		partition("blockdevice-6", "6E1C2D4A-9B3F-4E6D-8A21-5C0F9D3B1E47"),
+		partition("blockdevice-7", "6e1c2d4a-9b3f-4e6d-8a21-5c0f9d3b1e47"),
+		partition("blockdevice-8", ""),
+		sparse,
+		sparse,
+		otherNode,
+		noNode,
+	})
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, fakeHostName+"/disk/ATA/SAMSUNG_MZ7LH960/S1", groups[0].Identifier)
+		assert.Len(t, groups[0].BlockDevices, 2)
+		assert.Equal(t, fakeHostName+"/partition/6e1c2d4a-9b3f-4e6d-8a21-5c0f9d3b1e47", groups[1].Identifier)
+		assert.Len(t, groups[1].BlockDevices, 2)
+	}
+}
+
+func TestMergeDuplicateBlockDevices(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{}, &apis.BlockDeviceClaimList{})
+
+	claimRef := &v1.ObjectReference{Kind: "BlockDeviceClaim", Name: "bdc-1"}
+	released := newDuplicateBlockDevice("blockdevice-released", "S1", 1, apis.BlockDeviceReleased)
+	released.Spec.ClaimRef = claimRef
+	released.Finalizers = []string{controllerutil.BlockDeviceFinalizer}
+	newest := newDuplicateBlockDevice("blockdevice-newest", "S1", 2, apis.BlockDeviceUnclaimed)
+	claimedA := newDuplicateBlockDevice("blockdevice-claimed-a", "S2", 1, apis.BlockDeviceClaimed)
+	claimedB := newDuplicateBlockDevice("blockdevice-claimed-b", "S2", 2, apis.BlockDeviceClaimed)
+	claim := &apis.BlockDeviceClaim{ObjectMeta: metav1.ObjectMeta{Name: "bdc-1"}}
+	claim.Spec.BlockDeviceName = released.Name
+
+	for _, apply := range []bool{false, true} {
+		cl := CreateFakeClient(t)
+		for _, blockDevice := range []apis.BlockDevice{released, newest, claimedA, claimedB} {
+			blockDevice := blockDevice
+			assert.NoError(t, cl.Create(context.TODO(), &blockDevice))
+		}
+		assert.NoError(t, cl.Create(context.TODO(), claim.DeepCopy()))
+		c := &Controller{Clientset: cl}
+
+		out := &bytes.Buffer{}
+		err := c.MergeDuplicateBlockDevices(out, apply)
+		// the group with two claimed blockdevices is never merged
+		assert.Error(t, err)
+		assert.Contains(t, out.String(), "keep blockdevice-newest, merge blockdevice-released")
+		assert.Contains(t, out.String(), "refusing to merge")
+
+		for _, name := range []string{claimedA.Name, claimedB.Name} {
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: name}, &apis.BlockDevice{}))
+		}
+		gotReleased := &apis.BlockDevice{}
+		err = cl.Get(context.TODO(), client.ObjectKey{Name: released.Name}, gotReleased)
+		gotNewest := &apis.BlockDevice{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: newest.Name}, gotNewest))
+		gotClaim := &apis.BlockDeviceClaim{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: claim.Name}, gotClaim))
+		if !apply {
+			assert.NoError(t, err, "nothing is deleted without apply")
+			assert.Nil(t, gotNewest.Spec.ClaimRef)
+			assert.Equal(t, released.Name, gotClaim.Spec.BlockDeviceName)
+			continue
+		}
+		// the duplicate is deleted, and its claim is moved to the canonical blockdevice
+		assert.True(t, errors.IsNotFound(err))
+		assert.Equal(t, claimRef, gotNewest.Spec.ClaimRef)
+		assert.Equal(t, apis.BlockDeviceReleased, gotNewest.Status.ClaimState)
+		assert.Equal(t, newest.Name, gotClaim.Spec.BlockDeviceName)
+	}
+}
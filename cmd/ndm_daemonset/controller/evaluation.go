@@ -0,0 +1,189 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CreateBlockDeviceOperation is creating a BlockDevice resource
+	CreateBlockDeviceOperation = "create-blockdevice"
+	// UpdateBlockDeviceOperation is updating a BlockDevice resource
+	UpdateBlockDeviceOperation = "update-blockdevice"
+	// QuarantineBlockDeviceOperation is creating / updating a BlockDevice resource in
+	// the Quarantined state
+	QuarantineBlockDeviceOperation = "quarantine-blockdevice"
+)
+
+// Evaluation records the operations that NDM would have performed on the devices and the
+// BlockDevice resources, when the controller is in the read-only evaluation mode. No
+// changes are made to the disks or the resources in this mode.
+type Evaluation struct {
+	mutex sync.Mutex
+	// operations are the recorded operations, keyed by the device path or the name of
+	// the resource on which the operation would have been performed
+	operations map[string][]string
+}
+
+// StartEvaluation puts the controller in the read-only evaluation mode. The writes of
+// BlockDevice resources and the destructive operations on the disks are recorded in the
+// returned Evaluation, instead of being performed. The reads are served by the API server.
+func (c *Controller) StartEvaluation() *Evaluation {
+	c.Evaluation = &Evaluation{
+		operations: make(map[string][]string),
+	}
+	c.Clientset = &evaluationClient{
+		Client:     c.Clientset,
+		evaluation: c.Evaluation,
+	}
+	// events are also writes to the API server
+	c.Recorder = nil
+	return c.Evaluation
+}
+
+// NewEvaluationController creates a controller in the read-only evaluation mode, having
+// the configuration, the filters and the probes of this controller. The hierarchy cache
+// is a copy of the cache of this controller, so that the evaluation does not modify it.
+// The publishers, the metrics and the periodic scans are not copied, as the evaluation
+// should not have any side effects.
+func (c *Controller) NewEvaluationController() *Controller {
+	c.Mutex.Lock()
+	filters := append([]*Filter(nil), c.Filters...)
+	probes := append([]*Probe(nil), c.Probes...)
+	c.Mutex.Unlock()
+
+	ec := &Controller{
+		config:                  c.config,
+		Namespace:               c.Namespace,
+		Clientset:               c.Clientset,
+		NDMConfig:               c.NDMConfig,
+		Mutex:                   &sync.Mutex{},
+		Filters:                 filters,
+		Probes:                  probes,
+		NodeAttributes:          c.NodeAttributes,
+		BDHierarchy:             blockdevice.NewHierarchyCache(c.BDHierarchy.Snapshot()),
+		SafeMode:                c.SafeMode,
+		RemovableDevicePolicy:   c.RemovableDevicePolicy,
+		LoopDevicePolicy:        c.LoopDevicePolicy,
+		PartitionReclaimPolicy:  c.PartitionReclaimPolicy,
+		PartitionedParentPolicy: c.PartitionedParentPolicy,
+		PartitionFabricDevices:  c.PartitionFabricDevices,
+		ProbeTimeout:            c.ProbeTimeout,
+		PartitionName:           c.PartitionName,
+		InternalErrorPolicy:     c.InternalErrorPolicy,
+		RemovalPolicy:           c.RemovalPolicy,
+		ProtectionLevel:         c.ProtectionLevel,
+		ReidentifyDevices:       c.ReidentifyDevices,
+		DeviceReadyTimeout:      c.DeviceReadyTimeout,
+		DiscoverOnly:            c.DiscoverOnly,
+		ContentFingerprintKiB:   c.ContentFingerprintKiB,
+		InstanceID:              c.InstanceID,
+		DiscardBeforePartition:  c.DiscardBeforePartition,
+		DisableWriteCache:       c.DisableWriteCache,
+		UUIDVersion:             c.UUIDVersion,
+		UUIDNamespace:           c.UUIDNamespace,
+		UUIDBasisAnnotation:     c.UUIDBasisAnnotation,
+		UdevAnnotations:         c.UdevAnnotations,
+		DecisionTrace:           c.DecisionTrace,
+		MultiSignaturePolicy:    c.MultiSignaturePolicy,
+		IOErrors:                c.IOErrors,
+		WritabilityCheck:        c.WritabilityCheck,
+		VerifyPartitionBlank:    c.VerifyPartitionBlank,
+		provisioningDone:        c.provisioningDone,
+	}
+	ec.StartEvaluation()
+	return ec
+}
+
+// record records the operation that would have been performed on the target
+func (e *Evaluation) record(target, op string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.operations[target] = append(e.operations[target], op)
+}
+
+// Operations returns the operations recorded for the targets, in the order in which they
+// would have been performed on each target
+func (e *Evaluation) Operations(targets ...string) []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	operations := make([]string, 0)
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		operations = append(operations, e.operations[target]...)
+	}
+	return operations
+}
+
+// evaluationClient is a client that records the writes of BlockDevice resources in the
+// evaluation, while the reads are performed using the underlying client
+type evaluationClient struct {
+	client.Client
+	evaluation *Evaluation
+}
+
+func (ec *evaluationClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	op := CreateBlockDeviceOperation
+	if isQuarantined(obj) {
+		op = QuarantineBlockDeviceOperation
+	}
+	ec.evaluation.record(getEvaluationTarget(obj), op)
+	return nil
+}
+
+func (ec *evaluationClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	op := UpdateBlockDeviceOperation
+	if isQuarantined(obj) {
+		op = QuarantineBlockDeviceOperation
+	}
+	ec.evaluation.record(getEvaluationTarget(obj), op)
+	return nil
+}
+
+func (ec *evaluationClient) Patch(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	ec.evaluation.record(getEvaluationTarget(obj), UpdateBlockDeviceOperation)
+	return nil
+}
+
+func (ec *evaluationClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	ec.evaluation.record(getEvaluationTarget(obj), string(DeleteBlockDeviceOperation))
+	return nil
+}
+
+// getEvaluationTarget gets the target of an operation on the object. The path of the
+// device is used for BlockDevice resources having the path, and the name otherwise.
+func getEvaluationTarget(obj client.Object) string {
+	if bd, ok := obj.(*apis.BlockDevice); ok && bd.Spec.Path != "" {
+		return bd.Spec.Path
+	}
+	return obj.GetName()
+}
+
+// isQuarantined checks if the object is a BlockDevice resource in the Quarantined state
+func isQuarantined(obj client.Object) bool {
+	bd, ok := obj.(*apis.BlockDevice)
+	return ok && bd.Status.State == apis.BlockDeviceQuarantined
+}
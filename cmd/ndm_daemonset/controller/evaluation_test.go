@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestEvaluation(t *testing.T) {
+	fakeNdmClient := CreateFakeClient(t)
+	nodeAttributes := make(map[string]string, 0)
+	nodeAttributes[HostNameKey] = fakeHostName
+	fakeController := &Controller{
+		NodeAttributes: nodeAttributes,
+		Clientset:      fakeNdmClient,
+	}
+
+	// an existing resource, that is read during the evaluation
+	existing := mockEmptyDeviceCr()
+	existing.Name = "blockdevice-existing"
+	existing.Spec.Path = "/dev/sdb"
+	err := fakeController.CreateBlockDevice(existing)
+	assert.NoError(t, err)
+
+	evaluation := fakeController.StartEvaluation()
+
+	dr := mockEmptyDeviceCr()
+	dr.Spec.Path = "/dev/sda"
+	err = fakeController.CreateBlockDevice(dr)
+	assert.NoError(t, err)
+	assert.False(t, fakeController.IsDestructiveOperationAllowed(CreatePartitionOperation, "/dev/sdc"))
+
+	existing.Spec.Capacity.Storage = 1024
+	err = fakeController.UpdateBlockDevice(existing, nil)
+	assert.NoError(t, err)
+	existingBD, err := fakeController.GetBlockDevice(existing.Name)
+	assert.NoError(t, err)
+	fakeController.DeactivateBlockDevice(*existingBD, AuditCause{})
+
+	quarantined := mockEmptyDeviceCr()
+	quarantined.Spec.Path = "/dev/sdd"
+	quarantined.Status.State = apis.BlockDeviceQuarantined
+	err = fakeController.CreateBlockDevice(quarantined)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{CreateBlockDeviceOperation}, evaluation.Operations("/dev/sda"))
+	assert.Equal(t, []string{UpdateBlockDeviceOperation, string(DeactivateBlockDeviceOperation)},
+		evaluation.Operations("/dev/sdb", existing.Name))
+	assert.Equal(t, []string{string(CreatePartitionOperation)}, evaluation.Operations("/dev/sdc"))
+	assert.Equal(t, []string{QuarantineBlockDeviceOperation}, evaluation.Operations("/dev/sdd"))
+
+	// nothing is written to the API server
+	_, err = fakeController.GetBlockDevice(dr.Name)
+	assert.True(t, errors.IsNotFound(err))
+	existingBD, err = fakeController.GetBlockDevice(existing.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), existingBD.Spec.Capacity.Storage)
+	assert.Equal(t, apis.BlockDeviceActive, existingBD.Status.State)
+}
+
+func TestEvaluationInSafeMode(t *testing.T) {
+	c := &Controller{SafeMode: true}
+	evaluation := c.StartEvaluation()
+	assert.False(t, c.IsDestructiveOperationAllowed(CreatePartitionOperation, "/dev/sda"))
+	// the operation is blocked by the safe mode, and would not be performed
+	assert.Empty(t, evaluation.Operations("/dev/sda"))
+}
+
+func TestNewEvaluationController(t *testing.T) {
+	c := &Controller{
+		Clientset:    CreateFakeClient(t),
+		Mutex:        &sync.Mutex{},
+		Filters:      []*Filter{{Name: "os disk exclude filter"}},
+		Probes:       []*Probe{{Name: "udev probe"}},
+		BDHierarchy:  blockdevice.NewHierarchyCache(nil),
+		DiscoverOnly: true,
+		UUIDVersion:  UUIDVersionV2,
+	}
+	c.BDHierarchy.Set("/dev/sda", blockdevice.BlockDevice{})
+
+	ec := c.NewEvaluationController()
+	assert.NotNil(t, ec.Evaluation)
+	assert.Nil(t, c.Evaluation)
+	assert.Equal(t, c.Filters, ec.Filters)
+	assert.Equal(t, c.Probes, ec.Probes)
+	assert.True(t, ec.DiscoverOnly)
+	assert.Equal(t, UUIDVersionV2, ec.UUIDVersion)
+
+	// the cache of the evaluation is a copy
+	ec.BDHierarchy.Set("/dev/sdb", blockdevice.BlockDevice{})
+	_, ok := ec.BDHierarchy.Get("/dev/sda")
+	assert.True(t, ok)
+	_, ok = c.BDHierarchy.Get("/dev/sdb")
+	assert.False(t, ok)
+
+	// the writes are recorded in the evaluation
+	dr := mockEmptyDeviceCr()
+	dr.Spec.Path = "/dev/sdb"
+	assert.NoError(t, ec.CreateBlockDevice(dr))
+	assert.Equal(t, []string{CreateBlockDeviceOperation}, ec.Evaluation.Operations("/dev/sdb"))
+	_, err := c.GetBlockDevice(dr.Name)
+	assert.True(t, errors.IsNotFound(err))
+}
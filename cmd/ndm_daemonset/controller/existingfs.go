@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NDMExistingFSKey is the label having the type of the filesystem already present
+	// on the device, so that consumers can select devices which can be reused without
+	// formatting
+	NDMExistingFSKey = NDMLabelPrefix + "existing-fs"
+
+	// NDMExistingFSUUIDKey is the label having the uuid of the filesystem already
+	// present on the device
+	NDMExistingFSUUIDKey = NDMLabelPrefix + "existing-fs-uuid"
+)
+
+// existingFSLabels are the labels derived from the filesystem on the device. These
+// labels are removed from the resource once the filesystem is removed from the device.
+var existingFSLabels = []string{
+	NDMExistingFSKey,
+	NDMExistingFSUUIDKey,
+}
+
+// getExistingFSLabels gets the labels for the filesystem present on the device. Values
+// which are not valid label values are skipped.
+func getExistingFSLabels(blockDevice *bd.BlockDevice) map[string]string {
+	labels := make(map[string]string)
+	values := map[string]string{
+		NDMExistingFSKey:     blockDevice.FSInfo.FileSystem,
+		NDMExistingFSUUIDKey: blockDevice.FSInfo.FileSystemUUID,
+	}
+	for key, value := range values {
+		if len(value) == 0 {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			klog.V(4).Infof("device: %s, not adding label %s=%s: %v",
+				blockDevice.DevPath, key, value, errs)
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetExistingFSLabels(t *testing.T) {
+	tests := map[string]struct {
+		fsInfo bd.FileSystemInformation
+		want   map[string]string
+	}{
+		"device without a filesystem": {
+			fsInfo: bd.FileSystemInformation{},
+			want:   map[string]string{},
+		},
+		"device with ext4 filesystem": {
+			fsInfo: bd.FileSystemInformation{
+				FileSystem:     "ext4",
+				FileSystemUUID: "7e7f160b-0e79-478b-b006-1ebc6d0050dd",
+			},
+			want: map[string]string{
+				NDMExistingFSKey:     "ext4",
+				NDMExistingFSUUIDKey: "7e7f160b-0e79-478b-b006-1ebc6d0050dd",
+			},
+		},
+		"filesystem uuid that is not a valid label value is skipped": {
+			fsInfo: bd.FileSystemInformation{
+				FileSystem:     "xfs",
+				FileSystemUUID: "invalid uuid",
+			},
+			want: map[string]string{
+				NDMExistingFSKey: "xfs",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{DevPath: "/dev/sda"},
+				FSInfo:     test.fsInfo,
+			}
+			assert.Equal(t, test.want, getExistingFSLabels(blockDevice))
+		})
+	}
+}
+
+func TestMergeMetadataRemovesExistingFSLabels(t *testing.T) {
+	oldMetadata := metav1.ObjectMeta{
+		Labels: map[string]string{
+			NDMDeviceTypeKey:     NDMDefaultDeviceType,
+			NDMExistingFSKey:     "ext4",
+			NDMExistingFSUUIDKey: "7e7f160b-0e79-478b-b006-1ebc6d0050dd",
+			"custom-label":       "value",
+		},
+	}
+	newMetadata := metav1.ObjectMeta{
+		Labels: map[string]string{
+			NDMDeviceTypeKey: NDMDefaultDeviceType,
+		},
+	}
+	want := map[string]string{
+		NDMDeviceTypeKey: NDMDefaultDeviceType,
+		"custom-label":   "value",
+	}
+	assert.Equal(t, want, mergeMetadata(newMetadata, oldMetadata).Labels)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// IsFilesystemReclaimAllowed checks if the filesystem on the device can be wiped, i.e the
+// device is explicitly listed for filesystem reclaim in the config. Devices are matched only
+// by their stable identifiers, so that the reclaim is never applied to any other device.
+func (c *Controller) IsFilesystemReclaimAllowed(bd blockdevice.BlockDevice) bool {
+	if c.NDMConfig == nil {
+		return false
+	}
+	for _, device := range c.NDMConfig.FilesystemReclaimConfigs {
+		if matchesStableIdentifiers(device.WWN, device.Serial, bd) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordFilesystemReclaim logs and records a warning event on the node, for the filesystem
+// on the device that is about to be wiped
+func (c *Controller) RecordFilesystemReclaim(bd blockdevice.BlockDevice) {
+	klog.Warningf("eventcode=%s msg=%s filesystem=%s fsuuid=%s wwn=%s serial=%s rname=%v",
+		"ndm.blockdevice.filesystem.reclaim", "Wiping filesystem of device listed for reclaim",
+		bd.FSInfo.FileSystem, bd.FSInfo.FileSystemUUID, bd.DeviceAttributes.WWN,
+		bd.DeviceAttributes.Serial, bd.DevPath)
+	c.recordNodeEvent(v1.EventTypeWarning, "FilesystemReclaim",
+		"Wiping %s filesystem (uuid: %s) of device %s (wwn: %s, serial: %s) listed for reclaim",
+		bd.FSInfo.FileSystem, bd.FSInfo.FileSystemUUID, bd.DevPath,
+		bd.DeviceAttributes.WWN, bd.DeviceAttributes.Serial)
+}
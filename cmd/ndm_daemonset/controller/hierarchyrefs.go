@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getHierarchyReferences gets the names of the blockdevices of the parent disk and of the
+// partitions of the device, so that the hierarchy can be navigated without re-probing.
+// The devices are resolved using the hierarchy cache. A relative whose blockdevice does not
+// exist yet is left out, and is filled the next time the device is written.
+func (c *Controller) getHierarchyReferences(di *DeviceInfo) (string, []string) {
+	if c.BDHierarchy == nil || c.Clientset == nil {
+		return "", nil
+	}
+
+	var parent string
+	if di.ParentPath != "" {
+		parent, _ = c.getBlockDeviceNameOfDevice(di.ParentPath)
+	}
+	var children []string
+	for _, partitionPath := range di.PartitionPaths {
+		if name, ok := c.getBlockDeviceNameOfDevice(partitionPath); ok {
+			children = append(children, name)
+		}
+	}
+	return parent, children
+}
+
+// getBlockDeviceNameOfDevice gets the name of the blockdevice of the device from the uuid
+// in the hierarchy cache. false is returned if the uuid of the device has not been
+// generated, or the blockdevice does not exist.
+func (c *Controller) getBlockDeviceNameOfDevice(devPath string) (string, bool) {
+	cachedBD, ok := c.BDHierarchy.Get(devPath)
+	if !ok || cachedBD.UUID == "" {
+		return "", false
+	}
+	name := cachedBD.UUID
+	err := c.Clientset.Get(context.TODO(),
+		client.ObjectKey{Namespace: c.Namespace, Name: name}, &apis.BlockDevice{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("unable to get blockdevice: %s of device: %s, %v", name, devPath, err)
+		}
+		return "", false
+	}
+	return name, true
+}
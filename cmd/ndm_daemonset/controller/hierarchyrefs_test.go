@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushBlockDeviceResourceHierarchyReferences(t *testing.T) {
+	parent := bd.BlockDevice{
+		Identifier: bd.Identifier{
+			UUID:    "blockdevice-parent",
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: bd.DeviceAttribute{
+			DeviceType: bd.BlockDeviceTypeDisk,
+		},
+		DependentDevices: bd.DependentBlockDevices{
+			Partitions: []string{"/dev/sda1", "/dev/sda2"},
+		},
+	}
+	newPartition := func(uuid, devPath string) bd.BlockDevice {
+		return bd.BlockDevice{
+			Identifier: bd.Identifier{
+				UUID:    uuid,
+				DevPath: devPath,
+			},
+			DeviceAttributes: bd.DeviceAttribute{
+				DeviceType: bd.BlockDeviceTypePartition,
+			},
+			DependentDevices: bd.DependentBlockDevices{
+				Parent: "/dev/sda",
+			},
+		}
+	}
+	firstPartition := newPartition("blockdevice-part1", "/dev/sda1")
+	secondPartition := newPartition("blockdevice-part2", "/dev/sda2")
+
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+		BDHierarchy: bd.NewHierarchyCache(bd.Hierarchy{
+			parent.DevPath:          parent,
+			firstPartition.DevPath:  firstPartition,
+			secondPartition.DevPath: secondPartition,
+		}),
+	}
+	push := func(device bd.BlockDevice) apis.BlockDevice {
+		existing, err := fakeController.GetBlockDevice(device.UUID)
+		if err != nil {
+			existing = nil
+		}
+		deviceInfo := fakeController.NewDeviceInfoFromBlockDevice(&device)
+		if err := fakeController.PushBlockDeviceResource(existing, deviceInfo); err != nil {
+			t.Fatal(err)
+		}
+		got, err := fakeController.GetBlockDevice(device.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *got
+	}
+
+	// the resource of the parent does not exist yet, the reference is left empty
+	got := push(firstPartition)
+	assert.Empty(t, got.Status.Parent)
+	assert.Empty(t, got.Status.Children)
+	push(secondPartition)
+
+	got = push(parent)
+	assert.Empty(t, got.Status.Parent)
+	assert.Equal(t, []string{"blockdevice-part1", "blockdevice-part2"}, got.Status.Children)
+
+	// the reference is filled the next time the partition is written
+	got = push(firstPartition)
+	assert.Equal(t, "blockdevice-parent", got.Status.Parent)
+	assert.Empty(t, got.Status.Children)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// InternalErrorPolicy defines how the non-fatal internal errors, like reaching an
+// unreachable state or not finding the parent of a device, are handled while processing
+// a device
+type InternalErrorPolicy string
+
+const (
+	// ReportInternalErrors skips the device, increments the internal error counter and
+	// records a warning event on the node. The error is not propagated, so that the
+	// batch is not aborted and no rescan is triggered.
+	ReportInternalErrors InternalErrorPolicy = "report"
+
+	// PropagateInternalErrors returns the error like any other error, marking the device
+	// as errored and triggering a rescan. Useful for debugging.
+	PropagateInternalErrors InternalErrorPolicy = "propagate"
+
+	// DefaultInternalErrorPolicy is the policy used if none is specified
+	DefaultInternalErrorPolicy = ReportInternalErrors
+)
+
+// ParseInternalErrorPolicy validates and returns the internal error policy.
+// Empty value is treated as the default policy.
+func ParseInternalErrorPolicy(policy string) (InternalErrorPolicy, error) {
+	switch InternalErrorPolicy(policy) {
+	case "":
+		return DefaultInternalErrorPolicy, nil
+	case ReportInternalErrors, PropagateInternalErrors:
+		return InternalErrorPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid internal error policy: %q, should be one of %s, %s",
+		policy, ReportInternalErrors, PropagateInternalErrors)
+}
+
+// ReportInternalError reports an internal error that occurred while processing the device,
+// by incrementing the internal error counter and recording a warning event with the given
+// reason on the node.
+func (c *Controller) ReportInternalError(devPath, reason string, err error) {
+	klog.Warningf("eventcode=%s msg=%s reason=%s rname=%v err=%v",
+		"ndm.internal.error", "Skipping device due to internal error",
+		reason, devPath, err)
+
+	if c.Metrics != nil {
+		c.Metrics.IncInternalErrorCounter(reason)
+	}
+	c.recordNodeEvent(v1.EventTypeWarning, reason,
+		"Skipped processing of device %s due to internal error: %v", devPath, err)
+}
+
+// recordNodeEvent records an event on the node, for the devices that may not have a
+// blockdevice resource
+func (c *Controller) recordNodeEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if c.Recorder == nil {
+		return
+	}
+	nodeName := c.NodeAttributes[NodeNameKey]
+	// the uid of the node is set to the node name, as done by the kubelet, so that
+	// the event is listed when describing the node
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nodeName,
+			UID:  types.UID(nodeName),
+		},
+	}
+	c.Recorder.Eventf(node, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/pkg/metrics/daemon"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestParseInternalErrorPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    InternalErrorPolicy
+		wantErr bool
+	}{
+		"empty policy":     {policy: "", want: ReportInternalErrors, wantErr: false},
+		"report policy":    {policy: "report", want: ReportInternalErrors, wantErr: false},
+		"propagate policy": {policy: "propagate", want: PropagateInternalErrors, wantErr: false},
+		"invalid policy":   {policy: "ignore", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseInternalErrorPolicy(tt.policy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReportInternalError(t *testing.T) {
+	tests := map[string]struct {
+		metrics    *daemon.Metrics
+		recorder   *record.FakeRecorder
+		wantEvents []string
+	}{
+		"error is recorded as an event": {
+			metrics:  daemon.NewMetrics(),
+			recorder: record.NewFakeRecorder(1),
+			wantEvents: []string{
+				"Warning ParentNotFound Skipped processing of device /dev/sda1 due to internal error: cannot get parent device",
+			},
+		},
+		"metrics and events disabled": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NodeAttributes: map[string]string{NodeNameKey: "node-1"},
+				Metrics:        tt.metrics,
+			}
+			if tt.recorder != nil {
+				c.Recorder = tt.recorder
+			}
+			c.ReportInternalError("/dev/sda1", "ParentNotFound", fmt.Errorf("cannot get parent device"))
+
+			if tt.recorder == nil {
+				return
+			}
+			close(tt.recorder.Events)
+			events := make([]string, 0)
+			for event := range tt.recorder.Events {
+				events = append(events, event)
+			}
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ioErrorsReason is the reason of the ErrorState condition when the device reported
+	// more IO errors than the threshold within the window
+	ioErrorsReason = "IOErrors"
+	// ioErrorsNormalReason is the reason of the ErrorState condition when the IO errors
+	// of the device are below the threshold
+	ioErrorsNormalReason = "IOErrorsNormal"
+)
+
+// ioErrorSample is the IO error count of a device read at a point in time
+type ioErrorSample struct {
+	at    time.Time
+	count uint64
+}
+
+// IOErrorTracker tracks the IO error counts of the devices, to find the devices that
+// reported more IO errors than the threshold within the window. The error count of a
+// device is cumulative since it was attached, so the errors within the window are the
+// increase of the count over the window.
+type IOErrorTracker struct {
+	mutex     sync.Mutex
+	threshold uint64
+	window    time.Duration
+	// samples are the error counts of each device read within the window, along with the
+	// last count read before the window, keyed by the path of the device
+	samples map[string][]ioErrorSample
+	// failing is the set of the paths of the devices in the error state
+	failing map[string]bool
+	// now gets the current time, it can be replaced in tests
+	now func() time.Time
+}
+
+// NewIOErrorTracker creates a tracker that puts the devices with threshold or more IO
+// errors within the window in the error state
+func NewIOErrorTracker(threshold uint64, window time.Duration) *IOErrorTracker {
+	return &IOErrorTracker{
+		threshold: threshold,
+		window:    window,
+		samples:   make(map[string][]ioErrorSample),
+		failing:   make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+// Record records the IO error count read for the device, and returns the IO error state
+// of the device
+func (t *IOErrorTracker) Record(devPath string, count uint64) bd.IOErrorState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	samples := t.samples[devPath]
+	// the count is reset when the device is attached again
+	if len(samples) > 0 && count < samples[len(samples)-1].count {
+		samples = nil
+	}
+	samples = append(samples, ioErrorSample{at: now, count: count})
+	// the last sample before the window is retained as the baseline of the window
+	windowStart := now.Add(-t.window)
+	for len(samples) > 1 && !samples[1].at.After(windowStart) {
+		samples = samples[1:]
+	}
+	t.samples[devPath] = samples
+
+	state := bd.IOErrorState{
+		Count:       count,
+		RecentCount: count - samples[0].count,
+		Window:      t.window,
+	}
+	state.Failing = state.RecentCount >= t.threshold
+	t.failing[devPath] = state.Failing
+	return state
+}
+
+// IsFailing checks if the device is in the error state, as of the last IO error count
+// recorded for it
+func (t *IOErrorTracker) IsFailing(devPath string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.failing[devPath]
+}
+
+// isIOErrorFailing checks if the device at the path is in the IO error state. The IO
+// error count is read only for disks, so a partition is resolved to its parent disk
+// from the hierarchy before the lookup.
+func (c *Controller) isIOErrorFailing(devPath string) bool {
+	if c.BDHierarchy != nil {
+		if device, ok := c.BDHierarchy.Get(devPath); ok &&
+			device.DeviceAttributes.DeviceType == bd.BlockDeviceTypePartition &&
+			device.DependentDevices.Parent != "" {
+			devPath = device.DependentDevices.Parent
+		}
+	}
+	return c.IOErrors.IsFailing(devPath)
+}
+
+// getErrorStateCondition gets the ErrorState condition of the device from its IO errors.
+// false is returned if the IO errors of the device are not tracked.
+func getErrorStateCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if di.IOErrors == nil {
+		return apis.BlockDeviceCondition{}, false
+	}
+	ioErrors := *di.IOErrors
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceErrorState,
+		LastTransitionTime: metav1.Now(),
+	}
+	if ioErrors.Failing {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = ioErrorsReason
+		condition.Message = fmt.Sprintf("device reported %d io errors in the last %v, %d since attached",
+			ioErrors.RecentCount, ioErrors.Window, ioErrors.Count)
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = ioErrorsNormalReason
+		condition.Message = fmt.Sprintf("device reported %d io errors in the last %v",
+			ioErrors.RecentCount, ioErrors.Window)
+	}
+	return condition, true
+}
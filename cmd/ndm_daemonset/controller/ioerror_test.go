@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIOErrorTrackerRecord(t *testing.T) {
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	type sample struct {
+		after time.Duration
+		count uint64
+	}
+	tests := map[string]struct {
+		samples     []sample
+		wantRecent  uint64
+		wantFailing bool
+	}{
+		"first read of a device with old errors": {
+			samples:     []sample{{0, 500}},
+			wantRecent:  0,
+			wantFailing: false,
+		},
+		"errors within the window below threshold": {
+			samples:     []sample{{0, 10}, {time.Minute, 30}},
+			wantRecent:  20,
+			wantFailing: false,
+		},
+		"errors within the window at threshold": {
+			samples:     []sample{{0, 10}, {time.Minute, 40}, {2 * time.Minute, 60}},
+			wantRecent:  50,
+			wantFailing: true,
+		},
+		"errors spread beyond the window": {
+			samples:     []sample{{0, 10}, {8 * time.Minute, 40}, {20 * time.Minute, 70}},
+			wantRecent:  30,
+			wantFailing: false,
+		},
+		"error count reset on reattach": {
+			samples:     []sample{{0, 10}, {time.Minute, 100}, {2 * time.Minute, 3}},
+			wantRecent:  0,
+			wantFailing: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracker := NewIOErrorTracker(50, 10*time.Minute)
+			var got blockdevice.IOErrorState
+			for _, s := range tt.samples {
+				tracker.now = func() time.Time { return start.Add(s.after) }
+				got = tracker.Record("/dev/sda", s.count)
+			}
+			assert.Equal(t, tt.samples[len(tt.samples)-1].count, got.Count)
+			assert.Equal(t, tt.wantRecent, got.RecentCount)
+			assert.Equal(t, tt.wantFailing, got.Failing)
+			assert.Equal(t, tt.wantFailing, tracker.IsFailing("/dev/sda"))
+			assert.False(t, tracker.IsFailing("/dev/sdb"))
+		})
+	}
+}
+
+func TestErrorStateCondition(t *testing.T) {
+	tests := map[string]struct {
+		ioErrors    *blockdevice.IOErrorState
+		wantOk      bool
+		wantStatus  v1.ConditionStatus
+		wantMessage string
+	}{
+		"io errors not tracked": {},
+		"io errors below threshold": {
+			ioErrors:    &blockdevice.IOErrorState{Count: 12, RecentCount: 2, Window: 10 * time.Minute},
+			wantOk:      true,
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: "device reported 2 io errors in the last 10m0s",
+		},
+		"io errors above threshold": {
+			ioErrors:    &blockdevice.IOErrorState{Count: 120, RecentCount: 80, Window: 10 * time.Minute, Failing: true},
+			wantOk:      true,
+			wantStatus:  v1.ConditionTrue,
+			wantMessage: "device reported 80 io errors in the last 10m0s, 120 since attached",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := blockdevice.BlockDevice{}
+			bd.DevPath = "/dev/sda"
+			bd.UUID = "blockdevice-io-errors"
+			bd.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeDisk
+			bd.DeviceAttributes.IOErrors = test.ioErrors
+			c := &Controller{}
+			di := c.NewDeviceInfoFromBlockDevice(&bd)
+
+			got, ok := getErrorStateCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceErrorState, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+				assert.Equal(t, test.wantMessage, got.Message)
+			}
+
+			bdAPI, err := di.ToDevice(c)
+			assert.NoError(t, err)
+			_, hasCondition := getCondition(bdAPI.Status.Conditions, apis.BlockDeviceErrorState)
+			assert.Equal(t, test.wantOk, hasCondition)
+		})
+	}
+}
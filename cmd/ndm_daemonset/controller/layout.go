@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// layoutViolationReason is the reason of the LayoutViolation condition when the
+	// partition starts in the area reserved at the start of the parent disk
+	layoutViolationReason = "LayoutViolation"
+	// layoutValidReason is the reason of the LayoutViolation condition when the
+	// partition respects the layout of the parent disk
+	layoutValidReason = "LayoutValid"
+)
+
+// getLayoutCondition gets the LayoutViolation condition of a partition from the check of
+// its position on the parent disk. false is returned if the layout was not checked, eg:
+// the device is not a partition or the parent disk was not partitioned by NDM.
+func getLayoutCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if !di.LayoutChecked {
+		return apis.BlockDeviceCondition{}, false
+	}
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceLayoutViolation,
+		LastTransitionTime: metav1.Now(),
+	}
+	if di.LayoutViolation != "" {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = layoutViolationReason
+		condition.Message = di.LayoutViolation
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = layoutValidReason
+		condition.Message = "partition is outside the area reserved on the parent disk"
+	}
+	return condition, true
+}
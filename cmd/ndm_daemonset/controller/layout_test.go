@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetLayoutCondition(t *testing.T) {
+	tests := map[string]struct {
+		layoutChecked   bool
+		layoutViolation string
+		wantOk          bool
+		wantStatus      v1.ConditionStatus
+	}{
+		"layout not checked": {},
+		"valid layout": {
+			layoutChecked: true,
+			wantOk:        true,
+			wantStatus:    v1.ConditionFalse,
+		},
+		"partition in reserved area": {
+			layoutChecked:   true,
+			layoutViolation: "partition starts at offset 524288, inside the reserved area",
+			wantOk:          true,
+			wantStatus:      v1.ConditionTrue,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			di := NewDeviceInfo()
+			di.LayoutChecked = test.layoutChecked
+			di.LayoutViolation = test.layoutViolation
+			got, ok := getLayoutCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceLayoutViolation, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+			}
+		})
+	}
+}
+
+func TestToDeviceLayoutCondition(t *testing.T) {
+	di := NewDeviceInfo()
+	di.UUID = "blockdevice-layout"
+	di.DeviceType = "partition"
+	di.LayoutChecked = true
+	di.LayoutViolation = "partition starts at offset 524288, inside the reserved area"
+	bd, err := di.ToDevice(&Controller{})
+	assert.NoError(t, err)
+	if assert.Len(t, bd.Status.Conditions, 1) {
+		assert.Equal(t, apis.BlockDeviceLayoutViolation, bd.Status.Conditions[0].Type)
+		assert.Equal(t, v1.ConditionTrue, bd.Status.Conditions[0].Status)
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+// DefaultLocalPathBasePaths are the base directories of the local-path provisioner
+// used if none are given in the config, i.e the default path of the rancher
+// local-path provisioner.
+var DefaultLocalPathBasePaths = []string{
+	"/opt/local-path-provisioner",
+}
+
+// GetLocalPathBasePath gets the base directory of the local-path provisioner at or
+// below which any of the given mount points lies.
+func (c *Controller) GetLocalPathBasePath(mountPoints []string) (string, bool) {
+	basePaths := DefaultLocalPathBasePaths
+	if c.NDMConfig != nil && len(c.NDMConfig.LocalPathBasePaths) > 0 {
+		basePaths = c.NDMConfig.LocalPathBasePaths
+	}
+	return getBasePath(basePaths, mountPoints)
+}
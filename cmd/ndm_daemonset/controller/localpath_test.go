@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLocalPathBasePath(t *testing.T) {
+	tests := map[string]struct {
+		basePaths   []string
+		mountPoints []string
+		wantPath    string
+		wantOk      bool
+	}{
+		"mounted at the default base path": {
+			mountPoints: []string{"/opt/local-path-provisioner"},
+			wantPath:    "/opt/local-path-provisioner",
+			wantOk:      true,
+		},
+		"mounted below the default base path": {
+			mountPoints: []string{"/opt/local-path-provisioner/pvc-1234_default_data"},
+			wantPath:    "/opt/local-path-provisioner",
+			wantOk:      true,
+		},
+		"mounted at a configured base path": {
+			basePaths:   []string{"/var/local-path/"},
+			mountPoints: []string{"/mnt/data", "/var/local-path"},
+			wantPath:    "/var/local-path",
+			wantOk:      true,
+		},
+		"default base path is not used if base paths are configured": {
+			basePaths:   []string{"/var/local-path"},
+			mountPoints: []string{"/opt/local-path-provisioner"},
+			wantOk:      false,
+		},
+		"not mounted at a base path": {
+			mountPoints: []string{"/opt/local-path-provisioner-old"},
+			wantOk:      false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{LocalPathBasePaths: tt.basePaths},
+			}
+			gotPath, gotOk := c.GetLocalPathBasePath(tt.mountPoints)
+			assert.Equal(t, tt.wantOk, gotOk)
+			assert.Equal(t, tt.wantPath, gotPath)
+		})
+	}
+}
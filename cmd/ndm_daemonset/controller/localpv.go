@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// localBlockPVIndex is the name of the raw block local PV pinned to this node, keyed by
+// its local path. The PVs are listed at most once per batch of events.
+type localBlockPVIndex struct {
+	mutex sync.Mutex
+	// pvs is nil till the PVs are listed in the batch
+	pvs map[string]string
+}
+
+// ResetLocalBlockPVs resets the index of the raw block local PVs, so that the PVs are
+// listed again when the next device is looked up. It is called at the start of every
+// batch of events.
+func (c *Controller) ResetLocalBlockPVs() {
+	c.localBlockPVs.mutex.Lock()
+	defer c.localBlockPVs.mutex.Unlock()
+	c.localBlockPVs.pvs = nil
+}
+
+// GetLocalBlockPVForDevice returns the name of the raw block (volumeMode: Block)
+// local PersistentVolume whose local path is one of the given device paths and which
+// is pinned to this node. An empty string is returned if no such PV exists.
+func (c *Controller) GetLocalBlockPVForDevice(devPaths []string) (string, error) {
+	c.localBlockPVs.mutex.Lock()
+	defer c.localBlockPVs.mutex.Unlock()
+
+	if c.localBlockPVs.pvs == nil {
+		pvs, err := c.listLocalBlockPVs()
+		if err != nil {
+			return "", err
+		}
+		c.localBlockPVs.pvs = pvs
+	}
+	for _, devPath := range devPaths {
+		if pvName, ok := c.localBlockPVs.pvs[devPath]; ok {
+			return pvName, nil
+		}
+	}
+	return "", nil
+}
+
+// listLocalBlockPVs lists the raw block local PVs pinned to this node, and gets the
+// name of each PV keyed by its local path
+func (c *Controller) listLocalBlockPVs() (map[string]string, error) {
+	pvList := &v1.PersistentVolumeList{}
+	if err := c.Clientset.List(context.TODO(), pvList, &client.ListOptions{}); err != nil {
+		return nil, err
+	}
+
+	hostName := c.NodeAttributes[HostNameKey]
+	pvs := make(map[string]string)
+	for _, pv := range pvList.Items {
+		if pv.Spec.Local == nil ||
+			pv.Spec.VolumeMode == nil ||
+			*pv.Spec.VolumeMode != v1.PersistentVolumeBlock {
+			continue
+		}
+		if isPVOnNode(pv, hostName) {
+			pvs[pv.Spec.Local.Path] = pv.Name
+		}
+	}
+	return pvs, nil
+}
+
+// isPVOnNode checks if the required node affinity of the PV selects the node
+// with the given hostname
+func isPVOnNode(pv v1.PersistentVolume, hostName string) bool {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key != KubernetesHostNameLabel ||
+				expr.Operator != v1.NodeSelectorOpIn {
+				continue
+			}
+			if util.Contains(expr.Values, hostName) {
+				return true
+			}
+		}
+	}
+	return false
+}
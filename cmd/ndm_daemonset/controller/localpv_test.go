@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newFakeLocalPV(name, path, hostName string, volumeMode v1.PersistentVolumeMode) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				Local: &v1.LocalVolumeSource{
+					Path: path,
+				},
+			},
+			VolumeMode: &volumeMode,
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      KubernetesHostNameLabel,
+									Operator: v1.NodeSelectorOpIn,
+									Values:   []string{hostName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGetLocalBlockPVForDevice(t *testing.T) {
+	tests := map[string]struct {
+		pv       *v1.PersistentVolume
+		devPaths []string
+		want     string
+	}{
+		"block PV referencing the device on this node": {
+			pv:       newFakeLocalPV("pv-1", "/dev/sdb", fakeHostName, v1.PersistentVolumeBlock),
+			devPaths: []string{"/dev/sdb"},
+			want:     "pv-1",
+		},
+		"block PV referencing the device by a devlink": {
+			pv:       newFakeLocalPV("pv-1", "/dev/disk/by-id/fake-id", fakeHostName, v1.PersistentVolumeBlock),
+			devPaths: []string{"/dev/sdb", "/dev/disk/by-id/fake-id"},
+			want:     "pv-1",
+		},
+		"block PV referencing the device on another node": {
+			pv:       newFakeLocalPV("pv-1", "/dev/sdb", "other-node", v1.PersistentVolumeBlock),
+			devPaths: []string{"/dev/sdb"},
+			want:     "",
+		},
+		"filesystem PV referencing the device": {
+			pv:       newFakeLocalPV("pv-1", "/dev/sdb", fakeHostName, v1.PersistentVolumeFilesystem),
+			devPaths: []string{"/dev/sdb"},
+			want:     "",
+		},
+		"block PV referencing another device": {
+			pv:       newFakeLocalPV("pv-1", "/dev/sdc", fakeHostName, v1.PersistentVolumeBlock),
+			devPaths: []string{"/dev/sdb"},
+			want:     "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := CreateFakeClient(t)
+			err := fakeClient.Create(context.TODO(), tt.pv)
+			assert.NoError(t, err)
+
+			c := &Controller{
+				Clientset: fakeClient,
+				NodeAttributes: map[string]string{
+					HostNameKey: fakeHostName,
+				},
+			}
+			got, err := c.GetLocalBlockPVForDevice(tt.devPaths)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLocalBlockPVsListedOncePerBatch(t *testing.T) {
+	fakeClient := CreateFakeClient(t)
+	c := &Controller{
+		Clientset: fakeClient,
+		NodeAttributes: map[string]string{
+			HostNameKey: fakeHostName,
+		},
+	}
+	got, err := c.GetLocalBlockPVForDevice([]string{"/dev/sdb"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", got)
+
+	// the PV created in the same batch is not listed again
+	pv := newFakeLocalPV("pv-1", "/dev/sdb", fakeHostName, v1.PersistentVolumeBlock)
+	assert.NoError(t, fakeClient.Create(context.TODO(), pv))
+	got, err = c.GetLocalBlockPVForDevice([]string{"/dev/sdb"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", got)
+
+	// the PVs are listed again in the next batch
+	c.ResetLocalBlockPVs()
+	got, err = c.GetLocalBlockPVForDevice([]string{"/dev/sdb"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pv-1", got)
+}
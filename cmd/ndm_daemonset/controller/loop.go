@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+)
+
+// LoopDevicePolicy defines how NDM handles loop devices, which are mostly backed by
+// image files on developer and CI nodes
+type LoopDevicePolicy string
+
+const (
+	// IgnoreLoopDevices ignores all loop devices. BlockDevice resources will not be
+	// created for them.
+	IgnoreLoopDevices LoopDevicePolicy = "ignore"
+
+	// ManageLoopDevices manages loop devices like any other device. This is useful
+	// for test clusters in which the disks are emulated with loop devices.
+	ManageLoopDevices LoopDevicePolicy = "manage"
+
+	// DefaultLoopDevicePolicy is the policy used if none is specified
+	DefaultLoopDevicePolicy = IgnoreLoopDevices
+
+	// LoopBackingFileAnnotation is the annotation having the file backing the loop
+	// device of the blockdevice
+	LoopBackingFileAnnotation = openEBSLabelPrefix + "loop-backing-file"
+
+	// NDMSparseKey is the label added to BlockDevice resources of loop devices that
+	// are backed by sparse files
+	NDMSparseKey = NDMLabelPrefix + "sparse"
+)
+
+// ParseLoopDevicePolicy validates and returns the loop device policy.
+// Empty value is treated as the default policy.
+func ParseLoopDevicePolicy(policy string) (LoopDevicePolicy, error) {
+	switch LoopDevicePolicy(policy) {
+	case "":
+		return DefaultLoopDevicePolicy, nil
+	case IgnoreLoopDevices, ManageLoopDevices:
+		return LoopDevicePolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid loop device policy: %q, should be one of %s, %s",
+		policy, IgnoreLoopDevices, ManageLoopDevices)
+}
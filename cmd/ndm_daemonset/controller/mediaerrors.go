@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/pkg/surfacescan"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// unreadableSectorsReason is the reason of the MediaErrors condition when the surface
+	// scan found unreadable sectors
+	unreadableSectorsReason = "UnreadableSectors"
+	// surfaceScanPassedReason is the reason of the MediaErrors condition when all the
+	// sectors read by the surface scan were readable
+	surfaceScanPassedReason = "SurfaceScanPassed"
+)
+
+// GetMediaErrorsCondition gets the MediaErrors condition of the device from the result of
+// its surface scan
+func GetMediaErrorsCondition(result surfacescan.Result) apis.BlockDeviceCondition {
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceMediaErrors,
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(result.UnreadableOffsets) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = unreadableSectorsReason
+		condition.Message = fmt.Sprintf("%d of %d samples read by the surface scan were unreadable, first at offset %d",
+			len(result.UnreadableOffsets), result.SamplesRead, result.UnreadableOffsets[0])
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = surfaceScanPassedReason
+		condition.Message = fmt.Sprintf("all %d samples read by the surface scan were readable", result.SamplesRead)
+	}
+	return condition
+}
+
+// SetBlockDeviceCondition sets the condition on the blockdevice resource, keeping its other
+// conditions. An event is recorded if the status of the condition changed.
+func (c *Controller) SetBlockDeviceCondition(blockDevice apis.BlockDevice, condition apis.BlockDeviceCondition) error {
+	if c.skipPeerManagedBlockDevice(blockDevice, "update") {
+		return nil
+	}
+	blockDeviceCopy := blockDevice.DeepCopy()
+	oldConditions := blockDevice.Status.Conditions
+	blockDeviceCopy.Status.Conditions = mergeConditions([]apis.BlockDeviceCondition{condition}, oldConditions)
+	if err := c.Clientset.Update(context.TODO(), blockDeviceCopy); err != nil {
+		klog.Errorf("eventcode=%s msg=%s : %v rname=%v",
+			"ndm.blockdevice.update.failure", "Unable to update condition of blockdevice object",
+			err, blockDeviceCopy.ObjectMeta.Name)
+		return err
+	}
+	c.recordConditionTransitions(blockDeviceCopy, oldConditions)
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+)
+
+// MultiSignaturePolicy defines how devices having multiple filesystem / storage engine
+// signatures are handled, eg: a disk reused across tools having both a stale ext4 and
+// a stale LVM signature. Which of the signatures is in use cannot be known for sure.
+type MultiSignaturePolicy string
+
+const (
+	// QuarantineMultiSignature quarantines the device, it is never partitioned
+	QuarantineMultiSignature MultiSignaturePolicy = "quarantine"
+
+	// PreferRecentSignature takes the filesystem reported by the detection of the device
+	// (udev / blkid) as the most recent signature, and the other signatures as stale
+	// leftovers. The device is quarantined if no filesystem is reported.
+	PreferRecentSignature MultiSignaturePolicy = "prefer-recent"
+
+	// RequireWipeMultiSignature leaves the device alone, without creating a resource,
+	// until the stale signatures are wiped by the admin
+	RequireWipeMultiSignature MultiSignaturePolicy = "require-wipe"
+
+	// DefaultMultiSignaturePolicy is the policy used if none is specified
+	DefaultMultiSignaturePolicy = QuarantineMultiSignature
+)
+
+// ParseMultiSignaturePolicy validates and returns the multi signature policy.
+// Empty value is treated as the default policy.
+func ParseMultiSignaturePolicy(policy string) (MultiSignaturePolicy, error) {
+	switch MultiSignaturePolicy(policy) {
+	case "":
+		return DefaultMultiSignaturePolicy, nil
+	case QuarantineMultiSignature, PreferRecentSignature, RequireWipeMultiSignature:
+		return MultiSignaturePolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid multi signature policy: %q, should be one of %s, %s, %s",
+		policy, QuarantineMultiSignature, PreferRecentSignature, RequireWipeMultiSignature)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMultiSignaturePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    MultiSignaturePolicy
+		wantErr bool
+	}{
+		"empty policy uses the default": {
+			policy: "",
+			want:   QuarantineMultiSignature,
+		},
+		"quarantine": {
+			policy: "quarantine",
+			want:   QuarantineMultiSignature,
+		},
+		"prefer recent": {
+			policy: "prefer-recent",
+			want:   PreferRecentSignature,
+		},
+		"require wipe": {
+			policy: "require-wipe",
+			want:   RequireWipeMultiSignature,
+		},
+		"invalid policy": {
+			policy:  "wipe",
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMultiSignaturePolicy(test.policy)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
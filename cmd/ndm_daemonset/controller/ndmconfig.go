@@ -38,6 +38,28 @@ type NodeDiskManagerConfig struct {
 	TagConfigs []TagConfig `json:"tagconfigs"`
 	// MetaConfig contains configs for device labels
 	MetaConfigs []MetaConfig `json:"metaconfigs"`
+	// UUIDPinConfigs contains the UUIDs manually pinned to devices
+	UUIDPinConfigs []UUIDPinConfig `json:"uuidpinconfigs"`
+	// CapacityRangeConfig limits the devices managed by NDM by their capacity
+	CapacityRangeConfig *CapacityRangeConfig `json:"capacityrange,omitempty"`
+	// VMDatastorePaths are the paths at which the VM datastores are mounted
+	VMDatastorePaths []string `json:"vmdatastorepaths,omitempty"`
+	// LocalPathBasePaths are the base directories of the local-path provisioner
+	LocalPathBasePaths []string `json:"localpathbasepaths,omitempty"`
+	// TransportPolicyConfig limits the devices managed by NDM by their transport
+	TransportPolicyConfig *TransportPolicyConfig `json:"transportpolicy,omitempty"`
+	// TemperatureConfig has the threshold for the temperature of the devices
+	TemperatureConfig *TemperatureConfig `json:"temperature,omitempty"`
+	// AllowListConfig limits the devices managed by NDM to an explicit list of devices
+	AllowListConfig *AllowListConfig `json:"allowlist,omitempty"`
+	// FilesystemReclaimConfigs are the devices whose stale filesystem can be wiped by NDM
+	FilesystemReclaimConfigs []FilesystemReclaimConfig `json:"filesystemreclaims,omitempty"`
+	// PartitioningConfidenceConfig has the identifiers to be known before NDM partitions a device
+	PartitioningConfidenceConfig *PartitioningConfidenceConfig `json:"partitioningconfidence,omitempty"`
+	// CSIDriverConfigs are the CSI drivers whose devices are not managed by NDM
+	CSIDriverConfigs []CSIDriverConfig `json:"csidrivers,omitempty"`
+	// UsedByPluginConfigs are the commands run to find whether a device is in use
+	UsedByPluginConfigs []UsedByPluginConfig `json:"usedbyplugins,omitempty"`
 }
 
 // ProbeConfig contains configs of Probe
@@ -45,6 +67,13 @@ type ProbeConfig struct {
 	Key   string `json:"key"`   // Key is key for each Probe
 	Name  string `json:"name"`  // Name is name of Probe
 	State string `json:"state"` // State is state of Probe
+	// Priority is the position of the probe in the order in which probes are
+	// run, lower priority probes run first. If not set, the default priority
+	// of the probe is used.
+	Priority int `json:"priority,omitempty"`
+	// Timeout is the time after which the probe is abandoned for a device,
+	// eg: 30s. If not set, the probe timeout given in NDM options is used.
+	Timeout string `json:"timeout,omitempty"`
 }
 
 // FilterConfig contains configs of Filter
@@ -70,6 +99,96 @@ type MetaConfig struct {
 	Pattern string `json:"pattern"`
 }
 
+// UUIDPinConfig pins a UUID to the disk having the given stable identifiers.
+// All the identifiers that are specified should match the disk. Partitions of
+// the disk are not pinned.
+type UUIDPinConfig struct {
+	WWN    string `json:"wwn"`    // WWN of the device
+	Serial string `json:"serial"` // Serial of the device
+	UUID   string `json:"uuid"`   // UUID to be used for the BlockDevice resource
+}
+
+// CapacityRangeConfig is the range of capacity of the devices to be managed by NDM.
+// Both the bounds are inclusive and optional, and are given as quantities, eg: 500G, 2Ti.
+type CapacityRangeConfig struct {
+	Min string `json:"min"` // Min is the minimum capacity of the device
+	Max string `json:"max"` // Max is the maximum capacity of the device
+}
+
+// TransportPolicyConfig is the list of transports over which the devices managed by NDM
+// can be attached, eg: sata, nvme-pcie. Denied transports take precedence over the allowed
+// ones. All the transports are allowed if the allow list is empty.
+type TransportPolicyConfig struct {
+	Allow []string `json:"allow,omitempty"` // Allow is the list of allowed transports
+	Deny  []string `json:"deny,omitempty"`  // Deny is the list of denied transports
+}
+
+// TemperatureConfig is the threshold for the temperature of the devices, as reported by
+// SMART. Devices above the threshold have the OverTemperature condition set.
+type TemperatureConfig struct {
+	// WarningThreshold is the temperature in celsius above which the device is over
+	// temperature. The check is disabled if it is not set.
+	WarningThreshold int16 `json:"warningthreshold"`
+}
+
+// AllowListConfig is the list of the devices to be managed by NDM, identified by their
+// stable identifiers. When enabled, devices that are not in the list are not managed at
+// all. An enabled allow list without any device does not manage any device.
+type AllowListConfig struct {
+	// Enabled turns on the allow list mode
+	Enabled bool `json:"enabled"`
+	// Devices are the devices to be managed
+	Devices []AllowListDevice `json:"devices,omitempty"`
+}
+
+// AllowListDevice identifies a device in the allow list. All the identifiers that are
+// specified should match the device.
+type AllowListDevice struct {
+	WWN    string `json:"wwn"`    // WWN of the device
+	Serial string `json:"serial"` // Serial of the device
+}
+
+// FilesystemReclaimConfig is a device, identified by all the identifiers that are
+// specified, whose filesystem is wiped so that the device is processed as a blank device
+type FilesystemReclaimConfig struct {
+	WWN    string `json:"wwn"`    // WWN of the device
+	Serial string `json:"serial"` // Serial of the device
+}
+
+// PartitioningConfidenceConfig is the minimum set of identifiers, eg: transport, size,
+// serial, model, vendor, wwn, that should be known for NDM to partition a device. All the
+// required identifiers, and at least one of the any of identifiers if given, should be known.
+type PartitioningConfidenceConfig struct {
+	Required []string `json:"required,omitempty"` // Required identifiers should all be known
+	AnyOf    []string `json:"anyof,omitempty"`    // AnyOf has identifiers one of which should be known
+}
+
+// CSIDriverConfig is a CSI driver present in the cluster, which stamps the devices it
+// manages with a recognizable partition type or filesystem label. Devices matching any
+// of the partition types or filesystem label patterns are owned by the driver.
+type CSIDriverConfig struct {
+	Name string `json:"name"` // Name of the CSI driver
+	// PartitionTypes are the partition type GUIDs of the partitions created by the driver
+	PartitionTypes []string `json:"partitiontypes,omitempty"`
+	// FilesystemLabels are the glob patterns of the labels of the filesystems created by
+	// the driver, eg: csi-vol-*
+	FilesystemLabels []string `json:"filesystemlabels,omitempty"`
+}
+
+// UsedByPluginConfig is a command run by NDM for each device, to find whether the device
+// is used by a storage stack that is not known to NDM. The path of the device is passed as
+// the last argument. The command exits with 0 if the device is in use, printing what uses
+// the device, and with 1 if the device is not in use. Any other exit code, or a command
+// that does not complete within the timeout, means the usage of the device is unknown.
+type UsedByPluginConfig struct {
+	Name    string   `json:"name"`           // Name of the plugin
+	Command string   `json:"command"`        // Command is the path of the executable
+	Args    []string `json:"args,omitempty"` // Args are passed before the path of the device
+	// Timeout is the time after which the command is killed, eg: 5s. If not set, the
+	// default timeout of 10s is used.
+	Timeout string `json:"timeout,omitempty"`
+}
+
 // SetNDMConfig sets config for probes and filters which user provides via configmap. If
 // no configmap present then ndm will load default config for each probes and filters.
 func (c *Controller) SetNDMConfig(opts NDMOptions) {
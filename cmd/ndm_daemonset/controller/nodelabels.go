@@ -0,0 +1,222 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// nodeLabelPollInterval is the interval at which the labels of the node are checked
+	// for changes
+	nodeLabelPollInterval = 30 * time.Second
+	// nodeLabelDebounce is the time for which the changed labels of the node should remain
+	// the same, before the labels of the blockdevices are updated. It avoids updating all
+	// the blockdevices of the node on every change, when the labels are changed in quick
+	// succession.
+	nodeLabelDebounce = time.Minute
+)
+
+// getNodeLabelPatterns gets the patterns of the node labels to be added to the
+// blockdevices, from the node-labels meta config
+func (c *Controller) getNodeLabelPatterns() []string {
+	var labelPattern []string
+	if c.NDMConfig != nil {
+		for _, metaConfig := range c.NDMConfig.MetaConfigs {
+			if metaConfig.Key == nodeLabelsKey {
+				labelPattern = strings.Split(metaConfig.Pattern, ",")
+			}
+		}
+	}
+	return labelPattern
+}
+
+// getNodeLabelAttributes gets the node labels with a non empty value, that match any of
+// the patterns
+func getNodeLabelAttributes(nodeLabels map[string]string, patterns []string) map[string]string {
+	attributes := make(map[string]string)
+	for key, value := range nodeLabels {
+		if value == "" {
+			continue
+		}
+		for _, pattern := range patterns {
+			if util.IsMatchRegex(pattern, key) {
+				attributes[key] = value
+				break
+			}
+		}
+	}
+	return attributes
+}
+
+// nodeLabelDebouncer reports a change in the labels of the node only once the changed
+// labels have been observed unchanged for the debounce period
+type nodeLabelDebouncer struct {
+	debounce time.Duration
+	// applied are the labels last reported
+	applied map[string]string
+	// pending are the changed labels yet to be reported, observed first at pendingSince
+	pending      map[string]string
+	pendingSince time.Time
+}
+
+// observe records the labels observed at the given time, and returns the labels to be
+// applied if they have remained the same for the debounce period
+func (d *nodeLabelDebouncer) observe(labels map[string]string, now time.Time) (map[string]string, bool) {
+	if reflect.DeepEqual(labels, d.applied) {
+		d.pending = nil
+		return nil, false
+	}
+	if d.pending == nil || !reflect.DeepEqual(labels, d.pending) {
+		d.pending = labels
+		d.pendingSince = now
+	}
+	if now.Sub(d.pendingSince) < d.debounce {
+		return nil, false
+	}
+	d.applied, d.pending = d.pending, nil
+	return d.applied, true
+}
+
+// StartNodeLabelWatcher starts watching the labels of the node, if node labels are
+// added to the blockdevices. The changed labels are sent on the channel returned by
+// NodeLabelChanges, once they are stable for the debounce period.
+func (c *Controller) StartNodeLabelWatcher() {
+	patterns := c.getNodeLabelPatterns()
+	if len(patterns) == 0 {
+		return
+	}
+	current := make(map[string]string)
+	for key, value := range c.NodeAttributes {
+		if key != HostNameKey && key != NodeNameKey {
+			current[key] = value
+		}
+	}
+	c.nodeLabelChanges = make(chan map[string]string)
+	go c.watchNodeLabels(patterns, current, nodeLabelPollInterval)
+}
+
+// NodeLabelChanges returns the channel on which the changed node labels are sent. nil is
+// returned if the node labels are not watched, so that the receive blocks forever.
+func (c *Controller) NodeLabelChanges() <-chan map[string]string {
+	return c.nodeLabelChanges
+}
+
+// watchNodeLabels polls the labels of the node at the given interval, and sends the
+// node labels matching the patterns on nodeLabelChanges when they change
+func (c *Controller) watchNodeLabels(patterns []string, current map[string]string, interval time.Duration) {
+	debouncer := &nodeLabelDebouncer{
+		debounce: nodeLabelDebounce,
+		applied:  current,
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	shutdown := c.ShutdownStarted()
+	for {
+		select {
+		case <-ticker.C:
+		case <-shutdown:
+			return
+		}
+		node := &v1.Node{}
+		err := c.Clientset.Get(context.TODO(), client.ObjectKey{Name: c.NodeAttributes[NodeNameKey]}, node)
+		if err != nil {
+			klog.Errorf("unable to get node: %s for checking its labels: %v", c.NodeAttributes[NodeNameKey], err)
+			continue
+		}
+		labels, ok := debouncer.observe(getNodeLabelAttributes(node.Labels, patterns), time.Now())
+		if !ok {
+			continue
+		}
+		select {
+		case c.nodeLabelChanges <- labels:
+		case <-shutdown:
+			return
+		}
+	}
+}
+
+// ReconcileNodeLabels updates the node attributes with the changed node labels, and the
+// labels of the blockdevices of this node that diverge from them. The labels removed from
+// the node are removed from the blockdevices.
+func (c *Controller) ReconcileNodeLabels(labels map[string]string) {
+	removed := make(map[string]bool)
+	for key := range c.NodeAttributes {
+		if _, ok := labels[key]; !ok && key != HostNameKey && key != NodeNameKey {
+			removed[key] = true
+			delete(c.NodeAttributes, key)
+		}
+	}
+	for key, value := range labels {
+		c.NodeAttributes[key] = value
+	}
+
+	bdAPIList, err := c.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for reconciling node labels: %v", err)
+		return
+	}
+	for i := range bdAPIList.Items {
+		bdAPI := bdAPIList.Items[i]
+		if !setNodeLabelsOnBlockDevice(&bdAPI, labels, removed) {
+			continue
+		}
+		if c.skipPeerManagedBlockDevice(bdAPI, "update") {
+			continue
+		}
+		if err := c.Clientset.Update(context.TODO(), &bdAPI); err != nil {
+			klog.Errorf("eventcode=%s msg=%s : %v rname=%v",
+				"ndm.blockdevice.update.failure", "Unable to update node labels of blockdevice object",
+				err, bdAPI.Name)
+			continue
+		}
+		klog.Infof("eventcode=%s msg=%s rname=%v",
+			"ndm.blockdevice.update.success", "Updated node labels of blockdevice object", bdAPI.Name)
+	}
+}
+
+// setNodeLabelsOnBlockDevice sets the node labels on the blockdevice and removes the
+// removed labels from it. true is returned if the labels of the blockdevice changed.
+func setNodeLabelsOnBlockDevice(bdAPI *apis.BlockDevice, labels map[string]string, removed map[string]bool) bool {
+	changed := false
+	for key := range removed {
+		if _, ok := bdAPI.Labels[key]; ok {
+			delete(bdAPI.Labels, key)
+			changed = true
+		}
+	}
+	if bdAPI.Labels == nil {
+		bdAPI.Labels = make(map[string]string, len(labels))
+	}
+	for key, value := range labels {
+		if bdAPI.Labels[key] != value {
+			bdAPI.Labels[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
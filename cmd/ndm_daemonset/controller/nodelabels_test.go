@@ -0,0 +1,143 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetNodeLabelAttributes(t *testing.T) {
+	nodeLabels := map[string]string{
+		"topology.kubernetes.io/zone": "zone-a",
+		"topology.kubernetes.io/rack": "",
+		"kubernetes.io/os":            "linux",
+	}
+	want := map[string]string{
+		"topology.kubernetes.io/zone": "zone-a",
+	}
+	assert.Equal(t, want, getNodeLabelAttributes(nodeLabels, []string{"topology.kubernetes.io.*"}))
+}
+
+func TestNodeLabelDebouncer(t *testing.T) {
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	zoneA := map[string]string{"zone": "a"}
+	zoneB := map[string]string{"zone": "b"}
+	zoneC := map[string]string{"zone": "c"}
+	observations := []struct {
+		labels  map[string]string
+		after   time.Duration
+		want    map[string]string
+		wantNew bool
+	}{
+		// the labels already applied are not reported
+		{labels: zoneA, after: 0},
+		// a change is reported only after it is stable for the debounce period
+		{labels: zoneB, after: 10 * time.Second},
+		{labels: zoneB, after: 30 * time.Second},
+		// a further change restarts the debounce period
+		{labels: zoneC, after: 30 * time.Second},
+		{labels: zoneC, after: 30 * time.Second},
+		{labels: zoneC, after: 30 * time.Second, want: zoneC, wantNew: true},
+		// the reported labels are not reported again
+		{labels: zoneC, after: 2 * time.Minute},
+		// a change reverted within the debounce period is not reported
+		{labels: zoneA, after: 10 * time.Second},
+		{labels: zoneC, after: 10 * time.Second},
+		{labels: zoneC, after: 2 * time.Minute},
+	}
+	d := &nodeLabelDebouncer{
+		debounce: time.Minute,
+		applied:  zoneA,
+	}
+	now := start
+	for i, o := range observations {
+		now = now.Add(o.after)
+		got, gotNew := d.observe(o.labels, now)
+		assert.Equal(t, o.wantNew, gotNew, "observation %d", i)
+		assert.Equal(t, o.want, got, "observation %d", i)
+	}
+}
+
+func TestReconcileNodeLabels(t *testing.T) {
+	newBDAPI := func(name, hostName string, labels map[string]string) *apis.BlockDevice {
+		bdLabels := map[string]string{
+			KubernetesHostNameLabel: hostName,
+		}
+		for key, value := range labels {
+			bdLabels[key] = value
+		}
+		return &apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: bdLabels,
+			},
+		}
+	}
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s,
+		newBDAPI("bd-1", "node1", map[string]string{"zone": "a", "rack": "r1", "custom": "x"}),
+		newBDAPI("bd-2", "node2", map[string]string{"zone": "a", "rack": "r1"}))
+
+	c := &Controller{
+		Clientset: cl,
+		NodeAttributes: map[string]string{
+			HostNameKey: "node1",
+			NodeNameKey: "node1",
+			"zone":      "a",
+			"rack":      "r1",
+		},
+	}
+	// the zone of the node changed, and the rack label was removed from it
+	c.ReconcileNodeLabels(map[string]string{"zone": "b"})
+
+	assert.Equal(t, map[string]string{
+		HostNameKey: "node1",
+		NodeNameKey: "node1",
+		"zone":      "b",
+	}, c.NodeAttributes)
+
+	tests := map[string]struct {
+		wantLabels map[string]string
+	}{
+		"bd-1": {
+			wantLabels: map[string]string{KubernetesHostNameLabel: "node1", "zone": "b", "custom": "x"},
+		},
+		// the blockdevices of the other nodes are not updated
+		"bd-2": {
+			wantLabels: map[string]string{KubernetesHostNameLabel: "node2", "zone": "a", "rack": "r1"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bdAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: name}, bdAPI))
+			assert.Equal(t, tt.wantLabels, bdAPI.Labels)
+		})
+	}
+}
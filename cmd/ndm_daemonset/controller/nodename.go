@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NodeNameSource defines from where NDM gets the name of the node on which it is
+// running. The node name is used as the NodeName of the BlockDevice resources and
+// to look up the Node object, from which the hostname label is taken.
+type NodeNameSource string
+
+const (
+	// NodeNameSourceEnv gets the node name from the NODE_NAME env, that is set
+	// using the downward API
+	NodeNameSourceEnv NodeNameSource = "env"
+
+	// NodeNameSourceHostname uses the hostname of the machine as the node name.
+	// The NDM pod should be running in the host network for this.
+	NodeNameSourceHostname NodeNameSource = "hostname"
+
+	// NodeNameSourceConfig uses the node name explicitly given in the NDM options
+	NodeNameSourceConfig NodeNameSource = "config"
+
+	// DefaultNodeNameSource is the source used if none is specified
+	DefaultNodeNameSource = NodeNameSourceEnv
+)
+
+// ParseNodeNameSource validates and returns the node name source.
+// Empty value is treated as the default source.
+func ParseNodeNameSource(source string) (NodeNameSource, error) {
+	switch NodeNameSource(source) {
+	case "":
+		return DefaultNodeNameSource, nil
+	case NodeNameSourceEnv, NodeNameSourceHostname, NodeNameSourceConfig:
+		return NodeNameSource(source), nil
+	}
+	return "", fmt.Errorf("invalid node name source: %q, should be one of %s, %s, %s",
+		source, NodeNameSourceEnv, NodeNameSourceHostname, NodeNameSourceConfig)
+}
+
+// resolveNodeName gets the node name from the given source. configuredName is
+// used only when the source is config.
+func resolveNodeName(source NodeNameSource, configuredName string) (string, error) {
+	switch source {
+	case NodeNameSourceHostname:
+		hostName, err := os.Hostname()
+		if err != nil {
+			return "", fmt.Errorf("error getting hostname: %v", err)
+		}
+		// node names are always lower case, whereas hostnames need not be
+		return strings.ToLower(hostName), nil
+	case NodeNameSourceConfig:
+		if configuredName == "" {
+			return "", errors.New("node name source is config, but node name is not set")
+		}
+		return configuredName, nil
+	default:
+		return getNodeName()
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseNodeNameSource(t *testing.T) {
+	tests := map[string]struct {
+		source  string
+		want    NodeNameSource
+		wantErr bool
+	}{
+		"empty source":    {source: "", want: NodeNameSourceEnv, wantErr: false},
+		"env source":      {source: "env", want: NodeNameSourceEnv, wantErr: false},
+		"hostname source": {source: "hostname", want: NodeNameSourceHostname, wantErr: false},
+		"config source":   {source: "config", want: NodeNameSourceConfig, wantErr: false},
+		"invalid source":  {source: "label", want: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseNodeNameSource(tt.source)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveNodeName(t *testing.T) {
+	hostName, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NODE_NAME", "node-from-env")
+
+	tests := map[string]struct {
+		source         NodeNameSource
+		configuredName string
+		want           string
+		wantErr        bool
+	}{
+		"env source": {
+			source:  NodeNameSourceEnv,
+			want:    "node-from-env",
+			wantErr: false,
+		},
+		"hostname source": {
+			source:  NodeNameSourceHostname,
+			want:    strings.ToLower(hostName),
+			wantErr: false,
+		},
+		"config source": {
+			source:         NodeNameSourceConfig,
+			configuredName: "node-from-config",
+			want:           "node-from-config",
+			wantErr:        false,
+		},
+		"config source without node name": {
+			source:  NodeNameSourceConfig,
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolveNodeName(tt.source, tt.configuredName)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetNodeAttributes(t *testing.T) {
+	tests := map[string]struct {
+		configuredName string
+		node           *v1.Node
+		wantHostName   string
+	}{
+		"node exists with hostname label": {
+			configuredName: "node-1",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-1",
+					Labels: map[string]string{
+						KubernetesHostNameLabel: "host-1",
+					},
+				},
+			},
+			wantHostName: "host-1",
+		},
+		"node does not exist": {
+			configuredName: "node-2",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-1",
+				},
+			},
+			wantHostName: "node-2",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := CreateFakeClient(t)
+			assert.NoError(t, fakeClient.Create(context.TODO(), tt.node))
+
+			c := &Controller{
+				Clientset:      fakeClient,
+				NodeAttributes: make(map[string]string),
+			}
+			err := c.setNodeAttributes(NodeNameSourceConfig, tt.configuredName)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.configuredName, c.NodeAttributes[NodeNameKey])
+			assert.Equal(t, tt.wantHostName, c.NodeAttributes[HostNameKey])
+		})
+	}
+}
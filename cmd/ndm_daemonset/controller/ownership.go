@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"k8s.io/klog/v2"
+)
+
+// ManagedByAnnotation is the annotation having the instance ID of the NDM daemon that
+// manages the blockdevice, when multiple NDM daemons run on the same nodes
+const ManagedByAnnotation = openEBSLabelPrefix + "managed-by"
+
+// IsManagedByPeer checks if the blockdevice is managed by another NDM instance. Ownership
+// is considered only if the instance ID of this daemon is set, resources without the
+// annotation are unowned and can be managed by any instance.
+func (c *Controller) IsManagedByPeer(blockDevice *apis.BlockDevice) bool {
+	if c.InstanceID == "" || blockDevice == nil {
+		return false
+	}
+	owner, ok := blockDevice.Annotations[ManagedByAnnotation]
+	return ok && owner != "" && owner != c.InstanceID
+}
+
+// stampManagedBy stamps the instance ID of this daemon on the blockdevice resource, so
+// that the peer instances leave the device alone
+func (c *Controller) stampManagedBy(blockDevice *apis.BlockDevice) {
+	if c.InstanceID == "" {
+		return
+	}
+	if blockDevice.Annotations == nil {
+		blockDevice.Annotations = make(map[string]string)
+	}
+	blockDevice.Annotations[ManagedByAnnotation] = c.InstanceID
+}
+
+// skipPeerManagedBlockDevice checks if the blockdevice is managed by a peer instance and
+// logs the operation being skipped on it
+func (c *Controller) skipPeerManagedBlockDevice(blockDevice apis.BlockDevice, operation string) bool {
+	if !c.IsManagedByPeer(&blockDevice) {
+		return false
+	}
+	klog.Infof("blockdevice: %s is managed by ndm instance: %s, skipping %s",
+		blockDevice.Name, blockDevice.Annotations[ManagedByAnnotation], operation)
+	return true
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsManagedByPeer(t *testing.T) {
+	tests := map[string]struct {
+		instanceID  string
+		annotations map[string]string
+		want        bool
+	}{
+		"instance id not set": {
+			instanceID:  "",
+			annotations: map[string]string{ManagedByAnnotation: "ndm-a"},
+			want:        false,
+		},
+		"unowned blockdevice": {
+			instanceID: "ndm-b",
+			want:       false,
+		},
+		"blockdevice owned by this instance": {
+			instanceID:  "ndm-b",
+			annotations: map[string]string{ManagedByAnnotation: "ndm-b"},
+			want:        false,
+		},
+		"blockdevice owned by a peer instance": {
+			instanceID:  "ndm-b",
+			annotations: map[string]string{ManagedByAnnotation: "ndm-a"},
+			want:        true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{InstanceID: test.instanceID}
+			bd := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+			}
+			assert.Equal(t, test.want, c.IsManagedByPeer(bd))
+		})
+	}
+}
+
+func TestDeactivatePeerManagedBlockDevice(t *testing.T) {
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+		InstanceID:     "ndm-b",
+	}
+	bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceUnclaimed)
+	bd.Annotations = map[string]string{ManagedByAnnotation: "ndm-a"}
+	if err := fakeController.Clientset.Create(context.TODO(), &bd); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeController.DeactivateBlockDevice(*created, AuditCause{})
+
+	got, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+	assert.Equal(t, NDMActive, string(got.Status.State))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// signatureFoundReason is the reason of the UnexpectedSignature condition when the
+	// partition created by NDM has a signature
+	signatureFoundReason = "SignatureFound"
+	// partitionBlankReason is the reason of the UnexpectedSignature condition when the
+	// partition created by NDM is blank
+	partitionBlankReason = "PartitionBlank"
+)
+
+// getUnexpectedSignatureCondition gets the UnexpectedSignature condition of a partition
+// created by NDM, from the check of its signatures after its creation. false is returned
+// if the partition was not checked.
+func getUnexpectedSignatureCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if !di.BlankChecked {
+		return apis.BlockDeviceCondition{}, false
+	}
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceUnexpectedSignature,
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(di.UnexpectedSignatures) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = signatureFoundReason
+		condition.Message = "partition created on a blank disk has the signatures: " +
+			strings.Join(di.UnexpectedSignatures, ",")
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = partitionBlankReason
+		condition.Message = "partition created on the blank disk has no signatures"
+	}
+	return condition, true
+}
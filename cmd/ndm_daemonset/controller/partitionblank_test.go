@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetUnexpectedSignatureCondition(t *testing.T) {
+	tests := map[string]struct {
+		blankChecked         bool
+		unexpectedSignatures []string
+		wantOk               bool
+		wantStatus           v1.ConditionStatus
+		wantMessage          string
+	}{
+		"partition not checked": {},
+		"blank partition": {
+			blankChecked: true,
+			wantOk:       true,
+			wantStatus:   v1.ConditionFalse,
+			wantMessage:  "partition created on the blank disk has no signatures",
+		},
+		"partition having signatures": {
+			blankChecked:         true,
+			unexpectedSignatures: []string{"xfs", "LVM2_member"},
+			wantOk:               true,
+			wantStatus:           v1.ConditionTrue,
+			wantMessage:          "partition created on a blank disk has the signatures: xfs,LVM2_member",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			di := NewDeviceInfo()
+			di.BlankChecked = test.blankChecked
+			di.UnexpectedSignatures = test.unexpectedSignatures
+			got, ok := getUnexpectedSignatureCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceUnexpectedSignature, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+				assert.Equal(t, test.wantMessage, got.Message)
+			}
+		})
+	}
+}
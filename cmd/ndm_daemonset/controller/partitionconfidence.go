@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+)
+
+const (
+	// IdentifierTransport is the transport over which the device is attached
+	IdentifierTransport = "transport"
+	// IdentifierSize is the capacity of the device
+	IdentifierSize = "size"
+	// IdentifierSerial is the serial number of the device
+	IdentifierSerial = "serial"
+	// IdentifierModel is the model of the device
+	IdentifierModel = "model"
+	// IdentifierVendor is the vendor of the device
+	IdentifierVendor = "vendor"
+	// IdentifierWWN is the WWN of the device
+	IdentifierWWN = "wwn"
+)
+
+// HasPartitioningConfidence checks if enough identifiers of the device are known, as per
+// the partitioning confidence rules in the config, for NDM to partition the device. If
+// not, the reason is returned. Devices from flaky enclosures that report almost nothing
+// are not partitioned till they are probed better.
+func (c *Controller) HasPartitioningConfidence(bd blockdevice.BlockDevice) (bool, string) {
+	if c.NDMConfig == nil || c.NDMConfig.PartitioningConfidenceConfig == nil {
+		return true, ""
+	}
+	rules := c.NDMConfig.PartitioningConfidenceConfig
+
+	missing := make([]string, 0)
+	for _, identifier := range rules.Required {
+		if !isIdentifierKnown(bd, identifier) {
+			missing = append(missing, strings.ToLower(strings.TrimSpace(identifier)))
+		}
+	}
+	if len(missing) != 0 {
+		return false, fmt.Sprintf("identifiers %s are not known", strings.Join(missing, ", "))
+	}
+
+	if len(rules.AnyOf) == 0 {
+		return true, ""
+	}
+	for _, identifier := range rules.AnyOf {
+		if isIdentifierKnown(bd, identifier) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("none of the identifiers %s are known", strings.Join(rules.AnyOf, ", "))
+}
+
+// isIdentifierKnown checks if the identifier of the device has been filled by the probes.
+// An identifier not known to NDM is never known, so that a mistake in the config does not
+// allow partitioning.
+func isIdentifierKnown(bd blockdevice.BlockDevice, identifier string) bool {
+	switch strings.ToLower(strings.TrimSpace(identifier)) {
+	case IdentifierTransport:
+		return bd.DeviceAttributes.Transport != ""
+	case IdentifierSize:
+		return bd.Capacity.Storage != 0
+	case IdentifierSerial:
+		return strings.TrimSpace(bd.DeviceAttributes.Serial) != ""
+	case IdentifierModel:
+		return strings.TrimSpace(bd.DeviceAttributes.Model) != ""
+	case IdentifierVendor:
+		return strings.TrimSpace(bd.DeviceAttributes.Vendor) != ""
+	case IdentifierWWN:
+		return strings.TrimSpace(bd.DeviceAttributes.WWN) != ""
+	default:
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPartitioningConfidence(t *testing.T) {
+	rules := &PartitioningConfidenceConfig{
+		Required: []string{IdentifierTransport, IdentifierSize},
+		AnyOf:    []string{IdentifierSerial, IdentifierModel},
+	}
+	wellProbed := blockdevice.BlockDevice{
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			Transport: blockdevice.TransportSATA,
+			Serial:    "ZA1B2C3D",
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 1024 * 1024 * 1024,
+		},
+	}
+	modelOnly := wellProbed
+	modelOnly.DeviceAttributes.Serial = ""
+	modelOnly.DeviceAttributes.Model = "ST4000NM0035"
+	noTransport := wellProbed
+	noTransport.DeviceAttributes.Transport = ""
+	noSerialOrModel := wellProbed
+	noSerialOrModel.DeviceAttributes.Serial = " "
+
+	tests := map[string]struct {
+		rules *PartitioningConfidenceConfig
+		bd    blockdevice.BlockDevice
+		want  bool
+	}{
+		"no rules allows a sparsely probed device": {
+			rules: nil,
+			bd:    blockdevice.BlockDevice{},
+			want:  true,
+		},
+		"all the identifiers are known": {
+			rules: rules,
+			bd:    wellProbed,
+			want:  true,
+		},
+		"model known instead of the serial": {
+			rules: rules,
+			bd:    modelOnly,
+			want:  true,
+		},
+		"required identifier is not known": {
+			rules: rules,
+			bd:    noTransport,
+			want:  false,
+		},
+		"none of the any of identifiers are known": {
+			rules: rules,
+			bd:    noSerialOrModel,
+			want:  false,
+		},
+		"identifier not known to ndm is never known": {
+			rules: &PartitioningConfidenceConfig{
+				Required: []string{"firmware"},
+			},
+			bd:   wellProbed,
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{
+					PartitioningConfidenceConfig: tt.rules,
+				},
+			}
+			got, reason := c.HasPartitioningConfidence(tt.bd)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want, reason == "")
+		})
+	}
+}
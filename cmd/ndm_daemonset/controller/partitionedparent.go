@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+)
+
+// PartitionedParentPolicy defines how NDM handles a disk that has partitions, but no
+// BlockDevice resource of its own
+type PartitionedParentPolicy string
+
+const (
+	// IgnorePartitionedParents does not create a resource for a disk having partitions.
+	// Only the partitions of the disk get resources.
+	IgnorePartitionedParents PartitionedParentPolicy = "ignore"
+
+	// ShowPartitionedParents creates a resource for a disk having partitions, so that
+	// the disk is visible in the inventory. The resource has the HasPartitionsAnnotation
+	// and is never claimed.
+	ShowPartitionedParents PartitionedParentPolicy = "show"
+
+	// DefaultPartitionedParentPolicy is the policy used if none is specified
+	DefaultPartitionedParentPolicy = IgnorePartitionedParents
+
+	// HasPartitionsAnnotation is the annotation having the partitions of the disk, added
+	// to the blockdevice of a partitioned disk shown with the show policy. Blockdevices
+	// having it are not considered for claiming.
+	HasPartitionsAnnotation = openEBSLabelPrefix + "has-partitions"
+)
+
+// ParsePartitionedParentPolicy validates and returns the partitioned parent policy.
+// Empty value is treated as the default policy.
+func ParsePartitionedParentPolicy(policy string) (PartitionedParentPolicy, error) {
+	switch PartitionedParentPolicy(policy) {
+	case "":
+		return DefaultPartitionedParentPolicy, nil
+	case IgnorePartitionedParents, ShowPartitionedParents:
+		return PartitionedParentPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid partitioned parent policy: %q, should be one of %s, %s",
+		policy, IgnorePartitionedParents, ShowPartitionedParents)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// partitionTableCorruptReason is the reason of the CorruptPartitionTable condition
+	// when the partition table has a defect
+	partitionTableCorruptReason = "PartitionTableCorrupt"
+	// partitionTableValidReason is the reason of the CorruptPartitionTable condition when
+	// the partition table is valid
+	partitionTableValidReason = "PartitionTableValid"
+)
+
+// getCorruptPartitionTableCondition gets the CorruptPartitionTable condition of a disk or
+// a partition, from the validation of the partition table of the disk. false is returned
+// if the partition table was not validated.
+func getCorruptPartitionTableCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if !di.PartitionTableChecked {
+		return apis.BlockDeviceCondition{}, false
+	}
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceCorruptPartitionTable,
+		LastTransitionTime: metav1.Now(),
+	}
+	if di.PartitionTableCorruption != "" {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = partitionTableCorruptReason
+		condition.Message = di.PartitionTableCorruption
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = partitionTableValidReason
+		condition.Message = "partition table is valid"
+	}
+	return condition, true
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetCorruptPartitionTableCondition(t *testing.T) {
+	tests := map[string]struct {
+		partitionTableChecked    bool
+		partitionTableCorruption string
+		wantOk                   bool
+		wantStatus               v1.ConditionStatus
+	}{
+		"partition table not checked": {},
+		"valid partition table": {
+			partitionTableChecked: true,
+			wantOk:                true,
+			wantStatus:            v1.ConditionFalse,
+		},
+		"overlapping partitions": {
+			partitionTableChecked:    true,
+			partitionTableCorruption: "overlap on disk /dev/sde, partition 2 overlaps partition 1",
+			wantOk:                   true,
+			wantStatus:               v1.ConditionTrue,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			di := NewDeviceInfo()
+			di.PartitionTableChecked = test.partitionTableChecked
+			di.PartitionTableCorruption = test.partitionTableCorruption
+			got, ok := getCorruptPartitionTableCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceCorruptPartitionTable, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+				if test.partitionTableCorruption != "" {
+					assert.Equal(t, test.partitionTableCorruption, got.Message)
+				}
+			}
+		})
+	}
+}
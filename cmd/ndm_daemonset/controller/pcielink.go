@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// degradedLinkReason is the reason of the DegradedLink condition when the PCIe link
+	// negotiated a lower speed or width than its maximum
+	degradedLinkReason = "DegradedLink"
+	// linkNormalReason is the reason of the DegradedLink condition when the PCIe link is
+	// running at its maximum speed and width
+	linkNormalReason = "LinkNormal"
+)
+
+// getDegradedLinkCondition gets the DegradedLink condition of the device from its PCIe
+// link. false is returned if the device is not attached over PCIe.
+func getDegradedLinkCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if di.PCIeLink == nil {
+		return apis.BlockDeviceCondition{}, false
+	}
+	link := *di.PCIeLink
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceDegradedLink,
+		LastTransitionTime: metav1.Now(),
+	}
+	if link.IsDegraded() {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = degradedLinkReason
+		condition.Message = fmt.Sprintf("pcie link is running at %s, below its maximum of %s",
+			formatPCIeLink(link.CurrentSpeed, link.CurrentWidth), formatPCIeLink(link.MaxSpeed, link.MaxWidth))
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = linkNormalReason
+		condition.Message = fmt.Sprintf("pcie link is running at its maximum of %s",
+			formatPCIeLink(link.MaxSpeed, link.MaxWidth))
+	}
+	return condition, true
+}
+
+// getDegradedLinkLabelValue gets the value of the degraded link label, having the observed
+// and the expected speed / width of the link, eg: x2-8GTps-of-x4-16GTps
+func getDegradedLinkLabelValue(link bd.PCIeLink) string {
+	return fmt.Sprintf("x%d-%sGTps-of-x%d-%sGTps",
+		link.CurrentWidth, strconv.FormatFloat(link.CurrentSpeed, 'f', -1, 64),
+		link.MaxWidth, strconv.FormatFloat(link.MaxSpeed, 'f', -1, 64))
+}
+
+// formatPCIeLink formats the speed and width of a PCIe link, eg: 8 GT/s x4
+func formatPCIeLink(speed float64, width int) string {
+	return fmt.Sprintf("%s GT/s x%d", strconv.FormatFloat(speed, 'f', -1, 64), width)
+}
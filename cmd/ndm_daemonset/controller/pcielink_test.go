@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDegradedLink(t *testing.T) {
+	tests := map[string]struct {
+		pcieLink    *blockdevice.PCIeLink
+		wantOk      bool
+		wantStatus  v1.ConditionStatus
+		wantMessage string
+		wantLabel   string
+	}{
+		"device not attached over pcie": {},
+		"link at full speed and width": {
+			pcieLink:    &blockdevice.PCIeLink{CurrentSpeed: 16, MaxSpeed: 16, CurrentWidth: 4, MaxWidth: 4},
+			wantOk:      true,
+			wantStatus:  v1.ConditionFalse,
+			wantMessage: "pcie link is running at its maximum of 16 GT/s x4",
+		},
+		"link degraded in width": {
+			pcieLink:    &blockdevice.PCIeLink{CurrentSpeed: 16, MaxSpeed: 16, CurrentWidth: 2, MaxWidth: 4},
+			wantOk:      true,
+			wantStatus:  v1.ConditionTrue,
+			wantMessage: "pcie link is running at 16 GT/s x2, below its maximum of 16 GT/s x4",
+			wantLabel:   "x2-16GTps-of-x4-16GTps",
+		},
+		"link degraded in speed": {
+			pcieLink:    &blockdevice.PCIeLink{CurrentSpeed: 2.5, MaxSpeed: 8, CurrentWidth: 4, MaxWidth: 4},
+			wantOk:      true,
+			wantStatus:  v1.ConditionTrue,
+			wantMessage: "pcie link is running at 2.5 GT/s x4, below its maximum of 8 GT/s x4",
+			wantLabel:   "x4-2.5GTps-of-x4-8GTps",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := blockdevice.BlockDevice{}
+			bd.DevPath = "/dev/nvme0n1"
+			bd.UUID = "blockdevice-pcie-link"
+			bd.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeDisk
+			bd.DeviceAttributes.PCIeLink = test.pcieLink
+			c := &Controller{}
+			di := c.NewDeviceInfoFromBlockDevice(&bd)
+
+			got, ok := getDegradedLinkCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceDegradedLink, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+				assert.Equal(t, test.wantMessage, got.Message)
+			}
+
+			bdAPI, err := di.ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantLabel, bdAPI.Labels[NDMDegradedLinkKey])
+			_, hasCondition := getCondition(bdAPI.Status.Conditions, apis.BlockDeviceDegradedLink)
+			assert.Equal(t, test.wantOk, hasCondition)
+		})
+	}
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"errors"
 	"sort"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -31,18 +35,32 @@ type EventMessage struct {
 	Devices         []*blockdevice.BlockDevice // list of block device details
 	RequestedProbes []string                   // List of probes (given as probe names) to be run for this event. Optional
 	AllBlockDevices bool                       // If true, ignore Devices list and iterate through all block devices present in the hierarchy cache.
+	FullScan        bool                       // If true, Devices are all the devices on the node, listed by a full scan.
+	StaleUUIDs      []string                   // uuids of the resources deactivated as stale by the full scan.
 }
 
 var EventMessageChannel = make(chan EventMessage)
 
 // Probe contains name, state and probeinterface
 type Probe struct {
-	Priority  int
-	Name      string
-	State     bool
+	Priority int
+	Name     string
+	State    bool
+	// Timeout is the time after which the probe is abandoned for a device. If not
+	// set, the probe timeout of the controller is used.
+	Timeout   time.Duration
 	Interface ProbeInterface
+
+	// abandoned has the paths of the devices for which the probe was abandoned after
+	// the timeout, and has not completed yet
+	abandoned     map[string]bool
+	abandonedLock sync.Mutex
 }
 
+// errProbeStillRunning is returned if the probe abandoned earlier for the device has not
+// completed yet, so that a device that hangs does not leak a goroutine on every event
+var errProbeStillRunning = errors.New("probe abandoned earlier for the device is still running")
+
 // Start implements ProbeInterface's Start()
 func (p *Probe) Start() {
 	p.Interface.Start()
@@ -53,12 +71,148 @@ func (p *Probe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevice) {
 	p.Interface.FillBlockDeviceDetails(blockDevice)
 }
 
+// FillBlockDeviceDetailsWithContext fills the details of the blockdevice using the probe,
+// within the deadline of the context. The probe is run on a copy of the blockdevice and the
+// details are copied back once the probe completes. If the context is done before that, the
+// probe is abandoned, the blockdevice is left unmodified and the context error is returned.
+// Probes implementing the ContextProbeInterface are stopped when the context is done, the
+// probe is not run again for the device until an abandoned probe completes.
+func (p *Probe) FillBlockDeviceDetailsWithContext(ctx context.Context, blockDevice *blockdevice.BlockDevice) error {
+	if ctx.Done() == nil {
+		// the context can never be cancelled, run the probe synchronously
+		p.Interface.FillBlockDeviceDetails(blockDevice)
+		return nil
+	}
+	if p.isAbandoned(blockDevice.DevPath) {
+		return errProbeStillRunning
+	}
+
+	bdCopy := copyBlockDevice(*blockDevice)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if cp, ok := p.Interface.(ContextProbeInterface); ok {
+			cp.FillBlockDeviceDetailsWithContext(ctx, &bdCopy)
+		} else {
+			p.Interface.FillBlockDeviceDetails(&bdCopy)
+		}
+	}()
+
+	select {
+	case <-done:
+		*blockDevice = bdCopy
+		return nil
+	case <-ctx.Done():
+		p.setAbandoned(blockDevice.DevPath, done)
+		return ctx.Err()
+	}
+}
+
+// isAbandoned checks if the probe abandoned earlier for the device is still running
+func (p *Probe) isAbandoned(devPath string) bool {
+	p.abandonedLock.Lock()
+	defer p.abandonedLock.Unlock()
+	return p.abandoned[devPath]
+}
+
+// setAbandoned records that the probe was abandoned for the device, till it completes
+func (p *Probe) setAbandoned(devPath string, done <-chan struct{}) {
+	p.abandonedLock.Lock()
+	if p.abandoned == nil {
+		p.abandoned = make(map[string]bool)
+	}
+	p.abandoned[devPath] = true
+	p.abandonedLock.Unlock()
+
+	go func() {
+		<-done
+		p.abandonedLock.Lock()
+		delete(p.abandoned, devPath)
+		p.abandonedLock.Unlock()
+	}()
+}
+
+// copyBlockDevice returns a copy of the blockdevice, which does not share any
+// maps, slices or pointers with the original blockdevice
+func copyBlockDevice(bd blockdevice.BlockDevice) blockdevice.BlockDevice {
+	bdCopy := bd
+	bdCopy.Labels = copyStringMap(bd.Labels)
+	if bd.NodeAttributes != nil {
+		bdCopy.NodeAttributes = make(blockdevice.NodeAttribute, len(bd.NodeAttributes))
+		for k, v := range bd.NodeAttributes {
+			bdCopy.NodeAttributes[k] = v
+		}
+	}
+	bdCopy.UdevProperties = copyStringMap(bd.UdevProperties)
+	if bd.DevLinks != nil {
+		bdCopy.DevLinks = make([]blockdevice.DevLink, 0, len(bd.DevLinks))
+		for _, devLink := range bd.DevLinks {
+			devLink.Links = copyStrings(devLink.Links)
+			bdCopy.DevLinks = append(bdCopy.DevLinks, devLink)
+		}
+	}
+	bdCopy.DecisionTrace = bd.DecisionTrace.Copy()
+	bdCopy.FSInfo.MountPoint = copyStrings(bd.FSInfo.MountPoint)
+	bdCopy.FSInfo.Signatures = copyStrings(bd.FSInfo.Signatures)
+	bdCopy.PartitionInfo.UnexpectedSignatures = copyStrings(bd.PartitionInfo.UnexpectedSignatures)
+	bdCopy.DependentDevices.Partitions = copyStrings(bd.DependentDevices.Partitions)
+	bdCopy.DependentDevices.Holders = copyStrings(bd.DependentDevices.Holders)
+	bdCopy.DependentDevices.Slaves = copyStrings(bd.DependentDevices.Slaves)
+
+	attributes := &bdCopy.DeviceAttributes
+	if bd.DeviceAttributes.NUMANode != nil {
+		numaNode := *bd.DeviceAttributes.NUMANode
+		attributes.NUMANode = &numaNode
+	}
+	if bd.DeviceAttributes.WriteCacheEnabled != nil {
+		writeCacheEnabled := *bd.DeviceAttributes.WriteCacheEnabled
+		attributes.WriteCacheEnabled = &writeCacheEnabled
+	}
+	if bd.DeviceAttributes.PCIeLink != nil {
+		pcieLink := *bd.DeviceAttributes.PCIeLink
+		attributes.PCIeLink = &pcieLink
+	}
+	if bd.DeviceAttributes.IOErrors != nil {
+		ioErrors := *bd.DeviceAttributes.IOErrors
+		attributes.IOErrors = &ioErrors
+	}
+	if bd.DeviceAttributes.Writability != nil {
+		writability := *bd.DeviceAttributes.Writability
+		attributes.Writability = &writability
+	}
+	return bdCopy
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	mCopy := make(map[string]string, len(m))
+	for k, v := range m {
+		mCopy[k] = v
+	}
+	return mCopy
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
 // ProbeInterface contains Start() and  FillBlockDeviceDetails()
 type ProbeInterface interface {
 	Start()
 	FillBlockDeviceDetails(*blockdevice.BlockDevice)
 }
 
+// ContextProbeInterface is implemented by the probes that can be stopped before they
+// complete. The probe stops filling the details once the context is done.
+type ContextProbeInterface interface {
+	FillBlockDeviceDetailsWithContext(context.Context, *blockdevice.BlockDevice)
+}
+
 // sortableProbes contains a slice of probes
 type sortableProbes []*Probe
 
@@ -84,7 +238,9 @@ func (c *Controller) AddNewProbe(probe *Probe) {
 	defer c.Unlock()
 	probes := c.Probes
 	probes = append(probes, probe)
-	sort.Sort(sortableProbes(probes))
+	// stable sort, so that probes with the same priority are run in the
+	// order in which they were registered
+	sort.Stable(sortableProbes(probes))
 	c.Probes = probes
 	klog.Info("configured ", probe.Name, " : state ", util.StateStatus(probe.State))
 }
@@ -108,14 +264,31 @@ func (c *Controller) ListProbe(requestedProbes ...string) []*Probe {
 	return listProbe
 }
 
-// FillBlockDeviceDetails lists registered probes and fills details from each probe
-func (c *Controller) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevice,
+// FillBlockDeviceDetails lists registered probes and fills details from each probe.
+// Each probe is given the probe timeout to fill the details of the device, after which
+// it is abandoned and the device proceeds with the details filled by the other probes.
+func (c *Controller) FillBlockDeviceDetails(ctx context.Context, blockDevice *blockdevice.BlockDevice,
 	requestedProbes ...string) {
 	blockDevice.NodeAttributes = c.NodeAttributes
 	blockDevice.Labels = make(map[string]string)
 	selectedProbes := c.ListProbe(requestedProbes...)
 	for _, probe := range selectedProbes {
-		probe.FillBlockDeviceDetails(blockDevice)
+		timeout := probe.Timeout
+		if timeout == 0 {
+			timeout = c.ProbeTimeout
+		}
+		probeCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			probeCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		err := probe.FillBlockDeviceDetailsWithContext(probeCtx, blockDevice)
+		cancel()
+		if err != nil {
+			klog.Errorf("eventcode=%s msg=%s probe=%s timeout=%v err=%v rname=%v",
+				"ndm.probe.timeout", "Probe abandoned, details not filled by probe",
+				probe.Name, timeout, err, blockDevice.DevPath)
+			continue
+		}
 		klog.Info("details filled by ", probe.Name)
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -220,7 +221,7 @@ func TestFillDetails(t *testing.T) {
 	// create one fake Disk struct
 	actualDr := &bd.BlockDevice{}
 
-	fakeController.FillBlockDeviceDetails(actualDr)
+	fakeController.FillBlockDeviceDetails(context.TODO(), actualDr)
 	tests := map[string]struct {
 		actualDisk   *bd.BlockDevice
 		expectedDisk *bd.BlockDevice
@@ -233,3 +234,250 @@ func TestFillDetails(t *testing.T) {
 		})
 	}
 }
+
+// fakeSectorSizeProbe fills only the sector size of the blockdevice
+type fakeSectorSizeProbe struct{}
+
+func (np *fakeSectorSizeProbe) Start() {}
+
+func (np *fakeSectorSizeProbe) FillBlockDeviceDetails(fakeBlockDevice *bd.BlockDevice) {
+	fakeBlockDevice.DeviceAttributes.LogicalBlockSize = 512
+}
+
+func TestFillDetailsWithDisabledProbe(t *testing.T) {
+	tests := map[string]struct {
+		sectorSizeProbeState bool
+		expectedDisk         *bd.BlockDevice
+	}{
+		"all probes enabled": {
+			sectorSizeProbeState: true,
+			expectedDisk: &bd.BlockDevice{
+				Labels: make(map[string]string),
+				DeviceAttributes: bd.DeviceAttribute{
+					Model:            fakeModel,
+					Serial:           fakeSerial,
+					Vendor:           fakeVendor,
+					LogicalBlockSize: 512,
+				},
+			},
+		},
+		"disabled probe leaves its fields empty": {
+			sectorSizeProbeState: false,
+			expectedDisk: &bd.BlockDevice{
+				Labels: make(map[string]string),
+				DeviceAttributes: bd.DeviceAttribute{
+					Model:  fakeModel,
+					Serial: fakeSerial,
+					Vendor: fakeVendor,
+				},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeController := &Controller{
+				Probes: make([]*Probe, 0),
+				Mutex:  &sync.Mutex{},
+			}
+			fakeController.AddNewProbe(&Probe{
+				Priority:  2,
+				Name:      "sector size probe",
+				State:     test.sectorSizeProbeState,
+				Interface: &fakeSectorSizeProbe{},
+			})
+			fakeController.AddNewProbe(&Probe{
+				Priority:  1,
+				Name:      "probe1",
+				State:     true,
+				Interface: &fakeProbe{},
+			})
+
+			actualDisk := &bd.BlockDevice{}
+			fakeController.FillBlockDeviceDetails(context.TODO(), actualDisk)
+			assert.Equal(t, test.expectedDisk, actualDisk)
+		})
+	}
+}
+
+// fakeSlowProbe fills the firmware revision of the blockdevice after the given delay
+type fakeSlowProbe struct {
+	delay time.Duration
+}
+
+func (np *fakeSlowProbe) Start() {}
+
+func (np *fakeSlowProbe) FillBlockDeviceDetails(fakeBlockDevice *bd.BlockDevice) {
+	time.Sleep(np.delay)
+	fakeBlockDevice.DeviceAttributes.FirmwareRevision = "fake-firmware"
+}
+
+func TestFillDetailsWithProbeTimeout(t *testing.T) {
+	tests := map[string]struct {
+		probeTimeout time.Duration
+		// slowProbeTimeout is the timeout set on the slow probe
+		slowProbeTimeout     time.Duration
+		wantFirmwareRevision string
+	}{
+		"no timeout, slow probe fills details": {
+			probeTimeout:         0,
+			wantFirmwareRevision: "fake-firmware",
+		},
+		"slow probe completes within timeout": {
+			probeTimeout:         5 * time.Second,
+			wantFirmwareRevision: "fake-firmware",
+		},
+		"slow probe abandoned after timeout": {
+			probeTimeout:         10 * time.Millisecond,
+			wantFirmwareRevision: "",
+		},
+		"timeout of the probe overrides the default timeout": {
+			probeTimeout:         5 * time.Second,
+			slowProbeTimeout:     10 * time.Millisecond,
+			wantFirmwareRevision: "",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeController := &Controller{
+				Probes:       make([]*Probe, 0),
+				Mutex:        &sync.Mutex{},
+				ProbeTimeout: test.probeTimeout,
+			}
+			fakeController.AddNewProbe(&Probe{
+				Priority:  1,
+				Name:      "slow probe",
+				State:     true,
+				Timeout:   test.slowProbeTimeout,
+				Interface: &fakeSlowProbe{delay: 200 * time.Millisecond},
+			})
+			fakeController.AddNewProbe(&Probe{
+				Priority:  2,
+				Name:      "probe1",
+				State:     true,
+				Interface: &fakeProbe{},
+			})
+
+			actualDisk := &bd.BlockDevice{}
+			fakeController.FillBlockDeviceDetails(context.TODO(), actualDisk)
+
+			// details from the other probes are filled even if a probe is abandoned
+			assert.Equal(t, fakeModel, actualDisk.DeviceAttributes.Model)
+			assert.Equal(t, fakeSerial, actualDisk.DeviceAttributes.Serial)
+			assert.Equal(t, test.wantFirmwareRevision, actualDisk.DeviceAttributes.FirmwareRevision)
+		})
+	}
+}
+
+func TestCopyBlockDevice(t *testing.T) {
+	numaNode := 1
+	writeCacheEnabled := true
+	original := bd.BlockDevice{
+		Labels:         map[string]string{"label": "value"},
+		UdevProperties: map[string]string{"ID_MODEL": fakeModel},
+		DevLinks: []bd.DevLink{
+			{Kind: "by-id", Links: []string{"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4"}},
+		},
+		DecisionTrace: &bd.DecisionTrace{},
+		DeviceAttributes: bd.DeviceAttribute{
+			NUMANode:          &numaNode,
+			WriteCacheEnabled: &writeCacheEnabled,
+			IOErrors:          &bd.IOErrorState{Count: 1},
+		},
+		DependentDevices: bd.DependentBlockDevices{
+			Partitions: []string{"/dev/sda1"},
+			Holders:    []string{"/dev/dm-0"},
+		},
+	}
+
+	bdCopy := copyBlockDevice(original)
+	bdCopy.Labels["label"] = "changed"
+	bdCopy.UdevProperties["ID_MODEL"] = "changed"
+	bdCopy.DevLinks[0].Links[0] = "changed"
+	bdCopy.DecisionTrace.Add("changed")
+	*bdCopy.DeviceAttributes.NUMANode = 2
+	*bdCopy.DeviceAttributes.WriteCacheEnabled = false
+	bdCopy.DeviceAttributes.IOErrors.Count = 2
+	bdCopy.DependentDevices.Partitions[0] = "changed"
+	bdCopy.DependentDevices.Holders[0] = "changed"
+
+	assert.Equal(t, "value", original.Labels["label"])
+	assert.Equal(t, fakeModel, original.UdevProperties["ID_MODEL"])
+	assert.Equal(t, "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4", original.DevLinks[0].Links[0])
+	assert.Empty(t, original.DecisionTrace.Steps())
+	assert.Equal(t, 1, *original.DeviceAttributes.NUMANode)
+	assert.True(t, *original.DeviceAttributes.WriteCacheEnabled)
+	assert.Equal(t, uint64(1), original.DeviceAttributes.IOErrors.Count)
+	assert.Equal(t, "/dev/sda1", original.DependentDevices.Partitions[0])
+	assert.Equal(t, "/dev/dm-0", original.DependentDevices.Holders[0])
+}
+
+// fakeContextProbe blocks until its context is done
+type fakeContextProbe struct {
+	calls   int
+	stopped chan struct{}
+}
+
+func (np *fakeContextProbe) Start() {}
+
+func (np *fakeContextProbe) FillBlockDeviceDetails(fakeBlockDevice *bd.BlockDevice) {
+	np.FillBlockDeviceDetailsWithContext(context.Background(), fakeBlockDevice)
+}
+
+func (np *fakeContextProbe) FillBlockDeviceDetailsWithContext(ctx context.Context, fakeBlockDevice *bd.BlockDevice) {
+	np.calls++
+	<-ctx.Done()
+	np.stopped <- struct{}{}
+}
+
+func TestFillDetailsWithContextProbe(t *testing.T) {
+	probe := &fakeContextProbe{
+		stopped: make(chan struct{}, 1),
+	}
+	fakeController := &Controller{
+		Probes:       make([]*Probe, 0),
+		Mutex:        &sync.Mutex{},
+		ProbeTimeout: 10 * time.Millisecond,
+	}
+	fakeController.AddNewProbe(&Probe{
+		Priority:  1,
+		Name:      "context probe",
+		State:     true,
+		Interface: probe,
+	})
+
+	// the probe is stopped once it times out
+	fakeController.FillBlockDeviceDetails(context.TODO(), &bd.BlockDevice{Identifier: bd.Identifier{DevPath: "/dev/sda"}})
+	select {
+	case <-probe.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("probe not stopped after the timeout")
+	}
+	assert.Equal(t, 1, probe.calls)
+}
+
+func TestFillDetailsWithAbandonedProbe(t *testing.T) {
+	probe := &Probe{
+		Name:      "slow probe",
+		State:     true,
+		Interface: &fakeSlowProbe{delay: 200 * time.Millisecond},
+	}
+	device := &bd.BlockDevice{Identifier: bd.Identifier{DevPath: "/dev/sda"}}
+
+	// the probe is abandoned, but keeps running as it cannot be stopped
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	assert.Error(t, probe.FillBlockDeviceDetailsWithContext(ctx, device))
+
+	// the probe is not run again for the device till the abandoned probe completes
+	ctx, cancel = context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	assert.Equal(t, errProbeStillRunning, probe.FillBlockDeviceDetailsWithContext(ctx, device))
+	otherDevice := &bd.BlockDevice{Identifier: bd.Identifier{DevPath: "/dev/sdb"}}
+	assert.NoError(t, probe.FillBlockDeviceDetailsWithContext(ctx, otherDevice))
+
+	assert.Eventually(t, func() bool {
+		return !probe.isAbandoned(device.DevPath)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, probe.FillBlockDeviceDetailsWithContext(ctx, device))
+	assert.Equal(t, "fake-firmware", device.DeviceAttributes.FirmwareRevision)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProtectionLevel defines which destructive operations are performed on a claimed
+// BlockDevice, or on a BlockDevice having an outstanding claim
+type ProtectionLevel string
+
+const (
+	// NormalProtection never writes to a protected device, or takes it out of service
+	// because of a change in its usage. The resource of a protected device removed from
+	// the node is still deactivated as per the removal policy.
+	NormalProtection ProtectionLevel = "normal"
+
+	// StrictProtection never touches a protected device, not even its resource when the
+	// device is removed from the node
+	StrictProtection ProtectionLevel = "strict"
+
+	// DefaultProtectionLevel is the protection level used if none is specified
+	DefaultProtectionLevel = NormalProtection
+)
+
+// DeactivateParentBlockDeviceOperation is marking the BlockDevice resource of a disk as
+// Inactive, because a consumer created partitions on the disk
+const DeactivateParentBlockDeviceOperation DestructiveOperation = "deactivate-parent-blockdevice"
+
+// claimIndexTTL is the time for which the listed claims are used to find the outstanding
+// claim of a BlockDevice, before they are listed again
+const claimIndexTTL = 10 * time.Second
+
+// claimIndex is the name of the outstanding claim of each BlockDevice, keyed by the name
+// of the BlockDevice. The claims are listed at most once in claimIndexTTL.
+type claimIndex struct {
+	mutex    sync.Mutex
+	listedAt time.Time
+	claims   map[string]string
+}
+
+// targetIndexTTL is the time for which the listed BlockDevices are used to find the
+// BlockDevice at the path of a device, before they are listed again
+const targetIndexTTL = 10 * time.Second
+
+// targetIndex is the name of the BlockDevice in use at each device path on this node,
+// keyed by the path. Inactive BlockDevices are left out, and an Active BlockDevice is
+// preferred over the others at the same path. The BlockDevices are listed at most once
+// in targetIndexTTL.
+type targetIndex struct {
+	mutex    sync.Mutex
+	listedAt time.Time
+	paths    map[string]string
+}
+
+// ParseProtectionLevel validates and returns the protection level.
+// Empty value is treated as the default level.
+func ParseProtectionLevel(level string) (ProtectionLevel, error) {
+	switch ProtectionLevel(level) {
+	case "":
+		return DefaultProtectionLevel, nil
+	case NormalProtection, StrictProtection:
+		return ProtectionLevel(level), nil
+	}
+	return "", fmt.Errorf("invalid protection level: %q, should be one of %s, %s",
+		level, NormalProtection, StrictProtection)
+}
+
+// IsProtected checks whether the destructive operation should not be performed on the
+// BlockDevice, because it is claimed, or has an outstanding claim, as per the protection
+// level. Every destructive operation on a BlockDevice is gated by this check.
+func (c *Controller) IsProtected(blockDevice apis.BlockDevice, op DestructiveOperation) bool {
+	if c.ProtectionLevel != StrictProtection && isResourceOperation(op) {
+		return false
+	}
+	reason := c.GetProtectionReason(blockDevice)
+	if reason == "" {
+		return false
+	}
+	klog.Warningf("eventcode=%s msg=%s op=%s reason=%q rname=%v",
+		"ndm.protection.blocked", "Destructive operation blocked on protected blockdevice",
+		op, reason, blockDevice.Name)
+	return true
+}
+
+// GetProtectionReason gets the reason for which the BlockDevice is protected by a claim,
+// irrespective of the protection level. Empty string is returned if the BlockDevice is
+// not protected. If the claims cannot be listed, the BlockDevice is protected.
+func (c *Controller) GetProtectionReason(blockDevice apis.BlockDevice) string {
+	if blockDevice.Status.ClaimState != apis.BlockDeviceUnclaimed {
+		return fmt.Sprintf("claim state is %s", blockDevice.Status.ClaimState)
+	}
+	if blockDevice.Spec.ClaimRef != nil {
+		return fmt.Sprintf("claimed by %s", blockDevice.Spec.ClaimRef.Name)
+	}
+
+	claim, err := c.getOutstandingClaim(blockDevice.Name)
+	if err != nil {
+		return fmt.Sprintf("unable to list claims: %v", err)
+	}
+	if claim != "" {
+		return fmt.Sprintf("outstanding claim %s", claim)
+	}
+	return ""
+}
+
+// getOutstandingClaim gets the name of the claim for the BlockDevice from the claim
+// index. The claims are listed again if the index is older than claimIndexTTL.
+func (c *Controller) getOutstandingClaim(name string) (string, error) {
+	c.claims.mutex.Lock()
+	defer c.claims.mutex.Unlock()
+
+	if c.claims.claims == nil || time.Since(c.claims.listedAt) >= claimIndexTTL {
+		claimList := &apis.BlockDeviceClaimList{}
+		if err := c.Clientset.List(context.TODO(), claimList, client.InNamespace(c.Namespace)); err != nil {
+			return "", err
+		}
+		claims := make(map[string]string, len(claimList.Items))
+		for _, claim := range claimList.Items {
+			if claim.Spec.BlockDeviceName != "" {
+				claims[claim.Spec.BlockDeviceName] = claim.Name
+			}
+		}
+		c.claims.claims = claims
+		c.claims.listedAt = time.Now()
+	}
+	return c.claims.claims[name], nil
+}
+
+// isTargetProtected checks if the target of the destructive operation is a protected
+// BlockDevice. The target is the name of the BlockDevice for the operations on a
+// resource, and the path of the device for the operations on a disk. If the BlockDevice
+// of the target cannot be looked up, the target is protected. A target that does not
+// have a BlockDevice, eg: a blank disk that is yet to be partitioned, cannot be claimed
+// and is not protected.
+func (c *Controller) isTargetProtected(op DestructiveOperation, target string) bool {
+	if c.ProtectionLevel != StrictProtection && isResourceOperation(op) {
+		return false
+	}
+	blockDevice, ok, err := c.getOperationTarget(op, target)
+	if err != nil {
+		klog.Warningf("eventcode=%s msg=%s op=%s reason=%q rname=%v",
+			"ndm.protection.blocked", "Destructive operation blocked on protected blockdevice",
+			op, fmt.Sprintf("unable to get blockdevice: %v", err), target)
+		return true
+	}
+	if !ok {
+		return false
+	}
+	return c.IsProtected(blockDevice, op)
+}
+
+// getOperationTarget gets the BlockDevice of the target of the destructive operation.
+// For the operations on a disk, it is the BlockDevice in use at the path of the disk on
+// this node, so that a stale resource left at a reused path is not mistaken for the
+// resource of the disk. false is returned if the target does not have a BlockDevice.
+func (c *Controller) getOperationTarget(op DestructiveOperation, target string) (apis.BlockDevice, bool, error) {
+	name := target
+	if !isResourceOperation(op) {
+		var err error
+		if name, err = c.getBlockDeviceAtPath(target); err != nil {
+			return apis.BlockDevice{}, false, err
+		}
+		if name == "" {
+			return apis.BlockDevice{}, false, nil
+		}
+	}
+
+	blockDevice := &apis.BlockDevice{}
+	err := c.Clientset.Get(context.TODO(), client.ObjectKey{Namespace: c.Namespace, Name: name}, blockDevice)
+	if errors.IsNotFound(err) {
+		return apis.BlockDevice{}, false, nil
+	}
+	if err != nil {
+		return apis.BlockDevice{}, false, err
+	}
+	return *blockDevice, true, nil
+}
+
+// getBlockDeviceAtPath gets the name of the BlockDevice in use at the path from the
+// target index. The BlockDevices are listed again if the index is older than
+// targetIndexTTL.
+func (c *Controller) getBlockDeviceAtPath(path string) (string, error) {
+	c.targets.mutex.Lock()
+	defer c.targets.mutex.Unlock()
+
+	if c.targets.paths == nil || time.Since(c.targets.listedAt) >= targetIndexTTL {
+		bdList, err := c.ListBlockDeviceResource(false)
+		if err != nil {
+			return "", err
+		}
+		paths := make(map[string]string, len(bdList.Items))
+		active := make(map[string]bool, len(bdList.Items))
+		for _, blockDevice := range bdList.Items {
+			if blockDevice.Status.State == NDMInactive || active[blockDevice.Spec.Path] {
+				continue
+			}
+			paths[blockDevice.Spec.Path] = blockDevice.Name
+			active[blockDevice.Spec.Path] = blockDevice.Status.State == NDMActive
+		}
+		c.targets.paths = paths
+		c.targets.listedAt = time.Now()
+	}
+	return c.targets.paths[path], nil
+}
+
+// isResourceOperation checks if the destructive operation is performed on the
+// BlockDevice resource, rather than on the disk
+func isResourceOperation(op DestructiveOperation) bool {
+	return op == DeactivateBlockDeviceOperation || op == DeleteBlockDeviceOperation
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newProtectionTestClient(objects ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+	return fake.NewFakeClientWithScheme(s, objects...)
+}
+
+func TestParseProtectionLevel(t *testing.T) {
+	tests := map[string]struct {
+		level   string
+		want    ProtectionLevel
+		wantErr bool
+	}{
+		"empty level uses the default": {
+			level: "",
+			want:  NormalProtection,
+		},
+		"normal level": {
+			level: "normal",
+			want:  NormalProtection,
+		},
+		"strict level": {
+			level: "strict",
+			want:  StrictProtection,
+		},
+		"invalid level": {
+			level:   "paranoid",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseProtectionLevel(tt.level)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsProtected(t *testing.T) {
+	unclaimed := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceUnclaimed)
+	claimed := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceClaimed)
+	released := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceReleased)
+	withClaimRef := unclaimed
+	withClaimRef.Spec.ClaimRef = &v1.ObjectReference{Name: "bdc-bound"}
+	pendingClaim := &apis.BlockDeviceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "bdc-pending"},
+		Spec:       apis.DeviceClaimSpec{BlockDeviceName: fakeDeviceUID},
+	}
+	otherClaim := &apis.BlockDeviceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "bdc-other"},
+		Spec:       apis.DeviceClaimSpec{BlockDeviceName: "blockdevice-other"},
+	}
+
+	tests := map[string]struct {
+		blockDevice apis.BlockDevice
+		claims      []runtime.Object
+		level       ProtectionLevel
+		op          DestructiveOperation
+		want        bool
+	}{
+		"unclaimed blockdevice is not protected": {
+			blockDevice: unclaimed,
+			claims:      []runtime.Object{otherClaim},
+			level:       NormalProtection,
+			op:          WipeSignaturesOperation,
+			want:        false,
+		},
+		"claimed blockdevice is protected from wipe": {
+			blockDevice: claimed,
+			level:       NormalProtection,
+			op:          WipeSignaturesOperation,
+			want:        true,
+		},
+		"released blockdevice is protected from partition delete": {
+			blockDevice: released,
+			level:       NormalProtection,
+			op:          DeletePartitionOperation,
+			want:        true,
+		},
+		"claimed parent is protected from deactivation": {
+			blockDevice: claimed,
+			level:       NormalProtection,
+			op:          DeactivateParentBlockDeviceOperation,
+			want:        true,
+		},
+		"blockdevice with a claim reference is protected": {
+			blockDevice: withClaimRef,
+			level:       NormalProtection,
+			op:          DeactivateParentBlockDeviceOperation,
+			want:        true,
+		},
+		"blockdevice with an outstanding claim is protected": {
+			blockDevice: unclaimed,
+			claims:      []runtime.Object{pendingClaim},
+			level:       NormalProtection,
+			op:          WipeSignaturesOperation,
+			want:        true,
+		},
+		"claimed blockdevice is deactivated on removal in normal level": {
+			blockDevice: claimed,
+			level:       NormalProtection,
+			op:          DeactivateBlockDeviceOperation,
+			want:        false,
+		},
+		"claimed blockdevice is not deactivated in strict level": {
+			blockDevice: claimed,
+			level:       StrictProtection,
+			op:          DeactivateBlockDeviceOperation,
+			want:        true,
+		},
+		"blockdevice with an outstanding claim is not deleted in strict level": {
+			blockDevice: unclaimed,
+			claims:      []runtime.Object{pendingClaim},
+			level:       StrictProtection,
+			op:          DeleteBlockDeviceOperation,
+			want:        true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				Clientset:       newProtectionTestClient(tt.claims...),
+				ProtectionLevel: tt.level,
+			}
+			assert.Equal(t, tt.want, c.IsProtected(tt.blockDevice, tt.op))
+		})
+	}
+}
+
+func TestRemoveBlockDeviceWithStrictProtection(t *testing.T) {
+	tests := map[string]struct {
+		claimState apis.DeviceClaimState
+		wantState  string
+	}{
+		"unclaimed blockdevice is deactivated": {
+			claimState: apis.BlockDeviceUnclaimed,
+			wantState:  NDMInactive,
+		},
+		"claimed blockdevice is not touched": {
+			claimState: apis.BlockDeviceClaimed,
+			wantState:  NDMActive,
+		},
+		"released blockdevice is not touched": {
+			claimState: apis.BlockDeviceReleased,
+			wantState:  NDMActive,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NodeAttributes:  map[string]string{HostNameKey: fakeHostName},
+				Clientset:       newProtectionTestClient(),
+				RemovalPolicy:   DeleteOnRemoval,
+				ProtectionLevel: StrictProtection,
+			}
+			if tt.claimState == apis.BlockDeviceUnclaimed {
+				c.RemovalPolicy = DeactivateOnRemoval
+			}
+			bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", tt.claimState)
+			if err := c.CreateBlockDevice(bd); err != nil {
+				t.Fatal(err)
+			}
+			created, err := c.GetBlockDevice(fakeDeviceUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.RemoveBlockDevice(*created)
+
+			got, err := c.GetBlockDevice(fakeDeviceUID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantState, string(got.Status.State))
+		})
+	}
+}
+
+func TestDestructiveOperationOnProtectedBlockDevice(t *testing.T) {
+	tests := map[string]struct {
+		claimState apis.DeviceClaimState
+		level      ProtectionLevel
+		op         DestructiveOperation
+		target     string
+		want       bool
+	}{
+		"wipe of unclaimed blockdevice is allowed": {
+			claimState: apis.BlockDeviceUnclaimed,
+			level:      NormalProtection,
+			op:         WipeSignaturesOperation,
+			target:     "/dev/sda",
+			want:       true,
+		},
+		"discard of claimed blockdevice is blocked": {
+			claimState: apis.BlockDeviceClaimed,
+			level:      NormalProtection,
+			op:         DiscardOperation,
+			target:     "/dev/sda",
+			want:       false,
+		},
+		"write cache disable of device without a blockdevice is allowed": {
+			claimState: apis.BlockDeviceClaimed,
+			level:      NormalProtection,
+			op:         DisableWriteCacheOperation,
+			target:     "/dev/sdb",
+			want:       true,
+		},
+		"deactivation of claimed blockdevice is allowed in normal level": {
+			claimState: apis.BlockDeviceClaimed,
+			level:      NormalProtection,
+			op:         DeactivateBlockDeviceOperation,
+			target:     fakeDeviceUID,
+			want:       true,
+		},
+		"deactivation of claimed blockdevice is blocked in strict level": {
+			claimState: apis.BlockDeviceClaimed,
+			level:      StrictProtection,
+			op:         DeactivateBlockDeviceOperation,
+			target:     fakeDeviceUID,
+			want:       false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", tt.claimState)
+			c := &Controller{
+				NodeAttributes:  map[string]string{HostNameKey: fakeHostName},
+				Clientset:       newProtectionTestClient(&bd),
+				ProtectionLevel: tt.level,
+			}
+			assert.Equal(t, tt.want, c.IsDestructiveOperationAllowed(tt.op, tt.target))
+		})
+	}
+}
+
+func TestDestructiveOperationAtReusedPath(t *testing.T) {
+	staleClaimed := newRemovalTestDevice("blockdevice-stale", "/dev/sda", apis.BlockDeviceClaimed)
+	staleClaimed.Status.State = NDMInactive
+	activeUnclaimed := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceUnclaimed)
+	activeClaimed := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceClaimed)
+
+	tests := map[string]struct {
+		blockDevices []runtime.Object
+		want         bool
+	}{
+		"stale claimed blockdevice does not protect the new disk": {
+			blockDevices: []runtime.Object{&staleClaimed, &activeUnclaimed},
+			want:         true,
+		},
+		"active claimed blockdevice of the new disk is protected": {
+			blockDevices: []runtime.Object{&staleClaimed, &activeClaimed},
+			want:         false,
+		},
+		"disk with only a stale blockdevice is not protected": {
+			blockDevices: []runtime.Object{&staleClaimed},
+			want:         true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NodeAttributes:  map[string]string{HostNameKey: fakeHostName},
+				Clientset:       newProtectionTestClient(tt.blockDevices...),
+				ProtectionLevel: NormalProtection,
+			}
+			assert.Equal(t, tt.want, c.IsDestructiveOperationAllowed(WipeSignaturesOperation, "/dev/sda"))
+		})
+	}
+}
+
+func TestTargetIndex(t *testing.T) {
+	cl := newProtectionTestClient()
+	c := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      cl,
+	}
+	assert.True(t, c.IsDestructiveOperationAllowed(WipeSignaturesOperation, "/dev/sda"))
+
+	bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceClaimed)
+	assert.NoError(t, cl.Create(context.TODO(), &bd))
+	// the blockdevices are not listed again till the index expires
+	assert.True(t, c.IsDestructiveOperationAllowed(WipeSignaturesOperation, "/dev/sda"))
+
+	c.targets.listedAt = time.Now().Add(-targetIndexTTL)
+	assert.False(t, c.IsDestructiveOperationAllowed(WipeSignaturesOperation, "/dev/sda"))
+}
+
+func TestOutstandingClaimIndex(t *testing.T) {
+	cl := newProtectionTestClient()
+	c := &Controller{Clientset: cl}
+	bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", apis.BlockDeviceUnclaimed)
+	assert.False(t, c.IsProtected(bd, WipeSignaturesOperation))
+
+	claim := &apis.BlockDeviceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "bdc-pending"},
+		Spec:       apis.DeviceClaimSpec{BlockDeviceName: fakeDeviceUID},
+	}
+	assert.NoError(t, cl.Create(context.TODO(), claim))
+
+	// the claims are listed again once the index expires
+	c.claims.listedAt = time.Now().Add(-claimIndexTTL)
+	assert.True(t, c.IsProtected(bd, WipeSignaturesOperation))
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultProvisioningTimeout is the default time after which NDM stops waiting for
+	// the provisioning markers and starts partitioning blank disks
+	DefaultProvisioningTimeout = 10 * time.Minute
+
+	// provisioningPollInterval is the interval at which the provisioning markers are checked
+	provisioningPollInterval = 5 * time.Second
+)
+
+// StartProvisioningWatcher starts waiting for the provisioning markers, like the result
+// file written by cloud-init (/run/cloud-init/result.json) on completion. Till all the
+// markers are present, or the timeout expires, the node is considered to be still being
+// provisioned and blank disks are not partitioned, so that NDM does not race with the
+// provisioning tools partitioning / formatting the data disks.
+func (c *Controller) StartProvisioningWatcher(markers []string, timeout time.Duration) {
+	if len(markers) == 0 {
+		return
+	}
+	c.provisioningDone = make(chan struct{})
+	go c.waitForProvisioning(markers, timeout, provisioningPollInterval)
+}
+
+// waitForProvisioning polls for the markers at the given interval and closes
+// provisioningDone once all the markers are present or the timeout expires.
+// A timeout of zero waits forever.
+func (c *Controller) waitForProvisioning(markers []string, timeout, interval time.Duration) {
+	defer close(c.provisioningDone)
+
+	klog.Infof("waiting for provisioning markers: %v before partitioning blank disks", markers)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if areMarkersPresent(markers) {
+			klog.Info("provisioning of the node completed, blank disks can be partitioned")
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			klog.Warningf("provisioning markers: %v not found after %v, blank disks can be partitioned",
+				markers, timeout)
+			return
+		}
+	}
+}
+
+// areMarkersPresent checks if all the marker files exist
+func areMarkersPresent(markers []string) bool {
+	for _, marker := range markers {
+		if _, err := os.Stat(marker); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IsNodeProvisioned checks whether the provisioning of the node has completed. It is
+// always true if no provisioning markers are configured.
+func (c *Controller) IsNodeProvisioned() bool {
+	if c.provisioningDone == nil {
+		return true
+	}
+	select {
+	case <-c.provisioningDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProvisioningDone returns the channel that is closed once the provisioning of the node
+// has completed. nil is returned if no provisioning markers are configured, so that the
+// receive blocks forever.
+func (c *Controller) ProvisioningDone() <-chan struct{} {
+	return c.provisioningDone
+}
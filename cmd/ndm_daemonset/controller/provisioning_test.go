@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForProvisioning(t *testing.T) {
+	tests := map[string]struct {
+		// markersPresent are the markers that are created before waiting
+		markersPresent []string
+		// markersCreatedLater are the markers that are created while waiting
+		markersCreatedLater []string
+		timeout             time.Duration
+		wantProvisioned     bool
+	}{
+		"all markers present": {
+			markersPresent:  []string{"result.json", ".ignition-result.json"},
+			wantProvisioned: true,
+		},
+		"marker created while waiting": {
+			markersPresent:      []string{".ignition-result.json"},
+			markersCreatedLater: []string{"result.json"},
+			wantProvisioned:     true,
+		},
+		"marker not created, provisioned after timeout": {
+			markersPresent:  []string{".ignition-result.json"},
+			timeout:         50 * time.Millisecond,
+			wantProvisioned: true,
+		},
+		"marker not created, waits forever without timeout": {
+			markersPresent:  []string{".ignition-result.json"},
+			wantProvisioned: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			markers := []string{filepath.Join(dir, "result.json"), filepath.Join(dir, ".ignition-result.json")}
+			createMarkers := func(names []string) {
+				for _, name := range names {
+					if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			createMarkers(tt.markersPresent)
+
+			c := &Controller{
+				provisioningDone: make(chan struct{}),
+			}
+			go c.waitForProvisioning(markers, tt.timeout, 10*time.Millisecond)
+
+			if len(tt.markersCreatedLater) > 0 {
+				time.Sleep(50 * time.Millisecond)
+				assert.False(t, c.IsNodeProvisioned())
+				createMarkers(tt.markersCreatedLater)
+			}
+
+			select {
+			case <-c.ProvisioningDone():
+			case <-time.After(500 * time.Millisecond):
+			}
+			assert.Equal(t, tt.wantProvisioned, c.IsNodeProvisioned())
+		})
+	}
+
+	t.Run("no markers configured", func(t *testing.T) {
+		c := &Controller{}
+		c.StartProvisioningWatcher(nil, DefaultProvisioningTimeout)
+		assert.True(t, c.IsNodeProvisioned())
+		assert.Nil(t, c.ProvisioningDone())
+	})
+}
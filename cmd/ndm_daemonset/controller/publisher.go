@@ -0,0 +1,248 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultInventoryEventQueueSize is the number of inventory events buffered for the
+	// sink. Events emitted while the buffer is full are dropped.
+	DefaultInventoryEventQueueSize = 1000
+
+	// inventoryEventPublishTimeout is the time allowed for publishing an event to the sink
+	inventoryEventPublishTimeout = 10 * time.Second
+)
+
+// InventoryEventType is the type of change of a BlockDevice resource
+type InventoryEventType string
+
+const (
+	// InventoryEventCreated is emitted when the resource of a device is created
+	InventoryEventCreated InventoryEventType = "created"
+	// InventoryEventUpdated is emitted when the resource of a device is updated
+	InventoryEventUpdated InventoryEventType = "updated"
+	// InventoryEventDeactivated is emitted when the resource of a device is deactivated
+	InventoryEventDeactivated InventoryEventType = "deactivated"
+	// InventoryEventClaimed is emitted when the resource is seen claimed, after it was
+	// seen unclaimed
+	InventoryEventClaimed InventoryEventType = "claimed"
+	// InventoryEventUnclaimed is emitted when the resource is seen unclaimed, after it
+	// was seen claimed
+	InventoryEventUnclaimed InventoryEventType = "unclaimed"
+)
+
+// InventoryEvent is a change in the inventory of BlockDevices of the node
+type InventoryEvent struct {
+	Type       InventoryEventType    `json:"type"`
+	Name       string                `json:"name"`
+	Path       string                `json:"path"`
+	NodeName   string                `json:"nodeName"`
+	State      apis.BlockDeviceState `json:"state"`
+	ClaimState apis.DeviceClaimState `json:"claimState"`
+	Time       time.Time             `json:"time"`
+}
+
+// Publisher publishes inventory events to a sink, like a webhook or a message queue.
+// Publish is never called concurrently, in the order in which the events were emitted.
+type Publisher interface {
+	Publish(ctx context.Context, event InventoryEvent) error
+}
+
+// NewPublisher creates the publisher for the sink given as an URL. The scheme of the
+// URL decides the type of the sink.
+func NewPublisher(sink string) (Publisher, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory event sink: %q, %v", sink, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPPublisher{URL: sink, Client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("invalid inventory event sink: %q, unsupported scheme: %q", sink, u.Scheme)
+	}
+}
+
+// HTTPPublisher POSTs each event as JSON to the URL
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+// Publish implements Publisher
+func (p *HTTPPublisher) Publish(ctx context.Context, event InventoryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink %s returned status %d", p.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// InventoryPublisher buffers the inventory events and publishes them to the sink outside
+// the processing of the devices. Emitting an event never blocks, the events are dropped
+// if the buffer is full, so that a slow sink does not stall the reconciliation.
+type InventoryPublisher struct {
+	publisher Publisher
+	queue     chan InventoryEvent
+	// dropped is the number of events dropped because the buffer was full
+	dropped uint64
+
+	claimStatesMutex sync.Mutex
+	// claimStates is the claim state of the resources seen last, used to find the
+	// claim state transitions
+	claimStates map[string]apis.DeviceClaimState
+}
+
+// NewInventoryPublisher creates an InventoryPublisher buffering queueSize events, and
+// starts publishing the events to the publisher
+func NewInventoryPublisher(publisher Publisher, queueSize int) *InventoryPublisher {
+	ip := &InventoryPublisher{
+		publisher:   publisher,
+		queue:       make(chan InventoryEvent, queueSize),
+		claimStates: make(map[string]apis.DeviceClaimState),
+	}
+	go ip.run()
+	return ip
+}
+
+// Emit queues the event to be published. false is returned if the event was dropped.
+func (ip *InventoryPublisher) Emit(event InventoryEvent) bool {
+	select {
+	case ip.queue <- event:
+		return true
+	default:
+		atomic.AddUint64(&ip.dropped, 1)
+		return false
+	}
+}
+
+// Dropped gets the number of events dropped because the buffer was full
+func (ip *InventoryPublisher) Dropped() uint64 {
+	return atomic.LoadUint64(&ip.dropped)
+}
+
+// observeClaimState records the claim state of the resource and returns the event type
+// if the claim state has changed since the resource was seen last. The first observation
+// only records the state, so that restarting the daemon does not replay the transitions.
+func (ip *InventoryPublisher) observeClaimState(name string, state apis.DeviceClaimState) (InventoryEventType, bool) {
+	ip.claimStatesMutex.Lock()
+	defer ip.claimStatesMutex.Unlock()
+	previous, seen := ip.claimStates[name]
+	ip.claimStates[name] = state
+	if !seen || previous == state {
+		return "", false
+	}
+	switch {
+	case state == apis.BlockDeviceClaimed:
+		return InventoryEventClaimed, true
+	case previous == apis.BlockDeviceClaimed:
+		return InventoryEventUnclaimed, true
+	}
+	return "", false
+}
+
+// run publishes the queued events
+func (ip *InventoryPublisher) run() {
+	for event := range ip.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), inventoryEventPublishTimeout)
+		if err := ip.publisher.Publish(ctx, event); err != nil {
+			klog.Errorf("unable to publish %s event of %s: %v", event.Type, event.Name, err)
+		}
+		cancel()
+	}
+}
+
+// newInventoryEvent creates the event of the given type for the resource
+func newInventoryEvent(eventType InventoryEventType, blockDevice apis.BlockDevice) InventoryEvent {
+	return InventoryEvent{
+		Type:       eventType,
+		Name:       blockDevice.Name,
+		Path:       blockDevice.Spec.Path,
+		NodeName:   blockDevice.Spec.NodeAttributes.NodeName,
+		State:      blockDevice.Status.State,
+		ClaimState: blockDevice.Status.ClaimState,
+		Time:       time.Now(),
+	}
+}
+
+// PublishInventoryEvent emits the event of the given type for the resource, along with
+// the claim state transition of the resource, if any. It is a no-op if no inventory
+// event sink is configured.
+func (c *Controller) PublishInventoryEvent(eventType InventoryEventType, blockDevice apis.BlockDevice) {
+	if c.InventoryPublisher == nil {
+		return
+	}
+	events := []InventoryEvent{newInventoryEvent(eventType, blockDevice)}
+	if claimEventType, ok := c.InventoryPublisher.observeClaimState(blockDevice.Name, blockDevice.Status.ClaimState); ok {
+		events = append(events, newInventoryEvent(claimEventType, blockDevice))
+	}
+	c.emitInventoryEvents(events)
+}
+
+// PublishClaimStateTransition emits only the claim state transition of the resource, if
+// any. It is used for a resource that was not written, as the claim state is changed by
+// the operator.
+func (c *Controller) PublishClaimStateTransition(blockDevice apis.BlockDevice) {
+	if c.InventoryPublisher == nil {
+		return
+	}
+	if claimEventType, ok := c.InventoryPublisher.observeClaimState(blockDevice.Name, blockDevice.Status.ClaimState); ok {
+		c.emitInventoryEvents([]InventoryEvent{newInventoryEvent(claimEventType, blockDevice)})
+	}
+}
+
+// emitInventoryEvents emits the events to the publisher, counting the dropped events
+func (c *Controller) emitInventoryEvents(events []InventoryEvent) {
+	for _, event := range events {
+		if c.InventoryPublisher.Emit(event) {
+			continue
+		}
+		klog.Warningf("eventcode=%s msg=%s type=%s rname=%v",
+			"ndm.blockdevice.inventory.event.dropped", "Inventory event buffer full, dropping event",
+			event.Type, event.Name)
+		if c.Metrics != nil {
+			c.Metrics.IncInventoryEventsDroppedCounter()
+		}
+	}
+}
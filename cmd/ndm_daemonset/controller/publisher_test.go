@@ -0,0 +1,275 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/pkg/metrics/daemon"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// blockingPublisher records the published events, blocking each publish until released
+type blockingPublisher struct {
+	mutex     sync.Mutex
+	published []InventoryEvent
+	// received gets the event as soon as the publish starts
+	received chan InventoryEvent
+	// release unblocks the publish
+	release chan struct{}
+}
+
+func newBlockingPublisher() *blockingPublisher {
+	return &blockingPublisher{
+		received: make(chan InventoryEvent, 100),
+		release:  make(chan struct{}),
+	}
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, event InventoryEvent) error {
+	p.received <- event
+	<-p.release
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *blockingPublisher) getPublished() []InventoryEvent {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]InventoryEvent(nil), p.published...)
+}
+
+func TestInventoryPublisherDropsOnBackpressure(t *testing.T) {
+	queueSize := 3
+	publisher := newBlockingPublisher()
+	ip := NewInventoryPublisher(publisher, queueSize)
+
+	event := func(name string) InventoryEvent {
+		return InventoryEvent{Type: InventoryEventCreated, Name: name}
+	}
+
+	// the first event is being published and blocks the sink
+	assert.True(t, ip.Emit(event("blockdevice-0")))
+	<-publisher.received
+
+	// the buffer is filled, and the events beyond it are dropped without blocking
+	emitted := make(chan []bool)
+	go func() {
+		var results []bool
+		for i := 1; i <= queueSize+2; i++ {
+			results = append(results, ip.Emit(event(fmt.Sprintf("blockdevice-%d", i))))
+		}
+		emitted <- results
+	}()
+	select {
+	case results := <-emitted:
+		assert.Equal(t, []bool{true, true, true, false, false}, results)
+	case <-time.After(5 * time.Second):
+		t.Fatal("emit blocked on a slow sink")
+	}
+	assert.Equal(t, uint64(2), ip.Dropped())
+
+	// once the sink catches up, the buffered events are published in order
+	close(publisher.release)
+	assert.Eventually(t, func() bool {
+		return len(publisher.getPublished()) == queueSize+1
+	}, 5*time.Second, 10*time.Millisecond)
+	var names []string
+	for _, e := range publisher.getPublished() {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"blockdevice-0", "blockdevice-1", "blockdevice-2", "blockdevice-3"}, names)
+	assert.Equal(t, uint64(2), ip.Dropped())
+}
+
+func TestPublishInventoryEvent(t *testing.T) {
+	blockDevice := func(claimState apis.DeviceClaimState) apis.BlockDevice {
+		bd := apis.BlockDevice{ObjectMeta: metav1.ObjectMeta{Name: "blockdevice-1"}}
+		bd.Spec.Path = "/dev/sdb"
+		bd.Spec.NodeAttributes.NodeName = "node1"
+		bd.Status.State = NDMActive
+		bd.Status.ClaimState = claimState
+		return bd
+	}
+
+	tests := map[string]struct {
+		previous   apis.DeviceClaimState
+		eventType  InventoryEventType
+		claimState apis.DeviceClaimState
+		wantTypes  []InventoryEventType
+	}{
+		"resource seen the first time": {
+			eventType:  InventoryEventCreated,
+			claimState: apis.BlockDeviceUnclaimed,
+			wantTypes:  []InventoryEventType{InventoryEventCreated},
+		},
+		"claim state not changed": {
+			previous:   apis.BlockDeviceUnclaimed,
+			eventType:  InventoryEventUpdated,
+			claimState: apis.BlockDeviceUnclaimed,
+			wantTypes:  []InventoryEventType{InventoryEventUpdated},
+		},
+		"resource claimed": {
+			previous:   apis.BlockDeviceUnclaimed,
+			eventType:  InventoryEventUpdated,
+			claimState: apis.BlockDeviceClaimed,
+			wantTypes:  []InventoryEventType{InventoryEventUpdated, InventoryEventClaimed},
+		},
+		"resource unclaimed": {
+			previous:   apis.BlockDeviceClaimed,
+			eventType:  InventoryEventUpdated,
+			claimState: apis.BlockDeviceUnclaimed,
+			wantTypes:  []InventoryEventType{InventoryEventUpdated, InventoryEventUnclaimed},
+		},
+		"resource being released": {
+			previous:   apis.BlockDeviceClaimed,
+			eventType:  InventoryEventDeactivated,
+			claimState: apis.BlockDeviceReleased,
+			wantTypes:  []InventoryEventType{InventoryEventDeactivated, InventoryEventUnclaimed},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			publisher := newBlockingPublisher()
+			close(publisher.release)
+			c := &Controller{InventoryPublisher: NewInventoryPublisher(publisher, 10)}
+			if tt.previous != "" {
+				c.InventoryPublisher.observeClaimState("blockdevice-1", tt.previous)
+			}
+
+			c.PublishInventoryEvent(tt.eventType, blockDevice(tt.claimState))
+			assert.Eventually(t, func() bool {
+				return len(publisher.getPublished()) == len(tt.wantTypes)
+			}, 5*time.Second, 10*time.Millisecond)
+			for i, event := range publisher.getPublished() {
+				assert.Equal(t, tt.wantTypes[i], event.Type)
+				assert.Equal(t, "blockdevice-1", event.Name)
+				assert.Equal(t, "/dev/sdb", event.Path)
+				assert.Equal(t, "node1", event.NodeName)
+				assert.Equal(t, tt.claimState, event.ClaimState)
+			}
+		})
+	}
+
+	t.Run("events dropped are counted", func(t *testing.T) {
+		publisher := newBlockingPublisher()
+		defer close(publisher.release)
+		c := &Controller{
+			InventoryPublisher: NewInventoryPublisher(publisher, 0),
+			Metrics:            daemon.NewMetrics(),
+		}
+		c.PublishInventoryEvent(InventoryEventCreated, blockDevice(apis.BlockDeviceUnclaimed))
+		assert.Equal(t, uint64(1), c.InventoryPublisher.Dropped())
+	})
+
+	t.Run("only the claim state transition of a resource not written", func(t *testing.T) {
+		publisher := newBlockingPublisher()
+		close(publisher.release)
+		c := &Controller{InventoryPublisher: NewInventoryPublisher(publisher, 10)}
+		c.InventoryPublisher.observeClaimState("blockdevice-1", apis.BlockDeviceUnclaimed)
+
+		c.PublishClaimStateTransition(blockDevice(apis.BlockDeviceUnclaimed))
+		c.PublishClaimStateTransition(blockDevice(apis.BlockDeviceClaimed))
+		assert.Eventually(t, func() bool {
+			return len(publisher.getPublished()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, InventoryEventClaimed, publisher.getPublished()[0].Type)
+	})
+
+	t.Run("no sink configured", func(t *testing.T) {
+		c := &Controller{}
+		c.PublishInventoryEvent(InventoryEventCreated, blockDevice(apis.BlockDeviceUnclaimed))
+	})
+}
+
+func TestNewPublisher(t *testing.T) {
+	tests := map[string]struct {
+		sink    string
+		wantErr bool
+	}{
+		"http sink": {
+			sink:    "http://inventory-collector:8080/events",
+			wantErr: false,
+		},
+		"https sink": {
+			sink:    "https://inventory.example.com/events",
+			wantErr: false,
+		},
+		"unsupported scheme": {
+			sink:    "amqp://broker:5672/inventory",
+			wantErr: true,
+		},
+		"invalid url": {
+			sink:    "http://inventory collector:8080/%zz",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			publisher, err := NewPublisher(tt.sink)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, publisher)
+		})
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var received InventoryEvent
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	publisher, err := NewPublisher(server.URL)
+	assert.NoError(t, err)
+
+	event := InventoryEvent{
+		Type:       InventoryEventClaimed,
+		Name:       "blockdevice-1",
+		Path:       "/dev/sdb",
+		NodeName:   "node1",
+		State:      NDMActive,
+		ClaimState: apis.BlockDeviceClaimed,
+	}
+	assert.NoError(t, publisher.Publish(context.TODO(), event))
+	assert.Equal(t, event.Name, received.Name)
+	assert.Equal(t, event.Type, received.Type)
+	assert.Equal(t, event.ClaimState, received.ClaimState)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, publisher.Publish(context.TODO(), event))
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// deviceNotReadyReason is the reason of the event recorded for a device that is not
+// ready to accept IO for the device ready timeout
+const deviceNotReadyReason = "DeviceNotReady"
+
+// ReportNotReadyDevice reports the device that is not ready to accept IO even after the
+// device ready timeout. A warning event is recorded on the node, as the device does not
+// have a blockdevice resource yet.
+func (c *Controller) ReportNotReadyDevice(devPath, state string, waited time.Duration) {
+	klog.Warningf("eventcode=%s msg=%s state=%s rname=%v",
+		"ndm.blockdevice.notready", "Device not ready to accept IO, deferring it",
+		state, devPath)
+	c.recordNodeEvent(v1.EventTypeWarning, deviceNotReadyReason,
+		"Device %s not ready to accept IO for %v, state: %s, it is processed once ready",
+		devPath, waited.Round(time.Second), state)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+)
+
+// PartitionReclaimPolicy defines what happens to the partitions created by NDM once
+// they are no longer used by any consumer
+type PartitionReclaimPolicy string
+
+const (
+	// RetainPartitions keeps the partitions created by NDM as is
+	RetainPartitions PartitionReclaimPolicy = "retain"
+
+	// ReclaimPartitions wipes and removes the partitions created by NDM that are
+	// flagged for reclaim, returning the parent disk to a clean state
+	ReclaimPartitions PartitionReclaimPolicy = "reclaim"
+
+	// DefaultPartitionReclaimPolicy is the policy used if none is specified
+	DefaultPartitionReclaimPolicy = RetainPartitions
+
+	// OpenEBSReclaim is used in annotation to flag a blockdevice of a partition created
+	// by NDM for reclaim
+	OpenEBSReclaim = openEBSLabelPrefix + "reclaim"
+)
+
+// ParsePartitionReclaimPolicy validates and returns the partition reclaim policy.
+// Empty value is treated as the default policy.
+func ParsePartitionReclaimPolicy(policy string) (PartitionReclaimPolicy, error) {
+	switch PartitionReclaimPolicy(policy) {
+	case "":
+		return DefaultPartitionReclaimPolicy, nil
+	case RetainPartitions, ReclaimPartitions:
+		return PartitionReclaimPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid partition reclaim policy: %q, should be one of %s, %s",
+		policy, RetainPartitions, ReclaimPartitions)
+}
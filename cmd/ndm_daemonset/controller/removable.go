@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+// RemovableDevicePolicy defines how NDM handles removable media like USB sticks
+// and external USB drives
+type RemovableDevicePolicy string
+
+const (
+	// IgnoreRemovableDevices ignores all removable media. BlockDevice resources
+	// will not be created for them.
+	IgnoreRemovableDevices RemovableDevicePolicy = "ignore"
+
+	// ManageRemovableDevices manages removable media like any other device
+	ManageRemovableDevices RemovableDevicePolicy = "manage"
+
+	// DefaultRemovableDevicePolicy is the policy used if none is specified
+	DefaultRemovableDevicePolicy = IgnoreRemovableDevices
+
+	// DefaultRemovableDeviceDelay is the time for which a removable disk has to be present
+	// before it is managed
+	DefaultRemovableDeviceDelay = 30 * time.Second
+
+	// NDMRemovableKey is the label added to BlockDevice resources of removable media
+	NDMRemovableKey = NDMLabelPrefix + "removable"
+)
+
+// ParseRemovableDevicePolicy validates and returns the removable device policy.
+// Empty value is treated as the default policy.
+func ParseRemovableDevicePolicy(policy string) (RemovableDevicePolicy, error) {
+	switch RemovableDevicePolicy(policy) {
+	case "":
+		return DefaultRemovableDevicePolicy, nil
+	case IgnoreRemovableDevices, ManageRemovableDevices:
+		return RemovableDevicePolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid removable device policy: %q, should be one of %s, %s",
+		policy, IgnoreRemovableDevices, ManageRemovableDevices)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/klog/v2"
+)
+
+// RemovalPolicy defines how the BlockDevice resource of a device that is removed from
+// the node is handled
+type RemovalPolicy string
+
+const (
+	// DeactivateOnRemoval marks the BlockDevice resource as Inactive, so that it is
+	// activated again if the device is reattached
+	DeactivateOnRemoval RemovalPolicy = "deactivate"
+
+	// DeleteOnRemoval deletes the BlockDevice resource if it is Unclaimed and has no
+	// finalizers. Other resources are deactivated, Released resources so that the
+	// cleanup can complete.
+	DeleteOnRemoval RemovalPolicy = "delete"
+
+	// DefaultRemovalPolicy is the policy used if none is specified
+	DefaultRemovalPolicy = DeactivateOnRemoval
+)
+
+// ParseRemovalPolicy validates and returns the removal policy.
+// Empty value is treated as the default policy.
+func ParseRemovalPolicy(policy string) (RemovalPolicy, error) {
+	switch RemovalPolicy(policy) {
+	case "":
+		return DefaultRemovalPolicy, nil
+	case DeactivateOnRemoval, DeleteOnRemoval:
+		return RemovalPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid removal policy: %q, should be one of %s, %s",
+		policy, DeactivateOnRemoval, DeleteOnRemoval)
+}
+
+// RemoveBlockDevice handles the BlockDevice resource of a device that is no longer
+// present on the node as per the removal policy. Only Unclaimed resources are ever
+// deleted, irrespective of the policy, as the data on the others is still in use by
+// the consumer or is yet to be cleaned up.
+func (c *Controller) RemoveBlockDevice(blockDevice apis.BlockDevice) {
+	if c.skipPeerManagedBlockDevice(blockDevice, "removal") {
+		return
+	}
+	if c.canDeleteOnRemoval(blockDevice) {
+		if c.IsDestructiveOperationAllowed(DeleteBlockDeviceOperation, blockDevice.Name) {
+			c.DeleteBlockDevice(blockDevice.Name, c.getRemovalAuditCause())
+		}
+		return
+	}
+	c.DeactivateBlockDevice(blockDevice, c.getRemovalAuditCause())
+}
+
+// RemoveStaleBlockDevices applies the removal policy to the resources deactivated as
+// stale at the start of a full scan, that were not activated again by any device once
+// all the devices on the node were processed. The resources are matched by their uuid,
+// as the path of a device can change across a rescan. The resources having one of the
+// uuids of the devices present on the node are left deactivated, eg: if the device was
+// excluded by a filter.
+func (c *Controller) RemoveStaleBlockDevices(staleUUIDs, presentUUIDs []string) {
+	if len(staleUUIDs) == 0 {
+		return
+	}
+	blockDeviceList, err := c.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, item := range blockDeviceList.Items {
+		if !util.Contains(staleUUIDs, item.Name) || util.Contains(presentUUIDs, item.Name) {
+			continue
+		}
+		// the resource was activated again, or is already deactivated and cannot be
+		// deleted as per the policy
+		if item.Status.State != NDMInactive || !c.canDeleteOnRemoval(item) {
+			continue
+		}
+		c.RemoveBlockDevice(item)
+	}
+}
+
+// getRemovalAuditCause gets the cause of the operation on the resource of a removed device,
+// recorded in the audit log
+func (c *Controller) getRemovalAuditCause() AuditCause {
+	return AuditCause{
+		Trigger: AuditTriggerDeviceRemoved,
+		Reason:  fmt.Sprintf("device is no longer present on the node, removal policy: %s", c.RemovalPolicy),
+	}
+}
+
+// canDeleteOnRemoval checks whether the removal policy allows deleting the resource.
+// Resources with finalizers are not deleted, as they are still held by their consumer.
+func (c *Controller) canDeleteOnRemoval(blockDevice apis.BlockDevice) bool {
+	switch c.RemovalPolicy {
+	case DeleteOnRemoval:
+		return blockDevice.Status.ClaimState == apis.BlockDeviceUnclaimed &&
+			len(blockDevice.Finalizers) == 0
+	}
+	return false
+}
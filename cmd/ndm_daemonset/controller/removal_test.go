@@ -0,0 +1,241 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseRemovalPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		want    RemovalPolicy
+		wantErr bool
+	}{
+		"empty policy uses the default": {
+			policy: "",
+			want:   DeactivateOnRemoval,
+		},
+		"deactivate": {
+			policy: "deactivate",
+			want:   DeactivateOnRemoval,
+		},
+		"delete": {
+			policy: "delete",
+			want:   DeleteOnRemoval,
+		},
+		"invalid policy": {
+			policy:  "purge",
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRemovalPolicy(test.policy)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+// newRemovalTestDevice returns an active BlockDevice resource on the fake host with
+// the given claim state
+func newRemovalTestDevice(name, path string, claimState apis.DeviceClaimState) apis.BlockDevice {
+	return apis.BlockDevice{
+		TypeMeta: fakeDeviceTypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				KubernetesHostNameLabel: fakeHostName,
+				NDMDeviceTypeKey:        NDMDefaultDeviceType,
+			},
+		},
+		Spec: apis.DeviceSpec{
+			Path: path,
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: claimState,
+			State:      NDMActive,
+		},
+	}
+}
+
+func TestRemoveBlockDevice(t *testing.T) {
+	tests := map[string]struct {
+		policy      RemovalPolicy
+		claimState  apis.DeviceClaimState
+		finalizers  []string
+		safeMode    bool
+		wantDeleted bool
+	}{
+		"deactivate policy, unclaimed resource is deactivated": {
+			policy:     DeactivateOnRemoval,
+			claimState: apis.BlockDeviceUnclaimed,
+		},
+		"deactivate policy, claimed resource is deactivated": {
+			policy:     DeactivateOnRemoval,
+			claimState: apis.BlockDeviceClaimed,
+		},
+		"delete policy, unclaimed resource is deleted": {
+			policy:      DeleteOnRemoval,
+			claimState:  apis.BlockDeviceUnclaimed,
+			wantDeleted: true,
+		},
+		"delete policy, released resource is deactivated": {
+			policy:     DeleteOnRemoval,
+			claimState: apis.BlockDeviceReleased,
+		},
+		"delete policy, unclaimed resource with finalizers is deactivated": {
+			policy:     DeleteOnRemoval,
+			claimState: apis.BlockDeviceUnclaimed,
+			finalizers: []string{"openebs.io/bd-protection"},
+		},
+		"delete policy, claimed resource survives": {
+			policy:     DeleteOnRemoval,
+			claimState: apis.BlockDeviceClaimed,
+		},
+		"delete policy, resource is not deleted in safe mode": {
+			policy:     DeleteOnRemoval,
+			claimState: apis.BlockDeviceUnclaimed,
+			safeMode:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeController := &Controller{
+				NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+				Clientset:      CreateFakeClient(t),
+				RemovalPolicy:  test.policy,
+				SafeMode:       test.safeMode,
+			}
+			bd := newRemovalTestDevice(fakeDeviceUID, "/dev/sda", test.claimState)
+			bd.Finalizers = test.finalizers
+			if err := fakeController.CreateBlockDevice(bd); err != nil {
+				t.Fatal(err)
+			}
+
+			created, err := fakeController.GetBlockDevice(fakeDeviceUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fakeController.RemoveBlockDevice(*created)
+
+			got, err := fakeController.GetBlockDevice(fakeDeviceUID)
+			if test.wantDeleted {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			if test.safeMode {
+				assert.Equal(t, NDMActive, string(got.Status.State))
+			} else {
+				assert.Equal(t, NDMInactive, string(got.Status.State))
+			}
+			assert.Equal(t, test.claimState, got.Status.ClaimState)
+		})
+	}
+}
+
+func TestRemoveStaleBlockDevices(t *testing.T) {
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+		RemovalPolicy:  DeleteOnRemoval,
+	}
+	devices := []apis.BlockDevice{
+		newRemovalTestDevice("blockdevice-present", "/dev/sda", apis.BlockDeviceUnclaimed),
+		newRemovalTestDevice("blockdevice-renumbered", "/dev/sdb", apis.BlockDeviceUnclaimed),
+		newRemovalTestDevice("blockdevice-filtered", "/dev/sdc", apis.BlockDeviceUnclaimed),
+		newRemovalTestDevice("blockdevice-removed", "/dev/sdd", apis.BlockDeviceUnclaimed),
+		newRemovalTestDevice("blockdevice-removed-claimed", "/dev/sde", apis.BlockDeviceClaimed),
+		newRemovalTestDevice("blockdevice-removed-released", "/dev/sdf", apis.BlockDeviceReleased),
+	}
+	for _, bd := range devices {
+		if err := fakeController.CreateBlockDevice(bd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the full scan does not know the uuids of the devices, all the resources are stale
+	staleUUIDs := fakeController.DeactivateStaleBlockDeviceResource(nil)
+	assert.ElementsMatch(t, []string{"blockdevice-present", "blockdevice-renumbered",
+		"blockdevice-filtered", "blockdevice-removed", "blockdevice-removed-claimed",
+		"blockdevice-removed-released"}, staleUUIDs)
+
+	// sda is processed and activated again. sdb is now at /dev/sdg, but has the same uuid,
+	// and sdc is excluded by a filter. Both are still present on the node.
+	present, err := fakeController.GetBlockDevice("blockdevice-present")
+	if err != nil {
+		t.Fatal(err)
+	}
+	present.Status.State = NDMActive
+	if err := fakeController.Clientset.Update(context.TODO(), present); err != nil {
+		t.Fatal(err)
+	}
+	fakeController.RemoveStaleBlockDevices(staleUUIDs,
+		[]string{"blockdevice-present", "blockdevice-renumbered", "blockdevice-filtered"})
+
+	tests := map[string]struct {
+		name        string
+		wantDeleted bool
+		wantState   string
+	}{
+		"resource activated again is not modified": {
+			name:      "blockdevice-present",
+			wantState: NDMActive,
+		},
+		"resource of a renumbered device is not deleted": {
+			name:      "blockdevice-renumbered",
+			wantState: NDMInactive,
+		},
+		"resource of a filtered device is not deleted": {
+			name:      "blockdevice-filtered",
+			wantState: NDMInactive,
+		},
+		"resource of a removed device is deleted": {
+			name:        "blockdevice-removed",
+			wantDeleted: true,
+		},
+		"claimed resource of a removed device survives": {
+			name:      "blockdevice-removed-claimed",
+			wantState: NDMInactive,
+		},
+		"released resource of a removed device survives": {
+			name:      "blockdevice-removed-released",
+			wantState: NDMInactive,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := fakeController.GetBlockDevice(test.name)
+			if test.wantDeleted {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantState, string(got.Status.State))
+		})
+	}
+}
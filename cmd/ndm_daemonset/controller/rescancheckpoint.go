@@ -0,0 +1,290 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultRescanCheckpointTTL is the default time for which the details of a device
+// recorded in the rescan checkpoint are reused, instead of probing the device again
+const DefaultRescanCheckpointTTL = 30 * time.Minute
+
+// rescanCheckpointPersistInterval is the minimum interval between the writes of the
+// checkpoint file while a rescan is in progress. The file is not written for every
+// device, the progress since the last write is lost on a crash.
+const rescanCheckpointPersistInterval = time.Minute
+
+// RescanCheckpoint records the progress of a full rescan of the node in a local file,
+// so that a rescan interrupted by a crash or a restart of the daemon can be resumed.
+// The expensive probes, like SMART, are not run again on the devices processed by the
+// interrupted rescan when it is resumed, if they were processed within the TTL and
+// have not changed since. The attributes of the devices filled by those probes are
+// recorded and reused instead. Only the attributes that do not change while the device
+// is present are recorded, the usage of the device is always probed again. Probing
+// hundreds of disks is expensive, and starting from scratch on every restart may never
+// let the rescan complete.
+type RescanCheckpoint struct {
+	// path is the path of the file in which the checkpoint is persisted
+	path string
+	// ttl is the time for which the recorded details of a device are reused
+	ttl time.Duration
+	// now gets the current time, it can be replaced in tests
+	now func() time.Time
+
+	mutex sync.Mutex
+	state rescanCheckpointState
+	// dirty is set if the state has changed since it was persisted
+	dirty bool
+	// persistedAt is the time at which the state was last persisted
+	persistedAt time.Time
+}
+
+// rescanCheckpointState is the progress of a rescan, as persisted in the checkpoint file
+type rescanCheckpointState struct {
+	// StartedAt is the time at which the rescan was started
+	StartedAt time.Time `json:"startedAt"`
+	// Completed is set once all the devices of the rescan have been processed
+	Completed bool `json:"completed"`
+	// Devices are the devices processed successfully by the rescan, keyed by the devpath
+	Devices map[string]CheckpointedDevice `json:"devices"`
+}
+
+// CheckpointedDevice is a device processed by the rescan
+type CheckpointedDevice struct {
+	// Identity is the identity of the device as listed by the scan, before it was
+	// probed. The recorded details are reused only if the identity is unchanged.
+	Identity string `json:"identity"`
+	// ProcessedAt is the time at which the device was processed
+	ProcessedAt time.Time `json:"processedAt"`
+	// DeviceAttributes are the attributes of the device filled by the probes
+	DeviceAttributes blockdevice.DeviceAttribute `json:"deviceAttributes"`
+	// Capacity is the capacity of the device filled by the probes
+	Capacity blockdevice.CapacityInformation `json:"capacity"`
+	// SMARTInfo is the SMART information of the device filled by the probes
+	SMARTInfo blockdevice.SMARTStats `json:"smartInfo"`
+}
+
+// Restore fills the recorded attributes of the device that are not already filled, the
+// same way the expensive probes fill only the attributes not filled by other probes
+func (cd CheckpointedDevice) Restore(bd *blockdevice.BlockDevice) {
+	fillZeroFields(reflect.ValueOf(&bd.DeviceAttributes).Elem(), reflect.ValueOf(cd.DeviceAttributes))
+	fillZeroFields(reflect.ValueOf(&bd.Capacity).Elem(), reflect.ValueOf(cd.Capacity))
+	fillZeroFields(reflect.ValueOf(&bd.SMARTInfo).Elem(), reflect.ValueOf(cd.SMARTInfo))
+}
+
+// fillZeroFields sets the fields of the struct dst that have the zero value to the
+// values of the same fields of src
+func fillZeroFields(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Field(i).CanSet() && dst.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// NewRescanCheckpoint creates a RescanCheckpoint persisted at the given path. The
+// details of the devices recorded in it are reused for ttl.
+func NewRescanCheckpoint(path string, ttl time.Duration) *RescanCheckpoint {
+	return &RescanCheckpoint{
+		path: path,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+// Begin marks the start of a full rescan. If the previous rescan recorded in the
+// checkpoint file did not complete, it is resumed and the no. of devices that need
+// not be probed again is returned. Otherwise a new rescan is started.
+func (rc *RescanCheckpoint) Begin() int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	previous, err := rc.load()
+	if err != nil {
+		klog.Warningf("eventcode=%s msg=%s file=%s err=%v",
+			"ndm.rescan.checkpoint.load.failed", "Failed to load rescan checkpoint, starting a new rescan",
+			rc.path, err)
+	}
+
+	now := rc.now()
+	if previous != nil && !previous.Completed {
+		for devPath, device := range previous.Devices {
+			if now.Sub(device.ProcessedAt) > rc.ttl {
+				delete(previous.Devices, devPath)
+			}
+		}
+		if len(previous.Devices) > 0 {
+			rc.state = *previous
+			klog.Infof("eventcode=%s msg=%s file=%s startedAt=%v devices=%d",
+				"ndm.rescan.checkpoint.resumed", "Resuming interrupted rescan",
+				rc.path, previous.StartedAt, len(previous.Devices))
+			return len(previous.Devices)
+		}
+	}
+
+	// the new rescan is persisted along with the first devices processed by it. Until
+	// then, the previous state in the file also results in a new rescan.
+	rc.state = rescanCheckpointState{
+		StartedAt: now,
+		Devices:   make(map[string]CheckpointedDevice),
+	}
+	rc.dirty = false
+	return 0
+}
+
+// Lookup gets the recorded details of a device listed by the rescan, if the device
+// was processed by the rescan being resumed within the TTL and has not changed since
+func (rc *RescanCheckpoint) Lookup(scanned blockdevice.BlockDevice) (CheckpointedDevice, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	device, ok := rc.state.Devices[scanned.DevPath]
+	if !ok {
+		return CheckpointedDevice{}, false
+	}
+	if device.Identity != rescanIdentity(scanned) || rc.now().Sub(device.ProcessedAt) > rc.ttl {
+		delete(rc.state.Devices, scanned.DevPath)
+		rc.dirty = true
+		return CheckpointedDevice{}, false
+	}
+	return device, true
+}
+
+// Record records a device as processed by the rescan. scanned is the device as listed
+// by the rescan, and probed is the device with the details filled by the probes. The
+// checkpoint is persisted if it was not persisted within the persist interval.
+func (rc *RescanCheckpoint) Record(scanned, probed blockdevice.BlockDevice) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.state.Devices == nil {
+		rc.state.Devices = make(map[string]CheckpointedDevice)
+	}
+	now := rc.now()
+	rc.state.Devices[scanned.DevPath] = CheckpointedDevice{
+		Identity:         rescanIdentity(scanned),
+		ProcessedAt:      now,
+		DeviceAttributes: probed.DeviceAttributes,
+		Capacity:         probed.Capacity,
+		SMARTInfo:        probed.SMARTInfo,
+	}
+	rc.dirty = true
+	if now.Sub(rc.persistedAt) >= rescanCheckpointPersistInterval {
+		rc.persist()
+	}
+}
+
+// Flush persists the devices recorded since the checkpoint was last persisted. It is
+// called once the devices of a rescan that did not complete have been processed.
+func (rc *RescanCheckpoint) Flush() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.dirty {
+		rc.persist()
+	}
+}
+
+// Complete marks the rescan as completed, so that the next rescan starts afresh
+func (rc *RescanCheckpoint) Complete() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.state.Completed = true
+	rc.state.Devices = nil
+	rc.persist()
+}
+
+// load reads the checkpoint file. nil is returned if the file does not exist.
+func (rc *RescanCheckpoint) load() (*rescanCheckpointState, error) {
+	data, err := os.ReadFile(rc.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &rescanCheckpointState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	return state, nil
+}
+
+// persist writes the checkpoint to the file. The file is replaced atomically, so that
+// a crash while writing does not leave a partial checkpoint. Failures are only logged,
+// as a rescan does not depend on the checkpoint. mutex should be held by the caller.
+func (rc *RescanCheckpoint) persist() {
+	rc.persistedAt = rc.now()
+	rc.dirty = false
+	err := rc.write()
+	if err != nil {
+		klog.Warningf("eventcode=%s msg=%s file=%s err=%v",
+			"ndm.rescan.checkpoint.write.failed", "Failed to write rescan checkpoint",
+			rc.path, err)
+	}
+}
+
+func (rc *RescanCheckpoint) write() error {
+	data, err := json.Marshal(rc.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(rc.path), filepath.Base(rc.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), rc.path)
+}
+
+// rescanIdentity gets the identity of a device from the details filled when the device
+// is listed by the scan. A device whose identifiers changed, like a disk that was
+// partitioned or replaced, is probed again.
+func rescanIdentity(bd blockdevice.BlockDevice) string {
+	return strings.Join([]string{
+		bd.DevPath,
+		bd.SysPath,
+		bd.UUID,
+		bd.DeviceAttributes.WWN,
+		bd.DeviceAttributes.Serial,
+		bd.PartitionInfo.PartitionTableUUID,
+		bd.PartitionInfo.PartitionEntryUUID,
+		bd.FSInfo.FileSystemUUID,
+		bd.DMInfo.DMUUID,
+	}, "|")
+}
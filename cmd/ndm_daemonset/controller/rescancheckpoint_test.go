@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCheckpointTestDevice(devPath, serial string) blockdevice.BlockDevice {
+	bd := blockdevice.BlockDevice{}
+	bd.DevPath = devPath
+	bd.DeviceAttributes.Serial = serial
+	return bd
+}
+
+func TestRescanCheckpoint(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sda := newCheckpointTestDevice("/dev/sda", "serial-a")
+	sdb := newCheckpointTestDevice("/dev/sdb", "serial-b")
+	probedSDA := sda
+	probedSDA.DeviceAttributes.Model = "model-a"
+
+	tests := map[string]struct {
+		// interrupt records the devices in the first rescan and restarts before completing it
+		interrupt     bool
+		restartAfter  time.Duration
+		lookup        blockdevice.BlockDevice
+		wantResumed   int
+		wantFound     bool
+		wantModelName string
+	}{
+		"interrupted rescan is resumed": {
+			interrupt:     true,
+			restartAfter:  time.Minute,
+			lookup:        sda,
+			wantResumed:   1,
+			wantFound:     true,
+			wantModelName: "model-a",
+		},
+		"device not processed by the interrupted rescan": {
+			interrupt:    true,
+			restartAfter: time.Minute,
+			lookup:       sdb,
+			wantResumed:  1,
+			wantFound:    false,
+		},
+		"device changed since it was processed": {
+			interrupt:    true,
+			restartAfter: time.Minute,
+			lookup:       newCheckpointTestDevice("/dev/sda", "serial-replaced"),
+			wantResumed:  1,
+			wantFound:    false,
+		},
+		"device processed before the ttl": {
+			interrupt:    true,
+			restartAfter: time.Hour,
+			lookup:       sda,
+			wantResumed:  0,
+			wantFound:    false,
+		},
+		"completed rescan is not resumed": {
+			interrupt:    false,
+			restartAfter: time.Minute,
+			lookup:       sda,
+			wantResumed:  0,
+			wantFound:    false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+			first := NewRescanCheckpoint(path, 30*time.Minute)
+			first.now = func() time.Time { return start }
+			assert.Equal(t, 0, first.Begin())
+			first.Record(sda, probedSDA)
+			if !test.interrupt {
+				first.Complete()
+			}
+
+			// the daemon restarts, and the checkpoint is loaded from the file
+			second := NewRescanCheckpoint(path, 30*time.Minute)
+			second.now = func() time.Time { return start.Add(test.restartAfter) }
+			assert.Equal(t, test.wantResumed, second.Begin())
+
+			bd, found := second.Lookup(test.lookup)
+			assert.Equal(t, test.wantFound, found)
+			assert.Equal(t, test.wantModelName, bd.DeviceAttributes.Model)
+		})
+	}
+}
+
+func TestRescanCheckpointInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{not json"), 0644))
+
+	rc := NewRescanCheckpoint(path, time.Hour)
+	assert.Equal(t, 0, rc.Begin())
+
+	// a new rescan is started, replacing the invalid checkpoint once a device is recorded
+	rc.Record(newCheckpointTestDevice("/dev/sda", "serial-a"), newCheckpointTestDevice("/dev/sda", "serial-a"))
+	state, err := rc.load()
+	assert.NoError(t, err)
+	assert.False(t, state.Completed)
+	assert.Len(t, state.Devices, 1)
+}
+
+func TestRescanCheckpointPersist(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	now := start
+	rc := NewRescanCheckpoint(path, time.Hour)
+	rc.now = func() time.Time { return now }
+	rc.Begin()
+
+	// the file is not written for every device
+	rc.Record(newCheckpointTestDevice("/dev/sda", "serial-a"), newCheckpointTestDevice("/dev/sda", "serial-a"))
+	now = now.Add(time.Second)
+	rc.Record(newCheckpointTestDevice("/dev/sdb", "serial-b"), newCheckpointTestDevice("/dev/sdb", "serial-b"))
+	state, err := rc.load()
+	assert.NoError(t, err)
+	assert.Len(t, state.Devices, 1)
+
+	// the remaining devices are written at the end of the scan
+	rc.Flush()
+	state, err = rc.load()
+	assert.NoError(t, err)
+	assert.Len(t, state.Devices, 2)
+}
+
+func TestCheckpointedDeviceRestore(t *testing.T) {
+	probed := newCheckpointTestDevice("/dev/sda", "serial-a")
+	probed.DeviceAttributes.FirmwareRevision = "fw-1"
+	probed.DeviceAttributes.LogicalBlockSize = 512
+	probed.SMARTInfo.RotationRate = 7200
+	probed.FSInfo.FileSystem = "ext4"
+	probed.DevUse.InUse = true
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	rc := NewRescanCheckpoint(path, time.Hour)
+	rc.Begin()
+	rc.Record(newCheckpointTestDevice("/dev/sda", "serial-a"), probed)
+	cached, ok := rc.Lookup(newCheckpointTestDevice("/dev/sda", "serial-a"))
+	assert.True(t, ok)
+
+	// the usage is not restored, and the attributes filled by the other probes are kept
+	bd := newCheckpointTestDevice("/dev/sda", "serial-a")
+	bd.DeviceAttributes.LogicalBlockSize = 4096
+	cached.Restore(&bd)
+	assert.Equal(t, "fw-1", bd.DeviceAttributes.FirmwareRevision)
+	assert.Equal(t, uint32(4096), bd.DeviceAttributes.LogicalBlockSize)
+	assert.Equal(t, uint16(7200), bd.SMARTInfo.RotationRate)
+	assert.Empty(t, bd.FSInfo.FileSystem)
+	assert.False(t, bd.DevUse.InUse)
+}
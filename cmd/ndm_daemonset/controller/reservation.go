@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openebs/node-disk-manager/pkg/smart"
+)
+
+// scsiReservationIndex is the persistent reservation of the disks, keyed by the path of
+// the disk. The reservation of a disk is read at most once per batch of events.
+type scsiReservationIndex struct {
+	mutex sync.Mutex
+	// reservations is nil till a reservation is read in the batch
+	reservations map[string]smart.ReservationInfo
+}
+
+// ParseSCSIReservationKeys validates and returns the persistent reservation keys,
+// given in hex with an optional 0x prefix. Zero is not a valid reservation key.
+func ParseSCSIReservationKeys(keys []string) ([]uint64, error) {
+	var reservationKeys []uint64
+	for _, key := range keys {
+		hexKey := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "0x")
+		reservationKey, err := strconv.ParseUint(hexKey, 16, 64)
+		if err != nil || reservationKey == 0 {
+			return nil, fmt.Errorf("invalid scsi reservation key: %q, should be a non zero 64 bit hex value", key)
+		}
+		reservationKeys = append(reservationKeys, reservationKey)
+	}
+	return reservationKeys, nil
+}
+
+// ResetSCSIReservations resets the index of the persistent reservations, so that the
+// reservation of a disk is read again when it is next looked up. It is called at the
+// start of every batch of events.
+func (c *Controller) ResetSCSIReservations() {
+	c.scsiReservations.mutex.Lock()
+	defer c.scsiReservations.mutex.Unlock()
+	c.scsiReservations.reservations = nil
+}
+
+// GetSCSIReservation gets the persistent reservation of the disk, reading it with the
+// given function only if it was not already read in this batch. Errors are not cached,
+// so that the reservation is read again on the next lookup.
+func (c *Controller) GetSCSIReservation(devPath string,
+	readReservation func(string) (smart.ReservationInfo, error)) (smart.ReservationInfo, error) {
+	c.scsiReservations.mutex.Lock()
+	defer c.scsiReservations.mutex.Unlock()
+
+	if reservation, ok := c.scsiReservations.reservations[devPath]; ok {
+		return reservation, nil
+	}
+	reservation, err := readReservation(devPath)
+	if err != nil {
+		return reservation, err
+	}
+	if c.scsiReservations.reservations == nil {
+		c.scsiReservations.reservations = make(map[string]smart.ReservationInfo)
+	}
+	c.scsiReservations.reservations[devPath] = reservation
+	return reservation, nil
+}
+
+// IsLocalSCSIReservationKey checks if the reservation key is one of the keys registered
+// by this host
+func (c *Controller) IsLocalSCSIReservationKey(key uint64) bool {
+	for _, localKey := range c.SCSIReservationKeys {
+		if localKey == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSCSIReservationKeys(t *testing.T) {
+	tests := map[string]struct {
+		keys    []string
+		want    []uint64
+		wantErr bool
+	}{
+		"no keys": {},
+		"keys with and without prefix": {
+			keys: []string{"0x1A2B3C4D", "abcd1234"},
+			want: []uint64{0x1a2b3c4d, 0xabcd1234},
+		},
+		"zero key": {
+			keys:    []string{"0x0"},
+			wantErr: true,
+		},
+		"key that is not hex": {
+			keys:    []string{"node-1"},
+			wantErr: true,
+		},
+		"key longer than 64 bits": {
+			keys:    []string{"0x1a2b3c4d5e6f7a8b9c"},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseSCSIReservationKeys(test.keys)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestIsLocalSCSIReservationKey(t *testing.T) {
+	c := &Controller{SCSIReservationKeys: []uint64{0x1a2b3c4d}}
+	assert.True(t, c.IsLocalSCSIReservationKey(0x1a2b3c4d))
+	assert.False(t, c.IsLocalSCSIReservationKey(0xabcd1234))
+	// the all registrants reservations report the key as zero
+	assert.False(t, c.IsLocalSCSIReservationKey(0))
+}
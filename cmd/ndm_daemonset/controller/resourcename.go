@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// fallbackResourceName is used as the sanitized name when nothing remains
+	// of the original name after sanitization
+	fallbackResourceName = "blockdevice"
+
+	// resourceNameHashLength is the number of hex characters of the hash of the
+	// original name, appended to a sanitized name to keep it unique
+	resourceNameHashLength = 10
+)
+
+// invalidResourceNameChars matches the runs of characters which are not allowed
+// in a DNS-1123 subdomain
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// sanitizeResourceName returns a name which is a valid kubernetes resource name
+// (DNS-1123 subdomain) for the given name. Valid names are returned unchanged.
+// For other names, the invalid characters are replaced, and the name is truncated
+// and suffixed with the hash of the original name, so that the same name always
+// gets the same valid name. The returned bool is true if the name was sanitized.
+func sanitizeResourceName(name string) (string, bool) {
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name, false
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(sum[:])[:resourceNameHashLength]
+
+	sanitized := invalidResourceNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	maxPrefixLength := validation.DNS1123SubdomainMaxLength - len(suffix)
+	if len(sanitized) > maxPrefixLength {
+		sanitized = sanitized[:maxPrefixLength]
+	}
+	// the name should start and end with an alphanumeric character
+	sanitized = strings.Trim(sanitized, ".-")
+	if len(sanitized) == 0 {
+		sanitized = fallbackResourceName
+	}
+	return sanitized + suffix, true
+}
+
+// SanitizeUUID returns the uuid of the device as a valid resource name, logging when the
+// uuid had to be sanitized. It is applied wherever the uuid of a device is generated, so
+// that the uuid of the device and the name of its resource are the same everywhere, eg:
+// in the hierarchy cache and in the lookups of the resource.
+func SanitizeUUID(devPath, uuid string) string {
+	validName, sanitized := sanitizeResourceName(uuid)
+	if sanitized {
+		klog.Warningf("eventcode=%s msg=%s rname=%s name=%s devpath=%s",
+			"ndm.blockdevice.name.sanitized", "Invalid resource name sanitized",
+			validName, uuid, devPath)
+	}
+	return validName
+}
+
+// validateResourceName checks if the name is a valid resource name
+func validateResourceName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return fmt.Errorf("invalid blockdevice name: %q, %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSanitizeResourceName(t *testing.T) {
+	overLongName := "blockdevice-" + strings.Repeat("a1b2c3d4", 40)
+	tests := map[string]struct {
+		name          string
+		wantSanitized bool
+		wantPrefix    string
+	}{
+		"valid name": {
+			name:          "blockdevice-0a1b2c3d4e5f60718293a4b5c6d7e8f9",
+			wantSanitized: false,
+			wantPrefix:    "blockdevice-0a1b2c3d4e5f60718293a4b5c6d7e8f9",
+		},
+		"over-long name": {
+			name:          overLongName,
+			wantSanitized: true,
+			wantPrefix:    overLongName[:validation.DNS1123SubdomainMaxLength-resourceNameHashLength-1],
+		},
+		"name derived from illegal characters": {
+			name:          "sparse-Node_01:disk.img",
+			wantSanitized: true,
+			wantPrefix:    "sparse-node-01-disk.img",
+		},
+		"name without any legal character": {
+			name:          "__",
+			wantSanitized: true,
+			wantPrefix:    fallbackResourceName,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, sanitized := sanitizeResourceName(tt.name)
+			assert.Equal(t, tt.wantSanitized, sanitized)
+			assert.Empty(t, validation.IsDNS1123Subdomain(got))
+			assert.True(t, strings.HasPrefix(got, tt.wantPrefix))
+
+			// sanitization is deterministic, and the sanitized name is stable
+			again, _ := sanitizeResourceName(tt.name)
+			assert.Equal(t, got, again)
+			stable, sanitizedAgain := sanitizeResourceName(got)
+			assert.Equal(t, got, stable)
+			assert.False(t, sanitizedAgain)
+		})
+	}
+}
+
+func TestSanitizeResourceNameUnique(t *testing.T) {
+	// names differing only in the illegal characters get different names
+	first, _ := sanitizeResourceName("disk_a")
+	second, _ := sanitizeResourceName("disk:a")
+	assert.NotEqual(t, first, second)
+}
+
+func TestToDeviceResourceName(t *testing.T) {
+	tests := map[string]struct {
+		uuid string
+	}{
+		"over-long uuid": {
+			uuid: "blockdevice-" + strings.Repeat("0123456789", 30),
+		},
+		"uuid derived from illegal characters": {
+			uuid: "blockdevice-WD_Blue SN570#1",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			blockDevice := &bd.BlockDevice{
+				Identifier: bd.Identifier{
+					UUID:    SanitizeUUID("/dev/non-existent-disk", tt.uuid),
+					DevPath: "/dev/non-existent-disk",
+				},
+			}
+			assert.Empty(t, validation.IsDNS1123Subdomain(blockDevice.UUID))
+
+			// the name of the resource is the sanitized uuid of the device
+			deviceInfo := c.NewDeviceInfoFromBlockDevice(blockDevice)
+			assert.Equal(t, blockDevice.UUID, deviceInfo.UUID)
+			bdAPI, err := deviceInfo.ToDevice(c)
+			assert.NoError(t, err)
+			assert.Equal(t, blockDevice.UUID, bdAPI.Name)
+
+			// an unsanitized uuid is not silently renamed
+			deviceInfo.UUID = tt.uuid
+			_, err = deviceInfo.ToDevice(c)
+			assert.Error(t, err)
+		})
+	}
+}
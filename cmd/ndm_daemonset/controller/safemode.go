@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/klog/v2"
+)
+
+// DestructiveOperation is a typed string for the operations performed by NDM
+// that modify the data on a disk or take a BlockDevice resource out of service
+type DestructiveOperation string
+
+const (
+	// CreatePartitionOperation is writing a partition table / partition on a disk
+	CreatePartitionOperation DestructiveOperation = "create-partition"
+
+	// DeletePartitionOperation is removing a partition from a disk
+	DeletePartitionOperation DestructiveOperation = "delete-partition"
+
+	// WipeSignaturesOperation is erasing the filesystem / partition table signatures
+	// from a device
+	WipeSignaturesOperation DestructiveOperation = "wipe-signatures"
+
+	// DiscardOperation is discarding (TRIM / UNMAP) all the blocks of a device
+	DiscardOperation DestructiveOperation = "discard"
+
+	// VerifyWritableOperation is writing to the scratch region at the end of a blank
+	// disk and zeroing it again, to check that the disk is writable
+	VerifyWritableOperation DestructiveOperation = "verify-writable"
+
+	// DisableWriteCacheOperation is disabling the volatile write cache of a disk, which
+	// changes the settings of the disk
+	DisableWriteCacheOperation DestructiveOperation = "disable-write-cache"
+
+	// DeactivateBlockDeviceOperation is marking a BlockDevice resource as Inactive
+	DeactivateBlockDeviceOperation DestructiveOperation = "deactivate-blockdevice"
+
+	// DeleteBlockDeviceOperation is deleting a BlockDevice resource
+	DeleteBlockDeviceOperation DestructiveOperation = "delete-blockdevice"
+)
+
+// IsDestructiveOperationAllowed checks whether the given destructive operation can be
+// performed on the target. When SafeMode is enabled all destructive operations are
+// blocked, while the non-destructive resource bookkeeping (create / update of
+// BlockDevice resources) continues to happen. Every blocked action is logged. In the
+// evaluation mode, the operation is recorded and is not performed. In the discover only
+// mode, the operations writing to a disk are never performed. No operation writing to
+// a disk is performed on a disk in the IO error state, or on its partitions. No operation
+// is performed on a protected BlockDevice, as per the protection level.
+func (c *Controller) IsDestructiveOperationAllowed(op DestructiveOperation, target string) bool {
+	if c.DiscoverOnly && op != DeactivateBlockDeviceOperation && op != DeleteBlockDeviceOperation {
+		klog.V(4).Infof("operation: %s on %s not performed in discover only mode", op, target)
+		return false
+	}
+	if c.IOErrors != nil && op != DeactivateBlockDeviceOperation && op != DeleteBlockDeviceOperation &&
+		c.isIOErrorFailing(target) {
+		klog.Warningf("eventcode=%s msg=%s op=%s rname=%v",
+			"ndm.ioerror.blocked", "Destructive operation blocked on device in io error state",
+			op, target)
+		return false
+	}
+	if !c.SafeMode {
+		if c.isTargetProtected(op, target) {
+			return false
+		}
+		if c.Evaluation != nil {
+			c.Evaluation.record(target, string(op))
+			return false
+		}
+		return true
+	}
+	klog.Warningf("eventcode=%s msg=%s op=%s rname=%v",
+		"ndm.safemode.blocked", "Destructive operation blocked by safe mode",
+		op, target)
+	return false
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDestructiveOperationAllowed(t *testing.T) {
+	tests := map[string]struct {
+		safeMode     bool
+		discoverOnly bool
+		ioErrors     uint64
+		op           DestructiveOperation
+		target       string
+		want         bool
+	}{
+		"safe mode disabled, create partition": {
+			safeMode: false,
+			op:       CreatePartitionOperation,
+			want:     true,
+		},
+		"safe mode disabled, deactivate blockdevice": {
+			safeMode: false,
+			op:       DeactivateBlockDeviceOperation,
+			want:     true,
+		},
+		"safe mode enabled, create partition": {
+			safeMode: true,
+			op:       CreatePartitionOperation,
+			want:     false,
+		},
+		"safe mode enabled, wipe signatures": {
+			safeMode: true,
+			op:       WipeSignaturesOperation,
+			want:     false,
+		},
+		"safe mode enabled, deactivate blockdevice": {
+			safeMode: true,
+			op:       DeactivateBlockDeviceOperation,
+			want:     false,
+		},
+		"discover only mode, create partition": {
+			discoverOnly: true,
+			op:           CreatePartitionOperation,
+			want:         false,
+		},
+		"discover only mode, delete partition": {
+			discoverOnly: true,
+			op:           DeletePartitionOperation,
+			want:         false,
+		},
+		"discover only mode, deactivate blockdevice": {
+			discoverOnly: true,
+			op:           DeactivateBlockDeviceOperation,
+			want:         true,
+		},
+		"device in io error state, create partition": {
+			ioErrors: 100,
+			op:       CreatePartitionOperation,
+			want:     false,
+		},
+		"device in io error state, deactivate blockdevice": {
+			ioErrors: 100,
+			op:       DeactivateBlockDeviceOperation,
+			want:     true,
+		},
+		"device with io errors below threshold, create partition": {
+			ioErrors: 5,
+			op:       CreatePartitionOperation,
+			want:     true,
+		},
+		"partition of device in io error state, delete partition": {
+			ioErrors: 100,
+			op:       DeletePartitionOperation,
+			target:   "/dev/sda1",
+			want:     false,
+		},
+		"partition of device with io errors below threshold, wipe signatures": {
+			ioErrors: 5,
+			op:       WipeSignaturesOperation,
+			target:   "/dev/sda1",
+			want:     true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				Clientset:    CreateFakeClient(t),
+				SafeMode:     tt.safeMode,
+				DiscoverOnly: tt.discoverOnly,
+				BDHierarchy:  blockdevice.NewHierarchyCache(nil),
+			}
+			partition := blockdevice.BlockDevice{}
+			partition.DevPath = "/dev/sda1"
+			partition.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypePartition
+			partition.DependentDevices.Parent = "/dev/sda"
+			c.BDHierarchy.Set(partition.DevPath, partition)
+			if tt.ioErrors > 0 {
+				c.IOErrors = NewIOErrorTracker(50, DefaultIOErrorWindow)
+				c.IOErrors.Record("/dev/sda", 0)
+				c.IOErrors.Record("/dev/sda", tt.ioErrors)
+			}
+			target := tt.target
+			if target == "" {
+				target = "/dev/sda"
+			}
+			assert.Equal(t, tt.want, c.IsDestructiveOperationAllowed(tt.op, target))
+		})
+	}
+}
+
+func TestDeactivateBlockDeviceInSafeMode(t *testing.T) {
+	fakeNdmClient := CreateFakeClient(t)
+	nodeAttributes := make(map[string]string, 0)
+	nodeAttributes[HostNameKey] = fakeHostName
+	fakeController := &Controller{
+		NodeAttributes: nodeAttributes,
+		Clientset:      fakeNdmClient,
+		SafeMode:       true,
+	}
+
+	dr := fakeDevice
+	dr.ObjectMeta.Labels[KubernetesHostNameLabel] = fakeController.NodeAttributes[HostNameKey]
+	dr.ObjectMeta.Labels[NDMDeviceTypeKey] = NDMDefaultDeviceType
+	dr.Status.State = apis.BlockDeviceActive
+	err := fakeController.CreateBlockDevice(dr)
+	assert.NoError(t, err)
+
+	bd, err := fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+	fakeController.DeactivateBlockDevice(*bd, AuditCause{})
+
+	bd, err = fakeController.GetBlockDevice(fakeDeviceUID)
+	assert.NoError(t, err)
+	assert.Equal(t, apis.BlockDeviceActive, bd.Status.State)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultShutdownTimeout is the default time for which the shutdown of the daemon waits
+// for the events being processed to complete. It is kept below the default termination
+// grace period of the pod.
+const DefaultShutdownTimeout = 20 * time.Second
+
+// shutdownState is used to stop accepting new events on shutdown and to wait for the
+// events being processed to complete
+type shutdownState struct {
+	mutex sync.Mutex
+	// started is closed when the shutdown of the controller starts
+	started chan struct{}
+	// isStarted is set when the shutdown of the controller starts
+	isStarted bool
+	// inFlight is the events being processed
+	inFlight sync.WaitGroup
+}
+
+// startedChan gets the channel that is closed on shutdown, creating it if required.
+// mutex should be held by the caller.
+func (s *shutdownState) startedChan() chan struct{} {
+	if s.started == nil {
+		s.started = make(chan struct{})
+	}
+	return s.started
+}
+
+// BeginEventProcessing marks the start of processing of an event. false is returned if
+// the controller is shutting down, in which case the event should not be processed.
+// EndEventProcessing should be called once the processing completes.
+func (c *Controller) BeginEventProcessing() bool {
+	c.shutdown.mutex.Lock()
+	defer c.shutdown.mutex.Unlock()
+	if c.shutdown.isStarted {
+		return false
+	}
+	c.shutdown.inFlight.Add(1)
+	return true
+}
+
+// EndEventProcessing marks the completion of processing of an event
+func (c *Controller) EndEventProcessing() {
+	c.shutdown.inFlight.Done()
+}
+
+// ShutdownStarted returns the channel that is closed when the shutdown of the
+// controller starts, so that the listeners can stop.
+func (c *Controller) ShutdownStarted() <-chan struct{} {
+	c.shutdown.mutex.Lock()
+	defer c.shutdown.mutex.Unlock()
+	return c.shutdown.startedChan()
+}
+
+// Shutdown stops accepting new events and waits for the events being processed to
+// complete, so that devices are not left half processed, eg: partitioned without a
+// BlockDevice resource. An error is returned if the events do not complete before the
+// context is done.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.shutdown.mutex.Lock()
+	if !c.shutdown.isStarted {
+		c.shutdown.isStarted = true
+		close(c.shutdown.startedChan())
+	}
+	c.shutdown.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.shutdown.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		klog.Info("processing of in-flight events completed")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("processing of in-flight events did not complete: %w", ctx.Err())
+	}
+}
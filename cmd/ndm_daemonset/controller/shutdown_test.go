@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	tests := map[string]struct {
+		// completeInFlight completes the event being processed after shutdown starts
+		completeInFlight bool
+		wantErr          bool
+	}{
+		"event being processed completes before the timeout": {
+			completeInFlight: true,
+			wantErr:          false,
+		},
+		"event being processed does not complete before the timeout": {
+			completeInFlight: false,
+			wantErr:          true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{}
+			shutdown := c.ShutdownStarted()
+			assert.True(t, c.BeginEventProcessing())
+
+			go func() {
+				<-shutdown
+				if tt.completeInFlight {
+					c.EndEventProcessing()
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := c.Shutdown(ctx)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			// new events are not processed once shutdown has started
+			assert.False(t, c.BeginEventProcessing())
+			_, open := <-c.ShutdownStarted()
+			assert.False(t, open)
+
+			// shutting down again does not panic
+			ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_ = c.Shutdown(ctx)
+		})
+	}
+}
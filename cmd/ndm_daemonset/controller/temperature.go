@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// overTemperatureReason is the reason of the OverTemperature condition when the
+	// temperature is above the threshold
+	overTemperatureReason = "OverTemperature"
+	// temperatureNormalReason is the reason of the OverTemperature condition when the
+	// temperature is within the threshold
+	temperatureNormalReason = "TemperatureNormal"
+)
+
+// getTemperatureCondition gets the OverTemperature condition of the device from the
+// temperature reported by SMART. false is returned if no threshold is configured or the
+// temperature of the device is not known.
+func (c *Controller) getTemperatureCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if di.Temperature == nil {
+		return apis.BlockDeviceCondition{}, false
+	}
+	return c.GetTemperatureCondition(*di.Temperature)
+}
+
+// GetTemperatureCondition gets the OverTemperature condition for the temperature reported
+// by SMART. false is returned if no threshold is configured.
+func (c *Controller) GetTemperatureCondition(temperature int16) (apis.BlockDeviceCondition, bool) {
+	if c == nil || c.NDMConfig == nil || c.NDMConfig.TemperatureConfig == nil ||
+		c.NDMConfig.TemperatureConfig.WarningThreshold == 0 {
+		return apis.BlockDeviceCondition{}, false
+	}
+	threshold := c.NDMConfig.TemperatureConfig.WarningThreshold
+
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceOverTemperature,
+		LastTransitionTime: metav1.Now(),
+	}
+	if temperature > threshold {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = overTemperatureReason
+		condition.Message = fmt.Sprintf("temperature %d°C is above the threshold %d°C", temperature, threshold)
+	} else {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = temperatureNormalReason
+		condition.Message = fmt.Sprintf("temperature %d°C is within the threshold %d°C", temperature, threshold)
+	}
+	return condition, true
+}
+
+// getCondition gets the condition of the given type from the list of conditions
+func getCondition(conditions []apis.BlockDeviceCondition, conditionType apis.BlockDeviceConditionType) (apis.BlockDeviceCondition, bool) {
+	for _, condition := range conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return apis.BlockDeviceCondition{}, false
+}
+
+// mergeConditions merges the newly observed conditions with the existing conditions of the
+// resource. The transition time of a condition whose status has not changed is retained,
+// and the existing conditions that were not observed this time are kept as they are.
+func mergeConditions(newConditions, oldConditions []apis.BlockDeviceCondition) []apis.BlockDeviceCondition {
+	var conditions []apis.BlockDeviceCondition
+	for _, condition := range newConditions {
+		if oldCondition, ok := getCondition(oldConditions, condition.Type); ok &&
+			oldCondition.Status == condition.Status {
+			condition.LastTransitionTime = oldCondition.LastTransitionTime
+		}
+		conditions = append(conditions, condition)
+	}
+	for _, oldCondition := range oldConditions {
+		if _, ok := getCondition(newConditions, oldCondition.Type); !ok {
+			conditions = append(conditions, oldCondition)
+		}
+	}
+	return conditions
+}
+
+// recordConditionTransitions records an event on the BlockDevice for each condition whose
+// status has changed from the old conditions. A condition that was not present earlier
+// is considered to be in its healthy status, so that no event is recorded for a device
+// that is healthy. Events are recorded only on transitions, so that they are not
+// repeated on every probe.
+func (c *Controller) recordConditionTransitions(blockDevice *apis.BlockDevice, oldConditions []apis.BlockDeviceCondition) {
+	for _, condition := range blockDevice.Status.Conditions {
+		healthyStatus := getHealthyConditionStatus(condition.Type)
+		oldStatus := healthyStatus
+		if oldCondition, ok := getCondition(oldConditions, condition.Type); ok {
+			oldStatus = oldCondition.Status
+		}
+		if condition.Status == oldStatus {
+			continue
+		}
+
+		eventType := v1.EventTypeNormal
+		if condition.Status != healthyStatus && condition.Status != v1.ConditionUnknown {
+			eventType = v1.EventTypeWarning
+		}
+		klog.Infof("eventcode=%s msg=%s rname=%v condition=%s status=%s reason=%s",
+			"ndm.blockdevice.condition.changed", "Condition of blockdevice changed",
+			blockDevice.Name, condition.Type, condition.Status, condition.Reason)
+		if c.Recorder != nil {
+			c.Recorder.Event(blockDevice, eventType, condition.Reason, condition.Message)
+		}
+	}
+}
+
+// getHealthyConditionStatus gets the status of the condition type when the device is
+// healthy. The conditions report problems with the device, except Writable.
+func getHealthyConditionStatus(conditionType apis.BlockDeviceConditionType) v1.ConditionStatus {
+	if conditionType == apis.BlockDeviceWritable {
+		return v1.ConditionTrue
+	}
+	return v1.ConditionFalse
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetTemperatureCondition(t *testing.T) {
+	temperature := int16(70)
+	tests := map[string]struct {
+		config      *NodeDiskManagerConfig
+		temperature *int16
+		wantOk      bool
+		wantStatus  v1.ConditionStatus
+	}{
+		"no config": {
+			temperature: &temperature,
+		},
+		"no threshold configured": {
+			config:      &NodeDiskManagerConfig{},
+			temperature: &temperature,
+		},
+		"temperature not known": {
+			config: &NodeDiskManagerConfig{TemperatureConfig: &TemperatureConfig{WarningThreshold: 60}},
+		},
+		"temperature above threshold": {
+			config:      &NodeDiskManagerConfig{TemperatureConfig: &TemperatureConfig{WarningThreshold: 60}},
+			temperature: &temperature,
+			wantOk:      true,
+			wantStatus:  v1.ConditionTrue,
+		},
+		"temperature equal to threshold": {
+			config:      &NodeDiskManagerConfig{TemperatureConfig: &TemperatureConfig{WarningThreshold: 70}},
+			temperature: &temperature,
+			wantOk:      true,
+			wantStatus:  v1.ConditionFalse,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{NDMConfig: test.config}
+			di := NewDeviceInfo()
+			di.Temperature = test.temperature
+			got, ok := c.getTemperatureCondition(di)
+			assert.Equal(t, test.wantOk, ok)
+			if ok {
+				assert.Equal(t, apis.BlockDeviceOverTemperature, got.Type)
+				assert.Equal(t, test.wantStatus, got.Status)
+			}
+		})
+	}
+}
+
+func TestTemperatureConditionTransitions(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	fakeController := &Controller{
+		NodeAttributes: map[string]string{HostNameKey: fakeHostName},
+		Clientset:      CreateFakeClient(t),
+		Recorder:       recorder,
+		NDMConfig: &NodeDiskManagerConfig{
+			TemperatureConfig: &TemperatureConfig{WarningThreshold: 60},
+		},
+	}
+
+	steps := []struct {
+		temperature int16
+		wantStatus  v1.ConditionStatus
+		wantEvent   string
+		// wantSameTransition is set if the transition time should be retained
+		wantSameTransition bool
+	}{
+		{temperature: 40, wantStatus: v1.ConditionFalse},
+		{temperature: 70, wantStatus: v1.ConditionTrue, wantEvent: "Warning OverTemperature"},
+		{temperature: 75, wantStatus: v1.ConditionTrue, wantSameTransition: true},
+		{temperature: 50, wantStatus: v1.ConditionFalse, wantEvent: "Normal TemperatureNormal"},
+	}
+
+	var previous apis.BlockDeviceCondition
+	for i, step := range steps {
+		di := NewDeviceInfo()
+		di.UUID = fakeDeviceUID
+		di.NodeAttributes = fakeController.NodeAttributes
+		di.Temperature = &step.temperature
+		bd, err := di.ToDevice(fakeController)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fakeController.CreateBlockDevice(bd); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := fakeController.GetBlockDevice(fakeDeviceUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		condition, ok := getCondition(got.Status.Conditions, apis.BlockDeviceOverTemperature)
+		assert.True(t, ok, "step %d", i)
+		assert.Equal(t, step.wantStatus, condition.Status, "step %d", i)
+		if step.wantSameTransition {
+			assert.True(t, previous.LastTransitionTime.Equal(&condition.LastTransitionTime), "step %d", i)
+		}
+		previous = condition
+
+		select {
+		case event := <-recorder.Events:
+			assert.Contains(t, event, step.wantEvent, "step %d", i)
+			assert.NotEmpty(t, step.wantEvent, "unexpected event %q at step %d", event, i)
+		default:
+			assert.Empty(t, step.wantEvent, "no event at step %d", i)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsTransportAllowed checks if devices attached over the transport are managed as per the
+// transport policy in the config. If not, the reason is returned. A policy entry matches
+// the transport, or the family of the transport, i.e nvme matches nvme-pcie and nvme-tcp.
+func (c *Controller) IsTransportAllowed(transport string) (bool, string) {
+	if c.NDMConfig == nil || c.NDMConfig.TransportPolicyConfig == nil {
+		return true, ""
+	}
+	policy := c.NDMConfig.TransportPolicyConfig
+
+	if matchesTransport(policy.Deny, transport) {
+		return false, fmt.Sprintf("transport %s is denied", transport)
+	}
+	if len(policy.Allow) != 0 && !matchesTransport(policy.Allow, transport) {
+		if transport == "" {
+			return false, "transport is not known, and is not in the allowed transports"
+		}
+		return false, fmt.Sprintf("transport %s is not in the allowed transports", transport)
+	}
+	return true, ""
+}
+
+// matchesTransport checks if the transport, or its family, is in the list of transports
+func matchesTransport(transports []string, transport string) bool {
+	if transport == "" {
+		return false
+	}
+	family := strings.SplitN(transport, "-", 2)[0]
+	for _, t := range transports {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == transport || t == family {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransportAllowed(t *testing.T) {
+	localDisks := &TransportPolicyConfig{
+		Allow: []string{"sata", "sas", "nvme"},
+		Deny:  []string{"usb", "iscsi"},
+	}
+	tests := map[string]struct {
+		transportPolicy *TransportPolicyConfig
+		transport       string
+		want            bool
+	}{
+		"no transport policy allows all transports": {
+			transportPolicy: nil,
+			transport:       bd.TransportUSB,
+			want:            true,
+		},
+		"no transport policy allows unknown transport": {
+			transportPolicy: nil,
+			transport:       "",
+			want:            true,
+		},
+		"transport in the allow list": {
+			transportPolicy: localDisks,
+			transport:       bd.TransportSATA,
+			want:            true,
+		},
+		"transport family in the allow list": {
+			transportPolicy: localDisks,
+			transport:       bd.TransportNVMePCIe,
+			want:            true,
+		},
+		"transport in the deny list": {
+			transportPolicy: localDisks,
+			transport:       bd.TransportUSB,
+			want:            false,
+		},
+		"transport not in the allow list": {
+			transportPolicy: localDisks,
+			transport:       bd.TransportVirtio,
+			want:            false,
+		},
+		"unknown transport with an allow list": {
+			transportPolicy: localDisks,
+			transport:       "",
+			want:            false,
+		},
+		"deny list takes precedence over the allow list": {
+			transportPolicy: &TransportPolicyConfig{
+				Allow: []string{"nvme"},
+				Deny:  []string{"nvme-tcp"},
+			},
+			transport: bd.TransportNVMeTCP,
+			want:      false,
+		},
+		"only a deny list allows other transports": {
+			transportPolicy: &TransportPolicyConfig{
+				Deny: []string{" USB "},
+			},
+			transport: bd.TransportSAS,
+			want:      true,
+		},
+		"only a deny list, entries are normalized": {
+			transportPolicy: &TransportPolicyConfig{
+				Deny: []string{" USB "},
+			},
+			transport: bd.TransportUSB,
+			want:      false,
+		},
+		"only a deny list allows unknown transport": {
+			transportPolicy: &TransportPolicyConfig{
+				Deny: []string{"usb"},
+			},
+			transport: "",
+			want:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{
+					TransportPolicyConfig: tt.transportPolicy,
+				},
+			}
+			got, reason := c.IsTransportAllowed(tt.transport)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want, reason == "")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+)
+
+// udevAnnotationPrefix is the prefix of the annotations holding the udev properties
+const udevAnnotationPrefix = NDMLabelPrefix + "udev-"
+
+// udevAnnotationProperties is the whitelist of the udev properties that are added as
+// annotations on the blockdevice. Only the properties identifying the device are added,
+// so that the resource is not bloated with all the udev properties.
+var udevAnnotationProperties = []string{
+	"ID_BUS",
+	"ID_MODEL",
+	"ID_PATH",
+	"ID_SERIAL",
+	"ID_SERIAL_SHORT",
+	"ID_TYPE",
+	"ID_VENDOR",
+	"ID_WWN",
+}
+
+// getUdevAnnotations gets the annotations for the whitelisted udev properties of a device,
+// eg: ID_BUS=ata is annotated as ndm.io/udev-id-bus=ata. Empty properties are skipped.
+func getUdevAnnotations(properties map[string]string) map[string]string {
+	annotations := make(map[string]string)
+	for _, property := range udevAnnotationProperties {
+		value, ok := properties[property]
+		if !ok || value == "" {
+			continue
+		}
+		annotations[getUdevAnnotationKey(property)] = value
+	}
+	return annotations
+}
+
+// getUdevAnnotationKey gets the annotation key of a udev property, eg: ID_BUS -> ndm.io/udev-id-bus
+func getUdevAnnotationKey(property string) string {
+	return udevAnnotationPrefix + strings.ReplaceAll(strings.ToLower(property), "_", "-")
+}
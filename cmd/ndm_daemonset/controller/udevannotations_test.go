@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUdevAnnotations(t *testing.T) {
+	tests := map[string]struct {
+		properties map[string]string
+		want       map[string]string
+	}{
+		"no properties": {
+			properties: nil,
+			want:       map[string]string{},
+		},
+		"only whitelisted properties are annotated": {
+			properties: map[string]string{
+				"ID_BUS":     "ata",
+				"ID_MODEL":   "Samsung_SSD_860",
+				"ID_SERIAL":  "Samsung_SSD_860_S3Z9NB0K",
+				"ID_PATH":    "pci-0000:00:17.0-ata-1",
+				"DEVNAME":    "/dev/sda",
+				"DEVLINKS":   "/dev/disk/by-id/ata-Samsung_SSD_860_S3Z9NB0K",
+				"ID_FS_TYPE": "ext4",
+			},
+			want: map[string]string{
+				"ndm.io/udev-id-bus":    "ata",
+				"ndm.io/udev-id-model":  "Samsung_SSD_860",
+				"ndm.io/udev-id-serial": "Samsung_SSD_860_S3Z9NB0K",
+				"ndm.io/udev-id-path":   "pci-0000:00:17.0-ata-1",
+			},
+		},
+		"empty properties are skipped": {
+			properties: map[string]string{
+				"ID_BUS": "",
+				"ID_WWN": "0x5002538e40a1b2c3",
+			},
+			want: map[string]string{
+				"ndm.io/udev-id-wwn": "0x5002538e40a1b2c3",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, getUdevAnnotations(test.properties))
+		})
+	}
+}
+
+func TestNewDeviceInfoFromBlockDeviceUdevAnnotations(t *testing.T) {
+	blockDevice := &bd.BlockDevice{}
+	blockDevice.UUID = "blockdevice-udev"
+	blockDevice.UdevProperties = map[string]string{"ID_BUS": "scsi", "DEVNAME": "/dev/sdb"}
+
+	c := &Controller{}
+	di := c.NewDeviceInfoFromBlockDevice(blockDevice)
+	assert.Empty(t, di.Annotations)
+
+	c.UdevAnnotations = true
+	di = c.NewDeviceInfoFromBlockDevice(blockDevice)
+	bdAPI, err := di.ToDevice(c)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ndm.io/udev-id-bus": "scsi"}, bdAPI.Annotations)
+}
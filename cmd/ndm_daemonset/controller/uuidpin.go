@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"k8s.io/klog/v2"
+)
+
+// pinnedUUIDRegex is the format of the UUIDs generated by NDM for blockdevices, i.e 32
+// hex characters of the v1 algorithm, 40 hex characters of the v2 / v3 algorithm, or the
+// RFC 4122 version 5 UUID of the v5 algorithm
+var pinnedUUIDRegex = regexp.MustCompile("^" + blockdevice.BlockDevicePrefix +
+	"([0-9a-f]{32}|[0-9a-f]{40}|[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12})$")
+
+// ValidatePinnedUUID checks if the pinned UUID is in the same format as that of
+// the UUIDs generated by NDM
+func ValidatePinnedUUID(uuid string) error {
+	if !pinnedUUIDRegex.MatchString(uuid) {
+		return fmt.Errorf("invalid uuid: %q, expected format %s<32 or 40 hex characters, or a version 5 uuid>",
+			uuid, blockdevice.BlockDevicePrefix)
+	}
+	return nil
+}
+
+// GetPinnedUUID returns the UUID pinned to the given device in the config, if any.
+// Pins with an invalid UUID are ignored.
+func (c *Controller) GetPinnedUUID(bd blockdevice.BlockDevice) (string, bool) {
+	if c.NDMConfig == nil {
+		return "", false
+	}
+	for _, pin := range c.NDMConfig.UUIDPinConfigs {
+		if !pin.matches(bd) {
+			continue
+		}
+		if err := ValidatePinnedUUID(pin.UUID); err != nil {
+			klog.Errorf("ignoring uuid pinned to device: %s, %v", bd.DevPath, err)
+			return "", false
+		}
+		return pin.UUID, true
+	}
+	return "", false
+}
+
+// matches checks if all the identifiers specified in the pin match the device. A pin
+// without any identifier does not match any device. Only disks are matched, since
+// partitions inherit the WWN and serial of the disk and would otherwise get the same
+// UUID as the disk.
+func (pin UUIDPinConfig) matches(bd blockdevice.BlockDevice) bool {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk {
+		return false
+	}
+	return matchesStableIdentifiers(pin.WWN, pin.Serial, bd)
+}
+
+// matchesStableIdentifiers checks if all the given identifiers that are not empty match
+// the device. No device is matched if none of the identifiers is given.
+func matchesStableIdentifiers(wwn, serial string, bd blockdevice.BlockDevice) bool {
+	if wwn == "" && serial == "" {
+		return false
+	}
+	if wwn != "" && wwn != bd.DeviceAttributes.WWN {
+		return false
+	}
+	if serial != "" && serial != bd.DeviceAttributes.Serial {
+		return false
+	}
+	return true
+}
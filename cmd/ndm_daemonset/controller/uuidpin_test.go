@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPinnedUUID(t *testing.T) {
+	validUUID := "blockdevice-0123456789abcdef0123456789abcdef"
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	tests := map[string]struct {
+		ndmConfig *NodeDiskManagerConfig
+		wantUUID  string
+		wantOK    bool
+	}{
+		"no ndm config": {
+			ndmConfig: nil,
+			wantUUID:  "",
+			wantOK:    false,
+		},
+		"pin matching wwn and serial": {
+			ndmConfig: &NodeDiskManagerConfig{
+				UUIDPinConfigs: []UUIDPinConfig{
+					{WWN: "0x5000c500a1b2c3d4", Serial: "ZA1B2C3D", UUID: validUUID},
+				},
+			},
+			wantUUID: validUUID,
+			wantOK:   true,
+		},
+		"pin matching only serial": {
+			ndmConfig: &NodeDiskManagerConfig{
+				UUIDPinConfigs: []UUIDPinConfig{
+					{Serial: "ZA1B2C3D", UUID: validUUID},
+				},
+			},
+			wantUUID: validUUID,
+			wantOK:   true,
+		},
+		"pin with different serial": {
+			ndmConfig: &NodeDiskManagerConfig{
+				UUIDPinConfigs: []UUIDPinConfig{
+					{WWN: "0x5000c500a1b2c3d4", Serial: "XXXXXXXX", UUID: validUUID},
+				},
+			},
+			wantUUID: "",
+			wantOK:   false,
+		},
+		"pin without identifiers": {
+			ndmConfig: &NodeDiskManagerConfig{
+				UUIDPinConfigs: []UUIDPinConfig{
+					{UUID: validUUID},
+				},
+			},
+			wantUUID: "",
+			wantOK:   false,
+		},
+		"pin with invalid uuid": {
+			ndmConfig: &NodeDiskManagerConfig{
+				UUIDPinConfigs: []UUIDPinConfig{
+					{Serial: "ZA1B2C3D", UUID: "my-disk"},
+				},
+			},
+			wantUUID: "",
+			wantOK:   false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{NDMConfig: tt.ndmConfig}
+			gotUUID, gotOK := c.GetPinnedUUID(bd)
+			assert.Equal(t, tt.wantUUID, gotUUID)
+			assert.Equal(t, tt.wantOK, gotOK)
+		})
+	}
+}
+
+func TestGetPinnedUUIDForPartitionsOfPinnedDisk(t *testing.T) {
+	validUUID := "blockdevice-0123456789abcdef0123456789abcdef"
+	c := &Controller{
+		NDMConfig: &NodeDiskManagerConfig{
+			UUIDPinConfigs: []UUIDPinConfig{
+				{WWN: "0x5000c500a1b2c3d4", Serial: "ZA1B2C3D", UUID: validUUID},
+			},
+		},
+	}
+	disk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sda1", "/dev/sda2"},
+		},
+	}
+
+	gotUUID, gotOK := c.GetPinnedUUID(disk)
+	assert.True(t, gotOK)
+	assert.Equal(t, validUUID, gotUUID)
+
+	for _, partition := range disk.DependentDevices.Partitions {
+		// partitions inherit the wwn and serial of the disk
+		bd := blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: partition,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				WWN:        disk.DeviceAttributes.WWN,
+				Serial:     disk.DeviceAttributes.Serial,
+				DeviceType: blockdevice.BlockDeviceTypePartition,
+			},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Parent: disk.DevPath,
+			},
+		}
+		gotUUID, gotOK := c.GetPinnedUUID(bd)
+		assert.False(t, gotOK, "partition %s should not be pinned", partition)
+		assert.Equal(t, "", gotUUID)
+	}
+}
+
+func TestValidatePinnedUUID(t *testing.T) {
+	tests := map[string]struct {
+		uuid    string
+		wantErr bool
+	}{
+		"valid uuid":             {uuid: "blockdevice-0123456789abcdef0123456789abcdef", wantErr: false},
+		"valid v2 uuid":          {uuid: "blockdevice-0123456789abcdef0123456789abcdef01234567", wantErr: false},
+		"valid v5 uuid":          {uuid: "blockdevice-5f0c2a1e-3b4d-5c6e-8f70-123456789abc", wantErr: false},
+		"dashed uuid of v4":      {uuid: "blockdevice-5f0c2a1e-3b4d-4c6e-8f70-123456789abc", wantErr: true},
+		"uuid without prefix":    {uuid: "0123456789abcdef0123456789abcdef", wantErr: true},
+		"uuid with short hash":   {uuid: "blockdevice-0123456789abcdef", wantErr: true},
+		"uuid with non hex hash": {uuid: "blockdevice-0123456789abcdef0123456789abcdeg", wantErr: true},
+		"empty uuid":             {uuid: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidatePinnedUUID(tt.uuid)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
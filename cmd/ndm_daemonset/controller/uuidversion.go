@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// UUIDVersion is the version of the algorithm used for hashing the identifier of a
+// device into the uuid of its BlockDevice resource
+type UUIDVersion string
+
+const (
+	// UUIDVersionV1 is the md5 hash of the identifier, used by all the earlier versions
+	// of NDM
+	UUIDVersionV1 UUIDVersion = "v1"
+
+	// UUIDVersionV2 is the sha256 hash of the identifier, truncated to 40 hex characters
+	// so that the names derived from the uuid, like the cleanup job name, are valid
+	// label values
+	UUIDVersionV2 UUIDVersion = "v2"
+
+	// UUIDVersionV3 is the v2 hash of a composite identifier. The WWN of a disk is combined
+	// with its serial and vendor, as the WWN alone can be the same for the disks
+	// in some cheap enclosures. Devices without a WWN use the same identifier as v2.
+	UUIDVersionV3 UUIDVersion = "v3"
+
+	// UUIDVersionV5 is the RFC 4122 version 5 (sha1, name based) UUID of the v2 identifier
+	// in the configured uuid namespace. Different clusters or NDM instances using different
+	// namespaces get different uuids for the same device. The version is named after the
+	// RFC 4122 version, and can only be used with a namespace.
+	UUIDVersionV5 UUIDVersion = "v5"
+
+	// DefaultUUIDVersion is the version used if none is specified
+	DefaultUUIDVersion = UUIDVersionV1
+)
+
+// UUIDVersions are all the known uuid versions that need no namespace, oldest first
+var UUIDVersions = []UUIDVersion{UUIDVersionV1, UUIDVersionV2, UUIDVersionV3}
+
+// ParseUUIDVersion validates and returns the uuid version.
+// Empty value is treated as the default version.
+func ParseUUIDVersion(version string) (UUIDVersion, error) {
+	switch UUIDVersion(version) {
+	case "":
+		return DefaultUUIDVersion, nil
+	case UUIDVersionV1, UUIDVersionV2, UUIDVersionV3, UUIDVersionV5:
+		return UUIDVersion(version), nil
+	}
+	return "", fmt.Errorf("invalid uuid version: %q, should be one of %s, %s, %s, %s",
+		version, UUIDVersionV1, UUIDVersionV2, UUIDVersionV3, UUIDVersionV5)
+}
+
+// ParseUUIDNamespace validates and returns the namespace of the v5 uuids.
+// Empty value is treated as no namespace, which is valid only if the version is not v5.
+func ParseUUIDNamespace(namespace string, version UUIDVersion) (uuid.UUID, error) {
+	if namespace == "" {
+		if version == UUIDVersionV5 {
+			return uuid.Nil, fmt.Errorf("uuid version %s requires a uuid namespace", UUIDVersionV5)
+		}
+		return uuid.Nil, nil
+	}
+	ns, err := uuid.Parse(namespace)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid uuid namespace: %q, %v", namespace, err)
+	}
+	if ns == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("invalid uuid namespace: %q, should not be the nil uuid", namespace)
+	}
+	return ns, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUUIDVersion(t *testing.T) {
+	tests := map[string]struct {
+		version string
+		want    UUIDVersion
+		wantErr bool
+	}{
+		"empty version uses the default": {
+			version: "",
+			want:    UUIDVersionV1,
+		},
+		"v1": {
+			version: "v1",
+			want:    UUIDVersionV1,
+		},
+		"v2": {
+			version: "v2",
+			want:    UUIDVersionV2,
+		},
+		"v3": {
+			version: "v3",
+			want:    UUIDVersionV3,
+		},
+		"v5": {
+			version: "v5",
+			want:    UUIDVersionV5,
+		},
+		"invalid version": {
+			version: "v4",
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseUUIDVersion(test.version)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParseUUIDNamespace(t *testing.T) {
+	tests := map[string]struct {
+		namespace string
+		version   UUIDVersion
+		want      uuid.UUID
+		wantErr   bool
+	}{
+		"no namespace without v5": {
+			namespace: "",
+			version:   UUIDVersionV2,
+			want:      uuid.Nil,
+		},
+		"no namespace with v5": {
+			namespace: "",
+			version:   UUIDVersionV5,
+			want:      uuid.Nil,
+			wantErr:   true,
+		},
+		"valid namespace": {
+			namespace: "3f1c2b6e-8a4d-4e5f-9b7a-1c2d3e4f5a6b",
+			version:   UUIDVersionV5,
+			want:      uuid.MustParse("3f1c2b6e-8a4d-4e5f-9b7a-1c2d3e4f5a6b"),
+		},
+		"invalid namespace": {
+			namespace: "ndm",
+			version:   UUIDVersionV5,
+			want:      uuid.Nil,
+			wantErr:   true,
+		},
+		"nil namespace": {
+			namespace: "00000000-0000-0000-0000-000000000000",
+			version:   UUIDVersionV5,
+			want:      uuid.Nil,
+			wantErr:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseUUIDNamespace(test.namespace, test.version)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
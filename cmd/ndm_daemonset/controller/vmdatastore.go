@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DefaultVMDatastorePaths are the paths of the VM datastores used if none are given
+// in the config, i.e the default storage pool of libvirt and the path used by the
+// hostpath provisioner of KubeVirt.
+var DefaultVMDatastorePaths = []string{
+	"/var/lib/libvirt/images",
+	"/var/hpvolumes",
+}
+
+// GetVMDatastorePath gets the path of the VM datastore at or below which any of the
+// given mount points lies.
+func (c *Controller) GetVMDatastorePath(mountPoints []string) (string, bool) {
+	datastorePaths := DefaultVMDatastorePaths
+	if c.NDMConfig != nil && len(c.NDMConfig.VMDatastorePaths) > 0 {
+		datastorePaths = c.NDMConfig.VMDatastorePaths
+	}
+	return getBasePath(datastorePaths, mountPoints)
+}
+
+// getBasePath gets the first of the base paths at or below which any of the given
+// mount points lies.
+func getBasePath(basePaths []string, mountPoints []string) (string, bool) {
+	for _, basePath := range basePaths {
+		basePath = filepath.Clean(basePath)
+		for _, mountPoint := range mountPoints {
+			mountPoint = filepath.Clean(mountPoint)
+			if mountPoint == basePath ||
+				strings.HasPrefix(mountPoint, basePath+string(filepath.Separator)) {
+				return basePath, true
+			}
+		}
+	}
+	return "", false
+}
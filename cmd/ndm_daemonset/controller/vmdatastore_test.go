@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVMDatastorePath(t *testing.T) {
+	tests := map[string]struct {
+		datastorePaths []string
+		mountPoints    []string
+		wantPath       string
+		wantOk         bool
+	}{
+		"mounted at the default libvirt pool path": {
+			mountPoints: []string{"/var/lib/libvirt/images"},
+			wantPath:    "/var/lib/libvirt/images",
+			wantOk:      true,
+		},
+		"mounted below the default libvirt pool path": {
+			mountPoints: []string{"/mnt/data", "/var/lib/libvirt/images/pool1/"},
+			wantPath:    "/var/lib/libvirt/images",
+			wantOk:      true,
+		},
+		"path having the pool path as prefix is not a datastore": {
+			mountPoints: []string{"/var/lib/libvirt/images-backup"},
+			wantOk:      false,
+		},
+		"mounted at a configured path": {
+			datastorePaths: []string{"/vmstore/"},
+			mountPoints:    []string{"/vmstore"},
+			wantPath:       "/vmstore",
+			wantOk:         true,
+		},
+		"default paths are not used if paths are configured": {
+			datastorePaths: []string{"/vmstore"},
+			mountPoints:    []string{"/var/lib/libvirt/images"},
+			wantOk:         false,
+		},
+		"not mounted": {
+			mountPoints: nil,
+			wantOk:      false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{
+				NDMConfig: &NodeDiskManagerConfig{VMDatastorePaths: tt.datastorePaths},
+			}
+			gotPath, gotOk := c.GetVMDatastorePath(tt.mountPoints)
+			assert.Equal(t, tt.wantOk, gotOk)
+			assert.Equal(t, tt.wantPath, gotPath)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// writeVerifiedReason is the reason of the Writable condition when the data written
+	// to the disk was read back
+	writeVerifiedReason = "WriteVerified"
+	// writeFailedReason is the reason of the Writable condition when the data could not
+	// be written to the disk, or was not read back
+	writeFailedReason = "WriteFailed"
+	// writeCheckSkippedReason is the reason of the Writable condition when the disk was
+	// not checked, as it is in use or not blank
+	writeCheckSkippedReason = "WriteCheckSkipped"
+)
+
+// getWritableCondition gets the Writable condition of the disk from the result of its
+// writability check. false is returned if the disk was not checked.
+func getWritableCondition(di *DeviceInfo) (apis.BlockDeviceCondition, bool) {
+	if di.Writability == nil {
+		return apis.BlockDeviceCondition{}, false
+	}
+	writability := *di.Writability
+	condition := apis.BlockDeviceCondition{
+		Type:               apis.BlockDeviceWritable,
+		LastTransitionTime: metav1.Now(),
+	}
+	switch {
+	case !writability.Checked:
+		condition.Status = v1.ConditionUnknown
+		condition.Reason = writeCheckSkippedReason
+		condition.Message = writability.Message
+	case writability.Writable:
+		condition.Status = v1.ConditionTrue
+		condition.Reason = writeVerifiedReason
+		condition.Message = "data written to the scratch region at the end of the disk was read back"
+	default:
+		condition.Status = v1.ConditionFalse
+		condition.Reason = writeFailedReason
+		condition.Message = writability.Message
+	}
+	return condition, true
+}
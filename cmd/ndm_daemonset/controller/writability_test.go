@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	bd "github.com/openebs/node-disk-manager/blockdevice"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetWritableCondition(t *testing.T) {
+	tests := map[string]struct {
+		writability *bd.WritabilityState
+		wantStatus  v1.ConditionStatus
+		wantReason  string
+		wantOk      bool
+	}{
+		"not checked": {
+			writability: nil,
+			wantOk:      false,
+		},
+		"verified": {
+			writability: &bd.WritabilityState{Checked: true, Writable: true},
+			wantStatus:  v1.ConditionTrue,
+			wantReason:  writeVerifiedReason,
+			wantOk:      true,
+		},
+		"failed": {
+			writability: &bd.WritabilityState{Checked: true, Message: "input/output error"},
+			wantStatus:  v1.ConditionFalse,
+			wantReason:  writeFailedReason,
+			wantOk:      true,
+		},
+		"skipped": {
+			writability: &bd.WritabilityState{Message: "writability check skipped, device is in use"},
+			wantStatus:  v1.ConditionUnknown,
+			wantReason:  writeCheckSkippedReason,
+			wantOk:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			di := NewDeviceInfo()
+			di.Writability = tt.writability
+			got, ok := getWritableCondition(di)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantReason, got.Reason)
+		})
+	}
+}
+
+func TestWritableConditionTransitions(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{Recorder: recorder}
+	tests := map[string]struct {
+		status    v1.ConditionStatus
+		wantEvent string
+	}{
+		// the Writable condition is healthy when True, unlike the other conditions
+		"verified":     {status: v1.ConditionTrue, wantEvent: ""},
+		"write failed": {status: v1.ConditionFalse, wantEvent: "Warning"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			blockDevice := &apis.BlockDevice{}
+			blockDevice.Status.Conditions = []apis.BlockDeviceCondition{
+				{Type: apis.BlockDeviceWritable, Status: tt.status},
+			}
+			c.recordConditionTransitions(blockDevice, nil)
+			select {
+			case event := <-recorder.Events:
+				assert.Contains(t, event, tt.wantEvent)
+				assert.NotEmpty(t, tt.wantEvent, "unexpected event %q", event)
+			default:
+				assert.Empty(t, tt.wantEvent, "no event")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+const (
+	// WriteCacheEnabled is the state of a device whose volatile write cache is enabled
+	WriteCacheEnabled = "enabled"
+	// WriteCacheDisabled is the state of a device whose volatile write cache is disabled,
+	// or that has no volatile write cache
+	WriteCacheDisabled = "disabled"
+)
+
+// getWriteCacheState gets the state of the write cache of the device, surfaced on the
+// blockdevice. Empty is returned if the state is not known.
+func getWriteCacheState(writeCacheEnabled *bool) string {
+	if writeCacheEnabled == nil {
+		return ""
+	}
+	if *writeCacheEnabled {
+		return WriteCacheEnabled
+	}
+	return WriteCacheDisabled
+}
@@ -32,6 +32,8 @@ var RegisteredFilters = []func(){
 	vendorFilterRegister,
 	pathFilterRegister,
 	deviceValidityFilterRegister,
+	removableDeviceFilterRegister,
+	loopDeviceFilterRegister,
 }
 
 type registerFilter struct {
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// NOTE: This is an internal filter used by NDM to exclude loop devices. Loop devices
+// backed by image files are common on developer and CI nodes, and would otherwise
+// create BlockDevice resources that are not usable as storage.
+//
+// The filter is controlled using the loop device policy of the daemon. Loop devices
+// are also excluded by the default configuration of the path filter, which has to be
+// changed along with the policy to manage them.
+
+var (
+	loopDeviceFilterName  = "loop device filter" // filter loop devices
+	loopDeviceFilterState = defaultEnabled       // filter state
+)
+
+// loopDeviceFilterRegister contains registration process of loopDeviceFilter
+var loopDeviceFilterRegister = func() {
+	ctrl := <-controller.ControllerBroadcastChannel
+	if ctrl == nil {
+		return
+	}
+
+	var fi controller.FilterInterface = newLoopDeviceFilter(ctrl)
+	newRegisterFilter := &registerFilter{
+		name:       loopDeviceFilterName,
+		state:      loopDeviceFilterState,
+		fi:         fi,
+		controller: ctrl,
+	}
+	newRegisterFilter.register()
+}
+
+// loopDeviceFilter excludes loop devices based on the policy
+type loopDeviceFilter struct {
+	controller *controller.Controller
+	policy     controller.LoopDevicePolicy
+}
+
+// newLoopDeviceFilter returns new pointer to a loopDeviceFilter
+func newLoopDeviceFilter(ctrl *controller.Controller) *loopDeviceFilter {
+	return &loopDeviceFilter{
+		controller: ctrl,
+	}
+}
+
+// Start sets the policy to be used by the filter
+func (ldf *loopDeviceFilter) Start() {
+	ldf.policy = controller.DefaultLoopDevicePolicy
+	if ldf.controller != nil && ldf.controller.LoopDevicePolicy != "" {
+		ldf.policy = ldf.controller.LoopDevicePolicy
+	}
+}
+
+// Include returns true because loop devices are only excluded
+func (ldf *loopDeviceFilter) Include(blockDevice *blockdevice.BlockDevice) bool {
+	return true
+}
+
+// Exclude returns false if the device is a loop device and loop devices
+// are to be ignored
+func (ldf *loopDeviceFilter) Exclude(blockDevice *blockdevice.BlockDevice) bool {
+	if blockDevice.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypeLoop &&
+		ldf.policy == controller.IgnoreLoopDevices {
+		klog.V(4).Infof("device: %s is a loop device backed by %q, and is ignored",
+			blockDevice.DevPath, blockDevice.LoopInfo.BackingFile)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoopDeviceFilterExclude(t *testing.T) {
+	loopDevice := &blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/loop0",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeLoop,
+		},
+		LoopInfo: blockdevice.LoopInformation{
+			BackingFile: "/var/lib/images/disk0.img",
+			Sparse:      true,
+		},
+	}
+	disk := &blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	tests := map[string]struct {
+		policy      controller.LoopDevicePolicy
+		blockDevice *blockdevice.BlockDevice
+		want        bool
+	}{
+		"default policy, loop device": {
+			policy:      "",
+			blockDevice: loopDevice,
+			want:        false,
+		},
+		"ignore policy, loop device": {
+			policy:      controller.IgnoreLoopDevices,
+			blockDevice: loopDevice,
+			want:        false,
+		},
+		"ignore policy, disk": {
+			policy:      controller.IgnoreLoopDevices,
+			blockDevice: disk,
+			want:        true,
+		},
+		"manage policy, loop device": {
+			policy:      controller.ManageLoopDevices,
+			blockDevice: loopDevice,
+			want:        true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ldf := newLoopDeviceFilter(&controller.Controller{
+				LoopDevicePolicy: test.policy,
+			})
+			ldf.Start()
+			assert.Equal(t, test.want, ldf.Exclude(test.blockDevice))
+		})
+	}
+}
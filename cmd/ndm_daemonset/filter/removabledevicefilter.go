@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// NOTE: This is an internal filter used by NDM to exclude removable media like
+// USB sticks and external USB drives. These devices are frequently plugged / unplugged
+// and would otherwise cause churn of BlockDevice resources.
+//
+// The filter is controlled using the removable device policy of the daemon.
+
+var (
+	removableDeviceFilterName  = "removable device filter" // filter removable devices
+	removableDeviceFilterState = defaultEnabled            // filter state
+)
+
+// removableDeviceFilterRegister contains registration process of removableDeviceFilter
+var removableDeviceFilterRegister = func() {
+	ctrl := <-controller.ControllerBroadcastChannel
+	if ctrl == nil {
+		return
+	}
+
+	var fi controller.FilterInterface = newRemovableDeviceFilter(ctrl)
+	newRegisterFilter := &registerFilter{
+		name:       removableDeviceFilterName,
+		state:      removableDeviceFilterState,
+		fi:         fi,
+		controller: ctrl,
+	}
+	newRegisterFilter.register()
+}
+
+// removableDeviceFilter excludes removable devices based on the policy
+type removableDeviceFilter struct {
+	controller *controller.Controller
+	policy     controller.RemovableDevicePolicy
+}
+
+// newRemovableDeviceFilter returns new pointer to a removableDeviceFilter
+func newRemovableDeviceFilter(ctrl *controller.Controller) *removableDeviceFilter {
+	return &removableDeviceFilter{
+		controller: ctrl,
+	}
+}
+
+// Start sets the policy to be used by the filter
+func (rdf *removableDeviceFilter) Start() {
+	rdf.policy = controller.DefaultRemovableDevicePolicy
+	if rdf.controller != nil && rdf.controller.RemovableDevicePolicy != "" {
+		rdf.policy = rdf.controller.RemovableDevicePolicy
+	}
+}
+
+// Include returns true because removable devices are only excluded
+func (rdf *removableDeviceFilter) Include(blockDevice *blockdevice.BlockDevice) bool {
+	return true
+}
+
+// Exclude returns false if the device is removable and removable devices
+// are to be ignored
+func (rdf *removableDeviceFilter) Exclude(blockDevice *blockdevice.BlockDevice) bool {
+	if blockDevice.DeviceAttributes.Removable &&
+		rdf.policy == controller.IgnoreRemovableDevices {
+		klog.V(4).Infof("device: %s is removable, and is ignored", blockDevice.DevPath)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemovableDeviceFilterExclude(t *testing.T) {
+	removableDevice := &blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdc",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			Removable: true,
+		},
+	}
+	fixedDevice := &blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+	}
+
+	tests := map[string]struct {
+		policy      controller.RemovableDevicePolicy
+		blockDevice *blockdevice.BlockDevice
+		want        bool
+	}{
+		"default policy, removable device": {
+			policy:      "",
+			blockDevice: removableDevice,
+			want:        false,
+		},
+		"ignore policy, removable device": {
+			policy:      controller.IgnoreRemovableDevices,
+			blockDevice: removableDevice,
+			want:        false,
+		},
+		"ignore policy, fixed device": {
+			policy:      controller.IgnoreRemovableDevices,
+			blockDevice: fixedDevice,
+			want:        true,
+		},
+		"manage policy, removable device": {
+			policy:      controller.ManageRemovableDevices,
+			blockDevice: removableDevice,
+			want:        true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rdf := newRemovableDeviceFilter(&controller.Controller{
+				RemovableDevicePolicy: test.policy,
+			})
+			rdf.Start()
+			assert.Equal(t, test.want, rdf.Exclude(test.blockDevice))
+		})
+	}
+}
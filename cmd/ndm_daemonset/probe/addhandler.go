@@ -18,9 +18,11 @@ package probe
 
 import (
 	"fmt"
+	"strings"
 
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/db/kubernetes"
 	"github.com/openebs/node-disk-manager/pkg/features"
 	"github.com/openebs/node-disk-manager/pkg/partition"
@@ -33,8 +35,12 @@ const (
 	internalUUIDSchemeAnnotation    = "internal.openebs.io/uuid-scheme"
 	legacyUUIDScheme                = "legacy"
 	gptUUIDScheme                   = "gpt"
+	pinnedUUIDScheme                = "pinned"
 	internalFSUUIDAnnotation        = "internal.openebs.io/fsuuid"
 	internalPartitionUUIDAnnotation = "internal.openebs.io/partition-uuid"
+	// internalNeedsIdentifierAnnotation is added on the resources created in discover only
+	// mode, whose uuid depends on the path of the device as no unique identifier was found
+	internalNeedsIdentifierAnnotation = "internal.openebs.io/needs-identifier"
 )
 
 // addBlockDeviceToHierarchyCache adds the given block device to the hierarchy of devices.
@@ -42,7 +48,7 @@ const (
 func (pe *ProbeEvent) addBlockDeviceToHierarchyCache(bd blockdevice.BlockDevice) bool {
 	var deviceAlreadyExistsInCache bool
 	// check if the device already exists in the cache
-	_, ok := pe.Controller.BDHierarchy[bd.DevPath]
+	_, ok := pe.Controller.BDHierarchy.Get(bd.DevPath)
 	if ok {
 		klog.V(4).Infof("device: %s already exists in cache, "+
 			"the event was likely generated by a partition table re-read or "+
@@ -55,24 +61,84 @@ func (pe *ProbeEvent) addBlockDeviceToHierarchyCache(bd blockdevice.BlockDevice)
 		deviceAlreadyExistsInCache = false
 	}
 
-	// in either case, whether it existed or not, we will update with the latest BD into the cache
-	pe.Controller.BDHierarchy[bd.DevPath] = bd
+	// in either case, whether it existed or not, we will update with the latest BD into the cache.
+	// The decision trace is specific to the event being processed, and is not cached.
+	bd.DecisionTrace = nil
+	pe.Controller.BDHierarchy.Set(bd.DevPath, bd)
 	return deviceAlreadyExistsInCache
 }
 
 // addBlockDevice processed when an add event is received for a device
 func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) error {
 
+	// the decision trace records the branches taken for the device, it is added to the
+	// resource of the device and logged once the event is processed.
+	if pe.Controller.DecisionTrace && bd.DecisionTrace == nil {
+		bd.DecisionTrace = &blockdevice.DecisionTrace{}
+	}
+	defer logDecisionTrace(bd)
+
+	// the parent of a partition may have been renumbered, eg: from /dev/sda to /dev/sdb on
+	// a bus rescan. The reference to the parent is repaired before the parent is looked up.
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		if parent, ok := pe.getParentDevice(bd); ok &&
+			parent.DevPath != "" && parent.DevPath != bd.DependentDevices.Parent {
+			bd.DependentDevices.Parent = parent.DevPath
+			bd.DecisionTrace.Add("parent:repaired")
+		}
+	}
+
+	// devices outside the configured capacity range are not managed at all, no
+	// resource is created and no partition is created on them.
+	if ok, reason := pe.Controller.IsCapacityInRange(pe.getRangeCapacity(bd)); !ok {
+		klog.Infof("device: %s skipped, %s", bd.DevPath, reason)
+		bd.DecisionTrace.Add("capacity-range:skipped")
+		return nil
+	}
+
+	// devices attached over a transport not allowed by the transport policy are not
+	// managed at all, eg: when only the local disks are to be managed.
+	if ok, reason := pe.Controller.IsTransportAllowed(bd.DeviceAttributes.Transport); !ok {
+		klog.Infof("device: %s skipped, %s", bd.DevPath, reason)
+		bd.DecisionTrace.Add("transport-policy:skipped")
+		return nil
+	}
+
+	// in the allow list mode, only the devices that are explicitly listed are managed,
+	// eg: on locked-down nodes.
+	if ok, reason := pe.Controller.IsDeviceAllowListed(pe.getIdentityDevice(bd)); !ok {
+		klog.Infof("device: %s skipped, %s", bd.DevPath, reason)
+		bd.DecisionTrace.Add("allow-list:skipped")
+		return nil
+	}
+
+	// devices whose resource is managed by another NDM instance are left alone, so that
+	// the instances do not fight over the device, eg: during a migration.
+	if owner, ok := pe.getPeerOwner(bd, bdAPIList); ok {
+		klog.Infof("device: %s skipped, managed by ndm instance: %s", bd.DevPath, owner)
+		bd.DecisionTrace.Add("peer-owner:skipped")
+		return nil
+	}
+
 	// handle devices that are not managed by NDM
 	// eg:devices in use by mayastor, zfs PV and jiva
 	// TODO jiva handling is still to be added.
+	if bd.DevUse.InUse {
+		bd.DecisionTrace.Add("in-use:" + string(bd.DevUse.UsedBy))
+	}
 	if ok, err := pe.handleUnmanagedDevices(bd, bdAPIList); err != nil {
 		klog.Errorf("error handling unmanaged device %s. error: %v", bd.DevPath, err)
 		return err
 	} else if !ok {
-		klog.V(4).Infof("processed device: %s being used by mayastor/zfs-localPV", bd.DevPath)
+		klog.V(4).Infof("processed device: %s being used by mayastor/zfs-localPV/windows/longhorn/glusterfs/reserved by another host", bd.DevPath)
+		bd.DecisionTrace.Add("unmanaged-checks:unmanaged")
 		return nil
 	}
+	bd.DecisionTrace.Add("unmanaged-checks:passed")
+
+	// partitions written into the area reserved at the start of a disk partitioned by
+	// NDM are flagged, so that the violation is visible on the resource
+	pe.checkReservedArea(&bd)
 
 	// if parent device in use, no need to process further
 	if ok, err := pe.isParentDeviceInUse(bd); err != nil {
@@ -80,8 +146,18 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 		return err
 	} else if ok {
 		klog.Infof("parent device of device: %s in use", bd.DevPath)
+		bd.DecisionTrace.Add("parent-in-use:true")
 		return nil
 	}
+	bd.DecisionTrace.Add("parent-in-use:false")
+
+	// if the operator has pinned a UUID to the device, it takes precedence over
+	// the generated UUID.
+	if pinnedUUID, ok := pe.Controller.GetPinnedUUID(bd); ok {
+		klog.Infof("uuid: %s has been pinned to device: %s", pinnedUUID, bd.DevPath)
+		bd.DecisionTrace.Add("uuid-basis:pinned")
+		return pe.createOrUpdateWithPinnedUUID(bd, pinnedUUID, bdAPIList)
+	}
 
 	// upgrades the devices that are in use and used the legacy method
 	// for uuid generation.
@@ -90,8 +166,33 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 		return err
 	} else if !ok {
 		klog.V(4).Infof("device: %s upgraded", bd.DevPath)
+		bd.DecisionTrace.Add("upgrade:upgraded")
 		return nil
 	}
+	bd.DecisionTrace.Add("upgrade:not-required")
+
+	// devices with multiple signatures are left alone until the stale signatures are
+	// wiped, if the multi signature policy requires it
+	if pe.requiresSignatureWipe(bd, bdAPIList) {
+		bd.DecisionTrace.Add("multi-signature:wipe-required")
+		return nil
+	}
+
+	// devices with conflicting metadata are quarantined instead of guessing their
+	// identity or usage. No destructive operations are performed on them.
+	checkPartitionTable(&bd)
+	if reason := pe.computeQuarantineReason(bd, bdAPIList); reason != "" {
+		bd.DecisionTrace.Add("quarantine:conflicting-metadata")
+		return pe.quarantineBlockDevice(bd, reason, bdAPIList)
+	}
+
+	// the stale filesystem on a disk explicitly listed for reclaim in the config is wiped,
+	// so that the disk is partitioned like a blank disk.
+	if ok, err := pe.reclaimStaleFilesystem(&bd, bdAPIList); err != nil {
+		return err
+	} else if ok {
+		bd.DecisionTrace.Add("filesystem:reclaimed")
+	}
 
 	/*
 		Cases when an add event is generated
@@ -104,41 +205,109 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 
 	// check if the disk can be uniquely identified. we try to generate the UUID for the device
 	klog.V(4).Infof("checking if device: %s can be uniquely identified", bd.DevPath)
-	uuid, ok := generateUUID(bd)
+	uuid, basis, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
 	// if UUID cannot be generated create a GPT partition on the device
 	if !ok {
 		klog.V(4).Infof("device: %s cannot be uniquely identified", bd.DevPath)
+		bd.DecisionTrace.Add("uuid-basis:none")
 		if len(bd.DependentDevices.Partitions) > 0 ||
 			len(bd.DependentDevices.Holders) > 0 {
 			klog.V(4).Infof("device: %s has holders/partitions. %+v", bd.DevPath, bd.DependentDevices)
+			bd.DecisionTrace.Add("partitioning:skipped-holders-or-partitions")
+		} else if mountedDevice, ok := getDeviceWithActiveMount(bd, pe.Controller.BDHierarchy.Snapshot()); ok {
+			klog.Infof("device: %s has an active mount on %s at %v, skipping partitioning",
+				bd.DevPath, mountedDevice.DevPath, mountedDevice.FSInfo.MountPoint)
+			bd.DecisionTrace.Add("partitioning:skipped-active-mount")
+		} else if blockdevice.IsFabricTransport(bd.DeviceAttributes.Transport) && !pe.Controller.PartitionFabricDevices {
+			klog.Infof("device: %s is attached over fabric transport: %s from target: %s, skipping partitioning",
+				bd.DevPath, bd.DeviceAttributes.Transport, bd.DeviceAttributes.FabricTarget)
+			bd.DecisionTrace.Add("partitioning:skipped-fabric-transport")
+		} else if !pe.Controller.IsNodeProvisioned() {
+			klog.Infof("device: %s not partitioned, waiting for the provisioning of the node to complete",
+				bd.DevPath)
+			bd.DecisionTrace.Add("partitioning:skipped-node-not-provisioned")
+		} else if pe.Controller.DiscoverOnly {
+			bd.DecisionTrace.Add("partitioning:skipped-discover-only")
+			return pe.createOrUpdateInDiscoverOnlyMode(bd, bdAPIList)
+		} else if ok, reason := pe.Controller.HasPartitioningConfidence(bd); !ok {
+			// partitioning is deferred till the device is probed better, it is retried
+			// when the next event of the device is processed.
+			klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+				"ndm.blockdevice.needs.more.info", "Deferring partitioning of sparsely probed device",
+				reason, bd.DevPath)
+			bd.DecisionTrace.Add("partitioning:deferred-needs-more-info")
 		} else {
+			if !pe.Controller.IsDestructiveOperationAllowed(controller.CreatePartitionOperation, bd.DevPath) {
+				bd.DecisionTrace.Add("partitioning:not-allowed")
+				return nil
+			}
+			// partition tables that are not parsed are recognized by their signatures, so
+			// that the disk is not mistaken for a blank disk and overwritten.
+			if ok, err := pe.quarantineForeignPartitionScheme(bd, bdAPIList); err != nil || ok {
+				return err
+			}
+			// a protective MBR with an empty GPT is a blank disk that has only been initialized
+			// with a GPT. If the GPT has entries, the disk is not blank, even though the kernel
+			// has not created the device nodes for them.
+			layout, err := partition.GetProtectiveMBRLayout(bd.DevPath)
+			if err != nil {
+				klog.Errorf("error reading protective MBR of device: %s, %v", bd.DevPath, err)
+				return err
+			}
+			switch layout {
+			case partition.ProtectiveMBRWithPartitions:
+				klog.Infof("device: %s has a protective MBR with GPT partition entries without partition devices, "+
+					"refusing to overwrite the partition table", bd.DevPath)
+				bd.DecisionTrace.Add("partitioning:skipped-gpt-entries")
+				return nil
+			case partition.ProtectiveMBROnly:
+				klog.Infof("device: %s has a protective MBR with an empty GPT, treating it as blank", bd.DevPath)
+			}
+			// the partition table may have entries for which the kernel has not created
+			// the device nodes, the disk is not blank in that case.
+			if hasEntries, err := partition.HasGPTPartitionEntries(bd.DevPath); err != nil {
+				klog.Errorf("error reading partition table of device: %s, %v", bd.DevPath, err)
+				return err
+			} else if hasEntries {
+				klog.Infof("device: %s has GPT partition entries without partition devices, "+
+					"refusing to overwrite the partition table", bd.DevPath)
+				bd.DecisionTrace.Add("partitioning:skipped-gpt-entries")
+				return nil
+			}
+			if pe.discardBlankDisk(&bd) {
+				klog.Infof("device: %s is being discarded, partitioning is deferred till the discard is complete", bd.DevPath)
+				bd.DecisionTrace.Add("partitioning:deferred-discard")
+				return nil
+			}
+			pe.disableWriteCacheOfBlankDisk(&bd)
 			d := partition.Disk{
-				DevPath:          bd.DevPath,
-				DiskSize:         bd.Capacity.Storage,
-				LogicalBlockSize: uint64(bd.DeviceAttributes.LogicalBlockSize),
+				DevPath:           bd.DevPath,
+				DiskSize:          bd.Capacity.Storage,
+				LogicalBlockSize:  uint64(bd.DeviceAttributes.LogicalBlockSize),
+				PhysicalBlockSize: uint64(bd.DeviceAttributes.PhysicalBlockSize),
+				PartitionName:     pe.Controller.PartitionName,
 			}
 
 			if features.FeatureGates.IsEnabled(features.PartitionTableUUID) {
 				klog.Infof("starting to create partition table on device: %s", bd.DevPath)
-				if err := d.CreatePartitionTable(); err != nil {
+				err := d.CreatePartitionTable()
+				pe.Controller.Audit(controller.CreatePartitionOperation, getAuditTarget(bd), blankDiskAuditCause, err)
+				if err != nil {
 					klog.Errorf("error create partition table for %s, %v", bd.DevPath, err)
 					return err
 				}
 				klog.Infof("created new partition table in %s", bd.DevPath)
+				bd.DecisionTrace.Add("action:partition-table-created")
 				return ErrNeedRescan
 			} else {
-				klog.Infof("starting to create partition on device: %s", bd.DevPath)
-				if err := d.CreateSinglePartition(); err != nil {
-					klog.Errorf("error creating partition for %s, %v", bd.DevPath, err)
-					return err
-				}
-				klog.Infof("created new partition in %s", bd.DevPath)
-				return nil
+				return pe.partitionDisk(&bd, &d, bdAPIList)
 			}
 		}
 	} else {
 		bd.UUID = uuid
 		klog.V(4).Infof("uuid: %s has been generated for device: %s", uuid, bd.DevPath)
+		bd.DecisionTrace.Add("uuid-basis:" + basis)
+		pe.detectUUIDBasisChange(&bd, basis, bdAPIList)
 		// update cache after generating uuid
 		pe.addBlockDeviceToHierarchyCache(bd)
 		bdAPI, err := pe.Controller.GetBlockDevice(uuid)
@@ -151,21 +320,38 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 				2. The device had partitions and BlockDevice was not created
 			*/
 
+			// the partition NDM has just created on the parent is adopted, the
+			// parent need not be looked up and deactivated, as the partition was
+			// not created by a consumer of the parent.
+			if pe.isInFlightNDMPartition(bd) {
+				bd.DecisionTrace.Add("partition:adopted")
+				pe.checkPartitionBlank(&bd)
+				if quarantined, err := pe.quarantineUnexpectedSignature(bd, bdAPIList); quarantined {
+					return err
+				}
+				annotations := map[string]string{
+					internalUUIDSchemeAnnotation: gptUUIDScheme,
+				}
+				existingBlockDeviceResource := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, bd.UUID)
+				return pe.createOrUpdateWithAnnotation(annotations, bd, existingBlockDeviceResource)
+			}
+
 			if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
 				klog.V(4).Infof("device: %s is partition", bd.DevPath)
 				klog.V(4).Info("checking if device has a parent")
 				// check if device has a parent that is claimed
-				parentBD, ok := pe.Controller.BDHierarchy[bd.DependentDevices.Parent]
+				parentBD, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent)
 				if !ok {
 					klog.V(4).Infof("unable to find parent device for device: %s", bd.DevPath)
-					return fmt.Errorf("cannot get parent device for device: %s", bd.DevPath)
+					return fmt.Errorf("%w for device: %s", ErrParentNotFound, bd.DevPath)
 				}
 
 				klog.V(4).Infof("parent device: %s found for device: %s", parentBD.DevPath, bd.DevPath)
 				klog.V(4).Infof("checking if parent device can be uniquely identified")
-				parentUUID, parentOK := generateUUID(parentBD)
+				parentUUID, _, _, parentOK := pe.generateDeviceUUID(parentBD, bdAPIList)
 				if !parentOK {
 					klog.V(4).Infof("unable to generate UUID for parent device, may be a device without WWN")
+					bd.DecisionTrace.Add("parent-uuid-basis:none")
 					// cannot generate UUID for parent, may be a device without WWN
 					// used the new algorithm to create partitions
 					return pe.createBlockDeviceResourceIfNoHolders(bd, bdAPIList)
@@ -178,6 +364,7 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 				if errors.IsNotFound(err) {
 					// parent not present in etcd, may be device without wwn or had partitions/holders
 					klog.V(4).Infof("parent device: %s, uuid: %s not found in etcd", parentBD.DevPath, parentUUID)
+					bd.DecisionTrace.Add("parent-resource:not-found")
 					return pe.createBlockDeviceResourceIfNoHolders(bd, bdAPIList)
 				}
 
@@ -187,10 +374,11 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 					// get call failed
 				}
 
-				if parentBDAPI.Status.ClaimState != apis.BlockDeviceUnclaimed {
-					// device is in use, and the consumer is doing something
+				if pe.Controller.IsProtected(*parentBDAPI, controller.DeactivateParentBlockDeviceOperation) {
+					// device is in use or about to be, and the consumer is doing something
 					// do nothing
 					klog.V(4).Infof("parent device: %s is in use, device: %s can be ignored", parentBD.DevPath, bd.DevPath)
+					bd.DecisionTrace.Add("parent-resource:claimed")
 					return nil
 				} else {
 					// the consumer created some partitions on the disk.
@@ -198,7 +386,8 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 					// 1. deactivate parent
 					// 2. create resource for partition
 
-					pe.Controller.DeactivateBlockDevice(*parentBDAPI)
+					bd.DecisionTrace.Add("parent-resource:deactivated")
+					pe.deactivateParentBlockDevice(*parentBDAPI)
 					existingBlockDeviceResource := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, bd.UUID)
 					annotations := map[string]string{
 						internalUUIDSchemeAnnotation: gptUUIDScheme,
@@ -217,9 +406,26 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 			if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition &&
 				len(bd.DependentDevices.Partitions) > 0 {
 				klog.V(4).Infof("device: %s has partitions: %+v", bd.DevPath, bd.DependentDevices.Partitions)
+				if pe.showPartitionedParent(bd) {
+					return pe.createPartitionedParentBlockDevice(bd, bdAPIList)
+				}
+				bd.DecisionTrace.Add("resource:skipped-has-partitions")
 				return nil
 			}
 
+			// the device may already have a resource with a uuid generated from an
+			// inferior identifier, eg: if the WWN could not be read earlier.
+			if pe.Controller.ReidentifyDevices && len(bd.DependentDevices.Holders) == 0 {
+				if ok, err := pe.reidentifyBlockDevice(bd, basis, bdAPIList); err != nil {
+					klog.Errorf("re-identification of device: %s failed: %v", bd.DevPath, err)
+					return err
+				} else if ok {
+					bd.DecisionTrace.Add("action:reidentified")
+					return nil
+				}
+			}
+
+			pe.checkWritability(&bd, bdAPIList)
 			return pe.createBlockDeviceResourceIfNoHolders(bd, bdAPIList)
 		}
 
@@ -230,6 +436,7 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 
 		if bdAPI.Status.ClaimState != apis.BlockDeviceUnclaimed {
 			klog.V(4).Infof("device: %s is in use. update the details of the blockdevice", bd.DevPath)
+			bd.DecisionTrace.Add("resource:claimed")
 
 			annotation := map[string]string{
 				internalUUIDSchemeAnnotation: gptUUIDScheme,
@@ -243,11 +450,20 @@ func (pe *ProbeEvent) addBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis
 			return nil
 		}
 
+		// the resource of a partitioned disk is annotated with its partitions, so that
+		// the disk is not claimed while its partitions are in use
+		if pe.showPartitionedParent(bd) {
+			return pe.createPartitionedParentBlockDevice(bd, bdAPIList)
+		}
+
 		klog.V(4).Infof("creating resource for device: %s with uuid: %s", bd.DevPath, bd.UUID)
 		existingBlockDeviceResource := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, bd.UUID)
 		annotations := map[string]string{
 			internalUUIDSchemeAnnotation: gptUUIDScheme,
 		}
+		// a device wiped to return it to the pool keeps its resource, the wipe is
+		// recorded on the resource instead of treating it as a new blank device.
+		pe.tagWipedBlockDevice(bd, bdAPI, annotations)
 
 		err = pe.createOrUpdateWithAnnotation(annotations, bd, existingBlockDeviceResource)
 		if err != nil {
@@ -265,6 +481,7 @@ func (pe *ProbeEvent) createBlockDeviceResourceIfNoHolders(bd blockdevice.BlockD
 	if len(bd.DependentDevices.Holders) > 0 {
 		klog.V(4).Infof("device: %s has holder devices: %+v", bd.DevPath, bd.DependentDevices.Holders)
 		klog.V(4).Infof("skip creating BlockDevice resource")
+		bd.DecisionTrace.Add("resource:skipped-holders")
 		return nil
 	}
 
@@ -285,7 +502,7 @@ func (pe *ProbeEvent) createBlockDeviceResourceIfNoHolders(bd blockdevice.BlockD
 }
 
 // upgradeBD returns true if further processing required after upgrade
-// NOTE: only cstor and localPV will be upgraded. upgrade of local PV raw block is not supported
+// NOTE: only cstor and localPV (filesystem and raw block) will be upgraded.
 func (pe *ProbeEvent) upgradeBD(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
 	if !bd.DevUse.InUse {
 		// device not in use
@@ -301,6 +518,14 @@ func (pe *ProbeEvent) upgradeBD(bd blockdevice.BlockDevice, bdAPIList *apis.Bloc
 
 	}
 
+	if bd.DevUse.UsedBy == blockdevice.LocalPVBlock {
+		if ok, err := pe.upgradeDeviceInUseByLocalPVBlock(bd, bdAPIList); err != nil {
+			return false, err
+		} else {
+			return ok, nil
+		}
+	}
+
 	if bd.DevUse.UsedBy == blockdevice.CStor {
 		if ok, err := pe.upgradeDeviceInUseByCStor(bd, bdAPIList); err != nil {
 			return false, err
@@ -329,9 +554,148 @@ func (pe *ProbeEvent) handleUnmanagedDevices(bd blockdevice.BlockDevice, bdAPILi
 	} else if !ok {
 		return false, nil
 	}
+
+	// handle if the device has the metadata of a windows host
+	if !pe.deviceInUseByWindows(bd) {
+		return false, nil
+	}
+
+	// handle if the device is a member of an md array
+	if !pe.deviceInUseByMDRaid(bd) {
+		return false, nil
+	}
+
+	// handle if the device is owned by another CSI driver
+	if !pe.deviceInUseByCSIDriver(bd) {
+		return false, nil
+	}
+
+	// handle if the device is used as a VM datastore
+	if !pe.deviceInUseByVMDatastore(bd) {
+		return false, nil
+	}
+
+	// handle if the device backs the volumes of the local-path provisioner
+	if !pe.deviceInUseByLocalPath(bd) {
+		return false, nil
+	}
+
+	// handle if the device is used as a longhorn disk
+	if ok, err := pe.deviceInUseByLonghorn(bd, bdAPIList); err != nil {
+		return ok, err
+	} else if !ok {
+		return false, nil
+	}
+
+	// handle if the device is used as a glusterfs brick
+	if ok, err := pe.deviceInUseByGluster(bd, bdAPIList); err != nil {
+		return ok, err
+	} else if !ok {
+		return false, nil
+	}
+
+	// handle if the device is reserved by another host
+	if !pe.deviceReservedBySCSIReservation(bd) {
+		return false, nil
+	}
+
+	// handle if the device is in use as reported by the used-by plugins in the config
+	if ok, err := pe.deviceInUseByPlugin(bd, bdAPIList); err != nil {
+		return ok, err
+	} else if !ok {
+		return false, nil
+	}
 	return true, nil
 }
 
+// deviceReservedBySCSIReservation checks if a SCSI-3 persistent reservation is held on
+// the device and returns true if further processing of the event is required. Such devices
+// are actively used by a peer node, hence they are never partitioned or managed.
+func (pe *ProbeEvent) deviceReservedBySCSIReservation(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.SCSIReserved {
+		return true
+	}
+
+	klog.Infof("device: %s has a persistent reservation held by another host, %s. ignoring the event",
+		bd.DevPath, bd.DevUse.Reason)
+	return false
+}
+
+// deviceInUseByCSIDriver checks if the device is owned by one of the CSI drivers in the
+// config and returns true if further processing of the event is required. Such devices
+// are managed by the driver, hence they are never partitioned or managed.
+func (pe *ProbeEvent) deviceInUseByCSIDriver(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.CSIManaged {
+		return true
+	}
+
+	klog.Infof("device: %s is owned by %s. ignoring the event",
+		bd.DevPath, bd.DevUse.Reason)
+	return false
+}
+
+// deviceInUseByMDRaid checks if the device has the superblock of an md array and returns
+// true if further processing of the event is required. The array may be stopped, so the
+// device may not have any holders, but it is never partitioned or managed. The same
+// applies to a spare of the array, which is used when a member of the array fails.
+func (pe *ProbeEvent) deviceInUseByMDRaid(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse {
+		return true
+	}
+
+	switch bd.DevUse.UsedBy {
+	case blockdevice.MDRaidMember:
+		klog.Infof("device: %s is a member of an md array, %s. ignoring the event",
+			bd.DevPath, bd.DevUse.Reason)
+	case blockdevice.ArraySpare:
+		klog.Infof("device: %s is reserved as a %s. ignoring the event",
+			bd.DevPath, bd.DevUse.Reason)
+	default:
+		return true
+	}
+	return false
+}
+
+// deviceInUseByVMDatastore checks if the device is mounted as a VM datastore and returns
+// true if further processing of the event is required. The backing images of the VMs
+// are stored on such devices, hence they are never managed.
+func (pe *ProbeEvent) deviceInUseByVMDatastore(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.VMDatastore {
+		return true
+	}
+
+	klog.Infof("device: %s is used as a vm datastore: %s. ignoring the event",
+		bd.DevPath, bd.DevUse.Reason)
+	return false
+}
+
+// deviceInUseByLocalPath checks if the device is mounted at the base directory of the
+// local-path provisioner and returns true if further processing of the event is required.
+// The host directories used as volumes by the pods are on such devices, hence they are
+// never partitioned or managed.
+func (pe *ProbeEvent) deviceInUseByLocalPath(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.LocalPath {
+		return true
+	}
+
+	klog.Infof("device: %s is used by the local-path provisioner: %s. ignoring the event",
+		bd.DevPath, bd.DevUse.Reason)
+	return false
+}
+
+// deviceInUseByWindows checks if the device has the metadata of a windows host, like a
+// Storage Spaces pool or a ReFS filesystem, and returns true if further processing of the
+// event is required. Such devices are treated as foreign and are never partitioned.
+func (pe *ProbeEvent) deviceInUseByWindows(bd blockdevice.BlockDevice) bool {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.Windows {
+		return true
+	}
+
+	klog.Infof("device: %s has %s metadata of a windows host. ignoring the event",
+		bd.DevPath, bd.FSInfo.FileSystem)
+	return false
+}
+
 // deviceInUseByMayastor checks if the device is in use by mayastor and returns true if further processing of the event
 // is required
 func (pe *ProbeEvent) deviceInUseByMayastor(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
@@ -353,7 +717,7 @@ func (pe *ProbeEvent) deviceInUseByMayastor(bd blockdevice.BlockDevice, bdAPILis
 // will be added on to the resource
 func (pe *ProbeEvent) deviceInUseByZFSLocalPV(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
 	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
-		parentBD, ok := pe.Controller.BDHierarchy[bd.DependentDevices.Parent]
+		parentBD, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent)
 		if !ok {
 			klog.Errorf("unable to find parent device for %s", bd.DevPath)
 			return false, fmt.Errorf("error in getting parent device for %s from device hierarchy", bd.DevPath)
@@ -403,7 +767,7 @@ func (pe *ProbeEvent) deviceInUseByZFSLocalPV(bd blockdevice.BlockDevice, bdAPIL
 // upgradeDeviceInUseByCStor handles the upgrade if the device is used by cstor. returns true if further processing
 // is required
 func (pe *ProbeEvent) upgradeDeviceInUseByCStor(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
-	uuid, ok := generateUUID(bd)
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
 	if ok {
 		existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
 		if existingBD != nil {
@@ -413,7 +777,7 @@ func (pe *ProbeEvent) upgradeDeviceInUseByCStor(bd blockdevice.BlockDevice, bdAP
 			} else {
 				// should never reach this case
 				klog.Error("unreachable state")
-				return false, fmt.Errorf("unreachable state")
+				return false, ErrUnreachableState
 			}
 		}
 	}
@@ -452,14 +816,14 @@ func (pe *ProbeEvent) upgradeDeviceInUseByCStor(bd blockdevice.BlockDevice, bdAP
 	} else {
 		// should never reach this case.
 		klog.Error("unreachable state")
-		return false, fmt.Errorf("unreachable state")
+		return false, ErrUnreachableState
 	}
 }
 
 // upgradeDeviceInUseByLocalPV handles upgrade for devices in use by localPV. returns true if further processing required.
-// NOTE: localPV raw block upgrade is not supported
+// NOTE: localPV raw block upgrade is handled by upgradeDeviceInUseByLocalPVBlock
 func (pe *ProbeEvent) upgradeDeviceInUseByLocalPV(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
-	uuid, ok := generateUUID(bd)
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
 	if ok {
 		existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
 		if existingBD != nil {
@@ -469,7 +833,7 @@ func (pe *ProbeEvent) upgradeDeviceInUseByLocalPV(bd blockdevice.BlockDevice, bd
 			} else {
 				// should never reach this case
 				klog.Error("unreachable state")
-				return false, fmt.Errorf("unreachable state")
+				return false, ErrUnreachableState
 			}
 		}
 	}
@@ -478,8 +842,17 @@ func (pe *ProbeEvent) upgradeDeviceInUseByLocalPV(bd blockdevice.BlockDevice, bd
 	existingLegacyBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, legacyUUID)
 
 	// check if any blockdevice exist with the annotation, if yes, that will be used.
-	// This is to handle the case where device comes at the same path of an earlier device
-	if r := getExistingBDWithFsUuid(bd, bdAPIList); r != nil {
+	// This is to handle the case where device comes at the same path of an earlier device.
+	// The device is quarantined if it is a clone sharing the annotation with other devices,
+	// and the blockdevice of the device cannot be determined.
+	r, candidates := getExistingBDWithFsUuid(bd, bdAPIList, pe.Controller.NodeAttributes[controller.NodeNameKey])
+	if len(candidates) > 0 {
+		bd.DecisionTrace.Add("quarantine:duplicate-fsuuid")
+		reason := fmt.Sprintf("DuplicateFilesystemUUID: filesystem uuid %s is shared by the blockdevices %s",
+			bd.FSInfo.FileSystemUUID, strings.Join(candidates, ", "))
+		return false, pe.quarantineUnidentifiedBlockDevice(bd, reason, bdAPIList)
+	}
+	if r != nil {
 		existingLegacyBD = r
 	}
 
@@ -510,8 +883,45 @@ func (pe *ProbeEvent) upgradeDeviceInUseByLocalPV(bd blockdevice.BlockDevice, bd
 	} else {
 		// should never reach this case.
 		klog.Error("unreachable state")
-		return false, fmt.Errorf("unreachable state")
+		return false, ErrUnreachableState
+	}
+}
+
+// upgradeDeviceInUseByLocalPVBlock handles upgrade for devices in use as a raw block localPV.
+// returns true if further processing required.
+// A raw block device does not have a filesystem UUID, so the resource can only be
+// matched using the GPT or the legacy UUID. The device is never partitioned, since that
+// would destroy the data of the PV.
+func (pe *ProbeEvent) upgradeDeviceInUseByLocalPVBlock(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
+	if ok {
+		existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+		if existingBD != nil {
+			// device in use using gpt UUID
+			return true, nil
+		}
+	}
+
+	legacyUUID, _ := generateLegacyUUID(bd)
+	existingLegacyBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, legacyUUID)
+	if existingLegacyBD == nil && ok {
+		// no resource exists for the device, proceed with the gpt UUID
+		return true, nil
+	}
+
+	// either a resource exists with the legacy UUID, or the device cannot be
+	// uniquely identified. In both cases legacy UUID is used, as the device cannot be
+	// partitioned to generate a new UUID.
+	bd.UUID = legacyUUID
+	annotation := map[string]string{
+		internalUUIDSchemeAnnotation: legacyUUIDScheme,
 	}
+	if err := pe.createOrUpdateWithAnnotation(annotation, bd, existingLegacyBD); err != nil {
+		klog.Errorf("could not push localPV block device: %s (%s) to etcd", bd.UUID, bd.DevPath)
+		return false, err
+	}
+	klog.Infof("Pushed localPV block device: %s (%s) to etcd", bd.UUID, bd.DevPath)
+	return false, nil
 }
 
 // isParentDeviceInUse checks if the parent device of a given device is in use.
@@ -521,29 +931,106 @@ func (pe *ProbeEvent) isParentDeviceInUse(bd blockdevice.BlockDevice) (bool, err
 		return false, nil
 	}
 
-	parentBD, ok := pe.Controller.BDHierarchy[bd.DependentDevices.Parent]
+	parentBD, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent)
 	if !ok {
 		return false, fmt.Errorf("cannot find parent device of %s", bd.DevPath)
 	}
 
-	return parentBD.DevUse.InUse, nil
+	if parentBD.DevUse.InUse {
+		return true, nil
+	}
+
+	// the usage of the parent is trusted if it was probed recently, eg: the partitions
+	// of a disk are processed in the same batch as the disk
+	if age := deviceUsageClock().Sub(parentBD.DevUse.ProbedAt); age >= 0 && age < parentUsageFreshness {
+		klog.V(4).Infof("usage of parent device: %s probed %v ago, not probing again", parentBD.DevPath, age)
+		return false, nil
+	}
+
+	// the usage in the cache may be stale, eg: the parent was claimed by an engine that
+	// has just created the partitions. The signatures on the parent are read again, so
+	// that the partitions of an engine disk are not processed.
+	parentCopy := parentBD
+	parentCopy.Labels = make(map[string]string)
+	for k, v := range parentBD.Labels {
+		parentCopy.Labels[k] = v
+	}
+	probeDeviceUsage(pe.Controller, &parentCopy)
+	parentCopy.DevUse.ProbedAt = deviceUsageClock()
+	if parentCopy.DevUse.InUse {
+		klog.Infof("parent device: %s of device: %s found in use by: %s on reprobe",
+			parentBD.DevPath, bd.DevPath, parentCopy.DevUse.UsedBy)
+	}
+	// the fresh usage is updated in the cache, so that the other partitions of the
+	// parent are processed based on it
+	pe.Controller.BDHierarchy.Set(parentBD.DevPath, parentCopy)
+	return parentCopy.DevUse.InUse, nil
+}
+
+// deactivateParentBlockDevice deactivates the resource of the parent of a partition.
+// The parent is deactivated only once in a batch, even if several of its partitions
+// are processed in the batch, to avoid redundant writes to etcd.
+func (pe *ProbeEvent) deactivateParentBlockDevice(parentBDAPI apis.BlockDevice) {
+	if pe.deactivatedParents != nil {
+		if _, ok := pe.deactivatedParents[parentBDAPI.Name]; ok {
+			klog.V(4).Infof("parent device: %s already deactivated in this batch", parentBDAPI.Name)
+			return
+		}
+		pe.deactivatedParents[parentBDAPI.Name] = struct{}{}
+	}
+	pe.Controller.DeactivateBlockDevice(parentBDAPI, controller.AuditCause{
+		Trigger: controller.AuditTriggerDeviceAdded,
+		Reason:  "partitions created on the disk by a consumer",
+	})
 }
 
-// getExistingBDWithFsUuid returns the blockdevice with matching FSUUID annotation from etcd
-func getExistingBDWithFsUuid(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) *apis.BlockDevice {
+// probeDeviceUsage fills the usage of the device by running the used-by probe on it. It is
+// a variable, so that it can be replaced in tests
+var probeDeviceUsage = func(ctrl *controller.Controller, bd *blockdevice.BlockDevice) {
+	(&usedbyProbe{Controller: ctrl}).FillBlockDeviceDetails(bd)
+}
+
+// getExistingBDWithFsUuid returns the blockdevice on this node with matching FSUUID annotation
+// from etcd. Cloned disks share the FSUUID, so the blockdevices of the disks on the other nodes
+// are never used, and if there are more than one matching blockdevices on this node, the one at
+// the path of the device is preferred. If the blockdevice still cannot be determined, the names
+// of the matching blockdevices are returned instead.
+func getExistingBDWithFsUuid(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList, nodeName string) (*apis.BlockDevice, []string) {
 	if len(bd.FSInfo.FileSystemUUID) == 0 {
-		return nil
+		return nil, nil
 	}
-	for _, bdAPI := range bdAPIList.Items {
-		fsUUID, ok := bdAPI.Annotations[internalFSUUIDAnnotation]
-		if !ok {
+	matches := make([]*apis.BlockDevice, 0)
+	for i := range bdAPIList.Items {
+		if bdAPIList.Items[i].Spec.NodeAttributes.NodeName != nodeName {
 			continue
 		}
-		if fsUUID == bd.FSInfo.FileSystemUUID {
-			return &bdAPI
+		fsUUID, ok := bdAPIList.Items[i].Annotations[internalFSUUIDAnnotation]
+		if ok && fsUUID == bd.FSInfo.FileSystemUUID {
+			matches = append(matches, &bdAPIList.Items[i])
 		}
 	}
-	return nil
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0], nil
+	}
+
+	atPath := make([]*apis.BlockDevice, 0)
+	for _, bdAPI := range matches {
+		if bdAPI.Spec.Path == bd.DevPath {
+			atPath = append(atPath, bdAPI)
+		}
+	}
+	if len(atPath) == 1 {
+		return atPath[0], nil
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, bdAPI := range matches {
+		names = append(names, bdAPI.Name)
+	}
+	return nil, names
 }
 
 // getExistingBDWithPartitionUUID returns the blockdevice with matching partition uuid annotation from etcd
@@ -579,6 +1066,54 @@ func (pe *ProbeEvent) createOrUpdateWithFSUUID(bd blockdevice.BlockDevice, exist
 	return nil
 }
 
+// createOrUpdateWithPinnedUUID creates/updates the resource with the UUID pinned to the device. An
+// annotation is added to note that the UUID was manually pinned. An error is returned if the pinned
+// UUID is already used by a different device.
+func (pe *ProbeEvent) createOrUpdateWithPinnedUUID(bd blockdevice.BlockDevice, pinnedUUID string, bdAPIList *apis.BlockDeviceList) error {
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, pinnedUUID)
+	if existingBD != nil && !isSamePinnedDevice(bd, *existingBD) {
+		klog.Errorf("pinned uuid: %s of device: %s is in use by device with serial: %s, wwn: %s",
+			pinnedUUID, bd.DevPath, existingBD.Spec.Details.Serial, controller.GetBlockDeviceWWN(*existingBD))
+		return fmt.Errorf("pinned uuid: %s already in use by a different device", pinnedUUID)
+	}
+	for devPath, cachedBD := range pe.Controller.BDHierarchy.Snapshot() {
+		if devPath != bd.DevPath && cachedBD.UUID == pinnedUUID {
+			klog.Errorf("pinned uuid: %s of device: %s is in use by device: %s",
+				pinnedUUID, bd.DevPath, devPath)
+			return fmt.Errorf("pinned uuid: %s already in use by device: %s", pinnedUUID, devPath)
+		}
+	}
+
+	bd.UUID = pinnedUUID
+	// update cache with the pinned uuid
+	pe.addBlockDeviceToHierarchyCache(bd)
+	annotation := map[string]string{
+		internalUUIDSchemeAnnotation: pinnedUUIDScheme,
+	}
+	if err := pe.createOrUpdateWithAnnotation(annotation, bd, existingBD); err != nil {
+		klog.Errorf("could not push device: %s (%s) with pinned uuid to etcd", bd.UUID, bd.DevPath)
+		return err
+	}
+	klog.Infof("Pushed device: %s (%s) with pinned uuid to etcd", bd.UUID, bd.DevPath)
+	return nil
+}
+
+// isSamePinnedDevice checks if the existing resource having the pinned UUID is that of the
+// device. The serial and the WWN are compared, when known for both of them. The WWN of the
+// resource is taken from the by-id link, which has the vendor extension appended to the
+// WWN of the device, if any.
+func isSamePinnedDevice(bd blockdevice.BlockDevice, existingBD apis.BlockDevice) bool {
+	serial := existingBD.Spec.Details.Serial
+	if serial != "" && bd.DeviceAttributes.Serial != "" && serial != bd.DeviceAttributes.Serial {
+		return false
+	}
+	wwn := controller.GetBlockDeviceWWN(existingBD)
+	if wwn != "" && bd.DeviceAttributes.WWN != "" && !strings.HasPrefix(wwn, bd.DeviceAttributes.WWN) {
+		return false
+	}
+	return true
+}
+
 // createOrUpdateWithPartitionUUID create/update a resource in etcd. It additionally adds an annotation with the
 // partition table uuid of the blockdevice
 func (pe *ProbeEvent) createOrUpdateWithPartitionUUID(bd blockdevice.BlockDevice, existingBD *apis.BlockDevice) error {
@@ -595,18 +1130,71 @@ func (pe *ProbeEvent) createOrUpdateWithPartitionUUID(bd blockdevice.BlockDevice
 	return nil
 }
 
+// createOrUpdateInDiscoverOnlyMode creates/updates the resource of a blank disk that cannot be
+// uniquely identified, instead of partitioning it. The legacy uuid is used, as it does not
+// depend on the partition table. If it has to use the path of the device, the resource is
+// annotated as needing an identifier, since the uuid changes if the path changes.
+func (pe *ProbeEvent) createOrUpdateInDiscoverOnlyMode(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) error {
+	uuid, uuidUsesPath := generateLegacyUUID(bd)
+	bd.UUID = uuid
+	pe.addBlockDeviceToHierarchyCache(bd)
+	annotation := map[string]string{
+		internalUUIDSchemeAnnotation: legacyUUIDScheme,
+	}
+	if uuidUsesPath {
+		annotation[internalNeedsIdentifierAnnotation] = controller.TrueString
+	}
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+	if err := pe.createOrUpdateWithAnnotation(annotation, bd, existingBD); err != nil {
+		klog.Errorf("could not push device: %s (%s) to etcd", bd.UUID, bd.DevPath)
+		return err
+	}
+	klog.Infof("Pushed device: %s (%s) in discover only mode to etcd", bd.UUID, bd.DevPath)
+	return nil
+}
+
 // createOrUpdateWithAnnotation creates or updates a resource in etcd with given annotation.
 func (pe *ProbeEvent) createOrUpdateWithAnnotation(annotation map[string]string, bd blockdevice.BlockDevice, existingBD *apis.BlockDevice) error {
+	if existingBD != nil {
+		bd.DecisionTrace.Add("action:resource-updated")
+	} else {
+		bd.DecisionTrace.Add("action:resource-created")
+	}
 	deviceInfo := pe.Controller.NewDeviceInfoFromBlockDevice(&bd)
 	bdAPI, err := deviceInfo.ToDevice(pe.Controller)
 	if err != nil {
 		klog.Error("Failed to create a block device resource CR, Error: ", err)
 		return err
 	}
-	bdAPI.Annotations = annotation
+	if bdAPI.Annotations == nil {
+		bdAPI.Annotations = make(map[string]string, len(annotation)+2)
+	}
+	for key, value := range annotation {
+		bdAPI.Annotations[key] = value
+	}
+	// record the identifier from which the uuid was generated and the version of
+	// the uuid algorithm, so that it can be found later why the device got its uuid.
+	// Legacy uuids do not have a basis. The namespace of v5 uuids is also recorded.
+	for _, version := range pe.uuidVersions() {
+		if uuid, basis, ok := pe.generateUUIDOfVersion(bd, version); ok && uuid == bd.UUID {
+			bdAPI.Annotations[pe.uuidBasisAnnotation()] = basis
+			bdAPI.Annotations[internalUUIDVersionAnnotation] = string(version)
+			if version == controller.UUIDVersionV5 {
+				bdAPI.Annotations[internalUUIDNamespaceAnnotation] = pe.Controller.UUIDNamespace.String()
+			}
+			break
+		}
+	}
+	if pe.Controller.ContentFingerprintKiB > 0 {
+		pe.setContentFingerprint(bd, existingBD, bdAPI.Annotations)
+	}
+	setSeenTimestamps(existingBD, bdAPI.Annotations)
 
+	// the update is skipped if the resource is unchanged, in which case only the claim
+	// state transition is published
+	updated := false
 	if existingBD != nil {
-		err = pe.Controller.UpdateBlockDevice(bdAPI, existingBD)
+		updated, err = pe.Controller.UpdateBlockDeviceIfChanged(bdAPI, existingBD)
 	} else {
 		err = pe.Controller.CreateBlockDevice(bdAPI)
 	}
@@ -614,5 +1202,51 @@ func (pe *ProbeEvent) createOrUpdateWithAnnotation(annotation map[string]string,
 		klog.Errorf("unable to push %s (%s) to etcd", bd.UUID, bd.DevPath)
 		return err
 	}
+
+	if existingBD == nil {
+		pe.Controller.PublishInventoryEvent(controller.InventoryEventCreated, bdAPI)
+		return nil
+	}
+	// the claim state is owned by the operator and is retained on update
+	bdAPI.Status.ClaimState = existingBD.Status.ClaimState
+	if updated {
+		pe.Controller.PublishInventoryEvent(controller.InventoryEventUpdated, bdAPI)
+	} else {
+		pe.Controller.PublishClaimStateTransition(bdAPI)
+	}
 	return nil
 }
+
+// logDecisionTrace logs the decision trace of the device, if it is enabled
+func logDecisionTrace(bd blockdevice.BlockDevice) {
+	if bd.DecisionTrace == nil {
+		return
+	}
+	klog.Infof("eventcode=%s msg=%s trace=%q rname=%v",
+		"ndm.blockdevice.decision.trace", "Decision trace of device",
+		bd.DecisionTrace.String(), bd.DevPath)
+}
+
+// getRangeCapacity gets the capacity of the device to be checked against the capacity
+// range. Partitions are checked using the capacity of their parent disk, so that all the
+// partitions of a disk in the range are managed, irrespective of their own size.
+func (pe *ProbeEvent) getRangeCapacity(bd blockdevice.BlockDevice) uint64 {
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		if parent, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent); ok {
+			return parent.Capacity.Storage
+		}
+	}
+	return bd.Capacity.Storage
+}
+
+// getIdentityDevice gets the device whose stable identifiers identify the given device.
+// Partitions are identified by their parent disk, so that all the partitions of a listed
+// disk are managed.
+func (pe *ProbeEvent) getIdentityDevice(bd blockdevice.BlockDevice) blockdevice.BlockDevice {
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		if parent, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent); ok {
+			return parent
+		}
+	}
+	return bd
+}
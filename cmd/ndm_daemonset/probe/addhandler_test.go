@@ -18,15 +18,26 @@ package probe
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/partition/gpt"
 
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/db/kubernetes"
+	"github.com/openebs/node-disk-manager/pkg/partition"
 	"github.com/openebs/node-disk-manager/pkg/util"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -112,11 +123,11 @@ func TestAddBlockDeviceToHierarchyCache(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			pe := &ProbeEvent{
 				Controller: &controller.Controller{
-					BDHierarchy: tt.cache,
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.cache),
 				},
 			}
 			gotOk := pe.addBlockDeviceToHierarchyCache(tt.bd)
-			assert.Equal(t, tt.wantCache, pe.Controller.BDHierarchy)
+			assert.Equal(t, tt.wantCache, pe.Controller.BDHierarchy.Snapshot())
 			assert.Equal(t, tt.wantOk, gotOk)
 		})
 	}
@@ -376,7 +387,7 @@ func TestDeviceInUseByZFSLocalPV(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -464,12 +475,37 @@ func TestIsParentDeviceInUse(t *testing.T) {
 				InUse: true,
 			},
 		},
+		// the parent disk has the signature of an engine, but the cached usage is stale
+		"/dev/sdd": {
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sdd",
+			},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Partitions: []string{"/dev/sdd1"},
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+			},
+			DevUse: blockdevice.DeviceUsage{
+				InUse: false,
+			},
+		},
 	}
 	pe := &ProbeEvent{
 		Controller: &controller.Controller{
-			BDHierarchy: cache,
+			BDHierarchy: blockdevice.NewHierarchyCache(cache),
 		},
 	}
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, bd *blockdevice.BlockDevice) {
+		if bd.DevPath == "/dev/sdd" {
+			bd.DevUse = blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.CStor,
+			}
+		}
+	}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
 	tests := map[string]struct {
 		bd      blockdevice.BlockDevice
 		want    bool
@@ -517,6 +553,21 @@ func TestIsParentDeviceInUse(t *testing.T) {
 			want:    true,
 			wantErr: false,
 		},
+		"parent device with stale usage in the cache": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/sdd1",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Parent: "/dev/sdd",
+				},
+			},
+			want:    true,
+			wantErr: false,
+		},
 		"non existent parent device": {
 			bd: blockdevice.BlockDevice{
 				Identifier: blockdevice.Identifier{
@@ -540,16 +591,138 @@ func TestIsParentDeviceInUse(t *testing.T) {
 			assert.Equal(t, tt.wantErr, gotErr != nil)
 		})
 	}
+	// the usage found on reprobe is updated in the cache
+	cachedBD, _ := pe.Controller.BDHierarchy.Get("/dev/sdd")
+	assert.True(t, cachedBD.DevUse.InUse)
+}
+
+func TestIsParentDeviceInUseWithStaleCache(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	oldDeviceUsageClock := deviceUsageClock
+	deviceUsageClock = func() time.Time { return now }
+	defer func() { deviceUsageClock = oldDeviceUsageClock }()
+
+	// the parent is now in use, but the cache has the usage from an older probe
+	reprobes := 0
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, bd *blockdevice.BlockDevice) {
+		reprobes++
+		bd.DevUse = blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.CStor,
+		}
+	}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+
+	newPartition := func(devPath string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypePartition,
+			},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Parent: "/dev/sdb",
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		probedAt     time.Time
+		want         bool
+		wantReprobes int
+	}{
+		"usage probed recently": {
+			probedAt:     now.Add(-2 * time.Second),
+			want:         false,
+			wantReprobes: 0,
+		},
+		"usage probed before the freshness threshold": {
+			probedAt:     now.Add(-time.Minute),
+			want:         true,
+			wantReprobes: 1,
+		},
+		"usage never probed": {
+			want:         true,
+			wantReprobes: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reprobes = 0
+			parent := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/sdb",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:    false,
+					ProbedAt: tt.probedAt,
+				},
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{parent.DevPath: parent}),
+				},
+			}
+
+			// the other partitions of the parent use the usage found on the first reprobe
+			for _, devPath := range []string{"/dev/sdb1", "/dev/sdb2"} {
+				got, err := pe.isParentDeviceInUse(newPartition(devPath))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got, devPath)
+			}
+			assert.Equal(t, tt.wantReprobes, reprobes)
+
+			cachedBD, _ := pe.Controller.BDHierarchy.Get(parent.DevPath)
+			assert.Equal(t, tt.want, cachedBD.DevUse.InUse)
+			if tt.wantReprobes > 0 {
+				assert.Equal(t, now, cachedBD.DevUse.ProbedAt)
+			}
+		})
+	}
 }
 
 func TestGetExistingBDWithFsUuid(t *testing.T) {
 
 	fakeFSUUID := "fake-fs-uuid"
 
+	// newBDAPI creates a resource with the fs uuid annotation on the node at the path
+	newBDAPI := func(name, nodeName, path string) apis.BlockDevice {
+		return apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					internalUUIDSchemeAnnotation: legacyUUIDScheme,
+					internalFSUUIDAnnotation:     fakeFSUUID,
+				},
+			},
+			Spec: apis.DeviceSpec{
+				Path:           path,
+				NodeAttributes: apis.NodeAttribute{NodeName: nodeName},
+			},
+		}
+	}
+	clonedDisk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			FileSystemUUID: fakeFSUUID,
+		},
+	}
+	bdOnNode1 := newBDAPI("blockdevice-123", "node1", "/dev/sdb")
+	bdOnNode1AtSdc := newBDAPI("blockdevice-456", "node1", "/dev/sdc")
+	bdOnNode2 := newBDAPI("blockdevice-789", "node2", "/dev/sdb")
+
 	tests := map[string]struct {
-		bd        blockdevice.BlockDevice
-		bdAPIList *apis.BlockDeviceList
-		want      *apis.BlockDevice
+		bd             blockdevice.BlockDevice
+		bdAPIList      *apis.BlockDeviceList
+		want           *apis.BlockDevice
+		wantCandidates []string
 	}{
 		"bd does not have a filesystem": {
 			bd: blockdevice.BlockDevice{},
@@ -571,27 +744,16 @@ func TestGetExistingBDWithFsUuid(t *testing.T) {
 				},
 			},
 			bdAPIList: &apis.BlockDeviceList{
-				Items: []apis.BlockDevice{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "blockdevice-123",
-							Annotations: map[string]string{
-								internalUUIDSchemeAnnotation: legacyUUIDScheme,
-								internalFSUUIDAnnotation:     fakeFSUUID,
-							},
-						},
-					},
-				},
+				Items: []apis.BlockDevice{bdOnNode1},
 			},
-			want: &apis.BlockDevice{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "blockdevice-123",
-					Annotations: map[string]string{
-						internalUUIDSchemeAnnotation: legacyUUIDScheme,
-						internalFSUUIDAnnotation:     fakeFSUUID,
-					},
-				},
+			want: &bdOnNode1,
+		},
+		"bd with fs uuid exists only on another node": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode2},
 			},
+			want: nil,
 		},
 		"bd with fs uuid does not exists": {
 			bd: blockdevice.BlockDevice{
@@ -614,11 +776,48 @@ func TestGetExistingBDWithFsUuid(t *testing.T) {
 			},
 			want: nil,
 		},
+		"cloned disks on different nodes, resource on this node is used": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode2, bdOnNode1},
+			},
+			want: &bdOnNode1,
+		},
+		"cloned disks on this node, resource at the path of the device is used": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode1AtSdc, bdOnNode2, bdOnNode1},
+			},
+			want: &bdOnNode1,
+		},
+		"cloned disks only on other nodes are not used": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode2, newBDAPI("blockdevice-abc", "node3", "/dev/sdb")},
+			},
+			want: nil,
+		},
+		"cloned disk on this node and on other nodes, resource on this node is used": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode2, bdOnNode1AtSdc, newBDAPI("blockdevice-abc", "node3", "/dev/sdb")},
+			},
+			want: &bdOnNode1AtSdc,
+		},
+		"cloned disks on this node at other paths are ambiguous": {
+			bd: clonedDisk,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{bdOnNode1AtSdc, newBDAPI("blockdevice-def", "node1", "/dev/sdd")},
+			},
+			want:           nil,
+			wantCandidates: []string{"blockdevice-456", "blockdevice-def"},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := getExistingBDWithFsUuid(tt.bd, tt.bdAPIList)
+			got, candidates := getExistingBDWithFsUuid(tt.bd, tt.bdAPIList, "node1")
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCandidates, candidates)
 		})
 	}
 }
@@ -712,6 +911,41 @@ func TestHandleUnmanagedDevices(t *testing.T) {
 	}
 
 	fakeUUID, _ := generateUUIDFromPartitionTable(fakeBD)
+	longhornBD := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdc",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d5",
+			Serial:     "ZA1B2C3E",
+		},
+		DevUse: blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.Longhorn,
+			Reason: "/var/lib/longhorn (longhorn disk: /var/lib/longhorn)",
+		},
+	}
+	longhornUUID, _, _ := generateUUID(longhornBD)
+	glusterBD := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdd",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d6",
+			Serial:     "ZA1B2C3F",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			MountPoint: []string{"/var/lib/heketi/mounts/vg_1/brick_1"},
+		},
+		DevUse: blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.Gluster,
+			Reason: "/var/lib/heketi/mounts/vg_1/brick_1 (glusterfs brick: /var/lib/heketi/mounts/vg_1/brick_1, volume-id: )",
+		},
+	}
+	glusterUUID, _, _ := generateUUID(glusterBD)
 	tests := map[string]struct {
 		bd                     blockdevice.BlockDevice
 		bdAPIList              *apis.BlockDeviceList
@@ -761,6 +995,143 @@ func TestHandleUnmanagedDevices(t *testing.T) {
 			want:                   false,
 			wantErr:                false,
 		},
+		"device with the metadata of a windows host": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.Windows,
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+				FSInfo: blockdevice.FileSystemInformation{
+					FileSystem: blockdevice.FileSystemStorageSpaces,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device used as a vm datastore": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.VMDatastore,
+					Reason: "/var/lib/libvirt/images (vm datastore: /var/lib/libvirt/images)",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device owned by another csi driver": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.CSIManaged,
+					Reason: "csi driver: topolvm.io",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device that is a member of a stopped md array": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.MDRaidMember,
+					Reason: "md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device reserved as a spare of an md array": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.ArraySpare,
+					Reason: "spare for md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device used by the local-path provisioner": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.LocalPath,
+					Reason: "/opt/local-path-provisioner (local-path: /opt/local-path-provisioner)",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
+		"device used as a longhorn disk": {
+			bd:                     longhornBD,
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                blockdevice.Hierarchy{},
+			createdOrUpdatedBDName: longhornUUID,
+			want:                   false,
+			wantErr:                false,
+		},
+		"device used as a glusterfs brick": {
+			bd:                     glusterBD,
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                blockdevice.Hierarchy{},
+			createdOrUpdatedBDName: glusterUUID,
+			want:                   false,
+			wantErr:                false,
+		},
+		"device reserved by another host": {
+			bd: blockdevice.BlockDevice{
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.SCSIReserved,
+					Reason: "reservation key: 0x00000000abcd1234, type: 0x3",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			bdAPIList:              &apis.BlockDeviceList{},
+			bdCache:                nil,
+			createdOrUpdatedBDName: "",
+			want:                   false,
+			wantErr:                false,
+		},
 		"device in use, not by zfs localPV": {
 			bd: blockdevice.BlockDevice{
 				DevUse: blockdevice.DeviceUsage{
@@ -933,7 +1304,7 @@ func TestHandleUnmanagedDevices(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -1092,7 +1463,7 @@ func TestUpgradeDeviceInUseByCStor(t *testing.T) {
 	fakePartitionEntry := "fake-part-entry-1"
 	fakePartTable := "fake-part-table"
 
-	gptUuidForPhysicalDevice, _ := generateUUID(physicalBlockDevice)
+	gptUuidForPhysicalDevice, _, _ := generateUUID(physicalBlockDevice)
 	gptUuidForPhysicalDevicePartition := blockdevice.BlockDevicePrefix + util.Hash(fakePartitionEntry)
 	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
 	legacyUuidForVirtualDevice, _ := generateLegacyUUID(virtualBlockDevice)
@@ -1457,7 +1828,7 @@ func TestUpgradeDeviceInUseByCStor(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -1516,7 +1887,7 @@ func TestUpgradeDeviceInUseByLocalPV(t *testing.T) {
 	fakePartitionEntry := "fake-part-entry-1"
 	fakefsUuid := "fake-fs-uuid"
 
-	gptUuidForPhysicalDevice, _ := generateUUID(physicalBlockDevice)
+	gptUuidForPhysicalDevice, _, _ := generateUUID(physicalBlockDevice)
 	gptUuidForPhysicalDevicePartition := blockdevice.BlockDevicePrefix + util.Hash(fakePartitionEntry)
 	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
 	legacyUuidForVirtualDevice, _ := generateLegacyUUID(virtualBlockDevice)
@@ -1881,7 +2252,7 @@ func TestUpgradeDeviceInUseByLocalPV(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -1913,31 +2284,273 @@ func TestUpgradeDeviceInUseByLocalPV(t *testing.T) {
 	}
 }
 
-func TestUpgradeBD(t *testing.T) {
-	physicalBlockDevice := blockdevice.BlockDevice{
+func TestUpgradeDeviceInUseByLocalPVWithClonedDisks(t *testing.T) {
+	fakefsUuid := "fake-fs-uuid"
+	bd := blockdevice.BlockDevice{
 		Identifier: blockdevice.Identifier{
-			DevPath: "/dev/sda",
+			DevPath: "/dev/sdb",
 		},
 		DeviceAttributes: blockdevice.DeviceAttribute{
 			WWN:        fakeWWN,
 			Serial:     fakeSerial,
 			Model:      "SanDiskSSD",
 			DeviceType: blockdevice.BlockDeviceTypeDisk,
-			IDType:     blockdevice.BlockDeviceTypeDisk,
 		},
+		FSInfo: blockdevice.FileSystemInformation{
+			FileSystemUUID: fakefsUuid,
+		},
+		NodeAttributes: blockdevice.NodeAttribute{blockdevice.NodeName: "node1"},
 	}
+	legacyUUID, _ := generateLegacyUUID(bd)
 
-	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
+	// newBDAPI creates a claimed resource of a clone of the disk on the node
+	newBDAPI := func(name, nodeName string) apis.BlockDevice {
+		return apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					internalFSUUIDAnnotation:     fakefsUuid,
+					internalUUIDSchemeAnnotation: legacyUUIDScheme,
+				},
+				Labels: make(map[string]string),
+			},
+			Spec: apis.DeviceSpec{
+				Path:           "/dev/sdb",
+				NodeAttributes: apis.NodeAttribute{NodeName: nodeName},
+			},
+			Status: apis.DeviceStatus{
+				ClaimState: apis.BlockDeviceClaimed,
+			},
+		}
+	}
 
 	tests := map[string]struct {
-		bd                     blockdevice.BlockDevice
-		bdAPIList              *apis.BlockDeviceList
-		bdCache                blockdevice.Hierarchy
-		createdOrUpdatedBDName string
-		want                   bool
-		wantErr                bool
+		bdAPIList      *apis.BlockDeviceList
+		wantBDName     string
+		wantQuarantine bool
 	}{
-		"device not in use": {
+		"resource of the clone on this node is updated": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{newBDAPI("blockdevice-789", "node2"), newBDAPI("blockdevice-123", "node1")},
+			},
+			wantBDName:     "blockdevice-123",
+			wantQuarantine: false,
+		},
+		"new resource is created if the clones are on other nodes": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{newBDAPI("blockdevice-789", "node2"), newBDAPI("blockdevice-abc", "node3")},
+			},
+			wantBDName:     legacyUUID,
+			wantQuarantine: false,
+		},
+		"device is quarantined if the clones on this node are at other paths": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{newBDAPI("blockdevice-789", "node2"), newBDAPI("blockdevice-abc", "node1"),
+					newBDAPI("blockdevice-def", "node1")},
+			},
+			wantBDName:     legacyUUID,
+			wantQuarantine: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			for _, bdAPI := range tt.bdAPIList.Items {
+				assert.NoError(t, cl.Create(context.TODO(), &bdAPI))
+			}
+			assert.NoError(t, cl.List(context.TODO(), tt.bdAPIList))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:      cl,
+					BDHierarchy:    blockdevice.NewHierarchyCache(nil),
+					NodeAttributes: map[string]string{controller.NodeNameKey: "node1"},
+				},
+			}
+			got, err := pe.upgradeDeviceInUseByLocalPV(bd, tt.bdAPIList)
+			assert.NoError(t, err)
+			assert.False(t, got)
+
+			gotBDAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: tt.wantBDName}, gotBDAPI))
+			assert.Equal(t, bd.DevPath, gotBDAPI.Spec.Path)
+			assert.Equal(t, "node1", gotBDAPI.Spec.NodeAttributes.NodeName)
+			if tt.wantQuarantine {
+				assert.Equal(t, apis.BlockDeviceQuarantined, gotBDAPI.Status.State)
+				assert.Equal(t, "DuplicateFilesystemUUID: filesystem uuid fake-fs-uuid is shared by the blockdevices blockdevice-abc, blockdevice-def",
+					gotBDAPI.Annotations[controller.QuarantineReasonAnnotation])
+			} else {
+				assert.NotEqual(t, apis.BlockDeviceQuarantined, gotBDAPI.Status.State)
+				assert.Equal(t, fakefsUuid, gotBDAPI.Annotations[internalFSUUIDAnnotation])
+			}
+			// the resources of the clones on the other nodes are not modified
+			other := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "blockdevice-789"}, other))
+			assert.Equal(t, "node2", other.Spec.NodeAttributes.NodeName)
+			assert.Equal(t, apis.BlockDeviceClaimed, other.Status.ClaimState)
+		})
+	}
+}
+
+func TestUpgradeDeviceInUseByLocalPVBlock(t *testing.T) {
+	physicalBlockDevice := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			WWN:        fakeWWN,
+			Serial:     fakeSerial,
+			Model:      "SanDiskSSD",
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			IDType:     blockdevice.BlockDeviceTypeDisk,
+		},
+		DevUse: blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.LocalPVBlock,
+		},
+	}
+
+	virtualBlockDevice := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			Model:      "Virtual_disk",
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+		DevUse: blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.LocalPVBlock,
+		},
+	}
+
+	gptUuidForPhysicalDevice, _, _ := generateUUID(physicalBlockDevice)
+	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
+	legacyUuidForVirtualDevice, _ := generateLegacyUUID(virtualBlockDevice)
+
+	tests := map[string]struct {
+		bd                     blockdevice.BlockDevice
+		bdAPIList              *apis.BlockDeviceList
+		createdOrUpdatedBDName string
+		want                   bool
+	}{
+		"deviceType: disk, using gpt based algorithm": {
+			bd: physicalBlockDevice,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: gptUuidForPhysicalDevice,
+						},
+						Status: apis.DeviceStatus{
+							ClaimState: apis.BlockDeviceClaimed,
+						},
+					},
+				},
+			},
+			createdOrUpdatedBDName: "",
+			want:                   true,
+		},
+		"deviceType: disk, using legacy algorithm": {
+			bd: physicalBlockDevice,
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: legacyUuidForPhysicalDevice,
+						},
+						Status: apis.DeviceStatus{
+							ClaimState: apis.BlockDeviceClaimed,
+						},
+					},
+				},
+			},
+			createdOrUpdatedBDName: legacyUuidForPhysicalDevice,
+			want:                   false,
+		},
+		"deviceType: disk, no resource exists": {
+			bd:                     physicalBlockDevice,
+			bdAPIList:              &apis.BlockDeviceList{},
+			createdOrUpdatedBDName: "",
+			want:                   true,
+		},
+		"deviceType: disk without WWN, no resource exists": {
+			bd:                     virtualBlockDevice,
+			bdAPIList:              &apis.BlockDeviceList{},
+			createdOrUpdatedBDName: legacyUuidForVirtualDevice,
+			want:                   false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			// initialize client with all the bd resources
+			for _, bdAPI := range tt.bdAPIList.Items {
+				cl.Create(context.TODO(), &bdAPI)
+			}
+
+			err := cl.List(context.TODO(), tt.bdAPIList)
+			if err != nil {
+				t.Errorf("error updating the resource API List %v", err)
+			}
+
+			ctrl := &controller.Controller{
+				Clientset:   cl,
+				BDHierarchy: blockdevice.NewHierarchyCache(nil),
+			}
+			pe := &ProbeEvent{
+				Controller: ctrl,
+			}
+			got, err := pe.upgradeDeviceInUseByLocalPVBlock(tt.bd, tt.bdAPIList)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// check if a BD has been created or updated
+			if len(tt.createdOrUpdatedBDName) != 0 {
+				gotBDAPI := &apis.BlockDevice{}
+				err := cl.Get(context.TODO(), client.ObjectKey{Name: tt.createdOrUpdatedBDName}, gotBDAPI)
+				if err != nil {
+					t.Errorf("error in getting blockdevice %s: %v", tt.createdOrUpdatedBDName, err)
+				}
+				assert.Equal(t, legacyUUIDScheme, gotBDAPI.GetAnnotations()[internalUUIDSchemeAnnotation])
+				assert.Equal(t, tt.bd.DevPath, gotBDAPI.Spec.Path)
+			}
+		})
+	}
+}
+
+func TestUpgradeBD(t *testing.T) {
+	physicalBlockDevice := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			WWN:        fakeWWN,
+			Serial:     fakeSerial,
+			Model:      "SanDiskSSD",
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			IDType:     blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
+
+	tests := map[string]struct {
+		bd                     blockdevice.BlockDevice
+		bdAPIList              *apis.BlockDeviceList
+		bdCache                blockdevice.Hierarchy
+		createdOrUpdatedBDName string
+		want                   bool
+		wantErr                bool
+	}{
+		"device not in use": {
 			bd: blockdevice.BlockDevice{
 				DevUse: blockdevice.DeviceUsage{
 					InUse: false,
@@ -2132,7 +2745,7 @@ func TestUpgradeBD(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -2198,8 +2811,8 @@ func TestAddBlockDevice(t *testing.T) {
 	}
 
 	fakeUUID, _ := generateUUIDFromPartitionTable(fakeBD)
-	gptUuidForPhysicalDevice, _ := generateUUID(physicalBlockDevice)
-	gptUuidForPartition, _ := generateUUID(fakeBDForPartition)
+	gptUuidForPhysicalDevice, _, _ := generateUUID(physicalBlockDevice)
+	gptUuidForPartition, _, _ := generateUUID(fakeBDForPartition)
 	legacyUuidForPhysicalDevice, _ := generateLegacyUUID(physicalBlockDevice)
 
 	tests := map[string]struct {
@@ -2638,7 +3251,7 @@ func TestAddBlockDevice(t *testing.T) {
 
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: tt.bdCache,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
 			}
 			pe := &ProbeEvent{
 				Controller: ctrl,
@@ -2665,6 +3278,128 @@ func TestAddBlockDevice(t *testing.T) {
 	}
 }
 
+func TestAddBlockDeviceInSafeMode(t *testing.T) {
+	// a disk without WWN and partitions cannot be uniquely identified, and would
+	// be partitioned by NDM if safe mode is not enabled
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/non-existent-disk",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	tests := map[string]struct {
+		safeMode bool
+		wantErr  bool
+	}{
+		"safe mode disabled, partitioning is attempted": {
+			safeMode: false,
+			wantErr:  true,
+		},
+		"safe mode enabled, partitioning is blocked": {
+			safeMode: true,
+			wantErr:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			ctrl := &controller.Controller{
+				Clientset:   cl,
+				BDHierarchy: blockdevice.NewHierarchyCache(nil),
+				SafeMode:    tt.safeMode,
+			}
+			pe := &ProbeEvent{
+				Controller: ctrl,
+			}
+			err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			assert.Equal(t, 0, len(bdAPIList.Items))
+		})
+	}
+}
+
+func TestAddBlockDeviceInDiscoverOnlyMode(t *testing.T) {
+	tests := map[string]struct {
+		discoverOnly bool
+		wantResource bool
+	}{
+		"discover only mode disabled, blank disk is partitioned": {
+			discoverOnly: false,
+			wantResource: false,
+		},
+		"discover only mode enabled, resource is created without partitioning": {
+			discoverOnly: true,
+			wantResource: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// a blank disk without WWN and partitions, which cannot be uniquely identified
+			diskImage := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+				t.Fatal(err)
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: diskImage,
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType:       blockdevice.BlockDeviceTypeDisk,
+					LogicalBlockSize: 512,
+				},
+				Capacity: blockdevice.CapacityInformation{
+					Storage: 10 * 1024 * 1024,
+				},
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:    cl,
+					BDHierarchy:  blockdevice.NewHierarchyCache(nil),
+					DiscoverOnly: tt.discoverOnly,
+				},
+			}
+			err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+			if err != nil && !errors.Is(err, ErrNeedRescan) {
+				t.Fatal(err)
+			}
+
+			hasEntries, err := partition.HasGPTPartitionEntries(diskImage)
+			assert.NoError(t, err)
+			assert.Equal(t, !tt.discoverOnly, hasEntries)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			if !tt.wantResource {
+				assert.Empty(t, bdAPIList.Items)
+				return
+			}
+			assert.Len(t, bdAPIList.Items, 1)
+			wantUUID, _ := generateLegacyUUID(bd)
+			gotBDAPI := bdAPIList.Items[0]
+			assert.Equal(t, wantUUID, gotBDAPI.Name)
+			assert.Equal(t, apis.BlockDeviceUnclaimed, gotBDAPI.Status.ClaimState)
+			assert.Equal(t, legacyUUIDScheme, gotBDAPI.Annotations[internalUUIDSchemeAnnotation])
+			assert.Equal(t, controller.TrueString, gotBDAPI.Annotations[internalNeedsIdentifierAnnotation])
+		})
+	}
+}
+
 func TestProbeEvent_createOrUpdateWithFSUUID(t *testing.T) {
 	tests := map[string]struct {
 		bd                     blockdevice.BlockDevice
@@ -2870,18 +3605,194 @@ func TestProbeEvent_createOrUpdateWithPartitionUUID(t *testing.T) {
 	}
 }
 
-func TestCreateOrUpdateWithAnnotation(t *testing.T) {
+func TestAddBlockDeviceWithPinnedUUID(t *testing.T) {
+	pinnedUUID := "blockdevice-0123456789abcdef0123456789abcdef"
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		NodeAttributes: map[string]string{
+			blockdevice.NodeName: "node1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			WWN:        fakeWWN,
+			Serial:     fakeSerial,
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			IDType:     blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+	ndmConfig := &controller.NodeDiskManagerConfig{
+		UUIDPinConfigs: []controller.UUIDPinConfig{
+			{WWN: fakeWWN, Serial: fakeSerial, UUID: pinnedUUID},
+		},
+	}
 
 	tests := map[string]struct {
-		bd                     blockdevice.BlockDevice
-		annotation             map[string]string
-		existingBD             *apis.BlockDevice
-		createdOrUpdatedBDName string
-		wantErr                bool
+		bdAPIList *apis.BlockDeviceList
+		bdCache   blockdevice.Hierarchy
+		wantErr   bool
 	}{
-		"existing resource has no annotation": {
-			bd: blockdevice.BlockDevice{
-				Identifier: blockdevice.Identifier{
+		"pinned uuid not in use": {
+			bdAPIList: &apis.BlockDeviceList{},
+			bdCache:   make(blockdevice.Hierarchy),
+			wantErr:   false,
+		},
+		"pinned uuid reuses the existing resource of the device": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: pinnedUUID,
+						},
+						Spec: apis.DeviceSpec{
+							Path: "/dev/sdX",
+							Details: apis.DeviceDetails{
+								Serial: fakeSerial,
+							},
+						},
+						Status: apis.DeviceStatus{
+							ClaimState: apis.BlockDeviceClaimed,
+						},
+					},
+				},
+			},
+			bdCache: make(blockdevice.Hierarchy),
+			wantErr: false,
+		},
+		"pinned uuid in use by a resource of a different device": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: pinnedUUID,
+						},
+						Spec: apis.DeviceSpec{
+							Path: "/dev/sdX",
+							Details: apis.DeviceDetails{
+								Serial: "other-serial",
+							},
+						},
+					},
+				},
+			},
+			bdCache: make(blockdevice.Hierarchy),
+			wantErr: true,
+		},
+		"pinned uuid in use by a resource of a device without serial with a different wwn": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: pinnedUUID,
+						},
+						Spec: apis.DeviceSpec{
+							Path: "/dev/sdX",
+							DevLinks: []apis.DeviceDevLink{
+								{Kind: "by-id", Links: []string{"/dev/disk/by-id/wwn-other-WWN"}},
+							},
+						},
+					},
+				},
+			},
+			bdCache: make(blockdevice.Hierarchy),
+			wantErr: true,
+		},
+		"pinned uuid reuses the resource of the device with the wwn extension in the link": {
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: pinnedUUID,
+						},
+						Spec: apis.DeviceSpec{
+							Path: "/dev/sdX",
+							DevLinks: []apis.DeviceDevLink{
+								{Kind: "by-id", Links: []string{"/dev/disk/by-id/wwn-" + fakeWWN + "0001"}},
+							},
+						},
+					},
+				},
+			},
+			bdCache: make(blockdevice.Hierarchy),
+			wantErr: false,
+		},
+		"pinned uuid in use by another device on the node": {
+			bdAPIList: &apis.BlockDeviceList{},
+			bdCache: blockdevice.Hierarchy{
+				"/dev/sdb": {
+					Identifier: blockdevice.Identifier{
+						UUID:    pinnedUUID,
+						DevPath: "/dev/sdb",
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			// initialize client with all the bd resources
+			for _, bdAPI := range tt.bdAPIList.Items {
+				cl.Create(context.TODO(), &bdAPI)
+			}
+
+			err := cl.List(context.TODO(), tt.bdAPIList)
+			if err != nil {
+				t.Errorf("error updating the resource API List %v", err)
+			}
+
+			ctrl := &controller.Controller{
+				Clientset:   cl,
+				BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
+				NDMConfig:   ndmConfig,
+			}
+			pe := &ProbeEvent{
+				Controller: ctrl,
+			}
+			err = pe.addBlockDevice(bd, tt.bdAPIList)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+
+			gotBDAPI := &apis.BlockDevice{}
+			err = cl.Get(context.TODO(), client.ObjectKey{Name: pinnedUUID}, gotBDAPI)
+			if err != nil {
+				t.Errorf("error in getting blockdevice %s: %v", pinnedUUID, err)
+			}
+			assert.Equal(t, pinnedUUIDScheme, gotBDAPI.GetAnnotations()[internalUUIDSchemeAnnotation])
+			assert.Equal(t, bd.DevPath, gotBDAPI.Spec.Path)
+			assert.Equal(t, bd.NodeAttributes[blockdevice.NodeName], gotBDAPI.Spec.NodeAttributes.NodeName)
+		})
+	}
+}
+
+func TestCreateOrUpdateWithAnnotation(t *testing.T) {
+	wwnDisk := blockdevice.BlockDevice{
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+	wwnDisk.UUID, _, _ = generateUUID(wwnDisk)
+
+	tests := map[string]struct {
+		bd                     blockdevice.BlockDevice
+		annotation             map[string]string
+		existingBD             *apis.BlockDevice
+		createdOrUpdatedBDName string
+		wantBasis              string
+		wantErr                bool
+	}{
+		"existing resource has no annotation": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
 					UUID: "blockdevice-123",
 				},
 			},
@@ -2935,6 +3846,16 @@ func TestCreateOrUpdateWithAnnotation(t *testing.T) {
 			createdOrUpdatedBDName: "blockdevice-123",
 			wantErr:                false,
 		},
+		"uuid generated from the wwn of the disk": {
+			bd: wwnDisk,
+			annotation: map[string]string{
+				internalUUIDSchemeAnnotation: gptUUIDScheme,
+			},
+			existingBD:             nil,
+			createdOrUpdatedBDName: wwnDisk.UUID,
+			wantBasis:              uuidBasisWWN,
+			wantErr:                false,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -2974,6 +3895,1178 @@ func TestCreateOrUpdateWithAnnotation(t *testing.T) {
 				for k, v := range tt.annotation {
 					assert.Equal(t, v, gotBDAPI.GetAnnotations()[k])
 				}
+				assert.Equal(t, tt.wantBasis, gotBDAPI.GetAnnotations()[controller.DefaultUUIDBasisAnnotation])
+			}
+		})
+	}
+}
+
+func TestAddBlockDeviceWithActiveMount(t *testing.T) {
+	tests := map[string]struct {
+		bd      blockdevice.BlockDevice
+		wantErr bool
+	}{
+		"device without mount, partitioning is attempted": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/non-existent-disk",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			wantErr: true,
+		},
+		"mounted device, partitioning is skipped": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/non-existent-disk",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/mnt/data"},
+				},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.Mounted,
+					Reason: "/mnt/data",
+				},
+			},
+			wantErr: false,
+		},
+		"device whose parent is mounted, partitioning is skipped": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/non-existent-disk",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Parent: "/dev/non-existent-parent",
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			ctrl := &controller.Controller{
+				Clientset: cl,
+				BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+					"/dev/non-existent-parent": {
+						Identifier: blockdevice.Identifier{
+							DevPath: "/dev/non-existent-parent",
+						},
+						FSInfo: blockdevice.FileSystemInformation{
+							MountPoint: []string{"/mnt/parent"},
+						},
+					},
+				}),
+			}
+			pe := &ProbeEvent{
+				Controller: ctrl,
+			}
+			err := pe.addBlockDevice(tt.bd, &apis.BlockDeviceList{})
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			assert.Equal(t, 0, len(bdAPIList.Items))
+		})
+	}
+}
+
+func TestAddBlockDeviceWithForeignGPTPartitions(t *testing.T) {
+	// a disk image having a GPT partition entry, for which no partition device
+	// has been created by the kernel
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	d, err := diskfs.Create(diskImage, 10*1024*1024, diskfs.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Partition(&gpt.Table{
+		LogicalSectorSize: 512,
+		ProtectiveMBR:     true,
+		Partitions: []*gpt.Partition{
+			{Start: 2048, End: 20446, Type: gpt.MicrosoftBasicData, Name: "Basic data partition"},
+		},
+	})
+	d.File.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: diskImage,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+	}
+	assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+
+	// the partition table should not have been overwritten
+	d, err = diskfs.OpenWithMode(diskImage, diskfs.ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.File.Close()
+	table, err := d.GetPartitionTable()
+	assert.NoError(t, err)
+	gptTable, ok := table.(*gpt.Table)
+	assert.True(t, ok)
+	assert.Equal(t, gpt.MicrosoftBasicData, gptTable.Partitions[0].Type)
+	assert.Equal(t, "Basic data partition", gptTable.Partitions[0].Name)
+
+	bdAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+	assert.Equal(t, 0, len(bdAPIList.Items))
+}
+
+func TestAddBlockDeviceWithProtectiveMBROnly(t *testing.T) {
+	// a disk image initialized with a protective MBR and a GPT without any partition
+	// entries, which is blank
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	d, err := diskfs.Create(diskImage, 10*1024*1024, diskfs.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Partition(&gpt.Table{
+		LogicalSectorSize: 512,
+		ProtectiveMBR:     true,
+		Partitions:        []*gpt.Partition{},
+	})
+	d.File.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: diskImage,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType:       blockdevice.BlockDeviceTypeDisk,
+			LogicalBlockSize: 512,
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 10 * 1024 * 1024,
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionTableType: "gpt",
+		},
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+	}
+	assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+
+	// the single NDM partition should have been created on the disk
+	layout, err := partition.GetProtectiveMBRLayout(diskImage)
+	assert.NoError(t, err)
+	assert.Equal(t, partition.ProtectiveMBRWithPartitions, layout)
+	entry, ok, err := partition.GetGPTPartitionEntry(diskImage, 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotEmpty(t, entry.GUID)
+
+	bdAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+	assert.Equal(t, 0, len(bdAPIList.Items))
+}
+
+func TestAddBlockDeviceDecisionTrace(t *testing.T) {
+	// a blank disk without WWN, which cannot be uniquely identified and is partitioned
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blankDisk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: diskImage,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType:       blockdevice.BlockDeviceTypeDisk,
+			LogicalBlockSize: 512,
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 10 * 1024 * 1024,
+		},
+	}
+	// a disk with WWN, for which a resource is created
+	wwnDisk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdX",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+
+	tests := map[string]struct {
+		bd            blockdevice.BlockDevice
+		decisionTrace bool
+		wantTrace     string
+		wantResource  bool
+	}{
+		"partition created on a blank disk": {
+			bd:            blankDisk,
+			decisionTrace: true,
+			wantTrace: "unmanaged-checks:passed > parent-in-use:false > upgrade:not-required > " +
+				"uuid-basis:none > action:partition-created",
+			wantResource: false,
+		},
+		"resource created for a disk with wwn": {
+			bd:            wwnDisk,
+			decisionTrace: true,
+			wantTrace: "unmanaged-checks:passed > parent-in-use:false > upgrade:not-required > " +
+				"uuid-basis:wwn > action:resource-created",
+			wantResource: true,
+		},
+		"decision trace disabled": {
+			bd:            wwnDisk,
+			decisionTrace: false,
+			wantTrace:     "",
+			wantResource:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:     cl,
+					BDHierarchy:   blockdevice.NewHierarchyCache(nil),
+					DecisionTrace: tt.decisionTrace,
+				},
+			}
+			bd := tt.bd
+			if tt.decisionTrace {
+				// the trace is passed in, so that it can be checked even if no resource is created
+				bd.DecisionTrace = &blockdevice.DecisionTrace{}
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+			assert.Equal(t, tt.wantTrace, bd.DecisionTrace.String())
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			if !tt.wantResource {
+				assert.Empty(t, bdAPIList.Items)
+				return
+			}
+			if assert.Len(t, bdAPIList.Items, 1) {
+				gotTrace, ok := bdAPIList.Items[0].Annotations[controller.DecisionTraceAnnotation]
+				assert.Equal(t, tt.decisionTrace, ok)
+				assert.Equal(t, tt.wantTrace, gotTrace)
+			}
+		})
+	}
+}
+
+func TestAddBlockDeviceWithFabricTransport(t *testing.T) {
+	// a namespace exported by an NVMe-oF target, that cannot be uniquely identified
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/non-existent-disk",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType:   blockdevice.BlockDeviceTypeDisk,
+			Transport:    blockdevice.TransportNVMeTCP,
+			FabricTarget: "nqn.2014-08.org.nvmexpress:target1",
+		},
+	}
+
+	tests := map[string]struct {
+		partitionFabricDevices bool
+		wantErr                bool
+	}{
+		"fabric devices are not partitioned by default": {
+			partitionFabricDevices: false,
+			wantErr:                false,
+		},
+		"partitioning of fabric devices enabled, partitioning is attempted": {
+			partitionFabricDevices: true,
+			wantErr:                true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			ctrl := &controller.Controller{
+				Clientset:              cl,
+				BDHierarchy:            blockdevice.NewHierarchyCache(nil),
+				PartitionFabricDevices: tt.partitionFabricDevices,
+			}
+			pe := &ProbeEvent{
+				Controller: ctrl,
+			}
+			err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestAddBlockDeviceBeforeNodeProvisioned(t *testing.T) {
+	// a blank disk that cannot be uniquely identified, which will be partitioned
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/non-existent-disk",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+
+	tests := map[string]struct {
+		markerPresent bool
+		wantErr       bool
+	}{
+		"partitioning is deferred till the provisioning marker appears": {
+			markerPresent: false,
+			wantErr:       false,
+		},
+		"provisioning marker present, partitioning is attempted": {
+			markerPresent: true,
+			// partitioning a non existent disk fails
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			marker := filepath.Join(t.TempDir(), "result.json")
+			if tt.markerPresent {
+				if err := os.WriteFile(marker, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   CreateFakeClient(t),
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+				},
+			}
+			pe.Controller.StartProvisioningWatcher([]string{marker}, 0)
+			if tt.markerPresent {
+				<-pe.Controller.ProvisioningDone()
+			}
+
+			err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithCapacityRange(t *testing.T) {
+	const gb = 1000 * 1000 * 1000
+	ndmConfig := &controller.NodeDiskManagerConfig{
+		CapacityRangeConfig: &controller.CapacityRangeConfig{Min: "500G", Max: "2T"},
+	}
+	newDisk := func(capacity uint64) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sda",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				WWN:        fakeWWN,
+				Serial:     fakeSerial,
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				IDType:     blockdevice.BlockDeviceTypeDisk,
+			},
+			Capacity: blockdevice.CapacityInformation{
+				Storage: capacity,
+			},
+		}
+	}
+	partition := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: "/dev/sda",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionEntryUUID: "065e2357-05",
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 10 * gb,
+		},
+	}
+
+	tests := map[string]struct {
+		bd           blockdevice.BlockDevice
+		bdCache      blockdevice.Hierarchy
+		wantResource bool
+	}{
+		"disk with capacity equal to the minimum is managed": {
+			bd:           newDisk(500 * gb),
+			bdCache:      make(blockdevice.Hierarchy),
+			wantResource: true,
+		},
+		"disk with capacity less than the minimum is skipped": {
+			bd:           newDisk(500*gb - 1),
+			bdCache:      make(blockdevice.Hierarchy),
+			wantResource: false,
+		},
+		"disk with capacity more than the maximum is skipped": {
+			bd:           newDisk(2000*gb + 1),
+			bdCache:      make(blockdevice.Hierarchy),
+			wantResource: false,
+		},
+		"partition of a disk with capacity more than the maximum is skipped": {
+			bd: partition,
+			bdCache: blockdevice.Hierarchy{
+				"/dev/sda": newDisk(4000 * gb),
+			},
+			wantResource: false,
+		},
+		"partition of a disk in the range is checked using the capacity of the disk": {
+			bd: partition,
+			bdCache: blockdevice.Hierarchy{
+				"/dev/sda": newDisk(1000 * gb),
+			},
+			wantResource: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
+					NDMConfig:   ndmConfig,
+				},
+			}
+			err := pe.addBlockDevice(tt.bd, &apis.BlockDeviceList{})
+			assert.NoError(t, err)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			if err := cl.List(context.TODO(), bdAPIList); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantResource {
+				assert.Len(t, bdAPIList.Items, 1)
+			} else {
+				assert.Empty(t, bdAPIList.Items)
+			}
+		})
+	}
+}
+
+func TestAddBlockDeviceWithAllowList(t *testing.T) {
+	ndmConfig := &controller.NodeDiskManagerConfig{
+		AllowListConfig: &controller.AllowListConfig{
+			Enabled: true,
+			Devices: []controller.AllowListDevice{{WWN: fakeWWN, Serial: fakeSerial}},
+		},
+	}
+	newDisk := func(wwn, serial string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sda",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				WWN:        wwn,
+				Serial:     serial,
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				IDType:     blockdevice.BlockDeviceTypeDisk,
+			},
+		}
+	}
+	partition := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: "/dev/sda",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionEntryUUID: "065e2357-05",
+		},
+	}
+
+	tests := map[string]struct {
+		bd           blockdevice.BlockDevice
+		bdCache      blockdevice.Hierarchy
+		wantResource bool
+	}{
+		"listed disk is managed": {
+			bd:           newDisk(fakeWWN, fakeSerial),
+			bdCache:      make(blockdevice.Hierarchy),
+			wantResource: true,
+		},
+		"unlisted disk is skipped": {
+			bd:           newDisk("0x5000c500a1b2c3ff", "ZA1B2CFF"),
+			bdCache:      make(blockdevice.Hierarchy),
+			wantResource: false,
+		},
+		"partition of a listed disk is managed": {
+			bd: partition,
+			bdCache: blockdevice.Hierarchy{
+				"/dev/sda": newDisk(fakeWWN, fakeSerial),
+			},
+			wantResource: true,
+		},
+		"partition of an unlisted disk is skipped": {
+			bd: partition,
+			bdCache: blockdevice.Hierarchy{
+				"/dev/sda": newDisk("0x5000c500a1b2c3ff", "ZA1B2CFF"),
+			},
+			wantResource: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.bdCache),
+					NDMConfig:   ndmConfig,
+				},
+			}
+			err := pe.addBlockDevice(tt.bd, &apis.BlockDeviceList{})
+			assert.NoError(t, err)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			if err := cl.List(context.TODO(), bdAPIList); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantResource {
+				assert.Len(t, bdAPIList.Items, 1)
+			} else {
+				assert.Empty(t, bdAPIList.Items)
+			}
+			// the unlisted devices are skipped entirely, they are not even cached
+			_, cached := pe.Controller.BDHierarchy.Get(tt.bd.DevPath)
+			assert.Equal(t, tt.wantResource, cached)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithTransportPolicy(t *testing.T) {
+	ndmConfig := &controller.NodeDiskManagerConfig{
+		TransportPolicyConfig: &controller.TransportPolicyConfig{
+			Allow: []string{"sata", "nvme"},
+			Deny:  []string{"usb"},
+		},
+	}
+	newDisk := func(transport string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sda",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				WWN:        fakeWWN,
+				Serial:     fakeSerial,
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				IDType:     blockdevice.BlockDeviceTypeDisk,
+				Transport:  transport,
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		bd           blockdevice.BlockDevice
+		wantResource bool
+	}{
+		"disk attached over an allowed transport is managed": {
+			bd:           newDisk(blockdevice.TransportSATA),
+			wantResource: true,
+		},
+		"disk attached over a denied transport is skipped": {
+			bd:           newDisk(blockdevice.TransportUSB),
+			wantResource: false,
+		},
+		"disk attached over a transport not in the allow list is skipped": {
+			bd:           newDisk(blockdevice.TransportISCSI),
+			wantResource: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+					NDMConfig:   ndmConfig,
+				},
+			}
+			err := pe.addBlockDevice(tt.bd, &apis.BlockDeviceList{})
+			assert.NoError(t, err)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			if err := cl.List(context.TODO(), bdAPIList); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantResource {
+				assert.Len(t, bdAPIList.Items, 1)
+			} else {
+				assert.Empty(t, bdAPIList.Items)
+			}
+		})
+	}
+}
+
+// updateCountingClient counts the updates made to each resource through the client
+type updateCountingClient struct {
+	client.Client
+	updates map[string]int
+}
+
+func (c *updateCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.updates[obj.GetName()]++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestAddBlockDeviceDeactivatesParentOnce(t *testing.T) {
+	parent := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+	partitions := make([]blockdevice.BlockDevice, 0)
+	for i := 1; i <= 4; i++ {
+		devPath := fmt.Sprintf("/dev/sdx%d", i)
+		parent.DependentDevices.Partitions = append(parent.DependentDevices.Partitions, devPath)
+		partitions = append(partitions, blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypePartition,
+			},
+			PartitionInfo: blockdevice.PartitionInformation{
+				PartitionEntryUUID: fmt.Sprintf("9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c4%d", i),
+			},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Parent: parent.DevPath,
+			},
+		})
+	}
+	parentUUID, _, ok := generateUUID(parent)
+	if !ok {
+		t.Fatal("unable to generate uuid for parent device")
+	}
+
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, _ *blockdevice.BlockDevice) {}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+
+	tests := map[string]struct {
+		perBatch        bool
+		wantParentWrite int
+	}{
+		"parent deactivated once for all the partitions in the batch": {
+			perBatch:        true,
+			wantParentWrite: 1,
+		},
+		"parent deactivated for every partition without batch tracking": {
+			perBatch:        false,
+			wantParentWrite: 4,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+			cl := &updateCountingClient{
+				Client:  fake.NewFakeClientWithScheme(s),
+				updates: make(map[string]int),
+			}
+			parentBDAPI := apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: parentUUID,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: apis.BlockDeviceUnclaimed,
+					State:      controller.NDMActive,
+				},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), &parentBDAPI))
+
+			hierarchy := blockdevice.Hierarchy{parent.DevPath: parent}
+			for _, bd := range partitions {
+				hierarchy[bd.DevPath] = bd
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(hierarchy),
+				},
+			}
+			if tt.perBatch {
+				pe.deactivatedParents = make(map[string]struct{})
+			}
+			for _, bd := range partitions {
+				assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+			}
+
+			assert.Equal(t, tt.wantParentWrite, cl.updates[parentUUID])
+			got := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: parentUUID}, got))
+			assert.Equal(t, controller.NDMInactive, string(got.Status.State))
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			assert.Equal(t, 5, len(bdAPIList.Items))
+		})
+	}
+}
+
+func TestAddBlockDeviceWithUUIDVersion(t *testing.T) {
+	newDisk := func(devPath, wwn string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        wwn,
+				Serial:     "ZA1B2C3D",
+			},
+		}
+	}
+	existingDisk := newDisk("/dev/sdb", "0x5000c500a1b2c3d4")
+	newDiskV2 := newDisk("/dev/sdc", "0x5000c500a1b2c3d5")
+
+	v1UUID, _, _ := generateUUIDWithVersion(existingDisk, controller.UUIDVersionV1)
+	v2UUIDOfExisting, _, _ := generateUUIDWithVersion(existingDisk, controller.UUIDVersionV2)
+	newV2UUID, _, _ := generateUUIDWithVersion(newDiskV2, controller.UUIDVersionV2)
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+
+	// resource of the existing disk, created by an earlier version of NDM without the
+	// uuid version annotation
+	v1BDAPI := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: v1UUID,
+			Annotations: map[string]string{
+				internalUUIDSchemeAnnotation: gptUUIDScheme,
+			},
+		},
+		Spec: apis.DeviceSpec{
+			Path: existingDisk.DevPath,
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: apis.BlockDeviceUnclaimed,
+		},
+	}
+	assert.NoError(t, cl.Create(context.TODO(), &v1BDAPI))
+	bdAPIList := &apis.BlockDeviceList{Items: []apis.BlockDevice{v1BDAPI}}
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+			UUIDVersion: controller.UUIDVersionV2,
+		},
+	}
+	assert.NoError(t, pe.addBlockDevice(existingDisk, bdAPIList))
+	assert.NoError(t, pe.addBlockDevice(newDiskV2, bdAPIList))
+
+	gotBDAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), gotBDAPIList))
+	gotVersions := make(map[string]string)
+	for _, bdAPI := range gotBDAPIList.Items {
+		gotVersions[bdAPI.Name] = bdAPI.Annotations[internalUUIDVersionAnnotation]
+	}
+	assert.Equal(t, map[string]string{
+		// the v1 resource keeps its name, and gets the version recorded
+		v1UUID: string(controller.UUIDVersionV1),
+		// the new device uses the configured version
+		newV2UUID: string(controller.UUIDVersionV2),
+	}, gotVersions)
+	assert.NotContains(t, gotVersions, v2UUIDOfExisting)
+}
+
+func TestUpgradeDeviceInUseWithUUIDVersion(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+	v2UUID, _, _ := generateUUIDWithVersion(bd, controller.UUIDVersionV2)
+
+	// the device is in use by a resource created with the configured version
+	bdAPIList := &apis.BlockDeviceList{
+		Items: []apis.BlockDevice{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: v2UUID,
+				},
+				Spec: apis.DeviceSpec{
+					Path: bd.DevPath,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: apis.BlockDeviceClaimed,
+				},
+			},
+		},
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+			UUIDVersion: controller.UUIDVersionV2,
+		},
+	}
+
+	upgrades := map[string]func(blockdevice.BlockDevice, *apis.BlockDeviceList) (bool, error){
+		"cstor":         pe.upgradeDeviceInUseByCStor,
+		"localpv":       pe.upgradeDeviceInUseByLocalPV,
+		"localpv-block": pe.upgradeDeviceInUseByLocalPVBlock,
+	}
+	for name, upgrade := range upgrades {
+		t.Run(name, func(t *testing.T) {
+			got, err := upgrade(bd, bdAPIList)
+			assert.NoError(t, err)
+			// the resource is found by its v2 uuid, so no legacy resource is created
+			assert.True(t, got)
+			gotBDAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), gotBDAPIList))
+			assert.Empty(t, gotBDAPIList.Items)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithV5UUID(t *testing.T) {
+	newDisk := func(devPath, wwn string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        wwn,
+				Serial:     "ZA1B2C3D",
+			},
+		}
+	}
+	namespace := uuid.MustParse("3f1c2b6e-8a4d-4e5f-9b7a-1c2d3e4f5a6b")
+	existingDisk := newDisk("/dev/sdb", "0x5000c500a1b2c3d4")
+	newDiskV5 := newDisk("/dev/sdc", "0x5000c500a1b2c3d5")
+
+	v1UUID, _, _ := generateUUIDWithVersion(existingDisk, controller.UUIDVersionV1)
+	newV5UUID, _, _ := generateV5UUID(newDiskV5, namespace)
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+
+	v1BDAPI := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: v1UUID,
+			Annotations: map[string]string{
+				internalUUIDSchemeAnnotation: gptUUIDScheme,
+			},
+		},
+		Spec: apis.DeviceSpec{
+			Path: existingDisk.DevPath,
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: apis.BlockDeviceUnclaimed,
+		},
+	}
+	assert.NoError(t, cl.Create(context.TODO(), &v1BDAPI))
+	bdAPIList := &apis.BlockDeviceList{Items: []apis.BlockDevice{v1BDAPI}}
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:     cl,
+			BDHierarchy:   blockdevice.NewHierarchyCache(nil),
+			UUIDVersion:   controller.UUIDVersionV5,
+			UUIDNamespace: namespace,
+		},
+	}
+	assert.NoError(t, pe.addBlockDevice(existingDisk, bdAPIList))
+	assert.NoError(t, pe.addBlockDevice(newDiskV5, bdAPIList))
+
+	gotBDAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), gotBDAPIList))
+	assert.Equal(t, 2, len(gotBDAPIList.Items))
+	for _, bdAPI := range gotBDAPIList.Items {
+		switch bdAPI.Name {
+		case v1UUID:
+			// the existing resource is not re-keyed
+			assert.Equal(t, string(controller.UUIDVersionV1), bdAPI.Annotations[internalUUIDVersionAnnotation])
+			assert.NotContains(t, bdAPI.Annotations, internalUUIDNamespaceAnnotation)
+		case newV5UUID:
+			assert.Equal(t, string(controller.UUIDVersionV5), bdAPI.Annotations[internalUUIDVersionAnnotation])
+			assert.Equal(t, namespace.String(), bdAPI.Annotations[internalUUIDNamespaceAnnotation])
+		default:
+			t.Errorf("unexpected blockdevice: %s", bdAPI.Name)
+		}
+	}
+}
+
+func TestAddBlockDeviceDefersPartitioningOfSparselyProbedDevice(t *testing.T) {
+	tests := map[string]struct {
+		serial          string
+		wantPartitioned bool
+	}{
+		"sparsely probed device is not partitioned": {
+			serial:          "",
+			wantPartitioned: false,
+		},
+		"device with enough identifiers is partitioned": {
+			serial:          "ZA1B2C3D",
+			wantPartitioned: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// a blank disk without WWN and partitions, which cannot be uniquely identified
+			diskImage := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+				t.Fatal(err)
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: diskImage,
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType:       blockdevice.BlockDeviceTypeDisk,
+					LogicalBlockSize: 512,
+					Transport:        blockdevice.TransportSATA,
+					Serial:           tt.serial,
+				},
+				Capacity: blockdevice.CapacityInformation{
+					Storage: 10 * 1024 * 1024,
+				},
+				DecisionTrace: &blockdevice.DecisionTrace{},
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						PartitioningConfidenceConfig: &controller.PartitioningConfidenceConfig{
+							Required: []string{controller.IdentifierTransport, controller.IdentifierSize},
+							AnyOf:    []string{controller.IdentifierSerial, controller.IdentifierModel},
+						},
+					},
+				},
+			}
+			err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+			if err != nil && !errors.Is(err, ErrNeedRescan) {
+				t.Fatal(err)
+			}
+
+			hasEntries, err := partition.HasGPTPartitionEntries(diskImage)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPartitioned, hasEntries)
+			assert.Equal(t, !tt.wantPartitioned,
+				util.Contains(bd.DecisionTrace.Steps(), "partitioning:deferred-needs-more-info"))
+		})
+	}
+}
+
+func TestAddBlockDeviceWithProtectedParent(t *testing.T) {
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, _ *blockdevice.BlockDevice) {}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+
+	parent := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sdx1"},
+		},
+	}
+	partitionBD := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionEntryUUID: "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c41",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: parent.DevPath,
+		},
+	}
+	parentUUID, _, ok := generateUUID(parent)
+	if !ok {
+		t.Fatal("unable to generate uuid for parent device")
+	}
+
+	tests := map[string]struct {
+		claimState      apis.DeviceClaimState
+		claimRef        bool
+		pendingClaim    bool
+		protectionLevel controller.ProtectionLevel
+		wantDeactivated bool
+	}{
+		"unclaimed parent is deactivated": {
+			claimState:      apis.BlockDeviceUnclaimed,
+			wantDeactivated: true,
+		},
+		"claimed parent is not deactivated": {
+			claimState:      apis.BlockDeviceClaimed,
+			wantDeactivated: false,
+		},
+		"released parent is not deactivated": {
+			claimState:      apis.BlockDeviceReleased,
+			wantDeactivated: false,
+		},
+		"unclaimed parent with a claim reference is not deactivated": {
+			claimState:      apis.BlockDeviceUnclaimed,
+			claimRef:        true,
+			wantDeactivated: false,
+		},
+		"unclaimed parent with an outstanding claim is not deactivated": {
+			claimState:      apis.BlockDeviceUnclaimed,
+			pendingClaim:    true,
+			wantDeactivated: false,
+		},
+		"unclaimed parent with an outstanding claim is not deactivated in strict level": {
+			claimState:      apis.BlockDeviceUnclaimed,
+			pendingClaim:    true,
+			protectionLevel: controller.StrictProtection,
+			wantDeactivated: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			parentBDAPI := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: parentUUID,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: tt.claimState,
+					State:      controller.NDMActive,
+				},
+			}
+			if tt.claimRef {
+				parentBDAPI.Spec.ClaimRef = &v1.ObjectReference{Name: "bdc-bound"}
+			}
+			assert.NoError(t, cl.Create(context.TODO(), parentBDAPI))
+			if tt.pendingClaim {
+				claim := &apis.BlockDeviceClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "bdc-pending"},
+					Spec:       apis.DeviceClaimSpec{BlockDeviceName: parentUUID},
+				}
+				assert.NoError(t, cl.Create(context.TODO(), claim))
+			}
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+						parent.DevPath:      parent,
+						partitionBD.DevPath: partitionBD,
+					}),
+					ProtectionLevel: tt.protectionLevel,
+				},
+			}
+			assert.NoError(t, pe.addBlockDevice(partitionBD, &apis.BlockDeviceList{}))
+
+			got := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: parentUUID}, got))
+			if tt.wantDeactivated {
+				assert.Equal(t, controller.NDMInactive, string(got.Status.State))
+			} else {
+				assert.Equal(t, controller.NDMActive, string(got.Status.State))
 			}
 		})
 	}
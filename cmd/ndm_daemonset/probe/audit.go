@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+)
+
+// getAuditTarget gets the device as the target of a destructive operation, recorded in
+// the audit log
+func getAuditTarget(bd blockdevice.BlockDevice) controller.AuditTarget {
+	return controller.AuditTarget{
+		Device:      bd.DevPath,
+		BlockDevice: bd.UUID,
+		WWN:         bd.DeviceAttributes.WWN,
+		Serial:      bd.DeviceAttributes.Serial,
+	}
+}
+
+// blankDiskAuditCause is the cause of the operations performed on a blank disk that is
+// partitioned, recorded in the audit log
+var blankDiskAuditCause = controller.AuditCause{
+	Trigger: controller.AuditTriggerDeviceAdded,
+	Reason:  "blank disk without partitions, filesystem or other signatures",
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertAuditRecords asserts that the audit log has exactly the wanted records, comparing
+// the operation, device, trigger and result of each record. Every record must have a reason
+// and the chain of records must be intact.
+func assertAuditRecords(t *testing.T, buf *bytes.Buffer, want []controller.AuditRecord) {
+	t.Helper()
+	assert.NoError(t, controller.VerifyAuditLog(bytes.NewReader(buf.Bytes()), nil))
+
+	got := make([]controller.AuditRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		record := controller.AuditRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		assert.NotEmpty(t, record.Reason)
+		got = append(got, controller.AuditRecord{
+			Operation: record.Operation,
+			Device:    record.Device,
+			Trigger:   record.Trigger,
+			Result:    record.Result,
+		})
+	}
+	if want == nil {
+		want = []controller.AuditRecord{}
+	}
+	assert.Equal(t, want, got)
+}
@@ -20,17 +20,23 @@ import (
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/blkid"
+	"github.com/openebs/node-disk-manager/pkg/partition"
 	"k8s.io/klog/v2"
 )
 
 const (
+	blkidConfigKey     = "blkid-probe"
 	blkidProbePriority = 4
 )
 
 var (
+	blkidProbeName  = "blkid probe"
 	blkidProbeState = defaultEnabled
 )
 
+// getGPTPartitionEntry is a variable, so that it can be replaced in tests
+var getGPTPartitionEntry = partition.GetGPTPartitionEntry
+
 type blkidProbe struct {
 }
 
@@ -38,19 +44,21 @@ var blkidProbeRegister = func() {
 	// Get a controller object
 	ctrl := <-controller.ControllerBroadcastChannel
 	if ctrl == nil {
-		klog.Error("unable to configure custom tag probe")
+		klog.Error("unable to configure", blkidProbeName)
 		return
 	}
 	probe := &blkidProbe{}
 
 	newRegisterProbe := &registerProbe{
 		priority:   blkidProbePriority,
-		name:       "blkid probe",
+		name:       blkidProbeName,
 		state:      blkidProbeState,
+		configKey:  blkidConfigKey,
 		pi:         probe,
 		controller: ctrl,
 	}
-
+	newRegisterProbe.configure()
+	blkidProbeName = newRegisterProbe.name
 	newRegisterProbe.register()
 }
 
@@ -63,6 +71,18 @@ func (bp *blkidProbe) FillBlockDeviceDetails(bd *blockdevice.BlockDevice) {
 		bd.FSInfo.FileSystem = di.GetOnDiskFileSystem()
 	}
 
+	// all the signatures are read, as a stale signature of an earlier use can make the
+	// filesystem reported above ambiguous
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypeDisk ||
+		bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		signatures, err := di.GetOnDiskSignatures()
+		if err != nil {
+			klog.Warningf("unable to read signatures of device: %s, err: %v", bd.DevPath, err)
+		} else {
+			bd.FSInfo.Signatures = signatures
+		}
+	}
+
 	// if the host is CentOS 7, the `libblkid` version on host is `2.23`,
 	// but the `PTUUID` tag was start to provide from `2.24`. This will cause
 	// the udev cache fetched from host udevd will not contain env `ID_PART_TABLE_UUID`.
@@ -76,4 +96,33 @@ func (bp *blkidProbe) FillBlockDeviceDetails(bd *blockdevice.BlockDevice) {
 	if len(bd.PartitionInfo.PartitionEntryUUID) == 0 {
 		bd.PartitionInfo.PartitionEntryUUID = di.GetPartitionEntryUUID()
 	}
+
+	// as a last resort, the GUID of a partition is read from its entry in the GPT
+	// partition table of the parent disk
+	if len(bd.PartitionInfo.PartitionEntryUUID) == 0 {
+		fillPartitionInfoFromGPT(bd)
+	}
+}
+
+// fillPartitionInfoFromGPT fills the partition GUID and the start offset of a partition
+// from the entry of the partition in the GPT partition table of the parent disk
+func fillPartitionInfoFromGPT(bd *blockdevice.BlockDevice) {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition ||
+		bd.DependentDevices.Parent == "" || bd.PartitionInfo.PartitionNumber == 0 {
+		return
+	}
+	entry, ok, err := getGPTPartitionEntry(bd.DependentDevices.Parent, bd.PartitionInfo.PartitionNumber)
+	if err != nil {
+		klog.Warningf("unable to read GPT entry of partition: %s, err: %v", bd.DevPath, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	bd.PartitionInfo.PartitionEntryUUID = entry.GUID
+	if bd.PartitionInfo.StartOffset == 0 {
+		bd.PartitionInfo.StartOffset = entry.StartOffset
+	}
+	klog.V(4).Infof("blockdevice path: %s partition guid :%s filled from GPT of disk: %s",
+		bd.DevPath, entry.GUID, bd.DependentDevices.Parent)
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package probe
 
 import (
+	"context"
 	"errors"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
@@ -28,9 +29,16 @@ import (
 func (pe *ProbeEvent) changeBlockDevice(bd *blockdevice.BlockDevice, requestedProbes ...string) error {
 	bdCopy := *bd
 	haveEqualMountPoints := true
-	pe.Controller.FillBlockDeviceDetails(bd, requestedProbes...)
+	pe.Controller.FillBlockDeviceDetails(context.TODO(), bd, requestedProbes...)
 	if bd.UUID == "" {
-		uuid, ok := generateUUID(*bd)
+		// the uuid is generated with the version used by the existing resource of the
+		// device, if any, same as when the device was added
+		bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+		if err != nil {
+			klog.Errorf("unable to list blockdevices for generating uuid of device: %s, %v", bd.DevPath, err)
+			return err
+		}
+		uuid, _, _, ok := pe.generateDeviceUUID(*bd, bdAPIList)
 		if !ok {
 			klog.Error("could no generate uuid for device. aborting")
 			return errors.New("could not identify device uniquely")
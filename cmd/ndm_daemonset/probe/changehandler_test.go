@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestChangeBlockDeviceWithUUIDVersion(t *testing.T) {
+	newDisk := func() blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sdb",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        "0x5000c500a1b2c3d4",
+				Serial:     "ZA1B2C3D",
+			},
+		}
+	}
+	v1UUID, _, _ := generateUUIDWithVersion(newDisk(), controller.UUIDVersionV1)
+	v2UUID, _, _ := generateUUIDWithVersion(newDisk(), controller.UUIDVersionV2)
+
+	tests := map[string]struct {
+		existingBDName string
+		wantUUID       string
+	}{
+		"device without a resource uses the configured version": {
+			existingBDName: "",
+			wantUUID:       v2UUID,
+		},
+		"device with a v1 resource keeps the v1 uuid": {
+			existingBDName: v1UUID,
+			wantUUID:       v1UUID,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			if test.existingBDName != "" {
+				assert.NoError(t, cl.Create(context.TODO(), &apis.BlockDevice{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: test.existingBDName,
+						Labels: map[string]string{
+							controller.KubernetesHostNameLabel: "node1",
+						},
+					},
+					Status: apis.DeviceStatus{
+						ClaimState: apis.BlockDeviceClaimed,
+					},
+				}))
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+					Mutex:       &sync.Mutex{},
+					NodeAttributes: map[string]string{
+						controller.HostNameKey: "node1",
+					},
+					UUIDVersion: controller.UUIDVersionV2,
+				},
+			}
+
+			bd := newDisk()
+			assert.NoError(t, pe.changeBlockDevice(&bd))
+			assert.Equal(t, test.wantUUID, bd.UUID)
+		})
+	}
+}
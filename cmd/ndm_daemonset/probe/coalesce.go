@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// eventCoalescer buffers the udev events of a disk and its partitions for a window,
+// starting from the first event of the disk, so that the burst of events generated by
+// a partition table re-read is processed as a single batch.
+type eventCoalescer struct {
+	window time.Duration
+	// pending are the buffered events, keyed by the path of the disk
+	pending map[string]*pendingEvents
+}
+
+type pendingEvents struct {
+	deadline time.Time
+	messages []controller.EventMessage
+}
+
+func newEventCoalescer(window time.Duration) *eventCoalescer {
+	return &eventCoalescer{
+		window:  window,
+		pending: make(map[string]*pendingEvents),
+	}
+}
+
+// add buffers the event message, received at the given time
+func (ec *eventCoalescer) add(msg controller.EventMessage, now time.Time) {
+	key := getCoalesceKey(msg)
+	p, ok := ec.pending[key]
+	if !ok {
+		p = &pendingEvents{deadline: now.Add(ec.window)}
+		ec.pending[key] = p
+	}
+	p.messages = append(p.messages, msg)
+}
+
+// nextDeadline gets the earliest deadline among the buffered events. false is
+// returned if no events are buffered.
+func (ec *eventCoalescer) nextDeadline() (time.Time, bool) {
+	var next time.Time
+	for _, p := range ec.pending {
+		if next.IsZero() || p.deadline.Before(next) {
+			next = p.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// flush removes the buffered events whose window has expired at the given time and
+// returns the coalesced event messages to be processed.
+func (ec *eventCoalescer) flush(now time.Time) []controller.EventMessage {
+	messages := make([]controller.EventMessage, 0)
+	for key, p := range ec.pending {
+		if p.deadline.After(now) {
+			continue
+		}
+		coalesced := coalesceEvents(p.messages)
+		klog.V(4).Infof("coalesced %d events of device: %s into %d", len(p.messages), key, len(coalesced))
+		messages = append(messages, coalesced...)
+		delete(ec.pending, key)
+	}
+	return messages
+}
+
+// discard removes all the buffered events without processing them, eg: on shutdown,
+// and returns the no. of disks whose events were discarded.
+func (ec *eventCoalescer) discard() int {
+	n := len(ec.pending)
+	ec.pending = make(map[string]*pendingEvents)
+	return n
+}
+
+// getCoalesceKey gets the path of the disk to which the device in the event belongs.
+// The dependents are not filled for remove events, as the sysfs entry is already gone,
+// so the parent of a partition is found from the sysfs path in that case.
+func getCoalesceKey(msg controller.EventMessage) string {
+	if len(msg.Devices) == 0 {
+		return ""
+	}
+	device := msg.Devices[0]
+	if device.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition {
+		return device.DevPath
+	}
+	if device.DependentDevices.Parent != "" {
+		return device.DependentDevices.Parent
+	}
+	if device.SysPath != "" {
+		return "/dev/" + filepath.Base(filepath.Dir(device.SysPath))
+	}
+	return device.DevPath
+}
+
+// deviceEvents are the events of a single device within the window
+type deviceEvents struct {
+	// removed is the device from the first remove event
+	removed *blockdevice.BlockDevice
+	// added is the device from the last add event, if the device was not removed after it
+	added *blockdevice.BlockDevice
+	// changed is the last change event, if the device was neither added nor removed
+	changed *controller.EventMessage
+}
+
+// coalesceEvents coalesces the event messages, each having a single device, into a
+// detach message having all the removed devices, followed by an attach message having
+// all the added devices and then the change messages. The order of the devices is the
+// order in which they first appeared in the events.
+//
+// A device that is removed and added again within the window is both detached and
+// attached, as it may be a different device at the same path. A device that is added
+// and then removed is only detached. A change event is dropped if the device is added
+// or removed, as all the details are filled again on an add event.
+func coalesceEvents(messages []controller.EventMessage) []controller.EventMessage {
+	devPaths := make([]string, 0)
+	events := make(map[string]*deviceEvents)
+	for i := range messages {
+		msg := messages[i]
+		for _, device := range msg.Devices {
+			e, ok := events[device.DevPath]
+			if !ok {
+				e = &deviceEvents{}
+				events[device.DevPath] = e
+				devPaths = append(devPaths, device.DevPath)
+			}
+			switch msg.Action {
+			case string(AttachEA):
+				e.added = device
+				e.changed = nil
+			case string(DetachEA):
+				if e.removed == nil {
+					e.removed = device
+				}
+				e.added = nil
+				e.changed = nil
+			case string(ChangeEA):
+				if e.added == nil && e.removed == nil {
+					e.changed = &messages[i]
+				}
+			}
+		}
+	}
+
+	detach := controller.EventMessage{Action: string(DetachEA)}
+	attach := controller.EventMessage{Action: string(AttachEA)}
+	changes := make([]controller.EventMessage, 0)
+	for _, devPath := range devPaths {
+		e := events[devPath]
+		if e.removed != nil {
+			detach.Devices = append(detach.Devices, e.removed)
+		}
+		if e.added != nil {
+			attach.Devices = append(attach.Devices, e.added)
+		}
+		if e.changed != nil {
+			changes = append(changes, *e.changed)
+		}
+	}
+
+	result := make([]controller.EventMessage, 0)
+	if len(detach.Devices) > 0 {
+		result = append(result, detach)
+	}
+	if len(attach.Devices) > 0 {
+		result = append(result, attach)
+	}
+	return append(result, changes...)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEventMessage(action EventAction, devPath, deviceType, parent string) controller.EventMessage {
+	return controller.EventMessage{
+		Action: string(action),
+		Devices: []*blockdevice.BlockDevice{
+			{
+				Identifier: blockdevice.Identifier{DevPath: devPath},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: deviceType,
+				},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Parent: parent,
+				},
+			},
+		},
+	}
+}
+
+// getEventDevPaths gets the action and the paths of the devices of each message
+func getEventDevPaths(messages []controller.EventMessage) [][]string {
+	result := make([][]string, 0)
+	for _, msg := range messages {
+		devPaths := []string{msg.Action}
+		for _, device := range msg.Devices {
+			devPaths = append(devPaths, device.DevPath)
+		}
+		result = append(result, devPaths)
+	}
+	return result
+}
+
+func TestCoalesceEvents(t *testing.T) {
+	disk, part := blockdevice.BlockDeviceTypeDisk, blockdevice.BlockDeviceTypePartition
+	tests := map[string]struct {
+		messages []controller.EventMessage
+		want     [][]string
+	}{
+		"partition table re-read": {
+			messages: []controller.EventMessage{
+				newTestEventMessage(DetachEA, "/dev/sda1", part, ""),
+				newTestEventMessage(DetachEA, "/dev/sda2", part, ""),
+				newTestEventMessage(ChangeEA, "/dev/sda", disk, ""),
+				newTestEventMessage(AttachEA, "/dev/sda1", part, "/dev/sda"),
+				newTestEventMessage(AttachEA, "/dev/sda2", part, "/dev/sda"),
+			},
+			want: [][]string{
+				{string(DetachEA), "/dev/sda1", "/dev/sda2"},
+				{string(AttachEA), "/dev/sda1", "/dev/sda2"},
+				{string(ChangeEA), "/dev/sda"},
+			},
+		},
+		"device added and removed within the window": {
+			messages: []controller.EventMessage{
+				newTestEventMessage(AttachEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(ChangeEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(DetachEA, "/dev/sdb", disk, ""),
+			},
+			want: [][]string{
+				{string(DetachEA), "/dev/sdb"},
+			},
+		},
+		"device removed, added and removed within the window": {
+			messages: []controller.EventMessage{
+				newTestEventMessage(DetachEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(AttachEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(DetachEA, "/dev/sdb", disk, ""),
+			},
+			want: [][]string{
+				{string(DetachEA), "/dev/sdb"},
+			},
+		},
+		"change events of a device that was added": {
+			messages: []controller.EventMessage{
+				newTestEventMessage(AttachEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(ChangeEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(ChangeEA, "/dev/sdb", disk, ""),
+			},
+			want: [][]string{
+				{string(AttachEA), "/dev/sdb"},
+			},
+		},
+		"multiple change events of a device": {
+			messages: []controller.EventMessage{
+				newTestEventMessage(ChangeEA, "/dev/sdb", disk, ""),
+				newTestEventMessage(ChangeEA, "/dev/sdb", disk, ""),
+			},
+			want: [][]string{
+				{string(ChangeEA), "/dev/sdb"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getEventDevPaths(coalesceEvents(tt.messages)))
+		})
+	}
+}
+
+func TestEventCoalescer(t *testing.T) {
+	disk, part := blockdevice.BlockDeviceTypeDisk, blockdevice.BlockDeviceTypePartition
+	window := 250 * time.Millisecond
+	start := time.Now()
+
+	ec := newEventCoalescer(window)
+	_, ok := ec.nextDeadline()
+	assert.False(t, ok)
+
+	ec.add(newTestEventMessage(AttachEA, "/dev/sda", disk, ""), start)
+	// the parent of the removed partition is found from the sysfs path
+	removed := newTestEventMessage(DetachEA, "/dev/sda1", part, "")
+	removed.Devices[0].SysPath = "/sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1"
+	ec.add(removed, start.Add(100*time.Millisecond))
+	ec.add(newTestEventMessage(AttachEA, "/dev/sdb1", part, "/dev/sdb"), start.Add(200*time.Millisecond))
+
+	deadline, ok := ec.nextDeadline()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(window), deadline)
+
+	// the window of sdb has not expired yet
+	assert.Equal(t, [][]string{
+		{string(DetachEA), "/dev/sda1"},
+		{string(AttachEA), "/dev/sda"},
+	}, getEventDevPaths(ec.flush(start.Add(window))))
+
+	deadline, ok = ec.nextDeadline()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(200*time.Millisecond+window), deadline)
+
+	assert.Equal(t, [][]string{
+		{string(AttachEA), "/dev/sdb1"},
+	}, getEventDevPaths(ec.flush(deadline)))
+
+	_, ok = ec.nextDeadline()
+	assert.False(t, ok)
+
+	// buffered events are dropped on discard
+	ec.add(newTestEventMessage(AttachEA, "/dev/sdc", disk, ""), start)
+	ec.add(newTestEventMessage(AttachEA, "/dev/sdd", disk, ""), start)
+	assert.Equal(t, 2, ec.discard())
+	_, ok = ec.nextDeadline()
+	assert.False(t, ok)
+	assert.Empty(t, ec.flush(start.Add(window)))
+}
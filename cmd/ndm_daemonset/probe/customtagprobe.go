@@ -26,6 +26,7 @@ import (
 )
 
 const (
+	customTagConfigKey     = "custom-tag-probe"
 	customTagProbePriority = 7
 
 	tagTypePath = "path"
@@ -81,9 +82,11 @@ var customTagProbeRegister = func() {
 		priority:   customTagProbePriority,
 		name:       "Custom Tag Probe",
 		state:      customTagProbeState,
+		configKey:  customTagConfigKey,
 		pi:         tagProbe,
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
 	newRegisterProbe.register()
 }
 
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DecisionPath is the path at which the decisions are served
+	DecisionPath = "/decision"
+
+	// DecisionActionSkipInUse means no action would be taken on the device, as it is in
+	// use by a storage engine or another host
+	DecisionActionSkipInUse = "skip-in-use"
+
+	// uuidBasisStepPrefix is the prefix of the step of the decision trace having the
+	// identifier from which the uuid of the device is generated
+	uuidBasisStepPrefix = "uuid-basis:"
+
+	// decisionQueueTimeout is the time for which a decision request waits for the event
+	// loop to pick it up
+	decisionQueueTimeout = 30 * time.Second
+)
+
+var (
+	// errDeviceNotFound is returned when the device whose decision is requested is not
+	// on the node
+	errDeviceNotFound = errors.New("device not found")
+
+	// errEvaluationUnavailable is returned when the decision cannot be evaluated, as the
+	// event loop is busy or shutting down
+	errEvaluationUnavailable = errors.New("evaluation unavailable")
+)
+
+// decisionRequest is a request to evaluate the decision on the device at the path. The
+// decisions are evaluated in the udev event loop, so that the probes and the hierarchy
+// cache are not used concurrently with the processing of the events.
+type decisionRequest struct {
+	devPath string
+	result  chan decisionResult
+}
+
+// decisionResult is the result of the evaluation of a decision request
+type decisionResult struct {
+	decision *Decision
+	err      error
+}
+
+// decisionRequestChannel is the channel on which the decision requests are sent to the
+// udev event loop
+var decisionRequestChannel = make(chan decisionRequest)
+
+// serveDecisionRequest evaluates the decision on the device in the request, and sends
+// back the result
+func serveDecisionRequest(ctrl *controller.Controller, req decisionRequest) {
+	decision, err := EvaluateDevice(ctrl, req.devPath)
+	req.result <- decisionResult{decision: decision, err: err}
+}
+
+// Decision is the decision that NDM would make on the add event of a device, along with
+// the classification of the device and the reasons for the decision
+type Decision struct {
+	InventoryEntry
+	Transport          string   `json:"transport,omitempty"`
+	InUse              bool     `json:"inUse"`
+	UsedByReason       string   `json:"usedByReason,omitempty"`
+	PartitionTableType string   `json:"partitionTableType,omitempty"`
+	Partitions         []string `json:"partitions,omitempty"`
+	Holders            []string `json:"holders,omitempty"`
+	MountPoints        []string `json:"mountPoints,omitempty"`
+	UUIDBasis          string   `json:"uuidBasis,omitempty"`
+	// Reasons are the branches taken by the decision logic, in order
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// EvaluateDevice evaluates the decision that NDM would make on the add event of the device
+// at the path, by running the decision logic on an evaluation copy of the controller. The
+// path may also be a symlink to the device, eg: /dev/disk/by-id/<id>. It should be called
+// only from the udev event loop.
+func EvaluateDevice(ctrl *controller.Controller, devPath string) (*Decision, error) {
+	if resolved, err := filepath.EvalSymlinks(devPath); err == nil {
+		devPath = resolved
+	}
+	ec := ctrl.NewEvaluationController()
+	devPath = ec.BDHierarchy.Canonicalize(devPath)
+
+	devices, err := listNodeDevices(ec)
+	if err != nil {
+		return nil, err
+	}
+	var device *blockdevice.BlockDevice
+	for _, d := range devices {
+		if d.DevPath == devPath {
+			device = d
+			break
+		}
+	}
+	if device == nil {
+		return nil, fmt.Errorf("%w: %s", errDeviceNotFound, devPath)
+	}
+
+	// only the resources on this node are listed, as the request is served per device
+	bdAPIList, err := ec.ListBlockDeviceResource(false)
+	if err != nil {
+		return nil, err
+	}
+
+	pe := &ProbeEvent{
+		Controller:         ec,
+		deactivatedParents: make(map[string]struct{}),
+	}
+	// the trace is recorded even if it is not enabled on the controller, as it has the
+	// reasons for the decision
+	device.DecisionTrace = &blockdevice.DecisionTrace{}
+	skipReason, evalErr := pe.evaluateDevice(device, bdAPIList)
+
+	// the cache has the details filled during the evaluation, like the uuid
+	bd, ok := ec.BDHierarchy.Get(device.DevPath)
+	if !ok {
+		bd = *device
+	}
+	decision := &Decision{
+		InventoryEntry:     newInventoryEntry(bd, bdAPIList),
+		Transport:          bd.DeviceAttributes.Transport,
+		InUse:              bd.DevUse.InUse,
+		UsedByReason:       bd.DevUse.Reason,
+		PartitionTableType: bd.PartitionInfo.PartitionTableType,
+		Partitions:         bd.DependentDevices.Partitions,
+		Holders:            bd.DependentDevices.Holders,
+		MountPoints:        bd.FSInfo.MountPoint,
+		Reasons:            device.DecisionTrace.Steps(),
+	}
+	decision.UUIDBasis = getUUIDBasis(decision.Reasons)
+	decision.Operations = ec.Evaluation.Operations(bd.DevPath, bd.UUID)
+	decision.Action, decision.Reason = getInventoryAction(decision.Operations, skipReason, evalErr)
+	if decision.Action == InventoryActionSkip && decision.Reason == "" && bd.DevUse.InUse {
+		decision.Action = DecisionActionSkipInUse
+		decision.Reason = string(bd.DevUse.UsedBy)
+	}
+	return decision, nil
+}
+
+// getUUIDBasis gets the identifier from which the uuid of the device was generated, from
+// the steps of the decision trace. Empty string is returned if no uuid was generated.
+func getUUIDBasis(steps []string) string {
+	basis := ""
+	for _, step := range steps {
+		if strings.HasPrefix(step, uuidBasisStepPrefix) {
+			basis = strings.TrimPrefix(step, uuidBasisStepPrefix)
+		}
+	}
+	if basis == "none" {
+		return ""
+	}
+	return basis
+}
+
+// DecisionHandler serves the decision that NDM would make on a device as JSON, for the
+// device at the path in the path query parameter, eg: /decision?path=/dev/sdb. Nothing is
+// modified on the devices or the resources. The decisions are evaluated one at a time by
+// the udev event loop.
+type DecisionHandler struct {
+	Controller *controller.Controller
+}
+
+func (h *DecisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	devPath := r.URL.Query().Get("path")
+	if devPath == "" {
+		http.Error(w, "path of the device is required", http.StatusBadRequest)
+		return
+	}
+
+	req := decisionRequest{
+		devPath: devPath,
+		result:  make(chan decisionResult, 1),
+	}
+	select {
+	case decisionRequestChannel <- req:
+	case <-r.Context().Done():
+		return
+	case <-h.Controller.ShutdownStarted():
+		http.Error(w, errEvaluationUnavailable.Error(), http.StatusServiceUnavailable)
+		return
+	case <-time.After(decisionQueueTimeout):
+		http.Error(w, errEvaluationUnavailable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	result := <-req.result
+	decision, err := result.decision, result.err
+	if errors.Is(err, errDeviceNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errEvaluationUnavailable) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		klog.Errorf("error evaluating device: %s, %v", devPath, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(decision); err != nil {
+		klog.Errorf("error writing decision of device: %s, %v", devPath, err)
+	}
+}
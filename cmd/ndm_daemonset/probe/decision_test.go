@@ -0,0 +1,196 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDecisionHandler(t *testing.T) {
+	devices := []blockdevice.BlockDevice{
+		{
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdb"},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+			},
+		},
+		{
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdc"},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        "0x5000c500a1b2c3d4",
+				Serial:     "ZA1B2C3D",
+			},
+			DevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.ArraySpare,
+				Reason: "spare for md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+			},
+		},
+		{
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdd"},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        "0x5000c500a1b2c3d5",
+				Serial:     "ZA1B2C3E",
+			},
+		},
+	}
+	oldListNodeDevices := listNodeDevices
+	listNodeDevices = func(_ *controller.Controller) ([]*blockdevice.BlockDevice, error) {
+		// the devices are filled during the evaluation, a fresh copy is listed each time
+		list := make([]*blockdevice.BlockDevice, 0, len(devices))
+		for i := range devices {
+			device := devices[i]
+			list = append(list, &device)
+		}
+		return list, nil
+	}
+	defer func() { listNodeDevices = oldListNodeDevices }()
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	ctrl := &controller.Controller{
+		Clientset:      cl,
+		Mutex:          &sync.Mutex{},
+		BDHierarchy:    blockdevice.NewHierarchyCache(nil),
+		NodeAttributes: map[string]string{controller.HostNameKey: "node1"},
+	}
+	wwnUUID, wwnBasis, ok := generateUUID(devices[2])
+	assert.True(t, ok)
+
+	tests := map[string]struct {
+		path           string
+		wantStatus     int
+		wantAction     string
+		wantReason     string
+		wantUUID       string
+		wantUUIDBasis  string
+		wantOperations []string
+	}{
+		"blank disk is partitioned": {
+			path:           "/dev/sdb",
+			wantStatus:     http.StatusOK,
+			wantAction:     InventoryActionPartition,
+			wantOperations: []string{string(controller.CreatePartitionOperation)},
+		},
+		"disk in use is skipped": {
+			path:       "/dev/sdc",
+			wantStatus: http.StatusOK,
+			wantAction: DecisionActionSkipInUse,
+			wantReason: string(blockdevice.ArraySpare),
+		},
+		"identifiable disk is managed": {
+			path:           "/dev/sdd",
+			wantStatus:     http.StatusOK,
+			wantAction:     InventoryActionManage,
+			wantUUID:       wwnUUID,
+			wantUUIDBasis:  wwnBasis,
+			wantOperations: []string{controller.CreateBlockDeviceOperation},
+		},
+		"device not on the node": {
+			path:       "/dev/sdz",
+			wantStatus: http.StatusNotFound,
+		},
+		"path not given": {
+			path:       "",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	handler := &DecisionHandler{Controller: ctrl}
+	// the requests are served by the event loop
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case req := <-decisionRequestChannel:
+				serveDecisionRequest(ctrl, req)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, DecisionPath+"?path="+tt.path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			decision := Decision{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decision))
+			assert.Equal(t, tt.path, decision.DevPath)
+			assert.Equal(t, tt.wantAction, decision.Action)
+			assert.Equal(t, tt.wantReason, decision.Reason)
+			assert.Equal(t, tt.wantUUID, decision.UUID)
+			assert.Equal(t, tt.wantUUIDBasis, decision.UUIDBasis)
+			assert.Equal(t, tt.wantOperations, decision.Operations)
+			assert.NotEmpty(t, decision.Reasons)
+		})
+	}
+
+	// nothing is written to the API server or the cache of the controller
+	bdList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdList))
+	assert.Empty(t, bdList.Items)
+	assert.Empty(t, ctrl.BDHierarchy.Snapshot())
+	assert.Nil(t, ctrl.Evaluation)
+}
+
+func TestGetUUIDBasis(t *testing.T) {
+	tests := map[string]struct {
+		steps []string
+		want  string
+	}{
+		"uuid generated from wwn": {
+			steps: []string{"unmanaged-checks:passed", "uuid-basis:wwn", "action:created"},
+			want:  "wwn",
+		},
+		"uuid not generated": {
+			steps: []string{"unmanaged-checks:passed", "uuid-basis:none", "partitioning:not-allowed"},
+			want:  "",
+		},
+		"device skipped": {
+			steps: []string{"in-use:array-spare", "unmanaged-checks:unmanaged"},
+			want:  "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getUUIDBasis(tt.steps))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package probe
 import (
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 
 	"k8s.io/klog/v2"
 )
@@ -26,18 +27,19 @@ import (
 // removeBlockDeviceFromHierarchyCache removes a block device from the hierarchy.
 // returns true if the device existed in the cache, else returns false
 func (pe *ProbeEvent) removeBlockDeviceFromHierarchyCache(bd blockdevice.BlockDevice) bool {
-	_, ok := pe.Controller.BDHierarchy[bd.DevPath]
+	_, ok := pe.Controller.BDHierarchy.Get(bd.DevPath)
 	if !ok {
 		klog.Infof("Disk %s not in hierarchy", bd.DevPath)
 		// not in hierarchy continue
 		return false
 	}
 	// remove from the hierarchy
-	delete(pe.Controller.BDHierarchy, bd.DevPath)
+	pe.Controller.BDHierarchy.Delete(bd.DevPath)
 	return true
 }
 
-// deleteBlockDevice marks the block device resource as inactive
+// deleteBlockDevice marks the block device resource as inactive, or deletes it,
+// as per the removal policy
 // The following cases are handled
 //	1. Device using legacy UUID
 //	2. Device using GPT UUID
@@ -49,13 +51,15 @@ func (pe *ProbeEvent) deleteBlockDevice(bd blockdevice.BlockDevice, bdAPIList *a
 		return nil
 	}
 
-	// try with gpt uuid
-	if uuid, ok := generateUUID(bd); ok {
-		existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
-		if existingBD != nil {
-			pe.Controller.DeactivateBlockDevice(*existingBD)
-			klog.V(4).Infof("deactivated device: %s, using GPT UUID", bd.DevPath)
-			return nil
+	// try with gpt uuid, of any version
+	if uuids, ok := pe.generateUUIDsOfAllVersions(bd); ok {
+		for _, uuid := range uuids {
+			existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+			if existingBD != nil {
+				pe.Controller.RemoveBlockDevice(*existingBD)
+				klog.V(4).Infof("removed device: %s, using GPT UUID", bd.DevPath)
+				return nil
+			}
 		}
 		// uuid could be generated, but the disk may be using the legacy scheme
 	}
@@ -64,16 +68,22 @@ func (pe *ProbeEvent) deleteBlockDevice(bd blockdevice.BlockDevice, bdAPIList *a
 	if partUUID, ok := generateUUIDFromPartitionTable(bd); ok {
 		existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, partUUID)
 		if existingBD != nil {
-			pe.Controller.DeactivateBlockDevice(*existingBD)
-			klog.V(4).Infof("deactivated device: %s, using partition table UUID", bd.DevPath)
+			pe.Controller.RemoveBlockDevice(*existingBD)
+			klog.V(4).Infof("removed device: %s, using partition table UUID", bd.DevPath)
 			return nil
 		}
 	}
 
-	// try with FSUUID annotation
-	if existingBD := getExistingBDWithFsUuid(bd, bdAPIList); existingBD != nil {
-		pe.Controller.DeactivateBlockDevice(*existingBD)
-		klog.V(4).Infof("deactivated device: %s, using FS UUID annotation", bd.DevPath)
+	// try with FSUUID annotation. It is skipped if the device is a clone sharing the
+	// annotation with other devices, as the blockdevice of the device cannot be determined.
+	fsUUIDBD, candidates := getExistingBDWithFsUuid(bd, bdAPIList, pe.Controller.NodeAttributes[controller.NodeNameKey])
+	if len(candidates) > 0 {
+		klog.Warningf("device: %s has filesystem uuid: %s shared by the blockdevices %v, not using it to find the blockdevice",
+			bd.DevPath, bd.FSInfo.FileSystemUUID, candidates)
+	}
+	if fsUUIDBD != nil {
+		pe.Controller.RemoveBlockDevice(*fsUUIDBD)
+		klog.V(4).Infof("removed device: %s, using FS UUID annotation", bd.DevPath)
 		return nil
 	}
 
@@ -82,8 +92,8 @@ func (pe *ProbeEvent) deleteBlockDevice(bd blockdevice.BlockDevice, bdAPIList *a
 	// Therefore the search result is used only if the device is not a partition.
 	if existingBD := getExistingBDWithPartitionUUID(bd, bdAPIList); bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition &&
 		existingBD != nil {
-		pe.Controller.DeactivateBlockDevice(*existingBD)
-		klog.V(4).Infof("deactivated device: %s, using Partition UUID annotation", bd.DevPath)
+		pe.Controller.RemoveBlockDevice(*existingBD)
+		klog.V(4).Infof("removed device: %s, using Partition UUID annotation", bd.DevPath)
 		return nil
 	}
 
@@ -91,8 +101,8 @@ func (pe *ProbeEvent) deleteBlockDevice(bd blockdevice.BlockDevice, bdAPIList *a
 	legacyUUID, _ := generateLegacyUUID(bd)
 	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, legacyUUID)
 	if existingBD != nil {
-		pe.Controller.DeactivateBlockDevice(*existingBD)
-		klog.V(4).Infof("deactivated device: %s, using legacy UUID", bd.DevPath)
+		pe.Controller.RemoveBlockDevice(*existingBD)
+		klog.V(4).Infof("removed device: %s, using legacy UUID", bd.DevPath)
 		return nil
 	}
 
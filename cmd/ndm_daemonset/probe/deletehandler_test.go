@@ -81,11 +81,11 @@ func TestRemoveBlockDeviceFromHierarchyCache(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			pe := &ProbeEvent{
 				Controller: &controller.Controller{
-					BDHierarchy: tt.cache,
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.cache),
 				},
 			}
 			gotOk := pe.removeBlockDeviceFromHierarchyCache(tt.bd)
-			assert.Equal(t, tt.wantCache, pe.Controller.BDHierarchy)
+			assert.Equal(t, tt.wantCache, pe.Controller.BDHierarchy.Snapshot())
 			assert.Equal(t, tt.wantOk, gotOk)
 		})
 	}
@@ -192,8 +192,8 @@ func TestDeleteBlockDevice(t *testing.T) {
 		},
 	}
 
-	fakePhysicalDiskGPTBasedUUID, _ := generateUUID(physicalDisk)
-	fakePhysicalDiskGPTBasedUUIDPart1, _ := generateUUID(physicalDiskPart1)
+	fakePhysicalDiskGPTBasedUUID, _, _ := generateUUID(physicalDisk)
+	fakePhysicalDiskGPTBasedUUIDPart1, _, _ := generateUUID(physicalDiskPart1)
 	fakePhysicalDiskLegacyUUID, _ := generateLegacyUUID(physicalDisk)
 	fakecstorVirtualDiskLegacyUUID, _ := generateLegacyUUID(virtualDiskUsedByCstor1)
 	fakelocalpvVirtualDiskLegacyUUID, _ := generateLegacyUUID(virtualDiskUsedByLocalPV1)
@@ -436,11 +436,11 @@ func TestDeleteBlockDevice(t *testing.T) {
 			cl := fake.NewFakeClientWithScheme(s)
 			ctrl := &controller.Controller{
 				Clientset:   cl,
-				BDHierarchy: make(blockdevice.Hierarchy),
+				BDHierarchy: blockdevice.NewHierarchyCache(nil),
 			}
 
 			// add the bd to cache so that removing from cache does not error out.
-			ctrl.BDHierarchy[bd.DevPath] = bd
+			ctrl.BDHierarchy.Set(bd.DevPath, bd)
 
 			// initialize client with all the bd resources
 			for _, bdAPI := range bdAPIList.Items {
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// discardDevice is a variable, so that it can be replaced in tests
+var discardDevice = partition.Discard
+
+// rescan is a variable, so that it can be replaced in tests
+var rescan = Rescan
+
+// rescanAfterDiscard is a variable, so that it can be replaced in tests
+var rescanAfterDiscard = retryRescan
+
+// rescanBackoff is the backoff for retrying the rescan after a discard. The rescan fails
+// if a scan is in progress, which may have found the disk while it was being discarded.
+var rescanBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    6,
+}
+
+// retryRescan rescans the devices, so that a discarded disk is partitioned. The rescan is
+// retried with rescanBackoff, and the error of the last attempt is returned if it fails.
+func retryRescan(c *controller.Controller) error {
+	var rescanErr error
+	err := wait.ExponentialBackoff(rescanBackoff, func() (bool, error) {
+		rescanErr = rescan(c)
+		return rescanErr == nil, nil
+	})
+	if err != nil {
+		return rescanErr
+	}
+	return nil
+}
+
+// discardTracker tracks the discards of blank disks, keyed by the path of the disk. A
+// discard can take minutes on a large disk, and is run off the event loop. The disk is
+// partitioned once its discard is complete.
+type discardTracker struct {
+	mutex sync.Mutex
+	// inFlight are the disks being discarded
+	inFlight map[string]struct{}
+	// done are the disks discarded, which are yet to be partitioned
+	done map[string]struct{}
+}
+
+func newDiscardTracker() *discardTracker {
+	return &discardTracker{
+		inFlight: make(map[string]struct{}),
+		done:     make(map[string]struct{}),
+	}
+}
+
+// start records that the disk is being discarded. false is returned if the disk is
+// already being discarded.
+func (dt *discardTracker) start(devPath string) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	if _, ok := dt.inFlight[devPath]; ok {
+		return false
+	}
+	dt.inFlight[devPath] = struct{}{}
+	return true
+}
+
+// finish records that the discard of the disk is complete
+func (dt *discardTracker) finish(devPath string) {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	delete(dt.inFlight, devPath)
+	dt.done[devPath] = struct{}{}
+}
+
+// isInFlight checks if the disk is being discarded
+func (dt *discardTracker) isInFlight(devPath string) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	_, ok := dt.inFlight[devPath]
+	return ok
+}
+
+// takeDone checks if the discard of the disk is complete, and removes the disk from the
+// tracker, so that the disk is discarded only once before it is partitioned
+func (dt *discardTracker) takeDone(devPath string) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	if _, ok := dt.done[devPath]; !ok {
+		return false
+	}
+	delete(dt.done, devPath)
+	return true
+}
+
+// getDiscards gets the tracker of the discards of blank disks, creating it on first use
+func (pe *ProbeEvent) getDiscards() *discardTracker {
+	if pe.discards == nil {
+		pe.discards = newDiscardTracker()
+	}
+	return pe.discards
+}
+
+// discardBlankDisk discards all the blocks of a blank disk that is about to be
+// partitioned, if enabled and supported by the disk. The discard is run in the
+// background, and true is returned if partitioning has to be deferred till it is
+// complete. A rescan is triggered once the discard is complete, so that the disk is
+// partitioned. If the rescan fails, the disk is partitioned on its next event. It is best
+// effort, the disk is partitioned even if the discard fails.
+func (pe *ProbeEvent) discardBlankDisk(bd *blockdevice.BlockDevice) bool {
+	if !pe.Controller.DiscardBeforePartition || !bd.DeviceAttributes.DiscardSupported {
+		return false
+	}
+	discards := pe.getDiscards()
+	if discards.takeDone(bd.DevPath) {
+		return false
+	}
+	if discards.isInFlight(bd.DevPath) {
+		return true
+	}
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.DiscardOperation, bd.DevPath) {
+		return false
+	}
+	if !discards.start(bd.DevPath) {
+		return true
+	}
+	klog.Infof("discarding blocks of blank device: %s before partitioning", bd.DevPath)
+	auditTarget := getAuditTarget(*bd)
+	devPath := bd.DevPath
+	go func() {
+		err := discardDevice(devPath)
+		pe.Controller.Audit(controller.DiscardOperation, auditTarget, blankDiskAuditCause, err)
+		if err != nil {
+			klog.Warningf("unable to discard blocks of device: %s, err: %v", devPath, err)
+		}
+		discards.finish(devPath)
+		if err := rescanAfterDiscard(pe.Controller); err != nil {
+			klog.Warningf("unable to rescan after discard of device: %s, it is partitioned on its next event, err: %v",
+				devPath, err)
+		}
+	}()
+	return true
+}
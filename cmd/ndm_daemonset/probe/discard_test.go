@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestDiscardBlankDisk(t *testing.T) {
+	tests := map[string]struct {
+		discardBeforePartition bool
+		safeMode               bool
+		discardSupported       bool
+		discardErr             error
+		wantDiscard            bool
+	}{
+		"discard disabled": {
+			discardSupported: true,
+		},
+		"device does not support discard": {
+			discardBeforePartition: true,
+		},
+		"device supports discard": {
+			discardBeforePartition: true,
+			discardSupported:       true,
+			wantDiscard:            true,
+		},
+		"discard blocked by safe mode": {
+			discardBeforePartition: true,
+			safeMode:               true,
+			discardSupported:       true,
+		},
+		"failed discard is ignored": {
+			discardBeforePartition: true,
+			discardSupported:       true,
+			discardErr:             fmt.Errorf("operation not supported"),
+			wantDiscard:            true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			discarded := make(chan string, 1)
+			origDiscardDevice, origRescanAfterDiscard := discardDevice, rescanAfterDiscard
+			defer func() { discardDevice, rescanAfterDiscard = origDiscardDevice, origRescanAfterDiscard }()
+			discardDevice = func(devPath string) error {
+				discarded <- devPath
+				return tt.discardErr
+			}
+			rescanned := make(chan struct{}, 1)
+			rescanAfterDiscard = func(c *controller.Controller) error {
+				rescanned <- struct{}{}
+				return nil
+			}
+
+			auditBuf := &bytes.Buffer{}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:              CreateFakeClient(t),
+					DiscardBeforePartition: tt.discardBeforePartition,
+					SafeMode:               tt.safeMode,
+					AuditLog:               controller.NewAuditLog(auditBuf, nil),
+				},
+			}
+			bd := &blockdevice.BlockDevice{}
+			bd.DevPath = "/dev/sdb"
+			bd.DeviceAttributes.DiscardSupported = tt.discardSupported
+
+			deferred := pe.discardBlankDisk(bd)
+			assert.Equal(t, tt.wantDiscard, deferred)
+
+			var wantRecords []controller.AuditRecord
+			if tt.wantDiscard {
+				assert.Equal(t, "/dev/sdb", <-discarded)
+				<-rescanned
+				wantRecords = []controller.AuditRecord{{
+					Operation: string(controller.DiscardOperation),
+					Device:    "/dev/sdb",
+					Trigger:   controller.AuditTriggerDeviceAdded,
+					Result:    controller.AuditResultSuccess,
+				}}
+				if tt.discardErr != nil {
+					wantRecords[0].Result = controller.AuditResultFailure
+				}
+				// the disk is partitioned after the discard, without discarding it again
+				assert.False(t, pe.discardBlankDisk(bd))
+			}
+			assert.Empty(t, discarded)
+			assertAuditRecords(t, auditBuf, wantRecords)
+		})
+	}
+}
+
+func TestDiscardBlankDiskInFlight(t *testing.T) {
+	origDiscardDevice, origRescanAfterDiscard := discardDevice, rescanAfterDiscard
+	defer func() { discardDevice, rescanAfterDiscard = origDiscardDevice, origRescanAfterDiscard }()
+	release := make(chan struct{})
+	discardCount := 0
+	discardDevice = func(devPath string) error {
+		discardCount++
+		<-release
+		return nil
+	}
+	rescanned := make(chan struct{}, 1)
+	rescanAfterDiscard = func(c *controller.Controller) error {
+		rescanned <- struct{}{}
+		return nil
+	}
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:              CreateFakeClient(t),
+			DiscardBeforePartition: true,
+			AuditLog:               controller.NewAuditLog(&bytes.Buffer{}, nil),
+		},
+	}
+	bd := &blockdevice.BlockDevice{}
+	bd.DevPath = "/dev/sdb"
+	bd.DeviceAttributes.DiscardSupported = true
+
+	assert.True(t, pe.discardBlankDisk(bd))
+	// partitioning is deferred while the discard is in flight, and the disk is not
+	// discarded again
+	assert.True(t, pe.discardBlankDisk(bd))
+	close(release)
+	<-rescanned
+	assert.False(t, pe.discardBlankDisk(bd))
+	assert.Equal(t, 1, discardCount)
+}
+
+func TestRetryRescan(t *testing.T) {
+	tests := map[string]struct {
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		"rescan succeeds": {
+			failures:     0,
+			wantAttempts: 1,
+		},
+		"rescan is retried while a scan is in progress": {
+			failures:     2,
+			wantAttempts: 3,
+		},
+		"rescan fails after all the attempts": {
+			failures:     10,
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			origRescan, origRescanBackoff := rescan, rescanBackoff
+			defer func() { rescan, rescanBackoff = origRescan, origRescanBackoff }()
+			rescanBackoff = wait.Backoff{Duration: time.Millisecond, Steps: 4}
+			attempts := 0
+			rescan = func(c *controller.Controller) error {
+				attempts++
+				if attempts <= tt.failures {
+					return fmt.Errorf("Scan is in progress")
+				}
+				return nil
+			}
+
+			err := retryRescan(&controller.Controller{})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package probe
 
 import (
+	"context"
 	"errors"
 
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/features"
@@ -42,11 +44,55 @@ const (
 
 var (
 	ErrNeedRescan = errors.New("need rescan")
+
+	// ErrUnreachableState is returned when the device and its BlockDevice resource are
+	// in a state that should never be reached
+	ErrUnreachableState = errors.New("unreachable state")
+
+	// ErrParentNotFound is returned when the parent of a partition is not present in the
+	// hierarchy cache
+	ErrParentNotFound = errors.New("cannot get parent device")
+)
+
+const (
+	// skipReasonKernelDevice is used for devices like zram, used by kernel subsystems
+	skipReasonKernelDevice = "kernel-device"
+	// skipReasonFiltered is used for devices excluded by the filters
+	skipReasonFiltered = "filtered"
+	// skipReasonOSDisk is used for the disk having a partition used by the os, like a
+	// boot, root or swap partition, and its partitions. The reason is boot-disk, as it
+	// was when only the boot partitions were checked, so that the inventory is unchanged.
+	skipReasonOSDisk = "boot-disk"
+	// skipReasonNotReady is used for devices that did not become ready to accept IO
+	skipReasonNotReady = "not-ready"
 )
 
 // ProbeEvent struct contain a copy of controller it will update disk resources
 type ProbeEvent struct {
 	Controller *controller.Controller
+	// deactivatedParents is the set of uuids of the parent devices deactivated in the
+	// batch being processed. The partitions of a disk arrive together on a partition
+	// table re-read, and the parent needs to be deactivated only once for all of them.
+	deactivatedParents map[string]struct{}
+	// wipedDevices is the signature each device had before it was wiped, keyed by the
+	// path of the device, for the devices found blank in the batch being processed
+	wipedDevices map[string]string
+	// fingerprints is the content fingerprint of each device, keyed by the path of the
+	// device, for the devices fingerprinted in the batch being processed
+	fingerprints map[string]string
+	// notReady are the devices deferred as they were not ready to accept IO, keyed by
+	// the path of the device. They are processed by the readiness retry once ready.
+	notReady map[string]*notReadyDevice
+	// inFlightPartitions are the disks partitioned by NDM whose partition add event is
+	// yet to be processed
+	inFlightPartitions *partitionTracker
+	// partitionFailures are the disks on which partitioning failed
+	partitionFailures *partitionFailureTracker
+	// removableDisks are the managed removable disks, keyed by the path of the disk, with
+	// their devices deferred till the disk has been present for the removable device delay
+	removableDisks map[string]*removableDisk
+	// discards are the blank disks being discarded before they are partitioned
+	discards *discardTracker
 }
 
 // addBlockDeviceEvent fill block device details from different probes and push it to etcd
@@ -63,20 +109,71 @@ func (pe *ProbeEvent) addBlockDeviceEvent(msg controller.EventMessage) {
 
 	isNeedRescan := false
 	erroredDevices := make([]string, 0)
+	// the uuids of the devices listed by a full scan, whose resources are not removed
+	// as they are still present on the node
+	presentUUIDs := make([]string, 0)
+	allProcessed := true
+	pe.deactivatedParents = make(map[string]struct{})
+	pe.wipedDevices = make(map[string]string)
+	pe.fingerprints = make(map[string]string)
+	pe.Controller.ResetLocalBlockPVs()
+	pe.Controller.ResetSCSIReservations()
+
+	// the progress of a full scan is recorded, so that the devices already processed
+	// need not be probed again if the scan is interrupted and resumed.
+	var checkpoint *controller.RescanCheckpoint
+	if msg.FullScan && pe.Controller.RescanCheckpoint != nil {
+		checkpoint = pe.Controller.RescanCheckpoint
+		checkpoint.Begin()
+	}
 
 	// iterate through each block device and perform the add/update operation
 	for _, device := range msg.Devices {
-		klog.Infof("Processing details for %s", device.DevPath)
-		pe.Controller.FillBlockDeviceDetails(device, msg.RequestedProbes...)
+		pe.canonicalizeDevPath(device)
+		// a device that is not ready to accept IO is not probed, as it may be
+		// misclassified as blank and partitioned. It is processed once it is ready.
+		if !pe.isReadyOrDefer(device) {
+			allProcessed = false
+			continue
+		}
+		scanned := *device
+		if cached, ok := lookupRescanCheckpoint(checkpoint, scanned); ok {
+			// the usage of the device may have changed since it was checkpointed, only
+			// the expensive probes are skipped
+			klog.Infof("Using details of %s from rescan checkpoint", device.DevPath)
+			if probes := pe.getUncheckpointedProbes(msg.RequestedProbes); len(probes) > 0 {
+				pe.Controller.FillBlockDeviceDetails(context.TODO(), device, probes...)
+			}
+			cached.Restore(device)
+		} else {
+			klog.Infof("Processing details for %s", device.DevPath)
+			pe.Controller.FillBlockDeviceDetails(context.TODO(), device, msg.RequestedProbes...)
+		}
 
 		// add all devices to the hierarchy cache, irrespective of whether they will be
 		// filtered at a later stage. This is done so that a complete disk hierarchy is available
 		// at all times by NDM. It also helps in device processing when complex filter configurations
 		// are provided. Ref: https://github.com/openebs/openebs/issues/3321
+		// The cached state is compared before the update, to find the devices that were wiped.
+		pe.recordWipedDevice(*device)
 		pe.addBlockDeviceToHierarchyCache(*device)
+		if msg.FullScan {
+			presentUUIDs = append(presentUUIDs, pe.getPresentDeviceUUIDs(*device)...)
+		}
 
-		// if ApplyFilter returns true then we process the event further
-		if !pe.Controller.ApplyFilter(device) {
+		if skipReason := pe.getSkipReason(device); skipReason != "" {
+			// the os partition may be found after the other devices on the os disk
+			// got resources, which are deactivated.
+			if skipReason == skipReasonOSDisk && isGPTBasedUUIDEnabled {
+				pe.deactivateOSDiskBlockDevices(*device, bdAPIList)
+			}
+			recordRescanCheckpoint(checkpoint, scanned, *device)
+			continue
+		}
+		// a removable disk and its partitions are managed only once the disk has been
+		// present for the removable device delay, so that flapping media are not managed
+		if !pe.isRemovableSettledOrDefer(device) {
+			allProcessed = false
 			continue
 		}
 		klog.Infof("Processed details for %s", device.DevPath)
@@ -87,13 +184,21 @@ func (pe *ProbeEvent) addBlockDeviceEvent(msg controller.EventMessage) {
 				continue
 			}
 			err := pe.addBlockDevice(*device, bdAPIList)
+			if reason, ok := getInternalErrorReason(err); ok &&
+				pe.Controller.InternalErrorPolicy == controller.ReportInternalErrors {
+				pe.Controller.ReportInternalError(device.DevPath, reason, err)
+				allProcessed = false
+				continue
+			}
 			if err != nil {
 				isNeedRescan = true
 				if !errors.Is(err, ErrNeedRescan) {
 					erroredDevices = append(erroredDevices, device.DevPath)
 					klog.Error(err)
 				}
+				continue
 			}
+			recordRescanCheckpoint(checkpoint, scanned, *device)
 		} else {
 			// if GPTBasedUUID is disabled and the device type is partition,
 			// the event can be skipped.
@@ -108,15 +213,134 @@ func (pe *ProbeEvent) addBlockDeviceEvent(msg controller.EventMessage) {
 			if err != nil {
 				isNeedRescan = true
 				klog.Error(err)
+				continue
 			}
+			recordRescanCheckpoint(checkpoint, scanned, *device)
 		}
 	}
 
+	// the scan is complete only if all the devices were processed, else the rescan
+	// triggered below resumes it.
+	if checkpoint != nil {
+		if isNeedRescan {
+			checkpoint.Flush()
+		} else {
+			checkpoint.Complete()
+		}
+	}
+
+	// the resources deactivated as stale by the full scan, that none of the devices
+	// activated again, belong to devices removed from the node.
+	if msg.FullScan && !isNeedRescan && allProcessed {
+		pe.Controller.RemoveStaleBlockDevices(msg.StaleUUIDs, presentUUIDs)
+	}
+
+	// resources created by older versions of NDM may be missing the uuid scheme
+	// annotation, which can be repaired once the devices on the node are known.
+	if isGPTBasedUUIDEnabled {
+		uuidSchemeRepairOnce.Do(pe.repairUUIDSchemeAnnotations)
+	}
+
+	pe.updateHierarchyMetrics()
+
 	if isNeedRescan {
 		go Rescan(pe.Controller)
 	}
 }
 
+// getPresentDeviceUUIDs gets all the uuids by which the resource of a device listed by a
+// full scan can be identified, i.e the pinned uuid, the uuids of all the versions of the
+// uuid algorithm, and the partition table and legacy uuids
+func (pe *ProbeEvent) getPresentDeviceUUIDs(bd blockdevice.BlockDevice) []string {
+	uuids := make([]string, 0)
+	if pinnedUUID, ok := pe.Controller.GetPinnedUUID(bd); ok {
+		uuids = append(uuids, pinnedUUID)
+	}
+	if versionUUIDs, ok := pe.generateUUIDsOfAllVersions(bd); ok {
+		uuids = append(uuids, versionUUIDs...)
+	}
+	if partUUID, ok := generateUUIDFromPartitionTable(bd); ok {
+		uuids = append(uuids, partUUID)
+	}
+	legacyUUID, _ := generateLegacyUUID(bd)
+	return append(uuids, legacyUUID)
+}
+
+// checkpointedProbes are the probes whose details are recorded in the rescan checkpoint.
+// They are expensive, and only read the attributes of the device that do not change while
+// it is present, so they need not be run again when an interrupted rescan is resumed.
+var checkpointedProbes = []string{smartProbeName, seachestProbeName}
+
+// getUncheckpointedProbes gets the names of the probes, out of the requested probes, that
+// are run again on a device whose details are recorded in the rescan checkpoint
+func (pe *ProbeEvent) getUncheckpointedProbes(requestedProbes []string) []string {
+	probes := make([]string, 0)
+	for _, probe := range pe.Controller.ListProbe(requestedProbes...) {
+		if !util.Contains(checkpointedProbes, probe.Name) {
+			probes = append(probes, probe.Name)
+		}
+	}
+	return probes
+}
+
+// lookupRescanCheckpoint gets the details of the device from the checkpoint of the
+// full scan, if the device was processed by the interrupted scan being resumed
+func lookupRescanCheckpoint(checkpoint *controller.RescanCheckpoint, scanned blockdevice.BlockDevice) (controller.CheckpointedDevice, bool) {
+	if checkpoint == nil {
+		return controller.CheckpointedDevice{}, false
+	}
+	return checkpoint.Lookup(scanned)
+}
+
+// recordRescanCheckpoint records the device as processed in the checkpoint of the full scan
+func recordRescanCheckpoint(checkpoint *controller.RescanCheckpoint, scanned, probed blockdevice.BlockDevice) {
+	if checkpoint == nil {
+		return
+	}
+	checkpoint.Record(scanned, probed)
+}
+
+// getSkipReason gets the reason for which the device is not processed further after
+// filling its details. Empty reason is returned if the device has to be processed.
+func (pe *ProbeEvent) getSkipReason(device *blockdevice.BlockDevice) string {
+	// devices like zram are used by kernel subsystems as swap / cache and should never
+	// be managed, irrespective of the filter configuration
+	if util.Contains(blockdevice.KernelPseudoDeviceTypes, device.DeviceAttributes.DeviceType) {
+		klog.Infof("device: %s of type: %s is used by the kernel, skipping it",
+			device.DevPath, device.DeviceAttributes.DeviceType)
+		return skipReasonKernelDevice
+	}
+
+	// if ApplyFilter returns true then we process the event further
+	if !pe.Controller.ApplyFilter(device) {
+		return skipReasonFiltered
+	}
+
+	// the disk having a boot, root or swap partition is the os disk, which should be
+	// excluded even if the os disk filter missed it.
+	if pe.isOnOSDisk(*device) {
+		klog.Infof("device: %s is on a disk with an os partition, skipping it as os disk", device.DevPath)
+		return skipReasonOSDisk
+	}
+	return ""
+}
+
+// canonicalizeDevPath replaces the /dev/mapper path of a dm device in an event
+// with its kernel path, so that the events for /dev/dm-N and /dev/mapper/<name>
+// refer to the same device in the hierarchy. The mapper path is kept in the
+// device mapper information of the device.
+func (pe *ProbeEvent) canonicalizeDevPath(device *blockdevice.BlockDevice) {
+	devPath := pe.Controller.BDHierarchy.Canonicalize(device.DevPath)
+	if devPath == device.DevPath {
+		return
+	}
+	klog.V(4).Infof("device: %s resolved to %s", device.DevPath, devPath)
+	if device.DMInfo.DevMapperPath == "" {
+		device.DMInfo.DevMapperPath = device.DevPath
+	}
+	device.DevPath = devPath
+}
+
 // deleteBlockDeviceEvent deactivate blockdevice resource using uuid from etcd
 func (pe *ProbeEvent) deleteBlockDeviceEvent(msg controller.EventMessage) {
 	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
@@ -128,6 +352,9 @@ func (pe *ProbeEvent) deleteBlockDeviceEvent(msg controller.EventMessage) {
 	isGPTBasedUUIDEnabled := features.FeatureGates.IsEnabled(features.GPTBasedUUID)
 
 	for _, device := range msg.Devices {
+		pe.canonicalizeDevPath(device)
+		pe.forgetNotReadyDevice(device.DevPath)
+		pe.forgetRemovableDevice(device.DevPath)
 		if isGPTBasedUUIDEnabled {
 			_ = pe.deleteBlockDevice(*device, bdAPIList)
 		} else {
@@ -141,10 +368,12 @@ func (pe *ProbeEvent) deleteBlockDeviceEvent(msg controller.EventMessage) {
 				isDeactivated = false
 				continue
 			}
-			pe.Controller.DeactivateBlockDevice(*existingBlockDeviceResource)
+			pe.Controller.RemoveBlockDevice(*existingBlockDeviceResource)
 		}
 	}
 
+	pe.updateHierarchyMetrics()
+
 	// rescan only if GPT based UUID is disabled.
 	if !isDeactivated && !isGPTBasedUUIDEnabled {
 		go Rescan(pe.Controller)
@@ -154,8 +383,10 @@ func (pe *ProbeEvent) deleteBlockDeviceEvent(msg controller.EventMessage) {
 func (pe *ProbeEvent) changeBlockDeviceEvent(msg controller.EventMessage) {
 	var err error
 
+	pe.Controller.ResetLocalBlockPVs()
+	pe.Controller.ResetSCSIReservations()
 	if msg.AllBlockDevices {
-		for _, bd := range pe.Controller.BDHierarchy {
+		for _, bd := range pe.Controller.BDHierarchy.Snapshot() {
 			klog.Infof("Processing changes for %s", bd.DevPath)
 			err = pe.changeBlockDevice(&bd, msg.RequestedProbes...)
 			if err != nil {
@@ -169,7 +400,8 @@ func (pe *ProbeEvent) changeBlockDeviceEvent(msg controller.EventMessage) {
 		// The bd in `msg.Devices` mostly doesn't contain any information other than the
 		// DevPath. Get corresponding bd from cache since cache will have latest info
 		// for the bd.
-		cacheBD, ok := pe.Controller.BDHierarchy[bd.DevPath]
+		pe.canonicalizeDevPath(bd)
+		cacheBD, ok := pe.Controller.BDHierarchy.Get(bd.DevPath)
 		klog.Infof("Processing changes for %s", cacheBD.DevPath)
 		if ok {
 			err = pe.changeBlockDevice(&cacheBD, msg.RequestedProbes...)
@@ -187,6 +419,61 @@ func (pe *ProbeEvent) changeBlockDeviceEvent(msg controller.EventMessage) {
 
 }
 
+// updateHierarchyMetrics updates the metrics of the hierarchy cache by comparing the
+// devices in the cache with the BlockDevice resources of this node. The resources are
+// listed after the batch, so that the divergence is computed afresh on every batch and
+// is reset once the cache and the resources agree.
+func (pe *ProbeEvent) updateHierarchyMetrics() {
+	if pe.Controller.Metrics == nil {
+		return
+	}
+	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for the hierarchy metrics: %v", err)
+		return
+	}
+	bdCount, hierarchyOnly, etcdOnly := getHierarchyDivergence(pe.Controller.BDHierarchy.Snapshot(),
+		bdAPIList, pe.Controller.NodeAttributes[controller.HostNameKey])
+	pe.Controller.Metrics.SetHierarchyMetrics(pe.Controller.BDHierarchy.Len(), bdCount, hierarchyOnly, etcdOnly)
+}
+
+// getHierarchyDivergence gets the no. of BlockDevice resources of the node with the given
+// hostname, the no. of devices in the hierarchy that do not have a resource and the no. of
+// active resources whose device is not in the hierarchy.
+func getHierarchyDivergence(hierarchy blockdevice.Hierarchy, bdAPIList *apis.BlockDeviceList,
+	hostName string) (bdCount, hierarchyOnly, etcdOnly int) {
+	bdPaths := make(map[string]struct{})
+	for _, bdAPI := range bdAPIList.Items {
+		if bdAPI.Labels[controller.KubernetesHostNameLabel] != hostName {
+			continue
+		}
+		bdCount++
+		bdPaths[bdAPI.Spec.Path] = struct{}{}
+		if _, ok := hierarchy[bdAPI.Spec.Path]; !ok &&
+			bdAPI.Status.State == apis.BlockDeviceActive {
+			etcdOnly++
+		}
+	}
+	for devPath := range hierarchy {
+		if _, ok := bdPaths[devPath]; !ok {
+			hierarchyOnly++
+		}
+	}
+	return bdCount, hierarchyOnly, etcdOnly
+}
+
+// getInternalErrorReason gets the reason to be used in the event and metrics if the
+// error is a non-fatal internal error
+func getInternalErrorReason(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrUnreachableState):
+		return "UnreachableState", true
+	case errors.Is(err, ErrParentNotFound):
+		return "ParentNotFound", true
+	}
+	return "", false
+}
+
 // isParentOrSlaveDevice check if any of the errored device is a parent / slave to the
 // given blockdevice
 func isParentOrSlaveDevice(bd blockdevice.BlockDevice, erroredDevices []string) bool {
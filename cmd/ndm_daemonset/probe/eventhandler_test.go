@@ -17,9 +17,12 @@ limitations under the License.
 package probe
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
@@ -64,8 +67,8 @@ var (
 )
 
 var (
-	fakeBD1Uuid, _ = generateUUID(fakeBD1)
-	fakeBD2Uuid, _ = generateUUID(fakeBD2)
+	fakeBD1Uuid, _, _ = generateUUID(fakeBD1)
+	fakeBD2Uuid, _, _ = generateUUID(fakeBD2)
 )
 
 func mockEmptyBlockDeviceCr() apis.BlockDevice {
@@ -135,7 +138,7 @@ func TestAddBlockDeviceEvent(t *testing.T) {
 		Filters:        make([]*controller.Filter, 0),
 		Probes:         make([]*controller.Probe, 0),
 		NodeAttributes: nodeAttributes,
-		BDHierarchy:    make(blockdevice.Hierarchy),
+		BDHierarchy:    blockdevice.NewHierarchyCache(nil),
 	}
 	//add one filter
 	filter := &fakeFilter{}
@@ -203,6 +206,115 @@ func TestAddBlockDeviceEvent(t *testing.T) {
 	}
 }
 
+// countingProbe records the devices probed by it
+type countingProbe struct {
+	probed []string
+}
+
+func (p *countingProbe) Start() {}
+
+func (p *countingProbe) FillBlockDeviceDetails(bd *blockdevice.BlockDevice) {
+	p.probed = append(p.probed, bd.DevPath)
+	bd.DeviceAttributes.Model = fakeModel
+	bd.DeviceAttributes.Vendor = fakeVendor
+}
+
+// usageProbe records the devices probed by it, like a probe of the usage of the device
+type usageProbe struct {
+	probed []string
+}
+
+func (p *usageProbe) Start() {}
+
+func (p *usageProbe) FillBlockDeviceDetails(bd *blockdevice.BlockDevice) {
+	p.probed = append(p.probed, bd.DevPath)
+	bd.Labels["usage-probed"] = "true"
+}
+
+func TestAddBlockDeviceEventResumesRescan(t *testing.T) {
+	origCheckpointedProbes := checkpointedProbes
+	defer func() { checkpointedProbes = origCheckpointedProbes }()
+	checkpointedProbes = []string{"counting-probe"}
+
+	checkpointFile := filepath.Join(t.TempDir(), "rescan-checkpoint.json")
+	newScannedDevices := func() []*blockdevice.BlockDevice {
+		return []*blockdevice.BlockDevice{
+			{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdX"},
+				DeviceAttributes: blockdevice.DeviceAttribute{WWN: "fake-WWN-X", Serial: "fake-serial-X"},
+			},
+			{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdY"},
+				DeviceAttributes: blockdevice.DeviceAttribute{WWN: "fake-WWN-Y", Serial: "fake-serial-Y"},
+			},
+		}
+	}
+	// newProbeEvent creates the ProbeEvent of a newly started daemon
+	newProbeEvent := func(probe *countingProbe, usage *usageProbe) *ProbeEvent {
+		fakeController := &controller.Controller{
+			Clientset:        CreateFakeClient(t),
+			Mutex:            &sync.Mutex{},
+			Filters:          make([]*controller.Filter, 0),
+			Probes:           make([]*controller.Probe, 0),
+			NodeAttributes:   map[string]string{controller.HostNameKey: fakeHostName},
+			BDHierarchy:      blockdevice.NewHierarchyCache(nil),
+			RescanCheckpoint: controller.NewRescanCheckpoint(checkpointFile, time.Hour),
+		}
+		fakeController.AddNewProbe(&controller.Probe{
+			Name:      "counting-probe",
+			State:     true,
+			Interface: probe,
+		})
+		fakeController.AddNewProbe(&controller.Probe{
+			Name:      "usage-probe",
+			State:     true,
+			Interface: usage,
+		})
+		return &ProbeEvent{Controller: fakeController}
+	}
+
+	// the rescan was interrupted after /dev/sdX was processed
+	interrupted := newProbeEvent(&countingProbe{}, &usageProbe{})
+	interrupted.Controller.RescanCheckpoint.Begin()
+	scanned := newScannedDevices()[0]
+	probed := *scanned
+	interrupted.Controller.FillBlockDeviceDetails(context.TODO(), &probed)
+	interrupted.Controller.RescanCheckpoint.Record(*scanned, probed)
+	interrupted.Controller.RescanCheckpoint.Flush()
+
+	// the rescan is resumed after the daemon restarts
+	resumedProbe := &countingProbe{}
+	resumedUsageProbe := &usageProbe{}
+	resumed := newProbeEvent(resumedProbe, resumedUsageProbe)
+	resumed.addBlockDeviceEvent(controller.EventMessage{
+		Action:   libudevwrapper.UDEV_ACTION_ADD,
+		Devices:  newScannedDevices(),
+		FullScan: true,
+	})
+	assert.Equal(t, []string{"/dev/sdY"}, resumedProbe.probed)
+	// the usage of all the devices is probed again
+	assert.Equal(t, []string{"/dev/sdX", "/dev/sdY"}, resumedUsageProbe.probed)
+
+	// the recorded details of the skipped device are used for its resource and the cache
+	bdX, ok := resumed.Controller.BDHierarchy.Get("/dev/sdX")
+	assert.True(t, ok)
+	assert.Equal(t, fakeModel, bdX.DeviceAttributes.Model)
+	assert.Equal(t, "true", bdX.Labels["usage-probed"])
+	bdAPIList, err := resumed.Controller.ListBlockDeviceResource(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bdAPIList.Items))
+
+	// the resumed rescan completed, so the next rescan probes all the devices
+	nextProbe := &countingProbe{}
+	next := newProbeEvent(nextProbe, &usageProbe{})
+	next.addBlockDeviceEvent(controller.EventMessage{
+		Action:   libudevwrapper.UDEV_ACTION_ADD,
+		Devices:  newScannedDevices(),
+		FullScan: true,
+	})
+	assert.Equal(t, []string{"/dev/sdX", "/dev/sdY"}, nextProbe.probed)
+}
+
 func TestDeleteDiskEvent(t *testing.T) {
 	fakeNdmClient := CreateFakeClient(t)
 	probes := make([]*controller.Probe, 0)
@@ -214,9 +326,9 @@ func TestDeleteDiskEvent(t *testing.T) {
 		Probes:         probes,
 		Mutex:          mutex,
 		NodeAttributes: nodeAttributes,
-		BDHierarchy: blockdevice.Hierarchy{
+		BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
 			"/dev/sdX": fakeBD1,
-		},
+		}),
 	}
 
 	// Create one fake block device resource
@@ -364,3 +476,184 @@ func TestIsParentOrSlaveDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHierarchyDivergence(t *testing.T) {
+	newBD := func(name, path, hostName string, state apis.BlockDeviceState) apis.BlockDevice {
+		bd := apis.BlockDevice{}
+		bd.Name = name
+		bd.Labels = map[string]string{controller.KubernetesHostNameLabel: hostName}
+		bd.Spec.Path = path
+		bd.Status.State = state
+		return bd
+	}
+	tests := map[string]struct {
+		hierarchy         blockdevice.Hierarchy
+		bdAPIList         *apis.BlockDeviceList
+		wantBDCount       int
+		wantHierarchyOnly int
+		wantEtcdOnly      int
+	}{
+		"hierarchy in sync with resources": {
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda": {},
+				"/dev/sdb": {},
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					newBD("bd-1", "/dev/sda", fakeHostName, apis.BlockDeviceActive),
+					newBD("bd-2", "/dev/sdb", fakeHostName, apis.BlockDeviceActive),
+				},
+			},
+			wantBDCount: 2,
+		},
+		"devices without resources in hierarchy": {
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda":  {},
+				"/dev/sda1": {},
+				"/dev/sdb":  {},
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					newBD("bd-1", "/dev/sda1", fakeHostName, apis.BlockDeviceActive),
+				},
+			},
+			wantBDCount:       1,
+			wantHierarchyOnly: 2,
+		},
+		"active resource missing from hierarchy": {
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda": {},
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					newBD("bd-1", "/dev/sda", fakeHostName, apis.BlockDeviceActive),
+					newBD("bd-2", "/dev/sdb", fakeHostName, apis.BlockDeviceActive),
+					newBD("bd-3", "/dev/sdc", fakeHostName, apis.BlockDeviceInactive),
+				},
+			},
+			wantBDCount:  3,
+			wantEtcdOnly: 1,
+		},
+		"resources of other nodes are ignored": {
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda": {},
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					newBD("bd-1", "/dev/sda", fakeHostName, apis.BlockDeviceActive),
+					newBD("bd-2", "/dev/sdb", "other-node", apis.BlockDeviceActive),
+				},
+			},
+			wantBDCount: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bdCount, hierarchyOnly, etcdOnly := getHierarchyDivergence(tt.hierarchy, tt.bdAPIList, fakeHostName)
+			assert.Equal(t, tt.wantBDCount, bdCount)
+			assert.Equal(t, tt.wantHierarchyOnly, hierarchyOnly)
+			assert.Equal(t, tt.wantEtcdOnly, etcdOnly)
+		})
+	}
+}
+
+func TestGetInternalErrorReason(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantReason string
+		wantOK     bool
+	}{
+		"no error": {
+			err:    nil,
+			wantOK: false,
+		},
+		"unreachable state": {
+			err:        ErrUnreachableState,
+			wantReason: "UnreachableState",
+			wantOK:     true,
+		},
+		"wrapped parent not found": {
+			err:        fmt.Errorf("%w for device: %s", ErrParentNotFound, "/dev/sda1"),
+			wantReason: "ParentNotFound",
+			wantOK:     true,
+		},
+		"other errors are not internal errors": {
+			err:    fmt.Errorf("error reading partition table"),
+			wantOK: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reason, ok := getInternalErrorReason(tt.err)
+			assert.Equal(t, tt.wantReason, reason)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestCanonicalizeDevPathOfEvent(t *testing.T) {
+	tests := map[string]struct {
+		device         blockdevice.BlockDevice
+		wantDevPath    string
+		wantMapperPath string
+	}{
+		"event for the kernel path of a dm device": {
+			device: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/dm-3"},
+				DMInfo:     blockdevice.DeviceMapperInformation{DevMapperPath: "/dev/mapper/foo"},
+			},
+			wantDevPath:    "/dev/dm-3",
+			wantMapperPath: "/dev/mapper/foo",
+		},
+		"event for the mapper path of a dm device": {
+			device: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/mapper/foo"},
+			},
+			wantDevPath:    "/dev/dm-3",
+			wantMapperPath: "/dev/mapper/foo",
+		},
+		"event for a disk": {
+			device: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sda"},
+			},
+			wantDevPath: "/dev/sda",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dm := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/dm-3"},
+				DMInfo:     blockdevice.DeviceMapperInformation{DevMapperPath: "/dev/mapper/foo"},
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{dm.DevPath: dm}),
+				},
+			}
+			device := test.device
+			pe.canonicalizeDevPath(&device)
+			assert.Equal(t, test.wantDevPath, device.DevPath)
+			assert.Equal(t, test.wantMapperPath, device.DMInfo.DevMapperPath)
+		})
+	}
+}
+
+func TestDevMapperEventsResolveToOneEntry(t *testing.T) {
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+	}
+	for _, devPath := range []string{"/dev/dm-3", "/dev/mapper/foo"} {
+		device := blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{DevPath: devPath},
+			DMInfo:     blockdevice.DeviceMapperInformation{DevMapperPath: "/dev/mapper/foo"},
+		}
+		pe.canonicalizeDevPath(&device)
+		pe.addBlockDeviceToHierarchyCache(device)
+	}
+
+	assert.Equal(t, 1, pe.Controller.BDHierarchy.Len())
+	_, ok := pe.Controller.BDHierarchy.Get("/dev/dm-3")
+	assert.True(t, ok)
+}
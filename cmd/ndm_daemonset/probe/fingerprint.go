@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// internalContentFingerprintAnnotation is the fingerprint of the content at the start
+	// and the end of the device, in the format <head hash>:<tail hash>
+	internalContentFingerprintAnnotation = "internal.openebs.io/content-fingerprint"
+
+	// fingerprintHashLength is the number of hex characters of the sha256 hash of a
+	// region kept in the fingerprint, the full hash is not required to detect a change
+	fingerprintHashLength = 16
+
+	// fingerprintMetadataBytes is the size of the regions at the start and the end of a
+	// device that are not hashed, as they hold metadata that is rewritten while the device
+	// is otherwise idle, eg: the ZFS labels at both the ends, the md 0.90 / 1.0 superblock
+	// at the end, the md 1.1 / 1.2 superblock at the start and the GPT at both the ends
+	fingerprintMetadataBytes = 512 * 1024
+)
+
+// fingerprintDevice hashes size bytes near the start and the end of the device, excluding
+// the metadata regions at both the ends, and returns the fingerprint of the device. The
+// metadata regions are not excluded if the device is too small. Only a truncated hash is
+// kept, so the content of the device cannot be found from the fingerprint.
+func fingerprintDevice(bd blockdevice.BlockDevice, size int64) (string, error) {
+	f, err := os.Open(filepath.Clean(bd.DevPath))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	capacity := int64(bd.Capacity.Storage)
+	skip := int64(fingerprintMetadataBytes)
+	if capacity < 2*(skip+size) {
+		skip = 0
+	}
+	head, err := hashDeviceRegion(f, skip, size)
+	if err != nil {
+		return "", fmt.Errorf("error reading start of device %s: %v", bd.DevPath, err)
+	}
+	tailOffset := capacity - skip - size
+	if tailOffset < 0 {
+		tailOffset = 0
+	}
+	tail, err := hashDeviceRegion(f, tailOffset, size)
+	if err != nil {
+		return "", fmt.Errorf("error reading end of device %s: %v", bd.DevPath, err)
+	}
+	return head + ":" + tail, nil
+}
+
+// hashDeviceRegion returns the truncated sha256 hash of size bytes at the offset
+func hashDeviceRegion(r io.ReaderAt, offset, size int64) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, offset, size)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintHashLength], nil
+}
+
+// isFingerprintChanged checks if the content at both the start and the end of the device
+// has changed. A change at only one end is not flagged, as it can be a write to the device
+// rather than a different disk.
+func isFingerprintChanged(oldFingerprint, newFingerprint string) bool {
+	oldHead, oldTail, ok := strings.Cut(oldFingerprint, ":")
+	if !ok {
+		return false
+	}
+	newHead, newTail, ok := strings.Cut(newFingerprint, ":")
+	if !ok {
+		return false
+	}
+	return oldHead != newHead && oldTail != newTail
+}
+
+// getContentFingerprint gets the fingerprint of the device. The fingerprint is computed
+// only once per batch of events, as it reads the device.
+func (pe *ProbeEvent) getContentFingerprint(bd blockdevice.BlockDevice) (string, error) {
+	if fingerprint, ok := pe.fingerprints[bd.DevPath]; ok {
+		return fingerprint, nil
+	}
+	fingerprint, err := fingerprintDevice(bd, int64(pe.Controller.ContentFingerprintKiB)*1024)
+	if err != nil {
+		return "", err
+	}
+	if pe.fingerprints != nil {
+		pe.fingerprints[bd.DevPath] = fingerprint
+	}
+	return fingerprint, nil
+}
+
+// setContentFingerprint adds the content fingerprint of the device to the annotations of
+// its resource. Only unclaimed devices that are not in use are fingerprinted, as the
+// content of a device in use changes with the writes of its user. If the fingerprint of
+// the device at the path of the existing resource is now different, the disk may have
+// been swapped silently. The resource is then flagged with the potential swap annotation.
+func (pe *ProbeEvent) setContentFingerprint(bd blockdevice.BlockDevice, existingBD *apis.BlockDevice, annotations map[string]string) {
+	if (existingBD != nil && existingBD.Status.ClaimState != apis.BlockDeviceUnclaimed) ||
+		bd.DevUse.InUse || len(bd.DependentDevices.Holders) > 0 || len(bd.FSInfo.MountPoint) > 0 {
+		// the fingerprint from when the device was last unused is retained
+		if existingBD != nil {
+			if oldFingerprint, ok := existingBD.Annotations[internalContentFingerprintAnnotation]; ok {
+				annotations[internalContentFingerprintAnnotation] = oldFingerprint
+			}
+		}
+		return
+	}
+
+	fingerprint, err := pe.getContentFingerprint(bd)
+	if err != nil {
+		klog.Errorf("unable to fingerprint device: %s, %v", bd.DevPath, err)
+		return
+	}
+	annotations[internalContentFingerprintAnnotation] = fingerprint
+
+	if existingBD == nil || existingBD.Spec.Path != bd.DevPath {
+		return
+	}
+	oldFingerprint, ok := existingBD.Annotations[internalContentFingerprintAnnotation]
+	if !ok || !isFingerprintChanged(oldFingerprint, fingerprint) {
+		return
+	}
+
+	reason := fmt.Sprintf("content fingerprint of unused device %s changed from %s to %s",
+		bd.DevPath, oldFingerprint, fingerprint)
+	klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+		"ndm.blockdevice.potential.swap", "Device may have been swapped",
+		reason, existingBD.Name)
+	annotations[controller.PotentialSwapAnnotation] = reason
+	if pe.Controller.Recorder != nil {
+		pe.Controller.Recorder.Event(existingBD, v1.EventTypeWarning, "PotentialSwap", reason)
+	}
+}
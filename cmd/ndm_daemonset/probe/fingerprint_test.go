@@ -0,0 +1,232 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsFingerprintChanged(t *testing.T) {
+	tests := map[string]struct {
+		oldFingerprint string
+		newFingerprint string
+		want           bool
+	}{
+		"same fingerprint": {
+			oldFingerprint: "aaaa:bbbb",
+			newFingerprint: "aaaa:bbbb",
+			want:           false,
+		},
+		"only the start changed": {
+			oldFingerprint: "aaaa:bbbb",
+			newFingerprint: "cccc:bbbb",
+			want:           false,
+		},
+		"only the end changed": {
+			oldFingerprint: "aaaa:bbbb",
+			newFingerprint: "aaaa:cccc",
+			want:           false,
+		},
+		"both the start and end changed": {
+			oldFingerprint: "aaaa:bbbb",
+			newFingerprint: "cccc:dddd",
+			want:           true,
+		},
+		"invalid old fingerprint": {
+			oldFingerprint: "aaaa",
+			newFingerprint: "cccc:dddd",
+			want:           false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isFingerprintChanged(tt.oldFingerprint, tt.newFingerprint))
+		})
+	}
+}
+
+func TestContentFingerprint(t *testing.T) {
+	const (
+		fingerprintKiB = 1
+		deviceSize     = 2 * (fingerprintMetadataBytes + fingerprintKiB*1024)
+	)
+	devPath := filepath.Join(t.TempDir(), "sdx")
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			UUID:    "blockdevice-fingerprint",
+			DevPath: devPath,
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: deviceSize,
+		},
+	}
+
+	// content of a disk, where the hashed regions near the start and end are filled with
+	// the given bytes, and the metadata regions at both the ends with the given byte
+	content := func(head, tail, metadata byte) []byte {
+		buf := bytes.Repeat([]byte{metadata}, deviceSize)
+		copy(buf[fingerprintMetadataBytes:], bytes.Repeat([]byte{head}, fingerprintKiB*1024))
+		copy(buf[deviceSize-fingerprintMetadataBytes-fingerprintKiB*1024:], bytes.Repeat([]byte{tail}, fingerprintKiB*1024))
+		return buf
+	}
+	assert.NoError(t, os.WriteFile(devPath, content('a', 'b', 0), 0600))
+	oldFingerprint, err := fingerprintDevice(bd, fingerprintKiB*1024)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		content         []byte
+		claimState      apis.DeviceClaimState
+		inUse           bool
+		oldFingerprint  string
+		wantFingerprint string
+		wantFlagged     bool
+	}{
+		"unclaimed device swapped at the same path": {
+			content:        content('c', 'd', 0),
+			claimState:     apis.BlockDeviceUnclaimed,
+			oldFingerprint: oldFingerprint,
+			wantFlagged:    true,
+		},
+		"unclaimed device with writes at the start": {
+			content:        content('c', 'b', 0),
+			claimState:     apis.BlockDeviceUnclaimed,
+			oldFingerprint: oldFingerprint,
+			wantFlagged:    false,
+		},
+		"unclaimed device with the metadata at both the ends rewritten": {
+			content:        content('a', 'b', 'm'),
+			claimState:     apis.BlockDeviceUnclaimed,
+			oldFingerprint: oldFingerprint,
+			wantFlagged:    false,
+		},
+		"unclaimed device without a fingerprint": {
+			content:     content('c', 'd', 0),
+			claimState:  apis.BlockDeviceUnclaimed,
+			wantFlagged: false,
+		},
+		"claimed device is not read": {
+			content:         content('c', 'd', 0),
+			claimState:      apis.BlockDeviceClaimed,
+			oldFingerprint:  oldFingerprint,
+			wantFingerprint: oldFingerprint,
+			wantFlagged:     false,
+		},
+		"unclaimed device in use is not read": {
+			content:         content('c', 'd', 0),
+			claimState:      apis.BlockDeviceUnclaimed,
+			inUse:           true,
+			oldFingerprint:  oldFingerprint,
+			wantFingerprint: oldFingerprint,
+			wantFlagged:     false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, os.WriteFile(devPath, tt.content, 0600))
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			existingBD := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        bd.UUID,
+					Annotations: map[string]string{},
+				},
+				Spec: apis.DeviceSpec{
+					Path: devPath,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: tt.claimState,
+					State:      controller.NDMActive,
+				},
+			}
+			if tt.oldFingerprint != "" {
+				existingBD.Annotations[internalContentFingerprintAnnotation] = tt.oldFingerprint
+			}
+			assert.NoError(t, cl.Create(context.TODO(), existingBD))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:             cl,
+					NodeAttributes:        map[string]string{controller.HostNameKey: "fake-host-name"},
+					ContentFingerprintKiB: fingerprintKiB,
+				},
+				fingerprints: make(map[string]string),
+			}
+			device := bd
+			device.DevUse.InUse = tt.inUse
+			annotations := map[string]string{
+				internalUUIDSchemeAnnotation: gptUUIDScheme,
+			}
+			assert.NoError(t, pe.createOrUpdateWithAnnotation(annotations, device, existingBD))
+
+			gotBD := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: bd.UUID}, gotBD))
+			wantFingerprint := tt.wantFingerprint
+			if wantFingerprint == "" {
+				wantFingerprint, err = fingerprintDevice(bd, fingerprintKiB*1024)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, wantFingerprint, gotBD.Annotations[internalContentFingerprintAnnotation])
+			_, flagged := gotBD.Annotations[controller.PotentialSwapAnnotation]
+			assert.Equal(t, tt.wantFlagged, flagged)
+		})
+	}
+}
+
+func TestContentFingerprintCachedPerBatch(t *testing.T) {
+	devPath := filepath.Join(t.TempDir(), "sdx")
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: devPath,
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 8 * 1024,
+		},
+	}
+	assert.NoError(t, os.WriteFile(devPath, bytes.Repeat([]byte{'a'}, 8*1024), 0600))
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			ContentFingerprintKiB: 1,
+		},
+		fingerprints: make(map[string]string),
+	}
+	first, err := pe.getContentFingerprint(bd)
+	assert.NoError(t, err)
+
+	// the device is not read again in the same batch
+	assert.NoError(t, os.WriteFile(devPath, bytes.Repeat([]byte{'b'}, 8*1024), 0600))
+	second, err := pe.getContentFingerprint(bd)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/klog/v2"
+)
+
+// detectForeignPartitionScheme is a variable, so that it can be replaced in tests
+var detectForeignPartitionScheme = partition.DetectForeignPartitionScheme
+
+// quarantineForeignPartitionScheme checks the disk for the signature of a partition table
+// that NDM cannot parse, like a BSD disklabel or a Solaris VTOC. Such a disk looks blank,
+// but is not, and is quarantined instead of being partitioned. true is returned if the
+// disk has a foreign partition table.
+func (pe *ProbeEvent) quarantineForeignPartitionScheme(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	scheme, err := detectForeignPartitionScheme(bd.DevPath)
+	if err != nil {
+		klog.Errorf("error checking partition table signatures of device: %s, %v", bd.DevPath, err)
+		return false, err
+	}
+	if scheme == partition.NoForeignPartitionScheme {
+		return false, nil
+	}
+	klog.Infof("device: %s has a %s partition table that cannot be parsed, "+
+		"refusing to overwrite the partition table", bd.DevPath, scheme)
+	bd.DecisionTrace.Add("partitioning:skipped-foreign-partition-scheme")
+	reason := fmt.Sprintf("ForeignPartitionScheme: device has a %s partition table that cannot be parsed", scheme)
+	return true, pe.quarantineUnidentifiedBlockDevice(bd, reason, bdAPIList)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAddBlockDeviceWithForeignPartitionScheme(t *testing.T) {
+	oldCreateSinglePartition := createSinglePartition
+	createSinglePartition = func(_ *partition.Disk) error {
+		return fmt.Errorf("disk with a foreign partition table should not be partitioned")
+	}
+	defer func() { createSinglePartition = oldCreateSinglePartition }()
+
+	tests := map[string]struct {
+		// writeLabel writes the partition table signature to the start of the disk image
+		writeLabel func(data []byte)
+		wantReason string
+	}{
+		"bsd disklabel": {
+			writeLabel: func(data []byte) {
+				binary.LittleEndian.PutUint32(data[512:], 0x82564557)
+				binary.LittleEndian.PutUint32(data[512+132:], 0x82564557)
+			},
+			wantReason: "ForeignPartitionScheme: device has a bsd partition table that cannot be parsed",
+		},
+		"solaris vtoc": {
+			writeLabel: func(data []byte) {
+				binary.LittleEndian.PutUint32(data[512+12:], 0x600DDEEE)
+			},
+			wantReason: "ForeignPartitionScheme: device has a solaris partition table that cannot be parsed",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// a disk without WWN and partitions, which cannot be uniquely identified
+			diskImage := filepath.Join(t.TempDir(), "disk.img")
+			data := make([]byte, 10*1024*1024)
+			test.writeLabel(data)
+			if err := os.WriteFile(diskImage, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: diskImage,
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType:       blockdevice.BlockDeviceTypeDisk,
+					LogicalBlockSize: 512,
+					Serial:           "ZA1B2C3D",
+				},
+				Capacity: blockdevice.CapacityInformation{
+					Storage: 10 * 1024 * 1024,
+				},
+				DecisionTrace: &blockdevice.DecisionTrace{},
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+				},
+			}
+
+			assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+			assert.True(t, util.Contains(bd.DecisionTrace.Steps(), "partitioning:skipped-foreign-partition-scheme"))
+
+			bdList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdList))
+			if assert.Len(t, bdList.Items, 1) {
+				bdAPI := bdList.Items[0]
+				assert.Equal(t, apis.BlockDeviceQuarantined, bdAPI.Status.State)
+				assert.Equal(t, test.wantReason, bdAPI.Annotations[controller.QuarantineReasonAnnotation])
+			}
+		})
+	}
+}
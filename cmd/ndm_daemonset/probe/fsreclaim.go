@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// reclaimStaleFilesystem wipes the filesystem on a disk that is explicitly listed for
+// filesystem reclaim in the config, so that the disk is processed like a blank disk and
+// partitioned. Mounted, in use or claimed disks are never wiped. true is returned if the
+// filesystem was wiped.
+func (pe *ProbeEvent) reclaimStaleFilesystem(bd *blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	if bd.FSInfo.FileSystem == "" || !pe.Controller.IsFilesystemReclaimAllowed(*bd) {
+		return false, nil
+	}
+
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk ||
+		len(bd.DependentDevices.Partitions) > 0 || len(bd.DependentDevices.Holders) > 0 {
+		klog.Infof("device: %s listed for filesystem reclaim is not a disk without partitions/holders, "+
+			"filesystem not wiped", bd.DevPath)
+		return false, nil
+	}
+	if bd.DevUse.InUse {
+		klog.Infof("device: %s listed for filesystem reclaim is in use by: %s, filesystem not wiped",
+			bd.DevPath, bd.DevUse.UsedBy)
+		return false, nil
+	}
+	if pool, ok := getZFSPoolLabel(*bd); ok {
+		klog.Infof("device: %s listed for filesystem reclaim has the zfs label of pool: %s, filesystem not wiped",
+			bd.DevPath, pool.PoolName)
+		return false, nil
+	}
+	if mountedDevice, ok := getDeviceWithActiveMount(*bd, pe.Controller.BDHierarchy.Snapshot()); ok {
+		klog.Infof("device: %s listed for filesystem reclaim has an active mount on %s at %v, filesystem not wiped",
+			bd.DevPath, mountedDevice.DevPath, mountedDevice.FSInfo.MountPoint)
+		return false, nil
+	}
+	if bdAPI, ok := pe.getProtectedBlockDevice(*bd, bdAPIList); ok {
+		klog.Infof("device: %s listed for filesystem reclaim has the protected blockdevice: %s, filesystem not wiped",
+			bd.DevPath, bdAPI.Name)
+		return false, nil
+	}
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.WipeSignaturesOperation, bd.DevPath) {
+		return false, nil
+	}
+
+	pe.Controller.RecordFilesystemReclaim(*bd)
+	err := wipeSignatures(bd.DevPath)
+	pe.Controller.Audit(controller.WipeSignaturesOperation, getAuditTarget(*bd), controller.AuditCause{
+		Trigger: controller.AuditTriggerDeviceAdded,
+		Reason:  fmt.Sprintf("stale %s filesystem listed for reclaim", bd.FSInfo.FileSystem),
+	}, err)
+	if err != nil {
+		klog.Errorf("error wiping filesystem of device: %s, %v", bd.DevPath, err)
+		return false, err
+	}
+	klog.Infof("wiped %s filesystem of device: %s", bd.FSInfo.FileSystem, bd.DevPath)
+	bd.FSInfo = blockdevice.FileSystemInformation{}
+	return true, nil
+}
+
+// getProtectedBlockDevice gets the blockdevice of the device that is protected from wiping,
+// looking up the uuids generated for the device by all the uuid algorithms
+func (pe *ProbeEvent) getProtectedBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (apis.BlockDevice, bool) {
+	uuids := make([]string, 0)
+	for _, version := range pe.uuidVersions() {
+		if uuid, _, ok := pe.generateUUIDOfVersion(bd, version); ok {
+			uuids = append(uuids, uuid)
+		}
+	}
+	legacyUUID, _ := generateLegacyUUID(bd)
+	uuids = append(uuids, legacyUUID)
+
+	for _, bdAPI := range bdAPIList.Items {
+		for _, uuid := range uuids {
+			if bdAPI.Name == uuid &&
+				pe.Controller.IsProtected(bdAPI, controller.WipeSignaturesOperation) {
+				return bdAPI, true
+			}
+		}
+	}
+	return apis.BlockDevice{}, false
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/zfs"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// staleFilesystemDisk is a decommissioned disk without WWN, having a stale ext4 filesystem
+func staleFilesystemDisk(devPath string) blockdevice.BlockDevice {
+	return blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: devPath,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType:       blockdevice.BlockDeviceTypeDisk,
+			Serial:           "ZA1B2C3F",
+			LogicalBlockSize: 512,
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 10 * 1024 * 1024,
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			FileSystem:     "ext4",
+			FileSystemUUID: "9f0c2d1e-5b3a-4c7d-8e6f-1a2b3c4d5e6f",
+		},
+	}
+}
+
+func TestReclaimStaleFilesystem(t *testing.T) {
+	origWipeSignatures := wipeSignatures
+	defer func() { wipeSignatures = origWipeSignatures }()
+
+	disk := staleFilesystemDisk("/dev/sdX")
+	fsUUID, _, _ := generateUUID(disk)
+	reclaimConfig := []controller.FilesystemReclaimConfig{{Serial: "ZA1B2C3F"}}
+
+	mounted := disk
+	mounted.FSInfo.MountPoint = []string{"/mnt/data"}
+	inUse := disk
+	inUse.DevUse = blockdevice.DeviceUsage{InUse: true, UsedBy: blockdevice.LocalPV}
+	withPartitions := disk
+	withPartitions.DependentDevices.Partitions = []string{"/dev/sdX1"}
+	withoutFilesystem := disk
+	withoutFilesystem.FSInfo = blockdevice.FileSystemInformation{}
+
+	tests := map[string]struct {
+		bd             blockdevice.BlockDevice
+		reclaimConfigs []controller.FilesystemReclaimConfig
+		claimState     apis.DeviceClaimState
+		pendingClaim   bool
+		safeMode       bool
+		zfsLabels      map[string]*zfs.Label
+		wantReclaimed  bool
+	}{
+		"device not listed for reclaim": {
+			bd:            disk,
+			wantReclaimed: false,
+		},
+		"another device listed for reclaim": {
+			bd:             disk,
+			reclaimConfigs: []controller.FilesystemReclaimConfig{{Serial: "ZA1B2C3G"}},
+			wantReclaimed:  false,
+		},
+		"device listed for reclaim with a different wwn": {
+			bd:             disk,
+			reclaimConfigs: []controller.FilesystemReclaimConfig{{WWN: "0x5000c500a1b2c3d4", Serial: "ZA1B2C3F"}},
+			wantReclaimed:  false,
+		},
+		"device listed for reclaim": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			wantReclaimed:  true,
+		},
+		"device listed for reclaim having an unclaimed blockdevice": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			claimState:     apis.BlockDeviceUnclaimed,
+			wantReclaimed:  true,
+		},
+		"device listed for reclaim having an unclaimed blockdevice with an outstanding claim": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			claimState:     apis.BlockDeviceUnclaimed,
+			pendingClaim:   true,
+			wantReclaimed:  false,
+		},
+		"device listed for reclaim having a claimed blockdevice": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			claimState:     apis.BlockDeviceClaimed,
+			wantReclaimed:  false,
+		},
+		"mounted device listed for reclaim": {
+			bd:             mounted,
+			reclaimConfigs: reclaimConfig,
+			wantReclaimed:  false,
+		},
+		"in use device listed for reclaim": {
+			bd:             inUse,
+			reclaimConfigs: reclaimConfig,
+			wantReclaimed:  false,
+		},
+		"device with partitions listed for reclaim": {
+			bd:             withPartitions,
+			reclaimConfigs: reclaimConfig,
+			wantReclaimed:  false,
+		},
+		"device without filesystem listed for reclaim": {
+			bd:             withoutFilesystem,
+			reclaimConfigs: reclaimConfig,
+			wantReclaimed:  false,
+		},
+		"device listed for reclaim having the zfs label of a degraded pool": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			zfsLabels: map[string]*zfs.Label{
+				"/dev/sdX": {PoolName: "tank", PoolGUID: 1, Layout: zfs.LayoutMirror},
+			},
+			wantReclaimed: false,
+		},
+		"device listed for reclaim in safe mode": {
+			bd:             disk,
+			reclaimConfigs: reclaimConfig,
+			safeMode:       true,
+			wantReclaimed:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var wiped []string
+			wipeSignatures = func(devPath string) error {
+				wiped = append(wiped, devPath)
+				return nil
+			}
+			defer mockZFSLabels(tt.zfsLabels)()
+			bdAPIList := &apis.BlockDeviceList{}
+			if tt.claimState != "" {
+				bdAPI := apis.BlockDevice{ObjectMeta: metav1.ObjectMeta{Name: fsUUID}}
+				bdAPI.Status.ClaimState = tt.claimState
+				bdAPIList.Items = append(bdAPIList.Items, bdAPI)
+			}
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			auditBuf := &bytes.Buffer{}
+			if tt.pendingClaim {
+				claim := &apis.BlockDeviceClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "bdc-pending"},
+					Spec:       apis.DeviceClaimSpec{BlockDeviceName: fsUUID},
+				}
+				assert.NoError(t, cl.Create(context.TODO(), claim))
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						FilesystemReclaimConfigs: tt.reclaimConfigs,
+					},
+					SafeMode: tt.safeMode,
+					AuditLog: controller.NewAuditLog(auditBuf, nil),
+				},
+			}
+			bd := tt.bd
+			got, err := pe.reclaimStaleFilesystem(&bd, bdAPIList)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantReclaimed, got)
+			if !tt.wantReclaimed {
+				assert.Empty(t, wiped)
+				assert.Equal(t, tt.bd.FSInfo, bd.FSInfo)
+				assertAuditRecords(t, auditBuf, nil)
+				return
+			}
+			assert.Equal(t, []string{bd.DevPath}, wiped)
+			assert.Equal(t, blockdevice.FileSystemInformation{}, bd.FSInfo)
+			assertAuditRecords(t, auditBuf, []controller.AuditRecord{{
+				Operation: string(controller.WipeSignaturesOperation),
+				Device:    bd.DevPath,
+				Trigger:   controller.AuditTriggerDeviceAdded,
+				Result:    controller.AuditResultSuccess,
+			}})
+		})
+	}
+}
+
+func TestAddBlockDeviceWithFilesystemReclaim(t *testing.T) {
+	origWipeSignatures := wipeSignatures
+	defer func() { wipeSignatures = origWipeSignatures }()
+
+	tests := map[string]struct {
+		reclaimConfigs  []controller.FilesystemReclaimConfig
+		wantPartitioned bool
+	}{
+		"filesystem blocks partitioning without the override": {
+			reclaimConfigs:  nil,
+			wantPartitioned: false,
+		},
+		"filesystem is wiped and disk is partitioned with the override": {
+			reclaimConfigs:  []controller.FilesystemReclaimConfig{{Serial: "ZA1B2C3F"}},
+			wantPartitioned: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the disk image is blank, the filesystem reported by the probes is wiped
+			// by the mocked wipe
+			diskImage := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var wiped []string
+			wipeSignatures = func(devPath string) error {
+				wiped = append(wiped, devPath)
+				return nil
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						FilesystemReclaimConfigs: tt.reclaimConfigs,
+					},
+				},
+			}
+			bd := staleFilesystemDisk(diskImage)
+			assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+
+			hasEntries, err := partition.HasGPTPartitionEntries(diskImage)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPartitioned, hasEntries)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			if tt.wantPartitioned {
+				assert.Equal(t, []string{diskImage}, wiped)
+				assert.Empty(t, bdAPIList.Items)
+				return
+			}
+			// the disk is identified by its filesystem uuid
+			assert.Empty(t, wiped)
+			fsUUID, _, _ := generateUUID(bd)
+			if assert.Len(t, bdAPIList.Items, 1) {
+				assert.Equal(t, fsUUID, bdAPIList.Items[0].Name)
+			}
+		})
+	}
+}
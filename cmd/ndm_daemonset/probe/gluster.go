@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// glusterVolumeIDXattr is the extended attribute set by glusterfs on the root
+	// directory of every brick, having the id of the volume the brick belongs to
+	glusterVolumeIDXattr = "trusted.glusterfs.volume-id"
+
+	// heketiBrickMountPath is the path at which heketi mounts the bricks it
+	// creates, eg: /var/lib/heketi/mounts/vg_<id>/brick_<id>
+	heketiBrickMountPath = "/var/lib/heketi/mounts/"
+)
+
+// getXattr is a variable, so that it can be replaced in tests
+var getXattr = unix.Getxattr
+
+// glusterBrick is a glusterfs brick found on a mounted filesystem
+type glusterBrick struct {
+	// Path is the path of the brick on the host
+	Path string
+	// VolumeID is the id of the glusterfs volume the brick belongs to. It is
+	// empty if the brick was recognized from the mount path convention.
+	VolumeID string
+}
+
+// getGlusterBrick gets the glusterfs brick among the given mount points. The brick is
+// recognized by the volume-id xattr on the mount point or on one of its immediate
+// subdirectories, as bricks are usually created in a directory of the filesystem.
+// Mount points following the heketi convention are bricks even without the xattr.
+func getGlusterBrick(mountPoints []string) (glusterBrick, bool) {
+	for _, mountPoint := range mountPoints {
+		hostPath := filepath.Join(hostRootPath, mountPoint)
+		candidates := []string{hostPath}
+		if entries, err := ioutil.ReadDir(hostPath); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					candidates = append(candidates, filepath.Join(hostPath, entry.Name()))
+				}
+			}
+		}
+		for _, candidate := range candidates {
+			if volumeID, ok := getGlusterVolumeID(candidate); ok {
+				brickPath, _ := filepath.Rel(hostRootPath, candidate)
+				return glusterBrick{Path: "/" + brickPath, VolumeID: volumeID}, true
+			}
+		}
+	}
+	for _, mountPoint := range mountPoints {
+		if strings.HasPrefix(mountPoint, heketiBrickMountPath) {
+			return glusterBrick{Path: mountPoint}, true
+		}
+	}
+	return glusterBrick{}, false
+}
+
+// getGlusterVolumeID reads the glusterfs volume id from the xattr of the given path and
+// formats it as a uuid
+func getGlusterVolumeID(path string) (string, bool) {
+	buf := make([]byte, 16)
+	n, err := getXattr(path, glusterVolumeIDXattr, buf)
+	if err != nil || n != len(buf) {
+		return "", false
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), true
+}
+
+// deviceInUseByGluster checks if the device is used as a glusterfs brick and returns true
+// if further processing of the event is required. A resource tagged for glusterfs, having
+// the id of the volume, is created for such devices, so that they are not claimed by others,
+// and they are never partitioned.
+func (pe *ProbeEvent) deviceInUseByGluster(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.Gluster {
+		return true, nil
+	}
+
+	klog.Infof("device: %s in use by glusterfs, %s", bd.DevPath, bd.DevUse.Reason)
+
+	var labels map[string]string
+	if mountedDevice, ok := getDeviceWithActiveMount(bd, pe.Controller.BDHierarchy.Snapshot()); ok {
+		if brick, ok := getGlusterBrick(mountedDevice.FSInfo.MountPoint); ok && brick.VolumeID != "" {
+			labels = map[string]string{controller.NDMGlusterVolumeIDKey: brick.VolumeID}
+		}
+	}
+	if err := pe.pushTaggedDevice(bd, bdAPIList, blockdevice.Gluster, labels); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/db/kubernetes"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const fakeGlusterVolumeID = "7e1c2d4a-9b3f-4e6d-8a21-5c0f9d3b1e47"
+
+// fakeGlusterXattrs sets up a fake host root having a glusterfs brick in a directory of the
+// filesystem mounted at /bricks/brick1, and fakes the xattr of the brick, as the trusted
+// xattrs cannot be set without privileges.
+func fakeGlusterXattrs(t *testing.T) {
+	origHostRootPath := hostRootPath
+	origGetXattr := getXattr
+	t.Cleanup(func() {
+		hostRootPath = origHostRootPath
+		getXattr = origGetXattr
+	})
+	hostRootPath = t.TempDir()
+	brickPath := filepath.Join(hostRootPath, "/bricks/brick1/data")
+	assert.NoError(t, os.MkdirAll(brickPath, 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(hostRootPath, "/mnt/data/dir"), 0700))
+
+	getXattr = func(path, attr string, dest []byte) (int, error) {
+		if path != brickPath || attr != glusterVolumeIDXattr {
+			return 0, unix.ENODATA
+		}
+		return copy(dest, []byte{
+			0x7e, 0x1c, 0x2d, 0x4a, 0x9b, 0x3f, 0x4e, 0x6d,
+			0x8a, 0x21, 0x5c, 0x0f, 0x9d, 0x3b, 0x1e, 0x47,
+		}), nil
+	}
+}
+
+func TestGetGlusterBrick(t *testing.T) {
+	fakeGlusterXattrs(t)
+
+	tests := map[string]struct {
+		mountPoints []string
+		want        glusterBrick
+		wantOk      bool
+	}{
+		"brick in a directory of the mount point": {
+			mountPoints: []string{"/bricks/brick1"},
+			want:        glusterBrick{Path: "/bricks/brick1/data", VolumeID: fakeGlusterVolumeID},
+			wantOk:      true,
+		},
+		"mount point without brick": {
+			mountPoints: []string{"/mnt/data"},
+		},
+		"brick mounted by heketi": {
+			mountPoints: []string{"/var/lib/heketi/mounts/vg_1/brick_1"},
+			want:        glusterBrick{Path: "/var/lib/heketi/mounts/vg_1/brick_1"},
+			wantOk:      true,
+		},
+		"no mount points": {},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := getGlusterBrick(test.mountPoints)
+			assert.Equal(t, test.wantOk, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestDeviceInUseByGluster(t *testing.T) {
+	fakeGlusterXattrs(t)
+
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sde",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d7",
+			Serial:     "ZA1B2C3G",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			MountPoint: []string{"/bricks/brick1"},
+		},
+	}
+	mp := &mountProbe{Controller: &controller.Controller{BDHierarchy: blockdevice.NewHierarchyCache(nil)}}
+	mp.fillDeviceUsage(&bd)
+	assert.Equal(t, blockdevice.DeviceUsage{
+		InUse:  true,
+		UsedBy: blockdevice.Gluster,
+		Reason: "/bricks/brick1 (glusterfs brick: /bricks/brick1/data, volume-id: " + fakeGlusterVolumeID + ")",
+	}, bd.DevUse)
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{}),
+		},
+	}
+	ok, err := pe.deviceInUseByGluster(bd, &apis.BlockDeviceList{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	uuid, _, _ := generateUUID(bd)
+	gotBDAPI := &apis.BlockDevice{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, gotBDAPI))
+	assert.Equal(t, string(blockdevice.Gluster), gotBDAPI.Labels[kubernetes.BlockDeviceTagLabel])
+	assert.Equal(t, fakeGlusterVolumeID, gotBDAPI.Labels[controller.NDMGlusterVolumeIDKey])
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/klog/v2"
+)
+
+// inFlightPartitionTimeout is the time within which the add event of the partition
+// created by NDM is expected. A later event is processed like any other partition.
+const inFlightPartitionTimeout = 2 * time.Minute
+
+// partitionTracker tracks the disks on which NDM has created a partition, keyed by the
+// path of the disk, with the time at which the partition was created
+type partitionTracker struct {
+	mutex   sync.Mutex
+	parents map[string]time.Time
+	// now gets the current time, it can be replaced in tests
+	now func() time.Time
+}
+
+func newPartitionTracker() *partitionTracker {
+	return &partitionTracker{
+		parents: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// add records that NDM created a partition on the disk
+func (pt *partitionTracker) add(parent string) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	pt.parents[parent] = pt.now()
+}
+
+// take checks if NDM created a partition on the disk within the timeout, and removes
+// the disk from the tracker, so that only the first partition event is adopted
+func (pt *partitionTracker) take(parent string) bool {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	createdAt, ok := pt.parents[parent]
+	if !ok {
+		return false
+	}
+	delete(pt.parents, parent)
+	return pt.now().Sub(createdAt) <= inFlightPartitionTimeout
+}
+
+// getInFlightPartitions gets the tracker of the disks partitioned by NDM, creating it on
+// first use
+func (pe *ProbeEvent) getInFlightPartitions() *partitionTracker {
+	if pe.inFlightPartitions == nil {
+		pe.inFlightPartitions = newPartitionTracker()
+	}
+	return pe.inFlightPartitions
+}
+
+// isInFlightNDMPartition checks if the partition is the one NDM has just created on its
+// parent disk. Such a partition is adopted directly, instead of treating it as created
+// by a consumer of the parent disk.
+func (pe *ProbeEvent) isInFlightNDMPartition(bd blockdevice.BlockDevice) bool {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition ||
+		bd.DependentDevices.Parent == "" {
+		return false
+	}
+	if !partition.IsNDMPartition(bd.PartitionInfo.PartitionType, bd.PartitionInfo.PartitionEntryUUID,
+		bd.PartitionInfo.PartitionName) {
+		return false
+	}
+	if !pe.getInFlightPartitions().take(bd.DependentDevices.Parent) {
+		return false
+	}
+	klog.Infof("eventcode=%s msg=%s rname=%v parent=%s",
+		"ndm.blockdevice.partition.adopted", "Adopting partition created by NDM",
+		bd.DevPath, bd.DependentDevices.Parent)
+	return true
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPartitionTrackerTake(t *testing.T) {
+	createdAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		add     bool
+		elapsed time.Duration
+		want    bool
+	}{
+		"partition created within the timeout": {
+			add:     true,
+			elapsed: time.Minute,
+			want:    true,
+		},
+		"partition event after the timeout": {
+			add:     true,
+			elapsed: 3 * time.Minute,
+			want:    false,
+		},
+		"no partition created on the disk": {
+			add:  false,
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pt := newPartitionTracker()
+			pt.now = func() time.Time { return createdAt }
+			if tt.add {
+				pt.add("/dev/sdx")
+			}
+			pt.now = func() time.Time { return createdAt.Add(tt.elapsed) }
+			assert.Equal(t, tt.want, pt.take("/dev/sdx"))
+			// only the first partition event is adopted
+			assert.False(t, pt.take("/dev/sdx"))
+		})
+	}
+}
+
+func TestAddBlockDeviceAdoptsInFlightPartition(t *testing.T) {
+	parent := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+	parentUUID, _, ok := generateUUID(parent)
+	if !ok {
+		t.Fatal("unable to generate uuid for parent device")
+	}
+
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, _ *blockdevice.BlockDevice) {}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+
+	tests := map[string]struct {
+		inFlight        bool
+		partitionGUID   string
+		partitionName   string
+		wantTrace       bool
+		wantParentState string
+	}{
+		"partition created by NDM is adopted": {
+			inFlight:        true,
+			partitionGUID:   "9a1b8f0e-6d5f-4b3e-9c1d-4e444d505254",
+			partitionName:   "ACME_9A1B8F0E",
+			wantTrace:       true,
+			wantParentState: controller.NDMActive,
+		},
+		"partition on a disk not partitioned by NDM deactivates the parent": {
+			inFlight:        false,
+			partitionGUID:   "9a1b8f0e-6d5f-4b3e-9c1d-4e444d505254",
+			partitionName:   "ACME_9A1B8F0E",
+			wantTrace:       false,
+			wantParentState: controller.NDMInactive,
+		},
+		"partition not created by NDM deactivates the parent": {
+			inFlight:        true,
+			partitionGUID:   "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c41",
+			partitionName:   "ACME_9A1B8F0E",
+			wantTrace:       false,
+			wantParentState: controller.NDMInactive,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inFlightPartitions := newPartitionTracker()
+			if tt.inFlight {
+				inFlightPartitions.add(parent.DevPath)
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			parentBDAPI := apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: parentUUID,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: apis.BlockDeviceUnclaimed,
+					State:      controller.NDMActive,
+				},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), &parentBDAPI))
+
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/sdx1",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+				PartitionInfo: blockdevice.PartitionInformation{
+					PartitionEntryUUID: tt.partitionGUID,
+					PartitionType:      "0fc63daf-8483-4772-8e79-3d69d8477de4",
+					PartitionName:      tt.partitionName,
+				},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Parent: parent.DevPath,
+				},
+				DecisionTrace: &blockdevice.DecisionTrace{},
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+						parent.DevPath: parent,
+						bd.DevPath:     bd,
+					}),
+				},
+				inFlightPartitions: inFlightPartitions,
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+
+			got := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: parentUUID}, got))
+			assert.Equal(t, tt.wantParentState, string(got.Status.State))
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			assert.Equal(t, 2, len(bdAPIList.Items))
+			assert.Equal(t, tt.wantTrace, util.Contains(bd.DecisionTrace.Steps(), "partition:adopted"))
+		})
+	}
+}
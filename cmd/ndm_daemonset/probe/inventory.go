@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"errors"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/features"
+	"github.com/openebs/node-disk-manager/pkg/util"
+)
+
+const (
+	// InventoryActionManage means a BlockDevice resource would be created / updated
+	InventoryActionManage = "manage"
+	// InventoryActionPartition means the device would be partitioned
+	InventoryActionPartition = "partition"
+	// InventoryActionQuarantine means the device would be quarantined
+	InventoryActionQuarantine = "quarantine"
+	// InventoryActionSkip means no action would be taken on the device
+	InventoryActionSkip = "skip"
+	// InventoryActionError means the device could not be processed
+	InventoryActionError = "error"
+)
+
+// InventoryEntry has the details of a device on the node and the action that NDM would
+// take on it
+type InventoryEntry struct {
+	DevPath    string   `json:"devPath"`
+	DeviceType string   `json:"deviceType"`
+	UUID       string   `json:"uuid,omitempty"`
+	WWN        string   `json:"wwn,omitempty"`
+	Serial     string   `json:"serial,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	Capacity   uint64   `json:"capacity"`
+	FileSystem string   `json:"fileSystem,omitempty"`
+	UsedBy     string   `json:"usedBy,omitempty"`
+	ClaimState string   `json:"claimState,omitempty"`
+	Action     string   `json:"action"`
+	Reason     string   `json:"reason,omitempty"`
+	Operations []string `json:"operations,omitempty"`
+}
+
+// Inventory lists the devices on the node and evaluates the action that NDM would take
+// on each of them, by running the decision logic of the add event. The controller should
+// be in the evaluation mode, so that the devices and the resources are not modified.
+func Inventory(ctrl *controller.Controller) ([]InventoryEntry, error) {
+	if ctrl.Evaluation == nil {
+		return nil, errors.New("inventory can be generated only in the evaluation mode")
+	}
+
+	ctrl.BDHierarchy = blockdevice.NewHierarchyCache(nil)
+	devices, err := listNodeDevices(ctrl)
+	if err != nil {
+		return nil, err
+	}
+
+	bdAPIList, err := ctrl.ListBlockDeviceResource(true)
+	if err != nil {
+		return nil, err
+	}
+
+	pe := &ProbeEvent{
+		Controller:         ctrl,
+		deactivatedParents: make(map[string]struct{}),
+	}
+	skipReasons := make(map[string]string)
+	errs := make(map[string]error)
+	for _, device := range devices {
+		skipReason, err := pe.evaluateDevice(device, bdAPIList)
+		if skipReason != "" {
+			skipReasons[device.DevPath] = skipReason
+		}
+		if err != nil {
+			errs[device.DevPath] = err
+		}
+	}
+
+	entries := make([]InventoryEntry, 0, len(devices))
+	for _, device := range devices {
+		// the cache has the details filled during the evaluation, like the uuid
+		bd, ok := ctrl.BDHierarchy.Get(device.DevPath)
+		if !ok {
+			bd = *device
+		}
+		entry := newInventoryEntry(bd, bdAPIList)
+		entry.Operations = ctrl.Evaluation.Operations(bd.DevPath, bd.UUID)
+		entry.Action, entry.Reason = getInventoryAction(entry.Operations, skipReasons[bd.DevPath], errs[bd.DevPath])
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// listNodeDevices lists the devices on the node from udev
+var listNodeDevices = func(ctrl *controller.Controller) ([]*blockdevice.BlockDevice, error) {
+	up := newUdevProbe(ctrl)
+	if up == nil {
+		return nil, errors.New("unable to initialize udev")
+	}
+	defer up.free()
+	devices, _, err := up.listDevices()
+	return devices, err
+}
+
+// evaluateDevice fills the details of the device and runs the decision logic of the add
+// event on it. The reason is returned if the device is skipped before the decision logic
+// is run. The controller should be in the evaluation mode.
+func (pe *ProbeEvent) evaluateDevice(device *blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (string, error) {
+	if ready, _ := pe.checkDeviceReady(device.DevPath); !ready {
+		return skipReasonNotReady, nil
+	}
+	pe.Controller.FillBlockDeviceDetails(context.TODO(), device)
+	pe.addBlockDeviceToHierarchyCache(*device)
+	if skipReason := pe.getSkipReason(device); skipReason != "" {
+		return skipReason, nil
+	}
+
+	var err error
+	if features.FeatureGates.IsEnabled(features.GPTBasedUUID) {
+		err = pe.addBlockDevice(*device, bdAPIList)
+	} else if device.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		return "partition", nil
+	} else {
+		deviceInfo := pe.Controller.NewDeviceInfoFromBlockDevice(device)
+		existingBlockDeviceResource := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, deviceInfo.UUID)
+		err = pe.Controller.PushBlockDeviceResource(existingBlockDeviceResource, deviceInfo)
+	}
+	if err != nil && !errors.Is(err, ErrNeedRescan) {
+		return "", err
+	}
+	return "", nil
+}
+
+// newInventoryEntry creates the inventory entry with the details of the device
+func newInventoryEntry(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) InventoryEntry {
+	entry := InventoryEntry{
+		DevPath:    bd.DevPath,
+		DeviceType: bd.DeviceAttributes.DeviceType,
+		UUID:       bd.UUID,
+		WWN:        bd.DeviceAttributes.WWN,
+		Serial:     bd.DeviceAttributes.Serial,
+		Model:      bd.DeviceAttributes.Model,
+		Capacity:   bd.Capacity.Storage,
+		FileSystem: bd.FSInfo.FileSystem,
+		UsedBy:     string(bd.DevUse.UsedBy),
+	}
+	for _, bdAPI := range bdAPIList.Items {
+		if bd.UUID != "" && bdAPI.Name == bd.UUID {
+			entry.ClaimState = string(bdAPI.Status.ClaimState)
+			break
+		}
+	}
+	return entry
+}
+
+// getInventoryAction gets the action that would be taken on the device and the reason for
+// it, from the operations recorded for the device during the evaluation
+func getInventoryAction(operations []string, skipReason string, err error) (string, string) {
+	switch {
+	case err != nil:
+		return InventoryActionError, err.Error()
+	case skipReason != "":
+		return InventoryActionSkip, skipReason
+	case util.Contains(operations, controller.QuarantineBlockDeviceOperation):
+		return InventoryActionQuarantine, ""
+	case util.Contains(operations, string(controller.CreatePartitionOperation)):
+		return InventoryActionPartition, ""
+	case util.Contains(operations, controller.CreateBlockDeviceOperation),
+		util.Contains(operations, controller.UpdateBlockDeviceOperation):
+		return InventoryActionManage, ""
+	}
+	return InventoryActionSkip, ""
+}
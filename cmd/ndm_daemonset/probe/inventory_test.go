@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"errors"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetInventoryAction(t *testing.T) {
+	tests := map[string]struct {
+		operations []string
+		skipReason string
+		err        error
+		wantAction string
+		wantReason string
+	}{
+		"device filtered": {
+			skipReason: skipReasonFiltered,
+			wantAction: InventoryActionSkip,
+			wantReason: skipReasonFiltered,
+		},
+		"resource would be created": {
+			operations: []string{controller.CreateBlockDeviceOperation},
+			wantAction: InventoryActionManage,
+		},
+		"parent resource would be deactivated and partition resource updated": {
+			operations: []string{string(controller.DeactivateBlockDeviceOperation), controller.UpdateBlockDeviceOperation},
+			wantAction: InventoryActionManage,
+		},
+		"device would be partitioned": {
+			operations: []string{string(controller.CreatePartitionOperation)},
+			wantAction: InventoryActionPartition,
+		},
+		"device would be quarantined": {
+			operations: []string{controller.QuarantineBlockDeviceOperation},
+			wantAction: InventoryActionQuarantine,
+		},
+		"no operation on the device": {
+			operations: []string{},
+			wantAction: InventoryActionSkip,
+		},
+		"error while evaluating the device": {
+			err:        errors.New("cannot get parent device"),
+			wantAction: InventoryActionError,
+			wantReason: "cannot get parent device",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotAction, gotReason := getInventoryAction(tt.operations, tt.skipReason, tt.err)
+			assert.Equal(t, tt.wantAction, gotAction)
+			assert.Equal(t, tt.wantReason, gotReason)
+		})
+	}
+}
+
+func TestNewInventoryEntry(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			UUID:    "blockdevice-ccc636c88bd9ab09dde9de476309058d",
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		DevUse: blockdevice.DeviceUsage{
+			InUse:  true,
+			UsedBy: blockdevice.CStor,
+		},
+	}
+	bdAPIList := &apis.BlockDeviceList{
+		Items: []apis.BlockDevice{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "blockdevice-ccc636c88bd9ab09dde9de476309058d"},
+				Status:     apis.DeviceStatus{ClaimState: apis.BlockDeviceClaimed},
+			},
+		},
+	}
+	want := InventoryEntry{
+		DevPath:    "/dev/sdb",
+		DeviceType: blockdevice.BlockDeviceTypeDisk,
+		UUID:       "blockdevice-ccc636c88bd9ab09dde9de476309058d",
+		WWN:        "0x5000c500a1b2c3d4",
+		Serial:     "ZA1B2C3D",
+		UsedBy:     string(blockdevice.CStor),
+		ClaimState: string(apis.BlockDeviceClaimed),
+	}
+	assert.Equal(t, want, newInventoryEntry(bd, bdAPIList))
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/klog/v2"
+)
+
+// getReservedAreaIntrusions is a variable, so that it can be replaced in tests
+var getReservedAreaIntrusions = partition.GetReservedAreaIntrusions
+
+// checkReservedArea checks if a partition was created inside the area NDM reserves at the
+// start of the disks it partitions, eg: by a consumer writing its own partition table over
+// the disk. The offsets of the partitions in the GPT of the parent disk are compared with
+// the reserved area and the result is recorded on the partition.
+func (pe *ProbeEvent) checkReservedArea(bd *blockdevice.BlockDevice) {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition ||
+		bd.DependentDevices.Parent == "" || bd.PartitionInfo.PartitionNumber == 0 {
+		return
+	}
+	intrusions, partitionedByNDM, err := getReservedAreaIntrusions(bd.DependentDevices.Parent)
+	if err != nil {
+		klog.Warningf("unable to check layout of partition: %s, err: %v", bd.DevPath, err)
+		return
+	}
+	if !partitionedByNDM {
+		return
+	}
+
+	bd.PartitionInfo.LayoutChecked = true
+	bd.PartitionInfo.LayoutViolation = ""
+	for _, intrusion := range intrusions {
+		if intrusion.Number != bd.PartitionInfo.PartitionNumber {
+			continue
+		}
+		bd.PartitionInfo.LayoutViolation = fmt.Sprintf("partition starts at offset %d, inside the area of %d bytes reserved on disk %s",
+			intrusion.StartOffset, partition.ReservedAreaBytes, bd.DependentDevices.Parent)
+		klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+			"ndm.blockdevice.layout.violation", "Partition violates the layout of the parent disk",
+			bd.PartitionInfo.LayoutViolation, bd.DevPath)
+	}
+}
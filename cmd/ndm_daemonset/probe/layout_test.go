@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReservedArea(t *testing.T) {
+	// partition 2 straddles the boundary of the reserved area of the disk
+	straddling := []partition.GPTPartitionEntry{
+		{Number: 2, StartOffset: 512 * 1024},
+	}
+	tests := map[string]struct {
+		deviceType       string
+		partitionNumber  uint8
+		intrusions       []partition.GPTPartitionEntry
+		partitionedByNDM bool
+		readErr          error
+		wantChecked      bool
+		wantViolation    bool
+	}{
+		"device is not a partition": {
+			deviceType:       blockdevice.BlockDeviceTypeDisk,
+			partitionedByNDM: true,
+		},
+		"parent disk not partitioned by NDM": {
+			deviceType:      blockdevice.BlockDeviceTypePartition,
+			partitionNumber: 2,
+		},
+		"error reading the partition table": {
+			deviceType:      blockdevice.BlockDeviceTypePartition,
+			partitionNumber: 2,
+			readErr:         fmt.Errorf("no such device"),
+		},
+		"partition outside the reserved area": {
+			deviceType:       blockdevice.BlockDeviceTypePartition,
+			partitionNumber:  1,
+			intrusions:       straddling,
+			partitionedByNDM: true,
+			wantChecked:      true,
+		},
+		"partition straddling the reserved area": {
+			deviceType:       blockdevice.BlockDeviceTypePartition,
+			partitionNumber:  2,
+			intrusions:       straddling,
+			partitionedByNDM: true,
+			wantChecked:      true,
+			wantViolation:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			origGetReservedAreaIntrusions := getReservedAreaIntrusions
+			defer func() { getReservedAreaIntrusions = origGetReservedAreaIntrusions }()
+			getReservedAreaIntrusions = func(devPath string) ([]partition.GPTPartitionEntry, bool, error) {
+				assert.Equal(t, "/dev/sdb", devPath)
+				return tt.intrusions, tt.partitionedByNDM, tt.readErr
+			}
+
+			pe := &ProbeEvent{Controller: &controller.Controller{}}
+			bd := &blockdevice.BlockDevice{}
+			bd.DevPath = fmt.Sprintf("/dev/sdb%d", tt.partitionNumber)
+			bd.DeviceAttributes.DeviceType = tt.deviceType
+			bd.DependentDevices.Parent = "/dev/sdb"
+			bd.PartitionInfo.PartitionNumber = tt.partitionNumber
+
+			pe.checkReservedArea(bd)
+			assert.Equal(t, tt.wantChecked, bd.PartitionInfo.LayoutChecked)
+			assert.Equal(t, tt.wantViolation, bd.PartitionInfo.LayoutViolation != "")
+		})
+	}
+}
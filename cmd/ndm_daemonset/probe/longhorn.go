@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"os"
+	"path/filepath"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/db/kubernetes"
+
+	"k8s.io/klog/v2"
+)
+
+// longhornDiskConfigFile is the marker file written by longhorn at the root of the
+// path of every disk it uses
+const longhornDiskConfigFile = "longhorn-disk.cfg"
+
+// hostRootPath is the path at which the root filesystem of the host can be accessed,
+// i.e the root of the host's init process. It is a variable, so that it can be replaced
+// in tests.
+var hostRootPath = "/host/proc/1/root"
+
+// getLonghornDiskPath gets the mount point among the given mount points that has the
+// longhorn disk marker at its root.
+func getLonghornDiskPath(mountPoints []string) (string, bool) {
+	for _, mountPoint := range mountPoints {
+		markerPath := filepath.Join(hostRootPath, mountPoint, longhornDiskConfigFile)
+		if _, err := os.Stat(markerPath); err == nil {
+			return mountPoint, true
+		}
+	}
+	return "", false
+}
+
+// deviceInUseByLonghorn checks if the device is used as a longhorn disk and returns true if
+// further processing of the event is required. A resource tagged for longhorn is created for
+// such devices, so that they are not claimed by others, and they are never partitioned.
+func (pe *ProbeEvent) deviceInUseByLonghorn(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	if !bd.DevUse.InUse || bd.DevUse.UsedBy != blockdevice.Longhorn {
+		return true, nil
+	}
+
+	klog.Infof("device: %s in use by longhorn, %s", bd.DevPath, bd.DevUse.Reason)
+
+	if err := pe.pushTaggedDevice(bd, bdAPIList, blockdevice.Longhorn, nil); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// pushTaggedDevice creates or updates the resource of a device used by a storage engine that
+// is not managed by NDM, tagged with the name of the storage engine, so that the device is
+// not claimed by others. labels are added to the resource along with the tag. The uuid of
+// such devices does not depend on the partition table, which is never created on them.
+func (pe *ProbeEvent) pushTaggedDevice(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList,
+	engine blockdevice.StorageEngine, labels map[string]string) error {
+	annotation := map[string]string{
+		internalUUIDSchemeAnnotation: gptUUIDScheme,
+	}
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
+	if !ok {
+		var uuidUsesPath bool
+		uuid, uuidUsesPath = generateLegacyUUID(bd)
+		annotation[internalUUIDSchemeAnnotation] = legacyUUIDScheme
+		if uuidUsesPath {
+			annotation[internalNeedsIdentifierAnnotation] = controller.TrueString
+		}
+	}
+	bd.UUID = uuid
+	pe.addBlockDeviceToHierarchyCache(bd)
+
+	bdLabels := make(map[string]string, len(bd.Labels)+len(labels)+1)
+	for k, v := range bd.Labels {
+		bdLabels[k] = v
+	}
+	for k, v := range labels {
+		bdLabels[k] = v
+	}
+	bdLabels[kubernetes.BlockDeviceTagLabel] = string(engine)
+	bd.Labels = bdLabels
+
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+	if err := pe.createOrUpdateWithAnnotation(annotation, bd, existingBD); err != nil {
+		klog.Errorf("could not push %s device: %s (%s) to etcd", engine, bd.UUID, bd.DevPath)
+		return err
+	}
+	klog.Infof("Pushed %s device: %s (%s) to etcd", engine, bd.UUID, bd.DevPath)
+	return nil
+}
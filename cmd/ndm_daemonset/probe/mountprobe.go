@@ -17,6 +17,9 @@ limitations under the License.
 package probe
 
 import (
+	"fmt"
+	"strings"
+
 	"k8s.io/klog/v2"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
@@ -25,7 +28,6 @@ import (
 	"github.com/openebs/node-disk-manager/pkg/features"
 	"github.com/openebs/node-disk-manager/pkg/mount"
 	"github.com/openebs/node-disk-manager/pkg/mount/libmount"
-	"github.com/openebs/node-disk-manager/pkg/util"
 )
 
 // mountProbe contains required variables for populating diskInfo
@@ -59,22 +61,16 @@ var mountProbeRegister = func() {
 		klog.Error("unable to configure", mountProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == mountConfigKey {
-				mountProbeName = probeConfig.Name
-				mountProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
 	newRegisterProbe := &registerProbe{
 		priority:   mountProbePriority,
 		name:       mountProbeName,
 		state:      mountProbeState,
+		configKey:  mountConfigKey,
 		pi:         newMountProbeForRegistration(ctrl),
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
+	mountProbeName = newRegisterProbe.name
 	// Here we register the probe (mount probe in this case)
 	newRegisterProbe.register()
 }
@@ -129,21 +125,126 @@ func (mp *mountProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevic
 	}
 	mountProbe := newMountProbe(blockDevice.DevPath)
 	basicMountInfo, err := mountProbe.MountIdentifier.DeviceBasicMountInfo(mount.HostMountsFilePath)
-	if err != nil {
-		if err == mount.ErrAttributesNotFound {
-			klog.Infof("no mount point found for %s. clearing mount points if any",
-				blockDevice.DevPath)
-			blockDevice.FSInfo.MountPoint = nil
-			return
-		}
+	if err != nil && err != mount.ErrAttributesNotFound {
 		klog.Error(err)
 		return
 	}
 
-	blockDevice.FSInfo.MountPoint = basicMountInfo.MountPoint
-	if blockDevice.FSInfo.FileSystem == "" {
-		blockDevice.FSInfo.FileSystem = basicMountInfo.FileSystem
+	if err == mount.ErrAttributesNotFound {
+		klog.Infof("no mount point found for %s. clearing mount points if any",
+			blockDevice.DevPath)
+		blockDevice.FSInfo.MountPoint = nil
+	} else {
+		blockDevice.FSInfo.MountPoint = basicMountInfo.MountPoint
+		if blockDevice.FSInfo.FileSystem == "" {
+			blockDevice.FSInfo.FileSystem = basicMountInfo.FileSystem
+		}
+	}
+
+	mp.fillDeviceUsage(blockDevice)
+}
+
+// fillDeviceUsage marks the device as in use, if the device or any of the devices
+// layered on top of it has an active mount that is not owned by any storage engine.
+// The used-by probe will override the usage if the mount belongs to a known
+// storage engine. Devices mounted at the path of a VM datastore are marked as used
+// by the VM datastore, devices mounted at the base directory of the local-path
+// provisioner are marked as used by local-path, devices mounted at a path having the
+// longhorn disk marker are marked as used by longhorn and glusterfs bricks are marked
+// as used by glusterfs.
+func (mp *mountProbe) fillDeviceUsage(blockDevice *blockdevice.BlockDevice) {
+	isMountUsage := blockDevice.DevUse.UsedBy == blockdevice.Mounted ||
+		blockDevice.DevUse.UsedBy == blockdevice.VMDatastore ||
+		blockDevice.DevUse.UsedBy == blockdevice.Longhorn ||
+		blockDevice.DevUse.UsedBy == blockdevice.Gluster ||
+		blockDevice.DevUse.UsedBy == blockdevice.LocalPath
+	if blockDevice.DevUse.InUse && !isMountUsage {
+		return
+	}
+
+	var hierarchy blockdevice.Hierarchy
+	if mp.Controller != nil {
+		hierarchy = mp.Controller.BDHierarchy.Snapshot()
+	}
+	mountedDevice, ok := getDeviceWithActiveMount(*blockDevice, hierarchy)
+	if !ok {
+		// clear the usage, if the device was previously marked as mounted
+		if isMountUsage {
+			blockDevice.DevUse = blockdevice.DeviceUsage{}
+		}
+		return
+	}
+
+	reason := strings.Join(mountedDevice.FSInfo.MountPoint, ",")
+	if mountedDevice.DevPath != blockDevice.DevPath {
+		reason = fmt.Sprintf("%s via %s", reason, mountedDevice.DevPath)
+	}
+	blockDevice.DevUse.InUse = true
+	blockDevice.DevUse.UsedBy = blockdevice.Mounted
+	if mp.Controller != nil {
+		if datastorePath, ok := mp.Controller.GetVMDatastorePath(mountedDevice.FSInfo.MountPoint); ok {
+			blockDevice.DevUse.UsedBy = blockdevice.VMDatastore
+			reason = fmt.Sprintf("%s (vm datastore: %s)", reason, datastorePath)
+		} else if basePath, ok := mp.Controller.GetLocalPathBasePath(mountedDevice.FSInfo.MountPoint); ok {
+			blockDevice.DevUse.UsedBy = blockdevice.LocalPath
+			reason = fmt.Sprintf("%s (local-path: %s)", reason, basePath)
+		}
+	}
+	if blockDevice.DevUse.UsedBy == blockdevice.Mounted {
+		if diskPath, ok := getLonghornDiskPath(mountedDevice.FSInfo.MountPoint); ok {
+			blockDevice.DevUse.UsedBy = blockdevice.Longhorn
+			reason = fmt.Sprintf("%s (longhorn disk: %s)", reason, diskPath)
+		}
+	}
+	if blockDevice.DevUse.UsedBy == blockdevice.Mounted {
+		if brick, ok := getGlusterBrick(mountedDevice.FSInfo.MountPoint); ok {
+			blockDevice.DevUse.UsedBy = blockdevice.Gluster
+			reason = fmt.Sprintf("%s (glusterfs brick: %s, volume-id: %s)", reason, brick.Path, brick.VolumeID)
+		}
+	}
+	blockDevice.DevUse.Reason = reason
+	klog.V(4).Infof("device: %s Used by: %s (%s) filled by mount probe",
+		blockDevice.DevPath, blockDevice.DevUse.UsedBy, reason)
+}
+
+// getDeviceWithActiveMount returns the device that has a live mount among the given
+// device, its ancestors and the devices layered on top of it (dm / LVM holders), as
+// found in the hierarchy. eg: for a PV member of an LVM volume group, the mounted LV
+// is returned.
+func getDeviceWithActiveMount(bd blockdevice.BlockDevice, hierarchy blockdevice.Hierarchy) (blockdevice.BlockDevice, bool) {
+	if len(bd.FSInfo.MountPoint) > 0 {
+		return bd, true
+	}
+
+	visited := map[string]bool{bd.DevPath: true}
+	// devices that are yet to be checked for a live mount
+	devices := make([]string, 0)
+	if bd.DependentDevices.Parent != "" {
+		devices = append(devices, bd.DependentDevices.Parent)
+	}
+	devices = append(devices, bd.DependentDevices.Holders...)
+
+	for len(devices) > 0 {
+		devPath := devices[0]
+		devices = devices[1:]
+		if visited[devPath] {
+			continue
+		}
+		visited[devPath] = true
+
+		relatedBD, ok := hierarchy[devPath]
+		if !ok {
+			continue
+		}
+		if len(relatedBD.FSInfo.MountPoint) > 0 {
+			return relatedBD, true
+		}
+		if relatedBD.DependentDevices.Parent != "" {
+			devices = append(devices, relatedBD.DependentDevices.Parent)
+		}
+		devices = append(devices, relatedBD.DependentDevices.Holders...)
 	}
+	return blockdevice.BlockDevice{}, false
 }
 
 func (mp *mountProbe) setupEpoll() error {
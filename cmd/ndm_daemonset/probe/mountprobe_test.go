@@ -24,7 +24,10 @@ import (
 	"testing"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/mount"
+
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -143,3 +146,330 @@ func exitFakeRoot() error {
 func createMountsFile(dest string) error {
 	return ioutil.WriteFile(dest, []byte(sampleMountsFile), 0444)
 }
+
+// lvmHierarchy is the hierarchy of an LVM volume group made of the PV members
+// /dev/sdb and /dev/sdc1, with the LV /dev/dm-0 mounted at /mnt/data
+func lvmHierarchy() blockdevice.Hierarchy {
+	return blockdevice.Hierarchy{
+		"/dev/sdb": {
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdb"},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Holders: []string{"/dev/dm-0"},
+			},
+		},
+		"/dev/sdc": {
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdc"},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Partitions: []string{"/dev/sdc1"},
+			},
+		},
+		"/dev/sdc1": {
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdc1"},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Parent:  "/dev/sdc",
+				Holders: []string{"/dev/dm-0"},
+			},
+		},
+		"/dev/dm-0": {
+			Identifier: blockdevice.Identifier{DevPath: "/dev/dm-0"},
+			DependentDevices: blockdevice.DependentBlockDevices{
+				Slaves: []string{"/dev/sdb", "/dev/sdc1"},
+			},
+			FSInfo: blockdevice.FileSystemInformation{
+				FileSystem: "ext4",
+				MountPoint: []string{"/mnt/data"},
+			},
+		},
+		"/dev/sdd": {
+			Identifier: blockdevice.Identifier{DevPath: "/dev/sdd"},
+		},
+	}
+}
+
+func TestGetDeviceWithActiveMount(t *testing.T) {
+	hierarchy := lvmHierarchy()
+	tests := map[string]struct {
+		devPath           string
+		wantMountedDevice string
+		wantOK            bool
+	}{
+		"mounted device": {
+			devPath:           "/dev/dm-0",
+			wantMountedDevice: "/dev/dm-0",
+			wantOK:            true,
+		},
+		"PV member disk of a mounted LV": {
+			devPath:           "/dev/sdb",
+			wantMountedDevice: "/dev/dm-0",
+			wantOK:            true,
+		},
+		"PV member partition of a mounted LV": {
+			devPath:           "/dev/sdc1",
+			wantMountedDevice: "/dev/dm-0",
+			wantOK:            true,
+		},
+		"device without any mount": {
+			devPath:           "/dev/sdd",
+			wantMountedDevice: "",
+			wantOK:            false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mountedDevice, ok := getDeviceWithActiveMount(hierarchy[test.devPath], hierarchy)
+			assert.Equal(t, test.wantOK, ok)
+			assert.Equal(t, test.wantMountedDevice, mountedDevice.DevPath)
+		})
+	}
+}
+
+func TestMountProbeFillDeviceUsage(t *testing.T) {
+	hierarchy := lvmHierarchy()
+	tests := map[string]struct {
+		bd             blockdevice.BlockDevice
+		datastorePaths []string
+		wantDevUse     blockdevice.DeviceUsage
+	}{
+		"mounted device is in use": {
+			bd: hierarchy["/dev/dm-0"],
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.Mounted,
+				Reason: "/mnt/data",
+			},
+		},
+		"PV member of a mounted LV is in use": {
+			bd: hierarchy["/dev/sdb"],
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.Mounted,
+				Reason: "/mnt/data via /dev/dm-0",
+			},
+		},
+		"usage by a storage engine is not overridden": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sde"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/var/openebs/local"},
+				},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.LocalPV,
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.LocalPV,
+			},
+		},
+		"unmounted device is no longer in use": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdd"},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.Mounted,
+					Reason: "/mnt/data",
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{},
+		},
+		"device mounted at the libvirt storage pool path is a vm datastore": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdf"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/var/lib/libvirt/images"},
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.VMDatastore,
+				Reason: "/var/lib/libvirt/images (vm datastore: /var/lib/libvirt/images)",
+			},
+		},
+		"device mounted at a configured datastore path is a vm datastore": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdf"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/vmstore/pool1"},
+				},
+			},
+			datastorePaths: []string{"/vmstore"},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.VMDatastore,
+				Reason: "/vmstore/pool1 (vm datastore: /vmstore)",
+			},
+		},
+		"unmounted vm datastore is no longer in use": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdd"},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.VMDatastore,
+					Reason: "/var/lib/libvirt/images (vm datastore: /var/lib/libvirt/images)",
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mp := &mountProbe{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(hierarchy),
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						VMDatastorePaths: test.datastorePaths,
+					},
+				},
+			}
+			bd := test.bd
+			mp.fillDeviceUsage(&bd)
+			assert.Equal(t, test.wantDevUse, bd.DevUse)
+		})
+	}
+}
+
+func TestMountProbeFillDeviceUsageLocalPath(t *testing.T) {
+	// the LV of the volume group is mounted at the default base directory of the
+	// local-path provisioner
+	hierarchy := lvmHierarchy()
+	lv := hierarchy["/dev/dm-0"]
+	lv.FSInfo.MountPoint = []string{"/opt/local-path-provisioner"}
+	hierarchy[lv.DevPath] = lv
+
+	tests := map[string]struct {
+		bd         blockdevice.BlockDevice
+		basePaths  []string
+		wantDevUse blockdevice.DeviceUsage
+	}{
+		"disk mounted at the default local-path directory": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sde"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/opt/local-path-provisioner"},
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.LocalPath,
+				Reason: "/opt/local-path-provisioner (local-path: /opt/local-path-provisioner)",
+			},
+		},
+		"PV member of an LV mounted at the default local-path directory": {
+			bd: hierarchy["/dev/sdc1"],
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.LocalPath,
+				Reason: "/opt/local-path-provisioner via /dev/dm-0 (local-path: /opt/local-path-provisioner)",
+			},
+		},
+		"disk mounted at a configured local-path directory": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sde"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/var/local-path"},
+				},
+			},
+			basePaths: []string{"/var/local-path"},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.LocalPath,
+				Reason: "/var/local-path (local-path: /var/local-path)",
+			},
+		},
+		"unmounted local-path disk is no longer in use": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdd"},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.LocalPath,
+					Reason: "/opt/local-path-provisioner (local-path: /opt/local-path-provisioner)",
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mp := &mountProbe{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(hierarchy),
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						LocalPathBasePaths: test.basePaths,
+					},
+				},
+			}
+			bd := test.bd
+			mp.fillDeviceUsage(&bd)
+			assert.Equal(t, test.wantDevUse, bd.DevUse)
+		})
+	}
+}
+
+func TestMountProbeFillDeviceUsageLonghorn(t *testing.T) {
+	origHostRootPath := hostRootPath
+	defer func() { hostRootPath = origHostRootPath }()
+	hostRootPath = t.TempDir()
+
+	// fake host root, having a longhorn disk mounted at /var/lib/longhorn
+	longhornDiskPath := path.Join(hostRootPath, "/var/lib/longhorn")
+	assert.NoError(t, os.MkdirAll(longhornDiskPath, 0700))
+	assert.NoError(t, ioutil.WriteFile(path.Join(longhornDiskPath, longhornDiskConfigFile),
+		[]byte(`{"diskUUID":"8c0b5e6a-4d2e-4f4c-9a61-0f3a1e9b7c21"}`), 0600))
+	assert.NoError(t, os.MkdirAll(path.Join(hostRootPath, "/mnt/data"), 0700))
+
+	tests := map[string]struct {
+		bd         blockdevice.BlockDevice
+		wantDevUse blockdevice.DeviceUsage
+	}{
+		"device mounted at a path with the longhorn disk marker is used by longhorn": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdg"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/var/lib/longhorn"},
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.Longhorn,
+				Reason: "/var/lib/longhorn (longhorn disk: /var/lib/longhorn)",
+			},
+		},
+		"device mounted at a path without the marker is mounted": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdh"},
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/mnt/data"},
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{
+				InUse:  true,
+				UsedBy: blockdevice.Mounted,
+				Reason: "/mnt/data",
+			},
+		},
+		"unmounted longhorn disk is no longer in use": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdg"},
+				DevUse: blockdevice.DeviceUsage{
+					InUse:  true,
+					UsedBy: blockdevice.Longhorn,
+					Reason: "/var/lib/longhorn (longhorn disk: /var/lib/longhorn)",
+				},
+			},
+			wantDevUse: blockdevice.DeviceUsage{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mp := &mountProbe{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{}),
+				},
+			}
+			bd := test.bd
+			mp.fillDeviceUsage(&bd)
+			assert.Equal(t, test.wantDevUse, bd.DevUse)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/klog/v2"
+)
+
+// osMountPoints are the mount points of the partitions created by os installers
+var osMountPoints = []string{"/", "/boot", "/boot/efi", "/home", "/usr", "/var"}
+
+// hasOSPartition is a variable, so that it can be replaced in tests
+var hasOSPartition = partition.HasOSPartition
+
+// isOnOSDisk checks whether the device is a disk having a partition used by the os, or a
+// partition of such a disk. Installers create the boot, root, swap, /home and recovery
+// partitions on the same disk, so once any of the partitions is used by the os, the disk
+// and all its partitions are treated as the os disk and are never modified by NDM, even
+// if the os disk filter did not exclude them.
+//
+// The partitions in the hierarchy cache are checked first, and the partition table of
+// the disk is read only if none of the known partitions is used by the os.
+func (pe *ProbeEvent) isOnOSDisk(bd blockdevice.BlockDevice) bool {
+	var diskPath string
+	var partitions []string
+
+	switch bd.DeviceAttributes.DeviceType {
+	case blockdevice.BlockDeviceTypeDisk:
+		if len(bd.DependentDevices.Partitions) == 0 {
+			return false
+		}
+		diskPath = bd.DevPath
+		partitions = bd.DependentDevices.Partitions
+	case blockdevice.BlockDeviceTypePartition:
+		if isOSPartition(bd) {
+			return true
+		}
+		diskPath = bd.DependentDevices.Parent
+		if parentBD, ok := pe.Controller.BDHierarchy.Get(diskPath); ok {
+			partitions = parentBD.DependentDevices.Partitions
+		}
+	default:
+		return false
+	}
+	if diskPath == "" {
+		return false
+	}
+
+	for _, partitionPath := range partitions {
+		partitionBD, ok := pe.Controller.BDHierarchy.Get(partitionPath)
+		if ok && isOSPartition(partitionBD) {
+			return true
+		}
+	}
+
+	// a disk whose partition table cannot be read may be the os disk, so it is skipped
+	// rather than being treated as a disk without os partitions.
+	ok, err := hasOSPartition(diskPath)
+	if err != nil {
+		klog.Errorf("unable to read partition table of device: %s to check for os partitions, "+
+			"treating it as os disk: %v", diskPath, err)
+		return true
+	}
+	return ok
+}
+
+// isOSPartition checks whether the partition is used by the os, i.e it is a boot, root,
+// swap, /home or recovery partition, or is mounted at one of the os mount points.
+func isOSPartition(bd blockdevice.BlockDevice) bool {
+	if blockdevice.IsOSPartitionType(bd.PartitionInfo.PartitionType) {
+		return true
+	}
+	if bd.FSInfo.FileSystem == blockdevice.FileSystemSwap {
+		return true
+	}
+	for _, mountPoint := range bd.FSInfo.MountPoint {
+		if util.Contains(osMountPoints, mountPoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// deactivateOSDiskBlockDevices deactivates the unclaimed resources of the os disk and its
+// partitions on this node. The os partition of a disk may be found only after the resources
+// of the disk or its other partitions were created, eg: if the swap partition is the last
+// partition of the disk.
+func (pe *ProbeEvent) deactivateOSDiskBlockDevices(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) {
+	diskPath := bd.DevPath
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		diskPath = bd.DependentDevices.Parent
+	}
+	paths := []string{diskPath}
+	if diskBD, ok := pe.Controller.BDHierarchy.Get(diskPath); ok {
+		paths = append(paths, diskBD.DependentDevices.Partitions...)
+	}
+	nodeName := pe.Controller.NodeAttributes[controller.NodeNameKey]
+
+	for _, bdAPI := range bdAPIList.Items {
+		if !util.Contains(paths, bdAPI.Spec.Path) || bdAPI.Spec.NodeAttributes.NodeName != nodeName ||
+			bdAPI.Status.State != controller.NDMActive {
+			continue
+		}
+		if bdAPI.Status.ClaimState != apis.BlockDeviceUnclaimed {
+			klog.Warningf("eventcode=%s msg=%s rname=%v",
+				"ndm.blockdevice.osdisk.claimed", "Claimed blockdevice is on the os disk",
+				bdAPI.Name)
+			continue
+		}
+		klog.Infof("device: %s is on the os disk: %s, deactivating blockdevice: %s",
+			bdAPI.Spec.Path, diskPath, bdAPI.Name)
+		pe.Controller.DeactivateBlockDevice(bdAPI, controller.AuditCause{
+			Trigger: controller.AuditTriggerDeviceAdded,
+			Reason:  fmt.Sprintf("device is on the os disk: %s", diskPath),
+		})
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsOnOSDisk(t *testing.T) {
+	disk := func(partitions ...string) blockdevice.BlockDevice {
+		bd := blockdevice.BlockDevice{}
+		bd.DevPath = "/dev/sda"
+		bd.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeDisk
+		bd.DependentDevices.Partitions = partitions
+		return bd
+	}
+	part := func(devPath, partitionType string) blockdevice.BlockDevice {
+		bd := blockdevice.BlockDevice{}
+		bd.DevPath = devPath
+		bd.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypePartition
+		bd.DependentDevices.Parent = "/dev/sda"
+		bd.PartitionInfo.PartitionType = partitionType
+		return bd
+	}
+	// withFS sets the filesystem and the mount point of the partition
+	withFS := func(bd blockdevice.BlockDevice, fileSystem string, mountPoints ...string) blockdevice.BlockDevice {
+		bd.FSInfo.FileSystem = fileSystem
+		bd.FSInfo.MountPoint = mountPoints
+		return bd
+	}
+	linuxFilesystem := "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	mbrLinux := "0x83"
+
+	tests := map[string]struct {
+		bd        blockdevice.BlockDevice
+		hierarchy blockdevice.Hierarchy
+		// diskHasOSPartition is the result of reading the partition table of the disk
+		diskHasOSPartition bool
+		diskReadErr        error
+		want               bool
+	}{
+		"disk with BIOS boot partition": {
+			bd: disk("/dev/sda1", "/dev/sda2"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": part("/dev/sda1", "21686148-6449-6E6F-744E-656564454649"),
+				"/dev/sda2": part("/dev/sda2", linuxFilesystem),
+			},
+			want: true,
+		},
+		"disk with EFI System partition": {
+			bd: disk("/dev/sda1", "/dev/sda2"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": part("/dev/sda1", "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"),
+				"/dev/sda2": part("/dev/sda2", linuxFilesystem),
+			},
+			want: true,
+		},
+		"disk with EFI System partition on MBR": {
+			bd: disk("/dev/sda1"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": part("/dev/sda1", "0xef"),
+			},
+			want: true,
+		},
+		"data partition of disk with EFI System partition": {
+			bd: part("/dev/sda2", linuxFilesystem),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda":  disk("/dev/sda1", "/dev/sda2"),
+				"/dev/sda1": part("/dev/sda1", "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"),
+			},
+			want: true,
+		},
+		"os partition not yet in cache, found from the partition table": {
+			bd:                 part("/dev/sda2", linuxFilesystem),
+			hierarchy:          blockdevice.Hierarchy{},
+			diskHasOSPartition: true,
+			want:               true,
+		},
+		"data partition of disk with root and swap partitions": {
+			bd: part("/dev/sda3", linuxFilesystem),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda":  disk("/dev/sda1", "/dev/sda2", "/dev/sda3"),
+				"/dev/sda1": part("/dev/sda1", "4f68bce3-e8cd-4db1-96e7-fbcaf984b709"),
+				"/dev/sda2": part("/dev/sda2", "0657fd6d-a4ab-43c4-84e5-0933c84b4f4f"),
+			},
+			want: true,
+		},
+		"disk with root and swap partitions on MBR": {
+			bd: disk("/dev/sda1", "/dev/sda2", "/dev/sda3"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": withFS(part("/dev/sda1", mbrLinux), "ext4", "/"),
+				"/dev/sda2": withFS(part("/dev/sda2", mbrLinux), "swap"),
+				"/dev/sda3": withFS(part("/dev/sda3", mbrLinux), "ext4"),
+			},
+			want: true,
+		},
+		"swap partition": {
+			bd:        withFS(part("/dev/sda2", mbrLinux), "swap"),
+			hierarchy: blockdevice.Hierarchy{},
+			want:      true,
+		},
+		"data partition of disk with /home mounted": {
+			bd: withFS(part("/dev/sda3", mbrLinux), "xfs"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda":  disk("/dev/sda1", "/dev/sda2", "/dev/sda3"),
+				"/dev/sda2": withFS(part("/dev/sda2", mbrLinux), "ext4", "/home"),
+			},
+			want: true,
+		},
+		"data partition of disk with other data partitions": {
+			bd: withFS(part("/dev/sda2", mbrLinux), "xfs", "/var/lib/data"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda":  disk("/dev/sda1", "/dev/sda2"),
+				"/dev/sda1": withFS(part("/dev/sda1", mbrLinux), "ext4", "/mnt/data"),
+			},
+			want: false,
+		},
+		"disk with data partitions only": {
+			bd: disk("/dev/sda1"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": part("/dev/sda1", linuxFilesystem),
+			},
+			want: false,
+		},
+		"disk without partitions": {
+			bd:                 disk(),
+			hierarchy:          blockdevice.Hierarchy{},
+			diskHasOSPartition: true,
+			want:               false,
+		},
+		"error reading partition table": {
+			bd: disk("/dev/sda1"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sda1": part("/dev/sda1", linuxFilesystem),
+			},
+			diskReadErr: fmt.Errorf("permission denied"),
+			want:        true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func(f func(string) (bool, error)) { hasOSPartition = f }(hasOSPartition)
+			hasOSPartition = func(devPath string) (bool, error) {
+				assert.Equal(t, "/dev/sda", devPath)
+				return tt.diskHasOSPartition, tt.diskReadErr
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.hierarchy),
+				},
+			}
+			assert.Equal(t, tt.want, pe.isOnOSDisk(tt.bd))
+		})
+	}
+}
+
+func TestDeactivateOSDiskBlockDevices(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+
+	newBDAPI := func(name, path, nodeName string, claimState apis.DeviceClaimState) apis.BlockDevice {
+		return apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: apis.DeviceSpec{
+				Path:           path,
+				NodeAttributes: apis.NodeAttribute{NodeName: nodeName},
+			},
+			Status: apis.DeviceStatus{
+				ClaimState: claimState,
+				State:      controller.NDMActive,
+			},
+		}
+	}
+	// the data partition of the os disk got a resource before the swap partition was
+	// found, and a disk at the same path on another node is also managed
+	for _, bdAPI := range []apis.BlockDevice{
+		newBDAPI("blockdevice-data", "/dev/sda3", "node1", apis.BlockDeviceUnclaimed),
+		newBDAPI("blockdevice-claimed", "/dev/sda4", "node1", apis.BlockDeviceClaimed),
+		newBDAPI("blockdevice-other-node", "/dev/sda3", "node2", apis.BlockDeviceUnclaimed),
+		newBDAPI("blockdevice-other-disk", "/dev/sdb", "node1", apis.BlockDeviceUnclaimed),
+	} {
+		assert.NoError(t, cl.Create(context.TODO(), &bdAPI))
+	}
+	bdAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+	disk := blockdevice.BlockDevice{}
+	disk.DevPath = "/dev/sda"
+	disk.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeDisk
+	disk.DependentDevices.Partitions = []string{"/dev/sda1", "/dev/sda2", "/dev/sda3", "/dev/sda4"}
+	swap := blockdevice.BlockDevice{}
+	swap.DevPath = "/dev/sda2"
+	swap.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypePartition
+	swap.DependentDevices.Parent = "/dev/sda"
+	swap.FSInfo.FileSystem = blockdevice.FileSystemSwap
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:      cl,
+			BDHierarchy:    blockdevice.NewHierarchyCache(blockdevice.Hierarchy{disk.DevPath: disk, swap.DevPath: swap}),
+			NodeAttributes: map[string]string{controller.NodeNameKey: "node1"},
+		},
+	}
+	pe.deactivateOSDiskBlockDevices(swap, bdAPIList)
+
+	wantStates := map[string]string{
+		"blockdevice-data":       controller.NDMInactive,
+		"blockdevice-claimed":    controller.NDMActive,
+		"blockdevice-other-node": controller.NDMActive,
+		"blockdevice-other-disk": controller.NDMActive,
+	}
+	for name, wantState := range wantStates {
+		gotBDAPI := &apis.BlockDevice{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: name}, gotBDAPI))
+		assert.Equal(t, wantState, string(gotBDAPI.Status.State), name)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/util"
+)
+
+// getPeerOwner gets the NDM instance that manages the resource of the device, if it is
+// another instance. The resource is looked up by the uuid of the device, or by the path
+// if the device cannot be identified yet, eg: a disk partitioned by the peer instance
+// whose partition table is being reread.
+func (pe *ProbeEvent) getPeerOwner(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (string, bool) {
+	if pe.Controller.InstanceID == "" || bdAPIList == nil {
+		return "", false
+	}
+	uuids, ok := pe.generateUUIDsOfAllVersions(bd)
+	hostName := pe.Controller.NodeAttributes[controller.HostNameKey]
+	for i := range bdAPIList.Items {
+		bdAPI := &bdAPIList.Items[i]
+		if bdAPI.Labels[controller.KubernetesHostNameLabel] != hostName {
+			continue
+		}
+		if (ok && util.Contains(uuids, bdAPI.Name)) || (!ok && bdAPI.Spec.Path == bd.DevPath) {
+			if pe.Controller.IsManagedByPeer(bdAPI) {
+				return bdAPI.Annotations[controller.ManagedByAnnotation], true
+			}
+		}
+	}
+	return "", false
+}
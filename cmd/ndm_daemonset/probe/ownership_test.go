@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAddBlockDeviceWithPeerInstance(t *testing.T) {
+	hostName := "fake-host-name"
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			Model:      "ST1000NM0055",
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 1024 * 1024 * 1024,
+		},
+	}
+	uuid, _, _ := generateUUID(bd)
+
+	tests := map[string]struct {
+		owner      string
+		instanceID string
+		wantOwner  string
+		wantUpdate bool
+	}{
+		"instance B skips device owned by instance A": {
+			owner:      "ndm-a",
+			instanceID: "ndm-b",
+			wantOwner:  "ndm-a",
+			wantUpdate: false,
+		},
+		"instance B manages and stamps an unowned device": {
+			owner:      "",
+			instanceID: "ndm-b",
+			wantOwner:  "ndm-b",
+			wantUpdate: true,
+		},
+		"instance B manages its own device": {
+			owner:      "ndm-b",
+			instanceID: "ndm-b",
+			wantOwner:  "ndm-b",
+			wantUpdate: true,
+		},
+		"ownership ignored without an instance id": {
+			owner:      "ndm-a",
+			instanceID: "",
+			wantOwner:  "ndm-a",
+			wantUpdate: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			bdAPI := apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: uuid,
+					Labels: map[string]string{
+						controller.KubernetesHostNameLabel: hostName,
+					},
+					Annotations: map[string]string{
+						internalUUIDSchemeAnnotation: gptUUIDScheme,
+					},
+				},
+				Spec: apis.DeviceSpec{
+					Path: bd.DevPath,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: apis.BlockDeviceUnclaimed,
+					State:      controller.NDMActive,
+				},
+			}
+			if tt.owner != "" {
+				bdAPI.Annotations[controller.ManagedByAnnotation] = tt.owner
+			}
+			assert.NoError(t, cl.Create(context.TODO(), &bdAPI))
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:      cl,
+					BDHierarchy:    blockdevice.NewHierarchyCache(blockdevice.Hierarchy{bd.DevPath: bd}),
+					NodeAttributes: map[string]string{controller.HostNameKey: hostName},
+					InstanceID:     tt.instanceID,
+				},
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, bdAPIList))
+
+			gotBDAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, gotBDAPI))
+			assert.Equal(t, tt.wantOwner, gotBDAPI.Annotations[controller.ManagedByAnnotation])
+			if tt.wantUpdate {
+				assert.Equal(t, bd.Capacity.Storage, gotBDAPI.Spec.Capacity.Storage)
+			} else {
+				assert.Zero(t, gotBDAPI.Spec.Capacity.Storage)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+
+	"k8s.io/klog/v2"
+)
+
+// getParentDevice gets the parent disk of the partition from the hierarchy cache. If the
+// parent is not found at the path referenced by the partition, eg: the disk was renumbered
+// from /dev/sda to /dev/sdb on a bus rescan, the parent is matched by a stable identifier
+// instead, and the reference to the parent in the cached partition is repaired.
+func (pe *ProbeEvent) getParentDevice(bd blockdevice.BlockDevice) (blockdevice.BlockDevice, bool) {
+	if parent, ok := pe.Controller.BDHierarchy.Get(bd.DependentDevices.Parent); ok {
+		return parent, true
+	}
+	parent, ok := findParentByIdentifier(bd, pe.Controller.BDHierarchy.Snapshot())
+	if !ok {
+		return blockdevice.BlockDevice{}, false
+	}
+	klog.Infof("eventcode=%s msg=%s rname=%v old=%s new=%s",
+		"ndm.blockdevice.parent.repaired", "Parent of partition found at a new path",
+		bd.DevPath, bd.DependentDevices.Parent, parent.DevPath)
+	if cached, ok := pe.Controller.BDHierarchy.Get(bd.DevPath); ok &&
+		cached.DependentDevices.Parent == bd.DependentDevices.Parent {
+		cached.DependentDevices.Parent = parent.DevPath
+		pe.Controller.BDHierarchy.Set(bd.DevPath, cached)
+	}
+	return parent, true
+}
+
+// findParentByIdentifier finds the parent disk of the partition among the cached devices,
+// using the partition table uuid, or the WWN and serial, that udev reports for a partition
+// from its parent disk. false is returned if no disk, or more than one disk, matches.
+func findParentByIdentifier(bd blockdevice.BlockDevice, hierarchy blockdevice.Hierarchy) (blockdevice.BlockDevice, bool) {
+	var parent blockdevice.BlockDevice
+	matches := 0
+	for devPath, cachedBD := range hierarchy {
+		if devPath == bd.DevPath ||
+			cachedBD.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+			continue
+		}
+		if isParentByIdentifier(bd, cachedBD) {
+			parent = cachedBD
+			matches++
+		}
+	}
+	if matches != 1 {
+		return blockdevice.BlockDevice{}, false
+	}
+	return parent, true
+}
+
+// isParentByIdentifier checks if the disk is the parent of the partition, using the
+// partition table uuid if the partition has one, else the WWN and serial
+func isParentByIdentifier(partition, disk blockdevice.BlockDevice) bool {
+	if partition.PartitionInfo.PartitionTableUUID != "" {
+		return disk.PartitionInfo.PartitionTableUUID == partition.PartitionInfo.PartitionTableUUID
+	}
+	return partition.DeviceAttributes.WWN != "" &&
+		disk.DeviceAttributes.WWN == partition.DeviceAttributes.WWN &&
+		disk.DeviceAttributes.Serial == partition.DeviceAttributes.Serial
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newRenumberedDisk(devPath, wwn, partitionTableUUID string) blockdevice.BlockDevice {
+	return blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: devPath,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        wwn,
+			Serial:     "ZA1B2C3D",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionTableType: "gpt",
+			PartitionTableUUID: partitionTableUUID,
+		},
+	}
+}
+
+func TestFindParentByIdentifier(t *testing.T) {
+	partition := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionTableUUID: "6b3f8c1e-2d4a-4e5b-9f7c-1a2b3c4d5e6f",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: "/dev/sda",
+		},
+	}
+	withoutTableUUID := partition
+	withoutTableUUID.PartitionInfo.PartitionTableUUID = ""
+
+	tests := map[string]struct {
+		partition  blockdevice.BlockDevice
+		hierarchy  blockdevice.Hierarchy
+		wantParent string
+		wantOk     bool
+	}{
+		"parent matched by partition table uuid": {
+			partition: partition,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": newRenumberedDisk("/dev/sdb", "0x5000c500a1b2c3d4", "6b3f8c1e-2d4a-4e5b-9f7c-1a2b3c4d5e6f"),
+				"/dev/sdc": newRenumberedDisk("/dev/sdc", "0x5000c500ffffffff", "0a0b0c0d-0000-4000-8000-000000000000"),
+			},
+			wantParent: "/dev/sdb",
+			wantOk:     true,
+		},
+		"parent matched by wwn and serial": {
+			partition: withoutTableUUID,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": newRenumberedDisk("/dev/sdb", "0x5000c500a1b2c3d4", ""),
+				"/dev/sdc": newRenumberedDisk("/dev/sdc", "0x5000c500ffffffff", ""),
+			},
+			wantParent: "/dev/sdb",
+			wantOk:     true,
+		},
+		"no disk with the identifier": {
+			partition: partition,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdc": newRenumberedDisk("/dev/sdc", "0x5000c500ffffffff", "0a0b0c0d-0000-4000-8000-000000000000"),
+			},
+			wantOk: false,
+		},
+		"more than one disk with the identifier": {
+			partition: withoutTableUUID,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": newRenumberedDisk("/dev/sdb", "0x5000c500a1b2c3d4", ""),
+				"/dev/sdc": newRenumberedDisk("/dev/sdc", "0x5000c500a1b2c3d4", ""),
+			},
+			wantOk: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := findParentByIdentifier(tt.partition, tt.hierarchy)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantParent, got.DevPath)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithRenumberedParent(t *testing.T) {
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, _ *blockdevice.BlockDevice) {}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+
+	// the disk was at /dev/sda, and moved to /dev/sdb on a bus rescan. The cached partition
+	// still references the old path of its parent.
+	parent := newRenumberedDisk("/dev/sdb", "0x5000c500a1b2c3d4", "6b3f8c1e-2d4a-4e5b-9f7c-1a2b3c4d5e6f")
+	parent.DependentDevices.Partitions = []string{"/dev/sdb1"}
+	partition := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionTableUUID: "6b3f8c1e-2d4a-4e5b-9f7c-1a2b3c4d5e6f",
+			PartitionEntryUUID: "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c41",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: "/dev/sda",
+		},
+		DecisionTrace: &blockdevice.DecisionTrace{},
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset: cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+				parent.DevPath:    parent,
+				partition.DevPath: partition,
+			}),
+		},
+	}
+
+	assert.NoError(t, pe.addBlockDevice(partition, &apis.BlockDeviceList{}))
+	assert.True(t, util.Contains(partition.DecisionTrace.Steps(), "parent:repaired"))
+
+	cached, ok := pe.Controller.BDHierarchy.Get(partition.DevPath)
+	assert.True(t, ok)
+	assert.Equal(t, parent.DevPath, cached.DependentDevices.Parent)
+
+	bdAPIList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+	assert.Equal(t, 1, len(bdAPIList.Items))
+	assert.Equal(t, partition.DevPath, bdAPIList.Items[0].Spec.Path)
+}
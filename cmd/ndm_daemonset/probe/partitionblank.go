@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/blkid"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/klog/v2"
+)
+
+// probePartitionSignatures is a variable, so that it can be replaced in tests
+var probePartitionSignatures = func(devPath string) ([]string, error) {
+	return (&blkid.DeviceIdentifier{DevPath: devPath}).GetOnDiskSignatures()
+}
+
+// checkPartitionBlank probes the partition NDM has just created on a blank disk again,
+// if the verification is enabled, and records the signatures found on it. The partition
+// should be blank, a signature means that the blank disk detection missed the contents
+// of the disk. The signatures reported by the add event are also considered.
+func (pe *ProbeEvent) checkPartitionBlank(bd *blockdevice.BlockDevice) {
+	if !pe.Controller.VerifyPartitionBlank {
+		return
+	}
+	signatures := make([]string, 0)
+	if bd.FSInfo.FileSystem != "" {
+		signatures = append(signatures, bd.FSInfo.FileSystem)
+	}
+	for _, signature := range bd.FSInfo.Signatures {
+		if !util.Contains(signatures, signature) {
+			signatures = append(signatures, signature)
+		}
+	}
+	probed, err := probePartitionSignatures(bd.DevPath)
+	if err != nil {
+		klog.Warningf("unable to probe signatures of partition: %s, %v", bd.DevPath, err)
+		if len(signatures) == 0 {
+			// the partition cannot be said to be blank
+			return
+		}
+	}
+	for _, signature := range probed {
+		if !util.Contains(signatures, signature) {
+			signatures = append(signatures, signature)
+		}
+	}
+
+	bd.PartitionInfo.BlankChecked = true
+	bd.PartitionInfo.UnexpectedSignatures = signatures
+	if len(signatures) == 0 {
+		bd.DecisionTrace.Add("partition:verified-blank")
+		return
+	}
+	bd.DecisionTrace.Add("partition:unexpected-signature")
+	klog.Warningf("eventcode=%s msg=%s signatures=%q rname=%v parent=%s",
+		"ndm.blockdevice.partition.not.blank", "Partition created on a blank disk has signatures",
+		strings.Join(signatures, ","), bd.DevPath, bd.DependentDevices.Parent)
+}
+
+// quarantineUnexpectedSignature quarantines the partition created by NDM if signatures
+// were found on it, so that it is not offered as a clean device. true is returned if the
+// partition was quarantined.
+func (pe *ProbeEvent) quarantineUnexpectedSignature(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	if len(bd.PartitionInfo.UnexpectedSignatures) == 0 {
+		return false, nil
+	}
+	reason := fmt.Sprintf("UnexpectedSignature: partition created on a blank disk has the signatures: %s",
+		strings.Join(bd.PartitionInfo.UnexpectedSignatures, ","))
+	return true, pe.quarantineBlockDevice(bd, reason, bdAPIList)
+}
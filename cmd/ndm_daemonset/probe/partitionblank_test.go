@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckPartitionBlank(t *testing.T) {
+	tests := map[string]struct {
+		disabled       bool
+		fsInfo         blockdevice.FileSystemInformation
+		probed         []string
+		probeErr       error
+		wantChecked    bool
+		wantSignatures []string
+	}{
+		"verification disabled": {
+			disabled: true,
+			probed:   []string{"xfs"},
+		},
+		"blank partition": {
+			probed:         []string{},
+			wantChecked:    true,
+			wantSignatures: []string{},
+		},
+		"signature found on probing the partition again": {
+			probed:         []string{"xfs"},
+			wantChecked:    true,
+			wantSignatures: []string{"xfs"},
+		},
+		"signatures of the add event and of the probe are merged": {
+			fsInfo:         blockdevice.FileSystemInformation{FileSystem: "ext4", Signatures: []string{"ext4"}},
+			probed:         []string{"ext4", "LVM2_member"},
+			wantChecked:    true,
+			wantSignatures: []string{"ext4", "LVM2_member"},
+		},
+		"probe fails on a partition without signatures": {
+			probeErr:    errors.New("unable to create blkid probe"),
+			wantChecked: false,
+		},
+		"probe fails on a partition with a filesystem": {
+			fsInfo:         blockdevice.FileSystemInformation{FileSystem: "xfs"},
+			probeErr:       errors.New("unable to create blkid probe"),
+			wantChecked:    true,
+			wantSignatures: []string{"xfs"},
+		},
+	}
+	defer func(old func(string) ([]string, error)) { probePartitionSignatures = old }(probePartitionSignatures)
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			probePartitionSignatures = func(string) ([]string, error) {
+				return tt.probed, tt.probeErr
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{VerifyPartitionBlank: !tt.disabled},
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdx1"},
+				FSInfo:     tt.fsInfo,
+			}
+			pe.checkPartitionBlank(&bd)
+			assert.Equal(t, tt.wantChecked, bd.PartitionInfo.BlankChecked)
+			assert.Equal(t, tt.wantSignatures, bd.PartitionInfo.UnexpectedSignatures)
+		})
+	}
+}
+
+func TestAddBlockDeviceQuarantinesFalseBlankPartition(t *testing.T) {
+	parent := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+
+	oldProbeDeviceUsage := probeDeviceUsage
+	probeDeviceUsage = func(_ *controller.Controller, _ *blockdevice.BlockDevice) {}
+	defer func() { probeDeviceUsage = oldProbeDeviceUsage }()
+	defer func(old func(string) ([]string, error)) { probePartitionSignatures = old }(probePartitionSignatures)
+
+	tests := map[string]struct {
+		probed        []string
+		wantState     apis.BlockDeviceState
+		wantCondition v1.ConditionStatus
+		wantTrace     string
+	}{
+		"blank partition is adopted": {
+			probed:        []string{},
+			wantState:     apis.BlockDeviceActive,
+			wantCondition: v1.ConditionFalse,
+			wantTrace:     "partition:verified-blank",
+		},
+		"partition of a disk that was not blank is quarantined": {
+			// the old filesystem at the start of the partition was not seen on the disk
+			probed:        []string{"xfs"},
+			wantState:     apis.BlockDeviceQuarantined,
+			wantCondition: v1.ConditionTrue,
+			wantTrace:     "partition:unexpected-signature",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inFlightPartitions := newPartitionTracker()
+			inFlightPartitions.add(parent.DevPath)
+			probePartitionSignatures = func(string) ([]string, error) {
+				return tt.probed, nil
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/sdx1",
+				},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+				PartitionInfo: blockdevice.PartitionInformation{
+					PartitionEntryUUID: "9a1b8f0e-6d5f-4b3e-9c1d-2f8e7a6b5c41",
+					PartitionType:      "0fc63daf-8483-4772-8e79-3d69d8477de4",
+					PartitionName:      "OpenEBS_NDM",
+				},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Parent: parent.DevPath,
+				},
+				DecisionTrace: &blockdevice.DecisionTrace{},
+			}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+						parent.DevPath: parent,
+						bd.DevPath:     bd,
+					}),
+					VerifyPartitionBlank: true,
+				},
+				inFlightPartitions: inFlightPartitions,
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+			assert.True(t, util.Contains(bd.DecisionTrace.Steps(), tt.wantTrace))
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			if !assert.Equal(t, 1, len(bdAPIList.Items)) {
+				return
+			}
+			got := bdAPIList.Items[0]
+			assert.Equal(t, tt.wantState, got.Status.State)
+			var found bool
+			for _, condition := range got.Status.Conditions {
+				if condition.Type == apis.BlockDeviceUnexpectedSignature {
+					found = true
+					assert.Equal(t, tt.wantCondition, condition.Status)
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// showPartitionedParent returns true if the resource of the disk is to be created only
+// to make the disk visible, as the disk has partitions which get their own resources.
+func (pe *ProbeEvent) showPartitionedParent(bd blockdevice.BlockDevice) bool {
+	return pe.Controller.PartitionedParentPolicy == controller.ShowPartitionedParents &&
+		bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition &&
+		len(bd.DependentDevices.Partitions) > 0
+}
+
+// createPartitionedParentBlockDevice creates or updates the resource of a disk having
+// partitions. The partitions of the disk are recorded in an annotation, which also keeps
+// the disk from being claimed by the operator.
+func (pe *ProbeEvent) createPartitionedParentBlockDevice(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) error {
+	bd.DecisionTrace.Add("resource:partitioned-parent")
+	annotations := map[string]string{
+		internalUUIDSchemeAnnotation:       gptUUIDScheme,
+		controller.HasPartitionsAnnotation: strings.Join(bd.DependentDevices.Partitions, ","),
+	}
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, bd.UUID)
+	if err := pe.createOrUpdateWithAnnotation(annotations, bd, existingBD); err != nil {
+		klog.Errorf("unable to push partitioned device %s (%s) to etcd: %v", bd.UUID, bd.DevPath, err)
+		return err
+	}
+	return nil
+}
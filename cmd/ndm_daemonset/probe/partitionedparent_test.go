@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAddBlockDevicePartitionedParent(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			Model:      "ST1000NM0055",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sdx1", "/dev/sdx2"},
+		},
+	}
+	uuid, _, _ := generateUUID(bd)
+
+	tests := map[string]struct {
+		policy       controller.PartitionedParentPolicy
+		existingBD   bool
+		wantResource bool
+	}{
+		"partitioned disk is not shown by default": {
+			policy:       controller.DefaultPartitionedParentPolicy,
+			existingBD:   false,
+			wantResource: false,
+		},
+		"partitioned disk is shown with the show policy": {
+			policy:       controller.ShowPartitionedParents,
+			existingBD:   false,
+			wantResource: true,
+		},
+		"active resource of partitioned disk is annotated with the show policy": {
+			policy:       controller.ShowPartitionedParents,
+			existingBD:   true,
+			wantResource: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			if tt.existingBD {
+				bdAPI := apis.BlockDevice{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: uuid,
+						Annotations: map[string]string{
+							internalUUIDSchemeAnnotation: gptUUIDScheme,
+						},
+					},
+					Spec: apis.DeviceSpec{
+						Path: bd.DevPath,
+					},
+					Status: apis.DeviceStatus{
+						ClaimState: apis.BlockDeviceUnclaimed,
+						State:      controller.NDMActive,
+					},
+				}
+				assert.NoError(t, cl.Create(context.TODO(), &bdAPI))
+			}
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:               cl,
+					BDHierarchy:             blockdevice.NewHierarchyCache(blockdevice.Hierarchy{bd.DevPath: bd}),
+					PartitionedParentPolicy: tt.policy,
+				},
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, bdAPIList))
+
+			gotBDAPI := &apis.BlockDevice{}
+			err := cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, gotBDAPI)
+			if !tt.wantResource {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, controller.NDMActive, string(gotBDAPI.Status.State))
+			assert.Equal(t, apis.BlockDeviceUnclaimed, gotBDAPI.Status.ClaimState)
+			assert.Equal(t, "/dev/sdx1,/dev/sdx2", gotBDAPI.Annotations[controller.HasPartitionsAnnotation])
+			assert.NotEmpty(t, gotBDAPI.Annotations[internalUUIDVersionAnnotation])
+		})
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// partitionBackoffThreshold is the number of consecutive partitioning failures on a
+	// device after which partitioning is backed off
+	partitionBackoffThreshold = 2
+	// partitionQuarantineThreshold is the number of consecutive partitioning failures on
+	// a device after which the device is quarantined
+	partitionQuarantineThreshold = 5
+	// partitionBaseBackoff is the backoff after partitionBackoffThreshold failures. It is
+	// doubled with every further failure.
+	partitionBaseBackoff = time.Minute
+	// partitionFailureCooldown is the time since the last failure after which the failures
+	// of the device are forgotten, and partitioning is attempted again
+	partitionFailureCooldown = time.Hour
+)
+
+// createSinglePartition is a variable, so that it can be replaced in tests
+var createSinglePartition = func(d *partition.Disk) error {
+	return d.CreateSinglePartition()
+}
+
+// partitionFailure is the record of the consecutive partitioning failures of a disk
+type partitionFailure struct {
+	count       int
+	lastFailure time.Time
+	lastError   string
+	// retryAt is the time before which partitioning is not attempted
+	retryAt time.Time
+}
+
+// partitionFailureTracker tracks the consecutive partitioning failures of the disks,
+// keyed by a stable identifier of the disk, so that the failures of a disk are counted
+// even if the disk is renamed. Partitioning of a disk that keeps failing is backed off
+// exponentially, and the disk is quarantined after partitionQuarantineThreshold failures.
+type partitionFailureTracker struct {
+	mutex    sync.Mutex
+	failures map[string]*partitionFailure
+	// now gets the current time, it can be replaced in tests
+	now func() time.Time
+}
+
+func newPartitionFailureTracker() *partitionFailureTracker {
+	return &partitionFailureTracker{
+		failures: make(map[string]*partitionFailure),
+		now:      time.Now,
+	}
+}
+
+// get gets the failure record of the disk, forgetting the failures older than the cooldown
+func (pt *partitionFailureTracker) get(key string) (*partitionFailure, bool) {
+	failure, ok := pt.failures[key]
+	if !ok {
+		return nil, false
+	}
+	if pt.now().Sub(failure.lastFailure) >= partitionFailureCooldown {
+		delete(pt.failures, key)
+		return nil, false
+	}
+	return failure, true
+}
+
+// backoff checks if partitioning of the disk is backed off, and returns the time after
+// which it can be attempted again
+func (pt *partitionFailureTracker) backoff(key string) (time.Time, bool) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	failure, ok := pt.get(key)
+	if !ok || !pt.now().Before(failure.retryAt) {
+		return time.Time{}, false
+	}
+	return failure.retryAt, true
+}
+
+// recordFailure records a partitioning failure of the disk, and returns the number of
+// consecutive failures. The disk is backed off after partitionBackoffThreshold failures,
+// and until the cooldown once it reaches partitionQuarantineThreshold failures.
+func (pt *partitionFailureTracker) recordFailure(key string, err error) int {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	failure, ok := pt.get(key)
+	if !ok {
+		failure = &partitionFailure{}
+		pt.failures[key] = failure
+	}
+	now := pt.now()
+	failure.count++
+	failure.lastFailure = now
+	failure.lastError = err.Error()
+	switch {
+	case failure.count >= partitionQuarantineThreshold:
+		failure.retryAt = now.Add(partitionFailureCooldown)
+	case failure.count >= partitionBackoffThreshold:
+		failure.retryAt = now.Add(partitionBaseBackoff << uint(failure.count-partitionBackoffThreshold))
+	}
+	return failure.count
+}
+
+// reset forgets the failures of the disk, once it is partitioned
+func (pt *partitionFailureTracker) reset(key string) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	delete(pt.failures, key)
+}
+
+// getPartitionFailures gets the tracker of the partitioning failures of the disks,
+// creating it on first use
+func (pe *ProbeEvent) getPartitionFailures() *partitionFailureTracker {
+	if pe.partitionFailures == nil {
+		pe.partitionFailures = newPartitionFailureTracker()
+	}
+	return pe.partitionFailures
+}
+
+// getPartitionFailureKey gets the key of the disk in the partition failure tracker. The
+// disks that are partitioned cannot be uniquely identified, the legacy uuid is used as
+// it is generated from the identifiers that are available, or the path if there are none.
+func getPartitionFailureKey(bd blockdevice.BlockDevice) string {
+	uuid, _ := generateLegacyUUID(bd)
+	return uuid
+}
+
+// partitionDisk creates a single partition on the disk, unless partitioning of the disk
+// is backed off after repeated failures. The disk is quarantined once partitioning has
+// failed partitionQuarantineThreshold times in a row.
+func (pe *ProbeEvent) partitionDisk(bd *blockdevice.BlockDevice, d *partition.Disk, bdAPIList *apis.BlockDeviceList) error {
+	key := getPartitionFailureKey(*bd)
+	if retryAt, ok := pe.getPartitionFailures().backoff(key); ok {
+		klog.Infof("partitioning of device: %s is backed off till %v after repeated failures",
+			bd.DevPath, retryAt.Format(time.RFC3339))
+		bd.DecisionTrace.Add("partitioning:backoff")
+		return nil
+	}
+
+	klog.Infof("starting to create partition on device: %s", bd.DevPath)
+	err := createSinglePartition(d)
+	pe.Controller.Audit(controller.CreatePartitionOperation, getAuditTarget(*bd), blankDiskAuditCause, err)
+	if err != nil {
+		klog.Errorf("error creating partition for %s, %v", bd.DevPath, err)
+		failures := pe.getPartitionFailures().recordFailure(key, err)
+		if failures < partitionQuarantineThreshold {
+			return err
+		}
+		bd.DecisionTrace.Add("quarantine:partition-failures")
+		reason := fmt.Sprintf("PartitionFailures: partition creation failed %d times in a row, last error: %v",
+			failures, err)
+		return pe.quarantineUnidentifiedBlockDevice(*bd, reason, bdAPIList)
+	}
+	pe.getPartitionFailures().reset(key)
+	klog.Infof("created new partition in %s", bd.DevPath)
+	bd.DecisionTrace.Add("action:partition-created")
+	// the add event of the partition follows, in which it is adopted
+	pe.getInFlightPartitions().add(bd.DevPath)
+	return nil
+}
+
+// quarantineUnidentifiedBlockDevice creates / updates the resource of a device that
+// cannot be uniquely identified in the Quarantined state, with the reason as an
+// annotation. Like in discover only mode, the legacy uuid is used for the resource.
+func (pe *ProbeEvent) quarantineUnidentifiedBlockDevice(bd blockdevice.BlockDevice, reason string, bdAPIList *apis.BlockDeviceList) error {
+	klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+		"ndm.blockdevice.quarantine", "Quarantining device that cannot be partitioned",
+		reason, bd.DevPath)
+
+	uuid, uuidUsesPath := generateLegacyUUID(bd)
+	bd.UUID = uuid
+	pe.addBlockDeviceToHierarchyCache(bd)
+
+	bdAPI, err := pe.Controller.NewDeviceInfoFromBlockDevice(&bd).ToDevice(pe.Controller)
+	if err != nil {
+		klog.Error("Failed to create a block device resource CR, Error: ", err)
+		return err
+	}
+	bdAPI.Annotations = map[string]string{
+		internalUUIDSchemeAnnotation:          legacyUUIDScheme,
+		controller.QuarantineReasonAnnotation: reason,
+	}
+	if uuidUsesPath {
+		bdAPI.Annotations[internalNeedsIdentifierAnnotation] = controller.TrueString
+	}
+	bdAPI.Status.State = apis.BlockDeviceQuarantined
+
+	if existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid); existingBD != nil {
+		err = pe.Controller.UpdateBlockDevice(bdAPI, existingBD)
+	} else {
+		err = pe.Controller.CreateBlockDevice(bdAPI)
+	}
+	if err != nil {
+		klog.Errorf("unable to push quarantined device %s (%s) to etcd", bd.UUID, bd.DevPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPartitionFailureTrackerBackoff(t *testing.T) {
+	failedAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		failures    int
+		elapsed     time.Duration
+		wantBackoff bool
+	}{
+		"single failure is retried immediately": {
+			failures:    1,
+			elapsed:     0,
+			wantBackoff: false,
+		},
+		"backed off after consecutive failures": {
+			failures:    2,
+			elapsed:     30 * time.Second,
+			wantBackoff: true,
+		},
+		"retried once the backoff elapses": {
+			failures:    2,
+			elapsed:     time.Minute,
+			wantBackoff: false,
+		},
+		"backoff doubles with further failures": {
+			failures:    4,
+			elapsed:     3 * time.Minute,
+			wantBackoff: true,
+		},
+		"backed off till the cooldown after quarantine": {
+			failures:    partitionQuarantineThreshold,
+			elapsed:     59 * time.Minute,
+			wantBackoff: true,
+		},
+		"failures forgotten after the cooldown": {
+			failures:    partitionQuarantineThreshold,
+			elapsed:     partitionFailureCooldown,
+			wantBackoff: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pt := newPartitionFailureTracker()
+			pt.now = func() time.Time { return failedAt }
+			for i := 0; i < tt.failures; i++ {
+				pt.recordFailure("disk", fmt.Errorf("write failed"))
+			}
+			pt.now = func() time.Time { return failedAt.Add(tt.elapsed) }
+			_, gotBackoff := pt.backoff("disk")
+			assert.Equal(t, tt.wantBackoff, gotBackoff)
+		})
+	}
+}
+
+func TestPartitionFailureTrackerReset(t *testing.T) {
+	pt := newPartitionFailureTracker()
+	for i := 0; i < partitionBackoffThreshold; i++ {
+		pt.recordFailure("disk", fmt.Errorf("write failed"))
+	}
+	pt.reset("disk")
+	_, gotBackoff := pt.backoff("disk")
+	assert.False(t, gotBackoff)
+	assert.Equal(t, 1, pt.recordFailure("disk", fmt.Errorf("write failed")))
+}
+
+func TestAddBlockDeviceWithRepeatedPartitionFailures(t *testing.T) {
+	oldCreateSinglePartition := createSinglePartition
+	createSinglePartition = func(_ *partition.Disk) error {
+		return fmt.Errorf("unable to write partition table")
+	}
+	defer func() { createSinglePartition = oldCreateSinglePartition }()
+
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	partitionFailures := newPartitionFailureTracker()
+	partitionFailures.now = func() time.Time { return now }
+
+	// a blank disk without WWN and partitions, which cannot be uniquely identified
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newBlockDevice := func() blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: diskImage,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType:       blockdevice.BlockDeviceTypeDisk,
+				LogicalBlockSize: 512,
+				Serial:           "ZA1B2C3D",
+			},
+			Capacity: blockdevice.CapacityInformation{
+				Storage: 10 * 1024 * 1024,
+			},
+			DecisionTrace: &blockdevice.DecisionTrace{},
+		}
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+		partitionFailures: partitionFailures,
+	}
+
+	// each attempt is made once the backoff of the previous failure has elapsed
+	attempts := []struct {
+		after       time.Duration
+		wantErr     bool
+		wantBackoff bool
+	}{
+		{after: 0, wantErr: true},
+		{after: 0, wantErr: true},
+		{after: 0, wantBackoff: true},
+		{after: time.Minute, wantErr: true},
+		{after: 2 * time.Minute, wantErr: true},
+		{after: time.Minute, wantBackoff: true},
+		{after: 3 * time.Minute, wantErr: false},
+	}
+	for i, attempt := range attempts {
+		now = now.Add(attempt.after)
+		bd := newBlockDevice()
+		err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+		assert.Equal(t, attempt.wantErr, err != nil, "attempt %d", i)
+		assert.Equal(t, attempt.wantBackoff,
+			util.Contains(bd.DecisionTrace.Steps(), "partitioning:backoff"), "attempt %d", i)
+	}
+
+	// the fifth failure quarantines the device
+	bdList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdList))
+	if assert.Len(t, bdList.Items, 1) {
+		bdAPI := bdList.Items[0]
+		assert.Equal(t, apis.BlockDeviceQuarantined, bdAPI.Status.State)
+		assert.Contains(t, bdAPI.Annotations[controller.QuarantineReasonAnnotation],
+			"PartitionFailures: partition creation failed 5 times in a row")
+	}
+
+	// the device is not partitioned again till the cooldown
+	now = now.Add(time.Minute)
+	bd := newBlockDevice()
+	assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+	assert.True(t, util.Contains(bd.DecisionTrace.Steps(), "partitioning:backoff"))
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package probe
 
 import (
+	"time"
+
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/util"
 	"k8s.io/klog/v2"
 )
 
@@ -39,13 +42,55 @@ var RegisteredProbes = []func(){
 }
 
 type registerProbe struct {
-	priority   int
-	name       string
-	state      bool
+	priority int
+	name     string
+	state    bool
+	// timeout is the time after which the probe is abandoned for a device
+	timeout time.Duration
+	// configKey is the key used to look up the probe in the probeconfigs
+	// of NDM config
+	configKey string
+	// required probes are always enabled, since the rest of NDM depends
+	// on the details filled by them
+	required   bool
 	pi         controller.ProbeInterface
 	controller *controller.Controller
 }
 
+// configure overrides the default name, state and priority of the probe with the
+// values from the probeconfigs in NDM config, if the probe is present in it.
+// A required probe cannot be disabled from the config.
+func (rp *registerProbe) configure() {
+	if rp.controller.NDMConfig != nil && rp.configKey != "" {
+		for _, probeConfig := range rp.controller.NDMConfig.ProbeConfigs {
+			if probeConfig.Key != rp.configKey {
+				continue
+			}
+			if probeConfig.Name != "" {
+				rp.name = probeConfig.Name
+			}
+			rp.state = util.CheckTruthy(probeConfig.State)
+			if probeConfig.Priority != 0 {
+				rp.priority = probeConfig.Priority
+			}
+			if probeConfig.Timeout != "" {
+				timeout, err := time.ParseDuration(probeConfig.Timeout)
+				if err != nil || timeout < 0 {
+					klog.Warningf("invalid timeout: %q for %s, using the default probe timeout",
+						probeConfig.Timeout, rp.name)
+				} else {
+					rp.timeout = timeout
+				}
+			}
+			break
+		}
+	}
+	if rp.required && !rp.state {
+		klog.Warningf("%s is a required probe and cannot be disabled, enabling it", rp.name)
+		rp.state = true
+	}
+}
+
 // register called by register function of each probe it will check for probe
 // status if it is enabled then it will call Start() of that probe.
 func (rp *registerProbe) register() {
@@ -53,10 +98,13 @@ func (rp *registerProbe) register() {
 		Priority:  rp.priority,
 		Name:      rp.name,
 		State:     rp.state,
+		Timeout:   rp.timeout,
 		Interface: rp.pi,
 	}
 	rp.controller.AddNewProbe(newProbe)
-	if rp.state {
+	// the probes are not started in the evaluation mode, as the events generated by
+	// them are not processed
+	if rp.state && rp.controller.Evaluation == nil {
 		rp.pi.Start()
 	}
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/stretchr/testify/assert"
@@ -115,3 +116,123 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterProbeConfigure(t *testing.T) {
+	ndmConfig := &controller.NodeDiskManagerConfig{
+		ProbeConfigs: []controller.ProbeConfig{
+			{
+				Key:      "smart-probe",
+				Name:     "smart probe",
+				State:    "false",
+				Priority: 9,
+			},
+			{
+				Key:   "sysfs-probe",
+				Name:  "sysfs probe",
+				State: "false",
+			},
+			{
+				Key:   "udev-probe",
+				State: "true",
+			},
+			{
+				Key:     "seachest-probe",
+				State:   "true",
+				Timeout: "30s",
+			},
+			{
+				Key:     "mount-probe",
+				State:   "true",
+				Timeout: "-1s",
+			},
+		},
+	}
+	tests := map[string]struct {
+		rp           *registerProbe
+		wantName     string
+		wantState    bool
+		wantPriority int
+		wantTimeout  time.Duration
+	}{
+		"probe not present in config uses defaults": {
+			rp: &registerProbe{
+				priority:  3,
+				name:      "smart probe",
+				state:     true,
+				configKey: "smart-probe-1",
+			},
+			wantName:     "smart probe",
+			wantState:    true,
+			wantPriority: 3,
+		},
+		"probe timeout from config": {
+			rp: &registerProbe{
+				priority:  6,
+				name:      "seachest probe",
+				state:     true,
+				configKey: "seachest-probe",
+			},
+			wantName:     "seachest probe",
+			wantState:    true,
+			wantPriority: 6,
+			wantTimeout:  30 * time.Second,
+		},
+		"invalid probe timeout in config is ignored": {
+			rp: &registerProbe{
+				priority:  4,
+				name:      "mount probe",
+				state:     true,
+				configKey: "mount-probe",
+			},
+			wantName:     "mount probe",
+			wantState:    true,
+			wantPriority: 4,
+		},
+		"probe disabled and reordered from config": {
+			rp: &registerProbe{
+				priority:  3,
+				name:      "smart probe",
+				state:     true,
+				configKey: "smart-probe",
+			},
+			wantName:     "smart probe",
+			wantState:    false,
+			wantPriority: 9,
+		},
+		"required probe cannot be disabled": {
+			rp: &registerProbe{
+				priority:  2,
+				name:      "sysfs probe",
+				state:     true,
+				configKey: "sysfs-probe",
+				required:  true,
+			},
+			wantName:     "sysfs probe",
+			wantState:    true,
+			wantPriority: 2,
+		},
+		"empty name in config keeps the default name": {
+			rp: &registerProbe{
+				priority:  1,
+				name:      "udev probe",
+				state:     true,
+				configKey: "udev-probe",
+			},
+			wantName:     "udev probe",
+			wantState:    true,
+			wantPriority: 1,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.rp.controller = &controller.Controller{
+				NDMConfig: ndmConfig,
+			}
+			test.rp.configure()
+			assert.Equal(t, test.wantName, test.rp.name)
+			assert.Equal(t, test.wantState, test.rp.state)
+			assert.Equal(t, test.wantPriority, test.rp.priority)
+			assert.Equal(t, test.wantTimeout, test.rp.timeout)
+		})
+	}
+}
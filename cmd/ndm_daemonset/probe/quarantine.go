@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// validatePartitionTable is a variable, so that it can be replaced in tests
+var validatePartitionTable = partition.ValidatePartitionTable
+
+// computeQuarantineReason checks the device for conflicting or suspicious metadata, which
+// makes it unsafe for NDM to guess the identity or the usage of the device. The reason
+// for quarantining the device is returned, empty if the device need not be quarantined.
+//
+// A device is quarantined if
+//  1. another disk on the node reports the same WWN and serial
+//  2. the uuid of the device is used by the active resource of another device on the node
+//  3. the disk has a GPT partition table and also a signature on the whole disk
+//  4. the partition table of the disk, or of the parent disk of a partition, was found
+//     to be corrupt by checkPartitionTable
+//  5. the device has multiple filesystem signatures, as per the multi signature policy
+func (pe *ProbeEvent) computeQuarantineReason(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) string {
+	// partitions share the WWN of the disk, only disks need to be checked for collision
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition &&
+		len(bd.DeviceAttributes.WWN) != 0 && len(bd.DependentDevices.Holders) == 0 {
+		for devPath, cachedBD := range pe.Controller.BDHierarchy.Snapshot() {
+			// the paths of a multipath device report the same WWN, and have the
+			// multipath device as the holder
+			if devPath == bd.DevPath ||
+				cachedBD.DeviceAttributes.DeviceType != bd.DeviceAttributes.DeviceType ||
+				len(cachedBD.DependentDevices.Holders) != 0 {
+				continue
+			}
+			// LUNs of some storage arrays have the same WWN, but different serial
+			if cachedBD.DeviceAttributes.WWN == bd.DeviceAttributes.WWN &&
+				cachedBD.DeviceAttributes.Serial == bd.DeviceAttributes.Serial {
+				return fmt.Sprintf("WWN %s and serial %s are also reported by device %s",
+					bd.DeviceAttributes.WWN, bd.DeviceAttributes.Serial, devPath)
+			}
+		}
+	}
+
+	if uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList); ok && bdAPIList != nil {
+		hostName := pe.Controller.NodeAttributes[controller.HostNameKey]
+		for _, bdAPI := range bdAPIList.Items {
+			if bdAPI.Name != uuid ||
+				bdAPI.Spec.Path == bd.DevPath ||
+				bdAPI.Status.State != apis.BlockDeviceActive ||
+				bdAPI.Labels[controller.KubernetesHostNameLabel] != hostName {
+				continue
+			}
+			// the resource may be stale, if the device was renamed. It is a collision only
+			// if the device at the path of the resource still has the same uuid
+			otherBD, present := pe.Controller.BDHierarchy.Get(bdAPI.Spec.Path)
+			if !present {
+				continue
+			}
+			if otherUUID, _, _, ok := pe.generateDeviceUUID(otherBD, bdAPIList); ok && otherUUID == uuid {
+				return fmt.Sprintf("uuid %s is in use by device %s", uuid, bdAPI.Spec.Path)
+			}
+		}
+	}
+
+	if reason := getConflictingSignatures(bd); reason != "" {
+		return reason
+	}
+	if reason := pe.getMultipleSignaturesReason(bd, bdAPIList); reason != "" {
+		return reason
+	}
+	// the defect is recorded on the device by checkPartitionTable
+	if bd.PartitionInfo.PartitionTableCorruption != "" {
+		return "partition table is corrupt, see the CorruptPartitionTable condition"
+	}
+	return ""
+}
+
+// checkPartitionTable validates the partition table of the disk, or of the parent disk if
+// the device is a partition, and records the result on the device. It is surfaced as the
+// CorruptPartitionTable condition of the resource. A table with overlapping entries or a
+// bad CRC cannot be trusted, neither for partitioning the disk nor for the partitions on it.
+func checkPartitionTable(bd *blockdevice.BlockDevice) {
+	var diskPath string
+	switch bd.DeviceAttributes.DeviceType {
+	case blockdevice.BlockDeviceTypeDisk:
+		diskPath = bd.DevPath
+	case blockdevice.BlockDeviceTypePartition:
+		diskPath = bd.DependentDevices.Parent
+	}
+	if diskPath == "" {
+		return
+	}
+
+	err := validatePartitionTable(diskPath)
+	var corruptErr *partition.CorruptPartitionTableError
+	if err != nil && !errors.As(err, &corruptErr) {
+		klog.Errorf("unable to validate partition table of device: %s, %v", diskPath, err)
+		return
+	}
+	bd.PartitionInfo.PartitionTableChecked = true
+	bd.PartitionInfo.PartitionTableCorruption = ""
+	if corruptErr != nil {
+		bd.PartitionInfo.PartitionTableCorruption = fmt.Sprintf("%s on disk %s, %s",
+			corruptErr.Defect, corruptErr.DevPath, corruptErr.Details)
+	}
+}
+
+// getConflictingSignatures checks if the disk has a GPT partition table and also has the
+// signature of a filesystem or a storage engine on the whole disk. Either the partition
+// table or the signature is a leftover of an earlier use, but which one cannot be known.
+func getConflictingSignatures(bd blockdevice.BlockDevice) string {
+	if bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition ||
+		bd.PartitionInfo.PartitionTableType != "gpt" {
+		return ""
+	}
+	if bd.DevUse.UsedBy == blockdevice.Mayastor {
+		return fmt.Sprintf("disk has a GPT partition table and a %s signature", bd.DevUse.UsedBy)
+	}
+	// the zfs filesystem is filled from the zfs partitions on the disk, and is
+	// expected on a disk with a GPT partition table
+	if len(bd.FSInfo.FileSystem) != 0 && bd.FSInfo.FileSystem != zfsFileSystemLabel {
+		return fmt.Sprintf("disk has a GPT partition table and a %s filesystem", bd.FSInfo.FileSystem)
+	}
+	return ""
+}
+
+// getMultipleSignatures gets the signatures of the device, if it has more than one.
+// Devices having holders are in use by the holder, and are not ambiguous.
+func getMultipleSignatures(bd blockdevice.BlockDevice) ([]string, bool) {
+	if len(bd.FSInfo.Signatures) < 2 || len(bd.DependentDevices.Holders) != 0 {
+		return nil, false
+	}
+	return bd.FSInfo.Signatures, true
+}
+
+// getMultipleSignaturesReason checks if the device has multiple signatures, which make
+// the usage of the device ambiguous, and the multi signature policy quarantines it.
+// With the require-wipe policy, such devices are skipped before the quarantine check.
+// Only devices without a resource, or with an Unclaimed resource are quarantined.
+func (pe *ProbeEvent) getMultipleSignaturesReason(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) string {
+	signatures, ok := getMultipleSignatures(bd)
+	if !ok || pe.isClaimedWithMultipleSignatures(bd, signatures, bdAPIList) {
+		return ""
+	}
+	switch pe.Controller.MultiSignaturePolicy {
+	case controller.RequireWipeMultiSignature:
+		return ""
+	case controller.PreferRecentSignature:
+		if bd.FSInfo.FileSystem != "" && util.Contains(signatures, bd.FSInfo.FileSystem) {
+			klog.Infof("device: %s has signatures: %v, using the detected filesystem: %s",
+				bd.DevPath, signatures, bd.FSInfo.FileSystem)
+			return ""
+		}
+	}
+	return fmt.Sprintf("MultipleSignatures: device has the signatures %s", strings.Join(signatures, ", "))
+}
+
+// requiresSignatureWipe checks if the device has multiple signatures, and the multi
+// signature policy requires them to be wiped before the device is managed. The resources
+// that are Claimed or Released continue to be updated.
+func (pe *ProbeEvent) requiresSignatureWipe(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) bool {
+	if pe.Controller.MultiSignaturePolicy != controller.RequireWipeMultiSignature {
+		return false
+	}
+	signatures, ok := getMultipleSignatures(bd)
+	if !ok || pe.isClaimedWithMultipleSignatures(bd, signatures, bdAPIList) {
+		return false
+	}
+	klog.Warningf("eventcode=%s msg=%s signatures=%q rname=%v",
+		"ndm.blockdevice.signature.wipe.required", "Device with multiple signatures skipped until wiped",
+		strings.Join(signatures, ","), bd.DevPath)
+	return true
+}
+
+// isClaimedWithMultipleSignatures checks if the resource of the device having multiple
+// signatures is Claimed or Released. The consumer of such a device knows its usage, so
+// the resource is left as is and a warning event is recorded on it instead.
+func (pe *ProbeEvent) isClaimedWithMultipleSignatures(bd blockdevice.BlockDevice, signatures []string,
+	bdAPIList *apis.BlockDeviceList) bool {
+	if bdAPIList == nil {
+		return false
+	}
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
+	if !ok {
+		return false
+	}
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+	if existingBD == nil || existingBD.Status.ClaimState == apis.BlockDeviceUnclaimed {
+		return false
+	}
+	reason := fmt.Sprintf("device has the signatures %s", strings.Join(signatures, ", "))
+	klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+		"ndm.blockdevice.signature.multiple", "Claimed device has multiple signatures, not quarantined",
+		reason, bd.DevPath)
+	if pe.Controller.Recorder != nil {
+		pe.Controller.Recorder.Event(existingBD, v1.EventTypeWarning, "MultipleSignatures", reason)
+	}
+	return true
+}
+
+// quarantineBlockDevice creates / updates the resource of the device in the Quarantined
+// state, with the reason as an annotation. The device is not modified in any way.
+func (pe *ProbeEvent) quarantineBlockDevice(bd blockdevice.BlockDevice, reason string, bdAPIList *apis.BlockDeviceList) error {
+	klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v",
+		"ndm.blockdevice.quarantine", "Quarantining device with conflicting metadata",
+		reason, bd.DevPath)
+
+	uuid, basis, version, ok := pe.generateDeviceUUID(bd, bdAPIList)
+	if !ok {
+		// the device cannot be identified, so no resource can be created. The device is
+		// still not partitioned.
+		klog.Warningf("device: %s cannot be uniquely identified, skipping", bd.DevPath)
+		return nil
+	}
+	bd.UUID = uuid
+	pe.addBlockDeviceToHierarchyCache(bd)
+
+	bdAPI, err := pe.Controller.NewDeviceInfoFromBlockDevice(&bd).ToDevice(pe.Controller)
+	if err != nil {
+		klog.Error("Failed to create a block device resource CR, Error: ", err)
+		return err
+	}
+	bdAPI.Annotations = map[string]string{
+		internalUUIDSchemeAnnotation:          gptUUIDScheme,
+		pe.uuidBasisAnnotation():              basis,
+		internalUUIDVersionAnnotation:         string(version),
+		controller.QuarantineReasonAnnotation: reason,
+	}
+	if version == controller.UUIDVersionV5 {
+		bdAPI.Annotations[internalUUIDNamespaceAnnotation] = pe.Controller.UUIDNamespace.String()
+	}
+	bdAPI.Status.State = apis.BlockDeviceQuarantined
+
+	if existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid); existingBD != nil {
+		err = pe.Controller.UpdateBlockDevice(bdAPI, existingBD)
+	} else {
+		err = pe.Controller.CreateBlockDevice(bdAPI)
+	}
+	if err != nil {
+		klog.Errorf("unable to push quarantined device %s (%s) to etcd", bd.UUID, bd.DevPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,483 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestComputeQuarantineReason(t *testing.T) {
+	disk := func(devPath, wwn, serial string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{DevPath: devPath},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        wwn,
+				Serial:     serial,
+			},
+		}
+	}
+	mpathMember := disk("/dev/sdc", "0x5000c500a1b2c3d4", "ZA1B2C3D")
+	mpathMember.DependentDevices.Holders = []string{"/dev/dm-0"}
+	// a disk without WWN, identified by the filesystem uuid, and its clone
+	fsDisk := disk("/dev/sdb", "", "")
+	fsDisk.FSInfo.FileSystemUUID = "7e7f160b-0e79-478b-b006-1ebc6d0050dd"
+	clonedDisk := fsDisk
+	clonedDisk.DevPath = "/dev/sdd"
+	fsDiskUUID, _, _ := generateUUID(fsDisk)
+
+	// the disk /dev/sde has overlapping partitions
+	oldValidatePartitionTable := validatePartitionTable
+	validatePartitionTable = func(devPath string) error {
+		if devPath == "/dev/sde" {
+			return &partition.CorruptPartitionTableError{
+				DevPath: devPath,
+				Defect:  partition.DefectOverlap,
+				Details: "partition 2 (LBA 4096-20446) overlaps partition 1 (LBA 2048-8191)",
+			}
+		}
+		return nil
+	}
+	defer func() { validatePartitionTable = oldValidatePartitionTable }()
+
+	tests := map[string]struct {
+		bd         blockdevice.BlockDevice
+		hierarchy  blockdevice.Hierarchy
+		bdAPIList  *apis.BlockDeviceList
+		wantReason bool
+	}{
+		"disk with unique WWN": {
+			bd: disk("/dev/sda", "0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": disk("/dev/sdb", "0x5000c500e5f6a7b8", "ZA5E6F7B"),
+			},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: false,
+		},
+		"disk with WWN and serial colliding with another disk": {
+			bd: disk("/dev/sda", "0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": disk("/dev/sdb", "0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: true,
+		},
+		"LUNs of a storage array with same WWN and different serial": {
+			bd: disk("/dev/sda", "0x5000c500a1b2c3d4", "LUN0001"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": disk("/dev/sdb", "0x5000c500a1b2c3d4", "LUN0002"),
+			},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: false,
+		},
+		"paths of a multipath device": {
+			bd: disk("/dev/sda", "0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdc": mpathMember,
+			},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: false,
+		},
+		"disk cloned from a device having an active resource on the node": {
+			bd: clonedDisk,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": fsDisk,
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   fsDiskUUID,
+							Labels: map[string]string{controller.KubernetesHostNameLabel: "node1"},
+						},
+						Spec:   apis.DeviceSpec{Path: "/dev/sdb"},
+						Status: apis.DeviceStatus{State: apis.BlockDeviceActive},
+					},
+				},
+			},
+			wantReason: true,
+		},
+		"uuid in use by the resource of a renamed device": {
+			bd: clonedDisk,
+			hierarchy: blockdevice.Hierarchy{
+				"/dev/sdb": disk("/dev/sdb", "0x5000c500a1b2c3d4", "ZA1B2C3D"),
+			},
+			bdAPIList: &apis.BlockDeviceList{
+				Items: []apis.BlockDevice{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   fsDiskUUID,
+							Labels: map[string]string{controller.KubernetesHostNameLabel: "node1"},
+						},
+						Spec:   apis.DeviceSpec{Path: "/dev/sdb"},
+						Status: apis.DeviceStatus{State: apis.BlockDeviceActive},
+					},
+				},
+			},
+			wantReason: false,
+		},
+		"disk with GPT partition table and a filesystem on the whole disk": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sda"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				PartitionInfo:    blockdevice.PartitionInformation{PartitionTableType: "gpt"},
+				FSInfo:           blockdevice.FileSystemInformation{FileSystem: "ext4"},
+			},
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: true,
+		},
+		"disk with GPT partition table and a mayastor signature": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sda"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				PartitionInfo:    blockdevice.PartitionInformation{PartitionTableType: "gpt"},
+				DevUse:           blockdevice.DeviceUsage{InUse: true, UsedBy: blockdevice.Mayastor},
+			},
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: true,
+		},
+		"disk with zfs partitions": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sda"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				PartitionInfo:    blockdevice.PartitionInformation{PartitionTableType: "gpt"},
+				FSInfo:           blockdevice.FileSystemInformation{FileSystem: zfsFileSystemLabel},
+				DevUse:           blockdevice.DeviceUsage{InUse: true, UsedBy: blockdevice.CStor},
+			},
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: false,
+		},
+		"disk with corrupt partition table": {
+			bd:         disk("/dev/sde", "", ""),
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: true,
+		},
+		"partition on a disk with corrupt partition table": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sde1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+				DependentDevices: blockdevice.DependentBlockDevices{Parent: "/dev/sde"},
+			},
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: true,
+		},
+		"partition on a disk with valid partition table": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdf1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+				DependentDevices: blockdevice.DependentBlockDevices{Parent: "/dev/sdf"},
+			},
+			hierarchy:  blockdevice.Hierarchy{},
+			bdAPIList:  &apis.BlockDeviceList{},
+			wantReason: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.hierarchy[tt.bd.DevPath] = tt.bd
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					BDHierarchy: blockdevice.NewHierarchyCache(tt.hierarchy),
+					NodeAttributes: map[string]string{
+						controller.HostNameKey: "node1",
+					},
+				},
+			}
+			checkPartitionTable(&tt.bd)
+			reason := pe.computeQuarantineReason(tt.bd, tt.bdAPIList)
+			assert.Equal(t, tt.wantReason, reason != "", "reason: %q", reason)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithConflictingSignatures(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		PartitionInfo: blockdevice.PartitionInformation{
+			PartitionTableType: "gpt",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			FileSystem: "xfs",
+		},
+	}
+	uuid, _, _ := generateUUID(bd)
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{bd.DevPath: bd}),
+		},
+	}
+	err := pe.addBlockDevice(bd, &apis.BlockDeviceList{})
+	assert.NoError(t, err)
+
+	gotBDAPI := &apis.BlockDevice{}
+	err = cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, gotBDAPI)
+	assert.NoError(t, err)
+	assert.Equal(t, apis.BlockDeviceQuarantined, gotBDAPI.Status.State)
+	assert.NotEmpty(t, gotBDAPI.Annotations[controller.QuarantineReasonAnnotation])
+}
+
+func TestAddBlockDeviceWithMultipleSignatures(t *testing.T) {
+	// a disk reused across tools, having a stale ext4 and a stale LVM2 signature
+	multiSignatureDisk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d5",
+			Serial:     "ZA1B2C3E",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			FileSystem: "ext4",
+			Signatures: []string{"LVM2_member", "ext4"},
+		},
+	}
+	undetectedDisk := multiSignatureDisk
+	undetectedDisk.FSInfo.FileSystem = ""
+	singleSignatureDisk := multiSignatureDisk
+	singleSignatureDisk.FSInfo.Signatures = []string{"ext4"}
+
+	tests := map[string]struct {
+		bd           blockdevice.BlockDevice
+		policy       controller.MultiSignaturePolicy
+		wantResource bool
+		wantState    apis.BlockDeviceState
+	}{
+		"quarantine policy": {
+			bd:           multiSignatureDisk,
+			policy:       controller.QuarantineMultiSignature,
+			wantResource: true,
+			wantState:    apis.BlockDeviceQuarantined,
+		},
+		"default policy": {
+			bd:           multiSignatureDisk,
+			policy:       "",
+			wantResource: true,
+			wantState:    apis.BlockDeviceQuarantined,
+		},
+		"prefer recent policy, filesystem detected": {
+			bd:           multiSignatureDisk,
+			policy:       controller.PreferRecentSignature,
+			wantResource: true,
+			wantState:    apis.BlockDeviceActive,
+		},
+		"prefer recent policy, filesystem not detected": {
+			bd:           undetectedDisk,
+			policy:       controller.PreferRecentSignature,
+			wantResource: true,
+			wantState:    apis.BlockDeviceQuarantined,
+		},
+		"require wipe policy": {
+			bd:           multiSignatureDisk,
+			policy:       controller.RequireWipeMultiSignature,
+			wantResource: false,
+		},
+		"require wipe policy, single signature": {
+			bd:           singleSignatureDisk,
+			policy:       controller.RequireWipeMultiSignature,
+			wantResource: true,
+			wantState:    apis.BlockDeviceActive,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:            cl,
+					BDHierarchy:          blockdevice.NewHierarchyCache(blockdevice.Hierarchy{tt.bd.DevPath: tt.bd}),
+					MultiSignaturePolicy: tt.policy,
+				},
+			}
+			err := pe.addBlockDevice(tt.bd, &apis.BlockDeviceList{})
+			assert.NoError(t, err)
+
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+			if !tt.wantResource {
+				assert.Empty(t, bdAPIList.Items)
+				return
+			}
+			if assert.Len(t, bdAPIList.Items, 1) {
+				assert.Equal(t, tt.wantState, bdAPIList.Items[0].Status.State)
+			}
+		})
+	}
+}
+
+func TestAddBlockDeviceWithMultipleSignaturesClaimed(t *testing.T) {
+	multiSignatureDisk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d5",
+			Serial:     "ZA1B2C3E",
+		},
+		FSInfo: blockdevice.FileSystemInformation{
+			Signatures: []string{"LVM2_member", "ext4"},
+		},
+	}
+
+	for _, policy := range []controller.MultiSignaturePolicy{
+		controller.QuarantineMultiSignature, controller.RequireWipeMultiSignature} {
+		for _, claimState := range []apis.DeviceClaimState{apis.BlockDeviceClaimed, apis.BlockDeviceReleased} {
+			t.Run(string(policy)+" "+string(claimState), func(t *testing.T) {
+				s := scheme.Scheme
+				s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+				s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+				cl := fake.NewFakeClientWithScheme(s)
+				recorder := record.NewFakeRecorder(10)
+
+				pe := &ProbeEvent{
+					Controller: &controller.Controller{
+						Clientset:            cl,
+						BDHierarchy:          blockdevice.NewHierarchyCache(blockdevice.Hierarchy{multiSignatureDisk.DevPath: multiSignatureDisk}),
+						MultiSignaturePolicy: policy,
+						Recorder:             recorder,
+					},
+				}
+				uuid, _, _, ok := pe.generateDeviceUUID(multiSignatureDisk, &apis.BlockDeviceList{})
+				assert.True(t, ok)
+
+				existingBD := apis.BlockDevice{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: uuid,
+					},
+					Spec: apis.DeviceSpec{
+						Path: multiSignatureDisk.DevPath,
+					},
+					Status: apis.DeviceStatus{
+						ClaimState: claimState,
+						State:      apis.BlockDeviceActive,
+					},
+				}
+				assert.NoError(t, cl.Create(context.TODO(), &existingBD))
+				bdAPIList := &apis.BlockDeviceList{}
+				assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+				err := pe.addBlockDevice(multiSignatureDisk, bdAPIList)
+				assert.NoError(t, err)
+
+				// the claimed resource is not quarantined, a warning event is recorded instead
+				gotBDAPI := &apis.BlockDevice{}
+				assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, gotBDAPI))
+				assert.Equal(t, apis.BlockDeviceActive, gotBDAPI.Status.State)
+				assert.Equal(t, claimState, gotBDAPI.Status.ClaimState)
+				assert.Empty(t, gotBDAPI.Annotations[controller.QuarantineReasonAnnotation])
+				assert.NotEmpty(t, recorder.Events)
+			})
+		}
+	}
+}
+
+func TestCheckPartitionTable(t *testing.T) {
+	disk := func(devPath string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier:       blockdevice.Identifier{DevPath: devPath},
+			DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+		}
+	}
+	oldValidatePartitionTable := validatePartitionTable
+	validatePartitionTable = func(devPath string) error {
+		switch devPath {
+		case "/dev/sde":
+			return &partition.CorruptPartitionTableError{
+				DevPath: devPath,
+				Defect:  partition.DefectOverlap,
+				Details: "partition 2 (LBA 4096-20446) overlaps partition 1 (LBA 2048-8191)",
+			}
+		case "/dev/sdg":
+			return errors.New("permission denied")
+		}
+		return nil
+	}
+	defer func() { validatePartitionTable = oldValidatePartitionTable }()
+
+	tests := map[string]struct {
+		bd             blockdevice.BlockDevice
+		wantChecked    bool
+		wantCorruption string
+	}{
+		"disk with corrupt partition table": {
+			bd:             disk("/dev/sde"),
+			wantChecked:    true,
+			wantCorruption: "overlap on disk /dev/sde, partition 2 (LBA 4096-20446) overlaps partition 1 (LBA 2048-8191)",
+		},
+		"disk with valid partition table": {
+			bd:          disk("/dev/sdf"),
+			wantChecked: true,
+		},
+		"partition table cannot be read": {
+			bd: disk("/dev/sdg"),
+		},
+		"partition on a disk with corrupt partition table": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sde1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+				DependentDevices: blockdevice.DependentBlockDevices{Parent: "/dev/sde"},
+			},
+			wantChecked:    true,
+			wantCorruption: "overlap on disk /dev/sde, partition 2 (LBA 4096-20446) overlaps partition 1 (LBA 2048-8191)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkPartitionTable(&tt.bd)
+			assert.Equal(t, tt.wantChecked, tt.bd.PartitionInfo.PartitionTableChecked)
+			assert.Equal(t, tt.wantCorruption, tt.bd.PartitionInfo.PartitionTableCorruption)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sort"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/sysfs"
+
+	"k8s.io/klog/v2"
+)
+
+// readinessRetryInterval is the interval at which the readiness of the devices deferred
+// as not ready is checked again
+const readinessRetryInterval = 2 * time.Second
+
+// isDeviceReady checks whether the device is ready to accept IO and gets its state. A
+// device whose readiness cannot be found is considered ready, so that it is probed as before.
+var isDeviceReady = func(devPath string) (bool, string) {
+	sysFsDevice, err := sysfs.NewSysFsDeviceFromDevPath(devPath)
+	if err != nil {
+		klog.V(4).Infof("unable to check readiness of device: %s, %v", devPath, err)
+		return true, ""
+	}
+	return sysFsDevice.IsReady()
+}
+
+// readinessNow gets the current time, it can be replaced in tests
+var readinessNow = time.Now
+
+// notReadyDevice is a device deferred as it was not ready to accept IO
+type notReadyDevice struct {
+	device *blockdevice.BlockDevice
+	// since is the time at which the device was first found not ready
+	since time.Time
+	state string
+	// reported is set once the device is reported as not ready for the timeout
+	reported bool
+}
+
+// readinessRetryTicker gets the ticker at which the deferred devices are checked again.
+// nil is returned if the readiness check is disabled.
+func readinessRetryTicker(c *controller.Controller) <-chan time.Time {
+	if c.DeviceReadyTimeout == 0 {
+		return nil
+	}
+	return time.NewTicker(readinessRetryInterval).C
+}
+
+// checkDeviceReady checks whether the device is ready to accept IO. Freshly attached
+// SAN / NVMe devices can appear in sysfs before they accept IO, and probing them at that
+// time makes them look blank. The device is always ready if the check is disabled.
+func (pe *ProbeEvent) checkDeviceReady(devPath string) (bool, string) {
+	if pe.Controller.DeviceReadyTimeout == 0 {
+		return true, ""
+	}
+	return isDeviceReady(devPath)
+}
+
+// isReadyOrDefer checks whether the device is ready to accept IO. A device that is not
+// ready is deferred, so that the other events are not blocked while it gets ready. It is
+// processed by the readiness retry once it is ready.
+func (pe *ProbeEvent) isReadyOrDefer(device *blockdevice.BlockDevice) bool {
+	ready, state := pe.checkDeviceReady(device.DevPath)
+	if ready {
+		delete(pe.notReady, device.DevPath)
+		return true
+	}
+	if pe.notReady == nil {
+		pe.notReady = make(map[string]*notReadyDevice)
+	}
+	deferred, ok := pe.notReady[device.DevPath]
+	if !ok {
+		klog.Infof("device: %s not ready, state: %s, deferring it till it is ready", device.DevPath, state)
+		deferred = &notReadyDevice{since: readinessNow()}
+		pe.notReady[device.DevPath] = deferred
+	}
+	deferred.device = device
+	deferred.state = state
+	return false
+}
+
+// forgetNotReadyDevice stops the readiness retry of the device, as it was removed
+func (pe *ProbeEvent) forgetNotReadyDevice(devPath string) {
+	delete(pe.notReady, devPath)
+}
+
+// retryNotReadyDevices processes the deferred devices that are now ready. A device that
+// is not ready even after the device ready timeout is reported once, and is checked
+// again till it is ready or removed.
+func (pe *ProbeEvent) retryNotReadyDevices() {
+	ready := make([]*blockdevice.BlockDevice, 0)
+	now := readinessNow()
+	for devPath, deferred := range pe.notReady {
+		if ok, state := isDeviceReady(devPath); !ok {
+			deferred.state = state
+			if !deferred.reported && now.Sub(deferred.since) >= pe.Controller.DeviceReadyTimeout {
+				deferred.reported = true
+				pe.Controller.ReportNotReadyDevice(devPath, state, now.Sub(deferred.since))
+			}
+			continue
+		}
+		ready = append(ready, deferred.device)
+	}
+	if len(ready) == 0 {
+		return
+	}
+	// the disks are processed before their partitions
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].DevPath < ready[j].DevPath
+	})
+	klog.Infof("processing %d deferred devices that are now ready", len(ready))
+	pe.addBlockDeviceEvent(controller.EventMessage{
+		Action:  string(AttachEA),
+		Devices: ready,
+	})
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestIsReadyOrDefer(t *testing.T) {
+	tests := map[string]struct {
+		timeout      time.Duration
+		ready        bool
+		want         bool
+		wantDeferred bool
+	}{
+		"readiness check disabled": {
+			timeout:      0,
+			ready:        false,
+			want:         true,
+			wantDeferred: false,
+		},
+		"device ready": {
+			timeout:      30 * time.Second,
+			ready:        true,
+			want:         true,
+			wantDeferred: false,
+		},
+		"device not ready is deferred": {
+			timeout:      30 * time.Second,
+			ready:        false,
+			want:         false,
+			wantDeferred: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldIsDeviceReady := isDeviceReady
+			isDeviceReady = func(devPath string) (bool, string) {
+				if tt.ready {
+					return true, "running"
+				}
+				return false, "blocked"
+			}
+			defer func() { isDeviceReady = oldIsDeviceReady }()
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					DeviceReadyTimeout: tt.timeout,
+				},
+			}
+			device := &blockdevice.BlockDevice{}
+			device.DevPath = "/dev/sdb"
+			assert.Equal(t, tt.want, pe.isReadyOrDefer(device))
+			_, deferred := pe.notReady["/dev/sdb"]
+			assert.Equal(t, tt.wantDeferred, deferred)
+		})
+	}
+}
+
+func TestRetryNotReadyDevices(t *testing.T) {
+	ready := map[string]bool{}
+	oldIsDeviceReady := isDeviceReady
+	isDeviceReady = func(devPath string) (bool, string) {
+		if ready[devPath] {
+			return true, "running"
+		}
+		return false, "blocked"
+	}
+	defer func() { isDeviceReady = oldIsDeviceReady }()
+
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	oldReadinessNow := readinessNow
+	readinessNow = func() time.Time { return now }
+	defer func() { readinessNow = oldReadinessNow }()
+
+	recorder := record.NewFakeRecorder(10)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			DeviceReadyTimeout: 30 * time.Second,
+			Recorder:           recorder,
+			NodeAttributes:     map[string]string{controller.NodeNameKey: "node1"},
+		},
+	}
+	for _, devPath := range []string{"/dev/sdb", "/dev/sdc"} {
+		device := &blockdevice.BlockDevice{}
+		device.DevPath = devPath
+		// a device that is not managed, so that it is only added to the hierarchy
+		device.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeZRAM
+		assert.False(t, pe.isReadyOrDefer(device))
+	}
+
+	// not ready within the timeout, nothing is reported
+	now = start.Add(10 * time.Second)
+	pe.retryNotReadyDevices()
+	assert.Len(t, pe.notReady, 2)
+	assert.Len(t, recorder.Events, 0)
+
+	// not ready after the timeout, each device is reported once
+	now = start.Add(40 * time.Second)
+	pe.retryNotReadyDevices()
+	pe.retryNotReadyDevices()
+	assert.Len(t, recorder.Events, 2)
+
+	// a removed device is not checked again
+	pe.forgetNotReadyDevice("/dev/sdc")
+	assert.Len(t, pe.notReady, 1)
+
+	// the ready device is processed, and is no longer deferred
+	ready["/dev/sdb"] = true
+	pe.Controller.Clientset = CreateFakeClient(t)
+	pe.Controller.Mutex = &sync.Mutex{}
+	pe.Controller.BDHierarchy = blockdevice.NewHierarchyCache(nil)
+	pe.retryNotReadyDevices()
+	assert.Len(t, pe.notReady, 0)
+	_, ok := pe.Controller.BDHierarchy.Get("/dev/sdb")
+	assert.True(t, ok)
+}
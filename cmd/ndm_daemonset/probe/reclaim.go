@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"k8s.io/klog/v2"
+)
+
+// partitionReclaimInterval is the interval at which blockdevices are checked for reclaim
+const partitionReclaimInterval = 1 * time.Minute
+
+// these functions are variables, so that they can be replaced in tests
+var (
+	wipeSignatures     = partition.WipeSignatures
+	removeNDMPartition = func(d *partition.Disk) error {
+		return d.RemoveNDMPartition()
+	}
+)
+
+// reclaimTicker returns the channel on which reclaim of partitions should be triggered.
+// nil is returned if partitions are not to be reclaimed, so that the receive blocks forever.
+func reclaimTicker(c *controller.Controller) <-chan time.Time {
+	if c.PartitionReclaimPolicy != controller.ReclaimPartitions {
+		return nil
+	}
+	klog.Infof("partitions flagged for reclaim will be reclaimed every %v", partitionReclaimInterval)
+	return time.NewTicker(partitionReclaimInterval).C
+}
+
+// reclaimPartitions reclaims all the partitions created by NDM on this node, that are
+// flagged for reclaim and are no longer in use. A rescan is triggered if any partition
+// was reclaimed, so that the parent disks are processed again.
+func (pe *ProbeEvent) reclaimPartitions() {
+	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for reclaim: %v", err)
+		return
+	}
+
+	reclaimed := false
+	for _, bdAPI := range bdAPIList.Items {
+		if !pe.isReclaimable(bdAPI) {
+			continue
+		}
+		if err := pe.reclaimPartition(bdAPI, bdAPIList); err != nil {
+			klog.Errorf("reclaim of blockdevice: %s failed: %v", bdAPI.Name, err)
+			continue
+		}
+		reclaimed = true
+	}
+
+	if reclaimed {
+		go Rescan(pe.Controller)
+	}
+}
+
+// isReclaimable checks if the blockdevice is an active partition that is flagged for
+// reclaim. Protected blockdevices are not reclaimed, so released blockdevices are reclaimed
+// only after the cleanup is completed and the blockdevice becomes unclaimed.
+func (pe *ProbeEvent) isReclaimable(bdAPI apis.BlockDevice) bool {
+	val, ok := bdAPI.Annotations[controller.OpenEBSReclaim]
+	if !ok || !util.CheckTruthy(val) {
+		return false
+	}
+	return bdAPI.Spec.Details.DeviceType == blockdevice.BlockDeviceTypePartition &&
+		bdAPI.Status.State == apis.BlockDeviceActive &&
+		!pe.Controller.IsProtected(bdAPI, controller.DeletePartitionOperation)
+}
+
+// reclaimPartition wipes the partition, removes it from the parent disk and deletes the
+// blockdevice resource. The blockdevice resource of the parent disk, if present, is
+// activated again.
+func (pe *ProbeEvent) reclaimPartition(bdAPI apis.BlockDevice, bdAPIList *apis.BlockDeviceList) error {
+	partitionBD, ok := pe.Controller.BDHierarchy.Get(bdAPI.Spec.Path)
+	if !ok {
+		return fmt.Errorf("device: %s not found in cache", bdAPI.Spec.Path)
+	}
+	if partitionBD.DevUse.InUse ||
+		len(partitionBD.DependentDevices.Holders) > 0 ||
+		len(partitionBD.FSInfo.MountPoint) > 0 {
+		return fmt.Errorf("device: %s is in use", partitionBD.DevPath)
+	}
+	// a member of a degraded pool may not be reported in use, the pool label is checked
+	// again so that the recovery of the pool is not broken
+	if pool, ok := getZFSPoolLabel(partitionBD); ok {
+		return fmt.Errorf("device: %s has the zfs label of pool: %s", partitionBD.DevPath, pool.PoolName)
+	}
+
+	parentBD, ok := pe.getParentDevice(partitionBD)
+	if !ok {
+		return fmt.Errorf("%w for device: %s", ErrParentNotFound, partitionBD.DevPath)
+	}
+	if len(parentBD.DependentDevices.Partitions) != 1 {
+		return fmt.Errorf("parent device: %s has more than one partition", parentBD.DevPath)
+	}
+
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.WipeSignaturesOperation, partitionBD.DevPath) ||
+		!pe.Controller.IsDestructiveOperationAllowed(controller.DeletePartitionOperation, partitionBD.DevPath) {
+		return fmt.Errorf("reclaim of device: %s blocked by safe mode", partitionBD.DevPath)
+	}
+
+	klog.Infof("reclaiming partition: %s of device: %s", partitionBD.DevPath, parentBD.DevPath)
+	cause := controller.AuditCause{
+		Trigger: controller.AuditTriggerPartitionReclaim,
+		Reason:  fmt.Sprintf("blockdevice flagged for reclaim with annotation: %s", controller.OpenEBSReclaim),
+	}
+	err := wipeSignatures(partitionBD.DevPath)
+	pe.Controller.Audit(controller.WipeSignaturesOperation, getAuditTarget(partitionBD), cause, err)
+	if err != nil {
+		return err
+	}
+	d := &partition.Disk{
+		DevPath:          parentBD.DevPath,
+		DiskSize:         parentBD.Capacity.Storage,
+		LogicalBlockSize: uint64(parentBD.DeviceAttributes.LogicalBlockSize),
+		PartitionName:    pe.Controller.PartitionName,
+	}
+	err = removeNDMPartition(d)
+	pe.Controller.Audit(controller.DeletePartitionOperation, getAuditTarget(partitionBD), cause, err)
+	if err != nil {
+		return err
+	}
+
+	pe.Controller.DeleteBlockDevice(bdAPI.Name, cause)
+
+	for _, item := range bdAPIList.Items {
+		if item.Spec.Path == parentBD.DevPath &&
+			item.Status.State == apis.BlockDeviceInactive {
+			if err := pe.Controller.ActivateBlockDevice(item); err != nil {
+				return err
+			}
+		}
+	}
+	klog.Infof("reclaimed partition: %s of device: %s", partitionBD.DevPath, parentBD.DevPath)
+	return nil
+}
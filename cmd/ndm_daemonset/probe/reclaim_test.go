@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/zfs"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsReclaimable(t *testing.T) {
+	newBDAPI := func(reclaim string, claimState apis.DeviceClaimState) apis.BlockDevice {
+		bdAPI := apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{},
+			},
+			Spec: apis.DeviceSpec{
+				Details: apis.DeviceDetails{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+			},
+			Status: apis.DeviceStatus{
+				ClaimState: claimState,
+				State:      apis.BlockDeviceActive,
+			},
+		}
+		if reclaim != "" {
+			bdAPI.Annotations[controller.OpenEBSReclaim] = reclaim
+		}
+		return bdAPI
+	}
+
+	tests := map[string]struct {
+		bdAPI apis.BlockDevice
+		want  bool
+	}{
+		"unclaimed partition flagged for reclaim": {
+			bdAPI: newBDAPI("true", apis.BlockDeviceUnclaimed),
+			want:  true,
+		},
+		"unclaimed partition not flagged for reclaim": {
+			bdAPI: newBDAPI("", apis.BlockDeviceUnclaimed),
+			want:  false,
+		},
+		"unclaimed partition with reclaim disabled": {
+			bdAPI: newBDAPI("false", apis.BlockDeviceUnclaimed),
+			want:  false,
+		},
+		"claimed partition flagged for reclaim": {
+			bdAPI: newBDAPI("true", apis.BlockDeviceClaimed),
+			want:  false,
+		},
+		"released partition flagged for reclaim": {
+			bdAPI: newBDAPI("true", apis.BlockDeviceReleased),
+			want:  false,
+		},
+	}
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset: fake.NewFakeClientWithScheme(s),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pe.isReclaimable(tt.bdAPI))
+		})
+	}
+}
+
+func TestReclaimPartition(t *testing.T) {
+	parentBD := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sda1"},
+		},
+	}
+	partitionBD := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda1",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypePartition,
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Parent: "/dev/sda",
+		},
+	}
+	partitionBDAPI := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "blockdevice-partition",
+			Annotations: map[string]string{
+				controller.OpenEBSReclaim: "true",
+			},
+		},
+		Spec: apis.DeviceSpec{
+			Path: "/dev/sda1",
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: apis.BlockDeviceUnclaimed,
+			State:      apis.BlockDeviceActive,
+		},
+	}
+	parentBDAPI := apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "blockdevice-parent",
+		},
+		Spec: apis.DeviceSpec{
+			Path: "/dev/sda",
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: apis.BlockDeviceUnclaimed,
+			State:      apis.BlockDeviceInactive,
+		},
+	}
+
+	inUsePartitionBD := partitionBD
+	inUsePartitionBD.DevUse = blockdevice.DeviceUsage{InUse: true, UsedBy: blockdevice.CStor}
+
+	tests := map[string]struct {
+		partitionBD blockdevice.BlockDevice
+		safeMode    bool
+		zfsLabels   map[string]*zfs.Label
+		wantErr     bool
+	}{
+		"unused partition is reclaimed": {
+			partitionBD: partitionBD,
+			safeMode:    false,
+			wantErr:     false,
+		},
+		"partition in use is not reclaimed": {
+			partitionBD: inUsePartitionBD,
+			safeMode:    false,
+			wantErr:     true,
+		},
+		"partition having the zfs label of a degraded pool is not reclaimed": {
+			partitionBD: partitionBD,
+			zfsLabels: map[string]*zfs.Label{
+				"/dev/sda1": {PoolName: "tank", PoolGUID: 1, Layout: zfs.LayoutRAIDZ + "1"},
+			},
+			wantErr: true,
+		},
+		"partition is not reclaimed in safe mode": {
+			partitionBD: partitionBD,
+			safeMode:    true,
+			wantErr:     true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var wiped, removed []string
+			wipeSignatures = func(devPath string) error {
+				wiped = append(wiped, devPath)
+				return nil
+			}
+			removeNDMPartition = func(d *partition.Disk) error {
+				removed = append(removed, d.DevPath)
+				return nil
+			}
+			defer mockZFSLabels(tt.zfsLabels)()
+			defer func() {
+				wipeSignatures = partition.WipeSignatures
+				removeNDMPartition = func(d *partition.Disk) error {
+					return d.RemoveNDMPartition()
+				}
+			}()
+
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			bdAPIList := &apis.BlockDeviceList{}
+			for _, bdAPI := range []apis.BlockDevice{partitionBDAPI, parentBDAPI} {
+				cl.Create(context.TODO(), bdAPI.DeepCopy())
+			}
+			if err := cl.List(context.TODO(), bdAPIList); err != nil {
+				t.Fatal(err)
+			}
+
+			auditBuf := &bytes.Buffer{}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+						"/dev/sda":  parentBD,
+						"/dev/sda1": tt.partitionBD,
+					}),
+					SafeMode: tt.safeMode,
+					AuditLog: controller.NewAuditLog(auditBuf, nil),
+				},
+			}
+			err := pe.reclaimPartition(partitionBDAPI, bdAPIList)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			gotPartitionBDAPI := &apis.BlockDevice{}
+			err = cl.Get(context.TODO(), client.ObjectKey{Name: partitionBDAPI.Name}, gotPartitionBDAPI)
+			gotParentBDAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: parentBDAPI.Name}, gotParentBDAPI))
+			if tt.wantErr {
+				assert.NoError(t, err)
+				assert.Empty(t, wiped)
+				assert.Empty(t, removed)
+				assert.Equal(t, apis.BlockDeviceInactive, gotParentBDAPI.Status.State)
+				assertAuditRecords(t, auditBuf, nil)
+				return
+			}
+			assert.True(t, errors.IsNotFound(err))
+			assert.Equal(t, []string{"/dev/sda1"}, wiped)
+			assert.Equal(t, []string{"/dev/sda"}, removed)
+			assert.Equal(t, apis.BlockDeviceActive, gotParentBDAPI.Status.State)
+			assertAuditRecords(t, auditBuf, []controller.AuditRecord{
+				{
+					Operation: string(controller.WipeSignaturesOperation),
+					Device:    "/dev/sda1",
+					Trigger:   controller.AuditTriggerPartitionReclaim,
+					Result:    controller.AuditResultSuccess,
+				},
+				{
+					Operation: string(controller.DeletePartitionOperation),
+					Device:    "/dev/sda1",
+					Trigger:   controller.AuditTriggerPartitionReclaim,
+					Result:    controller.AuditResultSuccess,
+				},
+				{
+					Operation: string(controller.DeleteBlockDeviceOperation),
+					Trigger:   controller.AuditTriggerPartitionReclaim,
+					Result:    controller.AuditResultSuccess,
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"strings"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// internalPreviousUUIDAnnotation is the annotation having the uuid of the resource
+	// of the device before it was re-identified
+	internalPreviousUUIDAnnotation = "internal.openebs.io/previous-uuid"
+)
+
+// uuidBasisPriority is the priority of the identifiers from which the uuid of a disk can
+// be generated. An identifier of higher priority is more stable. The resources created
+// with the legacy scheme, whose uuid is generated from the model and serial of the
+// disk, have the lowest priority.
+var uuidBasisPriority = map[string]int{
+	uuidBasisPartitionTableUUID: 1,
+	uuidBasisFileSystemUUID:     2,
+	uuidBasisWWN:                3,
+	uuidBasisWWNComposite:       3,
+}
+
+// getUUIDBasisPriority gets the priority of the identifier from which the uuid of the
+// resource was generated. false is returned if the identifier is not known.
+func (pe *ProbeEvent) getUUIDBasisPriority(bdAPI apis.BlockDevice) (int, bool) {
+	if basis, ok := pe.getStoredUUIDBasis(bdAPI); ok {
+		priority, ok := uuidBasisPriority[basis]
+		return priority, ok
+	}
+	if bdAPI.Annotations[internalUUIDSchemeAnnotation] == legacyUUIDScheme {
+		return 0, true
+	}
+	return 0, false
+}
+
+// getReidentificationCandidate gets the resource of the device on this node, whose uuid
+// was generated from an identifier of lower priority than the given basis. The resource
+// is matched using the path and the serial of the device.
+func (pe *ProbeEvent) getReidentificationCandidate(bd blockdevice.BlockDevice, basis string,
+	bdAPIList *apis.BlockDeviceList) *apis.BlockDevice {
+	newPriority, ok := uuidBasisPriority[basis]
+	if !ok {
+		return nil
+	}
+	hostName := pe.Controller.NodeAttributes[controller.HostNameKey]
+	for i := range bdAPIList.Items {
+		bdAPI := &bdAPIList.Items[i]
+		if bdAPI.Name == bd.UUID ||
+			bdAPI.Labels[controller.KubernetesHostNameLabel] != hostName ||
+			bdAPI.Spec.Path != bd.DevPath ||
+			bdAPI.Spec.Details.Serial != bd.DeviceAttributes.Serial {
+			continue
+		}
+		if priority, ok := pe.getUUIDBasisPriority(*bdAPI); ok && priority < newPriority {
+			return bdAPI
+		}
+	}
+	return nil
+}
+
+// detectUUIDBasisChange reports if the identifier from which the uuid of the device is
+// generated differs from the one recorded on its resource, eg: if the WWN has appeared on
+// a disk that was identified by its partition table. The resource of the device is the
+// one with the same uuid, or the one on this node with the same path and serial.
+func (pe *ProbeEvent) detectUUIDBasisChange(bd *blockdevice.BlockDevice, basis string,
+	bdAPIList *apis.BlockDeviceList) {
+	hostName := pe.Controller.NodeAttributes[controller.HostNameKey]
+	for i := range bdAPIList.Items {
+		bdAPI := &bdAPIList.Items[i]
+		if bdAPI.Name != bd.UUID && (bdAPI.Labels[controller.KubernetesHostNameLabel] != hostName ||
+			bdAPI.Spec.Path != bd.DevPath ||
+			bdAPI.Spec.Details.Serial != bd.DeviceAttributes.Serial) {
+			continue
+		}
+		storedBasis, ok := pe.getStoredUUIDBasis(*bdAPI)
+		if !ok || storedBasis == basis {
+			continue
+		}
+		reason := fmt.Sprintf("uuid basis of device %s changed from %s to %s", bd.DevPath, storedBasis, basis)
+		klog.Warningf("eventcode=%s msg=%s reason=%q rname=%v uuid=%v",
+			"ndm.blockdevice.uuid-basis.changed", "UUID basis of the device changed",
+			reason, bdAPI.Name, bd.UUID)
+		bd.DecisionTrace.Add("uuid-basis-changed:" + storedBasis)
+		if pe.Controller.Recorder != nil {
+			pe.Controller.Recorder.Event(bdAPI, v1.EventTypeWarning, "UUIDBasisChanged", reason)
+		}
+		return
+	}
+}
+
+// reidentifyBlockDevice migrates the resource of the device to the uuid generated from a
+// better identifier, that has become available after the resource was created. The new
+// resource has the uuid of the old resource in an annotation for traceability, and the
+// old resource is deleted. Resources protected by a claim are never re-identified, at any
+// protection level, as the claim refers to the resource by its name. true is returned if
+// the device has been re-identified.
+func (pe *ProbeEvent) reidentifyBlockDevice(bd blockdevice.BlockDevice, basis string,
+	bdAPIList *apis.BlockDeviceList) (bool, error) {
+	oldBDAPI := pe.getReidentificationCandidate(bd, basis, bdAPIList)
+	if oldBDAPI == nil {
+		return false, nil
+	}
+	if reason := pe.Controller.GetProtectionReason(*oldBDAPI); reason != "" {
+		klog.Infof("device: %s can be identified by %s, but blockdevice: %s is protected (%s), not re-identifying it",
+			bd.DevPath, basis, oldBDAPI.Name, reason)
+		return false, nil
+	}
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.DeleteBlockDeviceOperation, oldBDAPI.Name) {
+		return false, nil
+	}
+
+	// labels added to the old resource by the users are retained, the labels of NDM
+	// are generated again from the device
+	labels := make(map[string]string, len(bd.Labels)+len(oldBDAPI.Labels))
+	for key, value := range oldBDAPI.Labels {
+		if !strings.HasPrefix(key, controller.NDMLabelPrefix) {
+			labels[key] = value
+		}
+	}
+	for key, value := range bd.Labels {
+		labels[key] = value
+	}
+	bd.Labels = labels
+
+	annotations := map[string]string{
+		internalUUIDSchemeAnnotation:   gptUUIDScheme,
+		internalPreviousUUIDAnnotation: oldBDAPI.Name,
+	}
+	if err := pe.createOrUpdateWithAnnotation(annotations, bd, nil); err != nil {
+		return false, err
+	}
+	pe.Controller.DeleteBlockDevice(oldBDAPI.Name, controller.AuditCause{
+		Trigger: controller.AuditTriggerDeviceAdded,
+		Reason:  fmt.Sprintf("device re-identified as blockdevice: %s", bd.UUID),
+	})
+	klog.Infof("eventcode=%s msg=%s rname=%v previous=%v basis=%s",
+		"ndm.blockdevice.reidentified", "Re-identified blockdevice",
+		bd.UUID, oldBDAPI.Name, basis)
+	return true, nil
+}
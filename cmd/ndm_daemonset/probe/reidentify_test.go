@@ -0,0 +1,260 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAddBlockDeviceReidentification(t *testing.T) {
+	hostName := "fake-host-name"
+	// the WWN of the disk could not be read earlier, and the resource was created
+	// with the legacy uuid generated from the model and serial
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			Model:      "ST1000NM0055",
+		},
+	}
+	newUUID, _, _ := generateUUID(bd)
+	withoutWWN := bd
+	withoutWWN.DeviceAttributes.WWN = ""
+	oldUUID, _ := generateLegacyUUID(withoutWWN)
+
+	tests := map[string]struct {
+		reidentify       bool
+		claimState       apis.DeviceClaimState
+		claimRef         *v1.ObjectReference
+		claim            string
+		annotations      map[string]string
+		wantReidentified bool
+	}{
+		"serial based uuid upgraded to wwn based uuid": {
+			reidentify: true,
+			claimState: apis.BlockDeviceUnclaimed,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: legacyUUIDScheme,
+			},
+			wantReidentified: true,
+		},
+		"filesystem uuid based uuid upgraded to wwn based uuid": {
+			reidentify: true,
+			claimState: apis.BlockDeviceUnclaimed,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation:          gptUUIDScheme,
+				controller.DefaultUUIDBasisAnnotation: uuidBasisFileSystemUUID,
+			},
+			wantReidentified: true,
+		},
+		"claimed resource is never re-identified": {
+			reidentify: true,
+			claimState: apis.BlockDeviceClaimed,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: legacyUUIDScheme,
+			},
+			wantReidentified: false,
+		},
+		"unclaimed resource with a claim reference is never re-identified": {
+			reidentify: true,
+			claimState: apis.BlockDeviceUnclaimed,
+			claimRef:   &v1.ObjectReference{Name: "bdc-1"},
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: legacyUUIDScheme,
+			},
+			wantReidentified: false,
+		},
+		"unclaimed resource with an outstanding claim is never re-identified": {
+			reidentify: true,
+			claimState: apis.BlockDeviceUnclaimed,
+			claim:      "bdc-1",
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: legacyUUIDScheme,
+			},
+			wantReidentified: false,
+		},
+		"re-identification disabled": {
+			reidentify: false,
+			claimState: apis.BlockDeviceUnclaimed,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: legacyUUIDScheme,
+			},
+			wantReidentified: false,
+		},
+		"resource with unknown uuid basis is not re-identified": {
+			reidentify: true,
+			claimState: apis.BlockDeviceUnclaimed,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: pinnedUUIDScheme,
+			},
+			wantReidentified: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaim{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceClaimList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			if tt.claim != "" {
+				claim := &apis.BlockDeviceClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: tt.claim},
+					Spec:       apis.DeviceClaimSpec{BlockDeviceName: oldUUID},
+				}
+				assert.NoError(t, cl.Create(context.TODO(), claim))
+			}
+
+			oldBDAPI := apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: oldUUID,
+					Labels: map[string]string{
+						controller.KubernetesHostNameLabel: hostName,
+						"example.com/rack":                 "r1",
+					},
+					Annotations: tt.annotations,
+				},
+				Spec: apis.DeviceSpec{
+					Path: bd.DevPath,
+					Details: apis.DeviceDetails{
+						Serial: bd.DeviceAttributes.Serial,
+					},
+					ClaimRef: tt.claimRef,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: tt.claimState,
+					State:      controller.NDMActive,
+				},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), &oldBDAPI))
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:         cl,
+					BDHierarchy:       blockdevice.NewHierarchyCache(blockdevice.Hierarchy{bd.DevPath: bd}),
+					NodeAttributes:    map[string]string{controller.HostNameKey: hostName},
+					ReidentifyDevices: tt.reidentify,
+				},
+			}
+			assert.NoError(t, pe.addBlockDevice(bd, bdAPIList))
+
+			newBDAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: newUUID}, newBDAPI))
+			oldErr := cl.Get(context.TODO(), client.ObjectKey{Name: oldUUID}, &apis.BlockDevice{})
+			if tt.wantReidentified {
+				assert.True(t, errors.IsNotFound(oldErr))
+				assert.Equal(t, oldUUID, newBDAPI.Annotations[internalPreviousUUIDAnnotation])
+				assert.Equal(t, uuidBasisWWN, newBDAPI.Annotations[controller.DefaultUUIDBasisAnnotation])
+				assert.Equal(t, "r1", newBDAPI.Labels["example.com/rack"])
+			} else {
+				assert.NoError(t, oldErr)
+				_, ok := newBDAPI.Annotations[internalPreviousUUIDAnnotation]
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
+func TestDetectUUIDBasisChange(t *testing.T) {
+	hostName := "fake-host-name"
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			UUID:    "blockdevice-new",
+			DevPath: "/dev/sdx",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			Serial: "ZA1B2C3D",
+		},
+	}
+	newBDAPI := func(name, path, annotation, basis string) apis.BlockDevice {
+		bdAPI := apis.BlockDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{controller.KubernetesHostNameLabel: hostName},
+				Annotations: map[string]string{annotation: basis},
+			},
+		}
+		bdAPI.Spec.Path = path
+		bdAPI.Spec.Details.Serial = "ZA1B2C3D"
+		return bdAPI
+	}
+
+	tests := map[string]struct {
+		basisAnnotation string
+		bdAPI           apis.BlockDevice
+		wantTrace       []string
+	}{
+		"resource of the device at the same path has another basis": {
+			bdAPI:     newBDAPI("blockdevice-old", "/dev/sdx", controller.DefaultUUIDBasisAnnotation, uuidBasisPartitionTableUUID),
+			wantTrace: []string{"uuid-basis-changed:" + uuidBasisPartitionTableUUID},
+		},
+		"resource with the same uuid has another basis": {
+			bdAPI:     newBDAPI("blockdevice-new", "/dev/sdy", controller.DefaultUUIDBasisAnnotation, uuidBasisWWNComposite),
+			wantTrace: []string{"uuid-basis-changed:" + uuidBasisWWNComposite},
+		},
+		"basis is unchanged": {
+			bdAPI: newBDAPI("blockdevice-new", "/dev/sdx", controller.DefaultUUIDBasisAnnotation, uuidBasisWWN),
+		},
+		"resource of another device": {
+			bdAPI: newBDAPI("blockdevice-other", "/dev/sdy", controller.DefaultUUIDBasisAnnotation, uuidBasisPartitionTableUUID),
+		},
+		"basis recorded with the configured annotation": {
+			basisAnnotation: "example.com/uuid-basis",
+			bdAPI:           newBDAPI("blockdevice-old", "/dev/sdx", "example.com/uuid-basis", uuidBasisFileSystemUUID),
+			wantTrace:       []string{"uuid-basis-changed:" + uuidBasisFileSystemUUID},
+		},
+		"basis recorded before the annotation was configured": {
+			basisAnnotation: "example.com/uuid-basis",
+			bdAPI:           newBDAPI("blockdevice-old", "/dev/sdx", controller.DefaultUUIDBasisAnnotation, uuidBasisFileSystemUUID),
+			wantTrace:       []string{"uuid-basis-changed:" + uuidBasisFileSystemUUID},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					NodeAttributes:      map[string]string{controller.HostNameKey: hostName},
+					UUIDBasisAnnotation: tt.basisAnnotation,
+				},
+			}
+			bd := bd
+			bd.DecisionTrace = &blockdevice.DecisionTrace{}
+			pe.detectUUIDBasisChange(&bd, uuidBasisWWN, &apis.BlockDeviceList{Items: []apis.BlockDevice{tt.bdAPI}})
+			assert.Equal(t, tt.wantTrace, bd.DecisionTrace.Steps())
+		})
+	}
+}
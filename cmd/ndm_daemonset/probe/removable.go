@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sort"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+// removableRetryInterval is the interval at which the removable disks deferred for the
+// removable device delay are checked again
+const removableRetryInterval = 2 * time.Second
+
+// removableNow gets the current time, it can be replaced in tests
+var removableNow = time.Now
+
+// removableDisk is a managed removable disk, that is processed only once it has been
+// present for the removable device delay
+type removableDisk struct {
+	// since is the time at which the disk was first found
+	since time.Time
+	// deferred are the devices of the disk, the disk and its partitions, waiting for the
+	// delay to complete, keyed by the path of the device
+	deferred map[string]*blockdevice.BlockDevice
+}
+
+// removableRetryTicker gets the ticker at which the deferred removable disks are checked
+// again. nil is returned if removable disks are not deferred.
+func removableRetryTicker(c *controller.Controller) <-chan time.Time {
+	if c.RemovableDevicePolicy != controller.ManageRemovableDevices || c.RemovableDeviceDelay == 0 {
+		return nil
+	}
+	return time.NewTicker(removableRetryInterval).C
+}
+
+// getRemovableDiskPath gets the path of the disk whose presence decides when the device
+// is managed. The partitions of a removable disk are evaluated with their parent disk.
+func getRemovableDiskPath(device *blockdevice.BlockDevice) string {
+	if device.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition &&
+		device.DependentDevices.Parent != "" {
+		return device.DependentDevices.Parent
+	}
+	return device.DevPath
+}
+
+// isRemovableSettledOrDefer checks whether the disk of a removable device has been present
+// for the removable device delay. The device is deferred otherwise, and is processed by
+// the removable retry once the delay is complete. Devices that are not removable, and
+// removable devices that are ignored by the filter, are never deferred.
+func (pe *ProbeEvent) isRemovableSettledOrDefer(device *blockdevice.BlockDevice) bool {
+	if !device.DeviceAttributes.Removable ||
+		pe.Controller.RemovableDevicePolicy != controller.ManageRemovableDevices ||
+		pe.Controller.RemovableDeviceDelay == 0 {
+		return true
+	}
+	diskPath := getRemovableDiskPath(device)
+	if pe.removableDisks == nil {
+		pe.removableDisks = make(map[string]*removableDisk)
+	}
+	disk, ok := pe.removableDisks[diskPath]
+	if !ok {
+		klog.Infof("removable disk: %s found, deferring it for %v", diskPath, pe.Controller.RemovableDeviceDelay)
+		disk = &removableDisk{
+			since:    removableNow(),
+			deferred: make(map[string]*blockdevice.BlockDevice),
+		}
+		pe.removableDisks[diskPath] = disk
+	}
+	if removableNow().Sub(disk.since) >= pe.Controller.RemovableDeviceDelay {
+		delete(disk.deferred, device.DevPath)
+		return true
+	}
+	disk.deferred[device.DevPath] = device
+	return false
+}
+
+// forgetRemovableDevice forgets the removed device. The delay of a removable disk starts
+// again when it is plugged in again.
+func (pe *ProbeEvent) forgetRemovableDevice(devPath string) {
+	if _, ok := pe.removableDisks[devPath]; ok {
+		delete(pe.removableDisks, devPath)
+		return
+	}
+	for _, disk := range pe.removableDisks {
+		delete(disk.deferred, devPath)
+	}
+}
+
+// retryRemovableDevices processes the deferred devices of the removable disks that have
+// been present for the removable device delay
+func (pe *ProbeEvent) retryRemovableDevices() {
+	settled := make([]*blockdevice.BlockDevice, 0)
+	now := removableNow()
+	for _, disk := range pe.removableDisks {
+		if now.Sub(disk.since) < pe.Controller.RemovableDeviceDelay {
+			continue
+		}
+		for _, device := range disk.deferred {
+			settled = append(settled, device)
+		}
+	}
+	if len(settled) == 0 {
+		return
+	}
+	// the disks are processed before their partitions
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].DevPath < settled[j].DevPath
+	})
+	klog.Infof("processing %d removable devices present for %v", len(settled), pe.Controller.RemovableDeviceDelay)
+	pe.addBlockDeviceEvent(controller.EventMessage{
+		Action:  string(AttachEA),
+		Devices: settled,
+	})
+}
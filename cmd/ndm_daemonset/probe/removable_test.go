@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRemovableSettledOrDefer(t *testing.T) {
+	disk := &blockdevice.BlockDevice{}
+	disk.DevPath = "/dev/sdc"
+	disk.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypeDisk
+	disk.DeviceAttributes.Removable = true
+	partition := &blockdevice.BlockDevice{}
+	partition.DevPath = "/dev/sdc1"
+	partition.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypePartition
+	partition.DeviceAttributes.Removable = true
+	partition.DependentDevices.Parent = "/dev/sdc"
+	fixed := &blockdevice.BlockDevice{}
+	fixed.DevPath = "/dev/sdb"
+
+	tests := map[string]struct {
+		policy       controller.RemovableDevicePolicy
+		delay        time.Duration
+		device       *blockdevice.BlockDevice
+		want         bool
+		wantDeferred bool
+	}{
+		"device is not removable": {
+			policy: controller.ManageRemovableDevices,
+			delay:  30 * time.Second,
+			device: fixed,
+			want:   true,
+		},
+		"delay disabled": {
+			policy: controller.ManageRemovableDevices,
+			device: disk,
+			want:   true,
+		},
+		"removable devices ignored": {
+			policy: controller.IgnoreRemovableDevices,
+			delay:  30 * time.Second,
+			device: disk,
+			want:   true,
+		},
+		"removable disk is deferred": {
+			policy:       controller.ManageRemovableDevices,
+			delay:        30 * time.Second,
+			device:       disk,
+			want:         false,
+			wantDeferred: true,
+		},
+		"partition is deferred with its disk": {
+			policy:       controller.ManageRemovableDevices,
+			delay:        30 * time.Second,
+			device:       partition,
+			want:         false,
+			wantDeferred: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					RemovableDevicePolicy: tt.policy,
+					RemovableDeviceDelay:  tt.delay,
+				},
+			}
+			assert.Equal(t, tt.want, pe.isRemovableSettledOrDefer(tt.device))
+			removableDisk, deferred := pe.removableDisks["/dev/sdc"]
+			assert.Equal(t, tt.wantDeferred, deferred)
+			if deferred {
+				assert.Contains(t, removableDisk.deferred, tt.device.DevPath)
+			}
+		})
+	}
+}
+
+func TestRemovableDeviceDelay(t *testing.T) {
+	now := time.Now()
+	oldRemovableNow := removableNow
+	removableNow = func() time.Time { return now }
+	defer func() { removableNow = oldRemovableNow }()
+
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			RemovableDevicePolicy: controller.ManageRemovableDevices,
+			RemovableDeviceDelay:  30 * time.Second,
+		},
+	}
+	disk := &blockdevice.BlockDevice{}
+	disk.DevPath = "/dev/sdc"
+	disk.DeviceAttributes.Removable = true
+
+	assert.False(t, pe.isRemovableSettledOrDefer(disk))
+
+	// the disk removed before the delay starts the delay again when it is plugged in
+	now = now.Add(20 * time.Second)
+	pe.forgetRemovableDevice("/dev/sdc")
+	assert.Empty(t, pe.removableDisks)
+	assert.False(t, pe.isRemovableSettledOrDefer(disk))
+	now = now.Add(20 * time.Second)
+	assert.False(t, pe.isRemovableSettledOrDefer(disk))
+
+	// the disk present for the delay is managed
+	now = now.Add(10 * time.Second)
+	assert.True(t, pe.isRemovableSettledOrDefer(disk))
+	assert.Empty(t, pe.removableDisks["/dev/sdc"].deferred)
+}
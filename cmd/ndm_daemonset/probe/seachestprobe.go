@@ -20,7 +20,6 @@ import (
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/seachest"
-	"github.com/openebs/node-disk-manager/pkg/util"
 	"k8s.io/klog/v2"
 )
 
@@ -51,22 +50,16 @@ var seachestProbeRegister = func() {
 		klog.Error("unable to configure", seachestProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == seachestConfigKey {
-				seachestProbeName = probeConfig.Name
-				seachestProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
 	newRegisterProbe := &registerProbe{
 		priority:   seachestProbePriority,
 		name:       seachestProbeName,
 		state:      seachestProbeState,
+		configKey:  seachestConfigKey,
 		pi:         &seachestProbe{Controller: ctrl},
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
+	seachestProbeName = newRegisterProbe.name
 	// Here we register the probe (seachest probe in this case)
 	newRegisterProbe.register()
 }
@@ -107,6 +100,18 @@ func (scp *seachestProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockD
 		klog.V(4).Infof("Disk: %s FirmwareRevision:%s filled by seachest.", blockDevice.DevPath, blockDevice.DeviceAttributes.FirmwareRevision)
 	}
 
+	// the form factor and the write endurance are not reported by all the disks,
+	// they are left empty in that case
+	if blockDevice.DeviceAttributes.FormFactor == "" {
+		blockDevice.DeviceAttributes.FormFactor = seachestProbe.SeachestIdentifier.GetFormFactor(driveInfo)
+		klog.V(4).Infof("Disk: %s FormFactor:%s filled by seachest.", blockDevice.DevPath, blockDevice.DeviceAttributes.FormFactor)
+	}
+
+	if blockDevice.DeviceAttributes.WriteEnduranceTBW == 0 {
+		blockDevice.DeviceAttributes.WriteEnduranceTBW = seachestProbe.SeachestIdentifier.GetWriteEnduranceTBW(driveInfo)
+		klog.V(4).Infof("Disk: %s WriteEnduranceTBW:%d filled by seachest.", blockDevice.DevPath, blockDevice.DeviceAttributes.WriteEnduranceTBW)
+	}
+
 	if blockDevice.DeviceAttributes.LogicalBlockSize == 0 {
 		blockDevice.DeviceAttributes.LogicalBlockSize = seachestProbe.SeachestIdentifier.GetLogicalSectorSize(driveInfo)
 		klog.V(4).Infof("Disk: %s LogicalBlockSize:%d filled by seachest.", blockDevice.DevPath, blockDevice.DeviceAttributes.LogicalBlockSize)
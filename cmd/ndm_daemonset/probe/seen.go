@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+)
+
+// lastSeenInterval is the interval at which the last-seen annotation is refreshed. The
+// annotation is not changed on every event, as it would make every update of the
+// resource a write to the API server.
+const lastSeenInterval = time.Hour
+
+// seenClock is the time at which an event of a device is processed, it is a variable so
+// that it can be changed in tests
+var seenClock = time.Now
+
+// setSeenTimestamps sets the first-seen and the last-seen annotations of the device. The
+// first-seen time of the existing resource is retained, resources created by earlier
+// versions of NDM without the annotation use their creation time instead. The last-seen
+// time of the existing resource is retained if it is within the lastSeenInterval.
+func setSeenTimestamps(existingBD *apis.BlockDevice, annotations map[string]string) {
+	now := seenClock().UTC()
+	firstSeen := now.Format(time.RFC3339)
+	lastSeen := now.Format(time.RFC3339)
+	if existingBD != nil {
+		if value, ok := existingBD.Annotations[controller.FirstSeenAnnotation]; ok {
+			firstSeen = value
+		} else if !existingBD.CreationTimestamp.IsZero() {
+			firstSeen = existingBD.CreationTimestamp.UTC().Format(time.RFC3339)
+		}
+		if value, ok := existingBD.Annotations[controller.LastSeenAnnotation]; ok {
+			if t, err := time.Parse(time.RFC3339, value); err == nil && now.Sub(t) < lastSeenInterval {
+				lastSeen = value
+			}
+		}
+	}
+	annotations[controller.FirstSeenAnnotation] = firstSeen
+	annotations[controller.LastSeenAnnotation] = lastSeen
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetSeenTimestamps(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	created := time.Date(2021, 6, 15, 8, 30, 0, 0, time.UTC)
+	tests := map[string]struct {
+		existingBD    *apis.BlockDevice
+		wantFirstSeen string
+		wantLastSeen  string
+	}{
+		"new device is first seen now": {
+			existingBD:    nil,
+			wantFirstSeen: "2026-03-01T10:00:00Z",
+			wantLastSeen:  "2026-03-01T10:00:00Z",
+		},
+		"first seen time of existing resource is retained": {
+			existingBD: &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: metav1.NewTime(created),
+					Annotations: map[string]string{
+						controller.FirstSeenAnnotation: "2020-01-02T03:04:05Z",
+					},
+				},
+			},
+			wantFirstSeen: "2020-01-02T03:04:05Z",
+			wantLastSeen:  "2026-03-01T10:00:00Z",
+		},
+		"recent last seen time of existing resource is retained": {
+			existingBD: &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						controller.FirstSeenAnnotation: "2020-01-02T03:04:05Z",
+						controller.LastSeenAnnotation:  "2026-03-01T09:30:00Z",
+					},
+				},
+			},
+			wantFirstSeen: "2020-01-02T03:04:05Z",
+			wantLastSeen:  "2026-03-01T09:30:00Z",
+		},
+		"last seen time older than the interval is refreshed": {
+			existingBD: &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						controller.FirstSeenAnnotation: "2020-01-02T03:04:05Z",
+						controller.LastSeenAnnotation:  "2026-03-01T09:00:00Z",
+					},
+				},
+			},
+			wantFirstSeen: "2020-01-02T03:04:05Z",
+			wantLastSeen:  "2026-03-01T10:00:00Z",
+		},
+		"resource without first seen time uses its creation time": {
+			existingBD: &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: metav1.NewTime(created),
+				},
+			},
+			wantFirstSeen: "2021-06-15T08:30:00Z",
+			wantLastSeen:  "2026-03-01T10:00:00Z",
+		},
+	}
+	defer func() { seenClock = time.Now }()
+	seenClock = func() time.Time { return now }
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			annotations := make(map[string]string)
+			setSeenTimestamps(tt.existingBD, annotations)
+			assert.Equal(t, tt.wantFirstSeen, annotations[controller.FirstSeenAnnotation])
+			assert.Equal(t, tt.wantLastSeen, annotations[controller.LastSeenAnnotation])
+		})
+	}
+}
+
+func TestFirstSeenIsRetainedAcrossUpdates(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+	}
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			UUID:    "blockdevice-seen",
+			DevPath: "/dev/sdb",
+		},
+	}
+
+	clock := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	defer func() { seenClock = time.Now }()
+	seenClock = func() time.Time { return clock }
+
+	var existingBD *apis.BlockDevice
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pe.createOrUpdateWithAnnotation(nil, bd, existingBD))
+		got := &apis.BlockDevice{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: bd.UUID}, got))
+		assert.Equal(t, "2026-03-01T10:00:00Z", got.Annotations[controller.FirstSeenAnnotation])
+		assert.Equal(t, clock.Format(time.RFC3339), got.Annotations[controller.LastSeenAnnotation])
+		existingBD = got
+		clock = clock.Add(time.Hour)
+	}
+}
+
+func TestLastSeenIsNotRefreshedOnEveryUpdate(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:   cl,
+			BDHierarchy: blockdevice.NewHierarchyCache(nil),
+		},
+	}
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			UUID:    "blockdevice-seen",
+			DevPath: "/dev/sdb",
+		},
+	}
+
+	clock := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	defer func() { seenClock = time.Now }()
+	seenClock = func() time.Time { return clock }
+
+	assert.NoError(t, pe.createOrUpdateWithAnnotation(nil, bd, nil))
+	existingBD := &apis.BlockDevice{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: bd.UUID}, existingBD))
+
+	// an event within the interval does not change the annotations, so that the update
+	// is a no-op for the API server
+	clock = clock.Add(10 * time.Minute)
+	assert.NoError(t, pe.createOrUpdateWithAnnotation(nil, bd, existingBD))
+	got := &apis.BlockDevice{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: bd.UUID}, got))
+	assert.Equal(t, "2026-03-01T10:00:00Z", got.Annotations[controller.LastSeenAnnotation])
+	assert.Equal(t, existingBD.Annotations, got.Annotations)
+}
@@ -20,7 +20,6 @@ import (
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/smart"
-	"github.com/openebs/node-disk-manager/pkg/util"
 	"k8s.io/klog/v2"
 )
 
@@ -51,22 +50,16 @@ var smartProbeRegister = func() {
 		klog.Error("unable to configure", smartProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == smartConfigKey {
-				smartProbeName = probeConfig.Name
-				smartProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
 	newRegisterProbe := &registerProbe{
 		priority:   smartProbePriority,
 		name:       smartProbeName,
 		state:      smartProbeState,
+		configKey:  smartConfigKey,
 		pi:         &smartProbe{Controller: ctrl},
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
+	smartProbeName = newRegisterProbe.name
 	// Here we register the probe (smart probe in this case)
 	newRegisterProbe.register()
 }
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/seachest"
+
+	"k8s.io/klog/v2"
+)
+
+// readTemperature is a variable, so that it can be replaced in tests
+var readTemperature = func(devPath string) (int16, bool) {
+	identifier := &seachest.Identifier{DevPath: devPath}
+	driveInfo, err := identifier.SeachestBasicDiskInfo()
+	if err != 0 {
+		klog.V(4).Infof("unable to read smart data of device: %s, error: %d", devPath, err)
+		return 0, false
+	}
+	if !identifier.GetTemperatureDataValidStatus(driveInfo) {
+		return 0, false
+	}
+	return identifier.GetCurrentTemperature(driveInfo), true
+}
+
+// smartRefreshTicker returns the channel on which the refresh of the SMART data of the
+// disks should be scheduled. nil is returned if the refresh is disabled, so that the
+// receive blocks forever.
+func smartRefreshTicker(c *controller.Controller) <-chan time.Time {
+	if c.SMARTRefreshInterval <= 0 {
+		return nil
+	}
+	klog.Infof("smart data of the disks will be refreshed every %v, %d disks at a time",
+		c.SMARTRefreshInterval, c.SMARTRefreshConcurrency)
+	return time.NewTicker(c.SMARTRefreshInterval).C
+}
+
+// smartRefresher spreads the refresh of the SMART data of the disks over the refresh
+// interval, by refreshing each disk at a random offset within the interval, and limits
+// the number of disks that are read at the same time. Reading all the disks at once on
+// every tick would spike the IO on the node, and on all the nodes of a large cluster.
+type smartRefresher struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	// slots limits the number of disks being refreshed at the same time
+	slots chan struct{}
+	// pending are the disks whose refresh is scheduled but not yet done, so that a slow
+	// refresh is not scheduled again by the next tick
+	pending map[string]struct{}
+	// jitter gets the offset within the interval at which a disk is refreshed, it can be
+	// replaced in tests
+	jitter func(interval time.Duration) time.Duration
+	// after calls the function after the duration, it can be replaced in tests
+	after func(d time.Duration, f func())
+}
+
+func newSMARTRefresher(interval time.Duration, concurrency int) *smartRefresher {
+	if concurrency <= 0 {
+		concurrency = controller.DefaultSMARTRefreshConcurrency
+	}
+	return &smartRefresher{
+		interval: interval,
+		slots:    make(chan struct{}, concurrency),
+		pending:  make(map[string]struct{}),
+		jitter:   randomJitter,
+		after: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+	}
+}
+
+// randomJitter returns a random offset within the interval
+func randomJitter(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(interval)))
+}
+
+// schedule schedules the refresh of each of the devices at a random offset within the
+// interval. Devices whose earlier refresh is still pending are skipped.
+func (r *smartRefresher) schedule(devPaths []string, refresh func(devPath string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, devPath := range devPaths {
+		if _, ok := r.pending[devPath]; ok {
+			klog.V(4).Infof("smart refresh of device: %s is still pending, skipping", devPath)
+			continue
+		}
+		r.pending[devPath] = struct{}{}
+		devPath := devPath
+		r.after(r.jitter(r.interval), func() {
+			r.slots <- struct{}{}
+			defer func() {
+				<-r.slots
+				r.mutex.Lock()
+				delete(r.pending, devPath)
+				r.mutex.Unlock()
+			}()
+			refresh(devPath)
+		})
+	}
+}
+
+// refreshSMART schedules the refresh of the SMART data of the active disks on this node
+func (pe *ProbeEvent) refreshSMART(r *smartRefresher) {
+	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for smart refresh: %v", err)
+		return
+	}
+	names := make(map[string]string)
+	devPaths := make([]string, 0, len(bdAPIList.Items))
+	for _, bdAPI := range bdAPIList.Items {
+		if bdAPI.Spec.Details.DeviceType != blockdevice.BlockDeviceTypeDisk ||
+			bdAPI.Status.State != apis.BlockDeviceActive {
+			continue
+		}
+		names[bdAPI.Spec.Path] = bdAPI.Name
+		devPaths = append(devPaths, bdAPI.Spec.Path)
+	}
+	r.schedule(devPaths, func(devPath string) {
+		if !pe.Controller.BeginEventProcessing() {
+			return
+		}
+		defer pe.Controller.EndEventProcessing()
+		pe.refreshTemperature(names[devPath], devPath)
+	})
+}
+
+// refreshTemperature reads the temperature of the disk from SMART, and updates the
+// OverTemperature condition of its blockdevice. The blockdevice is read again, as it may
+// have changed since the refresh was scheduled.
+func (pe *ProbeEvent) refreshTemperature(name, devPath string) {
+	temperature, ok := readTemperature(devPath)
+	if !ok {
+		return
+	}
+	condition, ok := pe.Controller.GetTemperatureCondition(temperature)
+	if !ok {
+		return
+	}
+	bdAPI, err := pe.Controller.GetBlockDevice(name)
+	if err != nil {
+		return
+	}
+	if bdAPI.Spec.Path != devPath {
+		klog.V(4).Infof("blockdevice: %s moved from %s, skipping smart refresh", name, devPath)
+		return
+	}
+	if err := pe.Controller.SetBlockDeviceCondition(*bdAPI, condition); err != nil {
+		klog.Errorf("unable to set temperature condition on blockdevice: %s: %v", name, err)
+	}
+}
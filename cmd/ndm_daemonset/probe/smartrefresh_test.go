@@ -0,0 +1,197 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSMARTRefresherSpreadsRefreshes(t *testing.T) {
+	interval := time.Hour
+	r := newSMARTRefresher(interval, 2)
+	var offsets []time.Duration
+	r.after = func(d time.Duration, _ func()) {
+		offsets = append(offsets, d)
+	}
+
+	devPaths := make([]string, 1000)
+	for i := range devPaths {
+		devPaths[i] = fmt.Sprintf("/dev/disk%d", i)
+	}
+	r.schedule(devPaths, func(string) {})
+	assert.Equal(t, len(devPaths), len(offsets))
+
+	// the refreshes are spread over the whole interval, instead of bursting at its start
+	buckets := make([]int, 4)
+	for _, offset := range offsets {
+		assert.True(t, offset >= 0 && offset < interval, "offset %v outside the interval", offset)
+		buckets[int(offset*time.Duration(len(buckets))/interval)]++
+	}
+	for i, count := range buckets {
+		assert.Greater(t, count, 150, "too few refreshes in quarter %d of the interval", i)
+		assert.Less(t, count, 350, "too many refreshes in quarter %d of the interval", i)
+	}
+}
+
+func TestSMARTRefresherLimitsConcurrency(t *testing.T) {
+	r := newSMARTRefresher(time.Hour, 2)
+	r.after = func(_ time.Duration, f func()) {
+		go f()
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	active, maxActive := 0, 0
+	refreshed := make(map[string]bool)
+	devPaths := []string{"/dev/sda", "/dev/sdb", "/dev/sdc", "/dev/sdd", "/dev/sde", "/dev/sdf"}
+	wg.Add(len(devPaths))
+	r.schedule(devPaths, func(devPath string) {
+		defer wg.Done()
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		refreshed[devPath] = true
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	})
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxActive, 2)
+	assert.Equal(t, len(devPaths), len(refreshed))
+}
+
+func TestSMARTRefresherSkipsPendingRefresh(t *testing.T) {
+	r := newSMARTRefresher(time.Hour, 2)
+	var scheduled []func()
+	r.after = func(_ time.Duration, f func()) {
+		scheduled = append(scheduled, f)
+	}
+	var refreshed []string
+	refresh := func(devPath string) {
+		refreshed = append(refreshed, devPath)
+	}
+
+	r.schedule([]string{"/dev/sda"}, refresh)
+	// the next tick, before the first refresh is done
+	r.schedule([]string{"/dev/sda", "/dev/sdb"}, refresh)
+	assert.Equal(t, 2, len(scheduled))
+
+	for _, f := range scheduled {
+		f()
+	}
+	assert.Equal(t, []string{"/dev/sda", "/dev/sdb"}, refreshed)
+
+	// once done, the device is scheduled again
+	r.schedule([]string{"/dev/sda"}, refresh)
+	assert.Equal(t, 3, len(scheduled))
+}
+
+func TestRefreshTemperature(t *testing.T) {
+	defer func(old func(string) (int16, bool)) { readTemperature = old }(readTemperature)
+
+	tests := map[string]struct {
+		temperature   int16
+		readable      bool
+		path          string
+		wantCondition bool
+		wantStatus    v1.ConditionStatus
+	}{
+		"disk above the threshold": {
+			temperature:   72,
+			readable:      true,
+			path:          "/dev/sda",
+			wantCondition: true,
+			wantStatus:    v1.ConditionTrue,
+		},
+		"disk within the threshold": {
+			temperature:   40,
+			readable:      true,
+			path:          "/dev/sda",
+			wantCondition: true,
+			wantStatus:    v1.ConditionFalse,
+		},
+		"temperature not readable": {
+			readable:      false,
+			path:          "/dev/sda",
+			wantCondition: false,
+		},
+		"blockdevice moved to another path": {
+			temperature:   72,
+			readable:      true,
+			path:          "/dev/sdb",
+			wantCondition: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			readTemperature = func(string) (int16, bool) {
+				return tt.temperature, tt.readable
+			}
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+			bdAPI := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{Name: "blockdevice-smart"},
+				Spec:       apis.DeviceSpec{Path: tt.path},
+				Status:     apis.DeviceStatus{State: apis.BlockDeviceActive},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), bdAPI))
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						TemperatureConfig: &controller.TemperatureConfig{WarningThreshold: 60},
+					},
+				},
+			}
+			pe.refreshTemperature(bdAPI.Name, "/dev/sda")
+
+			got := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: bdAPI.Name}, got))
+			if !tt.wantCondition {
+				assert.Empty(t, got.Status.Conditions)
+				return
+			}
+			if assert.Len(t, got.Status.Conditions, 1) {
+				assert.Equal(t, apis.BlockDeviceOverTemperature, got.Status.Conditions[0].Type)
+				assert.Equal(t, tt.wantStatus, got.Status.Conditions[0].Status)
+			}
+		})
+	}
+}
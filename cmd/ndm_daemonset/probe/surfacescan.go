@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/surfacescan"
+
+	"k8s.io/klog/v2"
+)
+
+// scanSurface is a variable, so that it can be replaced in tests
+var scanSurface = func(s *surfacescan.Scanner, devPath string, size int64) (surfacescan.Result, error) {
+	return s.ScanDevice(devPath, size)
+}
+
+// surfaceScanTicker returns the channel on which the surface scan of the disks should be
+// triggered. nil is returned if the surface scan is disabled, so that the receive blocks forever.
+func surfaceScanTicker(c *controller.Controller) <-chan time.Time {
+	if c.SurfaceScanner == nil {
+		return nil
+	}
+	klog.Infof("disks will be surface scanned every %v", c.SurfaceScanInterval)
+	return time.NewTicker(c.SurfaceScanInterval).C
+}
+
+// scanSurfaces surface scans the unclaimed disks on this node that are not in use, and
+// sets the MediaErrors condition on their resources
+func (pe *ProbeEvent) scanSurfaces() {
+	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for surface scan: %v", err)
+		return
+	}
+
+	for _, bdAPI := range bdAPIList.Items {
+		bd, ok := pe.getSurfaceScannableDevice(bdAPI)
+		if !ok {
+			continue
+		}
+		result, err := scanSurface(pe.Controller.SurfaceScanner, bd.DevPath, int64(bd.Capacity.Storage))
+		if err != nil {
+			klog.Errorf("surface scan of device: %s failed: %v", bd.DevPath, err)
+			continue
+		}
+		if len(result.UnreadableOffsets) > 0 {
+			klog.Warningf("eventcode=%s msg=%s unreadable=%d samples=%d rname=%v",
+				"ndm.blockdevice.media.errors", "Surface scan found unreadable sectors",
+				len(result.UnreadableOffsets), result.SamplesRead, bdAPI.Name)
+		}
+		condition := controller.GetMediaErrorsCondition(result)
+		if err := pe.Controller.SetBlockDeviceCondition(bdAPI, condition); err != nil {
+			klog.Errorf("unable to set media errors condition on blockdevice: %s: %v", bdAPI.Name, err)
+		}
+	}
+}
+
+// getSurfaceScannableDevice gets the device of the blockdevice, if it can be surface scanned.
+// Only active and unclaimed disks are scanned, and never if the disk or any of its
+// partitions is in use, mounted or has a holder.
+func (pe *ProbeEvent) getSurfaceScannableDevice(bdAPI apis.BlockDevice) (blockdevice.BlockDevice, bool) {
+	if bdAPI.Spec.Details.DeviceType != blockdevice.BlockDeviceTypeDisk ||
+		bdAPI.Status.State != apis.BlockDeviceActive ||
+		bdAPI.Status.ClaimState != apis.BlockDeviceUnclaimed {
+		return blockdevice.BlockDevice{}, false
+	}
+	bd, ok := pe.Controller.BDHierarchy.Get(bdAPI.Spec.Path)
+	if !ok || isDeviceInUse(bd) {
+		return blockdevice.BlockDevice{}, false
+	}
+	for _, partitionPath := range bd.DependentDevices.Partitions {
+		partitionBD, ok := pe.Controller.BDHierarchy.Get(partitionPath)
+		if !ok || isDeviceInUse(partitionBD) {
+			return blockdevice.BlockDevice{}, false
+		}
+	}
+	return bd, true
+}
+
+// isDeviceInUse checks if the device is in use, mounted or has a holder
+func isDeviceInUse(bd blockdevice.BlockDevice) bool {
+	return bd.DevUse.InUse ||
+		len(bd.DependentDevices.Holders) > 0 ||
+		len(bd.FSInfo.MountPoint) > 0
+}
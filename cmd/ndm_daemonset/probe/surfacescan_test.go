@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/surfacescan"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newSurfaceScanBDAPI(name, path, deviceType string, claimState apis.DeviceClaimState) *apis.BlockDevice {
+	return &apis.BlockDevice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				controller.KubernetesHostNameLabel: "node1",
+			},
+		},
+		Spec: apis.DeviceSpec{
+			Path: path,
+			Details: apis.DeviceDetails{
+				DeviceType: deviceType,
+			},
+		},
+		Status: apis.DeviceStatus{
+			ClaimState: claimState,
+			State:      apis.BlockDeviceActive,
+		},
+	}
+}
+
+func TestGetSurfaceScannableDevice(t *testing.T) {
+	disk := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sda1"},
+		},
+	}
+	tests := map[string]struct {
+		bdAPI     *apis.BlockDevice
+		partition blockdevice.BlockDevice
+		want      bool
+	}{
+		"unclaimed disk not in use": {
+			bdAPI:     newSurfaceScanBDAPI("bd-sda", "/dev/sda", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceUnclaimed),
+			partition: blockdevice.BlockDevice{},
+			want:      true,
+		},
+		"claimed disk": {
+			bdAPI:     newSurfaceScanBDAPI("bd-sda", "/dev/sda", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceClaimed),
+			partition: blockdevice.BlockDevice{},
+			want:      false,
+		},
+		"partition": {
+			bdAPI:     newSurfaceScanBDAPI("bd-sda1", "/dev/sda", blockdevice.BlockDeviceTypePartition, apis.BlockDeviceUnclaimed),
+			partition: blockdevice.BlockDevice{},
+			want:      false,
+		},
+		"disk with a mounted partition": {
+			bdAPI: newSurfaceScanBDAPI("bd-sda", "/dev/sda", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceUnclaimed),
+			partition: blockdevice.BlockDevice{
+				FSInfo: blockdevice.FileSystemInformation{
+					MountPoint: []string{"/var/data"},
+				},
+			},
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cache := blockdevice.NewHierarchyCache(nil)
+			cache.Set(disk.DevPath, disk)
+			partitionBD := tt.partition
+			partitionBD.DevPath = "/dev/sda1"
+			cache.Set(partitionBD.DevPath, partitionBD)
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					BDHierarchy: cache,
+				},
+			}
+			_, got := pe.getSurfaceScannableDevice(*tt.bdAPI)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScanSurfaces(t *testing.T) {
+	oldScanSurface := scanSurface
+	defer func() { scanSurface = oldScanSurface }()
+	scanned := make(map[string]bool)
+	scanSurface = func(_ *surfacescan.Scanner, devPath string, _ int64) (surfacescan.Result, error) {
+		scanned[devPath] = true
+		if devPath == "/dev/sdb" {
+			return surfacescan.Result{SamplesRead: 64, UnreadableOffsets: []int64{4096}}, nil
+		}
+		return surfacescan.Result{SamplesRead: 64}, nil
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s,
+		newSurfaceScanBDAPI("bd-sda", "/dev/sda", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceUnclaimed),
+		newSurfaceScanBDAPI("bd-sdb", "/dev/sdb", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceUnclaimed),
+		newSurfaceScanBDAPI("bd-sdc", "/dev/sdc", blockdevice.BlockDeviceTypeDisk, apis.BlockDeviceUnclaimed))
+
+	cache := blockdevice.NewHierarchyCache(nil)
+	for _, devPath := range []string{"/dev/sda", "/dev/sdb", "/dev/sdc"} {
+		bd := blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+		}
+		// the disk in use is never scanned
+		bd.DevUse.InUse = devPath == "/dev/sdc"
+		cache.Set(devPath, bd)
+	}
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:      cl,
+			BDHierarchy:    cache,
+			NodeAttributes: map[string]string{controller.HostNameKey: "node1"},
+			SurfaceScanner: surfacescan.NewScanner(controller.DefaultSurfaceScanRate),
+		},
+	}
+	pe.scanSurfaces()
+
+	assert.Equal(t, map[string]bool{"/dev/sda": true, "/dev/sdb": true}, scanned)
+	tests := map[string]struct {
+		wantStatus v1.ConditionStatus
+		wantFound  bool
+	}{
+		"bd-sda": {wantStatus: v1.ConditionFalse, wantFound: true},
+		"bd-sdb": {wantStatus: v1.ConditionTrue, wantFound: true},
+		"bd-sdc": {wantFound: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bdAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: name}, bdAPI))
+			var found bool
+			for _, condition := range bdAPI.Status.Conditions {
+				if condition.Type == apis.BlockDeviceMediaErrors {
+					found = true
+					assert.Equal(t, tt.wantStatus, condition.Status)
+				}
+			}
+			assert.Equal(t, tt.wantFound, found)
+		})
+	}
+}
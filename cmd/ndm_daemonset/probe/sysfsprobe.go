@@ -27,7 +27,6 @@ import (
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/sysfs"
-	"github.com/openebs/node-disk-manager/pkg/util"
 	"k8s.io/klog/v2"
 )
 
@@ -41,38 +40,39 @@ var (
 	sysfsProbeState = defaultEnabled
 )
 
+// isSparseFile checks if the file backing a loop device is sparse, it is a variable
+// so that it can be replaced in tests
+var isSparseFile = sysfs.IsSparseFile
+
 var sysfsProbeRegister = func() {
 	ctrl := <-controller.ControllerBroadcastChannel
 	if ctrl == nil {
 		klog.Error("unable to configure", sysfsProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == sysfsConfigKey {
-				sysfsProbeName = probeConfig.Name
-				sysfsProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
-
 	newRegistryProbe := &registerProbe{
 		priority:   sysfsProbePriority,
 		name:       sysfsProbeName,
 		state:      sysfsProbeState,
-		pi:         newSysFSProbe(),
+		configKey:  sysfsConfigKey,
+		required:   true,
+		pi:         newSysFSProbe(ctrl),
 		controller: ctrl,
 	}
+	newRegistryProbe.configure()
+	sysfsProbeName = newRegistryProbe.name
 	newRegistryProbe.register()
 }
 
 // sysfsProbe fills the logical sector size,
 // physical sector size, drive type(ssd or hdd) of the disk
-type sysfsProbe struct{}
+type sysfsProbe struct {
+	// Controller is used to track the IO errors of the devices
+	Controller *controller.Controller
+}
 
-func newSysFSProbe() *sysfsProbe {
-	return &sysfsProbe{}
+func newSysFSProbe(ctrl *controller.Controller) *sysfsProbe {
+	return &sysfsProbe{Controller: ctrl}
 }
 
 // It is part of probe interface. Hence, empty implementation.
@@ -101,6 +101,22 @@ func (cp *sysfsProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevic
 	// sector size and drive type.
 	// Get the parent disk sysfs device using the parent's dev path stored in the blokdevice
 	if blockDevice.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition {
+		// the slot of the partition on the parent disk is read from the partition itself
+		if blockDevice.PartitionInfo.PartitionNumber == 0 {
+			number, err := sysFsDevice.GetPartitionNumber()
+			if err != nil {
+				klog.Warningf("unable to get partition number for device: %s, err: %v", blockDevice.DevPath, err)
+			}
+			blockDevice.PartitionInfo.PartitionNumber = number
+		}
+		startOffset, err := sysFsDevice.GetPartitionStartOffset()
+		if err != nil {
+			klog.Warningf("unable to get start offset for partition: %s, err: %v", blockDevice.DevPath, err)
+		}
+		blockDevice.PartitionInfo.StartOffset = startOffset
+		klog.V(4).Infof("blockdevice path: %s partition number :%d start offset :%d filled by sysfs probe.",
+			blockDevice.DevPath, blockDevice.PartitionInfo.PartitionNumber, blockDevice.PartitionInfo.StartOffset)
+
 		parentDev := blockDevice.DependentDevices.Parent
 		if parentDev == "" {
 			klog.Errorf("cannot find the parent disk for partition: %s", blockDevice.DevPath)
@@ -150,6 +166,118 @@ func (cp *sysfsProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevic
 			blockDevice.DevPath, blockDevice.DeviceAttributes.HardwareSectorSize)
 	}
 
+	fabricInfo, err := sysFsDevice.GetFabricInfo()
+	if err != nil {
+		klog.Warningf("unable to get transport for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	blockDevice.DeviceAttributes.Transport = fabricInfo.Transport
+	blockDevice.DeviceAttributes.FabricTarget = fabricInfo.Target
+	blockDevice.DeviceAttributes.FabricAddress = fabricInfo.Address
+	if blockDevice.DeviceAttributes.Transport == "" {
+		blockDevice.DeviceAttributes.Transport = sysFsDevice.GetLocalTransport()
+	}
+	klog.V(4).Infof("blockdevice path: %s transport :%s target :%s address :%s filled by sysfs probe.",
+		blockDevice.DevPath, blockDevice.DeviceAttributes.Transport, fabricInfo.Target, fabricInfo.Address)
+
+	if busAddress, ok := sysFsDevice.GetVirtioBusAddress(); ok {
+		blockDevice.DeviceAttributes.VirtioBusAddress = busAddress
+		klog.V(4).Infof("blockdevice path: %s virtio bus address :%s filled by sysfs probe.",
+			blockDevice.DevPath, busAddress)
+	}
+
+	if controllerInfo, ok := sysFsDevice.GetNVMeControllerInfo(); ok {
+		blockDevice.DeviceAttributes.ControllerSerial = controllerInfo.Serial
+		blockDevice.DeviceAttributes.ControllerID = controllerInfo.ID
+		klog.V(4).Infof("blockdevice path: %s nvme controller :%s serial :%s id :%s filled by sysfs probe.",
+			blockDevice.DevPath, controllerInfo.Name, controllerInfo.Serial, controllerInfo.ID)
+	}
+
+	discardSupported, discardGranularity, err := sysFsDevice.GetDiscardInfo()
+	if err != nil {
+		klog.Warningf("unable to get discard support for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	blockDevice.DeviceAttributes.DiscardSupported = discardSupported
+	blockDevice.DeviceAttributes.DiscardGranularity = discardGranularity
+	klog.V(4).Infof("blockdevice path: %s discard supported :%t granularity :%d filled by sysfs probe.",
+		blockDevice.DevPath, discardSupported, discardGranularity)
+
+	ioScheduler, err := sysFsDevice.GetIOScheduler()
+	if err != nil {
+		klog.V(4).Infof("unable to get io scheduler for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	blockDevice.DeviceAttributes.IOScheduler = ioScheduler
+	queueDepth, err := sysFsDevice.GetQueueDepth()
+	if err != nil {
+		klog.V(4).Infof("unable to get queue depth for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	blockDevice.DeviceAttributes.QueueDepth = queueDepth
+	klog.V(4).Infof("blockdevice path: %s io scheduler :%s queue depth :%d filled by sysfs probe.",
+		blockDevice.DevPath, ioScheduler, queueDepth)
+
+	writeCacheEnabled, err := sysFsDevice.GetWriteCacheEnabled()
+	if err != nil {
+		klog.V(4).Infof("unable to get write cache state for device: %s, err: %v", blockDevice.DevPath, err)
+	} else {
+		blockDevice.DeviceAttributes.WriteCacheEnabled = &writeCacheEnabled
+		klog.V(4).Infof("blockdevice path: %s write cache enabled :%t filled by sysfs probe.",
+			blockDevice.DevPath, writeCacheEnabled)
+	}
+
+	numaNode, ok, err := sysFsDevice.GetNUMANode()
+	if err != nil {
+		klog.Warningf("unable to get numa node for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	if ok {
+		blockDevice.DeviceAttributes.NUMANode = &numaNode
+		klog.V(4).Infof("blockdevice path: %s numa node :%d filled by sysfs probe.",
+			blockDevice.DevPath, numaNode)
+	}
+
+	pcieLink, ok, err := sysFsDevice.GetPCIeLink()
+	if err != nil {
+		klog.Warningf("unable to get pcie link for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	if ok {
+		blockDevice.DeviceAttributes.PCIeLink = &pcieLink
+		klog.V(4).Infof("blockdevice path: %s pcie link :%v GT/s x%d (max %v GT/s x%d) filled by sysfs probe.",
+			blockDevice.DevPath, pcieLink.CurrentSpeed, pcieLink.CurrentWidth, pcieLink.MaxSpeed, pcieLink.MaxWidth)
+		if pcieLink.IsDegraded() {
+			klog.Warningf("eventcode=%s msg=%s rname=%v current=%v GT/s x%d max=%v GT/s x%d",
+				"ndm.blockdevice.pcie.link.degraded", "PCIe link negotiated below its maximum",
+				blockDevice.DevPath, pcieLink.CurrentSpeed, pcieLink.CurrentWidth, pcieLink.MaxSpeed, pcieLink.MaxWidth)
+		}
+	}
+
+	if cp.Controller != nil && cp.Controller.IOErrors != nil {
+		ioErrorCount, ok, err := sysFsDevice.GetIOErrorCount()
+		if err != nil {
+			klog.Warningf("unable to get io error count for device: %s, err: %v", blockDevice.DevPath, err)
+		}
+		if ok {
+			ioErrors := cp.Controller.IOErrors.Record(blockDevice.DevPath, ioErrorCount)
+			blockDevice.DeviceAttributes.IOErrors = &ioErrors
+			klog.V(4).Infof("blockdevice path: %s io errors :%d (%d in the last %v) filled by sysfs probe.",
+				blockDevice.DevPath, ioErrors.Count, ioErrors.RecentCount, ioErrors.Window)
+			if ioErrors.Failing {
+				klog.Warningf("eventcode=%s msg=%s rname=%v errors=%d window=%v",
+					"ndm.blockdevice.io.errors", "Device reported io errors above the threshold",
+					blockDevice.DevPath, ioErrors.RecentCount, ioErrors.Window)
+			}
+		}
+	}
+
+	removable, err := sysFsDevice.IsRemovable()
+	if err != nil {
+		klog.Warningf("unable to get removable state for device: %s, err: %v", blockDevice.DevPath, err)
+	}
+	blockDevice.DeviceAttributes.Removable = removable
+	klog.V(4).Infof("blockdevice path: %s removable :%t filled by sysfs probe.",
+		blockDevice.DevPath, blockDevice.DeviceAttributes.Removable)
+
+	if blockDevice.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypeLoop {
+		fillLoopInformation(sysFsDevice, blockDevice)
+	}
+
 	if blockDevice.DeviceAttributes.DriveType == "" ||
 		blockDevice.DeviceAttributes.DriveType == blockdevice.DriveTypeUnknown {
 		driveType, err := sysFsDevice.GetDriveType()
@@ -161,3 +289,26 @@ func (cp *sysfsProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevic
 			blockDevice.DevPath, blockDevice.DeviceAttributes.DriveType)
 	}
 }
+
+// fillLoopInformation fills the file backing the loop device, and whether the file
+// is sparse. The backing file may not be accessible from the NDM container, in which
+// case the sparse state is not known.
+func fillLoopInformation(sysFsDevice *sysfs.Device, blockDevice *blockdevice.BlockDevice) {
+	backingFile, ok, err := sysFsDevice.GetLoopBackingFile()
+	if err != nil {
+		klog.Warningf("unable to get backing file of loop device: %s, err: %v", blockDevice.DevPath, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	blockDevice.LoopInfo.BackingFile = backingFile
+	sparse, err := isSparseFile(backingFile)
+	if err != nil {
+		klog.V(4).Infof("unable to check if backing file: %s of loop device: %s is sparse, err: %v",
+			backingFile, blockDevice.DevPath, err)
+	}
+	blockDevice.LoopInfo.Sparse = sparse
+	klog.V(4).Infof("blockdevice path: %s backing file: %s sparse: %t filled by sysfs probe.",
+		blockDevice.DevPath, backingFile, sparse)
+}
@@ -18,6 +18,7 @@ package probe
 
 import (
 	"errors"
+	"time"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
@@ -25,7 +26,6 @@ import (
 	"github.com/openebs/node-disk-manager/pkg/sysfs"
 	libudevwrapper "github.com/openebs/node-disk-manager/pkg/udev"
 	"github.com/openebs/node-disk-manager/pkg/udevevent"
-	"github.com/openebs/node-disk-manager/pkg/util"
 	"golang.org/x/sync/semaphore"
 
 	"k8s.io/klog/v2"
@@ -48,22 +48,16 @@ var udevProbeRegister = func() {
 		klog.Error("unable to configure", udevProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == udevConfigKey {
-				udevProbeName = probeConfig.Name
-				udevProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
 	newRegisterProbe := &registerProbe{
 		priority:   udevProbePriority,
 		name:       udevProbeName,
 		state:      udevProbeState,
+		configKey:  udevConfigKey,
 		pi:         newUdevProbe(ctrl),
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
+	udevProbeName = newRegisterProbe.name
 	newRegisterProbe.register()
 }
 
@@ -163,22 +157,46 @@ func (up *udevProbe) scan() error {
 	}
 	defer sem.Release(1)
 
+	// everytime while performing the scan, we are re-initializing the
+	// disk map of the system
+	up.controller.BDHierarchy.Reset()
+	diskInfo, disksUid, err := up.listDevices()
+	if err != nil {
+		return err
+	}
+
+	// when GPTBasedUUID is enabled, all the blockdevices will be made inactive initially.
+	// after that each device that is detected by the probe will be marked as Active.
+	// the removal policy is applied to the resources that remain inactive, once all
+	// the devices are processed.
+	staleUUIDs := up.controller.DeactivateStaleBlockDeviceResource(disksUid)
+	eventDetails := controller.EventMessage{
+		Action:     libudevwrapper.UDEV_ACTION_ADD,
+		Devices:    diskInfo,
+		FullScan:   true,
+		StaleUUIDs: staleUUIDs,
+	}
+	controller.EventMessageChannel <- eventDetails
+	return nil
+}
+
+// listDevices lists the disks and partitions on the system, with the details required
+// for identifying the devices filled. The udev uids of the devices are also returned,
+// they are used for identifying the devices if GPTBasedUUID is disabled.
+func (up *udevProbe) listDevices() ([]*blockdevice.BlockDevice, []string, error) {
 	if (up.udev == nil) || (up.udevEnumerate == nil) {
-		return errors.New("unable to scan udev and udev enumerate is nil")
+		return nil, nil, errors.New("unable to scan udev and udev enumerate is nil")
 	}
 	diskInfo := make([]*blockdevice.BlockDevice, 0)
 	disksUid := make([]string, 0)
 	err := up.udevEnumerate.AddSubsystemFilter(libudevwrapper.UDEV_SUBSYSTEM)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	err = up.udevEnumerate.ScanDevices()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	// everytime while performing the scan, we are re-initializing the
-	// disk map of the system
-	up.controller.BDHierarchy = make(blockdevice.Hierarchy)
 	for l := up.udevEnumerate.ListEntry(); l != nil; l = l.GetNextEntry() {
 		s := l.GetName()
 		newUdevice, err := up.udev.NewDeviceFromSysPath(s)
@@ -199,7 +217,7 @@ func (up *udevProbe) scan() error {
 				deviceDetails.FSInfo.FileSystemUUID = newUdevice.GetPropertyValue(libudevwrapper.UDEV_FS_UUID)
 				deviceDetails.DMInfo.DMUUID = newUdevice.GetPropertyValue(libudevwrapper.UDEV_DM_UUID)
 			} else {
-				uuid := newUdevice.GetUid()
+				uuid := controller.SanitizeUUID(newUdevice.GetPath(), newUdevice.GetUid())
 				disksUid = append(disksUid, uuid)
 				deviceDetails.UUID = uuid
 			}
@@ -259,16 +277,7 @@ func (up *udevProbe) scan() error {
 		}
 		newUdevice.UdevDeviceUnref()
 	}
-
-	// when GPTBasedUUID is enabled, all the blockdevices will be made inactive initially.
-	// after that each device that is detected by the probe will be marked as Active.
-	up.controller.DeactivateStaleBlockDeviceResource(disksUid)
-	eventDetails := controller.EventMessage{
-		Action:  libudevwrapper.UDEV_ACTION_ADD,
-		Devices: diskInfo,
-	}
-	controller.EventMessageChannel <- eventDetails
-	return nil
+	return diskInfo, disksUid, nil
 }
 
 // fillDiskDetails fills details in diskInfo struct using probe information
@@ -325,6 +334,15 @@ func (up *udevProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevice
 		})
 	}
 
+	// the friendly name of a dm device is stored as a devlink, as the device is
+	// identified by its kernel path (/dev/dm-N) in the hierarchy
+	if udevDiskDetails.DMPath != "" {
+		blockDevice.DevLinks = append(blockDevice.DevLinks, blockdevice.DevLink{
+			Kind:  libudevwrapper.DM_NAME_LINK,
+			Links: []string{udevDiskDetails.DMPath},
+		})
+	}
+
 	if len(udevDiskDetails.SymLinks) != 0 {
 		blockDevice.DevLinks = append(blockDevice.DevLinks, blockdevice.DevLink{
 			Kind:  libudevwrapper.SYMLINK,
@@ -335,12 +353,17 @@ func (up *udevProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevice
 	// filesystem info of the attached device. Only filesystem data will be filled in the struct,
 	// as the mountpoint related information will be filled in by the mount probe
 	blockDevice.FSInfo.FileSystem = udevDiskDetails.FileSystem
+	blockDevice.FSInfo.FileSystemLabel = udevDiskDetails.FileSystemLabel
 
 	blockDevice.PartitionInfo.PartitionTableType = udevDiskDetails.PartitionTableType
 
+	blockDevice.UdevProperties = udevDiskDetails.Properties
+
 	// if this is a partition, partition number and partition UUID need to be filled
 	if udevDiskDetails.DiskType == blockdevice.BlockDeviceTypePartition {
 		blockDevice.PartitionInfo.PartitionNumber = udevDiskDetails.PartitionNumber
+		blockDevice.PartitionInfo.PartitionType = udevDiskDetails.PartitionType
+		blockDevice.PartitionInfo.PartitionName = udevDiskDetails.PartitionName
 	}
 }
 
@@ -356,15 +379,92 @@ func (up *udevProbe) listen() {
 		Controller: up.controller,
 	}
 	klog.Info("starting udev probe listener")
+	// reclaim and surface scan are done in the same loop, so that they are not
+	// processed concurrently with the device events
+	reclaim := reclaimTicker(up.controller)
+	surfaceScan := surfaceScanTicker(up.controller)
+	// the smart refresh is only scheduled in the loop, the disks are read in the
+	// background spread over the refresh interval
+	smartRefresh := smartRefreshTicker(up.controller)
+	// the devices that are not ready to accept IO are checked again in the same loop,
+	// so that the other events are not blocked while they get ready
+	readinessRetry := readinessRetryTicker(up.controller)
+	// the removable disks deferred for the removable device delay are processed in the
+	// same loop, once they have been present for the delay
+	removableRetry := removableRetryTicker(up.controller)
+	refresher := newSMARTRefresher(up.controller.SMARTRefreshInterval, up.controller.SMARTRefreshConcurrency)
+	// blank disks are not partitioned till the node is provisioned, a rescan is
+	// done once provisioning completes to partition them.
+	provisioned := up.controller.ProvisioningDone()
+	// the labels of the blockdevices derived from the node labels are updated in the
+	// same loop, as the events use the node attributes being updated
+	nodeLabels := up.controller.NodeLabelChanges()
+	shutdown := up.controller.ShutdownStarted()
 	for {
-		msg := <-controller.EventMessageChannel
-		switch msg.Action {
-		case string(AttachEA):
-			probeEvent.addBlockDeviceEvent(msg)
-		case string(DetachEA):
-			probeEvent.deleteBlockDeviceEvent(msg)
-		case string(ChangeEA):
-			probeEvent.changeBlockDeviceEvent(msg)
+		select {
+		case msg := <-controller.EventMessageChannel:
+			if !up.controller.BeginEventProcessing() {
+				klog.Infof("shutting down, %s event of %d devices not processed", msg.Action, len(msg.Devices))
+				continue
+			}
+			switch msg.Action {
+			case string(AttachEA):
+				probeEvent.addBlockDeviceEvent(msg)
+			case string(DetachEA):
+				probeEvent.deleteBlockDeviceEvent(msg)
+			case string(ChangeEA):
+				probeEvent.changeBlockDeviceEvent(msg)
+			}
+			up.controller.EndEventProcessing()
+		case <-reclaim:
+			if !up.controller.BeginEventProcessing() {
+				continue
+			}
+			probeEvent.reclaimPartitions()
+			up.controller.EndEventProcessing()
+		case <-surfaceScan:
+			if !up.controller.BeginEventProcessing() {
+				continue
+			}
+			probeEvent.scanSurfaces()
+			up.controller.EndEventProcessing()
+		case <-smartRefresh:
+			if !up.controller.BeginEventProcessing() {
+				continue
+			}
+			probeEvent.refreshSMART(refresher)
+			up.controller.EndEventProcessing()
+		case <-readinessRetry:
+			if len(probeEvent.notReady) == 0 || !up.controller.BeginEventProcessing() {
+				continue
+			}
+			probeEvent.retryNotReadyDevices()
+			up.controller.EndEventProcessing()
+		case <-removableRetry:
+			if len(probeEvent.removableDisks) == 0 || !up.controller.BeginEventProcessing() {
+				continue
+			}
+			probeEvent.retryRemovableDevices()
+			up.controller.EndEventProcessing()
+		case req := <-decisionRequestChannel:
+			if !up.controller.BeginEventProcessing() {
+				req.result <- decisionResult{err: errEvaluationUnavailable}
+				continue
+			}
+			serveDecisionRequest(up.controller, req)
+			up.controller.EndEventProcessing()
+		case labels := <-nodeLabels:
+			if !up.controller.BeginEventProcessing() {
+				continue
+			}
+			up.controller.ReconcileNodeLabels(labels)
+			up.controller.EndEventProcessing()
+		case <-shutdown:
+			klog.Info("stopping udev probe listener")
+			return
+		case <-provisioned:
+			provisioned = nil
+			go Rescan(up.controller)
 		}
 	}
 }
@@ -384,21 +484,64 @@ func (up *udevProbe) free() {
 
 func (up *udevProbe) listenUdevEventMonitor(errChan <-chan error) {
 	eventChan := up.udeveventSubscription.Events()
+	window := up.controller.EventCoalesceWindow
+	coalescer := newEventCoalescer(window)
+	timer := time.NewTimer(window)
+	timer.Stop()
+	var timerChan <-chan time.Time
+	shutdown := up.controller.ShutdownStarted()
 	for {
 		select {
 		case event := <-eventChan:
-			controller.EventMessageChannel <- processUdevEvent(event)
+			msg := processUdevEvent(event)
+			if window == 0 {
+				if !sendEvent(msg, shutdown) {
+					return
+				}
+				continue
+			}
+			coalescer.add(msg, time.Now())
+		case <-timerChan:
+			for _, msg := range coalescer.flush(time.Now()) {
+				if !sendEvent(msg, shutdown) {
+					return
+				}
+			}
+		case <-shutdown:
+			// the buffered events are dropped, all the devices are processed again
+			// by the scan on startup.
+			if n := coalescer.discard(); n > 0 {
+				klog.Infof("shutting down, buffered events of %d disks discarded", n)
+			}
+			return
 		case err := <-errChan:
 			klog.Error(err)
 		}
+		// wait for the earliest window among the buffered events to expire
+		timerChan = nil
+		if deadline, ok := coalescer.nextDeadline(); ok {
+			timer.Reset(time.Until(deadline))
+			timerChan = timer.C
+		}
+	}
+}
+
+// sendEvent sends the event message to the listener. false is returned if the message
+// could not be sent as the controller is shutting down.
+func sendEvent(msg controller.EventMessage, shutdown <-chan struct{}) bool {
+	select {
+	case controller.EventMessageChannel <- msg:
+		return true
+	case <-shutdown:
+		return false
 	}
 }
 
 func processUdevEvent(event udevevent.UdevEvent) controller.EventMessage {
 	defer event.UdevDeviceUnref()
 	diskInfo := make([]*blockdevice.BlockDevice, 0)
-	uuid := event.GetUid()
 	path := event.GetPath()
+	uuid := controller.SanitizeUUID(path, event.GetUid())
 	action := event.GetAction()
 	klog.Infof("processing new event for (%s) action type %s", path, action)
 	deviceDetails := &blockdevice.BlockDevice{}
@@ -433,6 +576,9 @@ func processUdevEvent(event udevevent.UdevEvent) controller.EventMessage {
 	deviceDetails.FSInfo.FileSystemUUID = event.GetPropertyValue(libudevwrapper.UDEV_FS_UUID)
 
 	deviceDetails.DMInfo.DMUUID = event.GetPropertyValue(libudevwrapper.UDEV_DM_UUID)
+	if dmName := event.GetPropertyValue(libudevwrapper.UDEV_DM_NAME); dmName != "" {
+		deviceDetails.DMInfo.DevMapperPath = "/dev/mapper/" + dmName
+	}
 
 	// fields used for dependents. dependents cannot be obtained while
 	// removing the device since sysfs entry will be absent
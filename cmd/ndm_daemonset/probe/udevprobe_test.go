@@ -140,6 +140,10 @@ func TestFillDiskDetails(t *testing.T) {
 	expectedDiskInfo.DevLinks = nil
 	actualDiskInfo.DevLinks = nil
 
+	// all the udev properties are retained, only the device name is compared
+	assert.Equal(t, mockOsDiskDetails.DevNode, actualDiskInfo.UdevProperties[libudevwrapper.UDEV_DEVNAME])
+	actualDiskInfo.UdevProperties = nil
+
 	assert.Equal(t, expectedDiskInfo, actualDiskInfo)
 }
 
@@ -161,7 +165,7 @@ func TestUdevProbe(t *testing.T) {
 		Probes:         probes,
 		Filters:        filters,
 		NodeAttributes: nodeAttributes,
-		BDHierarchy:    make(blockdevice.Hierarchy),
+		BDHierarchy:    blockdevice.NewHierarchyCache(nil),
 	}
 	udevprobe := newUdevProbe(fakeController)
 	var pi controller.ProbeInterface = udevprobe
@@ -196,7 +200,7 @@ func TestUdevProbe(t *testing.T) {
 	}
 	probeEvent.addBlockDeviceEvent(eventDetails)
 	// Retrieve disk resource
-	uuid, ok := generateUUID(*deviceDetails)
+	uuid, _, ok := generateUUID(*deviceDetails)
 	cdr1, err1 := fakeController.GetBlockDevice(uuid)
 	fakeDr, err := mockOsDiskToAPI()
 	if err != nil {
@@ -0,0 +1,208 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultUsedByPluginTimeout is the timeout of a used-by plugin, if none is configured
+	defaultUsedByPluginTimeout = 10 * time.Second
+	// maxUsedByPluginTimeout bounds the configured timeout, as the events of all the devices
+	// wait for the plugins to complete
+	maxUsedByPluginTimeout = time.Minute
+	// maxUsedByPluginOutput is the length of the output of the plugin that is kept as the
+	// description of the usage
+	maxUsedByPluginOutput = 256
+)
+
+// usedByPluginState is the usage of a device as reported by a used-by plugin
+type usedByPluginState string
+
+const (
+	// usedByPluginNotInUse is used if the plugin reports that the device is not in use
+	usedByPluginNotInUse usedByPluginState = "not-in-use"
+	// usedByPluginInUse is used if the plugin reports that the device is in use
+	usedByPluginInUse usedByPluginState = "in-use"
+	// usedByPluginUnknown is used if the plugin fails, times out or exits with an
+	// unexpected code
+	usedByPluginUnknown usedByPluginState = "unknown"
+)
+
+// usedByPluginResult is the result of running a used-by plugin for a device
+type usedByPluginResult struct {
+	State usedByPluginState
+	// Message is the output of the plugin if the device is in use, and the error if
+	// the usage is unknown
+	Message string
+}
+
+// deviceInUseByPlugin runs the used-by plugins in the config for the device and returns
+// true if further processing of the event is required. Devices reported in use by any of
+// the plugins get a resource tagged with the used-by-plugin tag, so that they are not
+// claimed by others, and they are never partitioned. If a plugin fails, the usage of the
+// device is unknown and the event is ignored, so that no destructive operation is
+// performed on a device that may be in use. It is processed again on the next event.
+// The plugins are not run for a device whose resource is claimed, as the device is
+// already in use, and the resource has to be updated even if a plugin fails.
+func (pe *ProbeEvent) deviceInUseByPlugin(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) (bool, error) {
+	if pe.Controller.NDMConfig == nil || len(pe.Controller.NDMConfig.UsedByPluginConfigs) == 0 {
+		return true, nil
+	}
+	if pe.isBlockDeviceClaimed(bd, bdAPIList) {
+		return true, nil
+	}
+	for _, plugin := range pe.Controller.NDMConfig.UsedByPluginConfigs {
+		result := runUsedByPlugin(plugin, bd)
+		switch result.State {
+		case usedByPluginInUse:
+			klog.Infof("device: %s is in use as reported by used-by plugin: %s, %s",
+				bd.DevPath, plugin.Name, result.Message)
+			bd.DecisionTrace.Add("used-by-plugin:in-use")
+			bd.DevUse = blockdevice.DeviceUsage{
+				InUse:    true,
+				UsedBy:   blockdevice.UsedByPlugin,
+				Reason:   fmt.Sprintf("%s: %s", plugin.Name, result.Message),
+				ProbedAt: bd.DevUse.ProbedAt,
+			}
+			if err := pe.pushTaggedDevice(bd, bdAPIList, blockdevice.UsedByPlugin, nil); err != nil {
+				return false, err
+			}
+			return false, nil
+		case usedByPluginUnknown:
+			klog.Warningf("eventcode=%s msg=%s plugin=%s err=%q rname=%v",
+				"ndm.blockdevice.usedby.plugin.failed", "Usage of device is unknown, ignoring the event",
+				plugin.Name, result.Message, bd.DevPath)
+			bd.DecisionTrace.Add("used-by-plugin:unknown")
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isBlockDeviceClaimed returns true if the device has a resource that is claimed
+func (pe *ProbeEvent) isBlockDeviceClaimed(bd blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) bool {
+	uuid, _, _, ok := pe.generateDeviceUUID(bd, bdAPIList)
+	if !ok {
+		uuid, _ = generateLegacyUUID(bd)
+	}
+	existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid)
+	return existingBD != nil && existingBD.Status.ClaimState != apis.BlockDeviceUnclaimed
+}
+
+// runUsedByPlugin runs the command of the plugin with the path of the device as the last
+// argument, and the identifiers of the device in the environment. The command, and any
+// process started by it, is killed if it does not complete within the timeout.
+func runUsedByPlugin(plugin controller.UsedByPluginConfig, bd blockdevice.BlockDevice) usedByPluginResult {
+	timeout := getUsedByPluginTimeout(plugin)
+	cmd := exec.Command(plugin.Command, append(append([]string{}, plugin.Args...), bd.DevPath)...)
+	cmd.Env = append(os.Environ(),
+		"NDM_DEVICE_PATH="+bd.DevPath,
+		"NDM_DEVICE_TYPE="+bd.DeviceAttributes.DeviceType,
+		"NDM_DEVICE_WWN="+bd.DeviceAttributes.WWN,
+		"NDM_DEVICE_SERIAL="+bd.DeviceAttributes.Serial,
+		"NDM_FILESYSTEM="+bd.FSInfo.FileSystem,
+	)
+	// the plugin runs in its own process group, so that the processes it starts are
+	// killed along with it on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Start(); err != nil {
+		return usedByPluginResult{
+			State:   usedByPluginUnknown,
+			Message: fmt.Sprintf("unable to start %s: %v", plugin.Command, err),
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return usedByPluginResult{
+			State:   usedByPluginUnknown,
+			Message: fmt.Sprintf("%s timed out after %v", plugin.Command, timeout),
+		}
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return usedByPluginResult{
+			State:   usedByPluginInUse,
+			Message: getUsedByPluginMessage(stdout.String()),
+		}
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return usedByPluginResult{State: usedByPluginNotInUse}
+	}
+	return usedByPluginResult{
+		State:   usedByPluginUnknown,
+		Message: fmt.Sprintf("%s failed: %v", plugin.Command, err),
+	}
+}
+
+// getUsedByPluginTimeout gets the timeout of the plugin from the config. The default
+// timeout is used if it is not set or is invalid, and it is bounded by the max timeout.
+func getUsedByPluginTimeout(plugin controller.UsedByPluginConfig) time.Duration {
+	if plugin.Timeout == "" {
+		return defaultUsedByPluginTimeout
+	}
+	timeout, err := time.ParseDuration(plugin.Timeout)
+	if err != nil || timeout <= 0 {
+		klog.Errorf("invalid timeout: %q for used-by plugin: %s, using default timeout: %v",
+			plugin.Timeout, plugin.Name, defaultUsedByPluginTimeout)
+		return defaultUsedByPluginTimeout
+	}
+	if timeout > maxUsedByPluginTimeout {
+		return maxUsedByPluginTimeout
+	}
+	return timeout
+}
+
+// getUsedByPluginMessage gets the first line of the output of the plugin, which describes
+// what uses the device
+func getUsedByPluginMessage(output string) string {
+	message := strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+	if len(message) > maxUsedByPluginOutput {
+		message = message[:maxUsedByPluginOutput]
+	}
+	if message == "" {
+		return "in use"
+	}
+	return message
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/db/kubernetes"
+	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// writeUsedByPlugin writes a fake used-by plugin script to a temp directory
+func writeUsedByPlugin(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "usedby.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunUsedByPlugin(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			Serial: "ZA1B2C3D",
+		},
+	}
+	tests := map[string]struct {
+		script      string
+		timeout     string
+		wantState   usedByPluginState
+		wantMessage string
+	}{
+		"device in use": {
+			script:      "echo \"drbd resource r0 on $1\"\nexit 0\n",
+			wantState:   usedByPluginInUse,
+			wantMessage: "drbd resource r0 on /dev/sdb",
+		},
+		"device in use, identifiers from the environment": {
+			script:      "echo \"serial $NDM_DEVICE_SERIAL\"\necho \"more details\"\n",
+			wantState:   usedByPluginInUse,
+			wantMessage: "serial ZA1B2C3D",
+		},
+		"device in use without output": {
+			script:      "exit 0\n",
+			wantState:   usedByPluginInUse,
+			wantMessage: "in use",
+		},
+		"device not in use": {
+			script:    "exit 1\n",
+			wantState: usedByPluginNotInUse,
+		},
+		"plugin fails": {
+			script:    "echo \"unable to query drbd\" >&2\nexit 2\n",
+			wantState: usedByPluginUnknown,
+		},
+		"plugin times out": {
+			script:    "sleep 10\nexit 1\n",
+			timeout:   "100ms",
+			wantState: usedByPluginUnknown,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			plugin := controller.UsedByPluginConfig{
+				Name:    "fake",
+				Command: writeUsedByPlugin(t, test.script),
+				Timeout: test.timeout,
+			}
+			start := time.Now()
+			result := runUsedByPlugin(plugin, bd)
+			assert.Equal(t, test.wantState, result.State)
+			if test.wantMessage != "" {
+				assert.Equal(t, test.wantMessage, result.Message)
+			}
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+	}
+}
+
+func TestRunUsedByPluginMissingCommand(t *testing.T) {
+	plugin := controller.UsedByPluginConfig{
+		Name:    "missing",
+		Command: filepath.Join(t.TempDir(), "missing.sh"),
+	}
+	result := runUsedByPlugin(plugin, blockdevice.BlockDevice{})
+	assert.Equal(t, usedByPluginUnknown, result.State)
+}
+
+func TestGetUsedByPluginTimeout(t *testing.T) {
+	tests := map[string]struct {
+		timeout string
+		want    time.Duration
+	}{
+		"timeout not set": {
+			timeout: "",
+			want:    defaultUsedByPluginTimeout,
+		},
+		"valid timeout": {
+			timeout: "5s",
+			want:    5 * time.Second,
+		},
+		"invalid timeout": {
+			timeout: "five seconds",
+			want:    defaultUsedByPluginTimeout,
+		},
+		"timeout above the max": {
+			timeout: "1h",
+			want:    maxUsedByPluginTimeout,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, getUsedByPluginTimeout(controller.UsedByPluginConfig{Timeout: test.timeout}))
+		})
+	}
+}
+
+func TestHandleUnmanagedDevicesWithUsedByPlugin(t *testing.T) {
+	tests := map[string]struct {
+		script    string
+		want      bool
+		wantTrace string
+	}{
+		"device in use": {
+			script:    "echo \"drbd resource r0\"\nexit 0\n",
+			want:      false,
+			wantTrace: "used-by-plugin:in-use",
+		},
+		"device not in use": {
+			script: "exit 1\n",
+			want:   true,
+		},
+		"plugin fails": {
+			script:    "exit 3\n",
+			want:      false,
+			wantTrace: "used-by-plugin:unknown",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						UsedByPluginConfigs: []controller.UsedByPluginConfig{
+							{
+								Name:    "fake",
+								Command: writeUsedByPlugin(t, test.script),
+							},
+						},
+					},
+					Clientset:   CreateFakeClient(t),
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+				},
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{
+					DevPath: "/dev/sdb",
+				},
+				DecisionTrace: &blockdevice.DecisionTrace{},
+			}
+			ok, err := pe.handleUnmanagedDevices(bd, &apis.BlockDeviceList{})
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, ok)
+			if test.wantTrace != "" {
+				assert.True(t, util.Contains(bd.DecisionTrace.Steps(), test.wantTrace))
+			}
+		})
+	}
+}
+
+func TestDeviceInUseByPluginExistingResource(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		DecisionTrace: &blockdevice.DecisionTrace{},
+	}
+	uuid, _, _ := generateUUID(bd)
+
+	tests := map[string]struct {
+		script     string
+		claimState apis.DeviceClaimState
+		want       bool
+		wantTag    string
+	}{
+		"unclaimed resource of device in use is tagged": {
+			script:     "echo \"drbd resource r0\"\nexit 0\n",
+			claimState: apis.BlockDeviceUnclaimed,
+			want:       false,
+			wantTag:    string(blockdevice.UsedByPlugin),
+		},
+		"claimed resource is processed if the plugin fails": {
+			script:     "exit 3\n",
+			claimState: apis.BlockDeviceClaimed,
+			want:       true,
+		},
+		"claimed resource is processed if the device is in use": {
+			script:     "exit 0\n",
+			claimState: apis.BlockDeviceClaimed,
+			want:       true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cl := CreateFakeClient(t)
+			existingBD := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: uuid,
+				},
+				Spec: apis.DeviceSpec{
+					Path: bd.DevPath,
+				},
+				Status: apis.DeviceStatus{
+					ClaimState: test.claimState,
+					State:      controller.NDMActive,
+				},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), existingBD))
+			bdAPIList := &apis.BlockDeviceList{}
+			assert.NoError(t, cl.List(context.TODO(), bdAPIList))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					NDMConfig: &controller.NodeDiskManagerConfig{
+						UsedByPluginConfigs: []controller.UsedByPluginConfig{
+							{
+								Name:    "fake",
+								Command: writeUsedByPlugin(t, test.script),
+							},
+						},
+					},
+					Clientset:   cl,
+					BDHierarchy: blockdevice.NewHierarchyCache(nil),
+				},
+			}
+			ok, err := pe.deviceInUseByPlugin(bd, bdAPIList)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, ok)
+
+			got := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: uuid}, got))
+			assert.Equal(t, test.wantTag, got.Labels[kubernetes.BlockDeviceTagLabel])
+			if test.wantTag != "" {
+				cached, found := pe.Controller.BDHierarchy.Get(bd.DevPath)
+				assert.True(t, found)
+				assert.Equal(t, blockdevice.UsedByPlugin, cached.DevUse.UsedBy)
+				assert.Equal(t, "fake: drbd resource r0", cached.DevUse.Reason)
+			}
+		})
+	}
+}
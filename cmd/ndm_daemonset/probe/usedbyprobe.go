@@ -17,18 +17,27 @@ limitations under the License.
 package probe
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/blkid"
+	"github.com/openebs/node-disk-manager/pkg/mdraid"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+	"github.com/openebs/node-disk-manager/pkg/refs"
+	"github.com/openebs/node-disk-manager/pkg/smart"
 	"github.com/openebs/node-disk-manager/pkg/spdk"
 	libudevwrapper "github.com/openebs/node-disk-manager/pkg/udev"
 	"github.com/openebs/node-disk-manager/pkg/util"
+	"github.com/openebs/node-disk-manager/pkg/zfs"
 
 	"k8s.io/klog/v2"
 )
@@ -47,11 +56,35 @@ const (
 	zfsFileSystemLabel  = "zfs_member"
 )
 
+// k8sLocalBlockVolumeDevicesPath is the directory in which kubelet maps the raw block
+// local PVs, either as a symlink or a bind mount of the device node. NDM container
+// should have the host directory mounted at the same path for the lookup to work.
+var k8sLocalBlockVolumeDevicesPath = "/var/lib/kubelet/plugins/kubernetes.io~local-volume/volumeDevices"
+
 var (
 	usedbyProbeName  = "used-by probe"
 	usedbyProbeState = defaultEnabled
 )
 
+// parentUsageFreshness is the duration for which the usage of a parent disk in the
+// hierarchy cache is trusted while processing its partitions. The partitions of a disk
+// arrive together, and the parent need not be probed again for each of them.
+const parentUsageFreshness = 10 * time.Second
+
+// deviceUsageClock is the time at which the usage of a device is probed, it is a
+// variable so that it can be replaced in tests
+var deviceUsageClock = time.Now
+
+// hasStorageSpacesPartition and hasReFSSignature are variables, so that they can be
+// replaced in tests
+var (
+	hasStorageSpacesPartition = partition.HasStorageSpacesPartition
+	hasReFSSignature          = refs.HasReFSSignature
+	getSCSIReservation        = smart.GetReservation
+	getMDSuperblock           = mdraid.GetSuperblock
+	getZFSLabel               = zfs.GetLabel
+)
+
 var usedbyProbeRegister = func() {
 	// Get a controller object
 	ctrl := <-controller.ControllerBroadcastChannel
@@ -59,22 +92,17 @@ var usedbyProbeRegister = func() {
 		klog.Error("unable to configure", usedbyProbeName)
 		return
 	}
-	if ctrl.NDMConfig != nil {
-		for _, probeConfig := range ctrl.NDMConfig.ProbeConfigs {
-			if probeConfig.Key == usedbyProbeConfigKey {
-				usedbyProbeName = probeConfig.Name
-				usedbyProbeState = util.CheckTruthy(probeConfig.State)
-				break
-			}
-		}
-	}
 	newRegisterProbe := &registerProbe{
 		priority:   usedbyProbePriority,
 		name:       usedbyProbeName,
 		state:      usedbyProbeState,
+		configKey:  usedbyProbeConfigKey,
+		required:   true,
 		pi:         &usedbyProbe{Controller: ctrl},
 		controller: ctrl,
 	}
+	newRegisterProbe.configure()
+	usedbyProbeName = newRegisterProbe.name
 	// Here we register the used-by probe
 	newRegisterProbe.register()
 }
@@ -91,10 +119,17 @@ func newUsedByProbe(devPath string) *usedbyProbe {
 func (sp *usedbyProbe) Start() {}
 
 func (sp *usedbyProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevice) {
+	sp.FillBlockDeviceDetailsWithContext(context.Background(), blockDevice)
+}
+
+// FillBlockDeviceDetailsWithContext fills the usage of the device. The checks that read
+// the device are not started once the context is done, eg: if the probe timed out.
+func (sp *usedbyProbe) FillBlockDeviceDetailsWithContext(ctx context.Context, blockDevice *blockdevice.BlockDevice) {
 	if blockDevice.DevPath == "" {
 		klog.Errorf("device identifier found empty, used-by probe will not fetch information")
 		return
 	}
+	blockDevice.DevUse.ProbedAt = deviceUsageClock()
 
 	// checking for local PV on the device
 	for _, mountPoint := range blockDevice.FSInfo.MountPoint {
@@ -107,6 +142,55 @@ func (sp *usedbyProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevi
 		}
 	}
 
+	// checking for raw block local PV. The device will not have any filesystem
+	// in this case, and needs to be identified either from the block volume
+	// mapped by kubelet or from the PV referencing the device
+	if blockDevice.FSInfo.FileSystem == "" {
+		if inUse, reason := sp.isDeviceInUseByLocalPVBlock(*blockDevice); inUse {
+			blockDevice.DevUse.InUse = true
+			blockDevice.DevUse.UsedBy = blockdevice.LocalPVBlock
+			blockDevice.DevUse.Reason = reason
+			klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+			return
+		}
+	}
+
+	// devices stamped by the CSI drivers configured by the operator are owned by those
+	// drivers, eg: raw block volumes having a label partition
+	if sp.Controller != nil {
+		if driver, ok := sp.Controller.GetCSIDriverOfDevice(*blockDevice); ok {
+			blockDevice.DevUse.InUse = true
+			blockDevice.DevUse.UsedBy = blockdevice.CSIManaged
+			blockDevice.DevUse.Reason = fmt.Sprintf("csi driver: %s", driver)
+			klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+			return
+		}
+	}
+
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
+	// cStor pools are recognized from the pool name in the zfs label, as the partition
+	// layout and the exclusive open heuristics below can miss them, eg: if the partitions
+	// are not listed in the expected order, or the pool has the device open exclusively
+	if pool, ok := getCStorPoolLabel(*blockDevice); ok {
+		blockDevice.DevUse.InUse = true
+		blockDevice.DevUse.UsedBy = blockdevice.CStor
+		blockDevice.DevUse.Reason = fmt.Sprintf("cstor pool: %s, layout: %s", pool.PoolName, pool.Layout)
+		blockDevice.FSInfo.FileSystem = zfsFileSystemLabel
+		if blockDevice.Labels == nil {
+			blockDevice.Labels = make(map[string]string)
+		}
+		blockDevice.Labels[controller.NDMZpoolName] = pool.PoolName
+		klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+		return
+	}
+
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
 	// checking for cstor and zfs localPV
 	// we start with the assumption that device has a zfs file system
 	lookupZFS := true
@@ -150,6 +234,30 @@ func (sp *usedbyProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevi
 		}
 	}
 
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
+	// blkid reads only the labels at the start of the device, so the members of a faulted
+	// or offline vdev whose first labels cannot be read are found from the other labels.
+	// Reusing such a device would break the recovery of the pool.
+	if pool, ok := getZFSPoolLabel(*blockDevice); ok {
+		blockDevice.DevUse.InUse = true
+		blockDevice.DevUse.UsedBy = blockdevice.ZFSLocalPV
+		blockDevice.DevUse.Reason = fmt.Sprintf("zfs pool: %s, layout: %s", pool.PoolName, pool.Layout)
+		blockDevice.FSInfo.FileSystem = zfsFileSystemLabel
+		if blockDevice.Labels == nil {
+			blockDevice.Labels = make(map[string]string)
+		}
+		blockDevice.Labels[controller.NDMZpoolName] = pool.PoolName
+		klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+		return
+	}
+
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
 	// create a device identifier for reading the spdk super block from the disk
 	spdkIdentifier := &spdk.DeviceIdentifier{
 		DevPath: blockDevice.DevPath,
@@ -166,9 +274,271 @@ func (sp *usedbyProbe) FillBlockDeviceDetails(blockDevice *blockdevice.BlockDevi
 		return
 	}
 
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
+	// disks imported from a Windows host may be members of a Storage Spaces pool or may
+	// have a ReFS filesystem. They are never managed, so that the disks can be
+	// reintroduced to a Windows host.
+	if fsType := getWindowsFileSystem(*blockDevice); fsType != "" {
+		blockDevice.DevUse.InUse = true
+		blockDevice.DevUse.UsedBy = blockdevice.Windows
+		if blockDevice.FSInfo.FileSystem == "" {
+			blockDevice.FSInfo.FileSystem = fsType
+		}
+		klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+		return
+	}
+
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
+	// members of a stopped md array do not have any holders, but partitioning them will
+	// prevent the array from being assembled again. spares never have any holders, but
+	// are used by the array when a member fails
+	if usedBy, reason, ok := getMDRaidMembership(*blockDevice); ok {
+		blockDevice.DevUse.InUse = true
+		blockDevice.DevUse.UsedBy = usedBy
+		blockDevice.DevUse.Reason = reason
+		if blockDevice.FSInfo.FileSystem == "" {
+			blockDevice.FSInfo.FileSystem = blockdevice.FileSystemMDRaidMember
+		}
+		klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+		return
+	}
+
+	if isUsedByProbeStopped(ctx, blockDevice.DevPath) {
+		return
+	}
+
+	// shared disks may have a persistent reservation held by another host, writing to
+	// such disks will corrupt the data of the peer. A reservation held with one of the
+	// keys of this host is not a reservation by another host.
+	if reason, ok := sp.getSCSIReservationHolder(*blockDevice); ok {
+		blockDevice.DevUse.InUse = true
+		blockDevice.DevUse.UsedBy = blockdevice.SCSIReserved
+		blockDevice.DevUse.Reason = reason
+		klog.V(4).Infof("device: %s Used by: %s filled by used-by probe", blockDevice.DevPath, blockDevice.DevUse.UsedBy)
+		return
+	}
+
 	// TODO jiva disk detection
 }
 
+// isUsedByProbeStopped checks if the context of the used-by probe is done, in which case
+// the remaining checks of the device are skipped
+func isUsedByProbeStopped(ctx context.Context, devPath string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	klog.V(4).Infof("used-by probe stopped for device: %s, %v", devPath, ctx.Err())
+	return true
+}
+
+// getSCSIReservationHolder checks if a SCSI-3 persistent reservation is held on the
+// disk by another host and returns the details of the holder. Devices that do not support
+// persistent reservations, like NVMe namespaces, are considered as not reserved. The
+// reservation is read once per batch of events, and compared with the keys of this host.
+// The all registrants reservation types report the key as zero, and are always
+// considered as held by another host.
+func (sp *usedbyProbe) getSCSIReservationHolder(bd blockdevice.BlockDevice) (string, bool) {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk ||
+		strings.HasPrefix(filepath.Base(bd.DevPath), "nvme") {
+		return "", false
+	}
+	var reservation smart.ReservationInfo
+	var err error
+	if sp.Controller != nil {
+		reservation, err = sp.Controller.GetSCSIReservation(bd.DevPath, getSCSIReservation)
+	} else {
+		reservation, err = getSCSIReservation(bd.DevPath)
+	}
+	if err != nil {
+		klog.V(4).Infof("unable to read persistent reservation of device: %s, %v", bd.DevPath, err)
+		return "", false
+	}
+	if !reservation.Reserved {
+		return "", false
+	}
+	if sp.Controller != nil && sp.Controller.IsLocalSCSIReservationKey(reservation.Key) {
+		klog.V(4).Infof("device: %s is reserved by this host, reservation key: 0x%016x",
+			bd.DevPath, reservation.Key)
+		return "", false
+	}
+	return fmt.Sprintf("reservation key: 0x%016x, type: %#x", reservation.Key, reservation.Type), true
+}
+
+// getCStorPoolLabel reads the zfs label of the device and returns it if the device is a
+// member of a cStor pool. For disks, the label is also read from the partitions, as the
+// pool may have been created on the whole disk, which creates the data partition.
+func getCStorPoolLabel(bd blockdevice.BlockDevice) (*zfs.Label, bool) {
+	return findZFSLabel(bd, (*zfs.Label).IsCStorPool)
+}
+
+// getZFSPoolLabel reads the zfs label of the device and returns it if the device is a
+// member of any pool, irrespective of the health of the pool. For disks, the label is
+// also read from the partitions.
+func getZFSPoolLabel(bd blockdevice.BlockDevice) (*zfs.Label, bool) {
+	return findZFSLabel(bd, func(*zfs.Label) bool { return true })
+}
+
+// findZFSLabel reads the zfs label of the device and of the partitions of a disk, and
+// returns the first label that matches
+func findZFSLabel(bd blockdevice.BlockDevice, match func(*zfs.Label) bool) (*zfs.Label, bool) {
+	var paths []string
+	switch bd.DeviceAttributes.DeviceType {
+	case blockdevice.BlockDeviceTypeDisk:
+		paths = append([]string{bd.DevPath}, bd.DependentDevices.Partitions...)
+	case blockdevice.BlockDeviceTypePartition:
+		paths = []string{bd.DevPath}
+	default:
+		return nil, false
+	}
+	for _, path := range paths {
+		label, err := getZFSLabel(path)
+		if err != nil {
+			klog.V(4).Infof("unable to read zfs label from device: %s, %v", path, err)
+			continue
+		}
+		if label != nil && match(label) {
+			return label, true
+		}
+	}
+	return nil, false
+}
+
+// getMDRaidMembership checks if the device has an md superblock and returns the details
+// of the array. The superblock is read from the device, as blkid may not report it for
+// all the superblock versions and the array may not be assembled. A device reserved as
+// a spare is reported as a spare of the array rather than a member.
+func getMDRaidMembership(bd blockdevice.BlockDevice) (blockdevice.StorageEngine, string, bool) {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk &&
+		bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypePartition {
+		return "", "", false
+	}
+	sb, err := getMDSuperblock(bd.DevPath)
+	if err != nil {
+		klog.Errorf("error reading md superblock from device: %s, %v", bd.DevPath, err)
+		return "", "", false
+	}
+	if sb == nil {
+		return "", "", false
+	}
+	reason := fmt.Sprintf("md array uuid: %s, superblock version: %s", sb.ArrayUUID, sb.Version)
+	if sb.Name != "" {
+		reason = fmt.Sprintf("md array: %s, %s", sb.Name, reason)
+	}
+	if sb.Role == mdraid.RoleSpare {
+		return blockdevice.ArraySpare, "spare for " + reason, true
+	}
+	return blockdevice.MDRaidMember, reason, true
+}
+
+// getWindowsFileSystem gets the type of the Windows metadata on the device, which is
+// not detected by blkid. Empty string is returned if no such metadata is present.
+func getWindowsFileSystem(bd blockdevice.BlockDevice) string {
+	switch bd.DeviceAttributes.DeviceType {
+	case blockdevice.BlockDeviceTypePartition:
+		if blockdevice.IsStorageSpacesPartitionType(bd.PartitionInfo.PartitionType) {
+			return blockdevice.FileSystemStorageSpaces
+		}
+	case blockdevice.BlockDeviceTypeDisk:
+		// the partition table is read, as the kernel may not have created the device
+		// nodes for the partitions
+		ok, err := hasStorageSpacesPartition(bd.DevPath)
+		if err != nil {
+			klog.Errorf("error reading partition table of device: %s, %v", bd.DevPath, err)
+		}
+		if ok {
+			return blockdevice.FileSystemStorageSpaces
+		}
+	}
+
+	// blkid does not detect ReFS, so the signature is read only if no other
+	// filesystem was found on the device
+	if bd.FSInfo.FileSystem != "" {
+		return ""
+	}
+	ok, err := hasReFSSignature(bd.DevPath)
+	if err != nil {
+		klog.Errorf("error reading ReFS signature from device: %s, %v", bd.DevPath, err)
+	}
+	if ok {
+		return blockdevice.FileSystemReFS
+	}
+	return ""
+}
+
+// isDeviceInUseByLocalPVBlock checks if the device is used as a raw block local PV. The
+// device is considered in use, if kubelet has mapped the device for a local block volume
+// or if a local PV with volumeMode Block references the device on this node. As the
+// check guards the destructive operations, the device is considered in use if the PVs
+// cannot be looked up, with the reason having the error. It is checked again on the
+// next event.
+func (sp *usedbyProbe) isDeviceInUseByLocalPVBlock(bd blockdevice.BlockDevice) (bool, string) {
+	ok, err := isDeviceMappedAsBlockVolume(bd.DevPath, k8sLocalBlockVolumeDevicesPath)
+	if err != nil {
+		klog.Errorf("error checking block volume mapping of device: %s, %v", bd.DevPath, err)
+	}
+	if ok {
+		return true, ""
+	}
+
+	if sp.Controller == nil || sp.Controller.Clientset == nil {
+		return false, ""
+	}
+	devPaths := []string{bd.DevPath}
+	for _, devLink := range bd.DevLinks {
+		devPaths = append(devPaths, devLink.Links...)
+	}
+	pvName, err := sp.Controller.GetLocalBlockPVForDevice(devPaths)
+	if err != nil {
+		klog.Errorf("error looking up local block PV for device: %s, treating it as in use, %v", bd.DevPath, err)
+		return true, fmt.Sprintf("unable to look up local block PVs: %v", err)
+	}
+	if pvName != "" {
+		klog.V(4).Infof("device: %s is referenced by local block PV: %s", bd.DevPath, pvName)
+		return true, fmt.Sprintf("local block PV: %s", pvName)
+	}
+	return false, ""
+}
+
+// isDeviceMappedAsBlockVolume walks the kubelet block volume directory and checks
+// if any of the entries (symlink or bind mounted device node) is the given device.
+func isDeviceMappedAsBlockVolume(devPath, volumeDevicesPath string) (bool, error) {
+	devInfo, err := os.Stat(devPath)
+	if err != nil {
+		return false, err
+	}
+	errFound := errors.New("device found")
+	err = filepath.WalkDir(volumeDevicesPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			// dangling links of volumes that are no longer mapped
+			return nil
+		}
+		if os.SameFile(devInfo, info) {
+			return errFound
+		}
+		return nil
+	})
+	if err == errFound {
+		return true, nil
+	}
+	return false, err
+}
+
 // getBlockDeviceZFSPartition is used to get the zfs partition if it exist in a
 // given BD
 func getBlockDeviceZFSPartition(bd blockdevice.BlockDevice) (string, bool) {
@@ -17,9 +17,20 @@ limitations under the License.
 package probe
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/mdraid"
+	"github.com/openebs/node-disk-manager/pkg/smart"
+	"github.com/openebs/node-disk-manager/pkg/util"
+	"github.com/openebs/node-disk-manager/pkg/zfs"
 	"github.com/stretchr/testify/assert"
-	"testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestGetBlockDeviceZFSPartition(t *testing.T) {
@@ -109,3 +120,606 @@ func TestGetBlockDeviceZFSPartition(t *testing.T) {
 		})
 	}
 }
+
+// mockZFSLabels replaces the reading of the zfs label with the given labels, returning
+// the function to restore it
+func mockZFSLabels(labels map[string]*zfs.Label) func() {
+	oldGetZFSLabel := getZFSLabel
+	getZFSLabel = func(devPath string) (*zfs.Label, error) {
+		if devPath == "/dev/sdz" {
+			return nil, errors.New("input/output error")
+		}
+		return labels[devPath], nil
+	}
+	return func() { getZFSLabel = oldGetZFSLabel }
+}
+
+func TestGetCStorPoolLabel(t *testing.T) {
+	mirrorPool := &zfs.Label{PoolName: "cstor-5e3f2a1b-6c4d-4e8f-9a0b-1c2d3e4f5a6b", PoolGUID: 1, Layout: zfs.LayoutMirror}
+	stripePool := &zfs.Label{PoolName: "cstor-7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d", PoolGUID: 2, Layout: zfs.LayoutStripe}
+	zfsLocalPVPool := &zfs.Label{PoolName: "zfspv-pool", PoolGUID: 3, Layout: zfs.LayoutStripe}
+	defer mockZFSLabels(map[string]*zfs.Label{
+		"/dev/sdb1":      mirrorPool,
+		"/dev/sdc":       stripePool,
+		"/dev/sdd1":      zfsLocalPVPool,
+		"/dev/nvme0n1":   nil,
+		"/dev/nvme1n1":   nil,
+		"/dev/nvme1n1p1": stripePool,
+	})()
+
+	tests := map[string]struct {
+		bd        blockdevice.BlockDevice
+		wantLabel *zfs.Label
+		wantOk    bool
+	}{
+		"disk having the pool partitions in an unexpected order": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdb"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Partitions: []string{"/dev/sdb9", "/dev/sdb1"},
+				},
+			},
+			wantLabel: mirrorPool,
+			wantOk:    true,
+		},
+		"partition of a mirrored pool": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdb1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+			},
+			wantLabel: mirrorPool,
+			wantOk:    true,
+		},
+		"whole disk member of a striped pool": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdc"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantLabel: stripePool,
+			wantOk:    true,
+		},
+		"nvme disk having only the data partition listed": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/nvme1n1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Partitions: []string{"/dev/nvme1n1p1"},
+				},
+			},
+			wantLabel: stripePool,
+			wantOk:    true,
+		},
+		"disk of a zfs localpv pool": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdd"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+				DependentDevices: blockdevice.DependentBlockDevices{
+					Partitions: []string{"/dev/sdd1", "/dev/sdd9"},
+				},
+			},
+			wantLabel: nil,
+			wantOk:    false,
+		},
+		"disk without zfs label": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/nvme0n1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantLabel: nil,
+			wantOk:    false,
+		},
+		"error reading zfs label": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdz"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantLabel: nil,
+			wantOk:    false,
+		},
+		"lvm device": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdc"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeLVM},
+			},
+			wantLabel: nil,
+			wantOk:    false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			label, ok := getCStorPoolLabel(tt.bd)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantLabel, label)
+		})
+	}
+}
+
+func TestUsedByProbeCStorPoolLabel(t *testing.T) {
+	defer mockZFSLabels(map[string]*zfs.Label{
+		"/dev/sdb1": {PoolName: "cstor-5e3f2a1b-6c4d-4e8f-9a0b-1c2d3e4f5a6b", PoolGUID: 1, Layout: zfs.LayoutMirror},
+	})()
+
+	// the partitions are not in the order expected by the zfs partition heuristic
+	bd := &blockdevice.BlockDevice{
+		Identifier:       blockdevice.Identifier{DevPath: "/dev/sdb"},
+		DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+		DependentDevices: blockdevice.DependentBlockDevices{
+			Partitions: []string{"/dev/sdb9", "/dev/sdb1"},
+		},
+	}
+	sp := &usedbyProbe{}
+	sp.FillBlockDeviceDetails(bd)
+
+	assert.True(t, bd.DevUse.InUse)
+	assert.Equal(t, blockdevice.CStor, bd.DevUse.UsedBy)
+	assert.Equal(t, "cstor pool: cstor-5e3f2a1b-6c4d-4e8f-9a0b-1c2d3e4f5a6b, layout: mirror", bd.DevUse.Reason)
+	assert.Equal(t, zfsFileSystemLabel, bd.FSInfo.FileSystem)
+	assert.Equal(t, "cstor-5e3f2a1b-6c4d-4e8f-9a0b-1c2d3e4f5a6b", bd.Labels[controller.NDMZpoolName])
+}
+
+func TestUsedByProbeZFSPoolLabel(t *testing.T) {
+	// the member of a faulted vdev, whose labels at the start cannot be read by blkid
+	defer mockZFSLabels(map[string]*zfs.Label{
+		"/dev/sdd1": {PoolName: "zfspv-pool", PoolGUID: 3, Layout: zfs.LayoutMirror},
+	})()
+
+	bd := &blockdevice.BlockDevice{
+		Identifier:       blockdevice.Identifier{DevPath: "/dev/sdd1"},
+		DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+	}
+	sp := &usedbyProbe{}
+	sp.FillBlockDeviceDetails(bd)
+
+	assert.True(t, bd.DevUse.InUse)
+	assert.Equal(t, blockdevice.ZFSLocalPV, bd.DevUse.UsedBy)
+	assert.Equal(t, "zfs pool: zfspv-pool, layout: mirror", bd.DevUse.Reason)
+	assert.Equal(t, zfsFileSystemLabel, bd.FSInfo.FileSystem)
+	assert.Equal(t, "zfspv-pool", bd.Labels[controller.NDMZpoolName])
+}
+
+func TestUsedByProbeCSIDriver(t *testing.T) {
+	sp := &usedbyProbe{
+		Controller: &controller.Controller{
+			NDMConfig: &controller.NodeDiskManagerConfig{
+				CSIDriverConfigs: []controller.CSIDriverConfig{
+					{Name: "raw.csi.example.com", FilesystemLabels: []string{"csi-raw-*"}},
+				},
+			},
+		},
+	}
+	tests := map[string]struct {
+		label      string
+		wantInUse  bool
+		wantReason string
+	}{
+		"label matching the pattern of a csi driver": {
+			label:      "csi-raw-pvc-0b1c2d3e",
+			wantInUse:  true,
+			wantReason: "csi driver: raw.csi.example.com",
+		},
+		"label not matching the pattern": {
+			label:     "data",
+			wantInUse: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := &blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdc1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+				FSInfo:           blockdevice.FileSystemInformation{FileSystemLabel: tt.label},
+			}
+			sp.FillBlockDeviceDetails(bd)
+			assert.Equal(t, tt.wantInUse, bd.DevUse.InUse)
+			if tt.wantInUse {
+				assert.Equal(t, blockdevice.CSIManaged, bd.DevUse.UsedBy)
+				assert.Equal(t, tt.wantReason, bd.DevUse.Reason)
+			}
+		})
+	}
+}
+
+func TestGetMDRaidMembership(t *testing.T) {
+	superblocks := map[string]*mdraid.Superblock{
+		"/dev/sdb": {
+			Version:   mdraid.Version12,
+			ArrayUUID: "3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617",
+			Name:      "node1:md0",
+			Role:      mdraid.RoleActive,
+		},
+		"/dev/sdc1": {
+			Version:   mdraid.Version090,
+			ArrayUUID: "5d1f3a2b:8c7e6f5d:4b3a2918:07f6e5d4",
+			Role:      mdraid.RoleActive,
+		},
+		"/dev/sde": {
+			Version:   mdraid.Version12,
+			ArrayUUID: "3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617",
+			Name:      "node1:md0",
+			Role:      mdraid.RoleSpare,
+		},
+		"/dev/sdf": {
+			Version:   mdraid.Version12,
+			ArrayUUID: "3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617",
+			Name:      "node1:md0",
+			Role:      mdraid.RoleFaulty,
+		},
+	}
+	oldGetMDSuperblock := getMDSuperblock
+	getMDSuperblock = func(devPath string) (*mdraid.Superblock, error) {
+		if devPath == "/dev/sdz" {
+			return nil, errors.New("input/output error")
+		}
+		return superblocks[devPath], nil
+	}
+	defer func() { getMDSuperblock = oldGetMDSuperblock }()
+
+	tests := map[string]struct {
+		bd         blockdevice.BlockDevice
+		wantUsedBy blockdevice.StorageEngine
+		wantReason string
+		wantMember bool
+	}{
+		"disk with a version 1.2 superblock": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdb"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantUsedBy: blockdevice.MDRaidMember,
+			wantReason: "md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+			wantMember: true,
+		},
+		"partition with a version 0.90 superblock": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdc1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypePartition},
+			},
+			wantUsedBy: blockdevice.MDRaidMember,
+			wantReason: "md array uuid: 5d1f3a2b:8c7e6f5d:4b3a2918:07f6e5d4, superblock version: 0.90",
+			wantMember: true,
+		},
+		"disk reserved as a spare of the array": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sde"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantUsedBy: blockdevice.ArraySpare,
+			wantReason: "spare for md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+			wantMember: true,
+		},
+		"faulty disk of the array": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdf"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantUsedBy: blockdevice.MDRaidMember,
+			wantReason: "md array: node1:md0, md array uuid: 3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617, superblock version: 1.2",
+			wantMember: true,
+		},
+		"md array device": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdb"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: "raid1"},
+			},
+			wantMember: false,
+		},
+		"disk without md superblock": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdd"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantMember: false,
+		},
+		"error reading superblock": {
+			bd: blockdevice.BlockDevice{
+				Identifier:       blockdevice.Identifier{DevPath: "/dev/sdz"},
+				DeviceAttributes: blockdevice.DeviceAttribute{DeviceType: blockdevice.BlockDeviceTypeDisk},
+			},
+			wantMember: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			usedBy, reason, ok := getMDRaidMembership(tt.bd)
+			assert.Equal(t, tt.wantMember, ok)
+			assert.Equal(t, tt.wantUsedBy, usedBy)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestGetWindowsFileSystem(t *testing.T) {
+	storageSpacesDisks := []string{"/dev/sdb"}
+	refsDevices := []string{"/dev/sdc", "/dev/sdd1"}
+	oldHasStorageSpacesPartition, oldHasReFSSignature := hasStorageSpacesPartition, hasReFSSignature
+	hasStorageSpacesPartition = func(devPath string) (bool, error) {
+		return util.Contains(storageSpacesDisks, devPath), nil
+	}
+	hasReFSSignature = func(devPath string) (bool, error) {
+		return util.Contains(refsDevices, devPath), nil
+	}
+	defer func() {
+		hasStorageSpacesPartition, hasReFSSignature = oldHasStorageSpacesPartition, oldHasReFSSignature
+	}()
+
+	tests := map[string]struct {
+		bd   blockdevice.BlockDevice
+		want string
+	}{
+		"disk in a Storage Spaces pool": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdb"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			want: blockdevice.FileSystemStorageSpaces,
+		},
+		"Storage Spaces protective partition": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdb2"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+				PartitionInfo: blockdevice.PartitionInformation{
+					PartitionType: "E75CAF8F-F680-4CEE-AFA3-B001E56EFC2D",
+				},
+			},
+			want: blockdevice.FileSystemStorageSpaces,
+		},
+		"disk with ReFS": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdc"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			want: blockdevice.FileSystemReFS,
+		},
+		"partition with ReFS": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdd1"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypePartition,
+				},
+				PartitionInfo: blockdevice.PartitionInformation{
+					PartitionType: "ebd0a0a2-b9e5-4433-87c0-68b6b72699c7",
+				},
+			},
+			want: blockdevice.FileSystemReFS,
+		},
+		"device with a filesystem detected by blkid": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdc"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+				FSInfo: blockdevice.FileSystemInformation{
+					FileSystem: "ext4",
+				},
+			},
+			want: "",
+		},
+		"disk without windows metadata": {
+			bd: blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sde"},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: blockdevice.BlockDeviceTypeDisk,
+				},
+			},
+			want: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getWindowsFileSystem(tt.bd))
+		})
+	}
+}
+
+func TestGetSCSIReservationHolder(t *testing.T) {
+	oldGetSCSIReservation := getSCSIReservation
+	getSCSIReservation = func(devPath string) (smart.ReservationInfo, error) {
+		switch devPath {
+		case "/dev/sdb":
+			return smart.ReservationInfo{Reserved: true, Key: 0xabcd1234, Type: 0x3}, nil
+		case "/dev/sdc":
+			return smart.ReservationInfo{}, errors.New("persistent reservations not supported")
+		case "/dev/sde":
+			return smart.ReservationInfo{Reserved: true, Key: 0x1a2b3c4d, Type: 0x5}, nil
+		}
+		return smart.ReservationInfo{}, nil
+	}
+	defer func() {
+		getSCSIReservation = oldGetSCSIReservation
+	}()
+
+	tests := map[string]struct {
+		devPath    string
+		deviceType string
+		wantReason string
+		wantOk     bool
+	}{
+		"disk reserved by another host": {
+			devPath:    "/dev/sdb",
+			deviceType: blockdevice.BlockDeviceTypeDisk,
+			wantReason: "reservation key: 0x00000000abcd1234, type: 0x3",
+			wantOk:     true,
+		},
+		"disk reserved by this host": {
+			devPath:    "/dev/sde",
+			deviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+		"disk not supporting persistent reservations": {
+			devPath:    "/dev/sdc",
+			deviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+		"disk without a reservation": {
+			devPath:    "/dev/sdd",
+			deviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+		"partition is not queried": {
+			devPath:    "/dev/sdb",
+			deviceType: blockdevice.BlockDeviceTypePartition,
+		},
+		"nvme namespace is not queried": {
+			devPath:    "/dev/nvme0n1",
+			deviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: tt.devPath},
+				DeviceAttributes: blockdevice.DeviceAttribute{
+					DeviceType: tt.deviceType,
+				},
+			}
+			sp := &usedbyProbe{
+				Controller: &controller.Controller{SCSIReservationKeys: []uint64{0x1a2b3c4d}},
+			}
+			reason, ok := sp.getSCSIReservationHolder(bd)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestGetSCSIReservationHolderOncePerBatch(t *testing.T) {
+	reads := 0
+	oldGetSCSIReservation := getSCSIReservation
+	getSCSIReservation = func(devPath string) (smart.ReservationInfo, error) {
+		reads++
+		return smart.ReservationInfo{Reserved: true, Key: 0xabcd1234, Type: 0x3}, nil
+	}
+	defer func() {
+		getSCSIReservation = oldGetSCSIReservation
+	}()
+
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{DevPath: "/dev/sdb"},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+		},
+	}
+	sp := &usedbyProbe{Controller: &controller.Controller{}}
+	for i := 0; i < 3; i++ {
+		_, ok := sp.getSCSIReservationHolder(bd)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, 1, reads)
+
+	// the reservation is read again in the next batch
+	sp.Controller.ResetSCSIReservations()
+	_, ok := sp.getSCSIReservationHolder(bd)
+	assert.True(t, ok)
+	assert.Equal(t, 2, reads)
+}
+
+func TestIsDeviceMappedAsBlockVolume(t *testing.T) {
+	tmpDir := t.TempDir()
+	devPath := filepath.Join(tmpDir, "sdb")
+	otherDevPath := filepath.Join(tmpDir, "sdc")
+	for _, f := range []string{devPath, otherDevPath} {
+		if err := os.WriteFile(f, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// kubelet maps the device for each pod using the PV
+	volumeDevicesPath := filepath.Join(tmpDir, "volumeDevices")
+	pvDir := filepath.Join(volumeDevicesPath, "local-pv-1")
+	if err := os.MkdirAll(pvDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(devPath, filepath.Join(pvDir, "fake-pod-uid")); err != nil {
+		t.Fatal(err)
+	}
+	// dangling link of an earlier mapping
+	if err := os.Symlink(filepath.Join(tmpDir, "sdx"), filepath.Join(pvDir, "old-pod-uid")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		devPath           string
+		volumeDevicesPath string
+		want              bool
+		wantErr           bool
+	}{
+		"device mapped as block volume": {
+			devPath:           devPath,
+			volumeDevicesPath: volumeDevicesPath,
+			want:              true,
+		},
+		"device not mapped as block volume": {
+			devPath:           otherDevPath,
+			volumeDevicesPath: volumeDevicesPath,
+			want:              false,
+		},
+		"volume devices directory does not exist": {
+			devPath:           devPath,
+			volumeDevicesPath: filepath.Join(tmpDir, "non-existent"),
+			want:              false,
+		},
+		"device does not exist": {
+			devPath:           filepath.Join(tmpDir, "sdy"),
+			volumeDevicesPath: volumeDevicesPath,
+			want:              false,
+			wantErr:           true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := isDeviceMappedAsBlockVolume(tt.devPath, tt.volumeDevicesPath)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// listFailingClient fails all the list calls, like when the apiserver is unreachable
+type listFailingClient struct {
+	client.Client
+}
+
+func (c *listFailingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return errors.New("connection refused")
+}
+
+func TestIsDeviceInUseByLocalPVBlock(t *testing.T) {
+	oldVolumeDevicesPath := k8sLocalBlockVolumeDevicesPath
+	k8sLocalBlockVolumeDevicesPath = filepath.Join(t.TempDir(), "volumeDevices")
+	defer func() { k8sLocalBlockVolumeDevicesPath = oldVolumeDevicesPath }()
+
+	tests := map[string]struct {
+		client     client.Client
+		wantInUse  bool
+		wantReason string
+	}{
+		"device not referenced by a local block PV": {
+			client:    CreateFakeClient(t),
+			wantInUse: false,
+		},
+		"local block PVs cannot be listed": {
+			client:     &listFailingClient{Client: CreateFakeClient(t)},
+			wantInUse:  true,
+			wantReason: "unable to look up local block PVs: connection refused",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sp := &usedbyProbe{
+				Controller: &controller.Controller{
+					Clientset: tt.client,
+				},
+			}
+			bd := blockdevice.BlockDevice{
+				Identifier: blockdevice.Identifier{DevPath: "/dev/sdb"},
+			}
+			inUse, reason := sp.isDeviceInUseByLocalPVBlock(bd)
+			assert.Equal(t, tt.wantInUse, inUse)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
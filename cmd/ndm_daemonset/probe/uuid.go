@@ -17,20 +17,186 @@ limitations under the License.
 package probe
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
+	"strconv"
+	"strings"
 
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/features"
 	"github.com/openebs/node-disk-manager/pkg/util"
 
+	"github.com/google/uuid"
 	"k8s.io/klog/v2"
 )
 
+const (
+	// internalUUIDVersionAnnotation is the annotation having the version of the algorithm
+	// used for hashing the identifier of the device into the UUID. Resources without the
+	// annotation use v1.
+	internalUUIDVersionAnnotation = "internal.openebs.io/uuid-version"
+
+	// internalUUIDNamespaceAnnotation is the annotation having the namespace in which the
+	// v5 UUID of the device was generated
+	internalUUIDNamespaceAnnotation = "internal.openebs.io/uuid-namespace"
+
+	uuidBasisLoop               = "loop"
+	uuidBasisDMUUID             = "dm-uuid"
+	uuidBasisPartitionUUID      = "partition-uuid"
+	uuidBasisWWN                = "wwn"
+	uuidBasisWWNComposite       = "wwn-composite"
+	uuidBasisFileSystemUUID     = "filesystem-uuid"
+	uuidBasisPartitionTableUUID = "partition-table-uuid"
+	uuidBasisVirtioBusAddress   = "virtio-bus-address"
+)
+
+// uuidHashFuncs are the hash functions of each version of the uuid algorithm
+var uuidHashFuncs = map[controller.UUIDVersion]func(string) string{
+	controller.UUIDVersionV1: util.Hash,
+	controller.UUIDVersionV2: hashV2,
+	controller.UUIDVersionV3: hashV2,
+}
+
+// hashV2 returns the first 40 hex characters of the sha256 hash of the string
+func hashV2(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:40]
+}
+
 // generateUUID creates a new UUID based on the algorithm proposed in
-// https://github.com/openebs/openebs/pull/2666
-func generateUUID(bd blockdevice.BlockDevice) (string, bool) {
+// https://github.com/openebs/openebs/pull/2666, using the v1 hash.
+// The identifier of the device chosen for generating the UUID is returned as the basis.
+func generateUUID(bd blockdevice.BlockDevice) (string, string, bool) {
+	return generateUUIDWithVersion(bd, controller.UUIDVersionV1)
+}
+
+// generateUUIDWithVersion creates a new UUID by hashing the identifier of the device with
+// the hash function of the given version of the uuid algorithm.
+func generateUUIDWithVersion(bd blockdevice.BlockDevice, version controller.UUIDVersion) (string, string, bool) {
+	uuidField, basis, ok := getUUIDField(bd)
+	if !ok {
+		return "", "", false
+	}
+	if version == controller.UUIDVersionV3 && basis == uuidBasisWWN {
+		uuidField, basis = getCompositeUUIDField(bd), uuidBasisWWNComposite
+	}
+	hash, ok := uuidHashFuncs[version]
+	if !ok {
+		klog.Errorf("unknown uuid version: %s for device: %s", version, bd.DevPath)
+		return "", "", false
+	}
+	uuid := controller.SanitizeUUID(bd.DevPath, blockdevice.BlockDevicePrefix+hash(uuidField))
+	klog.Infof("generated uuid: %s for device: %s", uuid, bd.DevPath)
+	return uuid, basis, true
+}
+
+// generateV5UUID creates a new UUID as the RFC 4122 version 5 UUID of the identifier of
+// the device in the given namespace.
+func generateV5UUID(bd blockdevice.BlockDevice, namespace uuid.UUID) (string, string, bool) {
+	uuidField, basis, ok := getUUIDField(bd)
+	if !ok {
+		return "", "", false
+	}
+	id := controller.SanitizeUUID(bd.DevPath,
+		blockdevice.BlockDevicePrefix+uuid.NewSHA1(namespace, []byte(uuidField)).String())
+	klog.Infof("generated uuid: %s in namespace: %s for device: %s", id, namespace, bd.DevPath)
+	return id, basis, true
+}
+
+// uuidVersions are the versions of the uuid algorithm that can be used by this daemon.
+// v5 can be used only if a uuid namespace is configured.
+func (pe *ProbeEvent) uuidVersions() []controller.UUIDVersion {
+	if pe.Controller.UUIDNamespace == uuid.Nil {
+		return controller.UUIDVersions
+	}
+	versions := make([]controller.UUIDVersion, 0, len(controller.UUIDVersions)+1)
+	versions = append(versions, controller.UUIDVersions...)
+	return append(versions, controller.UUIDVersionV5)
+}
+
+// uuidBasisAnnotation gets the key of the annotation having the identifier of the device
+// from which its uuid was generated
+func (pe *ProbeEvent) uuidBasisAnnotation() string {
+	if pe.Controller.UUIDBasisAnnotation == "" {
+		return controller.DefaultUUIDBasisAnnotation
+	}
+	return pe.Controller.UUIDBasisAnnotation
+}
+
+// getStoredUUIDBasis gets the identifier from which the uuid of the resource was
+// generated, falling back to the default annotation for the resources annotated before
+// the annotation was configured
+func (pe *ProbeEvent) getStoredUUIDBasis(bdAPI apis.BlockDevice) (string, bool) {
+	if basis, ok := bdAPI.Annotations[pe.uuidBasisAnnotation()]; ok {
+		return basis, true
+	}
+	basis, ok := bdAPI.Annotations[controller.DefaultUUIDBasisAnnotation]
+	return basis, ok
+}
+
+// generateUUIDOfVersion creates a new UUID of the device with the given version of the uuid
+// algorithm, using the configured uuid namespace for v5.
+func (pe *ProbeEvent) generateUUIDOfVersion(bd blockdevice.BlockDevice, version controller.UUIDVersion) (string, string, bool) {
+	if version != controller.UUIDVersionV5 {
+		return generateUUIDWithVersion(bd, version)
+	}
+	if pe.Controller.UUIDNamespace == uuid.Nil {
+		klog.Errorf("uuid version: %s requires a uuid namespace, device: %s", version, bd.DevPath)
+		return "", "", false
+	}
+	return generateV5UUID(bd, pe.Controller.UUIDNamespace)
+}
+
+// generateDeviceUUID creates the UUID of the device using the uuid version configured
+// for new devices. If the device already has a resource with a UUID of another version,
+// that UUID is used, so that changing the version does not change the resource of any
+// existing device. The version of the returned UUID is also returned.
+func (pe *ProbeEvent) generateDeviceUUID(bd blockdevice.BlockDevice,
+	bdAPIList *apis.BlockDeviceList) (string, string, controller.UUIDVersion, bool) {
+	configuredVersion := pe.Controller.UUIDVersion
+	if configuredVersion == "" {
+		configuredVersion = controller.DefaultUUIDVersion
+	}
+	for _, version := range pe.uuidVersions() {
+		if version == configuredVersion {
+			continue
+		}
+		uuid, basis, ok := pe.generateUUIDOfVersion(bd, version)
+		if !ok {
+			return "", "", "", false
+		}
+		if pe.Controller.GetExistingBlockDeviceResource(bdAPIList, uuid) != nil {
+			klog.V(4).Infof("device: %s has a resource with uuid version: %s", bd.DevPath, version)
+			return uuid, basis, version, true
+		}
+	}
+	uuid, basis, ok := pe.generateUUIDOfVersion(bd, configuredVersion)
+	return uuid, basis, configuredVersion, ok
+}
+
+// generateUUIDsOfAllVersions creates the UUIDs of the device with all the versions of the
+// uuid algorithm, for looking up the resource of a device irrespective of its version.
+func (pe *ProbeEvent) generateUUIDsOfAllVersions(bd blockdevice.BlockDevice) ([]string, bool) {
+	versions := pe.uuidVersions()
+	uuids := make([]string, 0, len(versions))
+	for _, version := range versions {
+		uuid, _, ok := pe.generateUUIDOfVersion(bd, version)
+		if !ok {
+			return nil, false
+		}
+		uuids = append(uuids, uuid)
+	}
+	return uuids, true
+}
+
+// getUUIDField selects the identifier of the device that is hashed to generate the UUID.
+// The kind of the identifier is returned as the basis.
+func getUUIDField(bd blockdevice.BlockDevice) (string, string, bool) {
 	var ok bool
-	var uuidField, uuid string
+	var uuidField, basis string
 
 	// select the field which is to be used for generating UUID
 	//
@@ -59,12 +225,14 @@ func generateUUID(bd blockdevice.BlockDevice) (string, bool) {
 		hostName, _ := os.Hostname()
 		klog.Infof("device(%s) is a loop device, using node name: %s and path: %s", bd.DevPath, hostName, bd.DevPath)
 		uuidField = hostName + bd.DevPath
+		basis = uuidBasisLoop
 		ok = true
 	case util.Contains(blockdevice.DeviceMapperDeviceTypes, bd.DeviceAttributes.DeviceType):
 		// if a DM device, use the DM uuid
 		klog.Infof("device(%s) is a dm device, using DM UUID: %s", bd.DevPath, bd.DMInfo.DMUUID)
 		// TODO add a check if DM uuid is present, else may need to add mitigation steps
 		uuidField = bd.DMInfo.DMUUID
+		basis = uuidBasisDMUUID
 		ok = true
 	case bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypePartition:
 		// The partition entry UUID is used when a partition (/dev/sda1) is processed. The partition UUID should be used
@@ -72,6 +240,7 @@ func generateUUID(bd blockdevice.BlockDevice) (string, bool) {
 		// UUID, but each partition will have a different UUID.
 		klog.Infof("device(%s) is a partition, using partition UUID: %s", bd.DevPath, bd.PartitionInfo.PartitionEntryUUID)
 		uuidField = bd.PartitionInfo.PartitionEntryUUID
+		basis = uuidBasisPartitionUUID
 		ok = true
 	case len(bd.DeviceAttributes.WWN) > 0:
 		// if device has WWN, both WWN and Serial will be used for UUID generation.
@@ -80,10 +249,22 @@ func generateUUID(bd blockdevice.BlockDevice) (string, bool) {
 			bd.DeviceAttributes.WWN, bd.DeviceAttributes.Serial)
 		uuidField = bd.DeviceAttributes.WWN +
 			bd.DeviceAttributes.Serial
+		basis = uuidBasisWWN
+		ok = true
+	case isVirtioDiskWithoutSerial(bd):
+		// the PCI address at which the disk is attached is stable across reboots of the VM,
+		// it is used along with the size and the node name. It is checked before the
+		// filesystem uuid, so that the uuid does not change once the disk is formatted.
+		hostName, _ := os.Hostname()
+		klog.Infof("device(%s) is a virtio disk without serial, using node name: %s, bus address: %s and size: %d",
+			bd.DevPath, hostName, bd.DeviceAttributes.VirtioBusAddress, bd.Capacity.Storage)
+		uuidField = getVirtioUUIDField(hostName, bd)
+		basis = uuidBasisVirtioBusAddress
 		ok = true
 	case len(bd.FSInfo.FileSystemUUID) > 0:
 		klog.Infof("device(%s) has a filesystem, using filesystem UUID: %s", bd.DevPath, bd.FSInfo.FileSystemUUID)
 		uuidField = bd.FSInfo.FileSystemUUID
+		basis = uuidBasisFileSystemUUID
 		ok = true
 	case features.FeatureGates.IsEnabled(features.PartitionTableUUID) && len(bd.PartitionInfo.PartitionTableType) > 0:
 		if len(bd.PartitionInfo.PartitionTableUUID) == 0 {
@@ -93,15 +274,44 @@ func generateUUID(bd blockdevice.BlockDevice) (string, bool) {
 
 		klog.Infof("device(%s) has a partition table, use partition table uuid: %s", bd.DevPath, bd.PartitionInfo.PartitionTableUUID)
 		uuidField = bd.PartitionInfo.PartitionTableUUID
+		basis = uuidBasisPartitionTableUUID
 		ok = true
 	}
 
-	if ok {
-		uuid = blockdevice.BlockDevicePrefix + util.Hash(uuidField)
-		klog.Infof("generated uuid: %s for device: %s", uuid, bd.DevPath)
-	}
+	return uuidField, basis, ok
+}
+
+// getCompositeUUIDField gets the composite identifier of a disk with a WWN, having its WWN,
+// serial and vendor. The capacity is not part of the identifier, as it changes when a LUN
+// is expanded online. The identifiers are separated, so that different values do not
+// concatenate to the same identifier.
+func getCompositeUUIDField(bd blockdevice.BlockDevice) string {
+	return strings.Join([]string{
+		bd.DeviceAttributes.WWN,
+		bd.DeviceAttributes.Serial,
+		bd.DeviceAttributes.Vendor,
+	}, "|")
+}
+
+// isVirtioDiskWithoutSerial checks if the disk is a virtio disk without a serial, which
+// is identified by its bus address if the VirtioBusAddressUUID feature is enabled
+func isVirtioDiskWithoutSerial(bd blockdevice.BlockDevice) bool {
+	return features.FeatureGates.IsEnabled(features.VirtioBusAddressUUID) &&
+		bd.DeviceAttributes.DeviceType == blockdevice.BlockDeviceTypeDisk &&
+		bd.DeviceAttributes.Transport == blockdevice.TransportVirtio &&
+		len(bd.DeviceAttributes.Serial) == 0 &&
+		len(bd.DeviceAttributes.VirtioBusAddress) > 0
+}
 
-	return uuid, ok
+// getVirtioUUIDField gets the identifier of a virtio disk having the node name, its bus
+// address and its size. The identifiers are separated, so that different values do
+// not concatenate to the same identifier.
+func getVirtioUUIDField(hostName string, bd blockdevice.BlockDevice) string {
+	return strings.Join([]string{
+		hostName,
+		bd.DeviceAttributes.VirtioBusAddress,
+		strconv.FormatUint(bd.Capacity.Storage, 10),
+	}, "|")
 }
 
 // generate old UUID, returns true if the UUID has used path or hostname for generation.
@@ -121,7 +331,7 @@ func generateLegacyUUID(bd blockdevice.BlockDevice) (string, bool) {
 		uid += host + bd.DevPath
 		uuidUsesPath = true
 	}
-	uuid := blockdevice.BlockDevicePrefix + util.Hash(uid)
+	uuid := controller.SanitizeUUID(bd.DevPath, blockdevice.BlockDevicePrefix+util.Hash(uid))
 
 	return uuid, uuidUsesPath
 }
@@ -135,7 +345,7 @@ func generateLegacyUUID(bd blockdevice.BlockDevice) (string, bool) {
 func generateUUIDFromPartitionTable(bd blockdevice.BlockDevice) (string, bool) {
 	uuidField := bd.PartitionInfo.PartitionTableUUID
 	if len(uuidField) > 0 {
-		return blockdevice.BlockDevicePrefix + util.Hash(uuidField), true
+		return controller.SanitizeUUID(bd.DevPath, blockdevice.BlockDevicePrefix+util.Hash(uuidField)), true
 	}
 	return "", false
 }
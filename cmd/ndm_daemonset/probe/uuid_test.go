@@ -21,8 +21,11 @@ import (
 	"testing"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/pkg/features"
 	"github.com/openebs/node-disk-manager/pkg/util"
+
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,9 +44,10 @@ func TestGenerateUUID(t *testing.T) {
 		"PartitionTableUUID=1",
 	})
 	tests := map[string]struct {
-		bd       blockdevice.BlockDevice
-		wantUUID string
-		wantOk   bool
+		bd        blockdevice.BlockDevice
+		wantUUID  string
+		wantBasis string
+		wantOk    bool
 	}{
 		"debiceType-disk with PartitionTableUUID": {
 			bd: blockdevice.BlockDevice{
@@ -52,8 +56,9 @@ func TestGenerateUUID(t *testing.T) {
 					PartitionTableUUID: fakePartitionTableUUID,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakePartitionTableUUID),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakePartitionTableUUID),
+			wantBasis: uuidBasisPartitionTableUUID,
+			wantOk:    true,
 		},
 		"deviceType-disk with WWN": {
 			bd: blockdevice.BlockDevice{
@@ -62,8 +67,9 @@ func TestGenerateUUID(t *testing.T) {
 					WWN:        fakeWWN,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeWWN),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeWWN),
+			wantBasis: uuidBasisWWN,
+			wantOk:    true,
 		},
 		"deviceType-disk with WWN and serial": {
 			bd: blockdevice.BlockDevice{
@@ -73,8 +79,9 @@ func TestGenerateUUID(t *testing.T) {
 					Serial:     fakeSerial,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeWWN+fakeSerial),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeWWN+fakeSerial),
+			wantBasis: uuidBasisWWN,
+			wantOk:    true,
 		},
 		"deviceType-disk with a filesystem and no wwn": {
 			bd: blockdevice.BlockDevice{
@@ -85,8 +92,9 @@ func TestGenerateUUID(t *testing.T) {
 					DeviceType: blockdevice.BlockDeviceTypeDisk,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeFileSystemUUID),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeFileSystemUUID),
+			wantBasis: uuidBasisFileSystemUUID,
+			wantOk:    true,
 		},
 		"deviceType-disk with a filesystem and wwn": {
 			bd: blockdevice.BlockDevice{
@@ -98,8 +106,9 @@ func TestGenerateUUID(t *testing.T) {
 					WWN:        fakeWWN,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeWWN),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeWWN),
+			wantBasis: uuidBasisWWN,
+			wantOk:    true,
 		},
 		"deviceType-partition with wwn on the disk": {
 			bd: blockdevice.BlockDevice{
@@ -111,8 +120,9 @@ func TestGenerateUUID(t *testing.T) {
 					PartitionEntryUUID: fakePartitionUUID,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakePartitionUUID),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakePartitionUUID),
+			wantBasis: uuidBasisPartitionUUID,
+			wantOk:    true,
 		},
 		"deviceType-disk with no wwn or filesystem": {
 			bd: blockdevice.BlockDevice{
@@ -120,8 +130,9 @@ func TestGenerateUUID(t *testing.T) {
 					DeviceType: blockdevice.BlockDeviceTypeDisk,
 				},
 			},
-			wantUUID: "",
-			wantOk:   false,
+			wantUUID:  "",
+			wantBasis: "",
+			wantOk:    false,
 		},
 		"deviceType-lvm device": {
 			bd: blockdevice.BlockDevice{
@@ -132,8 +143,9 @@ func TestGenerateUUID(t *testing.T) {
 					DeviceType: blockdevice.BlockDeviceTypeLVM,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeLVM_DM_UUID),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeLVM_DM_UUID),
+			wantBasis: uuidBasisDMUUID,
+			wantOk:    true,
 		},
 		"deviceType-crypt device": {
 			bd: blockdevice.BlockDevice{
@@ -144,8 +156,9 @@ func TestGenerateUUID(t *testing.T) {
 					DeviceType: blockdevice.BlockDeviceTypeCrypt,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(fakeCRYPT_DM_UUID),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(fakeCRYPT_DM_UUID),
+			wantBasis: uuidBasisDMUUID,
+			wantOk:    true,
 		},
 		"deviceType-loop device": {
 			bd: blockdevice.BlockDevice{
@@ -156,14 +169,16 @@ func TestGenerateUUID(t *testing.T) {
 					DeviceType: blockdevice.BlockDeviceTypeLoop,
 				},
 			},
-			wantUUID: blockdevice.BlockDevicePrefix + util.Hash(hostName+loopDevicePath),
-			wantOk:   true,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(hostName+loopDevicePath),
+			wantBasis: uuidBasisLoop,
+			wantOk:    true,
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			gotUUID, gotOk := generateUUID(tt.bd)
+			gotUUID, gotBasis, gotOk := generateUUID(tt.bd)
 			assert.Equal(t, tt.wantUUID, gotUUID)
+			assert.Equal(t, tt.wantBasis, gotBasis)
 			assert.Equal(t, tt.wantOk, gotOk)
 		})
 	}
@@ -219,3 +234,244 @@ func TestGenerateLegacyUUID(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateUUIDWithVersion(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			Vendor:     "ATA",
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 4000787030016,
+		},
+	}
+	tests := map[string]struct {
+		version   controller.UUIDVersion
+		wantUUID  string
+		wantBasis string
+		wantOk    bool
+	}{
+		"v1 is the md5 hash": {
+			version:   controller.UUIDVersionV1,
+			wantUUID:  blockdevice.BlockDevicePrefix + util.Hash(bd.DeviceAttributes.WWN+bd.DeviceAttributes.Serial),
+			wantBasis: uuidBasisWWN,
+			wantOk:    true,
+		},
+		"v2 is the truncated sha256 hash": {
+			version:   controller.UUIDVersionV2,
+			wantUUID:  blockdevice.BlockDevicePrefix + hashV2(bd.DeviceAttributes.WWN+bd.DeviceAttributes.Serial),
+			wantBasis: uuidBasisWWN,
+			wantOk:    true,
+		},
+		"v3 is the truncated sha256 hash of the composite identifier": {
+			version:   controller.UUIDVersionV3,
+			wantUUID:  blockdevice.BlockDevicePrefix + hashV2("0x5000c500a1b2c3d4|ZA1B2C3D|ATA"),
+			wantBasis: uuidBasisWWNComposite,
+			wantOk:    true,
+		},
+		"unknown version": {
+			version: controller.UUIDVersion("v9"),
+			wantOk:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			uuid, basis, ok := generateUUIDWithVersion(bd, tt.version)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantUUID, uuid)
+			assert.Equal(t, tt.wantBasis, basis)
+		})
+	}
+
+	// names derived from the v2 uuid, like the cleanup job name, should be valid label values
+	v2UUID, _, _ := generateUUIDWithVersion(bd, controller.UUIDVersionV2)
+	assert.LessOrEqual(t, len("cleanup-"+v2UUID), 63)
+}
+
+func TestGenerateV5UUID(t *testing.T) {
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sda",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+	}
+	namespace := uuid.MustParse("3f1c2b6e-8a4d-4e5f-9b7a-1c2d3e4f5a6b")
+	otherNamespace := uuid.MustParse("9d2e4c1a-7b3f-4a6e-8c5d-2f1e3d4c5b6a")
+
+	got, basis, ok := generateV5UUID(bd, namespace)
+	assert.True(t, ok)
+	assert.Equal(t, uuidBasisWWN, basis)
+	assert.Equal(t, blockdevice.BlockDevicePrefix+uuid.NewSHA1(namespace, []byte("0x5000c500a1b2c3d4ZA1B2C3D")).String(), got)
+
+	// the uuid is a RFC 4122 version 5 uuid
+	parsed, err := uuid.Parse(got[len(blockdevice.BlockDevicePrefix):])
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), parsed.Version())
+	assert.Equal(t, uuid.RFC4122, parsed.Variant())
+
+	// the same device gets the same uuid in the same namespace, and a different one in
+	// another namespace
+	again, _, _ := generateV5UUID(bd, namespace)
+	assert.Equal(t, got, again)
+	other, _, _ := generateV5UUID(bd, otherNamespace)
+	assert.NotEqual(t, got, other)
+
+	// v5 cannot be generated without a namespace
+	pe := &ProbeEvent{Controller: &controller.Controller{}}
+	_, _, ok = pe.generateUUIDOfVersion(bd, controller.UUIDVersionV5)
+	assert.False(t, ok)
+	assert.NotContains(t, pe.uuidVersions(), controller.UUIDVersionV5)
+
+	pe.Controller.UUIDNamespace = namespace
+	fromPE, _, ok := pe.generateUUIDOfVersion(bd, controller.UUIDVersionV5)
+	assert.True(t, ok)
+	assert.Equal(t, got, fromPE)
+	assert.Contains(t, pe.uuidVersions(), controller.UUIDVersionV5)
+}
+
+func TestGenerateUUIDWithCompositeIdentifier(t *testing.T) {
+	newDisk := func(devPath, serial string, capacity uint64) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				// the enclosure reports the same WWN for all the disks in it
+				WWN:    "0x5000000000000001",
+				Serial: serial,
+				Vendor: "JMicron",
+			},
+			Capacity: blockdevice.CapacityInformation{
+				Storage: capacity,
+			},
+		}
+	}
+	tests := map[string]struct {
+		disk1 blockdevice.BlockDevice
+		disk2 blockdevice.BlockDevice
+	}{
+		"identical wwn with different serials": {
+			disk1: newDisk("/dev/sda", "WD-WCC4N1234567", 2000398934016),
+			disk2: newDisk("/dev/sdb", "WD-WCC4N7654321", 2000398934016),
+		},
+		"identical wwn and capacity with different serials": {
+			disk1: newDisk("/dev/sda", "0000000000000001", 2000398934016),
+			disk2: newDisk("/dev/sdb", "0000000000000002", 2000398934016),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			uuid1, basis1, ok1 := generateUUIDWithVersion(tt.disk1, controller.UUIDVersionV3)
+			uuid2, basis2, ok2 := generateUUIDWithVersion(tt.disk2, controller.UUIDVersionV3)
+			assert.True(t, ok1)
+			assert.True(t, ok2)
+			assert.Equal(t, uuidBasisWWNComposite, basis1)
+			assert.Equal(t, uuidBasisWWNComposite, basis2)
+			assert.NotEqual(t, uuid1, uuid2)
+
+			// the composite uuid is stable for the same disk
+			again, _, _ := generateUUIDWithVersion(tt.disk1, controller.UUIDVersionV3)
+			assert.Equal(t, uuid1, again)
+
+			// the composite uuid does not change when the LUN is expanded online
+			expanded := tt.disk1
+			expanded.Capacity.Storage *= 2
+			afterExpansion, _, _ := generateUUIDWithVersion(expanded, controller.UUIDVersionV3)
+			assert.Equal(t, uuid1, afterExpansion)
+		})
+	}
+}
+
+func TestGenerateUUIDOfVirtioDisk(t *testing.T) {
+	newVirtioDisk := func(devPath, busAddress, serial string, capacity uint64) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: devPath,
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType:       blockdevice.BlockDeviceTypeDisk,
+				Transport:        blockdevice.TransportVirtio,
+				VirtioBusAddress: busAddress,
+				Serial:           serial,
+			},
+			Capacity: blockdevice.CapacityInformation{
+				Storage: capacity,
+			},
+		}
+	}
+	tests := map[string]struct {
+		enabled    bool
+		disk       blockdevice.BlockDevice
+		reattached blockdevice.BlockDevice
+		wantOk     bool
+		wantSame   bool
+	}{
+		"reattached at the same slot with another name": {
+			enabled:    true,
+			disk:       newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 10737418240),
+			reattached: newVirtioDisk("/dev/vdc", "0000:00:05.0", "", 10737418240),
+			wantOk:     true,
+			wantSame:   true,
+		},
+		"reattached at another slot": {
+			enabled:    true,
+			disk:       newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 10737418240),
+			reattached: newVirtioDisk("/dev/vdb", "0000:00:06.0", "", 10737418240),
+			wantOk:     true,
+			wantSame:   false,
+		},
+		"another disk of a different size at the same slot": {
+			enabled:    true,
+			disk:       newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 10737418240),
+			reattached: newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 21474836480),
+			wantOk:     true,
+			wantSame:   false,
+		},
+		"feature disabled": {
+			enabled:    false,
+			disk:       newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 10737418240),
+			reattached: newVirtioDisk("/dev/vdb", "0000:00:05.0", "", 10737418240),
+			wantOk:     false,
+		},
+		"virtio disk with serial": {
+			enabled:    true,
+			disk:       newVirtioDisk("/dev/vdb", "0000:00:05.0", "vol-1234", 10737418240),
+			reattached: newVirtioDisk("/dev/vdb", "0000:00:05.0", "vol-1234", 10737418240),
+			wantOk:     false,
+		},
+	}
+	defer features.FeatureGates.SetFeatureFlag([]string{"VirtioBusAddressUUID=0"})
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.enabled {
+				features.FeatureGates.SetFeatureFlag([]string{"GPTBasedUUID=1", "VirtioBusAddressUUID=1"})
+			} else {
+				features.FeatureGates.SetFeatureFlag([]string{"VirtioBusAddressUUID=0"})
+			}
+			uuid1, basis, ok := generateUUID(tt.disk)
+			assert.Equal(t, tt.wantOk, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, uuidBasisVirtioBusAddress, basis)
+			uuid2, _, _ := generateUUID(tt.reattached)
+			assert.Equal(t, tt.wantSame, uuid1 == uuid2)
+
+			// the uuid does not change once the disk is formatted
+			formatted := tt.disk
+			formatted.FSInfo.FileSystemUUID = "149108ca-f404-4556-a263-04943e6cb0b3"
+			uuid3, _, _ := generateUUID(formatted)
+			assert.Equal(t, uuid1, uuid3)
+		})
+	}
+}
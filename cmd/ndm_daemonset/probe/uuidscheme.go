@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// uuidSchemeRepairOnce makes sure that the uuid scheme annotations are repaired only
+// once, after the devices from the first scan have been processed
+var uuidSchemeRepairOnce sync.Once
+
+// repairUUIDSchemeAnnotations adds the missing uuid scheme annotation on the blockdevice
+// resources of this node. Resources created by older versions of NDM may not have the
+// annotation, which is required to decide how the resource is handled during upgrades.
+// The scheme is inferred by regenerating both the gpt UUIDs of all the versions and the
+// legacy UUIDs of the devices on the node and matching them against the resource name.
+func (pe *ProbeEvent) repairUUIDSchemeAnnotations() {
+	bdAPIList, err := pe.Controller.ListBlockDeviceResource(false)
+	if err != nil {
+		klog.Errorf("unable to list blockdevices for repairing uuid scheme annotations: %v", err)
+		return
+	}
+
+	// map of the UUIDs generated by each scheme to the uuid scheme
+	uuidSchemes := make(map[string]string)
+	for _, bd := range pe.Controller.BDHierarchy.Snapshot() {
+		if uuids, ok := pe.generateUUIDsOfAllVersions(bd); ok {
+			for _, uuid := range uuids {
+				uuidSchemes[uuid] = gptUUIDScheme
+			}
+		}
+		legacyUUID, _ := generateLegacyUUID(bd)
+		if _, ok := uuidSchemes[legacyUUID]; !ok {
+			uuidSchemes[legacyUUID] = legacyUUIDScheme
+		}
+	}
+
+	for _, bdAPI := range bdAPIList.Items {
+		if _, ok := bdAPI.Annotations[internalUUIDSchemeAnnotation]; ok {
+			continue
+		}
+		scheme, ok := uuidSchemes[bdAPI.Name]
+		if !ok {
+			klog.V(4).Infof("unable to infer uuid scheme of blockdevice: %s", bdAPI.Name)
+			continue
+		}
+		klog.Infof("adding missing uuid scheme: %s to blockdevice: %s", scheme, bdAPI.Name)
+		annotation := map[string]string{
+			internalUUIDSchemeAnnotation: scheme,
+		}
+		if err := pe.Controller.AnnotateBlockDevice(bdAPI, annotation); err != nil {
+			klog.Errorf("unable to add uuid scheme annotation to blockdevice: %s, %v", bdAPI.Name, err)
+		}
+	}
+}
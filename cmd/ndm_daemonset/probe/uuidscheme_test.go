@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRepairUUIDSchemeAnnotations(t *testing.T) {
+	fakeHostName := "node-1"
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: "/dev/sdb",
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+			Model:      "ST1000NM0055",
+			Vendor:     "ATA",
+			IDType:     "disk",
+		},
+	}
+	gptUUID, _, _ := generateUUID(bd)
+	legacyUUID, _ := generateLegacyUUID(bd)
+
+	tests := map[string]struct {
+		name           string
+		annotations    map[string]string
+		wantAnnotation string
+	}{
+		"resource with legacy uuid gets the legacy annotation": {
+			name:           legacyUUID,
+			wantAnnotation: legacyUUIDScheme,
+		},
+		"resource with gpt uuid gets the gpt annotation": {
+			name:           gptUUID,
+			wantAnnotation: gptUUIDScheme,
+		},
+		"existing annotation is not modified": {
+			name: legacyUUID,
+			annotations: map[string]string{
+				internalUUIDSchemeAnnotation: pinnedUUIDScheme,
+			},
+			wantAnnotation: pinnedUUIDScheme,
+		},
+		"resource not matching any device is not annotated": {
+			name:           "blockdevice-00000000000000000000000000000000",
+			wantAnnotation: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+			s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+			cl := fake.NewFakeClientWithScheme(s)
+
+			bdAPI := &apis.BlockDevice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        tt.name,
+					Namespace:   "openebs",
+					Annotations: tt.annotations,
+					Labels: map[string]string{
+						controller.KubernetesHostNameLabel: fakeHostName,
+					},
+				},
+			}
+			assert.NoError(t, cl.Create(context.TODO(), bdAPI))
+
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset: cl,
+					Namespace: "openebs",
+					NodeAttributes: map[string]string{
+						controller.HostNameKey: fakeHostName,
+					},
+					BDHierarchy: blockdevice.NewHierarchyCache(blockdevice.Hierarchy{
+						bd.DevPath: bd,
+					}),
+				},
+			}
+			pe.repairUUIDSchemeAnnotations()
+
+			gotBDAPI := &apis.BlockDevice{}
+			assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Namespace: "openebs", Name: tt.name}, gotBDAPI))
+			assert.Equal(t, tt.wantAnnotation, gotBDAPI.Annotations[internalUUIDSchemeAnnotation])
+		})
+	}
+}
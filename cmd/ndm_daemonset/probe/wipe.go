@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// getWipedSignature gets the signature the cached disk had, if the disk is now blank and
+// is identified by the same WWN and serial. A disk identified by its filesystem uuid
+// loses its identity when wiped, and is handled like any other blank disk.
+func getWipedSignature(cached, bd blockdevice.BlockDevice) (string, bool) {
+	if bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk ||
+		bd.FSInfo.FileSystem != "" ||
+		bd.PartitionInfo.PartitionTableType != "" ||
+		len(bd.DependentDevices.Partitions) > 0 ||
+		len(bd.DependentDevices.Holders) > 0 {
+		return "", false
+	}
+
+	var signature string
+	switch {
+	case cached.FSInfo.FileSystem != "":
+		signature = fmt.Sprintf("%s filesystem", cached.FSInfo.FileSystem)
+	case len(cached.DependentDevices.Partitions) > 0:
+		signature = fmt.Sprintf("partition table with %d partitions", len(cached.DependentDevices.Partitions))
+	case cached.PartitionInfo.PartitionTableType != "":
+		signature = fmt.Sprintf("%s partition table", cached.PartitionInfo.PartitionTableType)
+	default:
+		return "", false
+	}
+
+	cachedField, cachedBasis, cachedOK := getUUIDField(cached)
+	field, basis, ok := getUUIDField(bd)
+	if !cachedOK || !ok ||
+		cachedBasis != uuidBasisWWN || basis != uuidBasisWWN ||
+		cachedField != field {
+		return "", false
+	}
+	return signature, true
+}
+
+// recordWipedDevice records the signature the device had, if the device was wiped since
+// it was cached. It should be called before the device is updated in the cache.
+func (pe *ProbeEvent) recordWipedDevice(bd blockdevice.BlockDevice) {
+	if pe.wipedDevices == nil {
+		return
+	}
+	cached, ok := pe.Controller.BDHierarchy.Get(bd.DevPath)
+	if !ok {
+		return
+	}
+	if signature, ok := getWipedSignature(cached, bd); ok {
+		pe.wipedDevices[bd.DevPath] = signature
+	}
+}
+
+// tagWipedBlockDevice adds the wiped annotation to the existing resource of the device, if
+// the device was wiped, and records an event on it.
+func (pe *ProbeEvent) tagWipedBlockDevice(bd blockdevice.BlockDevice, existingBD *apis.BlockDevice, annotations map[string]string) {
+	signature, ok := pe.wipedDevices[bd.DevPath]
+	if !ok || existingBD == nil {
+		return
+	}
+	delete(pe.wipedDevices, bd.DevPath)
+
+	reason := fmt.Sprintf("%s wiped from device %s", signature, bd.DevPath)
+	klog.Infof("eventcode=%s msg=%s reason=%q rname=%v",
+		"ndm.blockdevice.wiped", "Wiped device retains its blockdevice",
+		reason, existingBD.Name)
+	bd.DecisionTrace.Add("wipe:detected")
+	annotations[controller.WipedAnnotation] = signature
+	if pe.Controller.Recorder != nil {
+		pe.Controller.Recorder.Event(existingBD, v1.EventTypeNormal, "Wiped", reason)
+	}
+}
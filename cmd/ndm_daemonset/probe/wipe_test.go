@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	libudevwrapper "github.com/openebs/node-disk-manager/pkg/udev"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWipedSignature(t *testing.T) {
+	newDisk := func(wwn string) blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sdX",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        wwn,
+				Serial:     "fake-serial-X",
+			},
+		}
+	}
+	withFS := func(bd blockdevice.BlockDevice) blockdevice.BlockDevice {
+		bd.FSInfo.FileSystem = "ext4"
+		bd.FSInfo.FileSystemUUID = "4f8a3b2c-1d5e-4a6b-9c7d-8e9f0a1b2c3d"
+		return bd
+	}
+	withPartitions := func(bd blockdevice.BlockDevice) blockdevice.BlockDevice {
+		bd.PartitionInfo.PartitionTableType = "gpt"
+		bd.DependentDevices.Partitions = []string{"/dev/sdX1", "/dev/sdX2"}
+		return bd
+	}
+
+	tests := map[string]struct {
+		cached        blockdevice.BlockDevice
+		bd            blockdevice.BlockDevice
+		wantSignature string
+		wantOk        bool
+	}{
+		"filesystem wiped from disk with stable wwn": {
+			cached:        withFS(newDisk("fake-WWN-X")),
+			bd:            newDisk("fake-WWN-X"),
+			wantSignature: "ext4 filesystem",
+			wantOk:        true,
+		},
+		"partitions wiped from disk with stable wwn": {
+			cached:        withPartitions(newDisk("fake-WWN-X")),
+			bd:            newDisk("fake-WWN-X"),
+			wantSignature: "partition table with 2 partitions",
+			wantOk:        true,
+		},
+		"disk that was already blank": {
+			cached: newDisk("fake-WWN-X"),
+			bd:     newDisk("fake-WWN-X"),
+			wantOk: false,
+		},
+		"disk that still has a filesystem": {
+			cached: withFS(newDisk("fake-WWN-X")),
+			bd:     withFS(newDisk("fake-WWN-X")),
+			wantOk: false,
+		},
+		"disk identified by its filesystem uuid": {
+			cached: withFS(newDisk("")),
+			bd:     newDisk(""),
+			wantOk: false,
+		},
+		"different disk at the same path": {
+			cached: withFS(newDisk("fake-WWN-X")),
+			bd:     newDisk("fake-WWN-Y"),
+			wantOk: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotSignature, gotOk := getWipedSignature(tt.cached, tt.bd)
+			assert.Equal(t, tt.wantOk, gotOk)
+			assert.Equal(t, tt.wantSignature, gotSignature)
+		})
+	}
+}
+
+func TestAddBlockDeviceEventWithWipedDisk(t *testing.T) {
+	newDisk := func() *blockdevice.BlockDevice {
+		return &blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sdX",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+				WWN:        "fake-WWN-X",
+				Serial:     "fake-serial-X",
+			},
+		}
+	}
+	fakeController := &controller.Controller{
+		Clientset:      CreateFakeClient(t),
+		Mutex:          &sync.Mutex{},
+		Filters:        make([]*controller.Filter, 0),
+		Probes:         make([]*controller.Probe, 0),
+		NodeAttributes: map[string]string{controller.HostNameKey: fakeHostName},
+		BDHierarchy:    blockdevice.NewHierarchyCache(nil),
+	}
+	pe := &ProbeEvent{Controller: fakeController}
+
+	// the disk is first seen with a filesystem
+	formatted := newDisk()
+	formatted.FSInfo.FileSystem = "ext4"
+	formatted.FSInfo.FileSystemUUID = "4f8a3b2c-1d5e-4a6b-9c7d-8e9f0a1b2c3d"
+	pe.addBlockDeviceEvent(controller.EventMessage{
+		Action:  libudevwrapper.UDEV_ACTION_ADD,
+		Devices: []*blockdevice.BlockDevice{formatted},
+	})
+	bdAPIList, err := fakeController.ListBlockDeviceResource(false)
+	assert.NoError(t, err)
+	if !assert.Equal(t, 1, len(bdAPIList.Items)) {
+		return
+	}
+	uuid := bdAPIList.Items[0].Name
+	assert.NotContains(t, bdAPIList.Items[0].Annotations, controller.WipedAnnotation)
+
+	// the operator wipes the disk, the resource of the disk is retained and tagged
+	pe.addBlockDeviceEvent(controller.EventMessage{
+		Action:  libudevwrapper.UDEV_ACTION_ADD,
+		Devices: []*blockdevice.BlockDevice{newDisk()},
+	})
+	bdAPIList, err = fakeController.ListBlockDeviceResource(false)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(bdAPIList.Items)) {
+		assert.Equal(t, uuid, bdAPIList.Items[0].Name)
+		assert.Equal(t, "ext4 filesystem", bdAPIList.Items[0].Annotations[controller.WipedAnnotation])
+		assert.Empty(t, bdAPIList.Items[0].Spec.FileSystem.Type)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/partition"
+
+	"k8s.io/klog/v2"
+)
+
+// verifyWritable is a variable, so that it can be replaced in tests
+var verifyWritable = partition.VerifyWritable
+
+// isReadOnly is a variable, so that it can be replaced in tests
+var isReadOnly = partition.IsReadOnly
+
+// checkWritability checks that the disk can be written to, if the writability check is
+// enabled, and records the result in the device. A read-only disk is reported as not
+// writable without writing to it. Only blank disks that are not in use, and whose
+// blockdevice is not protected, like one claimed for a raw block volume, are written to.
+// The check is skipped for other devices and the result is unknown.
+func (pe *ProbeEvent) checkWritability(bd *blockdevice.BlockDevice, bdAPIList *apis.BlockDeviceList) {
+	if !pe.Controller.WritabilityCheck || bd.DeviceAttributes.DeviceType != blockdevice.BlockDeviceTypeDisk {
+		return
+	}
+	if reason := getNotBlankReason(*bd); reason != "" {
+		klog.V(4).Infof("device: %s %s, skipping writability check", bd.DevPath, reason)
+		bd.DeviceAttributes.Writability = &blockdevice.WritabilityState{
+			Message: "writability check skipped, device " + reason,
+		}
+		bd.DecisionTrace.Add("writability:skipped")
+		return
+	}
+	if readOnly, err := isReadOnly(bd.DevPath); err != nil {
+		klog.V(4).Infof("unable to check if device: %s is read-only, %v", bd.DevPath, err)
+	} else if readOnly {
+		bd.DeviceAttributes.Writability = &blockdevice.WritabilityState{
+			Checked: true,
+			Message: "device is read-only",
+		}
+		bd.DecisionTrace.Add("writability:read-only")
+		return
+	}
+	// the blockdevice may be used as a raw block volume, which does not open the device
+	// exclusively, and is not found as in use
+	if existingBD := pe.Controller.GetExistingBlockDeviceResource(bdAPIList, bd.UUID); existingBD != nil &&
+		pe.Controller.IsProtected(*existingBD, controller.VerifyWritableOperation) {
+		bd.DeviceAttributes.Writability = &blockdevice.WritabilityState{
+			Message: "writability check skipped, blockdevice is protected",
+		}
+		bd.DecisionTrace.Add("writability:protected")
+		return
+	}
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.VerifyWritableOperation, bd.DevPath) {
+		bd.DecisionTrace.Add("writability:not-allowed")
+		return
+	}
+
+	state := &blockdevice.WritabilityState{
+		Checked:  true,
+		Writable: true,
+	}
+	err := verifyWritable(bd.DevPath)
+	pe.Controller.Audit(controller.VerifyWritableOperation, getAuditTarget(*bd), controller.AuditCause{
+		Trigger: controller.AuditTriggerDeviceAdded,
+		Reason:  "writability check of blank disk",
+	}, err)
+	if err != nil {
+		klog.Warningf("eventcode=%s msg=%s err=%q rname=%v",
+			"ndm.blockdevice.write.failed", "Writability check of device failed",
+			err, bd.DevPath)
+		state.Writable = false
+		state.Message = err.Error()
+		bd.DecisionTrace.Add("writability:failed")
+	} else {
+		bd.DecisionTrace.Add("writability:verified")
+	}
+	bd.DeviceAttributes.Writability = state
+}
+
+// getNotBlankReason gets the reason for which the disk is not considered blank. An empty
+// string is returned if the disk has no partitions, partition table, filesystem or other
+// signatures, and is not in use.
+func getNotBlankReason(bd blockdevice.BlockDevice) string {
+	switch {
+	case bd.DevUse.InUse:
+		return "is in use"
+	case len(bd.DependentDevices.Holders) > 0:
+		return "has holders"
+	case len(bd.FSInfo.MountPoint) > 0:
+		return "is mounted"
+	case len(bd.DependentDevices.Partitions) > 0:
+		return "has partitions"
+	case len(bd.PartitionInfo.PartitionTableType) > 0:
+		return "has a partition table"
+	case len(bd.FSInfo.FileSystem) > 0 || len(bd.FSInfo.Signatures) > 0:
+		return "has a filesystem"
+	}
+	return ""
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckWritability(t *testing.T) {
+	newDisk := func() blockdevice.BlockDevice {
+		return blockdevice.BlockDevice{
+			Identifier: blockdevice.Identifier{
+				DevPath: "/dev/sdb",
+			},
+			DeviceAttributes: blockdevice.DeviceAttribute{
+				DeviceType: blockdevice.BlockDeviceTypeDisk,
+			},
+		}
+	}
+	inUse := newDisk()
+	inUse.DevUse.InUse = true
+	mounted := newDisk()
+	mounted.FSInfo.MountPoint = []string{"/data"}
+	formatted := newDisk()
+	formatted.FSInfo.FileSystem = "ext4"
+	partitioned := newDisk()
+	partitioned.PartitionInfo.PartitionTableType = "gpt"
+	partition := newDisk()
+	partition.DeviceAttributes.DeviceType = blockdevice.BlockDeviceTypePartition
+	claimed := newDisk()
+	claimed.UUID = "blockdevice-claimed"
+	claimedBDAPI := apis.BlockDevice{}
+	claimedBDAPI.Name = claimed.UUID
+	claimedBDAPI.Status.ClaimState = apis.BlockDeviceClaimed
+
+	tests := map[string]struct {
+		bd           blockdevice.BlockDevice
+		bdAPIList    apis.BlockDeviceList
+		enabled      bool
+		readOnly     bool
+		verifyErr    error
+		wantVerified bool
+		want         *blockdevice.WritabilityState
+	}{
+		"blank disk is verified": {
+			bd:           newDisk(),
+			enabled:      true,
+			wantVerified: true,
+			want:         &blockdevice.WritabilityState{Checked: true, Writable: true},
+		},
+		"blank disk failing the check": {
+			bd:           newDisk(),
+			enabled:      true,
+			verifyErr:    fmt.Errorf("input/output error"),
+			wantVerified: true,
+			want:         &blockdevice.WritabilityState{Checked: true, Writable: false, Message: "input/output error"},
+		},
+		"read-only disk is not written to": {
+			bd:       newDisk(),
+			enabled:  true,
+			readOnly: true,
+			want:     &blockdevice.WritabilityState{Checked: true, Writable: false, Message: "device is read-only"},
+		},
+		"disk with a claimed blockdevice is not written to": {
+			bd:        claimed,
+			bdAPIList: apis.BlockDeviceList{Items: []apis.BlockDevice{claimedBDAPI}},
+			enabled:   true,
+			want:      &blockdevice.WritabilityState{Message: "writability check skipped, blockdevice is protected"},
+		},
+		"disk in use is skipped": {
+			bd:      inUse,
+			enabled: true,
+			want:    &blockdevice.WritabilityState{Message: "writability check skipped, device is in use"},
+		},
+		"mounted disk is skipped": {
+			bd:      mounted,
+			enabled: true,
+			want:    &blockdevice.WritabilityState{Message: "writability check skipped, device is mounted"},
+		},
+		"disk with a filesystem is skipped": {
+			bd:      formatted,
+			enabled: true,
+			want:    &blockdevice.WritabilityState{Message: "writability check skipped, device has a filesystem"},
+		},
+		"disk with a partition table is skipped": {
+			bd:      partitioned,
+			enabled: true,
+			want:    &blockdevice.WritabilityState{Message: "writability check skipped, device has a partition table"},
+		},
+		"partition is not checked": {
+			bd:      partition,
+			enabled: true,
+			want:    nil,
+		},
+		"check disabled": {
+			bd:      newDisk(),
+			enabled: false,
+			want:    nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldVerifyWritable := verifyWritable
+			defer func() { verifyWritable = oldVerifyWritable }()
+			verified := false
+			verifyWritable = func(devPath string) error {
+				verified = true
+				return tt.verifyErr
+			}
+			oldIsReadOnly := isReadOnly
+			defer func() { isReadOnly = oldIsReadOnly }()
+			isReadOnly = func(devPath string) (bool, error) {
+				return tt.readOnly, nil
+			}
+			auditBuf := &bytes.Buffer{}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:        CreateFakeClient(t),
+					WritabilityCheck: tt.enabled,
+					AuditLog:         controller.NewAuditLog(auditBuf, nil),
+				},
+			}
+			bd := tt.bd
+			pe.checkWritability(&bd, &tt.bdAPIList)
+			assert.Equal(t, tt.wantVerified, verified)
+			assert.Equal(t, tt.want, bd.DeviceAttributes.Writability)
+
+			var wantRecords []controller.AuditRecord
+			if tt.wantVerified {
+				wantRecords = []controller.AuditRecord{{
+					Operation: string(controller.VerifyWritableOperation),
+					Device:    bd.DevPath,
+					Trigger:   controller.AuditTriggerDeviceAdded,
+					Result:    controller.AuditResultSuccess,
+				}}
+				if tt.verifyErr != nil {
+					wantRecords[0].Result = controller.AuditResultFailure
+				}
+			}
+			assertAuditRecords(t, auditBuf, wantRecords)
+		})
+	}
+}
+
+func TestAddBlockDeviceWithWritabilityCheck(t *testing.T) {
+	// a blank disk with a WWN, for which the resource is created without partitioning it
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(diskImage, make([]byte, 10*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bd := blockdevice.BlockDevice{
+		Identifier: blockdevice.Identifier{
+			DevPath: diskImage,
+		},
+		DeviceAttributes: blockdevice.DeviceAttribute{
+			DeviceType: blockdevice.BlockDeviceTypeDisk,
+			WWN:        "0x5000c500a1b2c3d4",
+			Serial:     "ZA1B2C3D",
+		},
+		Capacity: blockdevice.CapacityInformation{
+			Storage: 10 * 1024 * 1024,
+		},
+		DecisionTrace: &blockdevice.DecisionTrace{},
+	}
+
+	s := scheme.Scheme
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDevice{})
+	s.AddKnownTypes(apis.GroupVersion, &apis.BlockDeviceList{})
+	cl := fake.NewFakeClientWithScheme(s)
+	pe := &ProbeEvent{
+		Controller: &controller.Controller{
+			Clientset:        cl,
+			BDHierarchy:      blockdevice.NewHierarchyCache(nil),
+			WritabilityCheck: true,
+		},
+	}
+	assert.NoError(t, pe.addBlockDevice(bd, &apis.BlockDeviceList{}))
+
+	bdList := &apis.BlockDeviceList{}
+	assert.NoError(t, cl.List(context.TODO(), bdList))
+	if assert.Len(t, bdList.Items, 1) {
+		var found bool
+		for _, condition := range bdList.Items[0].Status.Conditions {
+			if condition.Type == apis.BlockDeviceWritable {
+				found = true
+				assert.Equal(t, v1.ConditionTrue, condition.Status)
+			}
+		}
+		assert.True(t, found)
+	}
+
+	// the scratch region is left blank
+	data, err := os.ReadFile(diskImage)
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, len(data)), data)
+}
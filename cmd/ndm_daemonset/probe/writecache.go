@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/sysfs"
+
+	"k8s.io/klog/v2"
+)
+
+// disableWriteCache is a variable, so that it can be replaced in tests
+var disableWriteCache = func(devPath string) error {
+	sysFsDevice, err := sysfs.NewSysFsDeviceFromDevPath(devPath)
+	if err != nil {
+		return err
+	}
+	return sysFsDevice.DisableWriteCache()
+}
+
+// disableWriteCacheOfBlankDisk disables the volatile write cache of a blank disk that
+// is about to be partitioned, if enabled. It is best effort, the disk is partitioned
+// even if the write cache could not be disabled.
+func (pe *ProbeEvent) disableWriteCacheOfBlankDisk(bd *blockdevice.BlockDevice) {
+	writeCacheEnabled := bd.DeviceAttributes.WriteCacheEnabled
+	if !pe.Controller.DisableWriteCache || writeCacheEnabled == nil || !*writeCacheEnabled {
+		return
+	}
+	if !pe.Controller.IsDestructiveOperationAllowed(controller.DisableWriteCacheOperation, bd.DevPath) {
+		return
+	}
+	klog.Infof("disabling write cache of blank device: %s before partitioning", bd.DevPath)
+	err := disableWriteCache(bd.DevPath)
+	pe.Controller.Audit(controller.DisableWriteCacheOperation, getAuditTarget(*bd), blankDiskAuditCause, err)
+	if err != nil {
+		klog.Warningf("unable to disable write cache of device: %s, err: %v", bd.DevPath, err)
+		return
+	}
+	bd.DecisionTrace.Add("action:write-cache-disabled")
+	disabled := false
+	bd.DeviceAttributes.WriteCacheEnabled = &disabled
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableWriteCacheOfBlankDisk(t *testing.T) {
+	enabled, disabled := true, false
+	tests := map[string]struct {
+		disableWriteCache bool
+		safeMode          bool
+		writeCacheEnabled *bool
+		disableErr        error
+		wantDisable       bool
+		wantEnabled       *bool
+	}{
+		"disabling write cache not enabled": {
+			writeCacheEnabled: &enabled,
+			wantEnabled:       &enabled,
+		},
+		"write cache state not known": {
+			disableWriteCache: true,
+			writeCacheEnabled: nil,
+			wantEnabled:       nil,
+		},
+		"write cache already disabled": {
+			disableWriteCache: true,
+			writeCacheEnabled: &disabled,
+			wantEnabled:       &disabled,
+		},
+		"write cache is disabled": {
+			disableWriteCache: true,
+			writeCacheEnabled: &enabled,
+			wantDisable:       true,
+			wantEnabled:       &disabled,
+		},
+		"disabling write cache blocked by safe mode": {
+			disableWriteCache: true,
+			safeMode:          true,
+			writeCacheEnabled: &enabled,
+			wantEnabled:       &enabled,
+		},
+		"failure to disable write cache is ignored": {
+			disableWriteCache: true,
+			writeCacheEnabled: &enabled,
+			disableErr:        fmt.Errorf("not a scsi disk"),
+			wantDisable:       true,
+			wantEnabled:       &enabled,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			disabledDevices := make([]string, 0)
+			origDisableWriteCache := disableWriteCache
+			defer func() { disableWriteCache = origDisableWriteCache }()
+			disableWriteCache = func(devPath string) error {
+				disabledDevices = append(disabledDevices, devPath)
+				return tt.disableErr
+			}
+
+			auditBuf := &bytes.Buffer{}
+			pe := &ProbeEvent{
+				Controller: &controller.Controller{
+					Clientset:         CreateFakeClient(t),
+					DisableWriteCache: tt.disableWriteCache,
+					SafeMode:          tt.safeMode,
+					AuditLog:          controller.NewAuditLog(auditBuf, nil),
+				},
+			}
+			bd := &blockdevice.BlockDevice{}
+			bd.DevPath = "/dev/sdb"
+			bd.DeviceAttributes.WriteCacheEnabled = tt.writeCacheEnabled
+
+			pe.disableWriteCacheOfBlankDisk(bd)
+			assert.Equal(t, tt.wantDisable, len(disabledDevices) == 1)
+			assert.Equal(t, tt.wantEnabled, bd.DeviceAttributes.WriteCacheEnabled)
+
+			var wantRecords []controller.AuditRecord
+			if tt.wantDisable {
+				wantRecords = []controller.AuditRecord{{
+					Operation: string(controller.DisableWriteCacheOperation),
+					Device:    "/dev/sdb",
+					Trigger:   controller.AuditTriggerDeviceAdded,
+					Result:    controller.AuditResultSuccess,
+				}}
+				if tt.disableErr != nil {
+					wantRecords[0].Result = controller.AuditResultFailure
+				}
+			}
+			assertAuditRecords(t, auditBuf, wantRecords)
+		})
+	}
+}
@@ -27,7 +27,10 @@ package blkid
 */
 import "C"
 import (
+	"fmt"
 	"unsafe"
+
+	"github.com/openebs/node-disk-manager/pkg/util"
 )
 
 const (
@@ -64,6 +67,40 @@ func (di *DeviceIdentifier) GetPartitionEntryUUID() string {
 	return di.GetTagValue(partitionEntryUUIDIdentifier)
 }
 
+// GetOnDiskSignatures returns the types of all the filesystem and storage engine signatures
+// present on the disk, by probing the disk like wipefs using libblkid. Unlike the high level
+// tag lookup, which reports nothing if the signatures are ambiguous, all the signatures are
+// reported, in the order in which they were found.
+func (di *DeviceIdentifier) GetOnDiskSignatures() ([]string, error) {
+	device := C.CString(di.DevPath)
+	defer C.free(unsafe.Pointer(device))
+
+	probe := C.blkid_new_probe_from_filename(device)
+	if probe == nil {
+		return nil, fmt.Errorf("unable to create blkid probe for device %s", di.DevPath)
+	}
+	defer C.blkid_free_probe(probe)
+
+	C.blkid_probe_enable_superblocks(probe, 1)
+	C.blkid_probe_set_superblocks_flags(probe, C.BLKID_SUBLKS_TYPE)
+
+	typeName := C.CString(fsTypeIdentifier)
+	defer C.free(unsafe.Pointer(typeName))
+
+	signatures := make([]string, 0)
+	for C.blkid_do_probe(probe) == 0 {
+		var value *C.char
+		if C.blkid_probe_lookup_value(probe, typeName, &value, nil) != 0 {
+			continue
+		}
+		signature := C.GoString(value)
+		if !util.Contains(signatures, signature) {
+			signatures = append(signatures, signature)
+		}
+	}
+	return signatures, nil
+}
+
 func (di *DeviceIdentifier) GetTagValue(tag string) string {
 	var blkidType *C.char
 	blkidType = C.CString(tag)
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blkid
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// deviceSize is the size of the device images used in the tests
+	deviceSize = 8 * 1024 * 1024
+
+	// ext4SuperblockOffset is the offset of the ext4 superblock on the device
+	ext4SuperblockOffset = 1024
+
+	// lvm2LabelOffset is the offset of the LVM2 physical volume label, in the
+	// second sector of the device
+	lvm2LabelOffset = 512
+
+	// lvm2CRCInitial is the initial value of the crc used by LVM2
+	lvm2CRCInitial = 0xf597a6cf
+)
+
+// writeExt4Superblock writes the superblock of an ext4 filesystem with 4KiB blocks,
+// having a journal and extents
+func writeExt4Superblock(buf []byte) {
+	sb := buf[ext4SuperblockOffset:]
+	binary.LittleEndian.PutUint32(sb[0:], 2048)    // s_inodes_count
+	binary.LittleEndian.PutUint32(sb[4:], 2048)    // s_blocks_count_lo
+	binary.LittleEndian.PutUint32(sb[24:], 2)      // s_log_block_size
+	binary.LittleEndian.PutUint16(sb[56:], 0xef53) // s_magic
+	binary.LittleEndian.PutUint32(sb[76:], 1)      // s_rev_level
+	binary.LittleEndian.PutUint32(sb[92:], 0x4)    // s_feature_compat: has_journal
+	binary.LittleEndian.PutUint32(sb[96:], 0x42)   // s_feature_incompat: filetype, extents
+	copy(sb[104:], "\x7e\x7f\x16\x0b\x0e\x79\x47\x8b\xb0\x06\x1e\xbc\x6d\x00\x50\xdd")
+}
+
+// writeLVM2Label writes the label of an LVM2 physical volume, which lies in the area
+// before the ext4 superblock, that is not used by ext4
+func writeLVM2Label(buf []byte) {
+	label := buf[lvm2LabelOffset : lvm2LabelOffset+512]
+	copy(label[0:], "LABELONE")
+	binary.LittleEndian.PutUint64(label[8:], 1)   // sector of the label
+	binary.LittleEndian.PutUint32(label[20:], 32) // offset of the pv header
+	copy(label[24:], "LVM2 001")
+	copy(label[32:], "Wq3vVd2nRcKx8Yt5Lm9Pz0Hs4Jf6Gb1E")
+	// the crc covers the label from the offset of the pv header, LVM2 uses the IEEE
+	// polynomial without the final inversion
+	crc := ^crc32.Update(^uint32(lvm2CRCInitial), crc32.IEEETable, label[20:])
+	binary.LittleEndian.PutUint32(label[16:], crc)
+}
+
+func TestGetOnDiskSignatures(t *testing.T) {
+	tests := map[string]struct {
+		write func(buf []byte)
+		want  []string
+	}{
+		"device with overlapping ext4 and LVM2 signatures": {
+			write: func(buf []byte) {
+				writeExt4Superblock(buf)
+				writeLVM2Label(buf)
+			},
+			want: []string{"LVM2_member", "ext4"},
+		},
+		"device with ext4": {
+			write: writeExt4Superblock,
+			want:  []string{"ext4"},
+		},
+		"device with LVM2 physical volume": {
+			write: writeLVM2Label,
+			want:  []string{"LVM2_member"},
+		},
+		"blank device": {
+			write: func(buf []byte) {},
+			want:  []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := make([]byte, deviceSize)
+			tt.write(buf)
+			path := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(path, buf, 0644); err != nil {
+				t.Fatal(err)
+			}
+			di := &DeviceIdentifier{DevPath: path}
+			got, err := di.GetOnDiskSignatures()
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+	t.Run("non existent device", func(t *testing.T) {
+		di := &DeviceIdentifier{DevPath: "/dev/non-existent-disk"}
+		_, err := di.GetOnDiskSignatures()
+		assert.Error(t, err)
+	})
+}
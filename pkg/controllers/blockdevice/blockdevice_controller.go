@@ -19,6 +19,8 @@ package blockdevice
 import (
 	"context"
 	util2 "github.com/openebs/node-disk-manager/pkg/controllers/util"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -42,6 +44,20 @@ type BlockDeviceReconciler struct {
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// ClaimStateHook, if set, is run whenever a BlockDevice moves to a new
+	// claim state. It is run asynchronously and never blocks reconciliation.
+	ClaimStateHook ClaimStateHook
+	// ClaimHookTimeout bounds a single run of the ClaimStateHook.
+	ClaimHookTimeout time.Duration
+
+	// claimStates caches the last observed claim state of each BlockDevice
+	// to detect transitions.
+	claimStatesMu sync.Mutex
+	claimStates   map[string]apis.DeviceClaimState
+	// claimHookQueue feeds the single worker that runs the ClaimStateHook.
+	claimHookOnce  sync.Once
+	claimHookQueue chan ClaimStateEvent
 }
 
 //+kubebuilder:rbac:groups=openebs.io,resources=blockdevices,verbs=get;list;watch;create;update;patch;delete
@@ -61,6 +77,7 @@ func (r *BlockDeviceReconciler) Reconcile(ctx context.Context, request ctrl.Requ
 			// Requested object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			r.forgetClaimState(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -72,6 +89,8 @@ func (r *BlockDeviceReconciler) Reconcile(ctx context.Context, request ctrl.Requ
 		return reconcile.Result{}, nil
 	}
 
+	r.observeClaimState(instance)
+
 	switch instance.Status.ClaimState {
 	case apis.BlockDeviceReleased:
 		klog.V(2).Infof("%s is in Released state", instance.Name)
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+)
+
+// DefaultClaimHookTimeout is the time a claim state hook is allowed to run
+// before it is cancelled.
+const DefaultClaimHookTimeout = 10 * time.Second
+
+// claimHookQueueSize is the number of pending hook runs. Transitions observed
+// while the queue is full are dropped.
+const claimHookQueueSize = 100
+
+// ClaimStateEvent identifies a BlockDevice whose claim state has changed.
+type ClaimStateEvent struct {
+	Name       string                `json:"name"`
+	Path       string                `json:"path"`
+	NodeName   string                `json:"nodeName"`
+	ClaimState apis.DeviceClaimState `json:"claimState"`
+}
+
+// ClaimStateHook is invoked after a BlockDevice transitions to a new claim
+// state. Hooks are run one at a time, in the order the transitions were
+// observed, outside the reconcile loop. Any error is only logged.
+type ClaimStateHook func(ctx context.Context, event ClaimStateEvent) error
+
+// NewExecClaimStateHook returns a hook that runs the given command. The
+// device identity and new state are passed to the command as environment
+// variables.
+func NewExecClaimStateHook(command string) ClaimStateHook {
+	return func(ctx context.Context, event ClaimStateEvent) error {
+		cmd := exec.CommandContext(ctx, command)
+		cmd.Env = append(os.Environ(),
+			"NDM_BLOCKDEVICE_NAME="+event.Name,
+			"NDM_BLOCKDEVICE_PATH="+event.Path,
+			"NDM_NODE_NAME="+event.NodeName,
+			"NDM_CLAIM_STATE="+string(event.ClaimState),
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command %s failed: %v, output: %s", command, err, out)
+		}
+		return nil
+	}
+}
+
+// NewWebhookClaimStateHook returns a hook that POSTs the event as JSON to the
+// given URL.
+func NewWebhookClaimStateHook(url string) ClaimStateHook {
+	return func(ctx context.Context, event ClaimStateEvent) error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook %s returned status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// observeClaimState compares the claim state of the BlockDevice with the one
+// seen in the previous reconcile and runs the claim state hook if it has
+// changed. The first observation of a BlockDevice only records its state, so
+// that restarting the operator does not replay hooks for every device.
+func (r *BlockDeviceReconciler) observeClaimState(bd *apis.BlockDevice) {
+	if r.ClaimStateHook == nil {
+		return
+	}
+
+	state := bd.Status.ClaimState
+	r.claimStatesMu.Lock()
+	if r.claimStates == nil {
+		r.claimStates = make(map[string]apis.DeviceClaimState)
+	}
+	previous, seen := r.claimStates[bd.Name]
+	r.claimStates[bd.Name] = state
+	r.claimStatesMu.Unlock()
+
+	if !seen || previous == state {
+		return
+	}
+
+	event := ClaimStateEvent{
+		Name:       bd.Name,
+		Path:       bd.Spec.Path,
+		NodeName:   bd.Spec.NodeAttributes.NodeName,
+		ClaimState: state,
+	}
+	klog.V(4).Infof("%s claim state changed from %s to %s, queueing hook", bd.Name, previous, state)
+	r.claimHookOnce.Do(func() {
+		r.claimHookQueue = make(chan ClaimStateEvent, claimHookQueueSize)
+		go r.runClaimStateHooks()
+	})
+	select {
+	case r.claimHookQueue <- event:
+	default:
+		klog.Warningf("claim state hook queue full, dropping %s transition to %s", bd.Name, state)
+	}
+}
+
+// runClaimStateHooks runs the claim state hook for each queued transition.
+func (r *BlockDeviceReconciler) runClaimStateHooks() {
+	timeout := r.ClaimHookTimeout
+	if timeout <= 0 {
+		timeout = DefaultClaimHookTimeout
+	}
+	for event := range r.claimHookQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := r.ClaimStateHook(ctx, event); err != nil {
+			klog.Errorf("claim state hook for %s failed: %v", event.Name, err)
+		}
+		cancel()
+	}
+}
+
+// forgetClaimState drops the cached claim state of a deleted BlockDevice.
+func (r *BlockDeviceReconciler) forgetClaimState(name string) {
+	r.claimStatesMu.Lock()
+	delete(r.claimStates, name)
+	r.claimStatesMu.Unlock()
+}
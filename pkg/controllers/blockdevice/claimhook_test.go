@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+)
+
+func TestClaimStateHookRunsOncePerTransition(t *testing.T) {
+	cl, s := CreateFakeClient(t)
+
+	events := make(chan ClaimStateEvent, 10)
+	r := &BlockDeviceReconciler{
+		Client:   cl,
+		Scheme:   s,
+		Recorder: fakeRecorder,
+		ClaimStateHook: func(ctx context.Context, event ClaimStateEvent) error {
+			events <- event
+			return nil
+		},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: deviceName, Namespace: namespace},
+	}
+
+	reconcileWithState := func(state apis.DeviceClaimState, times int) {
+		bd := &apis.BlockDevice{}
+		assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, bd))
+		bd.Status.ClaimState = state
+		assert.NoError(t, cl.Update(context.TODO(), bd))
+		for i := 0; i < times; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			assert.NoError(t, err)
+		}
+	}
+
+	// first observation only populates the cache
+	reconcileWithState(apis.BlockDeviceUnclaimed, 3)
+	reconcileWithState(apis.BlockDeviceClaimed, 3)
+	reconcileWithState(apis.BlockDeviceClaimed, 2)
+	reconcileWithState(apis.BlockDeviceUnclaimed, 3)
+
+	var got []apis.DeviceClaimState
+	for len(got) < 2 {
+		select {
+		case e := <-events:
+			assert.Equal(t, deviceName, e.Name)
+			assert.Equal(t, "dev/disk-fake-path", e.Path)
+			got = append(got, e.ClaimState)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for hook, got %v", got)
+		}
+	}
+	assert.Equal(t, []apis.DeviceClaimState{apis.BlockDeviceClaimed, apis.BlockDeviceUnclaimed}, got)
+
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected extra hook run: %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClaimStateHookNotRunOnFirstObservation(t *testing.T) {
+	cl, s := CreateFakeClient(t)
+
+	calls := make(chan struct{}, 1)
+	r := &BlockDeviceReconciler{
+		Client:   cl,
+		Scheme:   s,
+		Recorder: fakeRecorder,
+		ClaimStateHook: func(ctx context.Context, event ClaimStateEvent) error {
+			calls <- struct{}{}
+			return nil
+		},
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: deviceName, Namespace: namespace},
+	}
+	_, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+
+	select {
+	case <-calls:
+		t.Fatal("hook should not run on the first observation of a BlockDevice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
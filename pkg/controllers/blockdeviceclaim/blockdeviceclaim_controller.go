@@ -20,6 +20,7 @@ import (
 
 	"context"
 	"fmt"
+	"time"
 
 	util2 "github.com/openebs/node-disk-manager/pkg/controllers/util"
 
@@ -49,6 +50,13 @@ type BlockDeviceClaimReconciler struct {
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// ClaimValidationHook, if set, is consulted before a BlockDevice is bound to a
+	// claim, so that an external policy can reject devices not meeting it.
+	ClaimValidationHook ClaimValidationHook
+	// ClaimValidationTimeout is the time the hook is allowed to take for validating the
+	// candidates of a claim.
+	ClaimValidationTimeout time.Duration
 }
 
 //+kubebuilder:rbac:groups=openebs.io,resources=blockdeviceclaims,verbs=get;list;watch;create;update;patch;delete
@@ -154,7 +162,7 @@ func (r *BlockDeviceClaimReconciler) claimDeviceForBlockDeviceClaim(instance *ap
 		return err
 	}
 
-	selectedDevice, err := config.Filter(bdList)
+	selectedDevice, err := r.selectBlockDevice(instance, config, bdList)
 	if err != nil {
 		klog.Errorf("Error selecting device for %s: %v", instance.Name, err)
 		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "SelectionFailed", err.Error())
@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdeviceclaim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	ndm "github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+	"github.com/openebs/node-disk-manager/pkg/select/blockdevice"
+	"github.com/openebs/node-disk-manager/pkg/select/verify"
+)
+
+// DefaultClaimValidationTimeout is the time a claim validation hook is allowed to
+// take for validating the candidates of a claim before the validation is considered failed.
+const DefaultClaimValidationTimeout = 10 * time.Second
+
+// ClaimAttributes are the attributes of the BlockDeviceClaim sent for validation.
+type ClaimAttributes struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	HostName   string `json:"hostName,omitempty"`
+	DeviceType string `json:"deviceType,omitempty"`
+	// Capacity is the requested capacity in bytes. It is 0 for manual selection.
+	Capacity int64 `json:"capacity,omitempty"`
+}
+
+// ClaimDeviceAttributes are the assessed attributes of the BlockDevice selected for a
+// claim, which an external policy can use to validate the claim.
+type ClaimDeviceAttributes struct {
+	Name       string `json:"name"`
+	NodeName   string `json:"nodeName"`
+	HostName   string `json:"hostName"`
+	Path       string `json:"path"`
+	Capacity   uint64 `json:"capacity"`
+	DeviceType string `json:"deviceType"`
+	DriveType  string `json:"driveType"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Vendor     string `json:"vendor,omitempty"`
+	FileSystem string `json:"fileSystem,omitempty"`
+	MountPoint string `json:"mountPoint,omitempty"`
+	// Transport is the fabric transport of the device, empty for local devices
+	Transport string `json:"transport,omitempty"`
+	// State and Conditions are the health of the device as observed by NDM
+	State      apis.BlockDeviceState       `json:"state"`
+	Conditions []apis.BlockDeviceCondition `json:"conditions,omitempty"`
+	Labels     map[string]string           `json:"labels,omitempty"`
+}
+
+// ClaimValidationRequest is sent to the claim validation hook before a BlockDevice
+// is bound to a BlockDeviceClaim. All the devices matching the claim are sent at once,
+// in the order in which they would be bound.
+type ClaimValidationRequest struct {
+	Claim   ClaimAttributes         `json:"claim"`
+	Devices []ClaimDeviceAttributes `json:"devices"`
+}
+
+// ClaimValidationResponse is the decision of the claim validation hook for each of the
+// devices in the request. A device without a verdict is not allowed.
+type ClaimValidationResponse struct {
+	Devices []ClaimDeviceVerdict `json:"devices"`
+}
+
+// ClaimDeviceVerdict is the decision of the claim validation hook for a device
+type ClaimDeviceVerdict struct {
+	Name    string `json:"name"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ClaimValidationHook is consulted once per reconcile of a claim, with all the devices
+// matching the claim. The first allowed device is bound to the claim. An error fails
+// the selection, and the claim stays Pending till the next reconcile.
+type ClaimValidationHook func(ctx context.Context, request ClaimValidationRequest) (ClaimValidationResponse, error)
+
+// NewWebhookClaimValidationHook returns a hook that POSTs the request as JSON to the
+// given URL and expects a ClaimValidationResponse in the body.
+func NewWebhookClaimValidationHook(url string) ClaimValidationHook {
+	return func(ctx context.Context, request ClaimValidationRequest) (ClaimValidationResponse, error) {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return ClaimValidationResponse{}, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return ClaimValidationResponse{}, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return ClaimValidationResponse{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return ClaimValidationResponse{}, fmt.Errorf("webhook %s returned status %d", url, resp.StatusCode)
+		}
+		response := ClaimValidationResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return ClaimValidationResponse{}, fmt.Errorf("invalid response from webhook %s: %v", url, err)
+		}
+		return response, nil
+	}
+}
+
+// getClaimDeviceAttributes gets the attributes of the BlockDevice sent for validation.
+func getClaimDeviceAttributes(bd *apis.BlockDevice) ClaimDeviceAttributes {
+	return ClaimDeviceAttributes{
+		Name:       bd.Name,
+		NodeName:   bd.Spec.NodeAttributes.NodeName,
+		HostName:   bd.Labels[ndm.KubernetesHostNameLabel],
+		Path:       bd.Spec.Path,
+		Capacity:   bd.Spec.Capacity.Storage,
+		DeviceType: bd.Spec.Details.DeviceType,
+		DriveType:  bd.Spec.Details.DriveType,
+		Model:      bd.Spec.Details.Model,
+		Serial:     bd.Spec.Details.Serial,
+		Vendor:     bd.Spec.Details.Vendor,
+		FileSystem: bd.Spec.FileSystem.Type,
+		MountPoint: bd.Spec.FileSystem.Mountpoint,
+		Transport:  bd.Labels[ndm.NDMTransportKey],
+		State:      bd.Status.State,
+		Conditions: bd.Status.Conditions,
+		Labels:     bd.Labels,
+	}
+}
+
+// getMissingClaimAttributes gets the attributes of the BlockDevice, required for
+// validating a claim, that are not filled. A policy cannot be evaluated reliably
+// against such a device, so it is not bound when claims are validated.
+func getMissingClaimAttributes(attributes ClaimDeviceAttributes) []string {
+	var missing []string
+	required := []struct {
+		field  string
+		filled bool
+	}{
+		{"spec.nodeAttributes.nodeName", attributes.NodeName != ""},
+		{"metadata.labels[" + ndm.KubernetesHostNameLabel + "]", attributes.HostName != ""},
+		{"spec.path", attributes.Path != ""},
+		{"spec.capacity.storage", attributes.Capacity != 0},
+		{"spec.details.deviceType", attributes.DeviceType != ""},
+		{"spec.details.driveType", attributes.DriveType != ""},
+		{"status.state", attributes.State != ""},
+	}
+	for _, r := range required {
+		if !r.filled {
+			missing = append(missing, r.field)
+		}
+	}
+	return missing
+}
+
+// getVerdict gets the verdict of the hook for the device. A device without a verdict
+// is not allowed.
+func (response ClaimValidationResponse) getVerdict(name string) ClaimDeviceVerdict {
+	for _, verdict := range response.Devices {
+		if verdict.Name == name {
+			return verdict
+		}
+	}
+	return ClaimDeviceVerdict{Name: name, Allowed: false, Reason: "no verdict from claim validation"}
+}
+
+// validateClaim checks which of the candidate BlockDevices can be bound to the claim,
+// first for the completeness of their attributes and then with a single call to the
+// claim validation hook. The verdicts are in the order of the candidates.
+func (r *BlockDeviceClaimReconciler) validateClaim(instance *apis.BlockDeviceClaim,
+	candidates *apis.BlockDeviceList) ([]ClaimDeviceVerdict, error) {
+	verdicts := make([]ClaimDeviceVerdict, len(candidates.Items))
+	var devices []ClaimDeviceAttributes
+	for i := range candidates.Items {
+		device := getClaimDeviceAttributes(&candidates.Items[i])
+		if missing := getMissingClaimAttributes(device); len(missing) > 0 {
+			verdicts[i] = ClaimDeviceVerdict{
+				Name:    device.Name,
+				Allowed: false,
+				Reason:  "missing attributes: " + strings.Join(missing, ", "),
+			}
+			continue
+		}
+		devices = append(devices, device)
+	}
+	if len(devices) == 0 {
+		return verdicts, nil
+	}
+
+	// capacity is not requested for manual selection, so the error is ignored
+	capacity, _ := verify.GetRequestedCapacity(instance.Spec.Resources.Requests)
+	request := ClaimValidationRequest{
+		Claim: ClaimAttributes{
+			Name:       instance.Name,
+			Namespace:  instance.Namespace,
+			HostName:   instance.Spec.HostName,
+			DeviceType: instance.Spec.DeviceType,
+			Capacity:   capacity,
+		},
+		Devices: devices,
+	}
+
+	timeout := r.ClaimValidationTimeout
+	if timeout <= 0 {
+		timeout = DefaultClaimValidationTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	response, err := r.ClaimValidationHook(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates.Items {
+		if verdicts[i].Name == "" {
+			verdicts[i] = response.getVerdict(candidates.Items[i].Name)
+		}
+	}
+	return verdicts, nil
+}
+
+// selectBlockDevice selects a BlockDevice for the claim from the list. If a claim
+// validation hook is set, all the devices matching the claim are validated at once, and
+// the first allowed device is selected.
+func (r *BlockDeviceClaimReconciler) selectBlockDevice(instance *apis.BlockDeviceClaim, config *blockdevice.Config,
+	bdList *apis.BlockDeviceList) (*apis.BlockDevice, error) {
+	if r.ClaimValidationHook == nil {
+		return config.Filter(bdList)
+	}
+
+	candidates, err := config.FilterAll(bdList)
+	if err != nil {
+		return nil, err
+	}
+	verdicts, err := r.validateClaim(instance, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("error validating claim of %d blockdevices: %v", len(candidates.Items), err)
+	}
+
+	for i, verdict := range verdicts {
+		if verdict.Allowed {
+			return &candidates.Items[i], nil
+		}
+		klog.Infof("%s rejected for %s by claim validation: %s", verdict.Name, instance.Name, verdict.Reason)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "ClaimValidationFailed",
+			"BlockDevice %s rejected: %s", verdict.Name, verdict.Reason)
+	}
+	return nil, fmt.Errorf("%d blockdevices rejected by claim validation", len(verdicts))
+}
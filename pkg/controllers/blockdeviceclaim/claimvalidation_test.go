@@ -0,0 +1,259 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdeviceclaim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	ndm "github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
+)
+
+// getFakeClaimableDevice gets a BlockDevice having all the attributes required for
+// validating a claim
+func getFakeClaimableDevice(name string) *apis.BlockDevice {
+	bd := GetFakeDeviceObject(name, capacity*10)
+	bd.Labels[ndm.KubernetesHostNameLabel] = fakeHostName
+	bd.Spec.NodeAttributes.NodeName = fakeHostName
+	bd.Spec.Details.DeviceType = ndm.NDMDefaultDeviceType
+	bd.Spec.Details.DriveType = "SSD"
+	return bd
+}
+
+func TestGetMissingClaimAttributes(t *testing.T) {
+	tests := map[string]struct {
+		modify      func(bd *apis.BlockDevice)
+		wantMissing []string
+	}{
+		"all attributes filled": {
+			modify: func(bd *apis.BlockDevice) {},
+		},
+		"node name missing": {
+			modify:      func(bd *apis.BlockDevice) { bd.Spec.NodeAttributes.NodeName = "" },
+			wantMissing: []string{"spec.nodeAttributes.nodeName"},
+		},
+		"hostname label missing": {
+			modify:      func(bd *apis.BlockDevice) { delete(bd.Labels, ndm.KubernetesHostNameLabel) },
+			wantMissing: []string{"metadata.labels[kubernetes.io/hostname]"},
+		},
+		"capacity and drive type missing": {
+			modify: func(bd *apis.BlockDevice) {
+				bd.Spec.Capacity.Storage = 0
+				bd.Spec.Details.DriveType = ""
+			},
+			wantMissing: []string{"spec.capacity.storage", "spec.details.driveType"},
+		},
+		"path, device type and state missing": {
+			modify: func(bd *apis.BlockDevice) {
+				bd.Spec.Path = ""
+				bd.Spec.Details.DeviceType = ""
+				bd.Status.State = ""
+			},
+			wantMissing: []string{"spec.path", "spec.details.deviceType", "status.state"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bd := getFakeClaimableDevice("bd-1")
+			test.modify(bd)
+			assert.Equal(t, test.wantMissing, getMissingClaimAttributes(getClaimDeviceAttributes(bd)))
+		})
+	}
+}
+
+func TestGetClaimDeviceAttributes(t *testing.T) {
+	bd := getFakeClaimableDevice("bd-1")
+	bd.Labels[ndm.NDMTransportKey] = "nvme-tcp"
+	bd.Spec.FileSystem.Type = "ext4"
+	bd.Spec.FileSystem.Mountpoint = "/mnt/data"
+	bd.Status.Conditions = []apis.BlockDeviceCondition{
+		{Type: apis.BlockDeviceOverTemperature, Status: "True"},
+	}
+
+	got := getClaimDeviceAttributes(bd)
+	assert.Equal(t, "nvme-tcp", got.Transport)
+	assert.Equal(t, "ext4", got.FileSystem)
+	assert.Equal(t, "/mnt/data", got.MountPoint)
+	assert.Equal(t, fakeHostName, got.HostName)
+	assert.Equal(t, bd.Status.Conditions, got.Conditions)
+	assert.Equal(t, apis.BlockDeviceState(ndm.NDMActive), got.State)
+}
+
+func TestWebhookClaimValidationHook(t *testing.T) {
+	var received ClaimValidationRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&received))
+		response := ClaimValidationResponse{}
+		for _, device := range received.Devices {
+			verdict := ClaimDeviceVerdict{Name: device.Name, Allowed: true}
+			if device.DriveType != "SSD" {
+				verdict = ClaimDeviceVerdict{Name: device.Name, Allowed: false, Reason: "only SSDs are allowed"}
+			}
+			response.Devices = append(response.Devices, verdict)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	hook := NewWebhookClaimValidationHook(server.URL)
+
+	hdd := getClaimDeviceAttributes(getFakeClaimableDevice("bd-2"))
+	hdd.DriveType = "HDD"
+	request := ClaimValidationRequest{
+		Claim: ClaimAttributes{Name: blockDeviceClaimName, Capacity: 1024},
+		Devices: []ClaimDeviceAttributes{
+			getClaimDeviceAttributes(getFakeClaimableDevice("bd-1")),
+			hdd,
+		},
+	}
+	response, err := hook(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, request, received)
+	assert.True(t, response.getVerdict("bd-1").Allowed)
+	assert.False(t, response.getVerdict("bd-2").Allowed)
+	assert.Equal(t, "only SSDs are allowed", response.getVerdict("bd-2").Reason)
+	assert.False(t, response.getVerdict("bd-3").Allowed)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	_, err = NewWebhookClaimValidationHook(failing.URL)(context.TODO(), request)
+	assert.Error(t, err)
+}
+
+func TestClaimValidation(t *testing.T) {
+	tests := map[string]struct {
+		devices []*apis.BlockDevice
+		// rejected are the devices rejected by the hook
+		rejected map[string]bool
+		// withoutVerdict are the devices left out of the response of the hook
+		withoutVerdict map[string]bool
+		hookErr        error
+		wantPhase      apis.DeviceClaimPhase
+		wantBD         string
+		// wantValidated are the devices sent to the hook in each call
+		wantValidated [][]string
+	}{
+		"device allowed": {
+			devices:       []*apis.BlockDevice{getFakeClaimableDevice("bd-1")},
+			wantPhase:     apis.BlockDeviceClaimStatusDone,
+			wantBD:        "bd-1",
+			wantValidated: [][]string{{"bd-1"}},
+		},
+		"rejected device is skipped": {
+			devices:       []*apis.BlockDevice{getFakeClaimableDevice("bd-1"), getFakeClaimableDevice("bd-2")},
+			rejected:      map[string]bool{"bd-1": true},
+			wantPhase:     apis.BlockDeviceClaimStatusDone,
+			wantBD:        "bd-2",
+			wantValidated: [][]string{{"bd-1", "bd-2"}},
+		},
+		"device without a verdict is skipped": {
+			devices:        []*apis.BlockDevice{getFakeClaimableDevice("bd-1"), getFakeClaimableDevice("bd-2")},
+			withoutVerdict: map[string]bool{"bd-1": true},
+			wantPhase:      apis.BlockDeviceClaimStatusDone,
+			wantBD:         "bd-2",
+			wantValidated:  [][]string{{"bd-1", "bd-2"}},
+		},
+		"all devices rejected": {
+			devices:       []*apis.BlockDevice{getFakeClaimableDevice("bd-1")},
+			rejected:      map[string]bool{"bd-1": true},
+			wantPhase:     apis.BlockDeviceClaimStatusPending,
+			wantValidated: [][]string{{"bd-1"}},
+		},
+		"device with incomplete attributes is not validated": {
+			devices: func() []*apis.BlockDevice {
+				incomplete := getFakeClaimableDevice("bd-1")
+				incomplete.Spec.Details.DriveType = ""
+				return []*apis.BlockDevice{incomplete, getFakeClaimableDevice("bd-2")}
+			}(),
+			wantPhase:     apis.BlockDeviceClaimStatusDone,
+			wantBD:        "bd-2",
+			wantValidated: [][]string{{"bd-2"}},
+		},
+		"hook is not called if no device has complete attributes": {
+			devices: func() []*apis.BlockDevice {
+				incomplete := getFakeClaimableDevice("bd-1")
+				incomplete.Spec.Details.DriveType = ""
+				return []*apis.BlockDevice{incomplete}
+			}(),
+			wantPhase: apis.BlockDeviceClaimStatusPending,
+		},
+		"hook failure": {
+			devices:       []*apis.BlockDevice{getFakeClaimableDevice("bd-1")},
+			hookErr:       assert.AnError,
+			wantPhase:     apis.BlockDeviceClaimStatusPending,
+			wantValidated: [][]string{{"bd-1"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cl, s := CreateFakeClient()
+			var validated [][]string
+			r := &BlockDeviceClaimReconciler{
+				Client:   cl,
+				Scheme:   s,
+				Recorder: record.NewFakeRecorder(10),
+				ClaimValidationHook: func(ctx context.Context, request ClaimValidationRequest) (ClaimValidationResponse, error) {
+					var names []string
+					for _, device := range request.Devices {
+						names = append(names, device.Name)
+					}
+					validated = append(validated, names)
+					if test.hookErr != nil {
+						return ClaimValidationResponse{}, test.hookErr
+					}
+					response := ClaimValidationResponse{}
+					for _, name := range names {
+						if test.withoutVerdict[name] {
+							continue
+						}
+						verdict := ClaimDeviceVerdict{Name: name, Allowed: true}
+						if test.rejected[name] {
+							verdict = ClaimDeviceVerdict{Name: name, Allowed: false, Reason: "rejected by policy"}
+						}
+						response.Devices = append(response.Devices, verdict)
+					}
+					return response, nil
+				},
+			}
+			for _, bd := range test.devices {
+				assert.NoError(t, cl.Create(context.TODO(), bd))
+			}
+			assert.NoError(t, cl.Create(context.TODO(), GetFakeBlockDeviceClaimObject()))
+
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: blockDeviceClaimName, Namespace: namespace},
+			}
+			_, _ = r.Reconcile(context.TODO(), req)
+
+			bdc := &apis.BlockDeviceClaim{}
+			assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, bdc))
+			assert.Equal(t, test.wantPhase, bdc.Status.Phase)
+			assert.Equal(t, test.wantBD, bdc.Spec.BlockDeviceName)
+			assert.Equal(t, test.wantValidated, validated)
+		})
+	}
+}
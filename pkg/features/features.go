@@ -56,6 +56,13 @@ const (
 	// https://github.com/openebs/node-disk-manager/issues/621 .
 	// This feature must enabled with GPTBasedUUID.
 	PartitionTableUUID Feature = "PartitionTableUUID"
+
+	// VirtioBusAddressUUID feature flag is used to generate the uuid of the virtio
+	// disks without a serial from the PCI address at which they are attached to the
+	// virtual machine and their size. The uuid changes if the disk is hotplugged at
+	// another address, hence it is opt-in.
+	// This feature must be enabled with GPTBasedUUID.
+	VirtioBusAddressUUID Feature = "VirtioBusAddressUUID"
 )
 
 // supportedFeatures is the list of supported features. This is used while parsing the
@@ -66,21 +73,26 @@ var supportedFeatures = []Feature{
 	UseOSDisk,
 	ChangeDetection,
 	PartitionTableUUID,
+	VirtioBusAddressUUID,
 }
 
 // defaultFeatureGates is the default features that will be applied to the application
 var defaultFeatureGates = map[Feature]bool{
-	GPTBasedUUID:       true,
-	APIService:         false,
-	UseOSDisk:          false,
-	ChangeDetection:    false,
-	PartitionTableUUID: false,
+	GPTBasedUUID:         true,
+	APIService:           false,
+	UseOSDisk:            false,
+	ChangeDetection:      false,
+	PartitionTableUUID:   false,
+	VirtioBusAddressUUID: false,
 }
 
 var featureDependencies = map[Feature][]Feature{
 	PartitionTableUUID: {
 		GPTBasedUUID,
 	},
+	VirtioBusAddressUUID: {
+		GPTBasedUUID,
+	},
 }
 
 // featureFlag is a map representing the flag and its state
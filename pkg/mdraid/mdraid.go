@@ -0,0 +1,284 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mdraid detects the linux md (software RAID) superblock on a device, so that
+// the members of an array are detected even when the array is not assembled.
+package mdraid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// superblockMagic is the magic number at the start of all md superblocks
+	superblockMagic = 0xa92b4efc
+
+	// sectorSize is the unit of the offsets in the version 1 superblock
+	sectorSize = 512
+
+	// reservedSize090 is the size of the area reserved at the end of the device for
+	// the version 0.90 superblock. The superblock is at the start of the last 64KiB
+	// aligned block of the device
+	reservedSize090 = 64 * 1024
+
+	// superblockSize090 is the size of the version 0.90 superblock, the descriptor
+	// of the device itself is at the end of the superblock
+	superblockSize090 = 4096
+
+	// superblockSize1 is the size of the fixed part of the version 1 superblock,
+	// followed by the roles of all the devices in the array
+	superblockSize1 = 256
+
+	// offset of the fields in the version 1 superblock
+	setUUIDOffset1     = 16
+	setNameOffset1     = 32
+	setNameSize1       = 32
+	superOffsetOffset1 = 144
+	devNumberOffset1   = 160
+	maxDevOffset1      = 220
+
+	// roles of the devices in the version 1 superblock, any other value is the slot
+	// of an active device in the array
+	roleSpare1   = 0xffff
+	roleFaulty1  = 0xfffe
+	roleJournal1 = 0xfffd
+
+	// word offsets in the version 0.90 superblock
+	raidDisksWord090 = 10
+	thisDiskWord090  = 992
+
+	// word offsets in the disk descriptor of the version 0.90 superblock
+	raidDiskWord090 = 3
+	stateWord090    = 4
+
+	// bits of the state in the disk descriptor of the version 0.90 superblock
+	stateFaulty090 = 1 << 0
+)
+
+// Version of the md superblock
+const (
+	Version090 = "0.90"
+	Version10  = "1.0"
+	Version11  = "1.1"
+	Version12  = "1.2"
+)
+
+// Role of the device in the md array
+const (
+	// RoleActive is a device holding the data of the array
+	RoleActive = "active"
+
+	// RoleSpare is a device reserved as a spare, which is used to rebuild the array
+	// when an active device fails
+	RoleSpare = "spare"
+
+	// RoleFaulty is a device marked as failed in the array
+	RoleFaulty = "faulty"
+
+	// RoleJournal is a device used as the write journal of the array
+	RoleJournal = "journal"
+)
+
+// Superblock is the md superblock on a member device of an array
+type Superblock struct {
+	// Version is the version of the superblock, which also decides the location of
+	// the superblock on the device
+	Version string
+
+	// ArrayUUID is the uuid of the array, in the format used by mdadm
+	ArrayUUID string
+
+	// Name is the name of the array, only present in version 1 superblocks
+	Name string
+
+	// Role is the role of the device in the array, eg: a spare
+	Role string
+}
+
+// GetSuperblock reads the md superblock from the device. All the locations of the
+// superblock are checked, as the version is not known. nil is returned if the device
+// is not a member of any md array.
+func GetSuperblock(devPath string) (*Superblock, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the size of block devices is not available from stat
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting size of %s: %v", devPath, err)
+	}
+	sb, err := ReadSuperblock(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("error reading md superblock from %s: %v", devPath, err)
+	}
+	return sb, nil
+}
+
+// ReadSuperblock reads the md superblock from a device of the given size
+func ReadSuperblock(r io.ReaderAt, size int64) (*Superblock, error) {
+	// version 1.1 at the start, version 1.2 at 4KiB from the start and version 1.0
+	// at least 8KiB from the end of the device, aligned to 4KiB
+	offsets := []struct {
+		version string
+		sector  int64
+	}{
+		{Version11, 0},
+		{Version12, 8},
+		{Version10, ((size / sectorSize) - 8*2) &^ (4*2 - 1)},
+	}
+	for _, o := range offsets {
+		if o.sector < 0 {
+			continue
+		}
+		buf, err := readAt(r, o.sector*sectorSize, superblockSize1, size)
+		if err != nil {
+			return nil, err
+		}
+		if sb, ok := parseSuperblock1(buf, o.sector); ok {
+			sb.Version = o.version
+			if sb.Role, err = readRole1(r, buf, o.sector, size); err != nil {
+				return nil, err
+			}
+			return sb, nil
+		}
+	}
+
+	offset090 := (size &^ (reservedSize090 - 1)) - reservedSize090
+	if offset090 < 0 {
+		return nil, nil
+	}
+	buf, err := readAt(r, offset090, superblockSize090, size)
+	if err != nil {
+		return nil, err
+	}
+	if sb, ok := parseSuperblock090(buf); ok {
+		return sb, nil
+	}
+	return nil, nil
+}
+
+// readAt reads n bytes at the offset. nil is returned if the device is too small
+// to have the data at the offset.
+func readAt(r io.ReaderAt, offset int64, n int, size int64) ([]byte, error) {
+	if offset+int64(n) > size {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readRole1 reads the role of the device from the table of roles following the
+// version 1 superblock. The device is considered active if its role is not in the
+// table, as it is still a member of the array.
+func readRole1(r io.ReaderAt, buf []byte, sector int64, size int64) (string, error) {
+	devNumber := binary.LittleEndian.Uint32(buf[devNumberOffset1 : devNumberOffset1+4])
+	maxDev := binary.LittleEndian.Uint32(buf[maxDevOffset1 : maxDevOffset1+4])
+	if devNumber >= maxDev {
+		return RoleActive, nil
+	}
+	role, err := readAt(r, sector*sectorSize+superblockSize1+int64(devNumber)*2, 2, size)
+	if err != nil || role == nil {
+		return RoleActive, err
+	}
+	switch binary.LittleEndian.Uint16(role) {
+	case roleSpare1:
+		return RoleSpare, nil
+	case roleFaulty1:
+		return RoleFaulty, nil
+	case roleJournal1:
+		return RoleJournal, nil
+	default:
+		return RoleActive, nil
+	}
+}
+
+// parseSuperblock1 parses the version 1 superblock read from the given sector. The
+// superblock has the sector at which it was written, so that a superblock of a
+// partition is not mistaken for that of the disk.
+func parseSuperblock1(buf []byte, sector int64) (*Superblock, bool) {
+	if len(buf) < superblockSize1 ||
+		binary.LittleEndian.Uint32(buf[0:4]) != superblockMagic ||
+		binary.LittleEndian.Uint32(buf[4:8]) != 1 {
+		return nil, false
+	}
+	if binary.LittleEndian.Uint64(buf[superOffsetOffset1:superOffsetOffset1+8]) != uint64(sector) {
+		return nil, false
+	}
+
+	uuid := buf[setUUIDOffset1 : setUUIDOffset1+16]
+	words := make([]string, 0, 4)
+	for i := 0; i < len(uuid); i += 4 {
+		words = append(words, fmt.Sprintf("%x", uuid[i:i+4]))
+	}
+	name := buf[setNameOffset1 : setNameOffset1+setNameSize1]
+	return &Superblock{
+		ArrayUUID: strings.Join(words, ":"),
+		Name:      strings.TrimRight(string(name), "\x00"),
+	}, true
+}
+
+// parseSuperblock090 parses the version 0.90 superblock, which is written in the byte
+// order of the host that created the array
+func parseSuperblock090(buf []byte) (*Superblock, bool) {
+	if len(buf) < superblockSize090 {
+		return nil, false
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(buf[0:4]) == superblockMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(buf[0:4]) == superblockMagic:
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if order.Uint32(buf[4:8]) != 0 || order.Uint32(buf[8:12]) != 90 {
+		return nil, false
+	}
+
+	word := func(i int) uint32 {
+		return order.Uint32(buf[i*4 : i*4+4])
+	}
+	// a spare is not in any of the slots of the array, as it is only added to the
+	// array when an active device fails
+	role := RoleActive
+	switch {
+	case word(thisDiskWord090+stateWord090)&stateFaulty090 != 0:
+		role = RoleFaulty
+	case word(thisDiskWord090+raidDiskWord090) >= word(raidDisksWord090):
+		role = RoleSpare
+	}
+	return &Superblock{
+		Version:   Version090,
+		ArrayUUID: fmt.Sprintf("%08x:%08x:%08x:%08x", word(5), word(13), word(14), word(15)),
+		Role:      role,
+	}, true
+}
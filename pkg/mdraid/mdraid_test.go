@@ -0,0 +1,244 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mdraid
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// superblock12 is the start of the version 1.2 superblock of a member of the raid1
+// array node1:md0, written at sector 8 of the member
+var superblock12 = mustDecodeHex("" +
+	"fc4e2ba90100000000000000000000003b6f6a8e1c2d4e5f" +
+	"a0b1c2d3e4f506176e6f6465313a6d643000000000000000" +
+	"0000000000000000000000000000000000f1536500000000" +
+	"010000000000000000f83f01000000000000000002000000" +
+	"000000000000000000000000000000000000000000000000" +
+	"0000000000000000000800000000000000f83f0100000000" +
+	"08000000000000000000000000000000")
+
+// superblock090 is the version 0.90 superblock of a member of a raid1 array, created
+// on a little endian host
+var superblock090 = mustDecodeHex("" +
+	"fc4e2ba9000000005a00000000000000000000002b3a1f5d" +
+	"00f1536501000000c0ff9f00020000000200000000000000" +
+	"000000005d6f7e8c18293a4bd4e5f607")
+
+const (
+	// deviceSize is the size of the device images used in the tests
+	deviceSize = 10 * 1024 * 1024
+
+	// uuid of the arrays in the fixtures
+	superblock12UUID  = "3b6f6a8e:1c2d4e5f:a0b1c2d3:e4f50617"
+	superblock090UUID = "5d1f3a2b:8c7e6f5d:4b3a2918:07f6e5d4"
+)
+
+func mustDecodeHex(s string) []byte {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// withSuperOffset returns a copy of the version 1 superblock, having the given
+// sector as the offset of the superblock
+func withSuperOffset(sb []byte, sector uint64) []byte {
+	buf := append([]byte(nil), sb...)
+	binary.LittleEndian.PutUint64(buf[superOffsetOffset1:], sector)
+	return buf
+}
+
+// withRole returns a copy of the version 1 superblock of the second device of the
+// array, followed by the table of roles having the given role for the device
+func withRole(sb []byte, role uint16) []byte {
+	buf := make([]byte, superblockSize1+2*2)
+	copy(buf, sb)
+	binary.LittleEndian.PutUint32(buf[devNumberOffset1:], 1)
+	binary.LittleEndian.PutUint32(buf[maxDevOffset1:], 2)
+	binary.LittleEndian.PutUint16(buf[superblockSize1+2:], role)
+	return buf
+}
+
+// withThisDisk returns a copy of the version 0.90 superblock, having the given slot
+// and state in the descriptor of the device
+func withThisDisk(sb []byte, raidDisk, state uint32) []byte {
+	buf := make([]byte, superblockSize090)
+	copy(buf, sb)
+	binary.LittleEndian.PutUint32(buf[(thisDiskWord090+raidDiskWord090)*4:], raidDisk)
+	binary.LittleEndian.PutUint32(buf[(thisDiskWord090+stateWord090)*4:], state)
+	return buf
+}
+
+// bigEndian returns a copy of the version 0.90 superblock as written by a big endian host
+func bigEndian(sb []byte) []byte {
+	buf := make([]byte, len(sb))
+	for i := 0; i+4 <= len(sb); i += 4 {
+		binary.BigEndian.PutUint32(buf[i:], binary.LittleEndian.Uint32(sb[i:]))
+	}
+	return buf
+}
+
+// writeDevice creates a device image having the data at the given offset
+func writeDevice(t *testing.T, size, offset int64, data []byte) string {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(data, offset); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetSuperblock(t *testing.T) {
+	offset10 := int64((deviceSize/sectorSize-16)&^7) * sectorSize
+	offset090 := int64(deviceSize - reservedSize090)
+
+	tests := map[string]struct {
+		size   int64
+		offset int64
+		data   []byte
+		want   *Superblock
+	}{
+		"version 1.2 superblock": {
+			size:   deviceSize,
+			offset: 8 * sectorSize,
+			data:   superblock12,
+			want:   &Superblock{Version: Version12, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleActive},
+		},
+		"version 1.1 superblock": {
+			size:   deviceSize,
+			offset: 0,
+			data:   withSuperOffset(superblock12, 0),
+			want:   &Superblock{Version: Version11, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleActive},
+		},
+		"version 1.0 superblock": {
+			size:   deviceSize,
+			offset: offset10,
+			data:   withSuperOffset(superblock12, uint64(offset10/sectorSize)),
+			want:   &Superblock{Version: Version10, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleActive},
+		},
+		"version 1.2 superblock of a spare": {
+			size:   deviceSize,
+			offset: 8 * sectorSize,
+			data:   withRole(superblock12, roleSpare1),
+			want:   &Superblock{Version: Version12, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleSpare},
+		},
+		"version 1.2 superblock of a faulty device": {
+			size:   deviceSize,
+			offset: 8 * sectorSize,
+			data:   withRole(superblock12, roleFaulty1),
+			want:   &Superblock{Version: Version12, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleFaulty},
+		},
+		"version 1.2 superblock of a journal device": {
+			size:   deviceSize,
+			offset: 8 * sectorSize,
+			data:   withRole(superblock12, roleJournal1),
+			want:   &Superblock{Version: Version12, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleJournal},
+		},
+		"version 1.2 superblock of an active device in the second slot": {
+			size:   deviceSize,
+			offset: 8 * sectorSize,
+			data:   withRole(superblock12, 1),
+			want:   &Superblock{Version: Version12, ArrayUUID: superblock12UUID, Name: "node1:md0", Role: RoleActive},
+		},
+		"version 1 superblock not at its own offset": {
+			size:   deviceSize,
+			offset: 0,
+			data:   superblock12,
+			want:   nil,
+		},
+		"version 0.90 superblock": {
+			size:   deviceSize,
+			offset: offset090,
+			data:   superblock090,
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleActive},
+		},
+		"version 0.90 superblock of a big endian host": {
+			size:   deviceSize,
+			offset: offset090,
+			data:   bigEndian(superblock090),
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleActive},
+		},
+		"version 0.90 superblock of a spare": {
+			size:   deviceSize,
+			offset: offset090,
+			data:   withThisDisk(superblock090, 2, 0),
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleSpare},
+		},
+		"version 0.90 superblock of a spare of a big endian host": {
+			size:   deviceSize,
+			offset: offset090,
+			data:   bigEndian(withThisDisk(superblock090, 2, 0)),
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleSpare},
+		},
+		"version 0.90 superblock of a faulty device": {
+			size:   deviceSize,
+			offset: offset090,
+			data:   withThisDisk(superblock090, 1, stateFaulty090),
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleFaulty},
+		},
+		"version 0.90 superblock on a device not aligned to 64KiB": {
+			size:   deviceSize + 4096,
+			offset: offset090,
+			data:   superblock090,
+			want:   &Superblock{Version: Version090, ArrayUUID: superblock090UUID, Role: RoleActive},
+		},
+		"version 0.90 superblock at the end of an unaligned device": {
+			size:   deviceSize + 4096,
+			offset: offset090 + 4096,
+			data:   superblock090,
+			want:   nil,
+		},
+		"blank device": {
+			size:   deviceSize,
+			offset: 0,
+			data:   nil,
+			want:   nil,
+		},
+		"device smaller than the superblock area": {
+			size:   4096,
+			offset: 0,
+			data:   nil,
+			want:   nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeDevice(t, tt.size, tt.offset, tt.data)
+			got, err := GetSuperblock(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("non existent device", func(t *testing.T) {
+		_, err := GetSuperblock("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// NodeNamespace is the namespace used for components on the node.
+	NodeNamespace = "node"
+
+	// PresentInHierarchy is the label value for the devices that are present in the
+	// hierarchy cache, but do not have a BlockDevice resource
+	PresentInHierarchy = "hierarchy"
+
+	// PresentInEtcd is the label value for the active BlockDevice resources of the node
+	// whose device is not present in the hierarchy cache
+	PresentInEtcd = "etcd"
+)
+
+// Metrics is the prometheus metrics exposed by the NDM daemon. The hierarchy metrics help
+// in detecting leaks in the hierarchy cache of block devices and drift between the cache
+// and the BlockDevice resources.
+type Metrics struct {
+	hierarchySize       prometheus.Gauge
+	blockDeviceCount    prometheus.Gauge
+	hierarchyDivergence *prometheus.GaugeVec
+
+	// internal errors for which the device was skipped
+	internalErrorCount *prometheus.CounterVec
+	// inventory events dropped because the buffer of the sink was full
+	inventoryEventsDroppedCount prometheus.Counter
+}
+
+// NewMetrics creates instance of metrics
+func NewMetrics() *Metrics {
+	return new(Metrics).
+		withHierarchySize().
+		withBlockDeviceCount().
+		withHierarchyDivergence().
+		withInternalErrors().
+		withInventoryEventsDropped()
+}
+
+// Collectors lists out all the collectors for which the metrics is exposed
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.hierarchySize,
+		m.blockDeviceCount,
+		m.hierarchyDivergence,
+		m.internalErrorCount,
+		m.inventoryEventsDroppedCount,
+	}
+}
+
+// IncInternalErrorCounter increments the counter of internal errors with the given reason
+func (m *Metrics) IncInternalErrorCounter(reason string) {
+	m.internalErrorCount.WithLabelValues(reason).Inc()
+}
+
+// IncInventoryEventsDroppedCounter increments the counter of dropped inventory events
+func (m *Metrics) IncInventoryEventsDroppedCounter() {
+	m.inventoryEventsDroppedCount.Inc()
+}
+
+func (m *Metrics) withHierarchySize() *Metrics {
+	m.hierarchySize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: NodeNamespace,
+			Name:      "block_device_hierarchy_size",
+			Help:      `No. of devices in the hierarchy cache of the node`,
+		},
+	)
+	return m
+}
+
+func (m *Metrics) withBlockDeviceCount() *Metrics {
+	m.blockDeviceCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: NodeNamespace,
+			Name:      "block_device_resource_count",
+			Help:      `No. of BlockDevice resources of the node`,
+		},
+	)
+	return m
+}
+
+func (m *Metrics) withHierarchyDivergence() *Metrics {
+	m.hierarchyDivergence = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: NodeNamespace,
+			Name:      "block_device_hierarchy_divergence",
+			Help:      `No. of devices present only in the hierarchy cache or only as active BlockDevice resources`,
+		},
+		[]string{"present_in"},
+	)
+	return m
+}
+
+func (m *Metrics) withInternalErrors() *Metrics {
+	m.internalErrorCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: NodeNamespace,
+			Name:      "block_device_internal_error_count",
+			Help:      `No. of internal errors for which the processing of a device was skipped`,
+		},
+		[]string{"reason"},
+	)
+	return m
+}
+
+func (m *Metrics) withInventoryEventsDropped() *Metrics {
+	m.inventoryEventsDroppedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: NodeNamespace,
+			Name:      "block_device_inventory_events_dropped_count",
+			Help:      `No. of inventory events dropped because the buffer of the event sink was full`,
+		},
+	)
+	return m
+}
+
+// SetHierarchyMetrics is used to set the hierarchy metrics to respective fields
+func (m *Metrics) SetHierarchyMetrics(hierarchySize, blockDeviceCount, hierarchyOnly, etcdOnly int) {
+	m.hierarchySize.Set(float64(hierarchySize))
+	m.blockDeviceCount.Set(float64(blockDeviceCount))
+	m.hierarchyDivergence.WithLabelValues(PresentInHierarchy).Set(float64(hierarchyOnly))
+	m.hierarchyDivergence.WithLabelValues(PresentInEtcd).Set(float64(etcdOnly))
+}
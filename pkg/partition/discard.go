@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+// Discard discards (TRIM / UNMAP) all the blocks of the device, so that an SSD can
+// reclaim the flash cells and start afresh. All the data on the device is lost.
+func Discard(devPath string) error {
+	f, err := os.OpenFile(filepath.Clean(devPath), os.O_RDWR|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening device %s: %v", devPath, err)
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error getting size of device %s: %v", devPath, err)
+	}
+
+	// the range is given as the start offset and the length in bytes
+	blkRange := [2]uint64{0, uint64(size)}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), unix.BLKDISCARD, uintptr(unsafe.Pointer(&blkRange)))
+	if errno != 0 {
+		return fmt.Errorf("error discarding blocks of device %s: %v", devPath, errno)
+	}
+	klog.Infof("discarded %d bytes on device %s", size, devPath)
+	return nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ForeignPartitionScheme is a partition table scheme that is not parsed by NDM, but whose
+// signature is recognized, so that a disk having it is not mistaken for a blank disk.
+type ForeignPartitionScheme string
+
+const (
+	// NoForeignPartitionScheme is used if no foreign partition table signature is found
+	NoForeignPartitionScheme ForeignPartitionScheme = ""
+
+	// BSDDisklabel is the disklabel used by the BSDs
+	BSDDisklabel ForeignPartitionScheme = "bsd"
+
+	// SunVTOC is the VTOC label in the first sector, used by Solaris on SPARC
+	SunVTOC ForeignPartitionScheme = "sun"
+
+	// SolarisVTOC is the VTOC in the second sector, used by Solaris on x86
+	SolarisVTOC ForeignPartitionScheme = "solaris"
+
+	// ApplePartitionMap is the partition map used by older Apple systems
+	ApplePartitionMap ForeignPartitionScheme = "mac"
+)
+
+const (
+	// foreignSignatureBytes is the size of the start of the disk that is read to find the
+	// signatures. It covers the Apple partition map entry for block sizes up to 4KiB.
+	foreignSignatureBytes = 8192
+
+	// bsdDisklabelMagic is the magic number present at the start of the disklabel, and
+	// again after the drive data
+	bsdDisklabelMagic uint32 = 0x82564557
+	// bsdDisklabelMagic2Offset is the offset of the second magic number in the disklabel
+	bsdDisklabelMagic2Offset = 132
+
+	// sunVTOCMagic is the magic number at the end of the sun label
+	sunVTOCMagic uint16 = 0xDABE
+	// sunVTOCMagicOffset is the offset of the magic number in the first sector
+	sunVTOCMagicOffset = 508
+
+	// solarisVTOCSanity is the sanity value of the x86 VTOC, present after the boot info
+	solarisVTOCSanity uint32 = 0x600DDEEE
+	// solarisVTOCSanityOffset is the offset of the sanity value, the VTOC is in the second sector
+	solarisVTOCSanityOffset = 512 + 12
+
+	// appleDriverDescriptorSignature is the signature of the driver descriptor in block 0, "ER"
+	appleDriverDescriptorSignature uint16 = 0x4552
+	// applePartitionMapSignature is the signature of each partition map entry, "PM"
+	applePartitionMapSignature uint16 = 0x504D
+)
+
+// bsdDisklabelOffsets are the offsets at which the disklabel is placed by the different
+// platforms. i386 and amd64 place it at the start of the second sector, alpha at an offset
+// of 64 bytes in the first sector, and some at an offset of 64 bytes in the second sector.
+var bsdDisklabelOffsets = []int{512, 64, 512 + 64}
+
+// DetectForeignPartitionScheme reads the start of the disk and checks for the signatures of
+// the partition table schemes that NDM does not parse. The signatures are only matched, the
+// partition tables are not parsed or validated, so that a disk having one of them is never
+// considered blank.
+func DetectForeignPartitionScheme(devPath string) (ForeignPartitionScheme, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return NoForeignPartitionScheme, fmt.Errorf("error opening disk %s: %v", devPath, err)
+	}
+	defer f.Close()
+	buf := make([]byte, foreignSignatureBytes)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return NoForeignPartitionScheme, fmt.Errorf("error reading start of disk %s: %v", devPath, err)
+	}
+	return detectForeignPartitionScheme(buf[:n]), nil
+}
+
+// detectForeignPartitionScheme checks the start of the disk for the signature of a foreign
+// partition table scheme
+func detectForeignPartitionScheme(buf []byte) ForeignPartitionScheme {
+	switch {
+	case hasBSDDisklabel(buf):
+		return BSDDisklabel
+	case hasApplePartitionMap(buf):
+		return ApplePartitionMap
+	case hasSunVTOC(buf):
+		return SunVTOC
+	case hasSolarisVTOC(buf):
+		return SolarisVTOC
+	}
+	return NoForeignPartitionScheme
+}
+
+// hasBSDDisklabel checks for both the magic numbers of the disklabel at any of the
+// known offsets
+func hasBSDDisklabel(buf []byte) bool {
+	for _, offset := range bsdDisklabelOffsets {
+		if len(buf) < offset+bsdDisklabelMagic2Offset+4 {
+			continue
+		}
+		if binary.LittleEndian.Uint32(buf[offset:]) == bsdDisklabelMagic &&
+			binary.LittleEndian.Uint32(buf[offset+bsdDisklabelMagic2Offset:]) == bsdDisklabelMagic {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSunVTOC checks for the magic number at the end of the first sector
+func hasSunVTOC(buf []byte) bool {
+	if len(buf) < sunVTOCMagicOffset+2 {
+		return false
+	}
+	return binary.BigEndian.Uint16(buf[sunVTOCMagicOffset:]) == sunVTOCMagic
+}
+
+// hasSolarisVTOC checks for the sanity value of the VTOC in the second sector
+func hasSolarisVTOC(buf []byte) bool {
+	if len(buf) < solarisVTOCSanityOffset+4 {
+		return false
+	}
+	return binary.LittleEndian.Uint32(buf[solarisVTOCSanityOffset:]) == solarisVTOCSanity
+}
+
+// hasApplePartitionMap checks for the driver descriptor in block 0, and the first partition
+// map entry in block 1. The block size is taken from the driver descriptor, the entry is
+// also looked for at 512 bytes as the block size is not always filled correctly.
+func hasApplePartitionMap(buf []byte) bool {
+	if len(buf) < 4 || binary.BigEndian.Uint16(buf) != appleDriverDescriptorSignature {
+		return false
+	}
+	for _, offset := range []int{512, int(binary.BigEndian.Uint16(buf[2:]))} {
+		if offset < 512 || len(buf) < offset+2 {
+			continue
+		}
+		if binary.BigEndian.Uint16(buf[offset:]) == applePartitionMapSignature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newBSDDisklabelFixture creates the start of a disk having a BSD disklabel at the given
+// offset, with an empty MBR as created by bsdlabel on a dedicated disk
+func newBSDDisklabelFixture(offset int) []byte {
+	buf := make([]byte, foreignSignatureBytes)
+	label := buf[offset:]
+	binary.LittleEndian.PutUint32(label[0:], bsdDisklabelMagic)
+	// d_type DTYPE_SCSI and the type name
+	binary.LittleEndian.PutUint16(label[4:], 4)
+	copy(label[8:], "amnesiac")
+	// d_secsize, d_nsectors, d_ntracks
+	binary.LittleEndian.PutUint32(label[40:], 512)
+	binary.LittleEndian.PutUint32(label[44:], 63)
+	binary.LittleEndian.PutUint32(label[48:], 255)
+	binary.LittleEndian.PutUint32(label[bsdDisklabelMagic2Offset:], bsdDisklabelMagic)
+	// d_npartitions
+	binary.LittleEndian.PutUint16(label[138:], 8)
+	return buf
+}
+
+// newSolarisVTOCFixture creates the start of a disk having the x86 VTOC in the second sector
+func newSolarisVTOCFixture() []byte {
+	buf := make([]byte, foreignSignatureBytes)
+	vtoc := buf[512:]
+	binary.LittleEndian.PutUint32(vtoc[12:], solarisVTOCSanity)
+	// v_version and the volume name
+	binary.LittleEndian.PutUint32(vtoc[16:], 1)
+	copy(vtoc[20:], "zpool")
+	// v_sectorsz and v_nparts
+	binary.LittleEndian.PutUint16(vtoc[28:], 512)
+	binary.LittleEndian.PutUint16(vtoc[30:], 16)
+	return buf
+}
+
+// newSunVTOCFixture creates the start of a disk having the sun label in the first sector
+func newSunVTOCFixture() []byte {
+	buf := make([]byte, foreignSignatureBytes)
+	copy(buf, "SUN146G cyl 14087 alt 2 hd 24 sec 848")
+	binary.BigEndian.PutUint16(buf[sunVTOCMagicOffset:], sunVTOCMagic)
+	return buf
+}
+
+// newApplePartitionMapFixture creates the start of a disk having the driver descriptor
+// and the first partition map entry, for the given block size
+func newApplePartitionMapFixture(blockSize uint16) []byte {
+	buf := make([]byte, foreignSignatureBytes)
+	binary.BigEndian.PutUint16(buf[0:], appleDriverDescriptorSignature)
+	binary.BigEndian.PutUint16(buf[2:], blockSize)
+	binary.BigEndian.PutUint16(buf[blockSize:], applePartitionMapSignature)
+	copy(buf[int(blockSize)+48:], "Apple_partition_map")
+	return buf
+}
+
+func TestDetectForeignPartitionScheme(t *testing.T) {
+	mbr := make([]byte, foreignSignatureBytes)
+	mbr[510], mbr[511] = 0x55, 0xAA
+	brokenBSDDisklabel := newBSDDisklabelFixture(512)
+	binary.LittleEndian.PutUint32(brokenBSDDisklabel[512+bsdDisklabelMagic2Offset:], 0)
+
+	tests := map[string]struct {
+		buf  []byte
+		want ForeignPartitionScheme
+	}{
+		"blank disk": {
+			buf:  make([]byte, foreignSignatureBytes),
+			want: NoForeignPartitionScheme,
+		},
+		"disk with an empty MBR": {
+			buf:  mbr,
+			want: NoForeignPartitionScheme,
+		},
+		"bsd disklabel in the second sector": {
+			buf:  newBSDDisklabelFixture(512),
+			want: BSDDisklabel,
+		},
+		"bsd disklabel in the first sector": {
+			buf:  newBSDDisklabelFixture(64),
+			want: BSDDisklabel,
+		},
+		"bsd disklabel without the second magic": {
+			buf:  brokenBSDDisklabel,
+			want: NoForeignPartitionScheme,
+		},
+		"solaris x86 vtoc": {
+			buf:  newSolarisVTOCFixture(),
+			want: SolarisVTOC,
+		},
+		"sun label": {
+			buf:  newSunVTOCFixture(),
+			want: SunVTOC,
+		},
+		"apple partition map with 512 byte blocks": {
+			buf:  newApplePartitionMapFixture(512),
+			want: ApplePartitionMap,
+		},
+		"apple partition map with 2048 byte blocks": {
+			buf:  newApplePartitionMapFixture(2048),
+			want: ApplePartitionMap,
+		},
+		"disk smaller than a sector": {
+			buf:  make([]byte, 256),
+			want: NoForeignPartitionScheme,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, detectForeignPartitionScheme(test.buf))
+		})
+	}
+}
+
+func TestDetectForeignPartitionSchemeOfDiskImage(t *testing.T) {
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	data := append(newBSDDisklabelFixture(512), make([]byte, 1<<20)...)
+	assert.NoError(t, os.WriteFile(diskImage, data, 0600))
+
+	scheme, err := DetectForeignPartitionScheme(diskImage)
+	assert.NoError(t, err)
+	assert.Equal(t, BSDDisklabel, scheme)
+
+	_, err = DetectForeignPartitionScheme(filepath.Join(t.TempDir(), "missing.img"))
+	assert.Error(t, err)
+}
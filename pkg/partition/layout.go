@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"strings"
+
+	"github.com/diskfs/go-diskfs/partition/gpt"
+)
+
+// ReservedAreaBytes is the size of the area at the start of a disk partitioned by NDM
+// that is reserved for the partition table and alignment. NDM never places a partition
+// in this area, the partition created by NDM starts right after it.
+const ReservedAreaBytes = GPTPartitionStartByte
+
+// GetReservedAreaIntrusions gets the partitions that start inside the area reserved at the
+// start of a disk partitioned by NDM, eg: a partition written by a consumer or by the
+// firmware that straddles the boundary of the reserved area. The disk is recognized as
+// partitioned by NDM if it has a partition created by NDM. false is returned
+// if the disk was not partitioned by NDM, in which case there is no reserved area.
+func GetReservedAreaIntrusions(devPath string) ([]GPTPartitionEntry, bool, error) {
+	table, err := readGPTTable(devPath)
+	if err != nil || table == nil {
+		return nil, false, err
+	}
+
+	partitionedByNDM := false
+	for _, p := range table.Partitions {
+		if p.Type != gpt.Unused && IsNDMPartitionEntry(p.GUID, p.Name) {
+			partitionedByNDM = true
+			break
+		}
+	}
+	if !partitionedByNDM {
+		return nil, false, nil
+	}
+
+	intrusions := make([]GPTPartitionEntry, 0)
+	for i, p := range table.Partitions {
+		if p.Type == gpt.Unused {
+			continue
+		}
+		startOffset := p.Start * uint64(table.LogicalSectorSize)
+		if startOffset < ReservedAreaBytes {
+			intrusions = append(intrusions, GPTPartitionEntry{
+				Number:      uint8(i + 1),
+				GUID:        strings.ToLower(p.GUID),
+				StartOffset: startOffset,
+			})
+		}
+	}
+	return intrusions, true, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"testing"
+
+	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReservedAreaIntrusions(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions           []*gpt.Partition
+		wantPartitionedByNDM bool
+		wantIntrusions       []uint8
+	}{
+		"disk partitioned by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+			},
+			wantPartitionedByNDM: true,
+			wantIntrusions:       []uint8{},
+		},
+		"partition straddling the boundary of the reserved area": {
+			partitions: []*gpt.Partition{
+				{Start: 4096, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+				{Start: 1024, End: 4095, Type: gpt.LinuxFilesystem, Name: "firmware"},
+			},
+			wantPartitionedByNDM: true,
+			wantIntrusions:       []uint8{2},
+		},
+		"partition inside the reserved area": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+				{Start: 40, End: 2047, Type: gpt.LinuxFilesystem, Name: "data"},
+			},
+			wantPartitionedByNDM: true,
+			wantIntrusions:       []uint8{2},
+		},
+		"disk not partitioned by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 40, End: endSector, Type: gpt.LinuxFilesystem, Name: "data"},
+			},
+			wantPartitionedByNDM: false,
+		},
+		"disk without partition table": {
+			partitions:           nil,
+			wantPartitionedByNDM: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			intrusions, partitionedByNDM, err := GetReservedAreaIntrusions(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPartitionedByNDM, partitionedByNDM)
+			if !partitionedByNDM {
+				assert.Nil(t, intrusions)
+				return
+			}
+			numbers := make([]uint8, 0)
+			for _, intrusion := range intrusions {
+				numbers = append(numbers, intrusion.Number)
+				assert.Less(t, intrusion.StartOffset, uint64(ReservedAreaBytes))
+			}
+			assert.Equal(t, tt.wantIntrusions, numbers)
+		})
+	}
+}
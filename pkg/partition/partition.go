@@ -17,13 +17,20 @@ limitations under the License.
 package partition
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf16"
 
 	"github.com/diskfs/go-diskfs"
 	"github.com/diskfs/go-diskfs/disk"
 	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/openebs/node-disk-manager/pkg/blkid"
 
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/klog/v2"
 )
 
@@ -45,8 +52,32 @@ const (
 
 	// OpenEBSNDMPartitionName is the name meta info for openEBS created partitions.
 	OpenEBSNDMPartitionName = "OpenEBS_NDM"
+
+	// DefaultPartitionName is the default template of the name of the partition created by NDM
+	DefaultPartitionName = OpenEBSNDMPartitionName
+
+	// MaxPartitionNameLength is the maximum no. of UTF-16 code units in a GPT partition name
+	MaxPartitionNameLength = 36
+
+	// MinPartitionNamePrefixLength is the minimum no. of characters of the static prefix
+	// of the template of the partition name
+	MinPartitionNamePrefixLength = 4
+
+	// shortUUIDLength is the no. of characters of the partition GUID used as the short UUID
+	shortUUIDLength = 8
+
+	// ndmPartitionGUIDNode is the node field, i.e the last 12 hex digits, of the GUID of
+	// the partitions created by NDM. It is "NDMPRT" in ASCII, and is used to recognize
+	// the partitions created by NDM irrespective of their name.
+	ndmPartitionGUIDNode = "4E444D505254"
 )
 
+// partitionNameData is the data with which the partition name template is expanded
+type partitionNameData struct {
+	// ShortUUID is the first 8 characters of the GUID of the partition
+	ShortUUID string
+}
+
 // Disk struct represents a disk which needs to be partitioned
 type Disk struct {
 	// DevPath is the /dev/sdX entry of the disk
@@ -55,6 +86,14 @@ type Disk struct {
 	DiskSize uint64
 	// LogicalBlockSize is the block size of the disk normally 512 or 4k
 	LogicalBlockSize uint64
+	// PhysicalBlockSize is the size of the blocks in which the disk actually writes the
+	// data. It is larger than the logical block size on 512e disks, in which case the
+	// partition is aligned to the physical blocks. Ignored if not set.
+	PhysicalBlockSize uint64
+	// PartitionName is the template of the name of the partition created by NDM, which
+	// can have the short UUID of the partition as {{.ShortUUID}}. DefaultPartitionName
+	// is used if empty.
+	PartitionName string
 
 	table *gpt.Table
 
@@ -110,16 +149,117 @@ func (d *Disk) addPartition() error {
 	// the last blocks of the disk.
 	endSector = (d.DiskSize / d.LogicalBlockSize) - PrimaryPartitionTableSize - 1
 
+	// on 512e disks, the partition should span whole physical blocks, so that writes at
+	// the end of the partition do not need a read-modify-write of the physical block.
+	// The start at 1MiB is already aligned to the physical block.
+	if blocksPerPhysicalBlock := d.getLogicalBlocksPerPhysicalBlock(); blocksPerPhysicalBlock > 1 {
+		endSector = (endSector+1)/blocksPerPhysicalBlock*blocksPerPhysicalBlock - 1
+	}
+
+	guid := newNDMPartitionGUID()
+	name, err := ExpandPartitionName(d.PartitionName, guid)
+	if err != nil {
+		return err
+	}
+
 	partition := &gpt.Partition{
 		Start: startSector,
 		End:   endSector,
 		Type:  gpt.LinuxFilesystem,
-		Name:  OpenEBSNDMPartitionName,
+		Name:  name,
+		GUID:  guid,
 	}
 	d.table.Partitions = append(d.table.Partitions, partition)
 	return nil
 }
 
+// getLogicalBlocksPerPhysicalBlock gets the no. of logical blocks in a physical block of
+// the disk. 1 is returned if the physical block size is not set or is not a multiple of
+// the logical block size.
+func (d *Disk) getLogicalBlocksPerPhysicalBlock() uint64 {
+	if d.PhysicalBlockSize <= d.LogicalBlockSize || d.PhysicalBlockSize%d.LogicalBlockSize != 0 {
+		return 1
+	}
+	return d.PhysicalBlockSize / d.LogicalBlockSize
+}
+
+// newNDMPartitionGUID generates a random GUID for the partition created by NDM, with
+// the node field set to ndmPartitionGUIDNode.
+func newNDMPartitionGUID() string {
+	guid := strings.ToUpper(string(uuid.NewUUID()))
+	return guid[:len(guid)-len(ndmPartitionGUIDNode)] + ndmPartitionGUIDNode
+}
+
+// ValidatePartitionName validates the template of the partition name. The template
+// should start with a static prefix of at least MinPartitionNamePrefixLength characters,
+// so that tools scanning the partitions by name can tell them apart, and the expanded
+// name should fit in a GPT partition entry.
+func ValidatePartitionName(nameTemplate string) error {
+	if len(GetPartitionNamePrefix(nameTemplate)) < MinPartitionNamePrefixLength {
+		return fmt.Errorf("invalid partition name: %q, should start with a static prefix of at least %d characters",
+			nameTemplate, MinPartitionNamePrefixLength)
+	}
+	_, err := ExpandPartitionName(nameTemplate, string(uuid.NewUUID()))
+	return err
+}
+
+// ExpandPartitionName expands the template of the partition name with the short UUID
+// taken from the given GUID of the partition.
+func ExpandPartitionName(nameTemplate, guid string) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = DefaultPartitionName
+	}
+	tmpl, err := template.New("partition-name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid partition name: %q, %v", nameTemplate, err)
+	}
+
+	shortUUID := guid
+	if len(shortUUID) > shortUUIDLength {
+		shortUUID = shortUUID[:shortUUIDLength]
+	}
+	var name strings.Builder
+	if err := tmpl.Execute(&name, partitionNameData{ShortUUID: shortUUID}); err != nil {
+		return "", fmt.Errorf("invalid partition name: %q, %v", nameTemplate, err)
+	}
+	if length := len(utf16.Encode([]rune(name.String()))); length > MaxPartitionNameLength {
+		return "", fmt.Errorf("partition name: %q has %d UTF-16 code units, should not exceed %d",
+			name.String(), length, MaxPartitionNameLength)
+	}
+	return name.String(), nil
+}
+
+// GetPartitionNamePrefix gets the static prefix of the template of the partition name,
+// i.e the text before the first action in the template.
+func GetPartitionNamePrefix(nameTemplate string) string {
+	if nameTemplate == "" {
+		nameTemplate = DefaultPartitionName
+	}
+	if i := strings.Index(nameTemplate, "{{"); i >= 0 {
+		return nameTemplate[:i]
+	}
+	return nameTemplate
+}
+
+// IsNDMPartitionGUID checks if the partition with the given GUID was created by NDM
+func IsNDMPartitionGUID(guid string) bool {
+	return len(guid) == 36 && strings.EqualFold(guid[len(guid)-len(ndmPartitionGUIDNode):], ndmPartitionGUIDNode)
+}
+
+// IsNDMPartitionEntry checks if the partition with the given GUID and name was created by
+// NDM. The partitions are recognized by their GUID, the name is not used as it can be
+// set to anything by the operator. Partitions created before NDM marked the GUID have
+// the default name, and are recognized by it.
+func IsNDMPartitionEntry(guid, name string) bool {
+	return IsNDMPartitionGUID(guid) || name == OpenEBSNDMPartitionName
+}
+
+// IsNDMPartition checks if the partition with the given type GUID, GUID and name, as
+// reported by udev, is the partition created by NDM
+func IsNDMPartition(typeGUID, guid, name string) bool {
+	return strings.EqualFold(typeGUID, string(gpt.LinuxFilesystem)) && IsNDMPartitionEntry(guid, name)
+}
+
 // CreateSinglePartition creates a single GPT partition on the disk
 // that spans the entire disk
 func (d *Disk) CreateSinglePartition() error {
@@ -129,10 +269,15 @@ func (d *Disk) CreateSinglePartition() error {
 	}
 	d.disk = fd
 
-	// check for any existing partition table on the disk
-	if _, err := d.disk.GetPartitionTable(); err == nil {
-		klog.Errorf("aborting partition creation, disk %s already contains a known partition table", d.DevPath)
-		return fmt.Errorf("disk %s contains a partition table, cannot create a single partition", d.DevPath)
+	// check for any existing partition table on the disk. A protective MBR with an empty
+	// GPT is a blank disk that has only been initialized with a GPT, and can be partitioned.
+	if table, err := d.disk.GetPartitionTable(); err == nil {
+		if gptTable, ok := table.(*gpt.Table); ok && isProtectiveMBROnly(gptTable) {
+			klog.Infof("disk %s has a protective MBR with an empty GPT, replacing it", d.DevPath)
+		} else {
+			klog.Errorf("aborting partition creation, disk %s already contains a known partition table", d.DevPath)
+			return fmt.Errorf("disk %s contains a partition table, cannot create a single partition", d.DevPath)
+		}
 	}
 
 	// check for any existing filesystem on the disk
@@ -201,3 +346,135 @@ func (d *Disk) CreatePartitionTable() error {
 	klog.Infof("created partition table on disk %s", d.DevPath)
 	return nil
 }
+
+// HasGPTPartitionEntries checks whether the disk has a GPT partition table with at least
+// one used partition entry. The partition entries are read directly from the disk, so that
+// the partitions for which the kernel has not yet created device nodes are also found.
+func HasGPTPartitionEntries(devPath string) (bool, error) {
+	partitions, err := readGPTPartitions(devPath)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range partitions {
+		if p.Type != gpt.Unused {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasOSPartition checks whether the disk has a GPT partition table with a partition entry
+// created by an os installer, like a boot, root or swap partition. Such a disk is the os
+// disk of the node.
+func HasOSPartition(devPath string) (bool, error) {
+	partitions, err := readGPTPartitions(devPath)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range partitions {
+		if blockdevice.IsOSPartitionType(string(p.Type)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasStorageSpacesPartition checks whether the disk has a GPT partition table with a
+// Storage Spaces protective partition entry. Such a disk is a member of a Windows Storage
+// Spaces pool.
+func HasStorageSpacesPartition(devPath string) (bool, error) {
+	partitions, err := readGPTPartitions(devPath)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range partitions {
+		if blockdevice.IsStorageSpacesPartitionType(string(p.Type)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GPTPartitionEntry is the entry of a partition in the GPT partition table of a disk
+type GPTPartitionEntry struct {
+	// Number is the number of the partition, i.e the index of the entry starting from 1
+	Number uint8
+	// GUID is the unique partition GUID of the entry
+	GUID string
+	// StartOffset is the offset in bytes of the start of the partition from the start of the disk
+	StartOffset uint64
+}
+
+// GetGPTPartitionEntry reads the entry of the partition with the given number from the
+// GPT partition table of the disk. false is returned if the disk does not have a GPT
+// partition table or the entry is not used.
+func GetGPTPartitionEntry(devPath string, number uint8) (GPTPartitionEntry, bool, error) {
+	table, err := readGPTTable(devPath)
+	if err != nil || table == nil {
+		return GPTPartitionEntry{}, false, err
+	}
+	if number == 0 || int(number) > len(table.Partitions) {
+		return GPTPartitionEntry{}, false, nil
+	}
+	p := table.Partitions[number-1]
+	if p.Type == gpt.Unused {
+		return GPTPartitionEntry{}, false, nil
+	}
+	return GPTPartitionEntry{
+		Number:      number,
+		GUID:        strings.ToLower(p.GUID),
+		StartOffset: p.Start * uint64(table.LogicalSectorSize),
+	}, true, nil
+}
+
+// readGPTPartitions reads the GPT partition entries from the disk. No entries are
+// returned if the disk does not have a GPT partition table. An error is returned if the
+// disk cannot be read or has a GPT that cannot be parsed.
+func readGPTPartitions(devPath string) ([]*gpt.Partition, error) {
+	table, err := readGPTTable(devPath)
+	if err != nil || table == nil {
+		return nil, err
+	}
+	return table.Partitions, nil
+}
+
+// readGPTTable reads the GPT partition table from the disk. nil is returned if the disk
+// does not have a GPT partition table. An error is returned if the disk cannot be read,
+// or if the disk has a GPT header or a protective MBR but the GPT cannot be parsed, so
+// that such a disk is never mistaken for a disk without a partition table.
+func readGPTTable(devPath string) (*gpt.Table, error) {
+	fd, err := diskfs.OpenWithMode(devPath, diskfs.ReadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("error opening disk fd for disk %s: %v", devPath, err)
+	}
+	defer fd.File.Close()
+
+	table, err := fd.GetPartitionTable()
+	if gptTable, ok := table.(*gpt.Table); err == nil && ok {
+		return gptTable, nil
+	}
+
+	// the library does not differentiate between a disk without a partition table and
+	// a disk that cannot be read, and falls back to the protective MBR if the GPT cannot
+	// be parsed. So the start of the disk is read again to find out which one it is.
+	if ok, readErr := hasGPTOrProtectiveMBR(fd.File); readErr != nil {
+		return nil, fmt.Errorf("error reading partition table of disk %s: %v", devPath, readErr)
+	} else if ok {
+		return nil, fmt.Errorf("disk %s has a GPT header or a protective MBR, but the GPT cannot be read",
+			devPath)
+	}
+	// no GPT partition table on the disk
+	return nil, nil
+}
+
+// hasGPTOrProtectiveMBR checks if the first two logical blocks of the disk have a GPT
+// header or a protective MBR
+func hasGPTOrProtectiveMBR(r io.ReaderAt) (bool, error) {
+	buf := make([]byte, 2*4096)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	buf = buf[:n]
+	return getGPTHeaderBlockSize(buf) != 0 || hasProtectiveMBR(buf), nil
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package partition
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/openebs/node-disk-manager/blockdevice"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -119,13 +122,454 @@ func TestAddPartition(t *testing.T) {
 				},
 			},
 		},
+		"465GiB 512e HDD is aligned to the physical block": {
+			actualDisk: Disk{
+				DevPath:           "/dev/sda",
+				DiskSize:          500107862016,
+				LogicalBlockSize:  512,
+				PhysicalBlockSize: 4096,
+				table:             &gpt.Table{},
+			},
+			expectedPartitionTable: &gpt.Table{
+				Partitions: []*gpt.Partition{
+					{
+						Start: 2048,
+						End:   976773127,
+						Type:  gpt.LinuxFilesystem,
+						Name:  OpenEBSNDMPartitionName,
+					},
+				},
+			},
+		},
+		"375 GiB 4Kn SSD": {
+			actualDisk: Disk{
+				DevPath:           "/dev/sda",
+				DiskSize:          402653184000,
+				LogicalBlockSize:  4096,
+				PhysicalBlockSize: 4096,
+				table:             &gpt.Table{},
+			},
+			expectedPartitionTable: &gpt.Table{
+				Partitions: []*gpt.Partition{
+					{
+						Start: 256,
+						End:   98303994,
+						Type:  gpt.LinuxFilesystem,
+						Name:  OpenEBSNDMPartitionName,
+					},
+				},
+			},
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			if err := test.actualDisk.addPartition(); (err != nil) != test.wantErr {
 				t.Errorf("AddPartition() error = %v, wantErr %v", err, test.wantErr)
 			}
+			// the GUID is random, except for the node field set by NDM
+			for i, p := range test.actualDisk.table.Partitions {
+				assert.True(t, IsNDMPartitionGUID(p.GUID))
+				test.expectedPartitionTable.Partitions[i].GUID = p.GUID
+			}
 			assert.Equal(t, test.actualDisk.table, test.expectedPartitionTable)
 		})
 	}
 }
+
+func TestHasGPTPartitionEntries(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		want       bool
+	}{
+		"disk without partition table": {
+			partitions: nil,
+			want:       false,
+		},
+		"disk with empty GPT partition table": {
+			partitions: []*gpt.Partition{},
+			want:       false,
+		},
+		"disk with foreign GPT partition": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.MicrosoftBasicData, Name: "Basic data partition"},
+			},
+			want: true,
+		},
+		"disk with partition created by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+			},
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			got, err := HasGPTPartitionEntries(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("non existent disk", func(t *testing.T) {
+		_, err := HasGPTPartitionEntries("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+
+	// a disk with a GPT that cannot be parsed should not be mistaken for a disk
+	// without a partition table
+	t.Run("disk with unreadable GPT", func(t *testing.T) {
+		path := createDiskImage(t, []*gpt.Partition{
+			{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: "data"},
+		})
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// corrupt the signature of the GPT header, the protective MBR is left as is
+		if _, err := f.WriteAt([]byte("XXXXXXXX"), 512); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = HasGPTPartitionEntries(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestHasOSPartition(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		want       bool
+	}{
+		"disk without partition table": {
+			partitions: nil,
+			want:       false,
+		},
+		"disk with BIOS boot partition": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: 4095, Type: gpt.BiosBoot, Name: "BIOS boot partition"},
+				{Start: 4096, End: endSector, Type: gpt.LinuxFilesystem, Name: "root"},
+			},
+			want: true,
+		},
+		"disk with EFI System partition": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: 8191, Type: gpt.EFISystemPartition, Name: "EFI System Partition"},
+				{Start: 8192, End: endSector, Type: gpt.LinuxFilesystem, Name: "root"},
+			},
+			want: true,
+		},
+		"disk with root and swap partitions": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: 8191, Type: gpt.LinuxRootX86_64, Name: "root"},
+				{Start: 8192, End: 16383, Type: gpt.LinuxSwap, Name: "swap"},
+				{Start: 16384, End: endSector, Type: gpt.LinuxFilesystem, Name: "data"},
+			},
+			want: true,
+		},
+		"disk with recovery partition": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: 8191, Type: gpt.MicrosoftWindowsRecovery, Name: "Recovery"},
+				{Start: 8192, End: endSector, Type: gpt.MicrosoftBasicData, Name: "data"},
+			},
+			want: true,
+		},
+		"disk with data partitions only": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+			},
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			got, err := HasOSPartition(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHasStorageSpacesPartition(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		want       bool
+	}{
+		"disk without partition table": {
+			partitions: nil,
+			want:       false,
+		},
+		"disk in a Storage Spaces pool": {
+			// layout created by Windows when the disk is added to a pool
+			partitions: []*gpt.Partition{
+				{Start: 34, End: 2047, Type: gpt.MicrosoftReserved, Name: "Microsoft reserved partition"},
+				{Start: 2048, End: endSector, Type: gpt.Type(blockdevice.PartitionTypeStorageSpaces),
+					Name: "Storage Spaces protective partition"},
+			},
+			want: true,
+		},
+		"disk with basic data partition": {
+			partitions: []*gpt.Partition{
+				{Start: 34, End: 2047, Type: gpt.MicrosoftReserved, Name: "Microsoft reserved partition"},
+				{Start: 2048, End: endSector, Type: gpt.MicrosoftBasicData, Name: "Basic data partition"},
+			},
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			got, err := HasStorageSpacesPartition(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("non existent disk", func(t *testing.T) {
+		_, err := HasStorageSpacesPartition("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+}
+
+func TestExpandPartitionName(t *testing.T) {
+	guid := "4B9C1E2A-7D3F-4E8A-9B1C-2D3E4F5A6B7C"
+	tests := map[string]struct {
+		nameTemplate string
+		want         string
+		wantErr      bool
+	}{
+		"empty template uses the default name": {
+			nameTemplate: "",
+			want:         OpenEBSNDMPartitionName,
+		},
+		"static name": {
+			nameTemplate: "ACME_DATA",
+			want:         "ACME_DATA",
+		},
+		"name with short UUID": {
+			nameTemplate: "OpenEBS_NDM_{{.ShortUUID}}",
+			want:         "OpenEBS_NDM_4B9C1E2A",
+		},
+		"name too long after expansion": {
+			nameTemplate: "OpenEBS_NDM_data_partition_{{.ShortUUID}}_x",
+			wantErr:      true,
+		},
+		"unknown field in template": {
+			nameTemplate: "OpenEBS_NDM_{{.Serial}}",
+			wantErr:      true,
+		},
+		"malformed template": {
+			nameTemplate: "OpenEBS_NDM_{{.ShortUUID",
+			wantErr:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ExpandPartitionName(tt.nameTemplate, guid)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidatePartitionName(t *testing.T) {
+	tests := map[string]struct {
+		nameTemplate string
+		wantErr      bool
+	}{
+		"default name": {
+			nameTemplate: DefaultPartitionName,
+			wantErr:      false,
+		},
+		"name with short UUID": {
+			nameTemplate: "OpenEBS_NDM_{{.ShortUUID}}",
+			wantErr:      false,
+		},
+		"name without static prefix": {
+			nameTemplate: "{{.ShortUUID}}_data",
+			wantErr:      true,
+		},
+		"name with a one character prefix": {
+			nameTemplate: "A{{.ShortUUID}}",
+			wantErr:      true,
+		},
+		"name exceeding 36 UTF-16 code units": {
+			nameTemplate: "OpenEBS_Node_Disk_Manager_Data_Partition",
+			wantErr:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidatePartitionName(tt.nameTemplate)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestIsNDMPartitionEntry(t *testing.T) {
+	tests := map[string]struct {
+		guid string
+		name string
+		want bool
+	}{
+		"partition with the GUID created by NDM": {
+			guid: newNDMPartitionGUID(),
+			name: "ACME_4B9C1E2A",
+			want: true,
+		},
+		"partition with the lowercase GUID created by NDM": {
+			guid: strings.ToLower(newNDMPartitionGUID()),
+			name: "",
+			want: true,
+		},
+		"partition with the default name, created before the GUID was marked": {
+			guid: "4B9C1E2A-3F6B-4F0B-9C3A-6C1F2B7A8E01",
+			name: OpenEBSNDMPartitionName,
+			want: true,
+		},
+		"partition with the prefix of the template as the name": {
+			guid: "4B9C1E2A-3F6B-4F0B-9C3A-6C1F2B7A8E01",
+			name: "ACME_4B9C1E2A",
+			want: false,
+		},
+		"partition with the default name as prefix": {
+			guid: "4B9C1E2A-3F6B-4F0B-9C3A-6C1F2B7A8E01",
+			name: OpenEBSNDMPartitionName + "_data",
+			want: false,
+		},
+		"foreign partition": {
+			guid: "4B9C1E2A-3F6B-4F0B-9C3A-6C1F2B7A8E01",
+			name: "Basic data partition",
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsNDMPartitionEntry(tt.guid, tt.name))
+		})
+	}
+}
+
+func TestCreateSinglePartitionWithName(t *testing.T) {
+	tests := map[string]struct {
+		nameTemplate string
+		wantPrefix   string
+	}{
+		"default name": {
+			nameTemplate: "",
+			wantPrefix:   OpenEBSNDMPartitionName,
+		},
+		"name with short UUID": {
+			nameTemplate: "ACME_{{.ShortUUID}}",
+			wantPrefix:   "ACME_",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, nil)
+			d := Disk{
+				DevPath:          path,
+				DiskSize:         testDiskSize,
+				LogicalBlockSize: 512,
+				PartitionName:    tt.nameTemplate,
+			}
+			if err := d.CreateSinglePartition(); err != nil {
+				t.Fatal(err)
+			}
+
+			partitions, err := readGPTPartitions(path)
+			if err != nil || len(partitions) == 0 {
+				t.Fatalf("unable to read partition table: %v", err)
+			}
+			// the name read back from the disk is the template expanded with the
+			// GUID of the partition
+			got := partitions[0]
+			want, err := ExpandPartitionName(tt.nameTemplate, got.GUID)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got.Name)
+			assert.True(t, strings.HasPrefix(got.Name, tt.wantPrefix))
+			assert.True(t, IsNDMPartitionGUID(got.GUID))
+			assert.Equal(t, gpt.Unused, partitions[1].Type)
+		})
+	}
+}
+
+func TestGetGPTPartitionEntry(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	// multi partition disk, with the third entry unused
+	path := createDiskImage(t, []*gpt.Partition{
+		{Start: 2048, End: 4095, Type: gpt.EFISystemPartition, Name: "EFI System Partition",
+			GUID: "5D3E1E4A-3F6B-4F0B-9C3A-6C1F2B7A8E01"},
+		{Start: 4096, End: 8191, Type: gpt.LinuxSwap, Name: "swap",
+			GUID: "5D3E1E4A-3F6B-4F0B-9C3A-6C1F2B7A8E02"},
+		{Type: gpt.Unused},
+		{Start: 8192, End: endSector, Type: gpt.LinuxFilesystem, Name: "data",
+			GUID: "5D3E1E4A-3F6B-4F0B-9C3A-6C1F2B7A8E04"},
+	})
+
+	tests := map[string]struct {
+		number  uint8
+		want    GPTPartitionEntry
+		wantOk  bool
+		wantErr bool
+	}{
+		"first partition": {
+			number: 1,
+			want: GPTPartitionEntry{
+				Number:      1,
+				GUID:        "5d3e1e4a-3f6b-4f0b-9c3a-6c1f2b7a8e01",
+				StartOffset: 2048 * 512,
+			},
+			wantOk: true,
+		},
+		"second partition": {
+			number: 2,
+			want: GPTPartitionEntry{
+				Number:      2,
+				GUID:        "5d3e1e4a-3f6b-4f0b-9c3a-6c1f2b7a8e02",
+				StartOffset: 4096 * 512,
+			},
+			wantOk: true,
+		},
+		"unused entry": {
+			number: 3,
+			wantOk: false,
+		},
+		"partition after an unused entry": {
+			number: 4,
+			want: GPTPartitionEntry{
+				Number:      4,
+				GUID:        "5d3e1e4a-3f6b-4f0b-9c3a-6c1f2b7a8e04",
+				StartOffset: 8192 * 512,
+			},
+			wantOk: true,
+		},
+		"invalid partition number": {
+			number: 0,
+			wantOk: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok, err := GetGPTPartitionEntry(path, tt.number)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("disk without partition table", func(t *testing.T) {
+		_, ok, err := GetGPTPartitionEntry(createDiskImage(t, nil), 1)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/diskfs/go-diskfs/partition/gpt"
+)
+
+// ProtectiveMBRLayout is the layout of a disk as seen from its protective MBR
+type ProtectiveMBRLayout string
+
+const (
+	// NoProtectiveMBR is used if the disk does not have a protective MBR
+	NoProtectiveMBR ProtectiveMBRLayout = "none"
+	// ProtectiveMBROnly is used if the disk has a protective MBR, but the GPT does not have
+	// any used partition entry. The disk has only been initialized with a GPT and is blank.
+	ProtectiveMBROnly ProtectiveMBRLayout = "protective-mbr-only"
+	// ProtectiveMBRWithPartitions is used if the disk has a protective MBR and the GPT has
+	// at least one used partition entry
+	ProtectiveMBRWithPartitions ProtectiveMBRLayout = "protective-mbr-with-partitions"
+)
+
+// GetProtectiveMBRLayout reads the MBR of the disk, and if it is a protective MBR, the
+// GPT partition entries protected by it. An error is returned if the disk has a protective
+// MBR but the GPT cannot be read, as the disk cannot be considered blank in that case.
+func GetProtectiveMBRLayout(devPath string) (ProtectiveMBRLayout, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return NoProtectiveMBR, fmt.Errorf("error opening disk %s: %v", devPath, err)
+	}
+	buf := make([]byte, 512)
+	n, err := f.ReadAt(buf, 0)
+	f.Close()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return NoProtectiveMBR, fmt.Errorf("error reading MBR of disk %s: %v", devPath, err)
+	}
+	if !hasProtectiveMBR(buf[:n]) {
+		return NoProtectiveMBR, nil
+	}
+
+	table, err := readGPTTable(devPath)
+	if err != nil {
+		return NoProtectiveMBR, err
+	}
+	if table == nil {
+		return NoProtectiveMBR, fmt.Errorf("disk %s has a protective MBR, but the GPT cannot be read", devPath)
+	}
+	if hasUsedGPTEntries(table) {
+		return ProtectiveMBRWithPartitions, nil
+	}
+	return ProtectiveMBROnly, nil
+}
+
+// isProtectiveMBROnly checks if the GPT partition table read from a disk has a protective
+// MBR and no used partition entries
+func isProtectiveMBROnly(table *gpt.Table) bool {
+	return table.ProtectiveMBR && !hasUsedGPTEntries(table)
+}
+
+// hasUsedGPTEntries checks if the GPT partition table has at least one used partition entry
+func hasUsedGPTEntries(table *gpt.Table) bool {
+	for _, p := range table.Partitions {
+		if p.Type != gpt.Unused {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/diskfs/go-diskfs/partition/mbr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProtectiveMBRLayout(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		patch      func(header, entries []byte)
+		want       ProtectiveMBRLayout
+		wantErr    bool
+	}{
+		"disk without partition table": {
+			partitions: nil,
+			want:       NoProtectiveMBR,
+		},
+		"protective MBR only": {
+			partitions: []*gpt.Partition{},
+			want:       ProtectiveMBROnly,
+		},
+		"protective MBR with partitions": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.MicrosoftBasicData, Name: "Basic data partition"},
+			},
+			want: ProtectiveMBRWithPartitions,
+		},
+		"protective MBR with partition created by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+			},
+			want: ProtectiveMBRWithPartitions,
+		},
+		"protective MBR without GPT header": {
+			partitions: []*gpt.Partition{},
+			patch: func(header, entries []byte) {
+				copy(header, make([]byte, len(header)))
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			if tt.patch != nil {
+				patchDiskImage(t, path, false, tt.patch)
+			}
+			got, err := GetProtectiveMBRLayout(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("disk with dos partition table", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "disk.img")
+		d, err := diskfs.Create(path, testDiskSize, diskfs.Raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table := &mbr.Table{
+			LogicalSectorSize:  512,
+			PhysicalSectorSize: 512,
+			Partitions: []*mbr.Partition{
+				{Start: 2048, Size: 8192, Type: mbr.Linux},
+			},
+		}
+		if err := d.Partition(table); err != nil {
+			t.Fatal(err)
+		}
+		d.File.Close()
+		got, err := GetProtectiveMBRLayout(path)
+		assert.NoError(t, err)
+		assert.Equal(t, NoProtectiveMBR, got)
+	})
+
+	t.Run("non existent disk", func(t *testing.T) {
+		_, err := GetProtectiveMBRLayout("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+
+	t.Run("empty disk image", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "disk.img")
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+		got, err := GetProtectiveMBRLayout(path)
+		assert.NoError(t, err)
+		assert.Equal(t, NoProtectiveMBR, got)
+	})
+}
+
+func TestCreateSinglePartitionOnExistingTable(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		wantErr    bool
+	}{
+		"protective MBR only": {
+			partitions: []*gpt.Partition{},
+			wantErr:    false,
+		},
+		"protective MBR with partitions": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.MicrosoftBasicData, Name: "Basic data partition"},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			d := Disk{
+				DevPath:          path,
+				DiskSize:         testDiskSize,
+				LogicalBlockSize: 512,
+			}
+			err := d.CreateSinglePartition()
+			partitions, readErr := readGPTPartitions(path)
+			assert.NoError(t, readErr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				// the existing partition is left untouched
+				assert.Equal(t, "Basic data partition", partitions[0].Name)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, OpenEBSNDMPartitionName, partitions[0].Name)
+		})
+	}
+}
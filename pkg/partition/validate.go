@@ -0,0 +1,238 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PartitionTableDefect is the defect found in a corrupt partition table
+type PartitionTableDefect string
+
+const (
+	// DefectInvalidHeader is used if the GPT header is missing or malformed
+	DefectInvalidHeader PartitionTableDefect = "invalid-header"
+	// DefectBadHeaderCRC is used if the CRC32 of the GPT header does not match
+	DefectBadHeaderCRC PartitionTableDefect = "bad-header-crc"
+	// DefectInvalidEntryCount is used if the no. or size of partition entries is invalid
+	DefectInvalidEntryCount PartitionTableDefect = "invalid-entry-count"
+	// DefectBadEntriesCRC is used if the CRC32 of the partition entries does not match
+	DefectBadEntriesCRC PartitionTableDefect = "bad-entries-crc"
+	// DefectInvalidEntry is used if a partition lies outside the usable blocks of the disk
+	DefectInvalidEntry PartitionTableDefect = "invalid-entry"
+	// DefectOverlap is used if two partitions overlap
+	DefectOverlap PartitionTableDefect = "overlap"
+)
+
+// CorruptPartitionTableError is returned if the partition table of the disk cannot be trusted
+type CorruptPartitionTableError struct {
+	DevPath string
+	Defect  PartitionTableDefect
+	Details string
+}
+
+func (e *CorruptPartitionTableError) Error() string {
+	return fmt.Sprintf("corrupt partition table on disk %s, %s: %s", e.DevPath, e.Defect, e.Details)
+}
+
+const (
+	gptSignature = "EFI PART"
+
+	// gptHeaderMinSize is the size of the fields defined in the GPT header
+	gptHeaderMinSize = 92
+
+	// gptEntryMinSize is the minimum size of a GPT partition entry
+	gptEntryMinSize = 128
+
+	// gptEntriesMaxBytes is the limit on the size of the partition entries array, much
+	// larger than the 16KiB used by all the partitioning tools
+	gptEntriesMaxBytes = 1048576
+
+	// mbrProtectivePartitionType is the partition type of the protective MBR entry of a GPT
+	mbrProtectivePartitionType = 0xee
+)
+
+// gptHeader has the fields of the GPT header used for validation
+type gptHeader struct {
+	headerSize     uint32
+	headerCRC      uint32
+	firstUsableLBA uint64
+	lastUsableLBA  uint64
+	entriesLBA     uint64
+	numEntries     uint32
+	entrySize      uint32
+	entriesCRC     uint32
+}
+
+// gptEntry is a used partition entry
+type gptEntry struct {
+	index    int
+	firstLBA uint64
+	lastLBA  uint64
+}
+
+// ValidatePartitionTable reads the primary GPT of the disk and checks that the headers and
+// the partition entries can be trusted. A *CorruptPartitionTableError with the defect is
+// returned if the partition table is corrupt. Disks without a GPT are considered valid.
+func ValidatePartitionTable(devPath string) error {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return fmt.Errorf("error opening disk %s: %v", devPath, err)
+	}
+	defer f.Close()
+	return validatePartitionTable(f, devPath)
+}
+
+func validatePartitionTable(r io.ReaderAt, devPath string) error {
+	corrupt := func(defect PartitionTableDefect, format string, a ...interface{}) error {
+		return &CorruptPartitionTableError{DevPath: devPath, Defect: defect, Details: fmt.Sprintf(format, a...)}
+	}
+
+	// the header is in the second logical block, the logical block size is found from
+	// the location of the header
+	buf := make([]byte, 2*4096)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading partition table of disk %s: %v", devPath, err)
+	}
+	buf = buf[:n]
+
+	blockSize := getGPTHeaderBlockSize(buf)
+	if blockSize == 0 {
+		if hasProtectiveMBR(buf) {
+			return corrupt(DefectInvalidHeader, "protective MBR present, but GPT header not found")
+		}
+		// not a GPT disk
+		return nil
+	}
+
+	header, err := parseGPTHeader(buf[blockSize : 2*blockSize])
+	if err != nil {
+		return corrupt(DefectInvalidHeader, "%v", err)
+	}
+	if crc := gptHeaderCRC(buf[blockSize:2*blockSize], header.headerSize); crc != header.headerCRC {
+		return corrupt(DefectBadHeaderCRC, "header CRC32 is %#08x, expected %#08x", header.headerCRC, crc)
+	}
+
+	entriesSize := uint64(header.numEntries) * uint64(header.entrySize)
+	if header.numEntries == 0 || header.entrySize < gptEntryMinSize || header.entrySize%8 != 0 ||
+		entriesSize > gptEntriesMaxBytes {
+		return corrupt(DefectInvalidEntryCount, "%d entries of %d bytes", header.numEntries, header.entrySize)
+	}
+
+	entriesBuf := make([]byte, entriesSize)
+	if _, err := r.ReadAt(entriesBuf, int64(header.entriesLBA)*int64(blockSize)); err != nil {
+		return corrupt(DefectInvalidEntryCount, "unable to read %d entries of %d bytes at LBA %d: %v",
+			header.numEntries, header.entrySize, header.entriesLBA, err)
+	}
+	if crc := crc32.ChecksumIEEE(entriesBuf); crc != header.entriesCRC {
+		return corrupt(DefectBadEntriesCRC, "partition entries CRC32 is %#08x, expected %#08x",
+			header.entriesCRC, crc)
+	}
+
+	entries := make([]gptEntry, 0)
+	for i := 0; i < int(header.numEntries); i++ {
+		entry := entriesBuf[i*int(header.entrySize) : (i+1)*int(header.entrySize)]
+		// entries with a zero type GUID are unused
+		if bytes.Equal(entry[:16], make([]byte, 16)) {
+			continue
+		}
+		e := gptEntry{
+			index:    i + 1,
+			firstLBA: binary.LittleEndian.Uint64(entry[32:40]),
+			lastLBA:  binary.LittleEndian.Uint64(entry[40:48]),
+		}
+		if e.firstLBA > e.lastLBA || e.firstLBA < header.firstUsableLBA || e.lastLBA > header.lastUsableLBA {
+			return corrupt(DefectInvalidEntry, "partition %d spans LBA %d-%d, outside the usable LBA %d-%d",
+				e.index, e.firstLBA, e.lastLBA, header.firstUsableLBA, header.lastUsableLBA)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].firstLBA < entries[j].firstLBA })
+	for i := 1; i < len(entries); i++ {
+		if entries[i].firstLBA <= entries[i-1].lastLBA {
+			return corrupt(DefectOverlap, "partition %d (LBA %d-%d) overlaps partition %d (LBA %d-%d)",
+				entries[i].index, entries[i].firstLBA, entries[i].lastLBA,
+				entries[i-1].index, entries[i-1].firstLBA, entries[i-1].lastLBA)
+		}
+	}
+	return nil
+}
+
+// getGPTHeaderBlockSize finds the logical block size of the disk from the location of the
+// GPT header in the first two blocks read from the disk. 0 is returned if the GPT header
+// signature is not found.
+func getGPTHeaderBlockSize(buf []byte) int {
+	for _, size := range []int{512, 4096} {
+		if len(buf) >= 2*size && string(buf[size:size+len(gptSignature)]) == gptSignature {
+			return size
+		}
+	}
+	return 0
+}
+
+// parseGPTHeader parses the GPT header from the logical block having the header
+func parseGPTHeader(block []byte) (gptHeader, error) {
+	h := gptHeader{
+		headerSize:     binary.LittleEndian.Uint32(block[12:16]),
+		headerCRC:      binary.LittleEndian.Uint32(block[16:20]),
+		firstUsableLBA: binary.LittleEndian.Uint64(block[40:48]),
+		lastUsableLBA:  binary.LittleEndian.Uint64(block[48:56]),
+		entriesLBA:     binary.LittleEndian.Uint64(block[72:80]),
+		numEntries:     binary.LittleEndian.Uint32(block[80:84]),
+		entrySize:      binary.LittleEndian.Uint32(block[84:88]),
+		entriesCRC:     binary.LittleEndian.Uint32(block[88:92]),
+	}
+	if h.headerSize < gptHeaderMinSize || int(h.headerSize) > len(block) {
+		return h, fmt.Errorf("invalid header size %d", h.headerSize)
+	}
+	if h.firstUsableLBA > h.lastUsableLBA {
+		return h, fmt.Errorf("first usable LBA %d is after last usable LBA %d", h.firstUsableLBA, h.lastUsableLBA)
+	}
+	return h, nil
+}
+
+// gptHeaderCRC computes the CRC32 of the GPT header, with the CRC field zeroed
+func gptHeaderCRC(block []byte, headerSize uint32) uint32 {
+	header := make([]byte, headerSize)
+	copy(header, block[:headerSize])
+	copy(header[16:20], []byte{0, 0, 0, 0})
+	return crc32.ChecksumIEEE(header)
+}
+
+// hasProtectiveMBR checks if the MBR has a partition of the type used to protect a GPT
+func hasProtectiveMBR(buf []byte) bool {
+	if len(buf) < 512 || buf[510] != 0x55 || buf[511] != 0xaa {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		// partition type is the 5th byte of each 16 byte entry starting at 446
+		if buf[446+i*16+4] == mbrProtectivePartitionType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/stretchr/testify/assert"
+)
+
+// the offsets in the disk image created with 512 byte logical blocks
+const (
+	testHeaderOffset  = 512
+	testEntriesOffset = 1024
+)
+
+// patchDiskImage modifies the disk image and, if fixCRC is set, recomputes the CRC32 of the
+// partition entries and the header, so that only the intended defect is present.
+func patchDiskImage(t *testing.T, path string, fixCRC bool, patch func(header, entries []byte)) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := data[testHeaderOffset : testHeaderOffset+gptHeaderMinSize]
+	entries := data[testEntriesOffset : testEntriesOffset+BytesRequiredForGPTPartitionEntries]
+	patch(header, entries)
+	if fixCRC {
+		binary.LittleEndian.PutUint32(header[88:92], crc32.ChecksumIEEE(entries))
+		binary.LittleEndian.PutUint32(header[16:20], gptHeaderCRC(header, gptHeaderMinSize))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setEntryLBA sets the first and last LBA of the partition entry at the given index
+func setEntryLBA(entries []byte, index int, firstLBA, lastLBA uint64) {
+	entry := entries[index*gptEntryMinSize:]
+	binary.LittleEndian.PutUint64(entry[32:40], firstLBA)
+	binary.LittleEndian.PutUint64(entry[40:48], lastLBA)
+}
+
+func TestValidatePartitionTable(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	twoPartitions := []*gpt.Partition{
+		{Start: 2048, End: 8191, Type: gpt.LinuxFilesystem, Name: "data1"},
+		{Start: 8192, End: endSector, Type: gpt.LinuxFilesystem, Name: "data2"},
+	}
+	tests := map[string]struct {
+		partitions []*gpt.Partition
+		fixCRC     bool
+		patch      func(header, entries []byte)
+		wantDefect PartitionTableDefect
+	}{
+		"disk without partition table": {
+			partitions: nil,
+		},
+		"valid partition table": {
+			partitions: twoPartitions,
+		},
+		"overlapping partitions": {
+			partitions: twoPartitions,
+			fixCRC:     true,
+			patch: func(header, entries []byte) {
+				setEntryLBA(entries, 1, 4096, endSector)
+			},
+			wantDefect: DefectOverlap,
+		},
+		"partition outside the usable blocks": {
+			partitions: twoPartitions,
+			fixCRC:     true,
+			patch: func(header, entries []byte) {
+				setEntryLBA(entries, 1, 8192, endSector+100)
+			},
+			wantDefect: DefectInvalidEntry,
+		},
+		"bad primary header CRC": {
+			partitions: twoPartitions,
+			patch: func(header, entries []byte) {
+				header[16] ^= 0xff
+			},
+			wantDefect: DefectBadHeaderCRC,
+		},
+		"bad partition entries CRC": {
+			partitions: twoPartitions,
+			patch: func(header, entries []byte) {
+				setEntryLBA(entries, 1, 8192, endSector-1)
+			},
+			wantDefect: DefectBadEntriesCRC,
+		},
+		"invalid entry count": {
+			partitions: twoPartitions,
+			fixCRC:     true,
+			patch: func(header, entries []byte) {
+				binary.LittleEndian.PutUint32(header[80:84], 0)
+			},
+			wantDefect: DefectInvalidEntryCount,
+		},
+		"protective MBR without GPT header": {
+			partitions: twoPartitions,
+			patch: func(header, entries []byte) {
+				copy(header, make([]byte, len(header)))
+			},
+			wantDefect: DefectInvalidHeader,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			if tt.patch != nil {
+				patchDiskImage(t, path, tt.fixCRC, tt.patch)
+			}
+			err := ValidatePartitionTable(path)
+			if tt.wantDefect == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var corruptErr *CorruptPartitionTableError
+			if assert.True(t, errors.As(err, &corruptErr), "got error: %v", err) {
+				assert.Equal(t, tt.wantDefect, corruptErr.Defect)
+				assert.Equal(t, path, corruptErr.DevPath)
+			}
+		})
+	}
+
+	t.Run("non existent disk", func(t *testing.T) {
+		err := ValidatePartitionTable("/dev/non-existent-disk")
+		assert.Error(t, err)
+		var corruptErr *CorruptPartitionTableError
+		assert.False(t, errors.As(err, &corruptErr))
+	})
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/disk"
+	"github.com/diskfs/go-diskfs/partition/gpt"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// SignatureWipeBytes is the no of bytes that are zeroed at the start and end of
+	// a device to erase the filesystem / partition table signatures. The signatures
+	// of all common filesystems, zfs labels and GPT headers lie within this range.
+	SignatureWipeBytes = 1048576
+)
+
+// WipeSignatures erases the filesystem and partition table signatures on the device by
+// zeroing the start and end of the device.
+func WipeSignatures(devPath string) error {
+	f, err := os.OpenFile(filepath.Clean(devPath), os.O_RDWR|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening device %s: %v", devPath, err)
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error getting size of device %s: %v", devPath, err)
+	}
+
+	if err := zeroRange(f, 0, SignatureWipeBytes, size); err != nil {
+		return fmt.Errorf("error wiping start of device %s: %v", devPath, err)
+	}
+	if err := zeroRange(f, size-SignatureWipeBytes, SignatureWipeBytes, size); err != nil {
+		return fmt.Errorf("error wiping end of device %s: %v", devPath, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("error syncing device %s: %v", devPath, err)
+	}
+	klog.Infof("wiped signatures on device %s", devPath)
+	return nil
+}
+
+// RemoveNDMPartition removes the single partition created by NDM on the disk, by erasing the
+// protective MBR and the primary and backup GPT. The disk will be left without any partition
+// table. Disks having a partition table that was not created by NDM are not modified.
+func (d *Disk) RemoveNDMPartition() error {
+	fd, err := diskfs.Open(d.DevPath)
+	if err != nil {
+		return fmt.Errorf("error opening disk fd for disk %s: %v", d.DevPath, err)
+	}
+	d.disk = fd
+	defer d.disk.File.Close()
+
+	table, err := d.disk.GetPartitionTable()
+	if err != nil {
+		return fmt.Errorf("unable to read partition table of disk %s: %v", d.DevPath, err)
+	}
+	gptTable, ok := table.(*gpt.Table)
+	if !ok {
+		return fmt.Errorf("disk %s does not have a GPT partition table", d.DevPath)
+	}
+
+	partitions := make([]*gpt.Partition, 0)
+	for _, p := range gptTable.Partitions {
+		if p.Type != gpt.Unused {
+			partitions = append(partitions, p)
+		}
+	}
+	if len(partitions) != 1 || !IsNDMPartitionEntry(partitions[0].GUID, partitions[0].Name) {
+		klog.Errorf("aborting partition removal, disk %s has partitions not created by NDM", d.DevPath)
+		return fmt.Errorf("disk %s has partitions not created by NDM, cannot remove partition", d.DevPath)
+	}
+
+	// the first partition starts at 1MiB, and the backup GPT is stored in the last blocks
+	// of the disk. Both these ranges do not overlap with the partition.
+	size := d.disk.Size
+	backupGPTSize := int64(BytesRequiredForGPTPartitionEntries) + d.disk.LogicalBlocksize
+	if err := zeroRange(d.disk.File, 0, GPTPartitionStartByte, size); err != nil {
+		return fmt.Errorf("error erasing primary GPT on disk %s: %v", d.DevPath, err)
+	}
+	if err := zeroRange(d.disk.File, size-backupGPTSize, backupGPTSize, size); err != nil {
+		return fmt.Errorf("error erasing backup GPT on disk %s: %v", d.DevPath, err)
+	}
+	if err := d.disk.File.Sync(); err != nil {
+		return fmt.Errorf("error syncing disk %s: %v", d.DevPath, err)
+	}
+
+	if d.disk.Type == disk.Device {
+		if err := d.disk.ReReadPartitionTable(); err != nil {
+			return err
+		}
+	}
+	klog.Infof("removed partition created by NDM on disk %s", d.DevPath)
+	return nil
+}
+
+// zeroRange writes zeroes to length bytes starting at offset. The range is
+// clamped to the size of the device.
+func zeroRange(f *os.File, offset, length, size int64) error {
+	if offset < 0 {
+		length += offset
+		offset = 0
+	}
+	if offset+length > size {
+		length = size - offset
+	}
+	if length <= 0 {
+		return nil
+	}
+	_, err := f.WriteAt(make([]byte, length), offset)
+	return err
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/partition/gpt"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDiskSize = 10 * 1024 * 1024
+
+// createDiskImage creates a disk image file with a GPT partition table having
+// the given partitions
+func createDiskImage(t *testing.T, partitions []*gpt.Partition) string {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	d, err := diskfs.Create(path, testDiskSize, diskfs.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.File.Close()
+	if partitions == nil {
+		return path
+	}
+	table := &gpt.Table{
+		LogicalSectorSize: 512,
+		ProtectiveMBR:     true,
+		Partitions:        partitions,
+	}
+	if err := d.Partition(table); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRemoveNDMPartition(t *testing.T) {
+	endSector := uint64(testDiskSize/512 - 34)
+	tests := map[string]struct {
+		partitions    []*gpt.Partition
+		partitionName string
+		wantErr       bool
+	}{
+		"disk with partition created by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+			},
+			wantErr: false,
+		},
+		"disk with partition created by NDM with a custom name": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: "ACME_4B9C1E2A",
+					GUID: "4B9C1E2A-3F6B-4F0B-9C3A-" + ndmPartitionGUIDNode},
+			},
+			partitionName: "ACME_{{.ShortUUID}}",
+			wantErr:       false,
+		},
+		"disk with partition having the prefix of the custom name": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: "ACME_4B9C1E2A",
+					GUID: "4B9C1E2A-3F6B-4F0B-9C3A-6C1F2B7A8E01"},
+			},
+			partitionName: "ACME_{{.ShortUUID}}",
+			wantErr:       true,
+		},
+		"disk with partition not created by NDM": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: endSector, Type: gpt.LinuxFilesystem, Name: "data"},
+			},
+			wantErr: true,
+		},
+		"disk with multiple partitions": {
+			partitions: []*gpt.Partition{
+				{Start: 2048, End: 4095, Type: gpt.LinuxFilesystem, Name: OpenEBSNDMPartitionName},
+				{Start: 4096, End: endSector, Type: gpt.LinuxFilesystem, Name: "data"},
+			},
+			wantErr: true,
+		},
+		"disk without partition table": {
+			partitions: nil,
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := createDiskImage(t, tt.partitions)
+			d := &Disk{
+				DevPath:          path,
+				DiskSize:         testDiskSize,
+				LogicalBlockSize: 512,
+				PartitionName:    tt.partitionName,
+			}
+			err := d.RemoveNDMPartition()
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			fd, err := diskfs.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fd.File.Close()
+			_, err = fd.GetPartitionTable()
+			// partition table should not be present if it was removed or never existed
+			assert.Equal(t, tt.partitions == nil || !tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestWipeSignatures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	data := bytes.Repeat([]byte{0xff}, 3*SignatureWipeBytes)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WipeSignatures(path)
+	assert.NoError(t, err)
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroes := make([]byte, SignatureWipeBytes)
+	assert.Equal(t, zeroes, got[:SignatureWipeBytes])
+	assert.Equal(t, data[SignatureWipeBytes:2*SignatureWipeBytes], got[SignatureWipeBytes:2*SignatureWipeBytes])
+	assert.Equal(t, zeroes, got[2*SignatureWipeBytes:])
+
+	err = WipeSignatures(filepath.Join(t.TempDir(), "non-existent"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+// WritabilityScratchBytes is the size of the scratch region at the end of the disk, to
+// which the writability check writes. It is within the area of the backup GPT written
+// when the disk is partitioned.
+const WritabilityScratchBytes = 4096
+
+var (
+	// ErrScratchRegionNotBlank is returned if the scratch region of the disk has data, the
+	// disk is not written to in that case
+	ErrScratchRegionNotBlank = errors.New("scratch region is not blank")
+	// ErrWriteVerifyMismatch is returned if the data read back from the scratch region is
+	// different from the data written
+	ErrWriteVerifyMismatch = errors.New("data read back differs from data written")
+)
+
+// writabilityPattern is the pattern written to the scratch region
+var writabilityPattern = []byte("NDM-WRITABILITY-CHECK\n")
+
+// scratchDevice is a device that can be read and written at an offset, and whose cached
+// data can be dropped, so that the data is read again from the device
+type scratchDevice interface {
+	io.ReaderAt
+	io.WriterAt
+	DropCache(offset, length int64) error
+}
+
+// deviceFile is a device opened for the writability check
+type deviceFile struct {
+	*os.File
+}
+
+// DropCache drops the pages of the range from the page cache. The written data has been
+// synced, so that the pages are clean and can be dropped.
+func (f deviceFile) DropCache(offset, length int64) error {
+	return unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
+
+// IsReadOnly checks if the device is read-only, using the BLKROGET ioctl. The device is
+// opened read-only, and is never written to.
+func IsReadOnly(devPath string) (bool, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return false, fmt.Errorf("error opening device %s: %v", devPath, err)
+	}
+	defer f.Close()
+
+	readOnly, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKROGET)
+	if err != nil {
+		return false, fmt.Errorf("error getting read-only state of device %s: %v", devPath, err)
+	}
+	return readOnly != 0, nil
+}
+
+// VerifyWritable checks that the disk can be written to, by writing a pattern to the
+// scratch region in the last WritabilityScratchBytes of the disk, reading it back and
+// comparing it. The pattern is read back after dropping it from the page cache, so that
+// it is read from the disk. The scratch region should be blank, i.e all zeroes, otherwise the disk is
+// not written to and ErrScratchRegionNotBlank is returned. The region is zeroed again
+// after the check, even if the check fails.
+func VerifyWritable(devPath string) error {
+	f, err := os.OpenFile(filepath.Clean(devPath), os.O_RDWR|os.O_EXCL|os.O_SYNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening device %s: %v", devPath, err)
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error getting size of device %s: %v", devPath, err)
+	}
+	if err := verifyWritable(deviceFile{f}, size, devPath); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("error syncing device %s: %v", devPath, err)
+	}
+	klog.Infof("verified device %s is writable", devPath)
+	return nil
+}
+
+func verifyWritable(rw scratchDevice, size int64, devPath string) error {
+	// the scratch region is aligned to its size, so that it is aligned to the logical
+	// and physical block size of the disk
+	offset := (size/WritabilityScratchBytes - 1) * WritabilityScratchBytes
+	if offset < 0 {
+		return fmt.Errorf("device %s is smaller than the scratch region", devPath)
+	}
+
+	original := make([]byte, WritabilityScratchBytes)
+	if _, err := rw.ReadAt(original, offset); err != nil {
+		return fmt.Errorf("error reading scratch region of device %s: %v", devPath, err)
+	}
+	if !isZeroed(original) {
+		return fmt.Errorf("%w on device %s", ErrScratchRegionNotBlank, devPath)
+	}
+
+	pattern := bytes.Repeat(writabilityPattern, WritabilityScratchBytes/len(writabilityPattern)+1)[:WritabilityScratchBytes]
+	verifyErr := writeAndVerify(rw, pattern, offset, devPath)
+	// the scratch region is restored irrespective of the result of the check
+	if _, err := rw.WriteAt(original, offset); err != nil {
+		return fmt.Errorf("error restoring scratch region of device %s: %v", devPath, err)
+	}
+	return verifyErr
+}
+
+// writeAndVerify writes the data at the offset and checks that the same data is read back
+// from the device, rather than from the page cache
+func writeAndVerify(rw scratchDevice, data []byte, offset int64, devPath string) error {
+	if _, err := rw.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("error writing scratch region of device %s: %v", devPath, err)
+	}
+	if err := rw.DropCache(offset, int64(len(data))); err != nil {
+		return fmt.Errorf("error dropping cached scratch region of device %s: %v", devPath, err)
+	}
+	readBack := make([]byte, len(data))
+	if _, err := rw.ReadAt(readBack, offset); err != nil {
+		return fmt.Errorf("error reading back scratch region of device %s: %v", devPath, err)
+	}
+	if !bytes.Equal(data, readBack) {
+		return fmt.Errorf("%w on device %s", ErrWriteVerifyMismatch, devPath)
+	}
+	return nil
+}
+
+// isZeroed checks if all the bytes are zero
+func isZeroed(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
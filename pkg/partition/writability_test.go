@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partition
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryDevice is an in memory device, whose writes can be dropped to simulate a device
+// that silently fails writes. The dropped writes are still read back till the cache is
+// dropped, like from the page cache.
+type memoryDevice struct {
+	data       []byte
+	cache      []byte
+	dropWrites bool
+	writes     int
+}
+
+func (m *memoryDevice) ReadAt(p []byte, off int64) (int, error) {
+	if m.cache != nil {
+		return copy(p, m.cache[off:]), nil
+	}
+	return copy(p, m.data[off:]), nil
+}
+
+func (m *memoryDevice) WriteAt(p []byte, off int64) (int, error) {
+	m.writes++
+	if m.dropWrites {
+		if m.cache == nil {
+			m.cache = append([]byte(nil), m.data...)
+		}
+		return copy(m.cache[off:], p), nil
+	}
+	return copy(m.data[off:], p), nil
+}
+
+func (m *memoryDevice) DropCache(offset, length int64) error {
+	m.cache = nil
+	return nil
+}
+
+func TestVerifyWritableDevice(t *testing.T) {
+	tests := map[string]struct {
+		size       int64
+		dataAt     int64
+		dropWrites bool
+		wantErr    error
+		wantWrites int
+	}{
+		"blank device": {
+			size:       testDiskSize,
+			dataAt:     -1,
+			wantErr:    nil,
+			wantWrites: 2,
+		},
+		"device with data in the scratch region": {
+			size:       testDiskSize,
+			dataAt:     testDiskSize - 10,
+			wantErr:    ErrScratchRegionNotBlank,
+			wantWrites: 0,
+		},
+		"device with data before the scratch region": {
+			size:       testDiskSize,
+			dataAt:     testDiskSize - WritabilityScratchBytes - 1,
+			wantErr:    nil,
+			wantWrites: 2,
+		},
+		"device dropping writes": {
+			size:       testDiskSize,
+			dataAt:     -1,
+			dropWrites: true,
+			wantErr:    ErrWriteVerifyMismatch,
+			wantWrites: 2,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			device := &memoryDevice{
+				data:       make([]byte, tt.size),
+				dropWrites: tt.dropWrites,
+			}
+			if tt.dataAt >= 0 {
+				device.data[tt.dataAt] = 0xff
+			}
+			before := append([]byte(nil), device.data...)
+
+			err := verifyWritable(device, tt.size, "/dev/sda")
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error: %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantWrites, device.writes)
+			// the contents of the device are never changed
+			assert.Equal(t, before, device.data)
+		})
+	}
+}
+
+func TestVerifyWritable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, make([]byte, testDiskSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, VerifyWritable(path))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, isZeroed(data))
+}
+
+func TestIsReadOnly(t *testing.T) {
+	// the read-only state is only known for block devices
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, make([]byte, testDiskSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := IsReadOnly(path)
+	assert.Error(t, err)
+
+	_, err = IsReadOnly(filepath.Join(t.TempDir(), "missing.img"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package refs detects the Windows ReFS filesystem, which is not detected by blkid.
+package refs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// bootSectorSize is the size of the ReFS volume boot record read from the device
+	bootSectorSize = 512
+
+	// fileSystemNameOffset is the offset of the filesystem name in the boot sector
+	fileSystemNameOffset = 3
+
+	// identifierOffset is the offset of the FSRS identifier in the boot sector
+	identifierOffset = 16
+)
+
+var (
+	// fileSystemName is the filesystem name in the ReFS boot sector, padded with zeroes
+	fileSystemName = []byte("ReFS\x00\x00\x00\x00")
+
+	// identifier is the signature of the ReFS file system recognition structure
+	identifier = []byte("FSRS")
+)
+
+// IsReFSBootSector checks if the given boot sector is that of a ReFS volume
+func IsReFSBootSector(buf []byte) bool {
+	if len(buf) < identifierOffset+len(identifier) {
+		return false
+	}
+	return bytes.Equal(buf[fileSystemNameOffset:fileSystemNameOffset+len(fileSystemName)], fileSystemName) &&
+		bytes.Equal(buf[identifierOffset:identifierOffset+len(identifier)], identifier)
+}
+
+// HasReFSSignature reads the boot sector of the device and checks if the device has
+// a ReFS filesystem
+func HasReFSSignature(devPath string) (bool, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, bootSectorSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		// devices smaller than the boot sector, like an empty cdrom, cannot have ReFS
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading boot sector from %s: %v", devPath, err)
+	}
+	return IsReFSBootSector(buf), nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package refs
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// refsBootSector is the start of the boot sector of a ReFS 3.4 volume of 1GiB
+// with 512 byte sectors and 4KiB clusters
+var refsBootSector = mustDecodeHex("" +
+	"0000005265465300000000000000000046535253000266e7" +
+	"000020000000000000020000080000000304000000000000" +
+	"9a3d1c7e2b56f041")
+
+// ntfsBootSector is the start of the boot sector of an NTFS volume
+var ntfsBootSector = mustDecodeHex("" +
+	"eb52904e5446532020202000020800000000000000f80000" +
+	"3f00ff000008000000000000")
+
+func mustDecodeHex(s string) []byte {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// sector pads the data with zeroes to the size of a boot sector
+func sector(data []byte) []byte {
+	buf := make([]byte, bootSectorSize)
+	copy(buf, data)
+	return buf
+}
+
+func TestIsReFSBootSector(t *testing.T) {
+	tests := map[string]struct {
+		buf  []byte
+		want bool
+	}{
+		"ReFS boot sector": {
+			buf:  sector(refsBootSector),
+			want: true,
+		},
+		"NTFS boot sector": {
+			buf:  sector(ntfsBootSector),
+			want: false,
+		},
+		"ReFS name without the FSRS identifier": {
+			buf:  sector(refsBootSector[:identifierOffset]),
+			want: false,
+		},
+		"blank sector": {
+			buf:  sector(nil),
+			want: false,
+		},
+		"short buffer": {
+			buf:  refsBootSector[:8],
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsReFSBootSector(tt.buf))
+		})
+	}
+}
+
+func TestHasReFSSignature(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+		want bool
+	}{
+		"device with ReFS": {
+			data: sector(refsBootSector),
+			want: true,
+		},
+		"device with NTFS": {
+			data: sector(ntfsBootSector),
+			want: false,
+		},
+		"device smaller than a sector": {
+			data: refsBootSector,
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := HasReFSSignature(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("non existent device", func(t *testing.T) {
+		_, err := HasReFSSignature("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+}
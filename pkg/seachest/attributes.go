@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package seachest
+
+// bytesPerTerabyte is the number of bytes in a terabyte, as used in the
+// endurance rating of SSDs
+const bytesPerTerabyte = 1000 * 1000 * 1000 * 1000
+
+// formFactors are the nominal form factors reported by the disk, in the
+// IDENTIFY DEVICE data of ATA disks and the Block Device Characteristics VPD
+// page of SCSI disks. 0 means that the form factor is not reported.
+var formFactors = map[uint8]string{
+	1: `5.25"`,
+	2: `3.5"`,
+	3: `2.5"`,
+	4: `1.8"`,
+	5: `less than 1.8"`,
+	6: "mSATA",
+	7: "M.2",
+	8: "MicroSSD",
+	9: "CFast",
+}
+
+// FormFactor returns the name of the nominal form factor reported by the disk.
+// An empty string is returned if the form factor is not reported or is not known.
+func FormFactor(code uint8) string {
+	return formFactors[code]
+}
+
+// WriteEnduranceTBW estimates the write endurance of the SSD in terabytes written,
+// from the bytes written to it and the percentage of its endurance used. 0 is returned
+// if the disk does not report either of them.
+func WriteEnduranceTBW(totalBytesWritten uint64, percentEnduranceUsed float64) uint64 {
+	if totalBytesWritten == 0 || percentEnduranceUsed <= 0 {
+		return 0
+	}
+	return uint64(float64(totalBytesWritten) * 100 / percentEnduranceUsed / bytesPerTerabyte)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package seachest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormFactor(t *testing.T) {
+	tests := map[string]struct {
+		code uint8
+		want string
+	}{
+		"form factor not reported": {
+			code: 0,
+			want: "",
+		},
+		"2.5 inch disk": {
+			code: 3,
+			want: `2.5"`,
+		},
+		"m.2 ssd": {
+			code: 7,
+			want: "M.2",
+		},
+		"unknown form factor": {
+			code: 15,
+			want: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormFactor(tt.code))
+		})
+	}
+}
+
+func TestWriteEnduranceTBW(t *testing.T) {
+	tests := map[string]struct {
+		totalBytesWritten    uint64
+		percentEnduranceUsed float64
+		want                 uint64
+	}{
+		"ssd with 10 percent endurance used": {
+			totalBytesWritten:    60 * bytesPerTerabyte,
+			percentEnduranceUsed: 10,
+			want:                 600,
+		},
+		"endurance used not reported": {
+			totalBytesWritten:    60 * bytesPerTerabyte,
+			percentEnduranceUsed: 0,
+			want:                 0,
+		},
+		"bytes written not reported": {
+			totalBytesWritten:    0,
+			percentEnduranceUsed: 10,
+			want:                 0,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, WriteEnduranceTBW(tt.totalBytesWritten, tt.percentEnduranceUsed))
+		})
+	}
+}
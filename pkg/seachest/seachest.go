@@ -212,6 +212,14 @@ func (I *Identifier) GetPercentEnduranceUsed(driveInfo *C.driveInformationSAS_SA
 	return 0
 }
 
+func (I *Identifier) GetFormFactor(driveInfo *C.driveInformationSAS_SATA) string {
+	return FormFactor((uint8)(driveInfo.formFactor))
+}
+
+func (I *Identifier) GetWriteEnduranceTBW(driveInfo *C.driveInformationSAS_SATA) uint64 {
+	return WriteEnduranceTBW(I.GetTotalBytesWritten(driveInfo), I.GetPercentEnduranceUsed(driveInfo))
+}
+
 func (I *Identifier) GetTemperatureDataValidStatus(driveInfo *C.driveInformationSAS_SATA) bool {
 	return ((bool)(driveInfo.temperatureData.temperatureDataValid))
 }
@@ -52,6 +52,8 @@ const (
 	FilterBlockDeviceTag = "filterBlockDeviceTag"
 	// FilterOutLegacyAnnotation is used to filter out devices with legacy annotation
 	FilterOutLegacyAnnotation = "filterOutLegacyAnnotation"
+	// FilterOutPartitionedParents is used to filter out disks having partitions
+	FilterOutPartitionedParents = "filterOutPartitionedParents"
 )
 
 const (
@@ -73,6 +75,7 @@ var filterFuncMap = map[string]filterFunc{
 	FilterNodeName:              filterNodeName,
 	FilterBlockDeviceTag:        filterBlockDeviceTag,
 	FilterOutLegacyAnnotation:   filterOutLegacyAnnotation,
+	FilterOutPartitionedParents: filterOutPartitionedParents,
 }
 
 // ApplyFilters apply the filter specified in the filterkeys on the given BD List,
@@ -216,7 +219,6 @@ func filterResourceStorage(originalBD *apis.BlockDeviceList, spec *apis.DeviceCl
 	for _, bd := range originalBD.Items {
 		if bd.Spec.Capacity.Storage >= uint64(capacity) {
 			filteredBDList.Items = append(filteredBDList.Items, bd)
-			break
 		}
 	}
 	return filteredBDList
@@ -348,6 +350,26 @@ func filterOutLegacyAnnotation(originalBD *apis.BlockDeviceList, spec *apis.Devi
 	return filteredBDList
 }
 
+// filterOutPartitionedParents removes all blockdevices of disks having partitions, which
+// are shown only to make the disk visible in the inventory
+func filterOutPartitionedParents(originalBD *apis.BlockDeviceList, spec *apis.DeviceClaimSpec) *apis.BlockDeviceList {
+	filteredBDList := &apis.BlockDeviceList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "BlockDevice",
+			APIVersion: "openebs.io/v1alpha1",
+		},
+	}
+
+	for _, bd := range originalBD.Items {
+		if _, ok := bd.Annotations[controller.HasPartitionsAnnotation]; ok {
+			continue
+		}
+		filteredBDList.Items = append(filteredBDList.Items, bd)
+	}
+
+	return filteredBDList
+}
+
 // isBDTagDoesNotExistSelectorRequired is used to check whether a selector
 // was present on the BDC. It is used to decide whether a `does not exist` selector
 // for the block-device-tag label should be applied or not.
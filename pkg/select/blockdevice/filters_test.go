@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	apis "github.com/openebs/node-disk-manager/api/v1alpha1"
+	"github.com/openebs/node-disk-manager/cmd/ndm_daemonset/controller"
 	"github.com/openebs/node-disk-manager/db/kubernetes"
 
 	"github.com/stretchr/testify/assert"
@@ -242,6 +243,22 @@ func TestFilterBlockDeviceTag(t *testing.T) {
 	}
 }
 
+func TestFilterOutPartitionedParents(t *testing.T) {
+	bdList := createFakeBlockDeviceList(make(BDLabelList, TestNoOfBDs), TestNoOfBDs)
+	bdList.Items[0].Annotations = map[string]string{
+		controller.HasPartitionsAnnotation: "/dev/sda1,/dev/sda2",
+	}
+	bdList.Items[1].Annotations = map[string]string{
+		internalUUIDSchemeAnnotation: "gpt",
+	}
+
+	got := filterOutPartitionedParents(bdList, &apis.DeviceClaimSpec{})
+	assert.Equal(t, TestNoOfBDs-1, len(got.Items))
+	for _, bd := range got.Items {
+		assert.NotEqual(t, "bd0", bd.Name)
+	}
+}
+
 func createFakeBlockDeviceList(labelList BDLabelList, noOfBDs int) *apis.BlockDeviceList {
 	bdListAPI := &apis.BlockDeviceList{
 		TypeMeta: v1.TypeMeta{
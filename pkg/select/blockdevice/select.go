@@ -23,6 +23,17 @@ import (
 
 // Filter selects a single block device from a list of block devices
 func (c *Config) Filter(bdList *apis.BlockDeviceList) (*apis.BlockDevice, error) {
+	selectedDevices, err := c.FilterAll(bdList)
+	if err != nil {
+		return nil, err
+	}
+	// will use the first available block device
+	return &selectedDevices.Items[0], nil
+}
+
+// FilterAll selects all the block devices from a list of block devices that match the
+// claim, in the order in which Filter would select them
+func (c *Config) FilterAll(bdList *apis.BlockDeviceList) (*apis.BlockDeviceList, error) {
 	if len(bdList.Items) == 0 {
 		return nil, fmt.Errorf("no blockdevices found")
 	}
@@ -31,11 +42,7 @@ func (c *Config) Filter(bdList *apis.BlockDeviceList) (*apis.BlockDevice, error)
 	if err != nil {
 		return nil, err
 	}
-	selectedDevice, err := c.getSelectedDevice(candidateDevices)
-	if err != nil {
-		return nil, err
-	}
-	return selectedDevice, nil
+	return c.getSelectedDevices(candidateDevices)
 }
 
 // getCandidateDevices selects a list of blockdevices from a given block device
@@ -47,6 +54,8 @@ func (c *Config) getCandidateDevices(bdList *apis.BlockDeviceList) (*apis.BlockD
 		FilterUnclaimed,
 		// do not consider any devices with legacy annotation for claiming
 		FilterOutLegacyAnnotation,
+		// disks having partitions are only shown in the inventory, and are never claimed
+		FilterOutPartitionedParents,
 		// remove block devices which do not have the blockdevice tag
 		// if selector is present on the BDC, select only those devices
 		// this applies to both manual and auto claiming.
@@ -77,11 +86,11 @@ func (c *Config) getCandidateDevices(bdList *apis.BlockDeviceList) (*apis.BlockD
 	return candidateBD, nil
 }
 
-// getSelectedDevice selects a single a block device based on the resource requirements
+// getSelectedDevices selects the block devices matching the resource requirements
 // requested by the claim
-func (c *Config) getSelectedDevice(bdList *apis.BlockDeviceList) (*apis.BlockDevice, error) {
+func (c *Config) getSelectedDevices(bdList *apis.BlockDeviceList) (*apis.BlockDeviceList, error) {
 	if c.ManualSelection {
-		return &bdList.Items[0], nil
+		return bdList, nil
 	}
 
 	// filterKeys for filtering based on resource requirements
@@ -92,7 +101,5 @@ func (c *Config) getSelectedDevice(bdList *apis.BlockDeviceList) (*apis.BlockDev
 	if len(selectedDevices.Items) == 0 {
 		return nil, fmt.Errorf("could not find a device with matching resource requirements")
 	}
-
-	// will use the first available block device
-	return &selectedDevices.Items[0], nil
+	return selectedDevices, nil
 }
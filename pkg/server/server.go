@@ -32,10 +32,13 @@ type Server struct {
 
 // Start boots up the server that runs on the specified port.
 // Returns an error if there is no connection established.
+// Each server has its own mux, so that the endpoints of a server
+// are not served on the port of another.
 func (s *Server) Start() error {
-	http.Handle(s.MetricsPath, s.Handler)
+	mux := http.NewServeMux()
+	mux.Handle(s.MetricsPath, s.Handler)
 	klog.Info("Starting HTTP server at http://localhost" + s.ListenPort + s.MetricsPath)
-	err := http.ListenAndServe(s.ListenPort, nil)
+	err := http.ListenAndServe(s.ListenPort, mux)
 	if err != nil {
 		klog.Error("error starting http server :", err)
 		return err
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package smart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReadReservation(t *testing.T) {
+	reserved := make([]byte, readReservationResponseSize)
+	reserved[7] = 0x10
+	copy(reserved[8:16], []byte{0, 0, 0, 0, 0xab, 0xcd, 0x12, 0x34})
+	reserved[21] = 0x03
+
+	tests := map[string]struct {
+		buf     []byte
+		want    ReservationInfo
+		wantErr bool
+	}{
+		"no reservation": {
+			buf:  make([]byte, readReservationResponseSize),
+			want: ReservationInfo{},
+		},
+		"reservation held": {
+			buf: reserved,
+			want: ReservationInfo{
+				Reserved: true,
+				Key:      0xabcd1234,
+				Type:     0x03,
+			},
+		},
+		"short response": {
+			buf:     []byte{0, 0, 0},
+			wantErr: true,
+		},
+		"truncated descriptor": {
+			buf:     []byte{0, 0, 0, 1, 0, 0, 0, 0x10, 0, 0},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseReadReservation(test.buf)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package surfacescan
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultSampleCount is the number of samples read from a device in a scan
+	DefaultSampleCount = 64
+	// DefaultSampleSize is the size in bytes of each sample read from a device
+	DefaultSampleSize = 64 * 1024
+)
+
+// Result is the result of the surface scan of a device
+type Result struct {
+	// SamplesRead is the number of samples that were read, successfully or not
+	SamplesRead int
+	// BytesRead is the number of bytes read successfully
+	BytesRead int64
+	// UnreadableOffsets are the offsets in bytes of the samples that could not be read
+	UnreadableOffsets []int64
+}
+
+// BoundedReader reads from the underlying reader at no more than the rate limit, and
+// accounts the bytes read and the offsets at which the reads failed
+type BoundedReader struct {
+	r io.ReaderAt
+	// rateLimit is the maximum number of bytes read per second
+	rateLimit int64
+	// sleep waits for the given duration, it can be replaced in tests
+	sleep func(time.Duration)
+
+	bytesRead         int64
+	unreadableOffsets []int64
+}
+
+// NewBoundedReader creates a reader that reads from r at no more than rateLimit bytes
+// per second
+func NewBoundedReader(r io.ReaderAt, rateLimit int64) *BoundedReader {
+	return &BoundedReader{
+		r:         r,
+		rateLimit: rateLimit,
+		sleep:     time.Sleep,
+	}
+}
+
+// ReadAt reads len(p) bytes at the offset. A failed read is accounted as unreadable at
+// the offset. The read is followed by a wait, so that the reads stay within the rate
+// limit even if they fail.
+func (br *BoundedReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := br.r.ReadAt(p, off)
+	br.bytesRead += int64(n)
+	if err != nil && err != io.EOF {
+		br.unreadableOffsets = append(br.unreadableOffsets, off)
+	}
+	if br.rateLimit > 0 {
+		br.sleep(time.Duration(int64(len(p)) * int64(time.Second) / br.rateLimit))
+	}
+	return n, err
+}
+
+// Scanner samples the sectors of a device by reading them, to find the sectors that
+// cannot be read. Only reads are done, the device is never written to.
+type Scanner struct {
+	// SampleCount is the number of samples read, spread evenly over the device
+	SampleCount int
+	// SampleSize is the size in bytes of each sample
+	SampleSize int64
+	// RateLimit is the maximum number of bytes read per second
+	RateLimit int64
+	// sleep waits for the given duration, it can be replaced in tests
+	sleep func(time.Duration)
+}
+
+// NewScanner creates a scanner with the default samples, which reads at no more than
+// rateLimit bytes per second
+func NewScanner(rateLimit int64) *Scanner {
+	return &Scanner{
+		SampleCount: DefaultSampleCount,
+		SampleSize:  DefaultSampleSize,
+		RateLimit:   rateLimit,
+		sleep:       time.Sleep,
+	}
+}
+
+// ScanDevice opens the device read only and scans it
+func (s *Scanner) ScanDevice(devPath string, size int64) (Result, error) {
+	f, err := os.Open(devPath)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	return s.Scan(f, size), nil
+}
+
+// Scan reads the samples from r of the given size. The samples are aligned to the sample
+// size, and spread evenly from the start to the end of the device.
+func (s *Scanner) Scan(r io.ReaderAt, size int64) Result {
+	br := NewBoundedReader(r, s.RateLimit)
+	br.sleep = s.sleep
+	buf := make([]byte, s.SampleSize)
+	result := Result{}
+	for _, offset := range getSampleOffsets(size, s.SampleSize, s.SampleCount) {
+		_, _ = br.ReadAt(buf, offset)
+		result.SamplesRead++
+	}
+	result.BytesRead = br.bytesRead
+	result.UnreadableOffsets = br.unreadableOffsets
+	return result
+}
+
+// getSampleOffsets gets the offsets of the samples spread evenly over the device. The
+// first sample is at the start of the device and the last sample at its end. Fewer
+// samples are returned if the device is too small.
+func getSampleOffsets(size, sampleSize int64, sampleCount int) []int64 {
+	if sampleSize <= 0 || sampleCount <= 0 || size < sampleSize {
+		return nil
+	}
+	lastSample := size/sampleSize - 1
+	if int64(sampleCount) > lastSample+1 {
+		sampleCount = int(lastSample + 1)
+	}
+	offsets := make([]int64, 0, sampleCount)
+	for i := 0; i < sampleCount; i++ {
+		var sample int64
+		if sampleCount > 1 {
+			sample = lastSample * int64(i) / int64(sampleCount-1)
+		}
+		offsets = append(offsets, sample*sampleSize)
+	}
+	return offsets
+}
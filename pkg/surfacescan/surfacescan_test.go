@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package surfacescan
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDevice is a device of the given size that fails the reads covering the bad offsets
+type fakeDevice struct {
+	size       int64
+	badOffsets []int64
+}
+
+func (f fakeDevice) ReadAt(p []byte, off int64) (int, error) {
+	for _, bad := range f.badOffsets {
+		if bad >= off && bad < off+int64(len(p)) {
+			return 0, fmt.Errorf("input/output error")
+		}
+	}
+	return len(p), nil
+}
+
+func TestGetSampleOffsets(t *testing.T) {
+	tests := map[string]struct {
+		size        int64
+		sampleSize  int64
+		sampleCount int
+		want        []int64
+	}{
+		"samples spread from the start to the end": {
+			size:        1000,
+			sampleSize:  10,
+			sampleCount: 3,
+			want:        []int64{0, 490, 990},
+		},
+		"single sample at the start": {
+			size:        1000,
+			sampleSize:  10,
+			sampleCount: 1,
+			want:        []int64{0},
+		},
+		"fewer samples on a small device": {
+			size:        30,
+			sampleSize:  10,
+			sampleCount: 5,
+			want:        []int64{0, 10, 20},
+		},
+		"device smaller than a sample": {
+			size:        5,
+			sampleSize:  10,
+			sampleCount: 5,
+			want:        nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getSampleOffsets(tt.size, tt.sampleSize, tt.sampleCount))
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := map[string]struct {
+		device        fakeDevice
+		wantOffsets   []int64
+		wantBytesRead int64
+	}{
+		"all samples readable": {
+			device:        fakeDevice{size: 1000},
+			wantOffsets:   nil,
+			wantBytesRead: 40,
+		},
+		"unreadable sectors in two samples": {
+			device:        fakeDevice{size: 1000, badOffsets: []int64{335, 999}},
+			wantOffsets:   []int64{330, 990},
+			wantBytesRead: 20,
+		},
+		"unreadable sector outside the samples is not found": {
+			device:        fakeDevice{size: 1000, badOffsets: []int64{500}},
+			wantOffsets:   nil,
+			wantBytesRead: 40,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var slept time.Duration
+			s := &Scanner{
+				SampleCount: 4,
+				SampleSize:  10,
+				RateLimit:   100,
+				sleep:       func(d time.Duration) { slept += d },
+			}
+			result := s.Scan(tt.device, tt.device.size)
+			assert.Equal(t, 4, result.SamplesRead)
+			assert.Equal(t, tt.wantOffsets, result.UnreadableOffsets)
+			assert.Equal(t, tt.wantBytesRead, result.BytesRead)
+			// 40 bytes at 100 bytes per second, failed reads are also rate limited
+			assert.Equal(t, 400*time.Millisecond, slept)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+// GetDiscardInfo gets whether the device supports discard (TRIM / UNMAP) and the
+// granularity in bytes at which it discards, as reported by
+// /sys/class/block/sda/queue/discard_granularity and discard_max_bytes.
+// A device supports discard only if both the values are non zero.
+func (s Device) GetDiscardInfo() (bool, uint64, error) {
+	granularity, err := readSysFSFileAsInt64(s.sysPath + "queue/discard_granularity")
+	if err != nil {
+		return false, 0, err
+	}
+	maxBytes, err := readSysFSFileAsInt64(s.sysPath + "queue/discard_max_bytes")
+	if err != nil {
+		return false, 0, err
+	}
+	if granularity <= 0 || maxBytes <= 0 {
+		return false, 0, nil
+	}
+	return true, uint64(granularity), nil
+}
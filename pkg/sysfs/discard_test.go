@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDiscardInfo(t *testing.T) {
+	tests := map[string]struct {
+		granularity     string
+		maxBytes        string
+		wantSupported   bool
+		wantGranularity uint64
+		wantErr         bool
+	}{
+		"ssd supporting discard": {
+			granularity:     "4096",
+			maxBytes:        "2147450880",
+			wantSupported:   true,
+			wantGranularity: 4096,
+		},
+		"hdd without discard": {
+			granularity: "0",
+			maxBytes:    "0",
+		},
+		"granularity reported without max bytes": {
+			granularity: "512",
+			maxBytes:    "0",
+		},
+		"queue attributes missing": {
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysPath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/"
+			assert.NoError(t, os.MkdirAll(sysPath+"queue", 0700))
+			if tt.granularity != "" {
+				assert.NoError(t, os.WriteFile(sysPath+"queue/discard_granularity", []byte(tt.granularity+"\n"), 0600))
+				assert.NoError(t, os.WriteFile(sysPath+"queue/discard_max_bytes", []byte(tt.maxBytes+"\n"), 0600))
+			}
+			s := Device{
+				deviceName: "sda",
+				path:       "/dev/sda",
+				sysPath:    sysPath,
+			}
+
+			supported, granularity, err := s.GetDiscardInfo()
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantSupported, supported)
+			assert.Equal(t, tt.wantGranularity, granularity)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+)
+
+const (
+	// iserProcName is the proc_name of the scsi hosts created by the ib_iser driver
+	iserProcName = "iser"
+)
+
+var (
+	nvmeControllerRegex = regexp.MustCompile(`^nvme[0-9]+$`)
+	scsiHostRegex       = regexp.MustCompile(`^host[0-9]+$`)
+	iscsiSessionRegex   = regexp.MustCompile(`^session[0-9]+$`)
+)
+
+// FabricInfo is the transport related information of a device
+type FabricInfo struct {
+	// Transport is the transport of the device, eg: nvme-tcp, iser
+	Transport string
+	// Target is the NQN / IQN of the target exporting the device
+	Target string
+	// Address is the address of the target
+	Address string
+}
+
+// GetFabricInfo gets the transport of NVMe and iSER devices, along with the target and
+// its address for the fabric transports. An empty FabricInfo is returned for devices
+// whose transport cannot be identified.
+func (s Device) GetFabricInfo() (FabricInfo, error) {
+	if strings.HasPrefix(s.deviceName, "nvme") {
+		return s.getNVMeFabricInfo()
+	}
+	return s.getISERFabricInfo(), nil
+}
+
+// getNVMeFabricInfo reads the transport, subsystem NQN and address from the NVMe controller
+// of the namespace. The syspath of the namespace will be similar to
+// /sys/devices/virtual/nvme-fabrics/ctl/nvme0/nvme0n1/ or, if native NVMe multipath is
+// enabled, /sys/devices/virtual/nvme-subsystem/nvme-subsys0/nvme0n1/
+func (s Device) getNVMeFabricInfo() (FabricInfo, error) {
+	controllerPath, ok := s.getNVMeControllerPath()
+	if !ok {
+		return FabricInfo{}, nil
+	}
+
+	transport, err := readSysFSFileAsString(filepath.Join(controllerPath, "transport"))
+	if err != nil {
+		return FabricInfo{}, err
+	}
+	info := FabricInfo{
+		Transport: "nvme-" + transport,
+	}
+	if !blockdevice.IsFabricTransport(info.Transport) {
+		return info, nil
+	}
+
+	// target and address are informational, so errors in reading them are ignored
+	info.Target, _ = readSysFSFileAsString(filepath.Join(controllerPath, "subsysnqn"))
+	info.Address, _ = readSysFSFileAsString(filepath.Join(controllerPath, "address"))
+	return info, nil
+}
+
+// getNVMeControllerPath gets the sysfs path of the controller of the NVMe namespace. If
+// the namespace belongs to a subsystem, the path of first controller of the subsystem
+// is returned.
+func (s Device) getNVMeControllerPath() (string, bool) {
+	parentPath := filepath.Dir(strings.TrimSuffix(s.sysPath, "/"))
+	if fileExists(filepath.Join(parentPath, "transport")) {
+		return parentPath, true
+	}
+
+	// the namespace is under an nvme subsystem, which links to all its controllers
+	files, err := ioutil.ReadDir(parentPath)
+	if err != nil {
+		return "", false
+	}
+	for _, file := range files {
+		if !nvmeControllerRegex.MatchString(file.Name()) {
+			continue
+		}
+		controllerPath := filepath.Join(parentPath, file.Name())
+		if fileExists(filepath.Join(controllerPath, "transport")) {
+			return controllerPath, true
+		}
+	}
+	return "", false
+}
+
+// getISERFabricInfo checks whether the device is an iSCSI device attached using iSER and
+// reads the target name and portal from the iSCSI session. The syspath of the device
+// will be similar to /sys/devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdc/
+func (s Device) getISERFabricInfo() FabricInfo {
+	var hostPath, host, sessionPath, session string
+	path := "/"
+	for _, part := range strings.Split(s.sysPath, "/") {
+		path = filepath.Join(path, part)
+		if scsiHostRegex.MatchString(part) && hostPath == "" {
+			hostPath, host = path, part
+		}
+		if iscsiSessionRegex.MatchString(part) {
+			sessionPath, session = path, part
+			break
+		}
+	}
+	if hostPath == "" || sessionPath == "" {
+		return FabricInfo{}
+	}
+
+	procName, err := readSysFSFileAsString(filepath.Join(hostPath, "scsi_host", host, "proc_name"))
+	if err != nil || procName != iserProcName {
+		return FabricInfo{}
+	}
+
+	info := FabricInfo{
+		Transport: blockdevice.TransportISER,
+	}
+	info.Target, _ = readSysFSFileAsString(filepath.Join(sessionPath, "iscsi_session", session, "targetname"))
+
+	// the first connection of the session, session1 will have the connection connection1:0
+	connection := strings.Replace(session, "session", "connection", 1) + ":0"
+	connectionPath := filepath.Join(sessionPath, connection, "iscsi_connection", connection)
+	address, err := readSysFSFileAsString(filepath.Join(connectionPath, "persistent_address"))
+	if err == nil {
+		info.Address = address
+		if port, err := readSysFSFileAsString(filepath.Join(connectionPath, "persistent_port")); err == nil {
+			info.Address = address + ":" + port
+		}
+	}
+	return info
+}
+
+// fileExists checks if the file exists in sysfs
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
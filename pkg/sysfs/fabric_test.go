@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSysFSFiles creates the files with the given content under the root directory
+func writeSysFSFiles(t *testing.T, root string, files map[string]string) {
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSysFsDeviceGetFabricInfo(t *testing.T) {
+	tests := map[string]struct {
+		deviceName string
+		sysPath    string
+		files      map[string]string
+		// symlinks from the key to the value, relative to the root directory
+		links map[string]string
+		want  FabricInfo
+	}{
+		"nvme-tcp namespace": {
+			deviceName: "nvme1n1",
+			sysPath:    "devices/virtual/nvme-fabrics/ctl/nvme1/nvme1n1/",
+			files: map[string]string{
+				"devices/virtual/nvme-fabrics/ctl/nvme1/transport": "tcp",
+				"devices/virtual/nvme-fabrics/ctl/nvme1/subsysnqn": "nqn.2014-08.org.nvmexpress:target1",
+				"devices/virtual/nvme-fabrics/ctl/nvme1/address":   "traddr=10.0.0.1,trsvcid=4420",
+			},
+			want: FabricInfo{
+				Transport: blockdevice.TransportNVMeTCP,
+				Target:    "nqn.2014-08.org.nvmexpress:target1",
+				Address:   "traddr=10.0.0.1,trsvcid=4420",
+			},
+		},
+		"nvme-rdma namespace with native multipath": {
+			deviceName: "nvme2n1",
+			sysPath:    "devices/virtual/nvme-subsystem/nvme-subsys2/nvme2n1/",
+			files: map[string]string{
+				"devices/virtual/nvme-fabrics/ctl/nvme2/transport":         "rdma",
+				"devices/virtual/nvme-fabrics/ctl/nvme2/subsysnqn":         "nqn.2014-08.org.nvmexpress:target2",
+				"devices/virtual/nvme-fabrics/ctl/nvme2/address":           "traddr=10.0.0.2,trsvcid=4420",
+				"devices/virtual/nvme-subsystem/nvme-subsys2/nvme2n1/size": "2048",
+			},
+			links: map[string]string{
+				"devices/virtual/nvme-subsystem/nvme-subsys2/nvme2": "devices/virtual/nvme-fabrics/ctl/nvme2",
+			},
+			want: FabricInfo{
+				Transport: blockdevice.TransportNVMeRDMA,
+				Target:    "nqn.2014-08.org.nvmexpress:target2",
+				Address:   "traddr=10.0.0.2,trsvcid=4420",
+			},
+		},
+		"local nvme namespace": {
+			deviceName: "nvme0n1",
+			sysPath:    "devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n1/",
+			files: map[string]string{
+				"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/transport": "pcie",
+				"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/subsysnqn": "nqn.2014.08.org.nvmexpress:local",
+			},
+			want: FabricInfo{
+				Transport: blockdevice.TransportNVMePCIe,
+			},
+		},
+		"iser device": {
+			deviceName: "sdc",
+			sysPath:    "devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdc/",
+			files: map[string]string{
+				"devices/platform/host3/scsi_host/host3/proc_name":                                                "iser",
+				"devices/platform/host3/session1/iscsi_session/session1/targetname":                               "iqn.2003-01.org.linux-iscsi.target:sn.1",
+				"devices/platform/host3/session1/connection1:0/iscsi_connection/connection1:0/persistent_address": "10.0.0.3",
+				"devices/platform/host3/session1/connection1:0/iscsi_connection/connection1:0/persistent_port":    "3260",
+			},
+			want: FabricInfo{
+				Transport: blockdevice.TransportISER,
+				Target:    "iqn.2003-01.org.linux-iscsi.target:sn.1",
+				Address:   "10.0.0.3:3260",
+			},
+		},
+		"iscsi device over tcp": {
+			deviceName: "sdd",
+			sysPath:    "devices/platform/host4/session2/target4:0:0/4:0:0:1/block/sdd/",
+			files: map[string]string{
+				"devices/platform/host4/scsi_host/host4/proc_name":                  "iscsi_tcp",
+				"devices/platform/host4/session2/iscsi_session/session2/targetname": "iqn.2003-01.org.linux-iscsi.target:sn.2",
+			},
+			want: FabricInfo{},
+		},
+		"local sata disk": {
+			deviceName: "sda",
+			sysPath:    "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			files: map[string]string{
+				"devices/pci0000:00/0000:00:1f.2/ata1/host0/scsi_host/host0/proc_name": "ahci",
+			},
+			want: FabricInfo{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeSysFSFiles(t, root, tt.files)
+			for link, target := range tt.links {
+				if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, link)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := Device{
+				deviceName: tt.deviceName,
+				path:       "/dev/" + tt.deviceName,
+				sysPath:    filepath.Join(root, tt.sysPath) + "/",
+			}
+			got, err := s.GetFabricInfo()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetIOErrorCount gets the number of IO requests of the SCSI device that completed with
+// an error since the device was attached, as reported by /sys/class/block/sda/device/ioerr_cnt
+// The count is in hex, eg: 0x1a. false is returned if the device is not a SCSI device.
+func (s Device) GetIOErrorCount() (uint64, bool, error) {
+	countPath := s.sysPath + "device/ioerr_cnt"
+	if _, err := os.Stat(countPath); os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	count, err := readSysFSFileAsString(countPath)
+	if err != nil {
+		return 0, false, err
+	}
+	ioErrors, err := strconv.ParseUint(count, 0, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return ioErrors, true, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIOErrorCount(t *testing.T) {
+	tests := map[string]struct {
+		ioErrCount string
+		wantCount  uint64
+		wantOk     bool
+		wantErr    bool
+	}{
+		"scsi device without errors": {
+			ioErrCount: "0x0",
+			wantCount:  0,
+			wantOk:     true,
+		},
+		"scsi device with errors": {
+			ioErrCount: "0x1a",
+			wantCount:  26,
+			wantOk:     true,
+		},
+		"device without error counter": {
+			wantOk: false,
+		},
+		"invalid error counter": {
+			ioErrCount: "none",
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysPath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/"
+			assert.NoError(t, os.MkdirAll(sysPath+"device", 0700))
+			if tt.ioErrCount != "" {
+				assert.NoError(t, os.WriteFile(sysPath+"device/ioerr_cnt", []byte(tt.ioErrCount+"\n"), 0600))
+			}
+			s := Device{
+				deviceName: "sda",
+				path:       "/dev/sda",
+				sysPath:    sysPath,
+			}
+
+			count, ok, err := s.GetIOErrorCount()
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// deletedBackingFileSuffix is appended by the kernel to the backing file of a loop
+// device, if the file was deleted after the device was set up
+const deletedBackingFileSuffix = " (deleted)"
+
+// GetLoopBackingFile gets the file backing the loop device, as reported by
+// /sys/block/loop0/loop/backing_file. false is returned if the device is not a
+// loop device, or the loop device is not attached to any file.
+func (s Device) GetLoopBackingFile() (string, bool, error) {
+	backingFile, err := readSysFSFileAsString(filepath.Join(s.sysPath, "loop", "backing_file"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	backingFile = strings.TrimSuffix(strings.TrimSpace(backingFile), deletedBackingFileSuffix)
+	if backingFile == "" {
+		return "", false, nil
+	}
+	return backingFile, true, nil
+}
+
+// IsSparseFile checks if the file has fewer blocks allocated than its size, which
+// is the case for the sparse image files commonly used to back loop devices.
+func IsSparseFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, nil
+	}
+	// st_blocks is always in 512 byte units
+	return stat.Blocks*sectorSize < info.Size(), nil
+}
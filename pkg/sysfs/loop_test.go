@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLoopBackingFile(t *testing.T) {
+	tests := map[string]struct {
+		deviceName      string
+		backingFile     string
+		wantBackingFile string
+		wantOk          bool
+	}{
+		"loop device backed by an image file": {
+			deviceName:      "loop0",
+			backingFile:     "/var/lib/images/disk0.img\n",
+			wantBackingFile: "/var/lib/images/disk0.img",
+			wantOk:          true,
+		},
+		"loop device backed by a deleted file": {
+			deviceName:      "loop1",
+			backingFile:     "/tmp/disk1.img (deleted)\n",
+			wantBackingFile: "/tmp/disk1.img",
+			wantOk:          true,
+		},
+		"detached loop device": {
+			deviceName: "loop2",
+		},
+		"disk": {
+			deviceName: "sda",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysPath := filepath.Join(t.TempDir(), "sys/devices/virtual/block", tt.deviceName) + "/"
+			assert.NoError(t, os.MkdirAll(sysPath, 0700))
+			if tt.backingFile != "" {
+				assert.NoError(t, os.MkdirAll(filepath.Join(sysPath, "loop"), 0700))
+				assert.NoError(t, os.WriteFile(filepath.Join(sysPath, "loop", "backing_file"),
+					[]byte(tt.backingFile), 0600))
+			}
+			s := Device{
+				deviceName: tt.deviceName,
+				sysPath:    sysPath,
+				path:       "/dev/" + tt.deviceName,
+			}
+			backingFile, ok, err := s.GetLoopBackingFile()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantBackingFile, backingFile)
+		})
+	}
+}
+
+func TestIsSparseFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sparseFile := filepath.Join(tmpDir, "sparse.img")
+	f, err := os.Create(sparseFile)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Truncate(64<<20))
+	assert.NoError(t, f.Close())
+	sparse, err := IsSparseFile(sparseFile)
+	assert.NoError(t, err)
+	assert.True(t, sparse)
+
+	denseFile := filepath.Join(tmpDir, "dense.img")
+	assert.NoError(t, os.WriteFile(denseFile, make([]byte, 64<<10), 0600))
+	sparse, err = IsSparseFile(denseFile)
+	assert.NoError(t, err)
+	assert.False(t, sparse)
+
+	_, err = IsSparseFile(filepath.Join(tmpDir, "missing.img"))
+	assert.Error(t, err)
+}
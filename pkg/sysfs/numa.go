@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pciAddressRegex is the format of the address of a PCI device, eg: 0000:00:1f.2
+var pciAddressRegex = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// GetNUMANode gets the NUMA node to which the PCI device, the device is attached over, is
+// attached, as reported by /sys/bus/pci/devices/0000:01:00.0/numa_node. The PCI device is
+// the nearest PCI component of the syspath of the device, eg: 0000:01:00.0 for
+// /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/nvme/nvme0/nvme0n1/
+// false is returned if the device is not attached over PCI, or the PCI device has no
+// NUMA affinity, i.e the numa node is -1.
+func (s Device) GetNUMANode() (int, bool, error) {
+	pciPath, ok := getPCIDevicePath(s.sysPath)
+	if !ok {
+		return 0, false, nil
+	}
+	numaNode, err := readSysFSFileAsInt64(filepath.Join(pciPath, "numa_node"))
+	if err != nil {
+		return 0, false, err
+	}
+	if numaNode < 0 {
+		return 0, false, nil
+	}
+	return int(numaNode), true, nil
+}
+
+// getPCIDevicePath gets the path of the nearest PCI component of the sysfs path.
+// false is returned if the path has no PCI component.
+func getPCIDevicePath(sysPath string) (string, bool) {
+	parts := strings.Split(strings.TrimSuffix(sysPath, "/"), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if pciAddressRegex.MatchString(parts[i]) {
+			return strings.Join(parts[:i+1], "/"), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNUMANode(t *testing.T) {
+	tests := map[string]struct {
+		// pciPath is the path of the PCI device in the fake sysfs having the numa node
+		pciPath      string
+		devicePath   string
+		numaNode     string
+		wantNUMANode int
+		wantOk       bool
+		wantErr      bool
+	}{
+		"nvme disk behind a pci bridge": {
+			pciPath:      "sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0",
+			devicePath:   "nvme/nvme0/nvme0n1",
+			numaNode:     "1",
+			wantNUMANode: 1,
+			wantOk:       true,
+		},
+		"sas disk attached to an hba": {
+			pciPath:      "sys/devices/pci0000:80/0000:80:02.0/0000:81:00.0",
+			devicePath:   "host0/port-0:0/end_device-0:0/target0:0:0/0:0:0:0/block/sdb",
+			numaNode:     "0",
+			wantNUMANode: 0,
+			wantOk:       true,
+		},
+		"pci device without numa affinity": {
+			pciPath:    "sys/devices/pci0000:00/0000:00:1f.2",
+			devicePath: "ata1/host0/target0:0:0/0:0:0:0/block/sda",
+			numaNode:   "-1",
+		},
+		"device not attached over pci": {
+			devicePath: "sys/devices/virtual/block/dm-0",
+		},
+		"numa node of pci device missing": {
+			pciPath:    "sys/devices/pci0000:00/0000:00:04.0",
+			devicePath: "virtio1/block/vda",
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			pciPath := filepath.Join(root, tt.pciPath)
+			sysPath := filepath.Join(pciPath, tt.devicePath) + "/"
+			assert.NoError(t, os.MkdirAll(sysPath, 0700))
+			if tt.numaNode != "" {
+				assert.NoError(t, os.WriteFile(filepath.Join(pciPath, "numa_node"), []byte(tt.numaNode+"\n"), 0600))
+			}
+
+			s := Device{sysPath: sysPath}
+			numaNode, ok, err := s.GetNUMANode()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantNUMANode, numaNode)
+		})
+	}
+}
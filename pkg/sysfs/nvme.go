@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// NVMeControllerInfo is the identity of the NVMe controller of a namespace. All the
+// namespaces exposed by a controller share the same physical device.
+type NVMeControllerInfo struct {
+	// Name is the name of the controller, eg: nvme0
+	Name string
+	// Serial is the serial number of the controller
+	Serial string
+	// ID is the controller id (cntlid) within the NVM subsystem
+	ID string
+}
+
+// GetNVMeControllerInfo gets the identity of the NVMe controller of the namespace from
+// /sys/class/nvme/nvme0/{serial,cntlid}. The controller of the namespace is used for a
+// partition of the namespace. false is returned if the device is not an NVMe namespace
+// or its controller cannot be found.
+func (s Device) GetNVMeControllerInfo() (NVMeControllerInfo, bool) {
+	if parent, ok := s.getParent(); ok {
+		parentDevice := Device{
+			deviceName: parent,
+			path:       "/dev/" + parent,
+			sysPath:    filepath.Dir(strings.TrimSuffix(s.sysPath, "/")) + "/",
+		}
+		return parentDevice.GetNVMeControllerInfo()
+	}
+
+	if !strings.HasPrefix(s.deviceName, "nvme") {
+		return NVMeControllerInfo{}, false
+	}
+	controllerPath, ok := s.getNVMeControllerPath()
+	if !ok {
+		return NVMeControllerInfo{}, false
+	}
+	info := NVMeControllerInfo{
+		Name: filepath.Base(controllerPath),
+	}
+	// the controller is identified even if the attributes cannot be read
+	serial, _ := readSysFSFileAsString(filepath.Join(controllerPath, "serial"))
+	// the serial number is padded with spaces by the controller
+	info.Serial = strings.TrimSpace(serial)
+	info.ID, _ = readSysFSFileAsString(filepath.Join(controllerPath, "cntlid"))
+	return info, true
+}
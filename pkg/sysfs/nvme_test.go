@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSysFsDeviceGetNVMeControllerInfo(t *testing.T) {
+	// two namespaces exposed by a single controller
+	files := map[string]string{
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/transport":              "pcie",
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/serial":                 "S4EVNX0N123456      ",
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/cntlid":                 "4",
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n1/size":           "2048",
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n2/size":           "2048",
+		"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n2/nvme0n2p1/size": "1024",
+	}
+	tests := map[string]struct {
+		deviceName string
+		sysPath    string
+		want       NVMeControllerInfo
+		wantOK     bool
+	}{
+		"first namespace of the controller": {
+			deviceName: "nvme0n1",
+			sysPath:    "devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n1/",
+			want: NVMeControllerInfo{
+				Name:   "nvme0",
+				Serial: "S4EVNX0N123456",
+				ID:     "4",
+			},
+			wantOK: true,
+		},
+		"second namespace of the controller": {
+			deviceName: "nvme0n2",
+			sysPath:    "devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n2/",
+			want: NVMeControllerInfo{
+				Name:   "nvme0",
+				Serial: "S4EVNX0N123456",
+				ID:     "4",
+			},
+			wantOK: true,
+		},
+		"partition of a namespace": {
+			deviceName: "nvme0n2p1",
+			sysPath:    "devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n2/nvme0n2p1/",
+			want: NVMeControllerInfo{
+				Name:   "nvme0",
+				Serial: "S4EVNX0N123456",
+				ID:     "4",
+			},
+			wantOK: true,
+		},
+		"scsi disk": {
+			deviceName: "sda",
+			sysPath:    "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			wantOK:     false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeSysFSFiles(t, root, files)
+			s := Device{
+				deviceName: tt.deviceName,
+				path:       "/dev/" + tt.deviceName,
+				sysPath:    filepath.Join(root, tt.sysPath) + "/",
+			}
+			got, ok := s.GetNVMeControllerInfo()
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"fmt"
+)
+
+// GetPartitionNumber gets the number of the partition in the partition table of the
+// parent disk, as reported by /sys/class/block/sda1/partition
+func (s Device) GetPartitionNumber() (uint8, error) {
+	number, err := readSysFSFileAsInt64(s.sysPath + "partition")
+	if err != nil {
+		return 0, err
+	}
+	if number <= 0 || number > 255 {
+		return 0, fmt.Errorf("invalid partition number %d", number)
+	}
+	return uint8(number), nil
+}
+
+// GetPartitionStartOffset gets the offset in bytes of the start of the partition from
+// the start of the parent disk. The start (/start) entry is always in 512 byte sectors,
+// irrespective of the logical block size of the disk.
+func (s Device) GetPartitionStartOffset() (uint64, error) {
+	start, err := readSysFSFileAsInt64(s.sysPath + "start")
+	if err != nil {
+		return 0, err
+	}
+	if start < 0 {
+		return 0, fmt.Errorf("invalid partition start sector %d", start)
+	}
+	return uint64(start * sectorSize), nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPartitionInfo(t *testing.T) {
+	tests := map[string]struct {
+		number          string
+		start           string
+		wantNumber      uint8
+		wantStartOffset uint64
+		wantErr         bool
+	}{
+		"first partition": {
+			number:          "1",
+			start:           "2048",
+			wantNumber:      1,
+			wantStartOffset: 1048576,
+		},
+		"third partition": {
+			number:          "3",
+			start:           "4196352",
+			wantNumber:      3,
+			wantStartOffset: 2148532224,
+		},
+		"not a partition": {
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysPath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1") + "/"
+			assert.NoError(t, os.MkdirAll(sysPath, 0700))
+			if tt.number != "" {
+				assert.NoError(t, os.WriteFile(sysPath+"partition", []byte(tt.number+"\n"), 0600))
+				assert.NoError(t, os.WriteFile(sysPath+"start", []byte(tt.start+"\n"), 0600))
+			}
+			s := Device{
+				deviceName: "sda1",
+				path:       "/dev/sda1",
+				sysPath:    sysPath,
+			}
+
+			number, err := s.GetPartitionNumber()
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantNumber, number)
+
+			startOffset, err := s.GetPartitionStartOffset()
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantStartOffset, startOffset)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+)
+
+// nvmeTransportPCIe is the transport of the NVMe controllers attached over PCIe
+const nvmeTransportPCIe = "pcie"
+
+// GetPCIeLink gets the negotiated and the maximum speed / width of the PCIe link of the
+// controller of an NVMe namespace, from the link attributes of the PCI device of the
+// controller, eg: /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/current_link_speed
+// for the controller /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/nvme/nvme0.
+// false is returned if the device is not an NVMe namespace attached over PCIe.
+func (s Device) GetPCIeLink() (blockdevice.PCIeLink, bool, error) {
+	if !strings.HasPrefix(s.deviceName, "nvme") {
+		return blockdevice.PCIeLink{}, false, nil
+	}
+	controllerPath, ok := s.getNVMeControllerPath()
+	if !ok {
+		return blockdevice.PCIeLink{}, false, nil
+	}
+	transport, err := readSysFSFileAsString(filepath.Join(controllerPath, "transport"))
+	if err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	if transport != nvmeTransportPCIe {
+		return blockdevice.PCIeLink{}, false, nil
+	}
+
+	// the controllers of a subsystem are links to the controllers under the PCI devices
+	controllerPath, err = filepath.EvalSymlinks(controllerPath)
+	if err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	pciPath, ok := getPCIDevicePath(controllerPath)
+	if !ok {
+		return blockdevice.PCIeLink{}, false, nil
+	}
+
+	link := blockdevice.PCIeLink{}
+	if link.CurrentSpeed, err = readPCIeLinkSpeed(filepath.Join(pciPath, "current_link_speed")); err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	if link.MaxSpeed, err = readPCIeLinkSpeed(filepath.Join(pciPath, "max_link_speed")); err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	currentWidth, err := readSysFSFileAsInt64(filepath.Join(pciPath, "current_link_width"))
+	if err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	maxWidth, err := readSysFSFileAsInt64(filepath.Join(pciPath, "max_link_width"))
+	if err != nil {
+		return blockdevice.PCIeLink{}, false, err
+	}
+	link.CurrentWidth = int(currentWidth)
+	link.MaxWidth = int(maxWidth)
+	return link, true, nil
+}
+
+// readPCIeLinkSpeed reads the speed of a PCIe link in GT/s. The speed is reported as
+// "8 GT/s" by older kernels and as "8.0 GT/s PCIe" by newer kernels.
+func readPCIeLinkSpeed(sysFilePath string) (float64, error) {
+	value, err := readSysFSFileAsString(sysFilePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(value)
+	if len(fields) < 2 || fields[1] != "GT/s" {
+		return 0, fmt.Errorf("unknown pcie link speed: %q in %s", value, sysFilePath)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
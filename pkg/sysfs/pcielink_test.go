@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+// pcieLinkFiles gets the link attributes of the fake PCI device 0000:01:00.0
+func pcieLinkFiles(currentSpeed, maxSpeed, currentWidth, maxWidth string) map[string]string {
+	pciPath := "devices/pci0000:00/0000:00:01.0/0000:01:00.0/"
+	return map[string]string{
+		pciPath + "nvme/nvme0/transport": "pcie",
+		pciPath + "current_link_speed":   currentSpeed,
+		pciPath + "max_link_speed":       maxSpeed,
+		pciPath + "current_link_width":   currentWidth,
+		pciPath + "max_link_width":       maxWidth,
+	}
+}
+
+func TestGetPCIeLink(t *testing.T) {
+	localNamespace := "devices/pci0000:00/0000:00:01.0/0000:01:00.0/nvme/nvme0/nvme0n1/"
+	tests := map[string]struct {
+		deviceName string
+		sysPath    string
+		files      map[string]string
+		// symlinks from the key to the value, relative to the root directory
+		links    map[string]string
+		wantLink blockdevice.PCIeLink
+		wantOk   bool
+		wantErr  bool
+	}{
+		"link at full speed and width": {
+			deviceName: "nvme0n1",
+			sysPath:    localNamespace,
+			files:      pcieLinkFiles("16.0 GT/s PCIe", "16.0 GT/s PCIe", "4", "4"),
+			wantLink:   blockdevice.PCIeLink{CurrentSpeed: 16, MaxSpeed: 16, CurrentWidth: 4, MaxWidth: 4},
+			wantOk:     true,
+		},
+		"link degraded in speed and width": {
+			deviceName: "nvme0n1",
+			sysPath:    localNamespace,
+			files:      pcieLinkFiles("8.0 GT/s PCIe", "16.0 GT/s PCIe", "2", "4"),
+			wantLink:   blockdevice.PCIeLink{CurrentSpeed: 8, MaxSpeed: 16, CurrentWidth: 2, MaxWidth: 4},
+			wantOk:     true,
+		},
+		"link speed in the format of older kernels": {
+			deviceName: "nvme0n1",
+			sysPath:    localNamespace,
+			files:      pcieLinkFiles("2.5 GT/s", "8 GT/s", "4", "4"),
+			wantLink:   blockdevice.PCIeLink{CurrentSpeed: 2.5, MaxSpeed: 8, CurrentWidth: 4, MaxWidth: 4},
+			wantOk:     true,
+		},
+		"namespace of a subsystem with native multipath": {
+			deviceName: "nvme0n1",
+			sysPath:    "devices/virtual/nvme-subsystem/nvme-subsys0/nvme0n1/",
+			files:      pcieLinkFiles("8.0 GT/s PCIe", "8.0 GT/s PCIe", "1", "4"),
+			links: map[string]string{
+				"devices/virtual/nvme-subsystem/nvme-subsys0/nvme0": "devices/pci0000:00/0000:00:01.0/0000:01:00.0/nvme/nvme0",
+			},
+			wantLink: blockdevice.PCIeLink{CurrentSpeed: 8, MaxSpeed: 8, CurrentWidth: 1, MaxWidth: 4},
+			wantOk:   true,
+		},
+		"unknown link speed": {
+			deviceName: "nvme0n1",
+			sysPath:    localNamespace,
+			files:      pcieLinkFiles("Unknown", "16.0 GT/s PCIe", "4", "4"),
+			wantErr:    true,
+		},
+		"nvme-tcp namespace": {
+			deviceName: "nvme1n1",
+			sysPath:    "devices/virtual/nvme-fabrics/ctl/nvme1/nvme1n1/",
+			files: map[string]string{
+				"devices/virtual/nvme-fabrics/ctl/nvme1/transport": "tcp",
+			},
+		},
+		"sata disk": {
+			deviceName: "sda",
+			sysPath:    "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			files: map[string]string{
+				"devices/pci0000:00/0000:00:1f.2/current_link_speed": "8.0 GT/s PCIe",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeSysFSFiles(t, root, tt.files)
+			for link, target := range tt.links {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, link)), 0700))
+				assert.NoError(t, os.Symlink(filepath.Join(root, target), filepath.Join(root, link)))
+			}
+			s := Device{
+				deviceName: tt.deviceName,
+				path:       "/dev/" + tt.deviceName,
+				sysPath:    filepath.Join(root, tt.sysPath) + "/",
+			}
+			link, ok, err := s.GetPCIeLink()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantLink, link)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetIOScheduler gets the active IO scheduler of the device, as reported by
+// /sys/class/block/sda/queue/scheduler, eg: mq-deadline, none
+func (s Device) GetIOScheduler() (string, error) {
+	schedulers, err := readSysFSFileAsString(s.sysPath + "queue/scheduler")
+	if err != nil {
+		return "", err
+	}
+	return parseIOScheduler(schedulers)
+}
+
+// GetQueueDepth gets the number of requests that can be queued for the device in the
+// block layer, as reported by /sys/class/block/sda/queue/nr_requests
+func (s Device) GetQueueDepth() (uint64, error) {
+	nrRequests, err := readSysFSFileAsInt64(s.sysPath + "queue/nr_requests")
+	if err != nil {
+		return 0, err
+	}
+	if nrRequests < 0 {
+		return 0, fmt.Errorf("invalid nr_requests value %d", nrRequests)
+	}
+	return uint64(nrRequests), nil
+}
+
+// parseIOScheduler gets the active scheduler from the contents of the scheduler file. The
+// file lists the available schedulers with the active one in brackets, eg:
+// "[mq-deadline] kyber bfq none". Devices for which the scheduler cannot be selected list
+// only the active scheduler, which may not be in brackets, eg: "none".
+func parseIOScheduler(schedulers string) (string, error) {
+	fields := strings.Fields(schedulers)
+	for _, field := range fields {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return strings.TrimSuffix(strings.TrimPrefix(field, "["), "]"), nil
+		}
+	}
+	if len(fields) == 1 {
+		return fields[0], nil
+	}
+	return "", fmt.Errorf("no active scheduler in %q", schedulers)
+}
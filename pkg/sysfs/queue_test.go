@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIOScheduler(t *testing.T) {
+	tests := map[string]struct {
+		schedulers string
+		want       string
+		wantErr    bool
+	}{
+		"sata disk with mq-deadline": {
+			schedulers: "[mq-deadline] kyber bfq none\n",
+			want:       "mq-deadline",
+		},
+		"nvme disk without scheduler": {
+			schedulers: "[none] mq-deadline kyber bfq\n",
+			want:       "none",
+		},
+		"bfq selected": {
+			schedulers: "mq-deadline kyber [bfq] none\n",
+			want:       "bfq",
+		},
+		"device without scheduler choice": {
+			schedulers: "none\n",
+			want:       "none",
+		},
+		"no active scheduler": {
+			schedulers: "mq-deadline kyber bfq none\n",
+			wantErr:    true,
+		},
+		"empty scheduler file": {
+			schedulers: "",
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseIOScheduler(tt.schedulers)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetIOSchedulerAndQueueDepth(t *testing.T) {
+	sysPath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/"
+	s := Device{
+		deviceName: "sda",
+		sysPath:    sysPath,
+		path:       "/dev/sda",
+	}
+
+	// queue attributes missing, eg: for a partition
+	_, err := s.GetIOScheduler()
+	assert.Error(t, err)
+	_, err = s.GetQueueDepth()
+	assert.Error(t, err)
+
+	assert.NoError(t, os.MkdirAll(sysPath+"queue", 0700))
+	assert.NoError(t, os.WriteFile(sysPath+"queue/scheduler", []byte("[mq-deadline] kyber bfq none\n"), 0600))
+	assert.NoError(t, os.WriteFile(sysPath+"queue/nr_requests", []byte("64\n"), 0600))
+
+	scheduler, err := s.GetIOScheduler()
+	assert.NoError(t, err)
+	assert.Equal(t, "mq-deadline", scheduler)
+	queueDepth, err := s.GetQueueDepth()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(64), queueDepth)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// scsiDeviceStateRunning is the state of a SCSI device that is ready to accept IO
+	scsiDeviceStateRunning = "running"
+	// nvmeControllerStateLive is the state of an NVMe controller that is ready to accept IO
+	nvmeControllerStateLive = "live"
+)
+
+// IsReady checks whether the device is ready to accept IO. A SCSI device is ready if its
+// state (/sys/class/block/sda/device/state) is running and an NVMe namespace is ready if
+// the state of its controller (/sys/class/nvme/nvme0/state) is live. A partition is ready
+// if its disk is ready. Devices that do not report a state, like dm and loop devices, are
+// always ready. The state of the device is returned along with the readiness.
+func (s Device) IsReady() (bool, string) {
+	if parent, ok := s.getParent(); ok {
+		parentDevice := Device{
+			deviceName: parent,
+			path:       "/dev/" + parent,
+			sysPath:    filepath.Dir(strings.TrimSuffix(s.sysPath, "/")) + "/",
+		}
+		return parentDevice.IsReady()
+	}
+
+	if strings.HasPrefix(s.deviceName, "nvme") {
+		controllerPath, ok := s.getNVMeControllerPath()
+		if !ok {
+			return true, ""
+		}
+		state, err := readSysFSFileAsString(filepath.Join(controllerPath, "state"))
+		if err != nil {
+			return true, ""
+		}
+		return state == nvmeControllerStateLive, state
+	}
+
+	state, err := readSysFSFileAsString(s.sysPath + "device/state")
+	if err != nil {
+		return true, ""
+	}
+	return state == scsiDeviceStateRunning, state
+}
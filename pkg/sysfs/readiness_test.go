@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSysFsDeviceIsReady(t *testing.T) {
+	tests := map[string]struct {
+		deviceName string
+		sysPath    string
+		files      map[string]string
+		wantReady  bool
+		wantState  string
+	}{
+		"running scsi disk": {
+			deviceName: "sda",
+			sysPath:    "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			files: map[string]string{
+				"devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/device/state": "running",
+			},
+			wantReady: true,
+			wantState: "running",
+		},
+		"blocked scsi disk": {
+			deviceName: "sdb",
+			sysPath:    "devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdb/",
+			files: map[string]string{
+				"devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdb/device/state": "blocked",
+			},
+			wantReady: false,
+			wantState: "blocked",
+		},
+		"partition of an offline scsi disk": {
+			deviceName: "sdb1",
+			sysPath:    "devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdb/sdb1/",
+			files: map[string]string{
+				"devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdb/device/state": "offline",
+			},
+			wantReady: false,
+			wantState: "offline",
+		},
+		"nvme namespace of a live controller": {
+			deviceName: "nvme0n1",
+			sysPath:    "devices/pci0000:00/0000:00:0e.0/nvme/nvme0/nvme0n1/",
+			files: map[string]string{
+				"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/transport": "pcie",
+				"devices/pci0000:00/0000:00:0e.0/nvme/nvme0/state":     "live",
+			},
+			wantReady: true,
+			wantState: "live",
+		},
+		"nvme namespace of a connecting controller": {
+			deviceName: "nvme1n1",
+			sysPath:    "devices/virtual/nvme-fabrics/ctl/nvme1/nvme1n1/",
+			files: map[string]string{
+				"devices/virtual/nvme-fabrics/ctl/nvme1/transport": "tcp",
+				"devices/virtual/nvme-fabrics/ctl/nvme1/state":     "connecting",
+			},
+			wantReady: false,
+			wantState: "connecting",
+		},
+		"device without state": {
+			deviceName: "dm-0",
+			sysPath:    "devices/virtual/block/dm-0/",
+			files: map[string]string{
+				"devices/virtual/block/dm-0/size": "2048",
+			},
+			wantReady: true,
+			wantState: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeSysFSFiles(t, root, tt.files)
+			s := Device{
+				deviceName: tt.deviceName,
+				path:       "/dev/" + tt.deviceName,
+				sysPath:    filepath.Join(root, tt.sysPath) + "/",
+			}
+			gotReady, gotState := s.IsReady()
+			assert.Equal(t, tt.wantReady, gotReady)
+			assert.Equal(t, tt.wantState, gotState)
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/openebs/node-disk-manager/blockdevice"
@@ -40,6 +41,9 @@ const (
 
 var sysFSDirectoryPath = "/sys/"
 
+// ramDiskRegex matches the RAM disks created by the brd driver, like ram0
+var ramDiskRegex = regexp.MustCompile(`^ram[0-9]+$`)
+
 // getDeviceSysPath gets the syspath struct for the given blockdevice.
 // It is generated by evaluating the symlink in /sys/class/block.
 func getDeviceSysPath(devicePath string) (string, error) {
@@ -247,6 +251,39 @@ func (s Device) GetDriveType() (string, error) {
 	return blockdevice.DriveTypeUnknown, fmt.Errorf("undefined rotational value %d", rotational)
 }
 
+// IsRemovable checks if the device is a removable media. A device is considered removable if
+// the kernel reports it as removable (/sys/class/block/sda/removable) or if it is
+// connected over USB, since most external USB drives report themselves as non-removable.
+// Partitions do not have the removable attribute, the parent disk is checked for them.
+func (s Device) IsRemovable() (bool, error) {
+	if s.isUSB() {
+		return true, nil
+	}
+	sysPath := s.sysPath
+	if _, ok := s.getParent(); ok {
+		// the syspath of a partition is within the syspath of its parent disk, eg:
+		// /sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1/
+		sysPath = filepath.Dir(strings.TrimSuffix(s.sysPath, "/")) + "/"
+	}
+	removable, err := readSysFSFileAsInt64(sysPath + "removable")
+	if err != nil {
+		return false, err
+	}
+	return removable == 1, nil
+}
+
+// isUSB checks if the device is connected over USB transport. The syspath of
+// USB devices will be similar to
+// /sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdc/
+func (s Device) isUSB() bool {
+	for _, part := range strings.Split(s.sysPath, "/") {
+		if strings.HasPrefix(part, "usb") {
+			return true
+		}
+	}
+	return false
+}
+
 // GetCapacityInBytes gets the capacity of the device in bytes
 func (s Device) GetCapacityInBytes() (int64, error) {
 	// The size (/size) entry returns the `nr_sects` field of the block device structure.
@@ -299,6 +336,10 @@ func (s Device) GetDeviceType(devType string) (string, error) {
 		if len(result) == 0 {
 			result = blockdevice.BlockDeviceTypeDMDevice
 		}
+	} else if s.isZRAM() {
+		result = blockdevice.BlockDeviceTypeZRAM
+	} else if ramDiskRegex.MatchString(s.deviceName) {
+		result = blockdevice.BlockDeviceTypeRAMDisk
 	} else if len(s.deviceName) >= 4 && s.deviceName[0:4] == "loop" {
 		result = blockdevice.BlockDeviceTypeLoop
 	} else if len(s.deviceName) >= 2 && s.deviceName[0:2] == "md" {
@@ -318,6 +359,16 @@ func (s Device) GetDeviceType(devType string) (string, error) {
 	return strings.ToLower(result), nil
 }
 
+// isZRAM checks if the device is a zram device. The comp_algorithm attribute, which
+// is present only for zram devices, is also checked, so that the device is identified
+// even if it has been renamed.
+func (s Device) isZRAM() bool {
+	if strings.HasPrefix(s.deviceName, "zram") {
+		return true
+	}
+	return fileExists(s.sysPath + "comp_algorithm")
+}
+
 func isDM(devName string) bool {
 	return devName[0:3] == "dm-"
 }
@@ -582,6 +582,106 @@ func TestSysFsDeviceGetDriveType(t *testing.T) {
 	}
 }
 
+func TestSysFsDeviceIsRemovable(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := map[string]struct {
+		sysfsDevice     *Device
+		removable       string
+		parentRemovable string
+		want            bool
+		wantErr         bool
+	}{
+		"no removable file in syspath": {
+			sysfsDevice: &Device{
+				deviceName: "sda",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/",
+				path: "/dev/sda",
+			},
+			removable: "",
+			want:      false,
+			wantErr:   true,
+		},
+		"non removable ata device": {
+			sysfsDevice: &Device{
+				deviceName: "sda",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/",
+				path: "/dev/sda",
+			},
+			removable: "0",
+			want:      false,
+			wantErr:   false,
+		},
+		"removable ata device": {
+			sysfsDevice: &Device{
+				deviceName: "sda",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/",
+				path: "/dev/sda",
+			},
+			removable: "1",
+			want:      true,
+			wantErr:   false,
+		},
+		"usb device reported as non removable": {
+			sysfsDevice: &Device{
+				deviceName: "sdc",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdc") + "/",
+				path: "/dev/sdc",
+			},
+			removable: "0",
+			want:      true,
+			wantErr:   false,
+		},
+		"partition of removable ata device": {
+			sysfsDevice: &Device{
+				deviceName: "sdb1",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sdb/sdb1") + "/",
+				path: "/dev/sdb1",
+			},
+			parentRemovable: "1",
+			want:            true,
+			wantErr:         false,
+		},
+		"partition of non removable ata device": {
+			sysfsDevice: &Device{
+				deviceName: "sdd1",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sdd/sdd1") + "/",
+				path: "/dev/sdd1",
+			},
+			parentRemovable: "0",
+			want:            false,
+			wantErr:         false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.MkdirAll(tt.sysfsDevice.sysPath, 0700)
+			if len(tt.removable) != 0 {
+				file, _ := os.Create(filepath.Join(tt.sysfsDevice.sysPath, "removable"))
+				file.Write([]byte(tt.removable))
+				file.Close()
+			}
+			if len(tt.parentRemovable) != 0 {
+				file, _ := os.Create(filepath.Join(filepath.Dir(filepath.Clean(tt.sysfsDevice.sysPath)), "removable"))
+				file.Write([]byte(tt.parentRemovable))
+				file.Close()
+			}
+			got, err := tt.sysfsDevice.IsRemovable()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IsRemovable() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			os.RemoveAll(tt.sysfsDevice.sysPath)
+		})
+	}
+}
+
 func TestSysFsDeviceGetCapacityInBytes(t *testing.T) {
 	tmpDir := t.TempDir()
 	tests := map[string]struct {
@@ -774,6 +874,42 @@ func TestSysFsDeviceGetDeviceType(t *testing.T) {
 			want:             blockdevice.BlockDeviceTypeDMDevice,
 			wantErr:          false,
 		},
+		"device is a zram device": {
+			sysfsDevice: &Device{
+				deviceName: "zram0",
+				path:       "/dev/zram0",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/virtual/block/zram0") + "/",
+			},
+			devType: blockdevice.BlockDeviceTypeDisk,
+			want:    blockdevice.BlockDeviceTypeZRAM,
+			wantErr: false,
+		},
+		"device is a renamed zram device": {
+			sysfsDevice: &Device{
+				deviceName: "swap0",
+				path:       "/dev/swap0",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/virtual/block/swap0") + "/",
+			},
+			devType:          blockdevice.BlockDeviceTypeDisk,
+			subDirectoryName: ".",
+			subFileName:      "comp_algorithm",
+			subFileContent:   "lzo lzo-rle [zstd]",
+			want:             blockdevice.BlockDeviceTypeZRAM,
+			wantErr:          false,
+		},
+		"device is a RAM disk": {
+			sysfsDevice: &Device{
+				deviceName: "ram12",
+				path:       "/dev/ram12",
+				sysPath: filepath.Join(tmpDir,
+					"sys/devices/virtual/block/ram12") + "/",
+			},
+			devType: blockdevice.BlockDeviceTypeDisk,
+			want:    blockdevice.BlockDeviceTypeRAMDisk,
+			wantErr: false,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+)
+
+// localTransportRegexes are the components of the syspath of a device identifying
+// the transport over which it is attached, in the order in which they are checked
+var localTransportRegexes = []struct {
+	transport string
+	regex     *regexp.Regexp
+}{
+	{blockdevice.TransportUSB, regexp.MustCompile(`^usb[0-9]+$`)},
+	{blockdevice.TransportISCSI, iscsiSessionRegex},
+	{blockdevice.TransportSAS, regexp.MustCompile(`^end_device-[0-9:]+$`)},
+	{blockdevice.TransportSATA, regexp.MustCompile(`^ata[0-9]+$`)},
+	{blockdevice.TransportVirtio, regexp.MustCompile(`^virtio[0-9]+$`)},
+}
+
+// GetLocalTransport gets the transport of the devices that are not NVMe / iSER devices
+// from the components of their syspath. The syspath of a sata disk will be similar to
+// /sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/
+// and that of a usb disk will be similar to
+// /sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdb/
+// An empty string is returned if the transport cannot be identified.
+func (s Device) GetLocalTransport() string {
+	parts := strings.Split(s.sysPath, "/")
+	for _, t := range localTransportRegexes {
+		for _, part := range parts {
+			if t.regex.MatchString(part) {
+				return t.transport
+			}
+		}
+	}
+	return ""
+}
+
+// virtioDeviceRegex is the component of the syspath of a virtio disk for its virtio device
+var virtioDeviceRegex = regexp.MustCompile(`^virtio[0-9]+$`)
+
+// GetVirtioBusAddress gets the PCI address of the virtio device of a virtio disk. It is
+// the component of the syspath preceding the virtio device, eg: 0000:00:04.0 for
+// /sys/devices/pci0000:00/0000:00:04.0/virtio1/block/vda/
+// false is returned if the device is not a virtio disk attached over PCI.
+func (s Device) GetVirtioBusAddress() (string, bool) {
+	parts := strings.Split(s.sysPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if virtioDeviceRegex.MatchString(parts[i]) && pciAddressRegex.MatchString(parts[i-1]) {
+			return parts[i-1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"testing"
+
+	"github.com/openebs/node-disk-manager/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSysFsDeviceGetLocalTransport(t *testing.T) {
+	tests := map[string]struct {
+		sysPath string
+		want    string
+	}{
+		"sata disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			want:    blockdevice.TransportSATA,
+		},
+		"partition of a sata disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1/",
+			want:    blockdevice.TransportSATA,
+		},
+		"sas disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/host0/port-0:0/end_device-0:0/target0:0:0/0:0:0:0/block/sdb/",
+			want:    blockdevice.TransportSAS,
+		},
+		"usb disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdc/",
+			want:    blockdevice.TransportUSB,
+		},
+		"iscsi disk": {
+			sysPath: "/sys/devices/platform/host4/session2/target4:0:0/4:0:0:1/block/sdd/",
+			want:    blockdevice.TransportISCSI,
+		},
+		"virtio disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:04.0/virtio1/block/vda/",
+			want:    blockdevice.TransportVirtio,
+		},
+		"dm device": {
+			sysPath: "/sys/devices/virtual/block/dm-0/",
+			want:    "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := Device{
+				sysPath: tt.sysPath,
+			}
+			assert.Equal(t, tt.want, s.GetLocalTransport())
+		})
+	}
+}
+
+func TestGetVirtioBusAddress(t *testing.T) {
+	tests := map[string]struct {
+		sysPath string
+		want    string
+		wantOk  bool
+	}{
+		"virtio disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:05.0/virtio2/block/vdb/",
+			want:    "0000:00:05.0",
+			wantOk:  true,
+		},
+		"virtio disk behind a pci bridge": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/virtio3/block/vdc/",
+			want:    "0000:01:00.0",
+			wantOk:  true,
+		},
+		"sata disk": {
+			sysPath: "/sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/",
+			want:    "",
+			wantOk:  false,
+		},
+		"virtio disk over mmio": {
+			sysPath: "/sys/devices/platform/a003e00.virtio_mmio/virtio31/block/vda/",
+			want:    "",
+			wantOk:  false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := Device{
+				sysPath: tt.sysPath,
+			}
+			got, gotOk := s.GetVirtioBusAddress()
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, gotOk)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// writeCacheWriteBack is the cache type of a device with a volatile write cache
+	writeCacheWriteBack = "write back"
+	// writeCacheWriteThrough is the cache type of a device without a volatile write
+	// cache, or whose write cache is disabled
+	writeCacheWriteThrough = "write through"
+)
+
+// GetWriteCacheEnabled gets whether the volatile write cache of the device is enabled,
+// as reported by /sys/class/block/sda/queue/write_cache
+func (s Device) GetWriteCacheEnabled() (bool, error) {
+	cacheType, err := readSysFSFileAsString(s.sysPath + "queue/write_cache")
+	if err != nil {
+		return false, err
+	}
+	switch cacheType {
+	case writeCacheWriteBack:
+		return true, nil
+	case writeCacheWriteThrough:
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown write cache type: %q of device: %s", cacheType, s.path)
+}
+
+// DisableWriteCache disables the volatile write cache of a SCSI disk, by writing to
+// /sys/class/block/sda/device/scsi_disk/0:0:0:0/cache_type, upon which the sd driver
+// clears the WCE bit in the caching mode page of the disk. Writing to queue/write_cache
+// only changes how the kernel treats the device, and not the cache of the disk. So
+// the other devices, eg: NVMe, are not supported.
+func (s Device) DisableWriteCache() error {
+	cacheTypeFiles, err := filepath.Glob(s.sysPath + "device/scsi_disk/*/cache_type")
+	if err != nil {
+		return err
+	}
+	if len(cacheTypeFiles) != 1 {
+		return fmt.Errorf("disabling write cache of device: %s is not supported, not a scsi disk", s.path)
+	}
+	return os.WriteFile(cacheTypeFiles[0], []byte(writeCacheWriteThrough), 0600)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWriteCacheEnabled(t *testing.T) {
+	tests := map[string]struct {
+		writeCache  string
+		wantEnabled bool
+		wantErr     bool
+	}{
+		"write cache enabled": {
+			writeCache:  "write back",
+			wantEnabled: true,
+		},
+		"write cache disabled": {
+			writeCache:  "write through",
+			wantEnabled: false,
+		},
+		"unknown write cache type": {
+			writeCache: "none",
+			wantErr:    true,
+		},
+		"write cache attribute missing": {
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysPath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda") + "/"
+			assert.NoError(t, os.MkdirAll(sysPath+"queue", 0700))
+			if tt.writeCache != "" {
+				assert.NoError(t, os.WriteFile(sysPath+"queue/write_cache", []byte(tt.writeCache+"\n"), 0600))
+			}
+			s := Device{
+				deviceName: "sda",
+				path:       "/dev/sda",
+				sysPath:    sysPath,
+			}
+
+			enabled, err := s.GetWriteCacheEnabled()
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantEnabled, enabled)
+		})
+	}
+}
+
+func TestDisableWriteCache(t *testing.T) {
+	tests := map[string]struct {
+		scsiDisk bool
+		wantErr  bool
+	}{
+		"write cache of scsi disk is disabled": {
+			scsiDisk: true,
+		},
+		"device other than scsi disk is not supported": {
+			scsiDisk: false,
+			wantErr:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			devicePath := filepath.Join(t.TempDir(), "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0")
+			sysPath := devicePath + "/block/sda/"
+			assert.NoError(t, os.MkdirAll(sysPath, 0700))
+			assert.NoError(t, os.Symlink(devicePath, sysPath+"device"))
+			cacheTypeFile := filepath.Join(devicePath, "scsi_disk/0:0:0:0/cache_type")
+			if tt.scsiDisk {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(cacheTypeFile), 0700))
+				assert.NoError(t, os.WriteFile(cacheTypeFile, []byte("write back\n"), 0600))
+			}
+			s := Device{
+				deviceName: "sda",
+				path:       "/dev/sda",
+				sysPath:    sysPath,
+			}
+
+			err := s.DisableWriteCache()
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.scsiDisk {
+				cacheType, err := os.ReadFile(cacheTypeFile)
+				assert.NoError(t, err)
+				assert.Equal(t, "write through", string(cacheType))
+			}
+		})
+	}
+}
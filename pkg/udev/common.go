@@ -65,16 +65,20 @@ const (
 	LINK_ID_INDEX             = 4                      // this is used to get link index from dev link
 	UDEV_FS_TYPE              = "ID_FS_TYPE"           // file system type the partition
 	UDEV_FS_UUID              = "ID_FS_UUID"           // UUID of the filesystem present
+	UDEV_FS_LABEL             = "ID_FS_LABEL"          // label of the filesystem present
 	UDEV_PARTITION_TABLE_TYPE = "ID_PART_TABLE_TYPE"   // udev attribute to get partition table type(gpt/dos)
 	UDEV_PARTITION_TABLE_UUID = "ID_PART_TABLE_UUID"   // udev attribute to get partition table UUID
 	UDEV_PARTITION_NUMBER     = "ID_PART_ENTRY_NUMBER" // udev attribute to get partition number
 	UDEV_PARTITION_UUID       = "ID_PART_ENTRY_UUID"   // udev attribute to get partition uuid
 	UDEV_PARTITION_TYPE       = "ID_PART_ENTRY_TYPE"   // udev attribute to get partition type
+	UDEV_PARTITION_NAME       = "ID_PART_ENTRY_NAME"   // udev attribute to get partition name (gpt)
 	UDEV_DM_UUID              = "DM_UUID"              // udev attribute to get the device mapper uuid
 	// UDEV_DM_NAME is udev attribute to get the name of the dm device. This is used to generate the device mapper path
 	UDEV_DM_NAME = "DM_NAME"
 	// SYMLINK is used to represent any manually created device symlinks
 	SYMLINK = "symlink"
+	// DM_NAME_LINK is used to represent the /dev/mapper link having the friendly name of a dm device
+	DM_NAME_LINK = "dm-name"
 )
 
 // UdevDiskDetails struct contain different attribute of disk.
@@ -91,14 +95,20 @@ type UdevDiskDetails struct {
 	// IDType is used for uuid generation using the legacy algorithm
 	IDType     string
 	FileSystem string // FileSystem on the disk
+	// FileSystemLabel is the label of the filesystem on the disk
+	FileSystemLabel string
 	// Partitiontype on the disk/device
 	PartitionType string
+	// PartitionName is the name of the gpt partition
+	PartitionName string
 	// PartitionNumber is the partition number, for /dev/sdb1, partition number is 1
 	PartitionNumber uint8
 	// PartitionTableType is the type of the partition table (dos/gpt)
 	PartitionTableType string
 	// DMPath is the /dev/mapper path if this is a dm device
 	DMPath string
+	// Properties contains all the udev properties of the device
+	Properties map[string]string
 }
 
 // freeCharPtr frees c pointer
@@ -121,9 +131,12 @@ func (device *UdevDevice) DiskInfoFromLibudev() UdevDiskDetails {
 		DiskType:           device.GetDevtype(),
 		IDType:             device.GetPropertyValue(UDEV_TYPE),
 		FileSystem:         device.GetFileSystemInfo(),
+		FileSystemLabel:    device.GetPropertyValue(UDEV_FS_LABEL),
 		PartitionType:      device.GetPartitionType(),
+		PartitionName:      device.GetPropertyValue(UDEV_PARTITION_NAME),
 		PartitionNumber:    device.GetPartitionNumber(),
 		PartitionTableType: device.GetPropertyValue(UDEV_PARTITION_TABLE_TYPE),
+		Properties:         device.GetProperties(),
 	}
 	// get the devicemapper path from the dm name
 	dmName := device.GetPropertyValue(UDEV_DM_NAME)
@@ -106,6 +106,9 @@ func TestDiskInfoFromLibudev(t *testing.T) {
 			test.actualDetails.ByIdDevLinks = nil
 			test.actualDetails.ByPathDevLinks = nil
 			test.actualDetails.SymLinks = nil
+			// the properties contain all the udev properties, only the device name is compared
+			assert.Equal(t, test.expectedDetails.Path, test.actualDetails.Properties[UDEV_DEVNAME])
+			test.actualDetails.Properties = nil
 			assert.Equal(t, test.expectedDetails, test.actualDetails)
 		})
 	}
@@ -49,6 +49,16 @@ func (ud *UdevDevice) GetPropertyValue(key string) string {
 	return C.GoString(C.udev_device_get_property_value(ud.udptr, k))
 }
 
+// GetProperties retrieves all the properties of the device as a map of the
+// property name to its value
+func (ud *UdevDevice) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	for entry := newUdevListEntry(C.udev_device_get_properties_list_entry(ud.udptr)); entry != nil; entry = entry.GetNextEntry() {
+		properties[entry.GetName()] = entry.GetValue()
+	}
+	return properties
+}
+
 // GetSysattrValue retrieves the content of a sys attribute file
 // returns an empty string if there is no sys attribute value.
 // The retrieved value is cached in the device. Repeated calls
@@ -41,6 +41,26 @@ func TestGetPropertyValue(t *testing.T) {
 	assert.Equal(t, diskDetails.DevType, deviceType)
 }
 
+func TestGetProperties(t *testing.T) {
+	diskDetails, err := MockDiskDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newUdev, err := NewUdev()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newUdev.UnrefUdev()
+	device, err := newUdev.NewDeviceFromSysPath(diskDetails.SysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.UdevDeviceUnref()
+	properties := device.GetProperties()
+	assert.Equal(t, device.GetPropertyValue(UDEV_TYPE), properties[UDEV_TYPE])
+	assert.Equal(t, diskDetails.DevNode, properties[UDEV_DEVNAME])
+}
+
 func TestGetSysattrValue(t *testing.T) {
 	diskDetails, err := MockDiskDetails()
 	if err != nil {
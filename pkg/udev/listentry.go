@@ -49,3 +49,8 @@ func (le *UdevListEntry) GetNextEntry() *UdevListEntry {
 func (le *UdevListEntry) GetName() string {
 	return C.GoString(C.udev_list_entry_get_name(le.listEntry))
 }
+
+// GetValue returns the value of the list entry, eg: the value of a device property.
+func (le *UdevListEntry) GetValue() string {
+	return C.GoString(C.udev_list_entry_get_value(le.listEntry))
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package zfs reads the vdev label of a ZFS pool member from the device, so that the
+// members of a pool are detected even if blkid or the partition layout does not reveal
+// them, like the members of cStor pools.
+package zfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// labelSize is the size of each of the 4 vdev labels, 2 at the start and 2 at the
+	// end of the device
+	labelSize = 256 * 1024
+
+	// vdevPhysOffset is the offset of the packed nvlist of the pool config in a label
+	vdevPhysOffset = 16 * 1024
+
+	// vdevPhysSize is the size of the packed nvlist of the pool config in a label
+	vdevPhysSize = 112 * 1024
+
+	// encodingXDR is the nvlist encoding used in the vdev labels
+	encodingXDR = 1
+
+	// data types of the nvpairs that are read from the label
+	dataTypeUint64      = 8
+	dataTypeString      = 9
+	dataTypeNvlist      = 19
+	dataTypeNvlistArray = 20
+
+	// poolStateDestroyed is the state of a destroyed pool, whose members can be reused
+	poolStateDestroyed = 2
+
+	// CStorPoolPrefix is the prefix of the name of the pools created by cStor
+	CStorPoolPrefix = "cstor-"
+)
+
+// Layouts of the top level vdev of a pool member
+const (
+	LayoutStripe = "stripe"
+	LayoutMirror = "mirror"
+	LayoutRAIDZ  = "raidz"
+)
+
+// Label is the config of the pool read from the vdev label of a pool member
+type Label struct {
+	// PoolName is the name of the pool
+	PoolName string
+	// PoolGUID is the guid of the pool
+	PoolGUID uint64
+	// Layout is the layout of the top level vdev having the member, eg: mirror
+	Layout string
+}
+
+// IsCStorPool checks if the pool was created by cStor
+func (l *Label) IsCStorPool() bool {
+	return strings.HasPrefix(l.PoolName, CStorPoolPrefix)
+}
+
+// GetLabel reads the vdev label from the device. The labels at the start of the device
+// are tried before those at the end, and any valid label is accepted, so that the members
+// of faulted or offline vdevs having damaged labels are still found. nil is returned if
+// the device does not have a valid label or the pool was destroyed.
+func GetLabel(devPath string) (*Label, error) {
+	f, err := os.Open(filepath.Clean(devPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the size of block devices is not available from stat
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting size of %s: %v", devPath, err)
+	}
+	label, err := ReadLabel(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("error reading zfs label from %s: %v", devPath, err)
+	}
+	return label, nil
+}
+
+// ReadLabel reads the vdev label from a device of the given size. A label that cannot be
+// read, eg: due to a media error, is skipped and the other labels are tried. An error is
+// returned only if none of the labels could be read.
+func ReadLabel(r io.ReaderAt, size int64) (*Label, error) {
+	// the labels at the end are at the end of the device size aligned to the label size
+	alignedSize := size &^ (labelSize - 1)
+	offsets := []int64{0, labelSize, alignedSize - 2*labelSize, alignedSize - labelSize}
+	var readErr error
+	read := 0
+	for i, offset := range offsets {
+		// small devices do not have the labels at the end
+		if offset < 0 || (i >= 2 && offset < 2*labelSize) || offset+labelSize > size {
+			continue
+		}
+		buf := make([]byte, vdevPhysSize)
+		if _, err := r.ReadAt(buf, offset+vdevPhysOffset); err != nil && !errors.Is(err, io.EOF) {
+			readErr = fmt.Errorf("error reading label at offset %d: %v", offset, err)
+			continue
+		}
+		read++
+		config, err := unpackNvlist(buf)
+		if err != nil {
+			continue
+		}
+		if label, ok := newLabel(config); ok {
+			return label, nil
+		}
+	}
+	if read == 0 && readErr != nil {
+		return nil, readErr
+	}
+	return nil, nil
+}
+
+// newLabel gets the label from the pool config. false is returned if the config does
+// not belong to a pool member.
+func newLabel(config nvlist) (*Label, bool) {
+	name, ok := config["name"].(string)
+	if !ok || name == "" {
+		return nil, false
+	}
+	guid, ok := config["pool_guid"].(uint64)
+	if !ok {
+		return nil, false
+	}
+	if state, ok := config["state"].(uint64); ok && state == poolStateDestroyed {
+		return nil, false
+	}
+	label := &Label{
+		PoolName: name,
+		PoolGUID: guid,
+	}
+	if vdevTree, ok := config["vdev_tree"].(nvlist); ok {
+		label.Layout = getLayout(vdevTree)
+	}
+	return label, true
+}
+
+// getLayout gets the layout from the type of the top level vdev
+func getLayout(vdevTree nvlist) string {
+	vdevType, _ := vdevTree["type"].(string)
+	switch vdevType {
+	case "disk", "file":
+		return LayoutStripe
+	case "mirror":
+		return LayoutMirror
+	case "raidz":
+		if parity, ok := vdevTree["nparity"].(uint64); ok {
+			return fmt.Sprintf("%s%d", LayoutRAIDZ, parity)
+		}
+		return LayoutRAIDZ
+	}
+	return vdevType
+}
+
+// nvlist is an unpacked nvlist. Only the values of the types read from the label are
+// unpacked, values of other types are skipped.
+type nvlist map[string]interface{}
+
+// unpackNvlist unpacks the XDR encoded nvlist
+func unpackNvlist(buf []byte) (nvlist, error) {
+	// the header has the encoding, the endianness and 2 reserved bytes
+	if len(buf) < 4 || buf[0] != encodingXDR {
+		return nil, fmt.Errorf("not an xdr encoded nvlist")
+	}
+	d := &xdrDecoder{buf: buf, offset: 4}
+	return d.nvlist()
+}
+
+// xdrDecoder decodes the XDR encoded nvlist. All the values are big endian and are
+// padded to 4 bytes.
+type xdrDecoder struct {
+	buf    []byte
+	offset int
+}
+
+var errShortBuffer = errors.New("nvlist exceeds the buffer")
+
+// minNvlistSize is the size of the smallest nvlist, having the version, the flags and
+// the terminating pair of zero sizes
+const minNvlistSize = 16
+
+func (d *xdrDecoder) uint32() (uint32, error) {
+	if d.offset+4 > len(d.buf) {
+		return 0, errShortBuffer
+	}
+	v := binary.BigEndian.Uint32(d.buf[d.offset:])
+	d.offset += 4
+	return v, nil
+}
+
+func (d *xdrDecoder) uint64() (uint64, error) {
+	if d.offset+8 > len(d.buf) {
+		return 0, errShortBuffer
+	}
+	v := binary.BigEndian.Uint64(d.buf[d.offset:])
+	d.offset += 8
+	return v, nil
+}
+
+func (d *xdrDecoder) string() (string, error) {
+	length, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	padded := (int(length) + 3) &^ 3
+	if length > uint32(len(d.buf)) || d.offset+padded > len(d.buf) {
+		return "", errShortBuffer
+	}
+	s := string(d.buf[d.offset : d.offset+int(length)])
+	d.offset += padded
+	return s, nil
+}
+
+// nvlist decodes an nvlist, having the version, the flags and the pairs terminated by
+// a pair of zero sizes
+func (d *xdrDecoder) nvlist() (nvlist, error) {
+	// version and flags
+	if _, err := d.uint32(); err != nil {
+		return nil, err
+	}
+	if _, err := d.uint32(); err != nil {
+		return nil, err
+	}
+
+	list := make(nvlist)
+	for {
+		start := d.offset
+		encodedSize, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		decodedSize, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if encodedSize == 0 && decodedSize == 0 {
+			return list, nil
+		}
+		end := start + int(encodedSize)
+		if encodedSize > uint32(len(d.buf)) || end > len(d.buf) || end <= d.offset {
+			return nil, errShortBuffer
+		}
+
+		name, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		dataType, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		nelem, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+
+		switch dataType {
+		case dataTypeUint64:
+			list[name], err = d.uint64()
+		case dataTypeString:
+			list[name], err = d.string()
+		case dataTypeNvlist:
+			list[name], err = d.nvlist()
+		case dataTypeNvlistArray:
+			// nelem is read from the disk, it cannot exceed the number of the smallest
+			// nvlists that fit in the rest of the pair
+			if int64(nelem) > int64(end-d.offset)/minNvlistSize {
+				return nil, errShortBuffer
+			}
+			lists := make([]nvlist, 0)
+			for i := uint32(0); i < nelem && err == nil; i++ {
+				var l nvlist
+				if l, err = d.nvlist(); err == nil {
+					lists = append(lists, l)
+				}
+			}
+			list[name] = lists
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the encoded size covers the whole pair, including any embedded nvlists
+		d.offset = end
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2023 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mirrorMemberLabel is the packed nvlist of the vdev label of a member of a mirrored
+// cStor pool, having /dev/sdb1 and /dev/sdc1 as the children of the mirror
+var mirrorMemberLabel = mustDecodeHex("" +
+	"010100000000000000000001000000240000002400000007" +
+	"76657273696f6e0000000008000000010000000000001388" +
+	"0000004800000048000000046e616d650000000900000001" +
+	"0000002a6373746f722d35653366326131622d366334642d" +
+	"346538662d396130622d3163326433653466356136620000" +
+	"000000240000002400000005737461746500000000000008" +
+	"000000010000000000000000000000200000002000000003" +
+	"747867000000000800000001000000000000000400000028" +
+	"0000002800000009706f6f6c5f6775696400000000000008" +
+	"000000011f2e3d4c5b6a7988000000240000002400000008" +
+	"746f705f6775696400000008000000012a3b4c5d6e7f8091" +
+	"000000200000002000000004677569640000000800000001" +
+	"3b4c5d6e7f8091020000002c0000002c0000000d76646576" +
+	"5f6368696c6472656e000000000000080000000100000000" +
+	"00000001000002300000023000000009766465765f747265" +
+	"650000000000001300000001000000000000000100000024" +
+	"000000240000000474797065000000090000000100000006" +
+	"6d6972726f72000000000020000000200000000269640000" +
+	"000000080000000100000000000000000000002000000020" +
+	"000000046775696400000008000000012a3b4c5d6e7f8091" +
+	"0000019c0000019c000000086368696c6472656e00000014" +
+	"000000020000000000000001000000200000002000000004" +
+	"747970650000000900000001000000046469736b00000020" +
+	"000000200000000269640000000000080000000100000000" +
+	"000000000000002000000020000000046775696400000008" +
+	"000000013b4c5d6e7f809102000000280000002800000004" +
+	"706174680000000900000001000000092f6465762f736462" +
+	"3100000000000028000000280000000a77686f6c655f6469" +
+	"736b00000000000800000001000000000000000100000000" +
+	"000000000000000000000001000000200000002000000004" +
+	"747970650000000900000001000000046469736b00000020" +
+	"000000200000000269640000000000080000000100000000" +
+	"000000000000002000000020000000046775696400000008" +
+	"000000014c5d6e7f80910213000000280000002800000004" +
+	"706174680000000900000001000000092f6465762f736463" +
+	"3100000000000028000000280000000a77686f6c655f6469" +
+	"736b00000000000800000001000000000000000100000000" +
+	"000000000000000000000000000000380000003800000011" +
+	"66656174757265735f666f725f7265616400000000000013" +
+	"000000010000000000000001000000000000000000000000" +
+	"00000000")
+
+// stripeMemberLabel is the packed nvlist of the vdev label of a member of a striped
+// cStor pool, the disk being the top level vdev
+var stripeMemberLabel = mustDecodeHex("" +
+	"010100000000000000000001000000240000002400000007" +
+	"76657273696f6e0000000008000000010000000000001388" +
+	"0000004800000048000000046e616d650000000900000001" +
+	"0000002a6373746f722d35653366326131622d366334642d" +
+	"346538662d396130622d3163326433653466356136620000" +
+	"000000240000002400000005737461746500000000000008" +
+	"000000010000000000000000000000200000002000000003" +
+	"747867000000000800000001000000000000000400000028" +
+	"0000002800000009706f6f6c5f6775696400000000000008" +
+	"000000011f2e3d4c5b6a7988000000240000002400000008" +
+	"746f705f6775696400000008000000012a3b4c5d6e7f8091" +
+	"000000200000002000000004677569640000000800000001" +
+	"3b4c5d6e7f8091020000002c0000002c0000000d76646576" +
+	"5f6368696c6472656e000000000000080000000100000000" +
+	"00000001000000e0000000e000000009766465765f747265" +
+	"650000000000001300000001000000000000000100000020" +
+	"000000200000000474797065000000090000000100000004" +
+	"6469736b0000002000000020000000026964000000000008" +
+	"000000010000000000000000000000200000002000000004" +
+	"6775696400000008000000012a3b4c5d6e7f809100000028" +
+	"000000280000000470617468000000090000000100000009" +
+	"2f6465762f7364623100000000000028000000280000000a" +
+	"77686f6c655f6469736b0000000000080000000100000000" +
+	"000000010000000000000000000000380000003800000011" +
+	"66656174757265735f666f725f7265616400000000000013" +
+	"000000010000000000000001000000000000000000000000" +
+	"00000000")
+
+const (
+	// deviceSize is the size of the device images used in the tests
+	deviceSize = 4 * 1024 * 1024
+
+	// cStorPoolName is the name of the pool in the fixtures
+	cStorPoolName = "cstor-5e3f2a1b-6c4d-4e8f-9a0b-1c2d3e4f5a6b"
+
+	// cStorPoolGUID is the guid of the pool in the fixtures
+	cStorPoolGUID = 0x1f2e3d4c5b6a7988
+)
+
+func mustDecodeHex(s string) []byte {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// withPoolState returns a copy of the label having the given pool state
+func withPoolState(label []byte, state uint64) []byte {
+	buf := append([]byte(nil), label...)
+	// the value follows the name, the type and the number of elements
+	i := bytes.Index(buf, []byte("state\x00\x00\x00"))
+	binary.BigEndian.PutUint64(buf[i+16:], state)
+	return buf
+}
+
+// withChildrenCount returns a copy of the label having the given number of elements in
+// the nvlist array of the children of the mirror
+func withChildrenCount(label []byte, nelem uint32) []byte {
+	buf := append([]byte(nil), label...)
+	// the number of elements follows the name and the type
+	i := bytes.Index(buf, []byte("children\x00\x00\x00\x14"))
+	binary.BigEndian.PutUint32(buf[i+12:], nelem)
+	return buf
+}
+
+// corrupt returns a copy of the label having the byte at the offset replaced
+func corrupt(label []byte, offset int, value byte) []byte {
+	buf := append([]byte(nil), label...)
+	buf[offset] = value
+	return buf
+}
+
+// writeDevice creates a device image having the label nvlist in the labels at the
+// given offsets
+func writeDevice(t *testing.T, size int64, label []byte, labelOffsets ...int64) string {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range labelOffsets {
+		if _, err := f.WriteAt(label, offset+vdevPhysOffset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestGetLabel(t *testing.T) {
+	allLabels := []int64{0, labelSize, deviceSize - 2*labelSize, deviceSize - labelSize}
+
+	tests := map[string]struct {
+		size         int64
+		label        []byte
+		labelOffsets []int64
+		want         *Label
+	}{
+		"member of a mirrored cStor pool": {
+			size:         deviceSize,
+			label:        mirrorMemberLabel,
+			labelOffsets: allLabels,
+			want:         &Label{PoolName: cStorPoolName, PoolGUID: cStorPoolGUID, Layout: LayoutMirror},
+		},
+		"member of a striped cStor pool": {
+			size:         deviceSize,
+			label:        stripeMemberLabel,
+			labelOffsets: allLabels,
+			want:         &Label{PoolName: cStorPoolName, PoolGUID: cStorPoolGUID, Layout: LayoutStripe},
+		},
+		"member having only the labels at the end": {
+			size:         deviceSize,
+			label:        mirrorMemberLabel,
+			labelOffsets: allLabels[2:],
+			want:         &Label{PoolName: cStorPoolName, PoolGUID: cStorPoolGUID, Layout: LayoutMirror},
+		},
+		"member on a device not aligned to the label size": {
+			size:         deviceSize + 4096,
+			label:        stripeMemberLabel,
+			labelOffsets: allLabels[2:],
+			want:         &Label{PoolName: cStorPoolName, PoolGUID: cStorPoolGUID, Layout: LayoutStripe},
+		},
+		"member of a destroyed pool": {
+			size:         deviceSize,
+			label:        withPoolState(stripeMemberLabel, poolStateDestroyed),
+			labelOffsets: allLabels,
+			want:         nil,
+		},
+		"label with a corrupted header": {
+			size:         deviceSize,
+			label:        corrupt(mirrorMemberLabel, 0, 0xff),
+			labelOffsets: allLabels,
+			want:         nil,
+		},
+		"label with a corrupted pair size": {
+			size:         deviceSize,
+			label:        corrupt(mirrorMemberLabel, 12, 0xff),
+			labelOffsets: allLabels,
+			want:         nil,
+		},
+		"label with a huge number of children": {
+			size:         deviceSize,
+			label:        withChildrenCount(mirrorMemberLabel, 0xffffffff),
+			labelOffsets: allLabels,
+			want:         nil,
+		},
+		"blank device": {
+			size:         deviceSize,
+			label:        nil,
+			labelOffsets: nil,
+			want:         nil,
+		},
+		"device smaller than the labels": {
+			size:         labelSize / 2,
+			label:        nil,
+			labelOffsets: nil,
+			want:         nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeDevice(t, tt.size, tt.label, tt.labelOffsets...)
+			got, err := GetLabel(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("non existent device", func(t *testing.T) {
+		_, err := GetLabel("/dev/non-existent-disk")
+		assert.Error(t, err)
+	})
+}
+
+func TestUnpackNvlistWithHugeArray(t *testing.T) {
+	_, err := unpackNvlist(withChildrenCount(mirrorMemberLabel, 0xffffffff))
+	assert.ErrorIs(t, err, errShortBuffer)
+}
+
+// faultyReader fails the reads below the offset, like a disk having media errors at
+// the start
+type faultyReader struct {
+	r           io.ReaderAt
+	faultyBelow int64
+}
+
+func (f *faultyReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < f.faultyBelow {
+		return 0, errors.New("input/output error")
+	}
+	return f.r.ReadAt(p, off)
+}
+
+func TestReadLabelOfFaultyDevice(t *testing.T) {
+	image := make([]byte, deviceSize)
+	copy(image[deviceSize-2*labelSize+vdevPhysOffset:], mirrorMemberLabel)
+	copy(image[deviceSize-labelSize+vdevPhysOffset:], mirrorMemberLabel)
+
+	// only the labels at the end of the device are readable
+	r := &faultyReader{r: bytes.NewReader(image), faultyBelow: 2 * labelSize}
+	got, err := ReadLabel(r, deviceSize)
+	assert.NoError(t, err)
+	assert.Equal(t, &Label{PoolName: cStorPoolName, PoolGUID: cStorPoolGUID, Layout: LayoutMirror}, got)
+
+	// none of the labels are readable
+	r = &faultyReader{r: bytes.NewReader(image), faultyBelow: deviceSize}
+	got, err = ReadLabel(r, deviceSize)
+	assert.Error(t, err)
+	assert.Nil(t, got)
+}
+
+func TestIsCStorPool(t *testing.T) {
+	assert.True(t, (&Label{PoolName: cStorPoolName}).IsCStorPool())
+	assert.False(t, (&Label{PoolName: "zfspv-pool"}).IsCStorPool())
+}